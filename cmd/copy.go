@@ -186,6 +186,11 @@ type rawCopyCmdArgs struct {
 
 	// when specified, AzCopy deletes the destination blob that has uncommitted blocks, not just the uncommitted blocks
 	deleteDestinationFileIfNecessary bool
+
+	// Optional. The AAD tenant to authenticate to the source with, when it differs from the logged-in tenant.
+	sourceTenantID string
+	// Optional. The auto-login type the source logs in with, when it doesn't share the destination's login.
+	sourceLoginType string
 }
 
 func (raw *rawCopyCmdArgs) parsePatterns(pattern string) (cookedPatterns []string) {
@@ -568,6 +573,25 @@ func (raw rawCopyCmdArgs) cook() (CookedCopyCmdArgs, error) {
 		return cooked, err
 	}
 
+	if raw.sourceTenantID != "" && !cooked.FromTo.IsS2S() {
+		return cooked, errors.New("source-tenant-id is only supported for service to service transfers")
+	}
+	cooked.sourceTenantID = raw.sourceTenantID
+
+	if raw.sourceLoginType != "" {
+		if !cooked.FromTo.IsS2S() {
+			return cooked, errors.New("source-login-type is only supported for service to service transfers")
+		}
+		cooked.sourceLoginType = strings.ToLower(raw.sourceLoginType)
+		// The source logs in again whenever the job is resumed, so it can't prompt the user.
+		if cooked.sourceLoginType == common.AutologinTypeDevice {
+			return cooked, errors.New("source-login-type cannot be DEVICE, as the source must log in without prompting")
+		}
+		if _, err := autoLoginArgs(cooked.sourceLoginType); err != nil {
+			return cooked, fmt.Errorf("invalid source-login-type %q, expected SPN, MSI, AZCLI, PSCRED, WORKLOAD, AZD or AUTO", raw.sourceLoginType)
+		}
+	}
+
 	if !(cooked.FromTo.To() == common.ELocation.Blob() || cooked.FromTo == common.EFromTo.BlobNone() || cooked.FromTo != common.EFromTo.BlobFSNone()) && raw.blobTags != "" {
 		return cooked, errors.New("blob tags can only be set when transferring to blob storage")
 	}
@@ -1240,6 +1264,9 @@ type CookedCopyCmdArgs struct {
 	trailingDot common.TrailingDotOption
 
 	deleteDestinationFileIfNecessary bool
+
+	sourceTenantID  string
+	sourceLoginType string
 }
 
 func (cca *CookedCopyCmdArgs) isRedirection() bool {
@@ -1447,7 +1474,7 @@ func (cca *CookedCopyCmdArgs) getSrcCredential(ctx context.Context, jpo *common.
 		if jpo.S2SSourceCredentialType.IsAzureOAuth() {
 			uotm := GetUserOAuthTokenManagerInstance()
 			// get token from env var or cache
-			if tokenInfo, err := uotm.GetTokenInfo(ctx); err != nil {
+			if tokenInfo, err := uotm.GetTokenInfoForRole(ctx, common.ECredentialRole.Source()); err != nil {
 				return srcCredInfo, err
 			} else if _, err := tokenInfo.GetTokenCredential(); err != nil {
 				// we just verified we can get a token credential
@@ -1495,7 +1522,7 @@ func (cca *CookedCopyCmdArgs) processCopyJobPartOrders() (err error) {
 	if cca.credentialInfo.CredentialType.IsAzureOAuth() {
 		uotm := GetUserOAuthTokenManagerInstance()
 		// Get token from env var or cache.
-		if tokenInfo, err := uotm.GetTokenInfo(ctx); err != nil {
+		if tokenInfo, err := uotm.GetTokenInfoForRole(ctx, common.ECredentialRole.Destination()); err != nil {
 			return err
 		} else {
 			cca.credentialInfo.OAuthTokenInfo = *tokenInfo
@@ -1550,6 +1577,11 @@ func (cca *CookedCopyCmdArgs) processCopyJobPartOrders() (err error) {
 		}
 	}
 
+	if err := useSourceLogin(ctx, cca.sourceLoginType, cca.sourceTenantID); err != nil {
+		return err
+	}
+	jobPartOrder.S2SSourceLoginType = cca.sourceLoginType
+	jobPartOrder.S2SSourceTenantID = cca.sourceTenantID
 	srcCredInfo, err := cca.getSrcCredential(ctx, &jobPartOrder)
 	if err != nil {
 		return err
@@ -1832,7 +1864,7 @@ Number of Folder Transfers Failed: %v
 Number of File Transfers Skipped: %v
 Number of Folder Transfers Skipped: %v
 Total Number of Bytes Transferred: %v
-Final Job Status: %v%s%s
+Final Job Status: %v%s%s%s
 `,
 					summary.JobID.String(),
 					jobsAdmin.ToFixed(duration.Minutes(), 4),
@@ -1848,6 +1880,7 @@ Final Job Status: %v%s%s
 					summary.FoldersSkipped,
 					summary.TotalBytesTransferred,
 					summary.JobStatus,
+					formatAuthStats(summary),
 					screenStats,
 					formatPerfAdvice(summary.PerformanceAdvice))
 
@@ -1926,6 +1959,15 @@ Server Busy: %.2f%%`,
 	return
 }
 
+// formatAuthStats describes the OAuth token refreshes made during the job, if there were any.
+func formatAuthStats(summary common.ListJobSummaryResponse) string {
+	if summary.TokenRefreshes == 0 && summary.CredentialFallbacks == 0 {
+		return ""
+	}
+	return fmt.Sprintf("\nNumber of Token Refreshes: %v (%v failed, average %v ms)\nNumber of Fallbacks to the Current Token: %v",
+		summary.TokenRefreshes, summary.TokenRefreshFailures, summary.AverageTokenRefreshMilliseconds, summary.CredentialFallbacks)
+}
+
 // Is disk speed looking like a constraint on throughput?  Ignore the first little-while,
 // to give an (arbitrary) amount of time for things to reach steady-state.
 func getPerfDisplayText(perfDiagnosticStrings []string, constraint common.PerfConstraint, durationOfJob time.Duration, isBench bool) (perfString string, diskString string) {
@@ -2110,6 +2152,11 @@ func init() {
 	cpCmd.PersistentFlags().StringVar(&raw.trailingDot, "trailing-dot", "", "'Enable' by default to treat file share related operations in a safe manner. Available options: Enable, Disable. "+
 		"Choose 'Disable' to go back to legacy (potentially unsafe) treatment of trailing dot files where the file service will trim any trailing dots in paths. This can result in potential data corruption if the transfer contains two paths that differ only by a trailing dot (ex: mypath and mypath.). If this flag is set to 'Disable' and AzCopy encounters a trailing dot file, it will warn customers in the scanning log but will not attempt to abort the operation."+
 		"If the destination does not support trailing dot files (Windows or Blob Storage), AzCopy will fail if the trailing dot file is the root of the transfer and skip any trailing dot paths encountered during enumeration.")
+	cpCmd.PersistentFlags().StringVar(&raw.sourceTenantID, "source-tenant-id", "", "Authenticate to the source with the given AAD tenant rather than the logged-in tenant. "+
+		"Useful for service to service copies between accounts in different tenants, where the source is authorized with OAuth.")
+	cpCmd.PersistentFlags().StringVar(&raw.sourceLoginType, "source-login-type", "", "Log in to the source separately from the destination, with the given login type: "+
+		"SPN, MSI, AZCLI, PSCRED, WORKLOAD, AZD or AUTO. The login is configured through the same environment variables as AZCOPY_AUTO_LOGIN_TYPE, "+
+		"and against the tenant given by source-tenant-id if set. Resuming the job logs the source in the same way.")
 
 	// Public Documentation: https://docs.microsoft.com/en-us/azure/storage/blobs/encryption-customer-provided-keys
 	// Clients making requests against Azure Blob storage have the option to provide an encryption key on a per-request basis.
@@ -28,6 +28,7 @@ import (
 	"fmt"
 	"net/http"
 	"net/url"
+	"strconv"
 	"strings"
 	"sync"
 
@@ -76,6 +77,10 @@ func GetUserOAuthTokenManagerInstance() *common.UserOAuthTokenManager {
 			ServiceName:   oauthLoginSessionCacheServiceName,
 			AccountName:   oauthLoginSessionCacheAccountName,
 		})
+		// The refreshes run for the lifetime of the process.
+		if enabled, _ := strconv.ParseBool(glcm.GetEnvironmentVariable(common.EEnvironmentVariable.BackgroundTokenRefresh())); enabled {
+			currentUserOAuthTokenManager.EnableBackgroundRefresh(context.Background())
+		}
 	})
 
 	return currentUserOAuthTokenManager
@@ -88,57 +93,18 @@ func GetUserOAuthTokenManagerInstance() *common.UserOAuthTokenManager {
 func GetOAuthTokenManagerInstance() (*common.UserOAuthTokenManager, error) {
 	var err error
 	autoOAuth.Do(func() {
-		var lca loginCmdArgs
 		autoLoginType := strings.ToLower(glcm.GetEnvironmentVariable(common.EEnvironmentVariable.AutoLoginType()))
 		if autoLoginType == "" {
 			glcm.Info("Autologin not specified.")
 			return
 		}
 
-		if tenantID := glcm.GetEnvironmentVariable(common.EEnvironmentVariable.TenantID()); tenantID != "" {
-			lca.tenantID = tenantID
-		}
-
-		if endpoint := glcm.GetEnvironmentVariable(common.EEnvironmentVariable.AADEndpoint()); endpoint != "" {
-			lca.aadEndpoint = endpoint
-		}
-
-		// Fill up lca
-		switch autoLoginType {
-		case common.AutologinTypeSPN:
-			lca.applicationID = glcm.GetEnvironmentVariable(common.EEnvironmentVariable.ApplicationID())
-			lca.certPath = glcm.GetEnvironmentVariable(common.EEnvironmentVariable.CertificatePath())
-			lca.certPass = glcm.GetEnvironmentVariable(common.EEnvironmentVariable.CertificatePassword())
-			lca.clientSecret = glcm.GetEnvironmentVariable(common.EEnvironmentVariable.ClientSecret())
-			lca.servicePrincipal = true
-
-		case common.AutologinTypeMSI:
-			lca.identityClientID = glcm.GetEnvironmentVariable(common.EEnvironmentVariable.ManagedIdentityClientID())
-			lca.identityObjectID = glcm.GetEnvironmentVariable(common.EEnvironmentVariable.ManagedIdentityObjectID())
-			lca.identityResourceID = glcm.GetEnvironmentVariable(common.EEnvironmentVariable.ManagedIdentityResourceString())
-			lca.identity = true
-
-		case common.AutologinTypeDevice:
-			lca.identity = false
-
-		case common.AutologinTypeAzCLI:
-			lca.identity = false
-			lca.servicePrincipal = false
-			lca.psCred = false
-			lca.azCliCred = true
-
-		case common.AutologinTypePsCred:
-			lca.identity = false
-			lca.servicePrincipal = false
-			lca.azCliCred = false
-			lca.psCred = true
-
-		default:
-			glcm.Error("Invalid Auto-login type specified: " + autoLoginType)
+		lca, loginErr := autoLoginArgs(autoLoginType)
+		if loginErr != nil {
+			glcm.Error(loginErr.Error())
 			return
 		}
 
-		lca.persistToken = false
 		if err = lca.process(); err != nil {
 			glcm.Error(fmt.Sprintf("Failed to perform Auto-login: %v.", err.Error()))
 		}
@@ -151,6 +117,118 @@ func GetOAuthTokenManagerInstance() (*common.UserOAuthTokenManager, error) {
 	return GetUserOAuthTokenManagerInstance(), nil
 }
 
+// autoLoginArgs builds the arguments of a login of the given auto-login type (see AZCOPY_AUTO_LOGIN_TYPE), whose
+// settings are read from the environment.
+func autoLoginArgs(loginType string) (loginCmdArgs, error) {
+	var lca loginCmdArgs
+
+	if tenantID := glcm.GetEnvironmentVariable(common.EEnvironmentVariable.TenantID()); tenantID != "" {
+		lca.tenantID = tenantID
+	}
+
+	if endpoint := glcm.GetEnvironmentVariable(common.EEnvironmentVariable.AADEndpoint()); endpoint != "" {
+		lca.aadEndpoint = endpoint
+	}
+
+	// Fill up lca
+	switch loginType {
+	case common.AutologinTypeSPN:
+		lca.applicationID = common.ResolveApplicationID("")
+		lca.certPath = glcm.GetEnvironmentVariable(common.EEnvironmentVariable.CertificatePath())
+		lca.certPass = glcm.GetEnvironmentVariable(common.EEnvironmentVariable.CertificatePassword())
+		lca.sendCertChain, _ = strconv.ParseBool(glcm.GetEnvironmentVariable(common.EEnvironmentVariable.CertificateSNIAuth()))
+		lca.clientSecret = glcm.GetEnvironmentVariable(common.EEnvironmentVariable.ClientSecret())
+		if lca.clientSecret == "" && lca.certPath == "" {
+			lca.assertionRequestURL = glcm.GetEnvironmentVariable(common.EEnvironmentVariable.ClientAssertionRequestURL())
+			lca.assertionRequestToken = glcm.GetEnvironmentVariable(common.EEnvironmentVariable.ClientAssertionRequestToken())
+		}
+		lca.servicePrincipal = true
+
+	case common.AutologinTypeMSI:
+		lca.identityClientID = glcm.GetEnvironmentVariable(common.EEnvironmentVariable.ManagedIdentityClientID())
+		lca.identityObjectID = glcm.GetEnvironmentVariable(common.EEnvironmentVariable.ManagedIdentityObjectID())
+		lca.resolveIdentityObjectID, _ = strconv.ParseBool(glcm.GetEnvironmentVariable(common.EEnvironmentVariable.ManagedIdentityResolveObjectID()))
+		lca.identityResourceID = glcm.GetEnvironmentVariable(common.EEnvironmentVariable.ManagedIdentityResourceString())
+		lca.identity = true
+
+	case common.AutologinTypeDevice:
+		lca.identity = false
+
+	case common.AutologinTypeAzCLI:
+		lca.identity = false
+		lca.servicePrincipal = false
+		lca.psCred = false
+		lca.azCliCred = true
+
+	case common.AutologinTypePsCred:
+		lca.identity = false
+		lca.servicePrincipal = false
+		lca.azCliCred = false
+		lca.psCred = true
+
+	case common.AutologinTypeWorkload:
+		lca.identity = false
+		lca.servicePrincipal = false
+		lca.azCliCred = false
+		lca.psCred = false
+		lca.workloadIdentity = true
+
+	case common.AutologinTypeAzd:
+		lca.identity = false
+		lca.servicePrincipal = false
+		lca.azCliCred = false
+		lca.psCred = false
+		lca.azdCred = true
+
+	case common.AutologinTypeAuto:
+		lca.credentialChain = true
+
+	default:
+		return lca, errors.New("Invalid Auto-login type specified: " + loginType)
+	}
+
+	lca.persistToken = false
+	return lca, nil
+}
+
+// useSourceLogin configures how the source of a service to service transfer authenticates with OAuth, when it doesn't
+// share the destination's login. With a login type, the source logs in on its own like auto-login of that type would,
+// otherwise the current login is used against tenantID.
+func useSourceLogin(ctx context.Context, loginType, tenantID string) error {
+	uotm := GetUserOAuthTokenManagerInstance()
+	if loginType == "" {
+		if tenantID != "" {
+			uotm.SetTenantForRole(common.ECredentialRole.Source(), tenantID)
+		}
+		return nil
+	}
+
+	lca, err := autoLoginArgs(loginType)
+	if err != nil {
+		return err
+	}
+	if tenantID != "" {
+		lca.tenantID = tenantID
+	}
+
+	// The source logs in through a manager of its own, so that its login doesn't replace the destination's.
+	sourceManager := common.NewUserOAuthTokenManagerInstance(common.CredCacheOptions{
+		DPAPIFilePath: common.AzcopyJobPlanFolder,
+		KeyName:       oauthLoginSessionCacheKeyName,
+		ServiceName:   oauthLoginSessionCacheServiceName,
+		AccountName:   oauthLoginSessionCacheAccountName,
+	})
+	if err := lca.processWith(sourceManager); err != nil {
+		return fmt.Errorf("failed to log in to the source: %w", err)
+	}
+	tokenInfo, err := sourceManager.GetTokenInfo(ctx)
+	if err != nil {
+		return err
+	}
+	uotm.SetTokenInfoForRole(common.ECredentialRole.Source(), tokenInfo)
+	return nil
+}
+
 var announceOAuthTokenOnce sync.Once
 
 func oAuthTokenExists() (oauthTokenExists bool) {
@@ -543,7 +621,7 @@ func GetCredentialInfoForLocation(ctx context.Context, location common.Location,
 	if credInfo.CredentialType.IsAzureOAuth() {
 		uotm := GetUserOAuthTokenManagerInstance()
 
-		if tokenInfo, err := uotm.GetTokenInfo(ctx); err != nil {
+		if tokenInfo, err := uotm.GetTokenInfoForRole(ctx, common.Iff(isSource, common.ECredentialRole.Source(), common.ECredentialRole.Destination())); err != nil {
 			return credInfo, false, err
 		} else {
 			credInfo.OAuthTokenInfo = *tokenInfo
@@ -284,6 +284,14 @@ Log in interactively with a specified tenant ID:
 
    - azcopy login --tenant-id "[TenantID]"
 
+Log in through the system browser, falling back to the device code flow when no browser is available (e.g. over SSH):
+
+   - azcopy login --login-type=INTERACTIVE
+
+Log in with the first of the service principal (from environment variables), workload identity, managed identity and Azure CLI credentials which works:
+
+   - azcopy login --login-type=AUTO
+
 Log in by using the system-assigned identity of a Virtual Machine (VM):
 
    - azcopy login --identity
@@ -313,18 +321,25 @@ Set the environment variable AZCOPY_SPA_CERT_PASSWORD to the certificate's passw
    Please treat /path/to/my/cert as a path to a PEM or PKCS12 file-- AzCopy does not reach into the system cert store to obtain your certificate.
    --certificate-path is mandatory when doing cert-based service principal auth.
 
+Log in with the account the Azure Developer CLI is signed in with (azd auth login). Nothing is cached by AzCopy:
+
+   - azcopy login --login-type=AZD
+
 Subcommand for login to check the login status of your current session.
 	- azcopy login status 
 `
 
 const loginStatusShortDescription = "Prints if you are currently logged in to your Azure Storage account."
 
-const loginStatusLongDescription = "This command will let you know if you are currently logged in to your Azure Storage account."
+const loginStatusLongDescription = "This command will let you know if you are currently logged in to your Azure Storage account, with which type of credential, and until when. " +
+	"The cached login is inspected without being refreshed, so this command never prompts. Use --output-type json for machine-readable output. " +
+	"It exits with an error when you need to log in again; a device code login whose access token expired is refreshed silently, so still counts as logged in."
 
 // ===================================== LOGOUT COMMAND ===================================== //
 const logoutCmdShortDescription = "Log out to terminate access to Azure Storage resources."
 
-const logoutCmdLongDescription = `This command will remove all of the cached login information for the current user.`
+const logoutCmdLongDescription = `This command will remove all of the cached login information for the current user.
+Use --all to also remove the tokens cached for AzCopy processes by integrations through the token store.`
 
 // ===================================== MAKE COMMAND ===================================== //
 const makeCmdShortDescription = "Create a container or file share."
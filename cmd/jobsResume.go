@@ -279,34 +279,32 @@ func (rca resumeCmdArgs) getSourceAndDestinationServiceClients(
 		return nil, nil, err
 	}
 
-	var tc azcore.TokenCredential
-	if srcCredType.IsAzureOAuth() || dstCredType.IsAzureOAuth() {
-		uotm := GetUserOAuthTokenManagerInstance()
-		// Get token from env var or cache.
-		tokenInfo, err := uotm.GetTokenInfo(ctx)
-		if err != nil {
+	// Each side gets the credential of its own role, as the source may log in separately (see useSourceLogin).
+	var srcTc, dstTc azcore.TokenCredential
+	if srcCredType.IsAzureOAuth() {
+		if srcTc, err = rca.getTokenCredential(ctx, common.ECredentialRole.Source()); err != nil {
 			return nil, nil, err
 		}
-
-		tc, err = tokenInfo.GetTokenCredential()
-		if err != nil {
+	}
+	if dstCredType.IsAzureOAuth() {
+		if dstTc, err = rca.getTokenCredential(ctx, common.ECredentialRole.Destination()); err != nil {
 			return nil, nil, err
 		}
 	}
 
 	options := createClientOptions(common.AzcopyCurrentJobLogger, nil)
 
-	srcServiceClient, err := common.GetServiceClientForLocation(fromTo.From(), source, srcCredType, tc, &options, nil)
+	srcServiceClient, err := common.GetServiceClientForLocation(fromTo.From(), source, srcCredType, srcTc, &options, nil)
 	if err != nil {
 		return nil, nil, err
 	}
 
 	var srcCred *common.ScopedCredential
 	if fromTo.IsS2S() && srcCredType.IsAzureOAuth() {
-		srcCred = common.NewScopedCredential(tc, srcCredType)
+		srcCred = common.NewScopedCredential(srcTc, srcCredType)
 	}
 	options = createClientOptions(common.AzcopyCurrentJobLogger, srcCred)
-	dstServiceClient, err := common.GetServiceClientForLocation(fromTo.To(), destination, dstCredType, tc, &options, nil)
+	dstServiceClient, err := common.GetServiceClientForLocation(fromTo.To(), destination, dstCredType, dstTc, &options, nil)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -314,6 +312,15 @@ func (rca resumeCmdArgs) getSourceAndDestinationServiceClients(
 	return srcServiceClient, dstServiceClient, nil
 }
 
+// getTokenCredential gets the OAuth credential of role, from env var or cache.
+func (rca resumeCmdArgs) getTokenCredential(ctx context.Context, role common.CredentialRole) (azcore.TokenCredential, error) {
+	tokenInfo, err := GetUserOAuthTokenManagerInstance().GetTokenInfoForRole(ctx, role)
+	if err != nil {
+		return nil, err
+	}
+	return tokenInfo.GetTokenCredential()
+}
+
 // processes the resume command,
 // dispatches the resume Job order to the storage engine.
 func (rca resumeCmdArgs) process() error {
@@ -373,6 +380,12 @@ func (rca resumeCmdArgs) process() error {
 	}
 
 	ctx := context.WithValue(context.TODO(), ste.ServiceAPIVersionOverride, ste.DefaultServiceApiVersion)
+	// Log the source in as the job did, rather than with the destination's login.
+	if getJobFromToResponse.FromTo.IsS2S() {
+		if err := useSourceLogin(ctx, getJobFromToResponse.SourceLoginType, getJobFromToResponse.SourceTenantID); err != nil {
+			return err
+		}
+	}
 	// Initialize credential info.
 	credentialInfo := common.CredentialInfo{}
 	// TODO: Replace context with root context
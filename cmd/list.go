@@ -243,7 +243,7 @@ func (cooked cookedListCmdArgs) HandleListContainerCommand() (err error) {
 		return errors.New("azure files requires a SAS token for authentication")
 	} else if credentialInfo.CredentialType.IsAzureOAuth() {
 		uotm := GetUserOAuthTokenManagerInstance()
-		if tokenInfo, err := uotm.GetTokenInfo(ctx); err != nil {
+		if tokenInfo, err := uotm.GetTokenInfoForRole(ctx, common.ECredentialRole.Source()); err != nil {
 			return err
 		} else {
 			credentialInfo.OAuthTokenInfo = *tokenInfo
@@ -21,8 +21,11 @@
 package cmd
 
 import (
+	"context"
 	"errors"
+	"fmt"
 	"strings"
+	"time"
 
 	"github.com/Azure/azure-storage-azcopy/v10/common"
 	"github.com/spf13/cobra"
@@ -44,6 +47,10 @@ var lgCmd = &cobra.Command{
 		loginCmdArg.clientSecret = glcm.GetEnvironmentVariable(common.EEnvironmentVariable.ClientSecret())
 		loginCmdArg.persistToken = true
 
+		if err := loginCmdArg.applyLoginType(); err != nil {
+			return err
+		}
+
 		if loginCmdArg.certPass != "" || loginCmdArg.clientSecret != "" {
 			glcm.Info(environmentVariableNotice)
 		}
@@ -77,10 +84,32 @@ func init() {
 	//login with SPN
 	lgCmd.PersistentFlags().StringVar(&loginCmdArg.applicationID, "application-id", "", "Application ID of user-assigned identity. Required for service principal auth.")
 	lgCmd.PersistentFlags().StringVar(&loginCmdArg.certPath, "certificate-path", "", "Path to certificate for SPN authentication. Required for certificate-based service principal auth.")
+	lgCmd.PersistentFlags().StringVar(&loginCmdArg.clientAssertion, "client-assertion", "", "Federated client assertion (e.g. a GitHub Actions OIDC token) for SPN authentication. "+
+		"Such assertions are short-lived, so it can't be persisted by the login command; use client-assertion-file instead.")
+	lgCmd.PersistentFlags().StringVar(&loginCmdArg.clientAssertionFile, "client-assertion-file", "", "Path of a file holding a federated client assertion for SPN authentication. "+
+		"The file is re-read whenever a new token is needed, so it may be rotated during long-running jobs.")
+	lgCmd.PersistentFlags().StringVar(&loginCmdArg.keyVaultURL, "key-vault-url", "", "URL of the Key Vault holding the certificate for SPN authentication, in place of certificate-path. The certificate is downloaded into memory only.")
+	lgCmd.PersistentFlags().StringVar(&loginCmdArg.keyVaultCertName, "key-vault-certificate-name", "", "Name of the certificate in the Key Vault given by key-vault-url. Its private key must be exportable.")
+	lgCmd.PersistentFlags().StringVar(&loginCmdArg.keyVaultBootstrap, "key-vault-bootstrap", common.AutologinTypeMSI, "Login used to download the certificate from Key Vault, MSI (optionally with identity-client-id or identity-resource-id) or AZCLI.")
+	lgCmd.PersistentFlags().BoolVar(&loginCmdArg.sendCertChain, "send-certificate-chain", false, "Send the certificate chain along with the certificate for SPN authentication. Required when the service principal trusts the certificate by subject name and issuer (SNI authentication), e.g. for certificates rotated by an enterprise CA.")
 
 	// Deprecate the identity-object-id flag
 	_ = lgCmd.PersistentFlags().MarkHidden("identity-object-id") // Object ID of user-assigned identity.
 	lgCmd.PersistentFlags().StringVar(&loginCmdArg.identityObjectID, "identity-object-id", "", "Object ID of user-assigned identity. This parameter is deprecated. Please use client id or resource id")
+	lgCmd.PersistentFlags().BoolVar(&loginCmdArg.resolveIdentityObjectID, "identity-resolve-object-id", false, "Translate the object ID given by identity-object-id to the identity's client ID through Microsoft Graph. "+
+		"The lookup authenticates with the environment, workload identity, managed identity or Azure CLI credentials available, and the login fails if it cannot be performed.")
+	lgCmd.PersistentFlags().StringVar(&loginCmdArg.identityEndpoint, "identity-endpoint", "", "Endpoint to request managed identity tokens from, such as the IDENTITY_ENDPOINT of an Azure Arc machine. "+
+		"The IDENTITY_HEADER environment variable is sent as the endpoint's secret when set.")
+	lgCmd.PersistentFlags().StringVar(&loginCmdArg.loginType, "login-type", "", "Type of login to perform, one of AUTO, INTERACTIVE, BROWSER, DEVICE, SPN, MSI, AZCLI, PSCRED, WORKLOAD or AZD. "+
+		"BROWSER logs in through the system browser, and INTERACTIVE does so unless no browser is available (e.g. over SSH), falling back to the device code flow of DEVICE, the default. "+
+		"AUTO logs in with the first of the environment's service principal, workload identity, managed identity and Azure CLI credentials which works, as AZCOPY_AUTO_LOGIN_TYPE=AUTO does. "+
+		"AZD reuses the login of the Azure Developer CLI (azd auth login).")
+	lgCmd.PersistentFlags().StringVar(&loginCmdArg.redirectURI, "redirect-uri", "", "Localhost URL the browser redirects to after a BROWSER or INTERACTIVE login, e.g. http://localhost:8400. By default a free port is picked.")
+	lgCmd.PersistentFlags().DurationVar(&loginCmdArg.identityProbeTimeout, "identity-probe-timeout", 0, "Timeout for each request to the managed identity endpoint, e.g. 10s.")
+	lgCmd.PersistentFlags().StringVar(&loginCmdArg.scopes, "scopes", "", "Comma separated token scopes to request in place of the storage audience, e.g. for private endpoints behind a custom STS. "+
+		"Scopes ending in //.default replace the managed disk audience. Defaults to AZCOPY_OAUTH_SCOPES.")
+	lgCmd.PersistentFlags().StringVar(&loginCmdArg.allowedTenants, "allowed-tenants", "", "Comma separated IDs of tenants besides the login tenant that tokens may be requested for, "+
+		"e.g. when a service principal is granted access through a guest assignment in another tenant. Use * to allow any tenant. Defaults to AZCOPY_ADDITIONALLY_ALLOWED_TENANTS.")
 
 }
 
@@ -93,6 +122,19 @@ type loginCmdArgs struct {
 	servicePrincipal bool
 	azCliCred        bool
 	psCred           bool
+	workloadIdentity bool
+	azdCred          bool
+	// Log in with the first of the environment's credentials which works, see CredentialChainLogin.
+	credentialChain bool
+	// loginType selects one of the login types above by name, see applyLoginType.
+	loginType string
+	// How to log in a user interactively, and where the browser redirects to.
+	interactiveLoginType common.LoginType
+	redirectURI          string
+	// Token scopes requested in place of the storage audience.
+	scopes string
+	// Tenants besides tenantID that tokens may be requested for.
+	allowedTenants string
 
 	// Info of VM's user assigned identity, client or object ids of the service identity are required if
 	// your VM has multiple user-assigned managed identities.
@@ -100,13 +142,62 @@ type loginCmdArgs struct {
 	identityClientID   string
 	identityObjectID   string
 	identityResourceID string
+	// Opt in to translating identityObjectID into a client ID, as object IDs are no longer supported.
+	resolveIdentityObjectID bool
+	// Optional identity endpoint override (e.g. Azure Arc's IDENTITY_ENDPOINT) and per-request timeout.
+	identityEndpoint     string
+	identityProbeTimeout time.Duration
 
 	//Required to sign in with a SPN (Service Principal Name)
 	applicationID string
 	certPath      string
 	certPass      string
+	sendCertChain bool
 	clientSecret  string
 	persistToken  bool
+
+	// Required to sign in with a SPN using a federated client assertion (e.g. GitHub Actions OIDC)
+	assertionRequestURL   string
+	assertionRequestToken string
+	// A client assertion, or the path of a file holding one, in place of the request URL.
+	clientAssertion     string
+	clientAssertionFile string
+
+	// Required to sign in with a SPN using a certificate kept in Key Vault, which is downloaded with the bootstrap login.
+	keyVaultURL       string
+	keyVaultCertName  string
+	keyVaultBootstrap string
+}
+
+// applyLoginType sets the login selected by --login-type, which accepts the same names as AZCOPY_AUTO_LOGIN_TYPE,
+// plus BROWSER and INTERACTIVE for the interactive flows.
+func (lca *loginCmdArgs) applyLoginType() error {
+	if lca.loginType != "" && (lca.identity || lca.servicePrincipal) {
+		return errors.New("login-type cannot be combined with the identity or service-principal flags")
+	}
+
+	switch strings.ToLower(lca.loginType) {
+	case "", common.AutologinTypeDevice:
+	case "devicecode", "browser", "interactive":
+		return lca.interactiveLoginType.Parse(lca.loginType)
+	case common.AutologinTypeAuto:
+		lca.credentialChain = true
+	case common.AutologinTypeSPN:
+		lca.servicePrincipal = true
+	case common.AutologinTypeMSI:
+		lca.identity = true
+	case common.AutologinTypeAzCLI:
+		lca.azCliCred = true
+	case common.AutologinTypePsCred:
+		lca.psCred = true
+	case common.AutologinTypeWorkload:
+		lca.workloadIdentity = true
+	case common.AutologinTypeAzd:
+		lca.azdCred = true
+	default:
+		return fmt.Errorf("invalid login type %q, expected one of AUTO, INTERACTIVE, BROWSER, DEVICE, SPN, MSI, AZCLI, PSCRED, WORKLOAD or AZD", lca.loginType)
+	}
+	return nil
 }
 
 func (lca loginCmdArgs) validate() error {
@@ -126,12 +217,22 @@ func (lca loginCmdArgs) validate() error {
 			return errors.New("you can only log in with one type of auth at once")
 		}
 
-		if lca.identityClientID != "" || lca.identityObjectID != "" || lca.identityResourceID != "" {
+		// A managed identity may only be chosen to download the certificate from Key Vault.
+		if (lca.identityClientID != "" || lca.identityObjectID != "" || lca.identityResourceID != "") &&
+			(lca.keyVaultURL == "" || !strings.EqualFold(lca.keyVaultBootstrap, common.AutologinTypeMSI)) {
 			return errors.New("identity client/object/resource ID are exclusive to managed service identity auth and are not compatible with service principal auth")
 		}
 
-		if lca.applicationID == "" || (lca.clientSecret == "" && lca.certPath == "") {
-			return errors.New("service principal auth requires an application ID, and client secret/certificate")
+		if lca.applicationID == "" || (lca.clientSecret == "" && lca.certPath == "" && lca.assertionRequestURL == "" && lca.clientAssertion == "" && lca.clientAssertionFile == "" && lca.keyVaultURL == "") {
+			return errors.New("service principal auth requires an application ID, and client secret/certificate/client assertion")
+		}
+
+		if lca.clientAssertion != "" && lca.clientAssertionFile != "" {
+			return errors.New("client-assertion and client-assertion-file are mutually exclusive")
+		}
+
+		if lca.keyVaultURL != "" && lca.keyVaultCertName == "" {
+			return errors.New("key-vault-certificate-name is required along with key-vault-url")
 		}
 	default: // OAuth login.
 		// This isn't necessary, but stands as a sanity check. It will never be hit.
@@ -147,25 +248,67 @@ func (lca loginCmdArgs) validate() error {
 		if lca.identityClientID != "" || lca.identityObjectID != "" || lca.identityResourceID != "" {
 			return errors.New("identity client/object/resource IDs are exclusive to managed service identity auth and are not compatible with OAuth")
 		}
+
+		if lca.redirectURI != "" && lca.interactiveLoginType == common.ELoginType.DeviceCode() {
+			return errors.New("redirect URI only applies to the BROWSER and INTERACTIVE login types")
+		}
 	}
 
 	return nil
 }
 
 func (lca loginCmdArgs) process() error {
+	return lca.processWith(GetUserOAuthTokenManagerInstance())
+}
+
+// processWith logs in through uotm.
+func (lca loginCmdArgs) processWith(uotm *common.UserOAuthTokenManager) error {
+	if lca.servicePrincipal {
+		lca.applicationID = common.ResolveApplicationID(lca.applicationID)
+	}
+
 	// Validate login parameters.
 	if err := lca.validate(); err != nil {
 		return err
 	}
 
-	uotm := GetUserOAuthTokenManagerInstance()
+	uotm.SetCustomScopes(common.ParseCustomScopes(lca.scopes))
+	uotm.SetAdditionalTenants(common.ParseAdditionalTenants(lca.allowedTenants))
 	// Persist the token to cache, if login fulfilled successfully.
 
 	switch {
 	case lca.servicePrincipal:
 
-		if lca.certPath != "" {
-			if err := uotm.CertLogin(lca.tenantID, lca.aadEndpoint, lca.certPath, lca.certPass, lca.applicationID, lca.persistToken); err != nil {
+		if lca.clientAssertion != "" || lca.clientAssertionFile != "" {
+			if err := uotm.AssertionLogin(lca.tenantID, lca.applicationID, common.ClientAssertionConfig{
+				Value: lca.clientAssertion,
+				File:  lca.clientAssertionFile,
+			}, lca.persistToken); err != nil {
+				return err
+			}
+
+			glcm.Info("SPN Auth via client assertion succeeded.")
+		} else if lca.assertionRequestURL != "" {
+			if err := uotm.AssertionLogin(lca.tenantID, lca.applicationID, common.ClientAssertionConfig{
+				RequestURL:   lca.assertionRequestURL,
+				RequestToken: lca.assertionRequestToken,
+			}, lca.persistToken); err != nil {
+				return err
+			}
+
+			glcm.Info("SPN Auth via client assertion succeeded.")
+		} else if lca.keyVaultURL != "" {
+			bootstrap, err := lca.keyVaultBootstrapLogin()
+			if err != nil {
+				return err
+			}
+			if err := uotm.KeyVaultCertLogin(lca.tenantID, lca.applicationID, lca.keyVaultURL, lca.keyVaultCertName, bootstrap, lca.persistToken); err != nil {
+				return err
+			}
+
+			glcm.Info("SPN Auth via Key Vault cert succeeded.")
+		} else if lca.certPath != "" {
+			if err := uotm.CertLogin(lca.tenantID, lca.aadEndpoint, lca.certPath, lca.certPass, lca.applicationID, lca.sendCertChain, lca.persistToken); err != nil {
 				return err
 			}
 
@@ -178,11 +321,25 @@ func (lca loginCmdArgs) process() error {
 			glcm.Info("SPN Auth via secret succeeded.")
 		}
 	case lca.identity:
-		if err := uotm.MSILogin(common.IdentityInfo{
-			ClientID: lca.identityClientID,
-			ObjectID: lca.identityObjectID,
-			MSIResID: lca.identityResourceID,
-		}, lca.persistToken); err != nil {
+		identityInfo := common.IdentityInfo{
+			ClientID:     lca.identityClientID,
+			ObjectID:     lca.identityObjectID,
+			MSIResID:     lca.identityResourceID,
+			Endpoint:     lca.identityEndpoint,
+			ProbeTimeout: lca.identityProbeTimeout,
+		}
+		if identityInfo.ObjectID != "" && lca.resolveIdentityObjectID {
+			if err := identityInfo.Validate(); err != nil {
+				return err
+			}
+			if err := identityInfo.ResolveObjectID(context.TODO()); err != nil {
+				return err
+			}
+
+			glcm.Info(fmt.Sprintf("Resolved identity object ID %s to client ID %s. Please pass the client ID in the future.", lca.identityObjectID, identityInfo.ClientID))
+		}
+
+		if err := uotm.MSILogin(identityInfo, lca.persistToken); err != nil {
 			return err
 		}
 		// For MSI login, info success message to user.
@@ -197,13 +354,48 @@ func (lca loginCmdArgs) process() error {
 			return err
 		}
 		glcm.Info("Login with Powershell context succeeded")
+	case lca.azdCred:
+		if err := uotm.AzdLogin(lca.tenantID); err != nil {
+			return err
+		}
+		glcm.Info("Login with Azure Developer CLI succeeded")
+	case lca.workloadIdentity:
+		if err := uotm.WorkloadIdentityLogin(lca.persistToken); err != nil {
+			return err
+		}
+		glcm.Info("Login with workload identity succeeded.")
+	case lca.credentialChain:
+		if err := uotm.CredentialChainLogin(lca.tenantID, lca.persistToken); err != nil {
+			return err
+		}
+		glcm.Info("Login with the credential chain succeeded.")
 	default:
-		if err := uotm.UserLogin(lca.tenantID, lca.aadEndpoint, lca.persistToken); err != nil {
+		if err := uotm.InteractiveLogin(lca.tenantID, lca.aadEndpoint, lca.interactiveLoginType, lca.redirectURI, lca.persistToken); err != nil {
 			return err
 		}
 		// User fulfills login in browser, and there would be message in browser indicating whether login fulfilled successfully.
 		glcm.Info("Login succeeded.")
 	}
 
+	if tokenInfo, err := uotm.GetTokenInfo(context.TODO()); err == nil {
+		glcm.Info(tokenInfo.Describe().String() + ".")
+	}
 	return nil
 }
+
+// keyVaultBootstrapLogin returns the login used to download the SPN certificate from Key Vault,
+// the VM's managed identity unless the Azure CLI is asked for.
+func (lca loginCmdArgs) keyVaultBootstrapLogin() (*common.OAuthTokenInfo, error) {
+	switch strings.ToLower(lca.keyVaultBootstrap) {
+	case "", common.AutologinTypeMSI:
+		identityInfo := common.IdentityInfo{ClientID: lca.identityClientID, ObjectID: lca.identityObjectID, MSIResID: lca.identityResourceID}
+		if err := identityInfo.Validate(); err != nil {
+			return nil, err
+		}
+		return &common.OAuthTokenInfo{Identity: true, IdentityInfo: identityInfo}, nil
+	case common.AutologinTypeAzCLI:
+		return &common.OAuthTokenInfo{AzCLICred: true, Tenant: lca.tenantID}, nil
+	default:
+		return nil, fmt.Errorf("invalid Key Vault bootstrap login %q, expected MSI or AZCLI", lca.keyVaultBootstrap)
+	}
+}
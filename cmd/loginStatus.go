@@ -21,10 +21,12 @@
 package cmd
 
 import (
-	"context"
+	"encoding/json"
 	"fmt"
+	"strings"
+	"time"
+
 	"github.com/Azure/azure-storage-azcopy/v10/common"
-	"github.com/Azure/azure-storage-azcopy/v10/ste"
 	"github.com/spf13/cobra"
 )
 
@@ -46,27 +48,22 @@ func init() {
 			return nil
 		},
 		Run: func(cmd *cobra.Command, args []string) {
-			// getting current token info and refreshing it with GetTokenInfo()
-			ctx := context.WithValue(context.TODO(), ste.ServiceAPIVersionOverride, ste.DefaultServiceApiVersion)
+			// Inspect the login without refreshing it, so that checking the status never prompts.
 			uotm := GetUserOAuthTokenManagerInstance()
-			tokenInfo, err := uotm.GetTokenInfo(ctx)
-
-			if err == nil && !tokenInfo.IsExpired() {
-				glcm.Info("You have successfully refreshed your token. Your login session is still active")
-
-				if commandLineInput.tenantID {
-					glcm.Info(fmt.Sprintf("Tenant ID: %v", tokenInfo.Tenant))
-				}
+			status, err := uotm.GetLoginStatus()
+			if err != nil {
+				glcm.Error(fmt.Sprintf("Failed to get login status: %v", err))
+			}
 
-				if commandLineInput.endpoint {
-					glcm.Info(fmt.Sprintf("Active directory endpoint: %v", tokenInfo.ActiveDirectoryEndpoint))
+			glcm.Exit(func(format common.OutputFormat) string {
+				if format == common.EOutputFormat.Json() {
+					jsonOutput, err := json.Marshal(status)
+					common.PanicIfErr(err)
+					return string(jsonOutput)
 				}
 
-				glcm.Exit(nil, common.EExitCode.Success())
-			}
-
-			glcm.Info("You are currently not logged in. Please login using 'azcopy login'")
-			glcm.Exit(nil, common.EExitCode.Error())
+				return formatLoginStatus(status, commandLineInput.tenantID, commandLineInput.endpoint)
+			}, loginStatusExitCode(status))
 		},
 	}
 
@@ -74,3 +71,42 @@ func init() {
 	lgStatus.PersistentFlags().BoolVar(&commandLineInput.tenantID, "tenant", false, "Prints the Azure Active Directory tenant ID that is currently being used in session.")
 	lgStatus.PersistentFlags().BoolVar(&commandLineInput.endpoint, "endpoint", false, "Prints the Azure Active Directory endpoint that is being used in the current session.")
 }
+
+// loginStatusExitCode fails when the user has to log in again, i.e. without a login, or with one which can't be refreshed.
+func loginStatusExitCode(status common.LoginStatus) common.ExitCode {
+	if !status.LoggedIn || (status.Expired && !status.Refreshable) {
+		return common.EExitCode.Error()
+	}
+	return common.EExitCode.Success()
+}
+
+func formatLoginStatus(status common.LoginStatus, showTenant, showEndpoint bool) string {
+	if !status.LoggedIn {
+		return "You are currently not logged in. Please login using 'azcopy login'"
+	}
+
+	var sb strings.Builder
+	if status.Expired && status.Refreshable {
+		sb.WriteString(fmt.Sprintf("Your login session is still active, its access token expired as of %v and will be refreshed when next used\n", status.ExpiresOn.Format(time.RFC1123)))
+	} else if status.Expired {
+		sb.WriteString(fmt.Sprintf("Your cached login has expired as of %v. Please login again using 'azcopy login'\n", status.ExpiresOn.Format(time.RFC1123)))
+	} else {
+		sb.WriteString("Your login session is still active\n")
+	}
+
+	sb.WriteString(fmt.Sprintf("Credential type: %v\n", status.CredentialType))
+	if status.ApplicationID != "" {
+		sb.WriteString(fmt.Sprintf("Application ID: %v\n", status.ApplicationID))
+	}
+	if status.ExpiresOn != nil && !status.Expired {
+		sb.WriteString(fmt.Sprintf("Token expires: %v\n", status.ExpiresOn.Format(time.RFC1123)))
+	}
+	if showTenant {
+		sb.WriteString(fmt.Sprintf("Tenant ID: %v\n", status.TenantID))
+	}
+	if showEndpoint {
+		sb.WriteString(fmt.Sprintf("Active directory endpoint: %v\n", status.ActiveDirectoryEndpoint))
+	}
+
+	return strings.TrimSuffix(sb.String(), "\n")
+}
@@ -21,8 +21,10 @@
 package cmd
 
 import (
+	"errors"
 	"fmt"
 
+	"github.com/Azure/azure-storage-azcopy/v10/common"
 	"github.com/spf13/cobra"
 )
 
@@ -48,13 +50,24 @@ func init() {
 	}
 
 	rootCmd.AddCommand(logoutCmd)
+	logoutCmd.PersistentFlags().BoolVar(&logoutCmdArgs.all, "all", false, "Also remove the tokens cached for AzCopy processes by integrations through the token store.")
 }
 
-type logoutCmdArgs struct{}
+type logoutCmdArgs struct {
+	all bool
+}
 
 func (lca logoutCmdArgs) process() error {
 	uotm := GetUserOAuthTokenManagerInstance()
-	if err := uotm.RemoveCachedToken(); err != nil {
+	remove := uotm.RemoveCachedToken
+	if lca.all {
+		remove = uotm.RemoveAllCachedTokens
+	}
+	if err := remove(); err != nil {
+		if errors.Is(err, common.ErrNoCachedToken) {
+			glcm.Info("No cached login was found, nothing to log out from.")
+			return nil
+		}
 		return err
 	}
 
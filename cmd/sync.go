@@ -628,7 +628,7 @@ Number of Copy Transfers Failed: %v
 Number of Deletions at Destination: %v
 Total Number of Bytes Transferred: %v
 Total Number of Bytes Enumerated: %v
-Final Job Status: %v%s%s
+Final Job Status: %v%s%s%s
 `,
 				summary.JobID.String(),
 				atomic.LoadUint64(&cca.atomicSourceFilesScanned),
@@ -643,6 +643,7 @@ Final Job Status: %v%s%s
 				summary.TotalBytesTransferred,
 				summary.TotalBytesEnumerated,
 				summary.JobStatus,
+				formatAuthStats(summary),
 				screenStats,
 				formatPerfAdvice(summary.PerformanceAdvice))
 
@@ -698,7 +699,7 @@ func (cca *cookedSyncCmdArgs) process() (err error) {
 	if cca.credentialInfo.CredentialType.IsAzureOAuth() || srcCredInfo.CredentialType.IsAzureOAuth() {
 		uotm := GetUserOAuthTokenManagerInstance()
 		// Get token from env var or cache.
-		if tokenInfo, err := uotm.GetTokenInfo(ctx); err != nil {
+		if tokenInfo, err := uotm.GetTokenInfoForRole(ctx, common.ECredentialRole.Destination()); err != nil {
 			return err
 		} else if _, err := tokenInfo.GetTokenCredential(); err != nil {
 			return err
@@ -203,6 +203,9 @@ func (t *blobTraverser) Traverse(preprocessor objectMorpher, processor objectPro
 		if respErr.RawResponse == nil {
 			return fmt.Errorf("cannot list files due to reason %s", respErr)
 		} else if respErr.StatusCode == 403 { // Some nature of auth error-- Whatever the user is pointing at, they don't have access to, regardless of whether it's a file or a dir stub.
+			if hint := common.SASRestrictionHint(err); hint != "" {
+				return fmt.Errorf("cannot list files due to reason %s\n%s", respErr, hint)
+			}
 			return fmt.Errorf("cannot list files due to reason %s", respErr)
 		}
 	}
@@ -0,0 +1,48 @@
+// Copyright © 2017 Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/Azure/azure-storage-azcopy/v10/common"
+)
+
+func TestLoginStatusOfRefreshableToken(t *testing.T) {
+	a := assert.New(t)
+	expiresOn := time.Now().Add(-time.Minute)
+
+	// An expired device code token is refreshed silently, so the session is still usable.
+	refreshable := common.LoginStatus{LoggedIn: true, Expired: true, Refreshable: true, CredentialType: "DeviceCode", ExpiresOn: &expiresOn}
+	a.Equal(common.EExitCode.Success(), loginStatusExitCode(refreshable))
+	a.Contains(formatLoginStatus(refreshable, false, false), "still active")
+
+	expired := refreshable
+	expired.Refreshable = false
+	a.Equal(common.EExitCode.Error(), loginStatusExitCode(expired))
+	a.Contains(formatLoginStatus(expired, false, false), "login again")
+
+	a.Equal(common.EExitCode.Error(), loginStatusExitCode(common.LoginStatus{}))
+	a.Equal(common.EExitCode.Success(), loginStatusExitCode(common.LoginStatus{LoggedIn: true, CredentialType: "ServicePrincipal"}))
+}
@@ -0,0 +1,66 @@
+// Copyright © 2017 Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/Azure/azure-storage-azcopy/v10/common"
+)
+
+func TestApplyLoginType(t *testing.T) {
+	a := assert.New(t)
+
+	// AUTO walks the credential chain, as AZCOPY_AUTO_LOGIN_TYPE=AUTO does.
+	lca := loginCmdArgs{loginType: "AUTO"}
+	a.NoError(lca.applyLoginType())
+	a.True(lca.credentialChain)
+
+	lca = loginCmdArgs{loginType: "interactive"}
+	a.NoError(lca.applyLoginType())
+	a.False(lca.credentialChain)
+	a.Equal(common.ELoginType.Interactive(), lca.interactiveLoginType)
+
+	lca = loginCmdArgs{loginType: "BROWSER", redirectURI: "http://localhost:8400"}
+	a.NoError(lca.applyLoginType())
+	a.Equal(common.ELoginType.Browser(), lca.interactiveLoginType)
+	a.NoError(lca.validate())
+
+	// The redirect only means something to the browser.
+	lca = loginCmdArgs{loginType: "AUTO", redirectURI: "http://localhost:8400"}
+	a.NoError(lca.applyLoginType())
+	a.Error(lca.validate())
+
+	lca = loginCmdArgs{loginType: "popup"}
+	a.Error(lca.applyLoginType())
+}
+
+func TestValidateClientAssertionSources(t *testing.T) {
+	a := assert.New(t)
+
+	lca := loginCmdArgs{servicePrincipal: true, applicationID: "app", clientAssertionFile: "/path/to/assertion"}
+	a.NoError(lca.validate())
+
+	lca.clientAssertion = "assertion"
+	a.ErrorContains(lca.validate(), "mutually exclusive")
+}
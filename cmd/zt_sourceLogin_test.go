@@ -0,0 +1,86 @@
+// Copyright © 2017 Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"context"
+	"testing"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/Azure/azure-storage-azcopy/v10/common"
+)
+
+func TestCookSourceLoginType(t *testing.T) {
+	a := assert.New(t)
+	cook := func(src, loginType string) (CookedCopyCmdArgs, error) {
+		raw := getDefaultRawCopyInput(src, "https://destination.blob.core.windows.net/container")
+		raw.sourceLoginType = loginType
+		raw.sourceTenantID = "tenant-a"
+		return raw.cook()
+	}
+
+	cooked, err := cook("https://source.blob.core.windows.net/container", "MSI")
+	a.NoError(err)
+	a.Equal(common.AutologinTypeMSI, cooked.sourceLoginType)
+	a.Equal("tenant-a", cooked.sourceTenantID)
+
+	_, err = cook("https://source.blob.core.windows.net/container", "DEVICE")
+	a.ErrorContains(err, "without prompting")
+	_, err = cook("https://source.blob.core.windows.net/container", "password")
+	a.ErrorContains(err, "invalid source-login-type")
+	_, err = cook(t.TempDir(), "MSI")
+	a.ErrorContains(err, "only supported for service to service transfers")
+}
+
+type fixedCredential struct {
+	token string
+}
+
+func (c fixedCredential) GetToken(context.Context, policy.TokenRequestOptions) (azcore.AccessToken, error) {
+	return azcore.AccessToken{Token: c.token}, nil
+}
+
+func TestResumeResolvesEachRole(t *testing.T) {
+	a := assert.New(t)
+	if common.AzcopyJobPlanFolder == "" {
+		common.AzcopyJobPlanFolder = t.TempDir()
+	}
+	uotm := GetUserOAuthTokenManagerInstance()
+	uotm.SetTokenInfo(common.NewOAuthTokenInfoFromCredential(fixedCredential{token: "destination"}, "tenant-b"))
+	uotm.SetTokenInfoForRole(common.ECredentialRole.Source(), common.NewOAuthTokenInfoFromCredential(fixedCredential{token: "source"}, "tenant-a"))
+	defer func() {
+		uotm.SetTokenInfoForRole(common.ECredentialRole.Source(), nil)
+		uotm.SetTokenInfo(nil)
+	}()
+
+	tokenFor := func(role common.CredentialRole) string {
+		tc, err := resumeCmdArgs{}.getTokenCredential(context.Background(), role)
+		a.NoError(err)
+		token, err := tc.GetToken(context.Background(), policy.TokenRequestOptions{Scopes: []string{common.StorageScope}})
+		a.NoError(err)
+		return token.Token
+	}
+	a.Equal("source", tokenFor(common.ECredentialRole.Source()))
+	a.Equal("destination", tokenFor(common.ECredentialRole.Destination()))
+}
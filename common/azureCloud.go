@@ -0,0 +1,174 @@
+// Copyright © 2017 Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package common
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/cloud"
+)
+
+const (
+	AzurePublicCloud       = "AzurePublic"
+	AzureUSGovernmentCloud = "AzureUSGovernment"
+	AzureChinaCloud        = "AzureChina"
+)
+
+// AzureCloud pairs the AAD configuration of an Azure cloud with the scope used to request storage tokens in it.
+type AzureCloud struct {
+	Name string
+	// Aliases are other names the cloud goes by, e.g. those used by the Azure CLI and the AZURE_CLOUD environment variable.
+	Aliases       []string
+	Configuration cloud.Configuration
+	StorageScope  string
+}
+
+// Storage currently accepts the same AAD audience in every cloud, but the scope is kept per cloud
+// so that callers never need to assume that.
+var azureClouds = []AzureCloud{
+	{Name: AzurePublicCloud, Aliases: []string{"AzureCloud", "AzurePublicCloud"}, Configuration: cloud.AzurePublic, StorageScope: StorageScope},
+	{Name: AzureUSGovernmentCloud, Aliases: []string{"AzureUSGovernmentCloud", "AzureGovernment"}, Configuration: cloud.AzureGovernment, StorageScope: StorageScope},
+	{Name: AzureChinaCloud, Aliases: []string{"AzureChinaCloud"}, Configuration: cloud.AzureChina, StorageScope: StorageScope},
+}
+
+// ResolveAzureCloud returns the cloud with the given name or alias, e.g. "AzureUSGovernment". Names are case-insensitive.
+func ResolveAzureCloud(name string) (AzureCloud, error) {
+	for _, c := range azureClouds {
+		if strings.EqualFold(c.Name, name) {
+			return c, nil
+		}
+		for _, alias := range c.Aliases {
+			if strings.EqualFold(alias, name) {
+				return c, nil
+			}
+		}
+	}
+
+	return AzureCloud{}, fmt.Errorf("unknown Azure cloud %q, available values are %s, %s and %s", name, AzurePublicCloud, AzureUSGovernmentCloud, AzureChinaCloud)
+}
+
+// azureCloudForAuthority returns the cloud whose AAD authority is activeDirectoryEndpoint.
+// Endpoints of unknown clouds (e.g. Azure Stack) are used as-is, with the public storage scope.
+func azureCloudForAuthority(activeDirectoryEndpoint string) AzureCloud {
+	if activeDirectoryEndpoint == "" {
+		return azureClouds[0]
+	}
+
+	activeDirectoryEndpoint = normalizeActiveDirectoryEndpoint(activeDirectoryEndpoint)
+	if u, err := url.Parse(activeDirectoryEndpoint); err == nil {
+		for _, c := range azureClouds {
+			if authority, err := url.Parse(c.Configuration.ActiveDirectoryAuthorityHost); err == nil && strings.EqualFold(authority.Host, u.Host) {
+				return c
+			}
+		}
+	}
+
+	return AzureCloud{
+		Configuration: cloud.Configuration{ActiveDirectoryAuthorityHost: activeDirectoryEndpoint},
+		StorageScope:  StorageScope,
+	}
+}
+
+// normalizeActiveDirectoryEndpoint accepts a bare host such as login.chinacloudapi.cn, as users commonly pass one.
+func normalizeActiveDirectoryEndpoint(activeDirectoryEndpoint string) string {
+	if activeDirectoryEndpoint != "" && !strings.Contains(activeDirectoryEndpoint, "://") {
+		return "https://" + activeDirectoryEndpoint
+	}
+	return activeDirectoryEndpoint
+}
+
+// resolveActiveDirectoryEndpoint picks the AD endpoint to log in with: the one specified, or else the one set through
+// AZCOPY_ACTIVE_DIRECTORY_ENDPOINT, AZCOPY_AUTH_ENDPOINT or AZURE_AUTHORITY_HOST, or else the authority of the cloud named by AZURE_CLOUD,
+// or else the public cloud.
+func resolveActiveDirectoryEndpoint(activeDirectoryEndpoint string) (string, error) {
+	if activeDirectoryEndpoint != "" {
+		return normalizeActiveDirectoryEndpoint(activeDirectoryEndpoint), nil
+	}
+
+	if endpoint, source := lookupLoginSetting(EEnvironmentVariable.AADEndpoint(), EEnvironmentVariable.AuthEndpoint(), EEnvironmentVariable.AzureAuthorityHost()); endpoint != "" {
+		endpoint = strings.TrimSuffix(normalizeActiveDirectoryEndpoint(endpoint), "/")
+		logLoginSetting("Active Directory endpoint", endpoint, source)
+		return endpoint, nil
+	}
+
+	if name := lcm.GetEnvironmentVariable(EEnvironmentVariable.AzureCloud()); name != "" {
+		c, err := ResolveAzureCloud(name)
+		if err != nil {
+			return "", err
+		}
+		return strings.TrimSuffix(c.Configuration.ActiveDirectoryAuthorityHost, "/"), nil
+	}
+
+	return DefaultActiveDirectoryEndpoint, nil
+}
+
+// resolveTenantAndEndpoint resolves the tenant and AD endpoint of a login together, so that every login method
+// honors the same environment overrides.
+func resolveTenantAndEndpoint(tenantID, activeDirectoryEndpoint string) (string, string, error) {
+	endpoint, err := resolveActiveDirectoryEndpoint(activeDirectoryEndpoint)
+	if err != nil {
+		return "", "", err
+	}
+	return resolveTenantID(tenantID), endpoint, nil
+}
+
+// resolveTenantID picks the tenant to log in to: the one specified, or else the one set through AZCOPY_TENANT_ID
+// or AZURE_TENANT_ID, or else the default tenant.
+func resolveTenantID(tenantID string) string {
+	if tenantID != "" {
+		return tenantID
+	}
+	if tenantID, source := lookupLoginSetting(EEnvironmentVariable.TenantID(), EEnvironmentVariable.AzureTenantID()); tenantID != "" {
+		logLoginSetting("tenant", tenantID, source)
+		return tenantID
+	}
+	return DefaultTenantID
+}
+
+// ResolveApplicationID picks the application to log in with as a service principal: the one specified, or else
+// the one set through AZCOPY_SPA_APPLICATION_ID or AZURE_CLIENT_ID.
+func ResolveApplicationID(applicationID string) string {
+	if applicationID != "" {
+		return applicationID
+	}
+	applicationID, source := lookupLoginSetting(EEnvironmentVariable.ApplicationID(), EEnvironmentVariable.AzureClientID())
+	if applicationID != "" {
+		logLoginSetting("application", applicationID, source)
+	}
+	return applicationID
+}
+
+// lookupLoginSetting returns the value of the first of vars which is set, along with its name. AzCopy's own variables
+// come before the standard ones read by the Azure SDKs, so that they can tell AzCopy apart from other tools.
+func lookupLoginSetting(vars ...EnvironmentVariable) (value, source string) {
+	for _, v := range vars {
+		if value = lcm.GetEnvironmentVariable(v); value != "" {
+			return value, v.Name
+		}
+	}
+	return "", ""
+}
+
+func logLoginSetting(setting, value, source string) {
+	lcm.Info(fmt.Sprintf("Using %s %s from %s.", setting, value, source))
+}
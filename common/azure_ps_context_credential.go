@@ -56,6 +56,14 @@ type PowershellContextCredentialOptions struct {
 	// which is the tenant of the selected Azure subscription.
 	TenantID string
 
+	// AdditionallyAllowedTenants specifies tenants, other than TenantID, for which GetToken may be asked for a token.
+	// Add "*" to allow any tenant.
+	AdditionallyAllowedTenants []string
+
+	// Transport is accepted so this credential is configured like azcopy's others. Azure PowerShell makes its own
+	// requests, so the default token provider doesn't use it.
+	Transport policy.Transporter
+
 	tokenProvider PSTokenProvider
 }
 
@@ -90,7 +98,7 @@ func (c *PowershellContextCredential) GetToken(ctx context.Context, opts policy.
 		return at, errors.New(credNamePSContext + ": GetToken() exactly one scope")
 	}
 
-	tenant, err := resolveTenant(c.opts.TenantID, opts.TenantID, credNamePSContext, nil)
+	tenant, err := resolveTenant(c.opts.TenantID, opts.TenantID, credNamePSContext, c.opts.AdditionallyAllowedTenants)
 	if err != nil {
 		return at, err
 	}
@@ -119,11 +127,7 @@ var defaultAzdTokenProvider PSTokenProvider = func(ctx context.Context, _ string
 
 	r := regexp.MustCompile("(?s){.*Token.*ExpiresOn.*}")
 
-	if tenantID != "" {
-		tenantID += " -TenantId" + tenantID
-	}
-	cmd := "Get-AzAccessToken -ResourceUrl https://storage.azure.com" + tenantID + " | ConvertTo-Json"
-	
+	cmd := psAccessTokenCommand(tenantID)
 
 	cliCmd := exec.CommandContext(ctx, "pwsh", "-Command", cmd)
 	cliCmd.Env = os.Environ()
@@ -131,6 +135,9 @@ var defaultAzdTokenProvider PSTokenProvider = func(ctx context.Context, _ string
 	cliCmd.Stderr = &stderr
 
 	output, err := cliCmd.Output()
+	if errors.Is(err, exec.ErrNotFound) {
+		return nil, errors.New(credNamePSContext + ": PowerShell (pwsh) was not found on the PATH. Install PowerShell 7 and the Az module, then run Connect-AzAccount")
+	}
 	if err != nil {
 		msg := stderr.String()
 		if msg == "" {
@@ -148,6 +155,15 @@ var defaultAzdTokenProvider PSTokenProvider = func(ctx context.Context, _ string
 	return output, nil
 }
 
+// psAccessTokenCommand is the PowerShell command for a storage token, from the tenant's context if one is given.
+func psAccessTokenCommand(tenantID string) string {
+	cmd := "Get-AzAccessToken -ResourceUrl https://storage.azure.com"
+	if tenantID != "" {
+		cmd += " -TenantId " + tenantID
+	}
+	return cmd + " | ConvertTo-Json"
+}
+
 func (c *PowershellContextCredential) createAccessToken(tk []byte) (azcore.AccessToken, error) {
 	t := struct {
 		AccessToken string `json:"Token"`
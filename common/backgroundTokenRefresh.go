@@ -0,0 +1,321 @@
+// Copyright © 2017 Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package common
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+)
+
+const (
+	defaultTokenRefreshWindow = minimumTokenValidDuration
+	// Failed background refreshes are retried with exponential backoff between these bounds.
+	minTokenRefreshBackoff = 5 * time.Second
+	maxTokenRefreshBackoff = 5 * time.Minute
+)
+
+// tokenRefreshWindow is how long before expiry tokens are refreshed in the background,
+// configurable in minutes through AZCOPY_TOKEN_REFRESH_WINDOW.
+func tokenRefreshWindow() time.Duration {
+	if minutes, err := strconv.Atoi(lcm.GetEnvironmentVariable(EEnvironmentVariable.TokenRefreshWindow())); err == nil && minutes > 0 {
+		return time.Duration(minutes) * time.Minute
+	}
+	return defaultTokenRefreshWindow
+}
+
+// backgroundRefreshCredential caches the tokens of cred, and sends a single request for new ones however many callers
+// need them. Once started, it also refreshes them ahead of expiry, so that long-running jobs don't stall on a burst of
+// requests failing at the moment their token expires. Failed refreshes are retried with backoff for as long as the
+// current token remains valid, until the context it was started with is cancelled or Stop is called.
+type backgroundRefreshCredential struct {
+	cred   azcore.TokenCredential
+	window time.Duration
+	// metrics counts the requests made to cred, unless it counts them itself (see GetTokenCredential).
+	metrics *TokenRefreshMetrics
+
+	// credLock serializes every request to cred, as credentials like DeviceCodeCredential don't guard their own state.
+	credLock sync.Mutex
+
+	// lock guards the cached tokens. It's never held across a request to cred, so callers finding a valid token
+	// don't wait behind a slow one.
+	lock   sync.Mutex
+	tokens map[string]*backgroundRefreshEntry
+	// ctx bounds the background refreshes, which are only scheduled once it's set by Start.
+	ctx context.Context
+	// stopped keeps refreshes from being scheduled once the credential is discarded, see Stop.
+	stopped bool
+	// done is closed by Stop, releasing the goroutine waiting for ctx to be cancelled.
+	done chan struct{}
+}
+
+type backgroundRefreshEntry struct {
+	options  policy.TokenRequestOptions
+	token    azcore.AccessToken
+	failures int
+	timer    *time.Timer
+	// pending is the request for a new token in flight, which other callers wait for rather than sending their own.
+	pending *pendingTokenRequest
+}
+
+type pendingTokenRequest struct {
+	done  chan struct{}
+	token azcore.AccessToken
+	err   error
+}
+
+func newBackgroundRefreshCredential(cred azcore.TokenCredential, window time.Duration) *backgroundRefreshCredential {
+	return &backgroundRefreshCredential{
+		cred:    cred,
+		window:  window,
+		metrics: AuthMetrics,
+		tokens:  make(map[string]*backgroundRefreshEntry),
+		done:    make(chan struct{}),
+	}
+}
+
+// Start refreshes the tokens of this credential in the background from now on, until ctx is cancelled or Stop is called.
+// Starting a credential again, or once stopped, does nothing.
+func (c *backgroundRefreshCredential) Start(ctx context.Context) {
+	c.lock.Lock()
+	if c.ctx != nil || c.stopped {
+		c.lock.Unlock()
+		return
+	}
+	c.ctx = ctx
+	for key, entry := range c.tokens {
+		if entry.pending == nil && !entry.token.ExpiresOn.IsZero() {
+			c.schedule(key, entry)
+		}
+	}
+	c.lock.Unlock()
+
+	go func() {
+		select {
+		case <-ctx.Done():
+			c.Stop()
+		case <-c.done:
+		}
+	}()
+}
+
+func (c *backgroundRefreshCredential) GetToken(ctx context.Context, options policy.TokenRequestOptions) (azcore.AccessToken, error) {
+	// Claims challenges demand a new token right away, which can't be served from the cache. The token which
+	// satisfies the challenge replaces the cached one, as the cached one has been revoked.
+	if options.Claims != "" {
+		token, err := c.requestToken(ctx, options)
+		if err == nil {
+			c.lock.Lock()
+			if entry, ok := c.tokens[backgroundRefreshKey(options)]; ok {
+				entry.token = token
+			}
+			c.lock.Unlock()
+		}
+		return token, err
+	}
+
+	key := backgroundRefreshKey(options)
+	c.lock.Lock()
+	entry, ok := c.tokens[key]
+	if ok && time.Until(entry.token.ExpiresOn) > minimumTokenValidDuration {
+		token := entry.token
+		c.lock.Unlock()
+		return token, nil
+	}
+	if !ok {
+		entry = &backgroundRefreshEntry{options: options}
+		c.tokens[key] = entry
+	}
+	c.lock.Unlock()
+
+	token, err := c.fetch(ctx, key, entry)
+	if err != nil {
+		// Rather than failing the transfer, keep using the current token while it lasts. The background refresh retries.
+		c.lock.Lock()
+		current := entry.token
+		c.lock.Unlock()
+		if time.Now().Before(current.ExpiresOn) {
+			c.metrics.recordFallback()
+			logTokenRefresh(fmt.Sprintf("OAuth token refresh from %s for scopes %v failed, using the current token until it expires at %s: %v",
+				credentialKind(c.cred), options.Scopes, current.ExpiresOn.UTC().Format(time.RFC3339), err))
+			return current, nil
+		}
+		return azcore.AccessToken{}, err
+	}
+	return token, nil
+}
+
+// fetch requests a new token for entry and schedules its next refresh. If a request for entry is already in flight,
+// it waits for that one instead; a request failing fails all its waiters.
+func (c *backgroundRefreshCredential) fetch(ctx context.Context, key string, entry *backgroundRefreshEntry) (azcore.AccessToken, error) {
+	c.lock.Lock()
+	if pending := entry.pending; pending != nil {
+		c.lock.Unlock()
+		select {
+		case <-pending.done:
+			return pending.token, pending.err
+		case <-ctx.Done():
+			return azcore.AccessToken{}, ctx.Err()
+		}
+	}
+	pending := &pendingTokenRequest{done: make(chan struct{})}
+	entry.pending = pending
+	c.lock.Unlock()
+
+	pending.token, pending.err = c.requestToken(ctx, entry.options)
+
+	c.lock.Lock()
+	entry.pending = nil
+	if pending.err == nil {
+		entry.token = pending.token
+		entry.failures = 0
+		c.schedule(key, entry)
+	}
+	c.lock.Unlock()
+	close(pending.done)
+	return pending.token, pending.err
+}
+
+// Stop cancels the pending background refreshes, and schedules no more. Tokens are still requested on demand.
+// Each scheduled refresh arms the next, so a started credential keeps refreshing until stopped or its context is cancelled.
+func (c *backgroundRefreshCredential) Stop() {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if !c.stopped {
+		c.stopped = true
+		close(c.done)
+	}
+	for _, entry := range c.tokens {
+		if entry.timer != nil {
+			entry.timer.Stop()
+			entry.timer = nil
+		}
+	}
+}
+
+// requestToken requests a new token from the underlying credential, recording the request in the metrics.
+func (c *backgroundRefreshCredential) requestToken(ctx context.Context, options policy.TokenRequestOptions) (azcore.AccessToken, error) {
+	start := time.Now()
+	c.credLock.Lock()
+	token, err := c.cred.GetToken(ctx, options)
+	c.credLock.Unlock()
+	c.metrics.recordRefresh(start, err)
+	if err == nil {
+		warnOnTokenClockSkew(token.Token)
+	}
+	return token, err
+}
+
+// serializeTokenRequest runs request, which calls on the credential underneath cred, under the lock of cred's background
+// refresher if it has one. Requests made to that credential other than through the refresher must go through here.
+func serializeTokenRequest(cred azcore.TokenCredential, request func() error) error {
+	if refresher, ok := cred.(*backgroundRefreshCredential); ok {
+		refresher.credLock.Lock()
+		defer refresher.credLock.Unlock()
+	}
+	return request()
+}
+
+// cachedToken returns the token currently held for options, without requesting one.
+func (c *backgroundRefreshCredential) cachedToken(options policy.TokenRequestOptions) (azcore.AccessToken, bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if entry, ok := c.tokens[backgroundRefreshKey(options)]; ok && !entry.token.ExpiresOn.IsZero() {
+		return entry.token, true
+	}
+	return azcore.AccessToken{}, false
+}
+
+func backgroundRefreshKey(options policy.TokenRequestOptions) string {
+	return options.TenantID + "|" + strings.Join(options.Scopes, " ")
+}
+
+// schedule arranges the next background refresh of entry. It must be called with the lock held.
+func (c *backgroundRefreshCredential) schedule(key string, entry *backgroundRefreshEntry) {
+	if entry.timer != nil {
+		entry.timer.Stop()
+		entry.timer = nil
+	}
+	if c.ctx == nil || c.stopped {
+		return
+	}
+
+	if delay, ok := c.nextRefresh(entry); ok {
+		entry.timer = time.AfterFunc(delay, func() { c.refresh(key, entry) })
+	}
+}
+
+// nextRefresh returns how long to wait before refreshing entry, or false if its token expires before then,
+// in which case the next GetToken fetches a new one.
+func (c *backgroundRefreshCredential) nextRefresh(entry *backgroundRefreshEntry) (time.Duration, bool) {
+	remaining := time.Until(entry.token.ExpiresOn)
+	if entry.failures == 0 {
+		// Tokens which live for less than twice the window are refreshed halfway through their lifetime instead.
+		delay := remaining - c.window
+		if delay < remaining/2 {
+			delay = remaining / 2
+		}
+		return delay, delay > 0
+	}
+
+	delay := minTokenRefreshBackoff << (entry.failures - 1)
+	if delay > maxTokenRefreshBackoff || delay <= 0 {
+		delay = maxTokenRefreshBackoff
+	}
+	return delay, delay < remaining
+}
+
+func (c *backgroundRefreshCredential) refresh(key string, entry *backgroundRefreshEntry) {
+	// The timer may have fired as the credential was stopped.
+	c.lock.Lock()
+	stopped, parent := c.stopped, c.ctx
+	c.lock.Unlock()
+	if stopped || parent == nil {
+		return
+	}
+
+	start := time.Now()
+	ctx, cancel := context.WithTimeout(parent, tokenRequestTimeout())
+	token, err := c.fetch(ctx, key, entry)
+	cancel()
+
+	if err == nil {
+		logTokenRefresh(fmt.Sprintf("OAuth token refreshed in the background from %s for scopes %v in %v, expires at %s",
+			credentialKind(c.cred), entry.options.Scopes, time.Since(start), token.ExpiresOn.UTC().Format(time.RFC3339)))
+		return
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.tokens[key] != entry {
+		return
+	}
+	entry.failures++
+	logTokenRefresh(fmt.Sprintf("Background OAuth token refresh from %s for scopes %v failed (attempt %d), the current token expires at %s: %v",
+		credentialKind(c.cred), entry.options.Scopes, entry.failures, entry.token.ExpiresOn.UTC().Format(time.RFC3339), err))
+	c.schedule(key, entry)
+}
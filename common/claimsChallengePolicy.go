@@ -0,0 +1,130 @@
+// Copyright © 2017 Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package common
+
+import (
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+)
+
+// claimsChallengePolicy handles the claims challenges of continuous access evaluation (CAE). When conditional access
+// revokes a session, storage rejects its token with a 401 carrying the claims a new token must satisfy. The policy
+// requests such a token and replays the request once, then keeps authorizing requests with it, as the SDK's bearer
+// token policy would otherwise carry on with the revoked token until it expires.
+type claimsChallengePolicy struct {
+	cred   azcore.TokenCredential
+	scopes []string
+
+	lock  sync.RWMutex
+	token *azcore.AccessToken
+}
+
+// NewClaimsChallengePolicy returns a per-retry policy which satisfies claims challenges with tokens from cred.
+// It must come after the policy authorizing requests, so that it sees their responses first.
+func NewClaimsChallengePolicy(cred azcore.TokenCredential, scopes []string) policy.Policy {
+	return &claimsChallengePolicy{cred: cred, scopes: scopes}
+}
+
+func (p *claimsChallengePolicy) Do(req *policy.Request) (*http.Response, error) {
+	if token, ok := p.challengeToken(); ok {
+		req.Raw().Header.Set("Authorization", "Bearer "+token)
+	}
+
+	resp, err := req.Next()
+	if err != nil || resp.StatusCode != http.StatusUnauthorized {
+		return resp, err
+	}
+	claims := parseClaimsChallenge(resp.Header.Get("WWW-Authenticate"))
+	if claims == "" {
+		return resp, err
+	}
+
+	token, tokenErr := p.cred.GetToken(req.Raw().Context(), policy.TokenRequestOptions{Scopes: p.scopes, Claims: claims})
+	if tokenErr != nil {
+		logTokenRefresh(fmt.Sprintf("OAuth token satisfying the claims challenge for scopes %v could not be acquired from %s: %v",
+			p.scopes, credentialKind(p.cred), tokenErr))
+		return resp, err
+	}
+	if rewindErr := req.RewindBody(); rewindErr != nil {
+		return resp, err
+	}
+	logTokenRefresh(fmt.Sprintf("OAuth token acquired from %s for scopes %v to satisfy a claims challenge, expires at %s",
+		credentialKind(p.cred), p.scopes, token.ExpiresOn.UTC().Format(time.RFC3339)))
+
+	p.lock.Lock()
+	p.token = &token
+	p.lock.Unlock()
+
+	// Release the rejected response's connection before replaying.
+	_, _ = io.Copy(io.Discard, resp.Body)
+	_ = resp.Body.Close()
+	req.Raw().Header.Set("Authorization", "Bearer "+token.Token)
+	return req.Next()
+}
+
+// challengeToken returns the token which satisfied the last claims challenge, for as long as it's valid.
+func (p *claimsChallengePolicy) challengeToken() (string, bool) {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+	if p.token == nil || time.Until(p.token.ExpiresOn) < minimumTokenValidDuration {
+		return "", false
+	}
+	return p.token.Token, true
+}
+
+var challengeParameter = regexp.MustCompile(`(\w+)="([^"]*)"`)
+
+// parseClaimsChallenge returns the claims requested by an insufficient claims challenge, or "" for other challenges.
+func parseClaimsChallenge(header string) string {
+	if !strings.HasPrefix(strings.ToLower(strings.TrimSpace(header)), "bearer") {
+		return ""
+	}
+
+	var challengeError, claims string
+	for _, match := range challengeParameter.FindAllStringSubmatch(header, -1) {
+		switch strings.ToLower(match[1]) {
+		case "error":
+			challengeError = match[2]
+		case "claims":
+			claims = match[2]
+		}
+	}
+	if challengeError != "insufficient_claims" || claims == "" {
+		return ""
+	}
+
+	// The claims are base64 encoded JSON, with or without padding depending on the service.
+	for _, encoding := range []*base64.Encoding{base64.StdEncoding, base64.RawStdEncoding, base64.URLEncoding, base64.RawURLEncoding} {
+		if decoded, err := encoding.DecodeString(claims); err == nil {
+			return string(decoded)
+		}
+	}
+	return ""
+}
@@ -15,3 +15,8 @@ func (p gnomeKeyring) Get(Service string, Account string) (string, error) {
 	// By design, not useful for non integration scenario.
 	return "", errors.New("Not implemented")
 }
+
+// Clear has nothing to remove, as nothing is stored for non integration scenario.
+func (p gnomeKeyring) Clear(service string) (bool, error) {
+	return false, nil
+}
@@ -30,6 +30,15 @@ gchar * gkr_get_password(gchar *service, gchar *account, GError **err) {
 		"account", account,
 		NULL);
 }
+
+gboolean gkr_clear_passwords(gchar *service, GError **err) {
+	return secret_password_clear_sync(
+		&keyring_schema,
+		NULL,
+		err,
+		"service", service,
+		NULL);
+}
 */
 import "C"
 
@@ -63,3 +72,18 @@ func (p gnomeKeyring) Get(service string, account string) (string, error) {
 	}
 	return C.GoString((*C.char)(pw)), nil
 }
+
+// Clear removes all the passwords of the service, and returns whether there were any.
+func (p gnomeKeyring) Clear(service string) (bool, error) {
+	var gErr *C.GError
+
+	cStrService := (*C.gchar)(C.CString(service))
+	defer C.free(unsafe.Pointer(cStrService))
+
+	removed := C.gkr_clear_passwords(cStrService, &gErr)
+	if gErr != nil {
+		defer C.g_error_free(gErr)
+		return false, fmt.Errorf("GnomeKeyring failed to clear: %+v", gErr)
+	}
+	return removed != 0, nil
+}
@@ -105,6 +105,17 @@ func (c *CredCacheInternalIntegration) removeCachedTokenInternal() error {
 	return errors.New("Not implemented")
 }
 
+// removeTokenStoreTokens deletes the token store entries of every AzCopy process, and returns whether there were any.
+// All the entries of the azcopy service are token store ones, so they're cleared at once as gnome keyring can't
+// match accounts by prefix.
+func removeTokenStoreTokens() (bool, error) {
+	removed, err := gnomeKeyring{}.Clear(tokenStoreServiceName)
+	if err != nil {
+		return false, fmt.Errorf("failed to remove token store entries from gnome keyring, %v", err)
+	}
+	return removed, nil
+}
+
 // loadTokenInternal restores a Token object from file cache.
 //nolint:staticcheck
 func (c *CredCacheInternalIntegration) loadTokenInternal() (*OAuthTokenInfo, error) {
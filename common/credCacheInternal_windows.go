@@ -111,6 +111,25 @@ func (c *CredCacheInternalIntegration) removeCachedTokenInternal() error {
 	return errors.New("Not implemented")
 }
 
+// removeTokenStoreTokens deletes the token store entries of every AzCopy process, including their segments,
+// and returns whether there were any.
+func removeTokenStoreTokens() (bool, error) {
+	creds, err := wincred.FilteredList(tokenStoreServiceName + "/" + tokenStoreAccountPrefix + "*")
+	if err != nil {
+		return false, fmt.Errorf("failed to list token store entries, %v", err)
+	}
+	for _, cred := range creds {
+		entry, err := wincred.GetGenericCredential(cred.TargetName)
+		if err != nil {
+			return false, fmt.Errorf("failed to read %s, %v", cred.TargetName, err)
+		}
+		if err := entry.Delete(); err != nil {
+			return false, fmt.Errorf("failed to remove %s, %v", cred.TargetName, err)
+		}
+	}
+	return len(creds) != 0, nil
+}
+
 // segmentTokenInfo is used to present information about segmented token saved in credential manager.
 type segmentedTokenHeader struct {
 	SegmentNum string `json:"SegmentNum"`
@@ -23,6 +23,7 @@ package common
 import (
 	"errors"
 	"fmt"
+	"strings"
 	"sync"
 
 	"github.com/wastore/keychain" // forked and customized from github.com/keybase/go-keychain, todo: make a release to ensure stability
@@ -144,6 +145,25 @@ func (c *CredCache) removeCachedTokenInternal() error {
 	return nil
 }
 
+// removeTokenStoreTokens deletes the token store entries of every AzCopy process, and returns whether there were any.
+func removeTokenStoreTokens() (bool, error) {
+	accounts, err := keychain.GetAccountsForService(tokenStoreServiceName)
+	if err != nil {
+		return false, fmt.Errorf("failed to list token store entries, %v", handleGenericKeyChainSecError(err))
+	}
+	removed := false
+	for _, account := range accounts {
+		if !strings.HasPrefix(account, tokenStoreAccountPrefix) {
+			continue
+		}
+		if err := keychain.DeleteGenericPasswordItem(tokenStoreServiceName, account); err != nil {
+			return removed, fmt.Errorf("failed to remove %s, %v", account, handleGenericKeyChainSecError(err))
+		}
+		removed = true
+	}
+	return removed, nil
+}
+
 // saveTokenInternal saves an oauth token in keychain(use user's default keychain, i.e. login keychain).
 func (c *CredCache) saveTokenInternal(token OAuthTokenInfo) error {
 	b, err := token.toJSON()
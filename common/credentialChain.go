@@ -0,0 +1,153 @@
+// Copyright © 2017 Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package common
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// credentialChainProbeTimeout bounds the requests to the managed identity endpoint made by the credential chain,
+// so that it moves on quickly off Azure, where the endpoint doesn't answer.
+const credentialChainProbeTimeout = 5 * time.Second
+
+// credentialChainLink is a way of logging in which the credential chain tries.
+type credentialChainLink struct {
+	name string
+	// tokenInfo returns the login of this link, or nil if the environment doesn't configure it.
+	tokenInfo func(tenantID string) *OAuthTokenInfo
+}
+
+// credentialChainLinks are tried in order by CredentialChainLogin.
+var credentialChainLinks = []credentialChainLink{
+	{name: "EnvironmentServicePrincipal", tokenInfo: environmentServicePrincipalLogin},
+	{name: "WorkloadIdentity", tokenInfo: func(string) *OAuthTokenInfo {
+		if lcm.GetEnvironmentVariable(EEnvironmentVariable.AzureFederatedTokenFile()) == "" {
+			return nil
+		}
+		return &OAuthTokenInfo{
+			WorkloadIdentity: true,
+			Tenant:           lcm.GetEnvironmentVariable(EEnvironmentVariable.AzureTenantID()),
+			ApplicationID:    lcm.GetEnvironmentVariable(EEnvironmentVariable.AzureClientID()),
+			SPNInfo: SPNInfo{
+				FederatedTokenFile: lcm.GetEnvironmentVariable(EEnvironmentVariable.AzureFederatedTokenFile()),
+			},
+		}
+	}},
+	{name: "ManagedIdentity", tokenInfo: func(string) *OAuthTokenInfo {
+		return &OAuthTokenInfo{
+			Identity: true,
+			IdentityInfo: IdentityInfo{
+				ClientID:     lcm.GetEnvironmentVariable(EEnvironmentVariable.ManagedIdentityClientID()),
+				MSIResID:     lcm.GetEnvironmentVariable(EEnvironmentVariable.ManagedIdentityResourceString()),
+				ProbeTimeout: credentialChainProbeTimeout,
+			},
+		}
+	}},
+	{name: "AzureCLI", tokenInfo: func(tenantID string) *OAuthTokenInfo {
+		return &OAuthTokenInfo{AzCLICred: true, Tenant: tenantID}
+	}},
+}
+
+// environmentServicePrincipalLogin returns the service principal login configured through AzCopy's or the standard
+// environment variables, with a client secret or a certificate.
+func environmentServicePrincipalLogin(tenantID string) *OAuthTokenInfo {
+	applicationID := ResolveApplicationID("")
+	if applicationID == "" {
+		return nil
+	}
+	tokenInfo := &OAuthTokenInfo{ServicePrincipalName: true, Tenant: tenantID, ApplicationID: applicationID}
+
+	if secret, _ := lookupLoginSetting(EEnvironmentVariable.ClientSecret(), EEnvironmentVariable.AzureClientSecret()); secret != "" {
+		tokenInfo.SPNInfo.Secret = secret
+		return tokenInfo
+	}
+	if certPath, _ := lookupLoginSetting(EEnvironmentVariable.CertificatePath(), EEnvironmentVariable.AzureClientCertificatePath()); certPath != "" {
+		tokenInfo.SPNInfo.CertPath, _ = filepath.Abs(certPath)
+		tokenInfo.SPNInfo.Secret, _ = lookupLoginSetting(EEnvironmentVariable.CertificatePassword(), EEnvironmentVariable.AzureClientCertificatePassword())
+		return tokenInfo
+	}
+	return nil
+}
+
+// CredentialChainLogin logs in with the first of environment service principal, workload identity, managed identity
+// and Azure CLI credentials which succeeds, like the Azure SDK's DefaultAzureCredential. The link which succeeded is
+// recorded in the login, so that later requests for token info in this process reuse it rather than walk the chain,
+// as do later processes when persist is set (see credentialChainLinkLogin).
+// Links which fail are logged at debug level, and listed in the error if all of them fail.
+func (uotm *UserOAuthTokenManager) CredentialChainLogin(tenantID string, persist bool) error {
+	if stashed := uotm.stashedInfo; stashed != nil && stashed.CredentialChainLink != "" {
+		return nil
+	}
+
+	var failures []string
+	for _, link := range credentialChainLinks {
+		oAuthTokenInfo := link.tokenInfo(tenantID)
+		if oAuthTokenInfo == nil {
+			logCredentialChain(fmt.Sprintf("Credential chain skipped %s, as it isn't configured", link.name))
+			continue
+		}
+		oAuthTokenInfo.CredentialChainLink = link.name
+
+		if err := uotm.validateAndPersistLogin(oAuthTokenInfo, persist); err != nil {
+			logCredentialChain(fmt.Sprintf("Credential chain failed to log in with %s: %v", link.name, err))
+			failures = append(failures, fmt.Sprintf("%s: %v", link.name, err))
+			continue
+		}
+		logCredentialChain(fmt.Sprintf("Credential chain logged in with %s", link.name))
+		return nil
+	}
+
+	if len(failures) == 0 {
+		return newTokenInfoError(ErrNoCachedToken, nil, "no credential was found in the environment")
+	}
+	return fmt.Errorf("failed to log in with any credential of the chain:\n\t%s", strings.Join(failures, "\n\t"))
+}
+
+// credentialChainLinkLogin rebuilds a persisted credential chain login from the link recorded in it, with the credentials
+// the environment holds now, so that a resumed login goes straight to the link which worked rather than walk the chain.
+func credentialChainLinkLogin(cached *OAuthTokenInfo) (*OAuthTokenInfo, error) {
+	for _, link := range credentialChainLinks {
+		if link.name != cached.CredentialChainLink {
+			continue
+		}
+
+		tokenInfo := link.tokenInfo(cached.Tenant)
+		if tokenInfo == nil {
+			return nil, newTokenInfoError(ErrNoCachedToken, nil, fmt.Sprintf("%s is no longer configured for the credential chain login, please log in with azcopy's login command again", link.name))
+		}
+		tokenInfo.CredentialChainLink = link.name
+		tokenInfo.CustomScopes = cached.CustomScopes
+		tokenInfo.AdditionalTenants = cached.AdditionalTenants
+		logCredentialChain(fmt.Sprintf("Credential chain resumed the login with %s", link.name))
+		return tokenInfo, nil
+	}
+
+	return nil, newTokenInfoError(ErrInvalidTokenInfo, nil, fmt.Sprintf("the cached login names an unknown credential chain link %q, please log in with azcopy's login command again", cached.CredentialChainLink))
+}
+
+func logCredentialChain(msg string) {
+	if AzcopyCurrentJobLogger != nil {
+		AzcopyCurrentJobLogger.Log(LogDebug, msg)
+	}
+}
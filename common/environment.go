@@ -60,10 +60,26 @@ var VisibleEnvironmentVariables = []EnvironmentVariable{
 	EEnvironmentVariable.AutoLoginType(),
 	EEnvironmentVariable.TenantID(),
 	EEnvironmentVariable.AADEndpoint(),
+	EEnvironmentVariable.AuthEndpoint(),
+	EEnvironmentVariable.AzureCloud(),
+	EEnvironmentVariable.BackgroundTokenRefresh(),
+	EEnvironmentVariable.TokenRefreshWindow(),
+	EEnvironmentVariable.TokenRequestTimeout(),
+	EEnvironmentVariable.DeviceCodeCache(),
+	EEnvironmentVariable.OAuthScopes(),
+	EEnvironmentVariable.AdditionallyAllowedTenants(),
+	EEnvironmentVariable.AuthCABundle(),
+	EEnvironmentVariable.AuthClientCertificate(),
+	EEnvironmentVariable.AuthClientKey(),
+	EEnvironmentVariable.DialTimeout(),
+	EEnvironmentVariable.TLSHandshakeTimeout(),
+	EEnvironmentVariable.MaxIdleConnsPerHost(),
 	EEnvironmentVariable.ApplicationID(),
 	EEnvironmentVariable.CertificatePath(),
+	EEnvironmentVariable.CertificateSNIAuth(),
 	EEnvironmentVariable.ManagedIdentityClientID(),
 	EEnvironmentVariable.ManagedIdentityObjectID(),
+	EEnvironmentVariable.ManagedIdentityResolveObjectID(),
 	EEnvironmentVariable.ManagedIdentityResourceString(),
 	EEnvironmentVariable.RequestTryTimeout(),
 	EEnvironmentVariable.CPKEncryptionKey(),
@@ -83,31 +99,41 @@ func (EnvironmentVariable) UserDir() EnvironmentVariable {
 }
 
 const (
-	AutologinTypeSPN    = "spn"
-	AutologinTypeMSI    = "msi"
-	AutologinTypeDevice = "device"
-	AutologinTypeAzCLI  = "azcli"
-	AutologinTypePsCred = "pscred"
+	AutologinTypeSPN      = "spn"
+	AutologinTypeMSI      = "msi"
+	AutologinTypeDevice   = "device"
+	AutologinTypeAzCLI    = "azcli"
+	AutologinTypePsCred   = "pscred"
+	AutologinTypeWorkload = "workload"
+	AutologinTypeAzd      = "azd"
+	AutologinTypeAuto     = "auto"
 )
 
 func (EnvironmentVariable) AutoLoginType() EnvironmentVariable {
 	return EnvironmentVariable{
 		Name:        "AZCOPY_AUTO_LOGIN_TYPE",
-		Description: "Specify the credential type to access Azure Resource without invoking the login command and using the OS secret store, available values SPN, MSI, DEVICE, AZCLI, and PSCRED  - sequentially for Service Principal, Managed Service Identity, Device workflow, Azure CLI, or Azure PowerShell.",
+		Description: "Specify the credential type to access Azure Resource without invoking the login command and using the OS secret store, available values SPN, MSI, DEVICE, AZCLI, PSCRED, WORKLOAD, AZD, and AUTO - sequentially for Service Principal, Managed Service Identity, Device workflow, Azure CLI, Azure PowerShell, Workload Identity, Azure Developer CLI, or the first of Service Principal (from the environment), Workload Identity, Managed Service Identity and Azure CLI which succeeds.",
 	}
 }
 
 func (EnvironmentVariable) TenantID() EnvironmentVariable {
 	return EnvironmentVariable{
 		Name:        "AZCOPY_TENANT_ID",
-		Description: "The Azure Active Directory tenant ID to use for OAuth device interactive login. This variable is only used for auto login, please use the command line flag instead when invoking the login command.",
+		Description: "The Azure Active Directory tenant ID to use for OAuth login when none is given to the login command. Takes precedence over AZURE_TENANT_ID.",
 	}
 }
 
 func (EnvironmentVariable) AADEndpoint() EnvironmentVariable {
 	return EnvironmentVariable{
 		Name:        "AZCOPY_ACTIVE_DIRECTORY_ENDPOINT",
-		Description: "The Azure Active Directory endpoint to use. This variable is only used for auto login, please use the command line flag instead when invoking the login command.",
+		Description: "The Azure Active Directory endpoint to use when none is given to the login command. Takes precedence over AZURE_AUTHORITY_HOST.",
+	}
+}
+
+func (EnvironmentVariable) AuthEndpoint() EnvironmentVariable {
+	return EnvironmentVariable{
+		Name:        "AZCOPY_AUTH_ENDPOINT",
+		Description: "An alias of AZCOPY_ACTIVE_DIRECTORY_ENDPOINT, which takes precedence over it.",
 	}
 }
 
@@ -115,7 +141,7 @@ func (EnvironmentVariable) ApplicationID() EnvironmentVariable {
 	// Used for auto-login.
 	return EnvironmentVariable{
 		Name:        "AZCOPY_SPA_APPLICATION_ID",
-		Description: "The Azure Active Directory application ID used for Service Principal authentication. This variable is only used for auto login, please use the command line flag instead when invoking the login command.",
+		Description: "The Azure Active Directory application ID used for Service Principal authentication when none is given to the login command. Takes precedence over AZURE_CLIENT_ID.",
 	}
 }
 
@@ -127,6 +153,13 @@ func (EnvironmentVariable) ClientSecret() EnvironmentVariable {
 	}
 }
 
+func (EnvironmentVariable) CertificateSNIAuth() EnvironmentVariable {
+	return EnvironmentVariable{
+		Name:        "AZCOPY_SPA_CERT_SNI_AUTH",
+		Description: "Set to true to send the certificate chain for Service Principal authentication, as required by applications trusting certificates by subject name and issuer (SNI). This variable is only used for auto login, please use the --send-certificate-chain flag instead when invoking the login command.",
+	}
+}
+
 func (EnvironmentVariable) CertificatePath() EnvironmentVariable {
 	return EnvironmentVariable{
 		Name:        "AZCOPY_SPA_CERT_PATH",
@@ -157,6 +190,13 @@ func (EnvironmentVariable) ManagedIdentityObjectID() EnvironmentVariable {
 	}
 }
 
+func (EnvironmentVariable) ManagedIdentityResolveObjectID() EnvironmentVariable {
+	return EnvironmentVariable{
+		Name:        "AZCOPY_MSI_RESOLVE_OBJECT_ID",
+		Description: "Set to true to translate the object ID of a user-assigned identity to its client ID through Microsoft Graph at login. The lookup authenticates with the environment, workload identity, managed identity or Azure CLI credentials available.",
+	}
+}
+
 func (EnvironmentVariable) ManagedIdentityResourceString() EnvironmentVariable {
 	return EnvironmentVariable{
 		Name:        "AZCOPY_MSI_RESOURCE_STRING",
@@ -164,6 +204,161 @@ func (EnvironmentVariable) ManagedIdentityResourceString() EnvironmentVariable {
 	}
 }
 
+func (EnvironmentVariable) AzureCloud() EnvironmentVariable {
+	return EnvironmentVariable{
+		Name:        "AZURE_CLOUD",
+		Description: "The Azure cloud to log in to when no AAD endpoint is specified, available values AzurePublic, AzureUSGovernment and AzureChina (the Azure CLI names AzureCloud, AzureUSGovernment and AzureChinaCloud are also accepted).",
+	}
+}
+
+// NO_PROXY lists the hosts which connections to AAD and the identity endpoints reach directly. Proxy settings are
+// otherwise looked up by GlobalProxyLookup.
+func (EnvironmentVariable) NoProxy() EnvironmentVariable {
+	return EnvironmentVariable{Name: "NO_PROXY", Hidden: true}
+}
+
+func (EnvironmentVariable) NoProxyLowercase() EnvironmentVariable {
+	return EnvironmentVariable{Name: "no_proxy", Hidden: true}
+}
+
+// IdentityHeader is the secret expected by identity endpoints such as App Service's, when one is configured for managed identity login.
+func (EnvironmentVariable) IdentityHeader() EnvironmentVariable {
+	return EnvironmentVariable{Name: "IDENTITY_HEADER", Hidden: true}
+}
+
+// IdentityEndpoint and ArcIMDSEndpoint are set by the Azure Connected Machine agent on Azure Arc-enabled servers.
+func (EnvironmentVariable) IdentityEndpoint() EnvironmentVariable {
+	return EnvironmentVariable{Name: "IDENTITY_ENDPOINT", Hidden: true}
+}
+
+func (EnvironmentVariable) ArcIMDSEndpoint() EnvironmentVariable {
+	return EnvironmentVariable{Name: "IMDS_ENDPOINT", Hidden: true}
+}
+
+// For workload identity login. These are the standard variables injected by the AKS workload identity webhook.
+func (EnvironmentVariable) AzureFederatedTokenFile() EnvironmentVariable {
+	return EnvironmentVariable{Name: "AZURE_FEDERATED_TOKEN_FILE"}
+}
+
+func (EnvironmentVariable) AzureClientID() EnvironmentVariable {
+	return EnvironmentVariable{Name: "AZURE_CLIENT_ID"}
+}
+
+func (EnvironmentVariable) AzureTenantID() EnvironmentVariable {
+	return EnvironmentVariable{Name: "AZURE_TENANT_ID"}
+}
+
+// For service principal login through the credential chain, after AzCopy's own variables.
+func (EnvironmentVariable) AzureClientSecret() EnvironmentVariable {
+	return EnvironmentVariable{Name: "AZURE_CLIENT_SECRET", Hidden: true}
+}
+
+func (EnvironmentVariable) AzureClientCertificatePath() EnvironmentVariable {
+	return EnvironmentVariable{Name: "AZURE_CLIENT_CERTIFICATE_PATH"}
+}
+
+func (EnvironmentVariable) AzureClientCertificatePassword() EnvironmentVariable {
+	return EnvironmentVariable{Name: "AZURE_CLIENT_CERTIFICATE_PASSWORD", Hidden: true}
+}
+
+// AzureAuthorityHost is the standard variable naming the authority of sovereign and private clouds to the Azure SDKs.
+func (EnvironmentVariable) AzureAuthorityHost() EnvironmentVariable {
+	return EnvironmentVariable{Name: "AZURE_AUTHORITY_HOST"}
+}
+
+// For client assertion login. These are provided by GitHub Actions to jobs with the id-token permission.
+func (EnvironmentVariable) ClientAssertionRequestURL() EnvironmentVariable {
+	return EnvironmentVariable{Name: "ACTIONS_ID_TOKEN_REQUEST_URL"}
+}
+
+func (EnvironmentVariable) ClientAssertionRequestToken() EnvironmentVariable {
+	return EnvironmentVariable{Name: "ACTIONS_ID_TOKEN_REQUEST_TOKEN", Hidden: true}
+}
+
+func (EnvironmentVariable) OAuthScopes() EnvironmentVariable {
+	return EnvironmentVariable{
+		Name:        "AZCOPY_OAUTH_SCOPES",
+		Description: "Comma separated token scopes to request in place of the storage audience, e.g. for private endpoints behind a custom STS. Scopes ending in //.default replace the managed disk audience. Scopes for service principal, workload identity and managed identity logins must end in /.default.",
+	}
+}
+
+func (EnvironmentVariable) AdditionallyAllowedTenants() EnvironmentVariable {
+	return EnvironmentVariable{
+		Name:        "AZCOPY_ADDITIONALLY_ALLOWED_TENANTS",
+		Description: "Comma separated IDs of tenants other than the login tenant that tokens may be requested for, e.g. when a service principal is granted access to a storage account through a guest assignment in another tenant. Use * to allow any tenant.",
+	}
+}
+
+func (EnvironmentVariable) DeviceCodeCache() EnvironmentVariable {
+	return EnvironmentVariable{
+		Name:        "AZCOPY_DEVICE_CODE_CACHE",
+		Description: "Name of a separate secure store entry in which logins are cached, e.g. device code logins, which later sessions refresh without prompting. Use a different name per tenant to keep their logins isolated.",
+	}
+}
+
+func (EnvironmentVariable) BackgroundTokenRefresh() EnvironmentVariable {
+	return EnvironmentVariable{
+		Name:        "AZCOPY_BACKGROUND_TOKEN_REFRESH",
+		Description: "Set to true to refresh OAuth tokens in the background before they expire, so that long-running jobs don't stall waiting for new ones. By default tokens are refreshed once they are about to expire.",
+	}
+}
+
+func (EnvironmentVariable) TokenRefreshWindow() EnvironmentVariable {
+	return EnvironmentVariable{
+		Name:        "AZCOPY_TOKEN_REFRESH_WINDOW",
+		Description: "Number of minutes before an OAuth token expires at which AzCopy refreshes it in the background, when AZCOPY_BACKGROUND_TOKEN_REFRESH is set. The default is 5.",
+	}
+}
+
+func (EnvironmentVariable) TokenRequestTimeout() EnvironmentVariable {
+	return EnvironmentVariable{
+		Name:        "AZCOPY_TOKEN_REQUEST_TIMEOUT",
+		Description: "Number of seconds AzCopy waits for each OAuth token request, e.g. when logging in, before giving up on an unreachable authority. The default is 30.",
+	}
+}
+
+func (EnvironmentVariable) AuthCABundle() EnvironmentVariable {
+	return EnvironmentVariable{
+		Name:        "AZCOPY_AUTH_CA_BUNDLE",
+		Description: "Path of a PEM bundle of root CAs to trust, in addition to the system ones, when connecting to Azure Active Directory and managed identity endpoints, e.g. behind a TLS-inspecting proxy.",
+	}
+}
+
+func (EnvironmentVariable) AuthClientCertificate() EnvironmentVariable {
+	return EnvironmentVariable{
+		Name:        "AZCOPY_AUTH_CLIENT_CERT",
+		Description: "Path of a PEM client certificate to present to Azure Active Directory and managed identity endpoints which require mutual TLS. The private key is read from AZCOPY_AUTH_CLIENT_KEY, or else from the certificate file.",
+	}
+}
+
+func (EnvironmentVariable) AuthClientKey() EnvironmentVariable {
+	return EnvironmentVariable{
+		Name:        "AZCOPY_AUTH_CLIENT_KEY",
+		Description: "Path of the PEM private key of the client certificate given by AZCOPY_AUTH_CLIENT_CERT.",
+	}
+}
+
+func (EnvironmentVariable) DialTimeout() EnvironmentVariable {
+	return EnvironmentVariable{
+		Name:        "AZCOPY_DIAL_TIMEOUT",
+		Description: "Number of seconds AzCopy waits for a network connection to be established. The defaults are 10 for token requests and 30 for transfers.",
+	}
+}
+
+func (EnvironmentVariable) TLSHandshakeTimeout() EnvironmentVariable {
+	return EnvironmentVariable{
+		Name:        "AZCOPY_TLS_HANDSHAKE_TIMEOUT",
+		Description: "Number of seconds AzCopy waits for a TLS handshake to complete, e.g. through a slow corporate proxy. The default is 10.",
+	}
+}
+
+func (EnvironmentVariable) MaxIdleConnsPerHost() EnvironmentVariable {
+	return EnvironmentVariable{
+		Name:        "AZCOPY_MAX_IDLE_CONNS_PER_HOST",
+		Description: "Maximum number of idle connections AzCopy keeps open to each host. By default it is derived from the concurrency for transfers, and is 1000 for token requests.",
+	}
+}
+
 func (EnvironmentVariable) ConcurrencyValue() EnvironmentVariable {
 	return EnvironmentVariable{
 		Name:        "AZCOPY_CONCURRENCY_VALUE",
@@ -955,6 +955,45 @@ func (ct *CredentialType) Parse(s string) error {
 
 ////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
 
+var ECredentialRole = CredentialRole(0)
+
+// CredentialRole identifies which side of a transfer an OAuth credential is used for,
+// so that the source and destination may authenticate against different tenants.
+type CredentialRole uint8
+
+func (CredentialRole) Default() CredentialRole     { return CredentialRole(0) } // The logged-in credential, used when no role-specific one is configured.
+func (CredentialRole) Source() CredentialRole      { return CredentialRole(1) }
+func (CredentialRole) Destination() CredentialRole { return CredentialRole(2) }
+
+func (cr CredentialRole) String() string {
+	return enum.StringInt(cr, reflect.TypeOf(cr))
+}
+
+////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
+
+var ELoginType = LoginType(0)
+
+// LoginType selects how an interactive user login is performed.
+type LoginType uint8
+
+func (LoginType) DeviceCode() LoginType  { return LoginType(0) }
+func (LoginType) Browser() LoginType     { return LoginType(1) }
+func (LoginType) Interactive() LoginType { return LoginType(2) } // Browser when one is available, and device code otherwise.
+
+func (lt *LoginType) Parse(s string) error {
+	val, err := enum.ParseInt(reflect.TypeOf(lt), s, true, true)
+	if err == nil {
+		*lt = val.(LoginType)
+	}
+	return err
+}
+
+func (lt LoginType) String() string {
+	return enum.StringInt(lt, reflect.TypeOf(lt))
+}
+
+////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
+
 var EOutputVerbosity = OutputVerbosity(0)
 
 type OutputVerbosity uint8
@@ -0,0 +1,191 @@
+// Copyright © 2017 Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package common
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"time"
+
+	"golang.org/x/net/http/httpproxy"
+)
+
+// HTTPClientOptions tunes the connections of the HTTP clients AzCopy creates, for token requests as well as transfers.
+// Zero values are left to the defaults of the client being created.
+type HTTPClientOptions struct {
+	DialTimeout         time.Duration
+	KeepAlive           time.Duration
+	TLSHandshakeTimeout time.Duration
+	IdleConnTimeout     time.Duration
+	MaxIdleConnsPerHost int
+	// TLSClientConfig optionally overrides the TLS settings, e.g. with a custom root CA bundle or a client certificate.
+	TLSClientConfig *tls.Config
+	// Proxy optionally overrides GlobalProxyLookup as the way proxies are looked up.
+	Proxy ProxyLookupFunc
+}
+
+// WithEnvironment overrides the options with those set through AZCOPY_DIAL_TIMEOUT, AZCOPY_TLS_HANDSHAKE_TIMEOUT
+// and AZCOPY_MAX_IDLE_CONNS_PER_HOST, e.g. for corporate proxies which are slow to complete TLS handshakes.
+func (o HTTPClientOptions) WithEnvironment() HTTPClientOptions {
+	if seconds, err := strconv.Atoi(lcm.GetEnvironmentVariable(EEnvironmentVariable.DialTimeout())); err == nil && seconds > 0 {
+		o.DialTimeout = time.Duration(seconds) * time.Second
+	}
+	if seconds, err := strconv.Atoi(lcm.GetEnvironmentVariable(EEnvironmentVariable.TLSHandshakeTimeout())); err == nil && seconds > 0 {
+		o.TLSHandshakeTimeout = time.Duration(seconds) * time.Second
+	}
+	if conns, err := strconv.Atoi(lcm.GetEnvironmentVariable(EEnvironmentVariable.MaxIdleConnsPerHost())); err == nil && conns > 0 {
+		o.MaxIdleConnsPerHost = conns
+	}
+	return o
+}
+
+// Dialer returns a dialer with the options' timeouts.
+func (o HTTPClientOptions) Dialer() *net.Dialer {
+	return &net.Dialer{
+		Timeout:   o.DialTimeout,
+		KeepAlive: o.KeepAlive,
+	}
+}
+
+// NewTransport returns a transport with the options applied, which dials connections with dialContext.
+func (o HTTPClientOptions) NewTransport(dialContext func(ctx context.Context, network, address string) (net.Conn, error)) *http.Transport {
+	proxy := o.Proxy
+	if proxy == nil {
+		proxy = GlobalProxyLookup
+	}
+	return &http.Transport{
+		Proxy:                  proxy,
+		DialContext:            dialContext,
+		MaxIdleConns:           0, // No limit
+		MaxIdleConnsPerHost:    o.MaxIdleConnsPerHost,
+		IdleConnTimeout:        o.IdleConnTimeout,
+		TLSHandshakeTimeout:    o.TLSHandshakeTimeout,
+		TLSClientConfig:        o.TLSClientConfig,
+		ExpectContinueTimeout:  1 * time.Second,
+		DisableKeepAlives:      false,
+		DisableCompression:     true, // must disable the auto-decompression of gzipped files, and just download the gzipped version. See https://github.com/Azure/azure-storage-azcopy/issues/374
+		MaxResponseHeaderBytes: 0,
+	}
+}
+
+// defaultTokenHTTPClientOptions are the options of the client used for token requests, see newAzcopyHTTPClient.
+var defaultTokenHTTPClientOptions = HTTPClientOptions{
+	DialTimeout:         10 * time.Second,
+	KeepAlive:           10 * time.Second,
+	TLSHandshakeTimeout: 10 * time.Second,
+	IdleConnTimeout:     180 * time.Second,
+	MaxIdleConnsPerHost: 1000,
+}
+
+// LoadTLSConfig builds TLS settings which trust the root CAs in the PEM bundle at caBundlePath on top of the system
+// ones, and present the client certificate at clientCertPath for mutual TLS. The certificate's private key is read
+// from clientKeyPath, or else from the certificate file itself. It returns nil if no path is given.
+func LoadTLSConfig(caBundlePath, clientCertPath, clientKeyPath string) (*tls.Config, error) {
+	if caBundlePath == "" && clientCertPath == "" && clientKeyPath == "" {
+		return nil, nil
+	}
+	config := &tls.Config{MinVersion: tls.VersionTLS12}
+
+	if caBundlePath != "" {
+		bundle, err := os.ReadFile(caBundlePath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA bundle %s, %v", caBundlePath, err)
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(bundle) {
+			return nil, fmt.Errorf("no PEM certificates found in CA bundle %s", caBundlePath)
+		}
+		config.RootCAs = pool
+	}
+
+	if clientCertPath == "" && clientKeyPath != "" {
+		return nil, errors.New("a client key was given without a client certificate")
+	}
+	if clientCertPath != "" {
+		if clientKeyPath == "" {
+			clientKeyPath = clientCertPath
+		}
+		cert, err := tls.LoadX509KeyPair(clientCertPath, clientKeyPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load client certificate %s, %v", clientCertPath, err)
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+	return config, nil
+}
+
+// authTLSConfigFromEnvironment loads the TLS settings of the connections to AAD and the identity endpoints from
+// AZCOPY_AUTH_CA_BUNDLE, AZCOPY_AUTH_CLIENT_CERT and AZCOPY_AUTH_CLIENT_KEY.
+func authTLSConfigFromEnvironment() (*tls.Config, error) {
+	return LoadTLSConfig(
+		lcm.GetEnvironmentVariable(EEnvironmentVariable.AuthCABundle()),
+		lcm.GetEnvironmentVariable(EEnvironmentVariable.AuthClientCertificate()),
+		lcm.GetEnvironmentVariable(EEnvironmentVariable.AuthClientKey()))
+}
+
+// imdsHost is the link-local address of the instance metadata service, which serves managed identity tokens.
+const imdsHost = "169.254.169.254"
+
+// authProxyLookup wraps lookup for the connections to AAD and the identity endpoints. IMDS is always reached directly,
+// as it only answers from within the VM and a proxy would intercept it, and so are the hosts listed in NO_PROXY.
+func authProxyLookup(lookup ProxyLookupFunc) ProxyLookupFunc {
+	noProxy := lcm.GetEnvironmentVariable(EEnvironmentVariable.NoProxy())
+	if noProxy == "" {
+		noProxy = lcm.GetEnvironmentVariable(EEnvironmentVariable.NoProxyLowercase())
+	}
+	// The proxy itself doesn't matter, a nil one only tells that the host is exempt from proxying.
+	exemptions := (&httpproxy.Config{HTTPProxy: "exempt", HTTPSProxy: "exempt", NoProxy: noProxy}).ProxyFunc()
+
+	return func(req *http.Request) (*url.URL, error) {
+		if req.URL.Hostname() == imdsHost {
+			return nil, nil
+		}
+		if noProxy != "" {
+			if proxy, err := exemptions(req.URL); err == nil && proxy == nil {
+				return nil, nil
+			}
+		}
+		if lookup == nil {
+			return nil, nil
+		}
+		return lookup(req)
+	}
+}
+
+// errorTransport fails every request with err.
+type errorTransport struct {
+	err error
+}
+
+func (t errorTransport) RoundTrip(*http.Request) (*http.Response, error) {
+	return nil, t.err
+}
@@ -0,0 +1,100 @@
+// Copyright © 2017 Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package common
+
+import (
+	"context"
+	"crypto"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"fmt"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/security/keyvault/azsecrets"
+)
+
+// KeyVaultCertificate locates a service principal certificate kept in Azure Key Vault.
+type KeyVaultCertificate struct {
+	VaultURL string `json:"_kv_vault_url,omitempty"`
+	Name     string `json:"_kv_cert_name,omitempty"`
+	// Bootstrap is the login used to download the certificate, such as a managed identity or the Azure CLI.
+	Bootstrap *OAuthTokenInfo `json:"_kv_bootstrap,omitempty"`
+}
+
+// GetKeyVaultCertificateCredential downloads the service principal certificate from Key Vault with the bootstrap login,
+// and authenticates with it. The certificate and its private key are only ever held in memory.
+func (credInfo *OAuthTokenInfo) GetKeyVaultCertificateCredential() (azcore.TokenCredential, error) {
+	kvCert := credInfo.SPNInfo.KeyVaultCert
+	if kvCert.Bootstrap == nil {
+		return nil, errors.New("a bootstrap login is required to download a certificate from Key Vault")
+	}
+	bootstrapCred, err := kvCert.Bootstrap.GetTokenCredential()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get bootstrap credential for Key Vault, %v", err)
+	}
+
+	client, err := azsecrets.NewClient(kvCert.VaultURL, bootstrapCred, &azsecrets.ClientOptions{
+		ClientOptions: azcore.ClientOptions{
+			Transport: newAzcopyHTTPClient(),
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	certs, key, err := downloadKeyVaultCertificate(context.TODO(), client, kvCert.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	return credInfo.newClientCertificateCredential(certs, key)
+}
+
+// downloadKeyVaultCertificate fetches a certificate along with its private key. Key Vault exposes both through the
+// secret backing the certificate, as base64 PKCS#12 or as PEM depending on the certificate's content type.
+func downloadKeyVaultCertificate(ctx context.Context, client *azsecrets.Client, name string) ([]*x509.Certificate, crypto.PrivateKey, error) {
+	resp, err := client.GetSecret(ctx, name, "", nil)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to download certificate %s from Key Vault, %v", name, err)
+	}
+	if resp.Value == nil {
+		return nil, nil, fmt.Errorf("key vault returned no content for certificate %s", name)
+	}
+
+	var certs []*x509.Certificate
+	var key crypto.PrivateKey
+	if resp.ContentType != nil && *resp.ContentType == "application/x-pkcs12" {
+		var data []byte
+		if data, err = base64.StdEncoding.DecodeString(*resp.Value); err != nil {
+			return nil, nil, fmt.Errorf("failed to decode certificate %s from Key Vault, %v", name, err)
+		}
+		certs, key, err = parseClientCertificate(name+".pfx", data, "")
+	} else {
+		certs, key, err = parseClientCertificate(name+".pem", []byte(*resp.Value), "")
+	}
+
+	if errors.Is(err, errCertificateNoPrivateKey) {
+		return nil, nil, fmt.Errorf("key vault certificate %s has no exportable private key, please issue it with a policy that marks the key as exportable", name)
+	} else if err != nil {
+		return nil, nil, fmt.Errorf("failed to parse certificate %s from Key Vault, %v", name, err)
+	}
+	return certs, key, nil
+}
@@ -59,6 +59,12 @@ var sensitiveQueryStringKeys = []string{
 func (s *azCopyLogSanitizer) SanitizeLogMessage(msg string) string {
 	lowerMsg := strings.ToLower(msg)
 
+	// JWTs, such as OAuth access tokens, may turn up anywhere, e.g. in a Bearer authorization header or a token dump.
+	// Their header always starts with eyJ, as it's base64 for {".
+	if strings.Contains(msg, "eyJ") {
+		msg = jwtRegex.ReplaceAllString(msg, "-REDACTED-")
+	}
+
 	for _, key := range sensitiveQueryStringKeys {
 		// take a quick look, using contains, and then get fancy only if we
 		// find something in the quick look
@@ -82,6 +88,9 @@ func (s *azCopyLogSanitizer) redact(msg, key string) string {
 	return sensitiveRegexMap[key].ReplaceAllString(msg, "$1"+redacted)
 }
 
+// jwtRegex matches the header, payload and (possibly empty, if unsigned) signature of a JWT.
+var jwtRegex = regexp.MustCompile(`eyJ[A-Za-z0-9_-]+\.[A-Za-z0-9_-]+\.[A-Za-z0-9_-]*`)
+
 // as per https://groups.google.com/forum/#!topic/golang-nuts/3FVAs9dPR8k, this map should be
 // safe for concurrent reads
 var sensitiveRegexMap = make(map[string]*regexp.Regexp)
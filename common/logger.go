@@ -138,7 +138,7 @@ func (jl jobLogger) Log(loglevel LogLevel, msg string) {
 }
 
 func (jl jobLogger) Panic(err error) {
-	jl.logger.Println(err) // We do NOT panic here as the app would terminate; we just log it
+	jl.logger.Println(jl.sanitizer.SanitizeLogMessage(err.Error())) // We do NOT panic here as the app would terminate; we just log it
 	panic(err)
 	// We should never reach this line of code!
 }
@@ -85,7 +85,7 @@ func (sl *sysLogger) CloseLog() {
 }
 
 func (sl *sysLogger) Panic(err error) {
-	_ = sl.writer.Crit(err.Error()) // We do NOT panic here as the app would terminate;
+	_ = sl.writer.Crit(sl.sanitizer.SanitizeLogMessage(err.Error())) // We do NOT panic here as the app would terminate;
 	//we just log it. We should never reach this line of code!
 }
 
@@ -0,0 +1,225 @@
+// Copyright © 2017 Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package common
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+)
+
+// managedIdentityEndpointCredential requests managed identity tokens from an explicitly configured identity endpoint,
+// for environments such as Azure Arc and App Service whose endpoint isn't the one azidentity discovers.
+// When a header secret is available (IDENTITY_HEADER) it uses the App Service protocol, otherwise the IMDS protocol.
+// Azure Arc's variant of IMDS additionally answers with a challenge naming a key file, which must be read and sent back.
+type managedIdentityEndpointCredential struct {
+	endpoint     *url.URL
+	identity     IdentityInfo
+	headerSecret string
+	client       *http.Client
+	// arc is set on Azure Arc machines, and arcKeyDir is the only directory the agent may point challenges at.
+	arc       bool
+	arcKeyDir string
+
+	lock   sync.Mutex
+	tokens map[string]azcore.AccessToken
+}
+
+func newManagedIdentityEndpointCredential(identity IdentityInfo, headerSecret string, client *http.Client) (*managedIdentityEndpointCredential, error) {
+	endpoint, err := url.Parse(identity.Endpoint)
+	if err != nil {
+		return nil, err
+	}
+
+	return &managedIdentityEndpointCredential{
+		endpoint:     endpoint,
+		identity:     identity,
+		headerSecret: headerSecret,
+		client:       client,
+		arc:          lcm.GetEnvironmentVariable(EEnvironmentVariable.ArcIMDSEndpoint()) != "",
+		arcKeyDir:    arcKeyDirectory(),
+		tokens:       make(map[string]azcore.AccessToken),
+	}, nil
+}
+
+// arcKeyDirectory returns where the Azure Connected Machine agent writes its challenge keys.
+func arcKeyDirectory() string {
+	switch runtime.GOOS {
+	case "linux":
+		return "/var/opt/azcmagent/tokens"
+	case "windows":
+		return filepath.Join(os.Getenv("ProgramData"), "AzureConnectedMachineAgent", "Tokens")
+	default:
+		return ""
+	}
+}
+
+func (c *managedIdentityEndpointCredential) GetToken(ctx context.Context, options policy.TokenRequestOptions) (azcore.AccessToken, error) {
+	if len(options.Scopes) != 1 {
+		return azcore.AccessToken{}, errors.New("managed identity requires exactly one scope per token request")
+	}
+	resource := strings.TrimSuffix(options.Scopes[0], "/.default")
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if token, ok := c.tokens[resource]; ok && time.Until(token.ExpiresOn) > minimumTokenValidDuration {
+		return token, nil
+	}
+
+	resp, err := c.requestToken(ctx, resource, "")
+	if err != nil {
+		return azcore.AccessToken{}, err
+	}
+	if challenge := resp.Header.Get("WWW-Authenticate"); resp.StatusCode == http.StatusUnauthorized && challenge != "" {
+		resp.Body.Close()
+		key, err := c.readArcChallengeKey(challenge)
+		if err != nil {
+			return azcore.AccessToken{}, err
+		}
+		if resp, err = c.requestToken(ctx, resource, key); err != nil {
+			return azcore.AccessToken{}, err
+		}
+	}
+	defer resp.Body.Close()
+
+	token, err := parseManagedIdentityToken(resp)
+	if err != nil {
+		return azcore.AccessToken{}, fmt.Errorf("managed identity endpoint %s failed to issue a token, %v", c.endpoint.Redacted(), err)
+	}
+	c.tokens[resource] = token
+	return token, nil
+}
+
+func (c *managedIdentityEndpointCredential) requestToken(ctx context.Context, resource, challengeKey string) (*http.Response, error) {
+	req, err := c.newTokenRequest(ctx, resource)
+	if err != nil {
+		return nil, err
+	}
+	if challengeKey != "" {
+		req.Header.Set("Authorization", "Basic "+challengeKey)
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to reach managed identity endpoint %s, %v", c.endpoint.Redacted(), err)
+	}
+	return resp, nil
+}
+
+// readArcChallengeKey reads the key named by an Azure Arc challenge (WWW-Authenticate: Basic realm=<key file>).
+// The agent only ever names small .key files in its own directory, so anything else is refused rather than read.
+func (c *managedIdentityEndpointCredential) readArcChallengeKey(challenge string) (string, error) {
+	const maxKeySize = 4096
+	realm := strings.TrimSpace(strings.TrimPrefix(challenge, "Basic realm="))
+	if !c.arc || realm == challenge || c.arcKeyDir == "" {
+		return "", fmt.Errorf("managed identity endpoint %s sent an unexpected challenge: %s", c.endpoint.Redacted(), challenge)
+	}
+
+	keyPath := filepath.Clean(realm)
+	if !strings.EqualFold(filepath.Dir(keyPath), filepath.Clean(c.arcKeyDir)) || filepath.Ext(keyPath) != ".key" {
+		return "", fmt.Errorf("refusing to read Azure Arc challenge key %s, which is not a .key file in %s", keyPath, c.arcKeyDir)
+	}
+	if info, err := os.Stat(keyPath); err != nil {
+		return "", fmt.Errorf("failed to read Azure Arc challenge key, %v", err)
+	} else if info.Size() > maxKeySize {
+		return "", fmt.Errorf("refusing to read Azure Arc challenge key %s, which is larger than %d bytes", keyPath, maxKeySize)
+	}
+
+	key, err := os.ReadFile(keyPath)
+	if err != nil {
+		return "", fmt.Errorf("failed to read Azure Arc challenge key, %v", err)
+	}
+	return string(key), nil
+}
+
+func (c *managedIdentityEndpointCredential) newTokenRequest(ctx context.Context, resource string) (*http.Request, error) {
+	q := c.endpoint.Query()
+	q.Set("resource", resource)
+	switch {
+	case c.headerSecret != "":
+		q.Set("api-version", "2019-08-01")
+	case c.arc:
+		q.Set("api-version", "2019-08-15")
+	default:
+		q.Set("api-version", "2018-02-01")
+	}
+	// The App Service protocol names the resource ID parameter differently from IMDS.
+	switch {
+	case c.identity.ClientID != "":
+		q.Set("client_id", c.identity.ClientID)
+	case c.identity.MSIResID != "" && c.headerSecret != "":
+		q.Set("mi_res_id", c.identity.MSIResID)
+	case c.identity.MSIResID != "":
+		q.Set("msi_res_id", c.identity.MSIResID)
+	}
+
+	u := *c.endpoint
+	u.RawQuery = q.Encode()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if c.headerSecret != "" {
+		req.Header.Set("X-IDENTITY-HEADER", c.headerSecret)
+	} else {
+		req.Header.Set("Metadata", "true")
+	}
+	return req, nil
+}
+
+// parseManagedIdentityToken reads a token response, in which expires_on may be a number or a numeric string.
+func parseManagedIdentityToken(resp *http.Response) (azcore.AccessToken, error) {
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return azcore.AccessToken{}, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return azcore.AccessToken{}, fmt.Errorf("unexpected status %s: %s", resp.Status, string(body))
+	}
+
+	var token struct {
+		AccessToken string      `json:"access_token"`
+		ExpiresOn   json.Number `json:"expires_on"`
+	}
+	if err := json.Unmarshal(body, &token); err != nil {
+		return azcore.AccessToken{}, fmt.Errorf("failed to parse token response, %v", err)
+	}
+	expiresOn, err := strconv.ParseInt(token.ExpiresOn.String(), 10, 64)
+	if err != nil || token.AccessToken == "" {
+		return azcore.AccessToken{}, errors.New("token response is missing access_token or expires_on")
+	}
+
+	return azcore.AccessToken{Token: token.AccessToken, ExpiresOn: time.Unix(expiresOn, 0)}, nil
+}
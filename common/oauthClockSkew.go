@@ -0,0 +1,140 @@
+// Copyright © 2017 Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package common
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// maxTokenClockSkew is how far the local clock may be off from the token issuer's before we warn about it.
+// Token lifetimes are at least an hour, and refreshes start minimumTokenValidDuration before expiry, so smaller skews go unnoticed.
+const maxTokenClockSkew = 5 * time.Minute
+
+// warnTokenTime reports problems with token times to the user, lcm.Warn unless overridden in tests.
+var warnTokenTime = func(msg string) { lcm.Warn(msg) }
+
+// tokenClockSkewWarned is set once clock skew has been reported, so that a skewed clock warns once, rather than on every token.
+var tokenClockSkewWarned int32
+
+// jwtTimeClaims are the time claims of a JWT access token, in seconds since the epoch.
+type jwtTimeClaims struct {
+	IssuedAt  *float64 `json:"iat"`
+	NotBefore *float64 `json:"nbf"`
+	Expiry    *float64 `json:"exp"`
+}
+
+func decodeJWTTimeClaims(token string) (jwtTimeClaims, error) {
+	var claims jwtTimeClaims
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return claims, errors.New("not a JWT")
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(parts[1], "="))
+	if err != nil {
+		return claims, fmt.Errorf("invalid JWT payload: %w", err)
+	}
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return claims, fmt.Errorf("invalid JWT claims: %w", err)
+	}
+	return claims, nil
+}
+
+func unixClaimTime(seconds *float64) time.Time {
+	if seconds == nil {
+		return time.Time{}
+	}
+	whole, frac := math.Modf(*seconds)
+	return time.Unix(int64(whole), int64(frac*float64(time.Second)))
+}
+
+// tokenClockSkew estimates how far the local clock (now) is off from the issuer's, from a token which was just issued.
+// The skew is negative if the local clock is behind (the token is issued, or valid, only in the future), and positive if it
+// is ahead (the token had already expired when it arrived). ok is false if token isn't a JWT, or shows no skew beyond maxTokenClockSkew.
+func tokenClockSkew(token string, now time.Time) (skew time.Duration, ok bool) {
+	claims, err := decodeJWTTimeClaims(token)
+	if err != nil {
+		return 0, false // e.g. an opaque token, which we can't tell anything from
+	}
+
+	start := unixClaimTime(claims.IssuedAt)
+	if notBefore := unixClaimTime(claims.NotBefore); notBefore.After(start) {
+		start = notBefore
+	}
+	if !start.IsZero() && start.Sub(now) > maxTokenClockSkew {
+		return now.Sub(start), true
+	}
+
+	if expiry := unixClaimTime(claims.Expiry); !expiry.IsZero() && now.Sub(expiry) > maxTokenClockSkew {
+		return now.Sub(expiry), true
+	}
+
+	return 0, false
+}
+
+// warnOnTokenClockSkew warns if a just issued token shows the local clock to be off, as tokens would then be refreshed
+// constantly, or used after the service considers them expired, failing requests with 401s.
+func warnOnTokenClockSkew(token string) {
+	skew, ok := tokenClockSkew(token, time.Now())
+	if !ok || !atomic.CompareAndSwapInt32(&tokenClockSkewWarned, 0, 1) {
+		return
+	}
+
+	direction := "ahead of"
+	if skew < 0 {
+		direction, skew = "behind", -skew
+	}
+	warnTokenTime(fmt.Sprintf("The system clock appears to be at least %s %s the clock of the token issuer. OAuth tokens may be rejected as "+
+		"expired, or refreshed constantly. Please synchronize the system clock (e.g. with NTP).", skew.Round(time.Minute), direction))
+}
+
+// badExpiresOnWarned holds the ExpiresOn values which have been reported as unparseable, so each is only reported once.
+var badExpiresOnWarned sync.Map
+
+// Expires is adal.Token's Expires, except that an ExpiresOn which can't be parsed is reported, rather than silently treated as
+// having expired an hour before the epoch. Such a token is still treated as expired.
+func (credInfo *OAuthTokenInfo) Expires() time.Time {
+	if credInfo.ExpiresOn != "" {
+		if _, err := credInfo.ExpiresOn.Float64(); err != nil {
+			if _, warned := badExpiresOnWarned.LoadOrStore(string(credInfo.ExpiresOn), true); !warned {
+				warnTokenTime(fmt.Sprintf("The OAuth token's expiry %q could not be parsed, so the token is treated as expired: %v", credInfo.ExpiresOn, err))
+			}
+		}
+	}
+	return credInfo.Token.Expires()
+}
+
+// WillExpireIn is adal.Token's WillExpireIn, using Expires.
+func (credInfo *OAuthTokenInfo) WillExpireIn(d time.Duration) bool {
+	return !credInfo.Expires().After(time.Now().Add(d))
+}
+
+// IsExpired is adal.Token's IsExpired, using Expires.
+func (credInfo *OAuthTokenInfo) IsExpired() bool {
+	return credInfo.WillExpireIn(0)
+}
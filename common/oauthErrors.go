@@ -0,0 +1,68 @@
+// Copyright © 2017 Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package common
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Kinds of failure to get token info, see TokenInfoError. Match them with errors.Is, e.g. to prompt for a login
+// on ErrNoCachedToken or ErrRefreshFailed, but abort on ErrInvalidTokenInfo.
+var (
+	// ErrEnvTokenNotSet indicates AZCOPY_OAUTH_TOKEN_INFO isn't set.
+	ErrEnvTokenNotSet = errors.New(ErrorCodeEnvVarOAuthTokenInfoNotSet)
+	// ErrNoCachedToken indicates there is no login cached.
+	ErrNoCachedToken = errors.New("no cached token found")
+	// ErrInvalidTokenInfo indicates token info which can't be used, e.g. a corrupt cache or malformed AZCOPY_OAUTH_TOKEN_INFO.
+	ErrInvalidTokenInfo = errors.New("invalid token info")
+	// ErrRefreshFailed indicates the token of a login couldn't be refreshed, e.g. because its refresh token expired.
+	ErrRefreshFailed = errors.New("token refresh failed")
+	// ErrTokenRemovalFailed indicates a cached login couldn't be deleted from the credential store.
+	ErrTokenRemovalFailed = errors.New("token removal failed")
+)
+
+// TokenInfoError is returned when token info can't be obtained, or a cached login can't be removed. It matches its Kind with errors.Is,
+// and unwraps to the error which caused it, if any.
+type TokenInfoError struct {
+	Kind    error
+	Message string
+	Err     error
+}
+
+func newTokenInfoError(kind error, err error, format string, a ...interface{}) *TokenInfoError {
+	return &TokenInfoError{Kind: kind, Message: fmt.Sprintf(format, a...), Err: err}
+}
+
+func (e *TokenInfoError) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("%s, %v", e.Message, e.Err)
+	}
+	return e.Message
+}
+
+func (e *TokenInfoError) Is(target error) bool {
+	return target == e.Kind
+}
+
+func (e *TokenInfoError) Unwrap() error {
+	return e.Err
+}
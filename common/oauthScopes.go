@@ -0,0 +1,156 @@
+// Copyright © 2017 Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package common
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+)
+
+// managedDiskScopeSuffix marks a custom scope as a managed disk audience. The disk service checks for the trailing
+// slash on its resource (see ManagedDiskScope), so those scopes end in "//.default".
+const managedDiskScopeSuffix = "//.default"
+
+// ParseCustomScopes splits a comma or space separated list of token scopes, as given to --scopes or AZCOPY_OAUTH_SCOPES.
+func ParseCustomScopes(value string) []string {
+	return strings.FieldsFunc(value, func(r rune) bool {
+		return r == ',' || r == ' '
+	})
+}
+
+// resolveCustomScopes picks the custom scopes to log in with: the ones specified, or else those in AZCOPY_OAUTH_SCOPES.
+func resolveCustomScopes(scopes []string) []string {
+	if len(scopes) != 0 {
+		return scopes
+	}
+	return ParseCustomScopes(lcm.GetEnvironmentVariable(EEnvironmentVariable.OAuthScopes()))
+}
+
+// validateCustomScopes checks the custom scopes of a login. Client credential flows can only request the
+// application's static permissions, so their scopes must end in "/.default".
+func validateCustomScopes(scopes []string, clientCredentials bool) error {
+	for _, scope := range scopes {
+		if strings.TrimSpace(scope) != scope || !strings.Contains(scope, "/") {
+			return fmt.Errorf("invalid token scope %q", scope)
+		}
+		if clientCredentials && !strings.HasSuffix(scope, "/.default") {
+			return fmt.Errorf("invalid token scope %q, scopes for service principal, workload identity and managed identity logins must end in /.default", scope)
+		}
+	}
+	return nil
+}
+
+// validateStorageAudience checks an override of the storage audience (see OAuthTokenInfo.StorageAudience), which must be
+// an absolute URL ending in "/.default", like StorageScope. An empty audience keeps the standard one.
+func validateStorageAudience(audience string) error {
+	if audience == "" {
+		return nil
+	}
+
+	u, err := url.Parse(audience)
+	if err != nil || !u.IsAbs() || u.Host == "" || u.RawQuery != "" || u.Fragment != "" ||
+		strings.TrimSpace(audience) != audience || !strings.HasSuffix(u.Path, "/.default") {
+		return fmt.Errorf("invalid storage audience %q, it must be an absolute URL ending in /.default, e.g. %s", audience, StorageScope)
+	}
+	return nil
+}
+
+// usesClientCredentials returns whether this login authenticates as an application rather than a user.
+func (credInfo *OAuthTokenInfo) usesClientCredentials() bool {
+	return credInfo.ServicePrincipalName || credInfo.Identity || credInfo.WorkloadIdentity
+}
+
+// storageScopes returns the scopes requested for storage access, the custom ones if any were configured.
+func (credInfo *OAuthTokenInfo) storageScopes(c AzureCloud) []string {
+	if storage, _ := splitCustomScopes(credInfo.CustomScopes); len(storage) != 0 {
+		return storage
+	}
+	return []string{c.StorageScope}
+}
+
+// storageResource returns the resource requested for storage access by device code logins.
+func (credInfo *OAuthTokenInfo) storageResource() string {
+	if storage, _ := splitCustomScopes(credInfo.CustomScopes); len(storage) != 0 {
+		return strings.TrimSuffix(storage[0], "/.default")
+	}
+	if credInfo.StorageAudience != "" {
+		return strings.TrimSuffix(credInfo.StorageAudience, "/.default")
+	}
+	return Resource
+}
+
+// splitCustomScopes separates custom storage scopes from managed disk ones.
+func splitCustomScopes(scopes []string) (storage, disk []string) {
+	for _, scope := range scopes {
+		if strings.HasSuffix(scope, managedDiskScopeSuffix) {
+			disk = append(disk, scope)
+		} else {
+			storage = append(storage, scope)
+		}
+	}
+	return storage, disk
+}
+
+// customScopesCredential requests tokens for custom scopes in place of the standard storage and managed disk audiences.
+type customScopesCredential struct {
+	cred         azcore.TokenCredential
+	storageScope string
+	storage      []string
+	disk         []string
+}
+
+// newCustomScopesCredential maps requests for the storage audience onto the custom storage scopes, or else onto c's StorageScope,
+// where that's been overridden (see OAuthTokenInfo.StorageAudience).
+func newCustomScopesCredential(cred azcore.TokenCredential, c AzureCloud, scopes []string) *customScopesCredential {
+	storage, disk := splitCustomScopes(scopes)
+	if len(storage) == 0 && c.StorageScope != StorageScope {
+		storage = []string{c.StorageScope}
+	}
+	return &customScopesCredential{cred: cred, storageScope: c.StorageScope, storage: storage, disk: disk}
+}
+
+func (c *customScopesCredential) GetToken(ctx context.Context, options policy.TokenRequestOptions) (azcore.AccessToken, error) {
+	if len(options.Scopes) == 1 {
+		switch scope := options.Scopes[0]; {
+		case (scope == c.storageScope || scope == StorageScope) && len(c.storage) != 0:
+			options.Scopes = c.storage
+		case scope == ManagedDiskScope && len(c.disk) != 0:
+			options.Scopes = c.disk
+		}
+	}
+	return c.cred.GetToken(ctx, options)
+}
+
+// unwrapTokenCredential returns the credential underneath the background refresher and custom scopes, if any.
+func unwrapTokenCredential(cred azcore.TokenCredential) azcore.TokenCredential {
+	if refresher, ok := cred.(*backgroundRefreshCredential); ok {
+		cred = refresher.cred
+	}
+	if scoped, ok := cred.(*customScopesCredential); ok {
+		cred = scoped.cred
+	}
+	return cred
+}
@@ -0,0 +1,51 @@
+// Copyright © 2017 Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package common
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ParseAdditionalTenants splits a comma or space separated list of tenant IDs, as given to --allowed-tenants or AZCOPY_ADDITIONALLY_ALLOWED_TENANTS.
+func ParseAdditionalTenants(value string) []string {
+	return strings.FieldsFunc(value, func(r rune) bool {
+		return r == ',' || r == ' '
+	})
+}
+
+// resolveAdditionalTenants picks the additional tenants to log in with: the ones specified, or else those in AZCOPY_ADDITIONALLY_ALLOWED_TENANTS.
+func resolveAdditionalTenants(tenants []string) []string {
+	if len(tenants) != 0 {
+		return tenants
+	}
+	return ParseAdditionalTenants(lcm.GetEnvironmentVariable(EEnvironmentVariable.AdditionallyAllowedTenants()))
+}
+
+// validateAdditionalTenants checks that each additional tenant is a tenant ID, or "*" to allow any tenant.
+func validateAdditionalTenants(tenants []string) error {
+	for _, tenant := range tenants {
+		if tenant != "*" && !validTenantID(tenant) {
+			return fmt.Errorf("invalid additional tenant %q", tenant)
+		}
+	}
+	return nil
+}
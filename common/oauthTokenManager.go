@@ -22,14 +22,16 @@ package common
 
 import (
 	"context"
+	"crypto"
+	"crypto/x509"
 	"encoding/json"
 	"errors"
 	"fmt"
-	"net"
 	"net/http"
 	"net/url"
 	"os"
 	"path/filepath"
+	"runtime"
 	"strconv"
 	"strings"
 	"sync"
@@ -61,51 +63,312 @@ const DefaultActiveDirectoryEndpoint = "https://login.microsoftonline.com"
 // UserOAuthTokenManager for token management.
 type UserOAuthTokenManager struct {
 	oauthClient *http.Client
-	credCache   *CredCache
+	// credCache keeps persisted logins in the secure store, so that later processes sign in silently, e.g. with a
+	// device code login's refresh token. AZCOPY_DEVICE_CODE_CACHE names a separate entry, see loginCacheOptions.
+	credCache *CredCache
 
 	// Stash the credential info as we delete the environment variable after reading it, and we need to get it multiple times.
 	stashedInfo *OAuthTokenInfo
+
+	// customScopes replaces the storage (and managed disk) audience of new logins, see SetCustomScopes.
+	customScopes []string
+	// additionalTenants lets new logins request tokens for tenants other than their own, see SetAdditionalTenants.
+	additionalTenants []string
+
+	// roleLogins holds the logins set for a given role, e.g. a managed identity for the source while the destination
+	// uses a service principal, see SetTokenInfoForRole. They're kept apart from the login of this process.
+	// roleTenants overrides the tenant used for a given role, e.g. a source account living in another tenant.
+	// roleInfos caches the token info derived for each (tenant, role), so each side keeps a single credential.
+	roleLock    sync.Mutex
+	roleLogins  map[CredentialRole]*OAuthTokenInfo
+	roleTenants map[CredentialRole]string
+	roleInfos   map[roleCredentialKey]*OAuthTokenInfo
+
+	// scopeInfos caches the token info issued for each set of scopes, see GetTokenInfoForScopes.
+	scopeLock  sync.Mutex
+	scopeInfos map[string]*OAuthTokenInfo
+
+	// refreshCtx bounds the background refreshes of the token infos handed out, see EnableBackgroundRefresh.
+	refreshCtx context.Context
+}
+
+type roleCredentialKey struct {
+	tenant string
+	role   CredentialRole
 }
 
 // NewUserOAuthTokenManagerInstance creates a token manager instance.
 func NewUserOAuthTokenManagerInstance(credCacheOptions CredCacheOptions) *UserOAuthTokenManager {
 	return &UserOAuthTokenManager{
 		oauthClient: newAzcopyHTTPClient(),
-		credCache:   NewCredCache(credCacheOptions),
+		credCache:   NewCredCache(loginCacheOptions(credCacheOptions)),
+	}
+}
+
+// SetTokenInfo makes GetTokenInfo return tokenInfo, without consulting the environment or the credential cache.
+// It's meant for programs embedding AzCopy, along with NewOAuthTokenInfoFromCredential.
+func (uotm *UserOAuthTokenManager) SetTokenInfo(tokenInfo *OAuthTokenInfo) {
+	uotm.replaceTokenInfo(tokenInfo)
+}
+
+// EnableBackgroundRefresh makes the token infos handed out by this manager from now on refresh their tokens in the
+// background, see OAuthTokenInfo.StartBackgroundRefresh. Cancelling ctx stops them.
+func (uotm *UserOAuthTokenManager) EnableBackgroundRefresh(ctx context.Context) {
+	uotm.refreshCtx = ctx
+}
+
+// startBackgroundRefresh starts refreshing tokenInfo in the background if EnableBackgroundRefresh was called.
+func (uotm *UserOAuthTokenManager) startBackgroundRefresh(tokenInfo *OAuthTokenInfo) error {
+	if uotm.refreshCtx == nil {
+		return nil
 	}
+	return tokenInfo.StartBackgroundRefresh(uotm.refreshCtx)
 }
 
+// StopBackgroundRefresh stops refreshing the tokens of every token info handed out by this manager in the background.
+// Programs embedding AzCopy should call it before discarding the manager. The token infos still fetch tokens on demand.
+func (uotm *UserOAuthTokenManager) StopBackgroundRefresh() {
+	// The login itself is kept, only the token infos derived from it are forgotten.
+	stashedInfo := uotm.stashedInfo
+	uotm.replaceTokenInfo(nil)
+	uotm.stashedInfo = stashedInfo
+
+	uotm.roleLock.Lock()
+	defer uotm.roleLock.Unlock()
+	for _, info := range uotm.roleLogins {
+		info.StopBackgroundRefresh()
+	}
+}
+
+// replaceTokenInfo makes tokenInfo the login of this process. The token infos derived from the previous login are
+// forgotten, and their background refreshes stopped, unless they share tokenInfo's credential.
+func (uotm *UserOAuthTokenManager) replaceTokenInfo(tokenInfo *OAuthTokenInfo) {
+	discarded := []*OAuthTokenInfo{uotm.stashedInfo}
+	uotm.stashedInfo = tokenInfo
+
+	uotm.roleLock.Lock()
+	for _, info := range uotm.roleInfos {
+		discarded = append(discarded, info)
+	}
+	uotm.roleInfos = nil
+	uotm.roleLock.Unlock()
+
+	uotm.scopeLock.Lock()
+	for _, info := range uotm.scopeInfos {
+		discarded = append(discarded, info)
+	}
+	uotm.scopeInfos = nil
+	uotm.scopeLock.Unlock()
+
+	for _, info := range discarded {
+		if info != nil && (tokenInfo == nil || info.TokenCredential != tokenInfo.TokenCredential) {
+			info.StopBackgroundRefresh()
+		}
+	}
+}
+
+// SetCustomScopes makes subsequent logins request tokens for the given scopes rather than the standard storage audience,
+// e.g. for private endpoints fronted by an internal STS. Scopes ending in "//.default" replace the managed disk audience.
+// When none are set, the scopes listed in AZCOPY_OAUTH_SCOPES are used.
+func (uotm *UserOAuthTokenManager) SetCustomScopes(scopes []string) {
+	uotm.customScopes = scopes
+}
+
+// SetAdditionalTenants lets subsequent logins request tokens for the given tenants besides the login tenant,
+// e.g. when storage access is granted to a service principal through a guest assignment in another tenant.
+// "*" allows any tenant. When none are set, the tenants listed in AZCOPY_ADDITIONALLY_ALLOWED_TENANTS are used.
+func (uotm *UserOAuthTokenManager) SetAdditionalTenants(tenants []string) {
+	uotm.additionalTenants = tenants
+}
+
+// loginCacheOptions derives where logins are cached from the session cache's options. Setting AZCOPY_DEVICE_CODE_CACHE
+// selects a separate entry, e.g. to keep the device code logins of each tenant apart.
+func loginCacheOptions(options CredCacheOptions) CredCacheOptions {
+	if name := lcm.GetEnvironmentVariable(EEnvironmentVariable.DeviceCodeCache()); name != "" {
+		options.KeyName += "-" + name
+		options.AccountName += "-" + name
+	}
+	return options
+}
+
+// newAzcopyHTTPClient creates the client used for token requests. It dials with the request's context, so that
+// cancelling a login aborts connections still being established.
 func newAzcopyHTTPClient() *http.Client {
+	options := defaultTokenHTTPClientOptions.WithEnvironment()
+	tlsConfig, err := authTLSConfigFromEnvironment()
+	if err != nil {
+		// Fail the token requests, rather than connecting without the TLS settings the user asked for.
+		return &http.Client{Transport: errorTransport{err: err}}
+	}
+	options.TLSClientConfig = tlsConfig
+	options.Proxy = authProxyLookup(GlobalProxyLookup)
 	return &http.Client{
-		Transport: &http.Transport{
-			Proxy: GlobalProxyLookup,
-			// We use Dial instead of DialContext as DialContext has been reported to cause slower performance.
-			Dial /*Context*/ : (&net.Dialer{
-				Timeout:   10 * time.Second,
-				KeepAlive: 10 * time.Second,
-				DualStack: true,
-			}).Dial, /*Context*/
-			MaxIdleConns:           0, // No limit
-			MaxIdleConnsPerHost:    1000,
-			IdleConnTimeout:        180 * time.Second,
-			TLSHandshakeTimeout:    10 * time.Second,
-			ExpectContinueTimeout:  1 * time.Second,
-			DisableKeepAlives:      false,
-			DisableCompression:     true,
-			MaxResponseHeaderBytes: 0,
-			// ResponseHeaderTimeout:  time.Duration{},
-			// ExpectContinueTimeout:  time.Duration{},
-		},
+		Transport: options.NewTransport(options.Dialer().DialContext),
 	}
 }
 
+// SetTokenInfoForRole makes GetTokenInfoForRole return tokenInfo for role, rather than a token info derived from the
+// login of this process. It lets each side of a transfer log in its own way, e.g. the source with a managed identity
+// in one tenant, and the destination with a service principal in another. A nil tokenInfo removes the login of role.
+func (uotm *UserOAuthTokenManager) SetTokenInfoForRole(role CredentialRole, tokenInfo *OAuthTokenInfo) {
+	uotm.roleLock.Lock()
+	defer uotm.roleLock.Unlock()
+
+	if previous, ok := uotm.roleLogins[role]; ok && previous != tokenInfo {
+		previous.StopBackgroundRefresh()
+	}
+	if tokenInfo == nil {
+		delete(uotm.roleLogins, role)
+		return
+	}
+	if uotm.roleLogins == nil {
+		uotm.roleLogins = make(map[CredentialRole]*OAuthTokenInfo)
+	}
+	uotm.roleLogins[role] = tokenInfo
+}
+
+// SetTenantForRole makes credentials requested for role authenticate against tenantID instead of the logged-in tenant.
+func (uotm *UserOAuthTokenManager) SetTenantForRole(role CredentialRole, tenantID string) {
+	uotm.roleLock.Lock()
+	defer uotm.roleLock.Unlock()
+
+	if uotm.roleTenants == nil {
+		uotm.roleTenants = make(map[CredentialRole]string)
+	}
+	uotm.roleTenants[role] = tenantID
+}
+
 // GetTokenInfo gets token info, it follows rule:
 //  1. If there is token passed from environment variable(note this is only for testing purpose),
 //     use token passed from environment variable.
-//  2. Otherwise, try to get token from cache.
+//  2. Otherwise, try to get token from cache, silently refreshing a persisted device code login.
+//
+// The token it holds is for storage, use GetDiskTokenInfo for managed disks, or GetTokenInfoForScopes for other audiences.
+// Use GetTokenInfoForRole where the source and destination may authenticate against different tenants.
 //
-// This method either successfully return token, or return error.
+// This method either successfully return token, or return error. Failures are TokenInfoErrors, whose kind
+// (e.g. ErrNoCachedToken or ErrRefreshFailed) can be checked with errors.Is.
 func (uotm *UserOAuthTokenManager) GetTokenInfo(ctx context.Context) (*OAuthTokenInfo, error) {
+	return uotm.GetTokenInfoForRole(ctx, ECredentialRole.Default())
+}
+
+// GetTokenInfoForRole gets token info for role like GetTokenInfo. A login set for role through SetTokenInfoForRole is
+// used as is. Otherwise, if a different tenant was configured for role through SetTenantForRole, it derives a separate
+// token info for that tenant from the login of this process.
+func (uotm *UserOAuthTokenManager) GetTokenInfoForRole(ctx context.Context, role CredentialRole) (*OAuthTokenInfo, error) {
+	uotm.roleLock.Lock()
+	roleLogin := uotm.roleLogins[role]
+	uotm.roleLock.Unlock()
+	if roleLogin != nil {
+		if err := uotm.startBackgroundRefresh(roleLogin); err != nil {
+			return nil, err
+		}
+		return roleLogin, nil
+	}
+
+	tokenInfo, err := uotm.getDefaultTokenInfo(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := uotm.startBackgroundRefresh(tokenInfo); err != nil {
+		return nil, err
+	}
+
+	uotm.roleLock.Lock()
+	defer uotm.roleLock.Unlock()
+
+	tenant, ok := uotm.roleTenants[role]
+	if !ok || tenant == "" || tenant == tokenInfo.Tenant {
+		return tokenInfo, nil
+	}
+
+	key := roleCredentialKey{tenant: tenant, role: role}
+	if info, ok := uotm.roleInfos[key]; ok {
+		return info, nil
+	}
+
+	if tokenInfo.Identity || tokenInfo.TokenRefreshSource != "" {
+		return nil, fmt.Errorf("a separate tenant (%s) cannot be derived for the %s from this login type, give the %s a login of its own instead",
+			tenant, strings.ToLower(role.String()), strings.ToLower(role.String()))
+	}
+
+	// Copy the login, but start from a fresh credential so that it authenticates against the other tenant.
+	// Any access token we hold was issued by the logged-in tenant, so only the refresh token is carried over.
+	roleInfo := *tokenInfo
+	roleInfo.TokenCredential = nil
+	roleInfo.Tenant = tenant
+	roleInfo.Token = adal.Token{RefreshToken: tokenInfo.RefreshToken, Resource: tokenInfo.Resource, Type: tokenInfo.Type}
+	if _, err := roleInfo.getTokenCredential(ctx); err != nil {
+		return nil, err
+	}
+	if err := uotm.startBackgroundRefresh(&roleInfo); err != nil {
+		return nil, err
+	}
+
+	if uotm.roleInfos == nil {
+		uotm.roleInfos = make(map[roleCredentialKey]*OAuthTokenInfo)
+	}
+	uotm.roleInfos[key] = &roleInfo
+	return &roleInfo, nil
+}
+
+// GetTokenInfoForScopes gets token info holding an access token for scopes, e.g. []string{ManagedDiskScope} for managed disk copies.
+// Tokens are cached per set of scopes, so fetching a token for one audience doesn't evict those of others.
+func (uotm *UserOAuthTokenManager) GetTokenInfoForScopes(ctx context.Context, scopes []string) (*OAuthTokenInfo, error) {
+	if len(scopes) == 0 {
+		return nil, errors.New("at least one token scope is required")
+	}
+
+	tokenInfo, err := uotm.getDefaultTokenInfo(ctx)
+	if err != nil {
+		return nil, err
+	}
+	// The scoped infos share the login's credential, so refreshing it in the background refreshes theirs.
+	if err := uotm.startBackgroundRefresh(tokenInfo); err != nil {
+		return nil, err
+	}
+
+	uotm.scopeLock.Lock()
+	defer uotm.scopeLock.Unlock()
+
+	key := strings.Join(scopes, " ")
+	if info, ok := uotm.scopeInfos[key]; ok && !info.WillExpireIn(minimumTokenValidDuration) {
+		return info, nil
+	}
+
+	tc, err := tokenInfo.getTokenCredential(ctx)
+	if err != nil {
+		return nil, err
+	}
+	t, err := tokenInfo.requestToken(ctx, tc, policy.TokenRequestOptions{Scopes: scopes})
+	if err != nil {
+		return nil, err
+	}
+
+	// The scoped info shares the login's credential, only its access token is specific to scopes.
+	scopeInfo := *tokenInfo
+	scopeInfo.Token = adal.Token{
+		AccessToken:  t.Token,
+		RefreshToken: tokenInfo.RefreshToken,
+		ExpiresOn:    json.Number(strconv.FormatInt(int64(t.ExpiresOn.Sub(date.UnixEpoch())/time.Second), 10)),
+		Resource:     strings.TrimSuffix(scopes[0], "/.default"),
+		Type:         "Bearer",
+	}
+
+	if uotm.scopeInfos == nil {
+		uotm.scopeInfos = make(map[string]*OAuthTokenInfo)
+	}
+	uotm.scopeInfos[key] = &scopeInfo
+	return &scopeInfo, nil
+}
+
+// GetDiskTokenInfo gets token info holding an access token for managed disks (ManagedDiskScope), from the same login as GetTokenInfo.
+// The login need only have been validated against storage, the disk token is requested when first needed.
+func (uotm *UserOAuthTokenManager) GetDiskTokenInfo(ctx context.Context) (*OAuthTokenInfo, error) {
+	return uotm.GetTokenInfoForScopes(ctx, []string{ManagedDiskScope})
+}
+
+func (uotm *UserOAuthTokenManager) getDefaultTokenInfo(ctx context.Context) (*OAuthTokenInfo, error) {
 	if uotm.stashedInfo != nil {
 		return uotm.stashedInfo, nil
 	}
@@ -125,7 +388,7 @@ func (uotm *UserOAuthTokenManager) GetTokenInfo(ctx context.Context) (*OAuthToke
 	}
 
 	if tokenInfo == nil || tokenInfo.IsEmpty() {
-		return nil, errors.New("invalid state, cannot get valid token info")
+		return nil, newTokenInfoError(ErrInvalidTokenInfo, nil, "invalid state, cannot get valid token info")
 	}
 
 	uotm.stashedInfo = tokenInfo
@@ -135,22 +398,47 @@ func (uotm *UserOAuthTokenManager) GetTokenInfo(ctx context.Context) (*OAuthToke
 
 func (uotm *UserOAuthTokenManager) validateAndPersistLogin(oAuthTokenInfo *OAuthTokenInfo, persist bool) error {
 	// Use default tenant ID and active directory endpoint, if nothing specified.
-	if oAuthTokenInfo.Tenant == "" {
-		oAuthTokenInfo.Tenant = DefaultTenantID
+	if oAuthTokenInfo.ActiveDirectoryEndpoint == "" && oAuthTokenInfo.Cloud != "" {
+		c, err := ResolveAzureCloud(oAuthTokenInfo.Cloud)
+		if err != nil {
+			return err
+		}
+		oAuthTokenInfo.ActiveDirectoryEndpoint = strings.TrimSuffix(c.Configuration.ActiveDirectoryAuthorityHost, "/")
+	}
+	tenant, endpoint, err := resolveTenantAndEndpoint(oAuthTokenInfo.Tenant, oAuthTokenInfo.ActiveDirectoryEndpoint)
+	if err != nil {
+		return err
+	}
+	oAuthTokenInfo.Tenant, oAuthTokenInfo.ActiveDirectoryEndpoint = tenant, endpoint
+	if len(oAuthTokenInfo.CustomScopes) == 0 {
+		oAuthTokenInfo.CustomScopes = resolveCustomScopes(uotm.customScopes)
+	}
+	if err := validateCustomScopes(oAuthTokenInfo.CustomScopes, oAuthTokenInfo.usesClientCredentials()); err != nil {
+		return err
+	}
+	if err := validateStorageAudience(oAuthTokenInfo.StorageAudience); err != nil {
+		return err
+	}
+	if len(oAuthTokenInfo.AdditionalTenants) == 0 {
+		oAuthTokenInfo.AdditionalTenants = resolveAdditionalTenants(uotm.additionalTenants)
+	}
+	if err := validateAdditionalTenants(oAuthTokenInfo.AdditionalTenants); err != nil {
+		return err
 	}
-	if oAuthTokenInfo.ActiveDirectoryEndpoint == "" {
-		oAuthTokenInfo.ActiveDirectoryEndpoint = DefaultActiveDirectoryEndpoint
+	c, err := oAuthTokenInfo.ResolveCloud()
+	if err != nil {
+		return err
 	}
 	tc, err := oAuthTokenInfo.GetTokenCredential()
 	if err != nil {
 		return err
 	}
-	scopes := []string{StorageScope}
-	_, err = tc.GetToken(context.TODO(), policy.TokenRequestOptions{Scopes: scopes})
+	scopes := oAuthTokenInfo.storageScopes(c)
+	_, err = oAuthTokenInfo.requestToken(context.Background(), tc, policy.TokenRequestOptions{Scopes: scopes})
 	if err != nil {
 		return err
 	}
-	uotm.stashedInfo = oAuthTokenInfo
+	uotm.replaceTokenInfo(oAuthTokenInfo)
 
 	if persist && err == nil {
 		err = uotm.credCache.SaveToken(*oAuthTokenInfo)
@@ -172,14 +460,63 @@ func (uotm *UserOAuthTokenManager) AzCliLogin(tenantID string) error {
 	return uotm.validateAndPersistLogin(oAuthTokenInfo, false)
 }
 
+// AzdLogin logs in through the Azure Developer CLI, which must already be signed in with "azd auth login".
+func (uotm *UserOAuthTokenManager) AzdLogin(tenantID string) error {
+	oAuthTokenInfo := &OAuthTokenInfo{
+		AzdCred: true,
+		Tenant:  tenantID,
+	}
+
+	// As with AzCLI, azd keeps its own credentials, so there is nothing to persist.
+	return uotm.validateAndPersistLogin(oAuthTokenInfo, false)
+}
+
+// PSContextToken logs in through the Azure PowerShell context, which must already be signed in with Connect-AzAccount.
+// A token for StorageScope is acquired up front, so a missing or expired context is reported here rather than mid-job.
 func (uotm *UserOAuthTokenManager) PSContextToken(tenantID string) error {
-	oAuthTokenInfo := &OAuthTokenInfo {
+	oAuthTokenInfo := &OAuthTokenInfo{
 		PSCred: true,
 		Tenant: tenantID,
 	}
 
+	// As with AzCLI, PowerShell keeps its own credentials, so there is nothing to persist.
+	if err := uotm.validateAndPersistLogin(oAuthTokenInfo, false); err != nil {
+		connect := "Connect-AzAccount"
+		if tenantID != "" {
+			connect += " -Tenant " + tenantID
+		}
+		return fmt.Errorf("failed to get a token for %s from the Azure PowerShell context; make sure the Az module is installed and run %q: %w", StorageScope, connect, err)
+	}
+
+	return nil
+}
+
+// WorkloadIdentityLogin logs in with Azure Workload Identity (e.g. an AKS pod with a federated service account token).
+// The token file, client ID and tenant ID are read from AZURE_FEDERATED_TOKEN_FILE, AZURE_CLIENT_ID and AZURE_TENANT_ID.
+func (uotm *UserOAuthTokenManager) WorkloadIdentityLogin(persist bool) error {
+	oAuthTokenInfo := &OAuthTokenInfo{
+		WorkloadIdentity: true,
+		Tenant:           lcm.GetEnvironmentVariable(EEnvironmentVariable.AzureTenantID()),
+		ApplicationID:    lcm.GetEnvironmentVariable(EEnvironmentVariable.AzureClientID()),
+		SPNInfo: SPNInfo{
+			FederatedTokenFile: lcm.GetEnvironmentVariable(EEnvironmentVariable.AzureFederatedTokenFile()),
+		},
+	}
+
+	return uotm.validateAndPersistLogin(oAuthTokenInfo, persist)
+}
+
+// DefaultCredentialChainLogin logs in via azidentity's DefaultAzureCredential chain (environment, workload identity, MSI, Azure CLI, ...).
+func (uotm *UserOAuthTokenManager) DefaultCredentialChainLogin(tenantID string) error {
+	oAuthTokenInfo := &OAuthTokenInfo{
+		UseDefaultCredentialChain: true,
+		Tenant:                    tenantID,
+	}
+
+	// Every link of the chain sources its own credentials, so there is nothing to persist.
 	return uotm.validateAndPersistLogin(oAuthTokenInfo, false)
 }
+
 // MSILogin tries to get token from MSI, persist indicates whether to cache the token on local disk.
 func (uotm *UserOAuthTokenManager) MSILogin(identityInfo IdentityInfo, persist bool) error {
 	if err := identityInfo.Validate(); err != nil {
@@ -210,14 +547,44 @@ func (uotm *UserOAuthTokenManager) SecretLogin(tenantID, activeDirectoryEndpoint
 	return uotm.validateAndPersistLogin(oAuthTokenInfo, persist)
 }
 
+// AssertionLogin non-interactively logs in as a service principal using a federated client assertion (e.g. a GitHub Actions OIDC token).
+// The assertion is fetched from assertionConfig, i.e. its request URL or file, on every token refresh, as such assertions are short-lived.
+// An assertion Value given as is can't be refreshed, so it can't be persisted either.
+func (uotm *UserOAuthTokenManager) AssertionLogin(tenantID, applicationID string, assertionConfig ClientAssertionConfig, persist bool) error {
+	sources := 0
+	for _, source := range []string{assertionConfig.RequestURL, assertionConfig.File, assertionConfig.Value} {
+		if source != "" {
+			sources++
+		}
+	}
+	if sources != 1 {
+		return errors.New("client assertion login requires exactly one of an assertion request URL, an assertion file or an assertion")
+	}
+	if assertionConfig.Value != "" && persist {
+		return errors.New("a client assertion is short-lived and cannot be persisted, please pass the path of a file holding it instead")
+	}
+	if assertionConfig.File != "" {
+		assertionConfig.File, _ = filepath.Abs(assertionConfig.File)
+	}
+
+	oAuthTokenInfo := &OAuthTokenInfo{
+		ServicePrincipalName: true,
+		Tenant:               tenantID,
+		ApplicationID:        applicationID,
+		SPNInfo: SPNInfo{
+			Assertion: assertionConfig,
+		},
+	}
+
+	return uotm.validateAndPersistLogin(oAuthTokenInfo, persist)
+}
+
 // CertLogin non-interactively logs in using a specified certificate, certificate password, and activedirectory endpoint.
-func (uotm *UserOAuthTokenManager) CertLogin(tenantID, activeDirectoryEndpoint, certPath, certPass, applicationID string, persist bool) error {
+func (uotm *UserOAuthTokenManager) CertLogin(tenantID, activeDirectoryEndpoint, certPath, certPass, applicationID string, sendCertChain, persist bool) error {
 	// Use default tenant ID and active directory endpoint, if nothing specified.
-	if tenantID == "" {
-		tenantID = DefaultTenantID
-	}
-	if activeDirectoryEndpoint == "" {
-		activeDirectoryEndpoint = DefaultActiveDirectoryEndpoint
+	tenantID, activeDirectoryEndpoint, err := resolveTenantAndEndpoint(tenantID, activeDirectoryEndpoint)
+	if err != nil {
+		return err
 	}
 	absCertPath, _ := filepath.Abs(certPath)
 	oAuthTokenInfo := &OAuthTokenInfo{
@@ -226,8 +593,39 @@ func (uotm *UserOAuthTokenManager) CertLogin(tenantID, activeDirectoryEndpoint,
 		ActiveDirectoryEndpoint: activeDirectoryEndpoint,
 		ApplicationID:           applicationID,
 		SPNInfo: SPNInfo{
-			Secret:   certPass,
-			CertPath: absCertPath,
+			Secret:        certPass,
+			CertPath:      absCertPath,
+			SendCertChain: sendCertChain,
+		},
+	}
+
+	return uotm.validateAndPersistLogin(oAuthTokenInfo, persist)
+}
+
+// KeyVaultCertLogin logs in as a service principal with a certificate kept in Azure Key Vault, which is downloaded
+// using the bootstrap login (e.g. a managed identity or the Azure CLI) and never written to disk.
+func (uotm *UserOAuthTokenManager) KeyVaultCertLogin(tenantID, applicationID, vaultURL, certName string, bootstrap *OAuthTokenInfo, persist bool) error {
+	if vaultURL == "" || certName == "" {
+		return errors.New("both the Key Vault URL and the certificate name are required")
+	}
+	if bootstrap == nil || bootstrap.SPNInfo.KeyVaultCert.VaultURL != "" {
+		return errors.New("a bootstrap login other than a Key Vault certificate is required")
+	}
+	tenantID, activeDirectoryEndpoint, err := resolveTenantAndEndpoint(tenantID, "")
+	if err != nil {
+		return err
+	}
+	oAuthTokenInfo := &OAuthTokenInfo{
+		ServicePrincipalName:    true,
+		Tenant:                  tenantID,
+		ActiveDirectoryEndpoint: activeDirectoryEndpoint,
+		ApplicationID:           applicationID,
+		SPNInfo: SPNInfo{
+			KeyVaultCert: KeyVaultCertificate{
+				VaultURL:  vaultURL,
+				Name:      certName,
+				Bootstrap: bootstrap,
+			},
 		},
 	}
 
@@ -238,11 +636,14 @@ func (uotm *UserOAuthTokenManager) CertLogin(tenantID, activeDirectoryEndpoint,
 // cache the token on local disk.
 func (uotm *UserOAuthTokenManager) UserLogin(tenantID, activeDirectoryEndpoint string, persist bool) error {
 	// Use default tenant ID and active directory endpoint, if nothing specified.
-	if tenantID == "" {
-		tenantID = DefaultTenantID
+	tenantID, activeDirectoryEndpoint, err := resolveTenantAndEndpoint(tenantID, activeDirectoryEndpoint)
+	if err != nil {
+		return err
 	}
-	if activeDirectoryEndpoint == "" {
-		activeDirectoryEndpoint = DefaultActiveDirectoryEndpoint
+
+	customScopes := resolveCustomScopes(uotm.customScopes)
+	if err := validateCustomScopes(customScopes, false); err != nil {
+		return err
 	}
 
 	// Init OAuth config
@@ -256,7 +657,7 @@ func (uotm *UserOAuthTokenManager) UserLogin(tenantID, activeDirectoryEndpoint s
 		uotm.oauthClient,
 		*oauthConfig,
 		ApplicationID,
-		Resource)
+		(&OAuthTokenInfo{CustomScopes: customScopes}).storageResource())
 	if err != nil {
 		return fmt.Errorf("failed to login with tenantID %q, Azure directory endpoint %q, %v",
 			tenantID, activeDirectoryEndpoint, err)
@@ -283,12 +684,12 @@ func (uotm *UserOAuthTokenManager) UserLogin(tenantID, activeDirectoryEndpoint s
 		Tenant:                  tenantID,
 		ActiveDirectoryEndpoint: activeDirectoryEndpoint,
 		ApplicationID:           ApplicationID,
+		CustomScopes:            customScopes,
 	}
-	uotm.stashedInfo = &oAuthTokenInfo
+	uotm.replaceTokenInfo(&oAuthTokenInfo)
 
-	// to dump for diagnostic purposes:
-	// buf, _ := json.Marshal(oAuthTokenInfo)
-	// panic("don't check me in. Buf is " + string(buf))
+	// to dump for diagnostic purposes (never dump the token info itself, its tokens would end up in the output):
+	// lcm.Info("Device code login is " + oAuthTokenInfo.String())
 
 	if persist {
 		err = uotm.credCache.SaveToken(oAuthTokenInfo)
@@ -300,34 +701,158 @@ func (uotm *UserOAuthTokenManager) UserLogin(tenantID, activeDirectoryEndpoint s
 	return nil
 }
 
+// RestoreDeviceCodeLogin signs in with the device code login persisted by an earlier process, e.g. to resume a job after a
+// restart. A still valid access token is used as is, so this needs no interaction. Once it has expired, the refresh
+// token is redeemed, and only if that's gone as well does the user have to log in again.
+func (uotm *UserOAuthTokenManager) RestoreDeviceCodeLogin(ctx context.Context) error {
+	tokenInfo, err := uotm.loadCachedTokenInfo()
+	if err != nil {
+		return err
+	}
+	if tokenInfo.CredentialKind() != "DeviceCode" {
+		return newTokenInfoError(ErrNoCachedToken, nil, "no cached device code login found, please log in with azcopy's login command")
+	}
+	if tokenInfo.WillExpireIn(minimumTokenValidDuration) && tokenInfo.RefreshToken == "" {
+		return newTokenInfoError(ErrRefreshFailed, nil, "the cached device code login has expired, please log in with azcopy's login command again")
+	}
+
+	if tokenInfo, err = uotm.refreshCachedTokenInfo(ctx, tokenInfo); err != nil {
+		return err
+	}
+	uotm.replaceTokenInfo(tokenInfo)
+	return nil
+}
+
+// InteractiveLogin logs in a user with the flow selected by loginType. With ELoginType.Interactive(), the browser is used
+// unless none is available (e.g. a headless SSH session), in which case this falls back to the device code flow.
+func (uotm *UserOAuthTokenManager) InteractiveLogin(tenantID, activeDirectoryEndpoint string, loginType LoginType, redirectURL string, persist bool) error {
+	switch loginType {
+	case ELoginType.Interactive():
+		if !browserAvailable() {
+			lcm.Info("No browser or display is available, falling back to device code login.")
+			return uotm.UserLogin(tenantID, activeDirectoryEndpoint, persist)
+		}
+		return uotm.BrowserLogin(tenantID, activeDirectoryEndpoint, redirectURL, persist)
+	case ELoginType.Browser():
+		return uotm.BrowserLogin(tenantID, activeDirectoryEndpoint, redirectURL, persist)
+	default:
+		return uotm.UserLogin(tenantID, activeDirectoryEndpoint, persist)
+	}
+}
+
+// BrowserLogin interactively logs in through the system browser with specified tenantID and activeDirectoryEndpoint.
+// redirectURL is where AAD redirects to, and must be a localhost URL registered for AzCopy's application;
+// when empty, the credential listens on a port of its choosing.
+func (uotm *UserOAuthTokenManager) BrowserLogin(tenantID, activeDirectoryEndpoint, redirectURL string, persist bool) error {
+	if err := validateBrowserRedirectURL(redirectURL); err != nil {
+		return err
+	}
+	tenantID, activeDirectoryEndpoint, err := resolveTenantAndEndpoint(tenantID, activeDirectoryEndpoint)
+	if err != nil {
+		return err
+	}
+
+	oAuthTokenInfo := &OAuthTokenInfo{
+		InteractiveBrowserCred:  true,
+		Tenant:                  tenantID,
+		ActiveDirectoryEndpoint: activeDirectoryEndpoint,
+		ApplicationID:           ApplicationID,
+		BrowserRedirectURL:      redirectURL,
+	}
+
+	if err := uotm.validateAndPersistLogin(oAuthTokenInfo, persist); err != nil {
+		return fmt.Errorf("failed to login with tenantID %q, Azure directory endpoint %q, %v",
+			oAuthTokenInfo.Tenant, oAuthTokenInfo.ActiveDirectoryEndpoint, err)
+	}
+
+	return nil
+}
+
+// validateBrowserRedirectURL checks that the redirect is one the credential can listen on, i.e. a plain localhost URL.
+func validateBrowserRedirectURL(redirectURL string) error {
+	if redirectURL == "" {
+		return nil
+	}
+	u, err := url.Parse(redirectURL)
+	if err != nil || u.Scheme != "http" || (u.Hostname() != "localhost" && u.Hostname() != "127.0.0.1") {
+		return fmt.Errorf("redirect URI %q must be an http://localhost URL", redirectURL)
+	}
+	return nil
+}
+
+// browserAvailable reports whether an interactive browser can plausibly be launched.
+// Windows and macOS always have one; on other platforms a display is required, and SSH sessions are treated as headless.
+func browserAvailable() bool {
+	switch runtime.GOOS {
+	case "windows", "darwin":
+		return true
+	}
+
+	if lcm.GetEnvironmentVariable(EnvironmentVariable{Name: "SSH_CONNECTION"}) != "" {
+		return false
+	}
+
+	return lcm.GetEnvironmentVariable(EnvironmentVariable{Name: "DISPLAY"}) != "" ||
+		lcm.GetEnvironmentVariable(EnvironmentVariable{Name: "WAYLAND_DISPLAY"}) != ""
+}
+
 // getCachedTokenInfo get a fresh token from local disk cache.
-// If access token is expired, it will refresh the token.
-// If refresh token is expired, the method will fail and return failure reason.
-// Fresh token is persisted if access token or refresh token is changed.
+// A device code login is refreshed with its refresh token once its access token is about to expire, and the fresh
+// tokens are persisted again. If the refresh token is expired, the method will fail and return failure reason.
+// Other logins (e.g. SPN and MSI) don't persist an access token, so a fresh one is requested from their credential
+// each time they're loaded, and kept in memory only. A credential chain login is rebuilt from the link which logged in.
 func (uotm *UserOAuthTokenManager) getCachedTokenInfo(ctx context.Context) (*OAuthTokenInfo, error) {
+	tokenInfo, err := uotm.loadCachedTokenInfo()
+	if err != nil {
+		return nil, err
+	}
+	if tokenInfo.CredentialChainLink != "" {
+		if tokenInfo, err = credentialChainLinkLogin(tokenInfo); err != nil {
+			return nil, err
+		}
+	}
+	return uotm.refreshCachedTokenInfo(ctx, tokenInfo)
+}
+
+// loadCachedTokenInfo loads the login from local disk cache as is.
+func (uotm *UserOAuthTokenManager) loadCachedTokenInfo() (*OAuthTokenInfo, error) {
 	hasToken, err := uotm.credCache.HasCachedToken()
 	if err != nil {
-		return nil, fmt.Errorf("no cached token found, please log in with azcopy's login command, %v", err)
+		return nil, newTokenInfoError(ErrNoCachedToken, err, "no cached token found, please log in with azcopy's login command")
 	}
 	if !hasToken {
-		return nil, errors.New("no cached token found, please log in with azcopy's login command")
+		return nil, newTokenInfoError(ErrNoCachedToken, nil, "no cached token found, please log in with azcopy's login command")
 	}
 
 	tokenInfo, err := uotm.credCache.LoadToken()
 	if err != nil {
-		return nil, fmt.Errorf("get cached token failed, %v", err)
+		return nil, newTokenInfoError(ErrInvalidTokenInfo, err, "get cached token failed, the cache may be corrupt, please log in with azcopy's login command again")
+	}
+	if tokenInfo == nil || tokenInfo.IsEmpty() {
+		return nil, newTokenInfoError(ErrInvalidTokenInfo, nil, "get cached token failed, the cached token is empty or partially written, please log in with azcopy's login command again")
+	}
+	return tokenInfo, nil
+}
+
+// refreshCachedTokenInfo refreshes the cached login tokenInfo as getCachedTokenInfo describes.
+func (uotm *UserOAuthTokenManager) refreshCachedTokenInfo(ctx context.Context, tokenInfo *OAuthTokenInfo) (*OAuthTokenInfo, error) {
+	// Only refresh when the access token is about to expire. Logins without a persisted access token always refresh.
+	if !tokenInfo.Token.IsZero() && !tokenInfo.WillExpireIn(minimumTokenValidDuration) {
+		return tokenInfo, nil
 	}
 
 	freshToken, err := tokenInfo.Refresh(ctx)
 	if err != nil {
-		return nil, fmt.Errorf("get cached token failed to ensure token fresh, please log in with azcopy's login command again, %v", err)
+		return nil, newTokenInfoError(ErrRefreshFailed, err, "get cached token failed to ensure token fresh, please log in with azcopy's login command again")
 	}
 
-	// Update token cache, if token is updated.
+	// Update token cache, if token is updated. Only a device code refresh hands back a refresh token to persist.
 	if freshToken.AccessToken != tokenInfo.AccessToken || freshToken.RefreshToken != tokenInfo.RefreshToken {
 		tokenInfo.Token = *freshToken
-		if err := uotm.credCache.SaveToken(*tokenInfo); err != nil {
-			return nil, err
+		if freshToken.RefreshToken != "" {
+			if err := uotm.credCache.SaveToken(*tokenInfo); err != nil {
+				return nil, err
+			}
 		}
 	}
 
@@ -343,9 +868,94 @@ func (uotm *UserOAuthTokenManager) HasCachedToken() (bool, error) {
 	return uotm.credCache.HasCachedToken()
 }
 
-// RemoveCachedToken delete all the cached token.
+// LoginStatus describes the current login, as reported by GetLoginStatus.
+type LoginStatus struct {
+	LoggedIn bool
+	// Expired is set when a login is cached, but its access token has expired.
+	Expired bool
+	// Refreshable is set when the access token is renewed silently once it expires, e.g. with a device code login's
+	// refresh token. An expired token which is refreshable doesn't need the user to log in again.
+	Refreshable             bool
+	CredentialType          string     `json:",omitempty"`
+	TenantID                string     `json:",omitempty"`
+	ApplicationID           string     `json:",omitempty"`
+	ActiveDirectoryEndpoint string     `json:",omitempty"`
+	ExpiresOn               *time.Time `json:",omitempty"` // Unset for logins which don't persist an access token, e.g. SPN and MSI.
+}
+
+// GetLoginStatus inspects the current login without refreshing it, so it never prompts or reaches out to AAD.
+func (uotm *UserOAuthTokenManager) GetLoginStatus() (LoginStatus, error) {
+	tokenInfo := uotm.stashedInfo
+	if tokenInfo == nil {
+		var err error
+		if tokenInfo, err = peekTokenInfoFromEnvVar(); err != nil {
+			return LoginStatus{}, err
+		}
+	}
+	if tokenInfo == nil {
+		// An error here (e.g. no key in the session keyring) likewise means nothing was ever cached.
+		if hasToken, err := uotm.credCache.HasCachedToken(); err != nil || !hasToken {
+			return LoginStatus{}, nil
+		}
+
+		var err error
+		if tokenInfo, err = uotm.credCache.LoadToken(); err != nil {
+			return LoginStatus{}, fmt.Errorf("get cached token failed, the cache may be corrupt, %v", err)
+		}
+		if tokenInfo == nil || tokenInfo.IsEmpty() {
+			return LoginStatus{}, nil
+		}
+	}
+
+	status := LoginStatus{
+		LoggedIn:                true,
+		CredentialType:          tokenInfo.CredentialKind(),
+		TenantID:                tokenInfo.Tenant,
+		ApplicationID:           tokenInfo.ApplicationID,
+		ActiveDirectoryEndpoint: tokenInfo.ActiveDirectoryEndpoint,
+		// Token store and bearer tokens are handed to us, the others hold no refresh token.
+		Refreshable: tokenInfo.TokenRefreshSource == "" && tokenInfo.RefreshToken != "",
+	}
+	if !tokenInfo.Token.IsZero() {
+		expiresOn := tokenInfo.Expires()
+		status.ExpiresOn = &expiresOn
+		status.Expired = tokenInfo.IsExpired()
+	}
+
+	return status, nil
+}
+
+// RemoveCachedToken deletes the login cached for the current user, and forgets the token info stashed in this process.
+// It returns an error matching ErrNoCachedToken when nothing was cached, or ErrTokenRemovalFailed when deletion failed.
 func (uotm *UserOAuthTokenManager) RemoveCachedToken() error {
-	return uotm.credCache.RemoveCachedToken()
+	uotm.replaceTokenInfo(nil)
+	stashedEnvOAuthTokenExists = false
+
+	if hasToken, err := uotm.credCache.HasCachedToken(); err != nil || !hasToken {
+		return newTokenInfoError(ErrNoCachedToken, err, "no cached token found for current user")
+	}
+	if err := uotm.credCache.RemoveCachedToken(); err != nil {
+		return newTokenInfoError(ErrTokenRemovalFailed, err, "failed to remove cached token")
+	}
+	return nil
+}
+
+// RemoveAllCachedTokens deletes the login cached for the current user like RemoveCachedToken, as well as the tokens
+// left in the token store by integrations for any AzCopy process, i.e. those with the azcopy/aadtoken/ key prefix.
+func (uotm *UserOAuthTokenManager) RemoveAllCachedTokens() error {
+	err := uotm.RemoveCachedToken()
+	if err != nil && !errors.Is(err, ErrNoCachedToken) {
+		return err
+	}
+
+	removed, storeErr := removeTokenStoreTokens()
+	if storeErr != nil {
+		return newTokenInfoError(ErrTokenRemovalFailed, storeErr, "failed to remove token store tokens")
+	}
+	if removed {
+		return nil
+	}
+	return err
 }
 
 // ====================================================================================
@@ -372,18 +982,39 @@ func EnvVarOAuthTokenInfoExists() bool {
 }
 
 // IsErrorEnvVarOAuthTokenInfoNotSet verifies if an error indicates environment variable AZCOPY_OAUTH_TOKEN_INFO is not set.
+// It's kept for compatibility, errors.Is(err, ErrEnvTokenNotSet) is equivalent.
 func IsErrorEnvVarOAuthTokenInfoNotSet(err error) bool {
-	if err != nil && strings.Contains(err.Error(), ErrorCodeEnvVarOAuthTokenInfoNotSet) {
-		return true
+	return errors.Is(err, ErrEnvTokenNotSet)
+}
+
+// peekTokenInfoFromEnvVar reads the token info passed through AZCOPY_OAUTH_TOKEN_INFO, if any. Unlike getTokenInfoFromEnvVar,
+// it neither refreshes the token nor clears the variable. Token store logins report the token the store holds for this process.
+func peekTokenInfoFromEnvVar() (*OAuthTokenInfo, error) {
+	rawToken := lcm.GetEnvironmentVariable(EEnvironmentVariable.OAuthTokenInfo())
+	if rawToken == "" {
+		return nil, nil
 	}
-	return false
+
+	tokenInfo, err := jsonToTokenInfo([]byte(rawToken))
+	if err != nil {
+		return nil, fmt.Errorf("get token from environment variable failed to unmarshal token, %v", err)
+	}
+
+	if tokenInfo.TokenRefreshSource == TokenRefreshSourceTokenStore {
+		if hasToken, err := tokenStoreCredCache.HasCachedToken(); err == nil && hasToken {
+			if stored, err := tokenStoreCredCache.LoadToken(); err == nil {
+				tokenInfo.Token = stored.Token
+			}
+		}
+	}
+	return tokenInfo, nil
 }
 
 // getTokenInfoFromEnvVar gets token info from environment variable.
 func (uotm *UserOAuthTokenManager) getTokenInfoFromEnvVar(ctx context.Context) (*OAuthTokenInfo, error) {
 	rawToken := lcm.GetEnvironmentVariable(EEnvironmentVariable.OAuthTokenInfo())
 	if rawToken == "" {
-		return nil, errors.New(ErrorCodeEnvVarOAuthTokenInfoNotSet)
+		return nil, newTokenInfoError(ErrEnvTokenNotSet, nil, ErrorCodeEnvVarOAuthTokenInfoNotSet)
 	}
 
 	// Remove the env var after successfully fetching once,
@@ -392,13 +1023,14 @@ func (uotm *UserOAuthTokenManager) getTokenInfoFromEnvVar(ctx context.Context) (
 
 	tokenInfo, err := jsonToTokenInfo([]byte(rawToken))
 	if err != nil {
-		return nil, fmt.Errorf("get token from environment variable failed to unmarshal token, %v", err)
+		return nil, newTokenInfoError(ErrInvalidTokenInfo, err, "get token from environment variable failed to unmarshal token")
 	}
 
-	if tokenInfo.TokenRefreshSource != TokenRefreshSourceTokenStore {
+	// Token store and bearer tokens are handed to us as they are, there is nothing to refresh them with.
+	if tokenInfo.TokenRefreshSource == "" {
 		refreshedToken, err := tokenInfo.Refresh(ctx)
 		if err != nil {
-			return nil, fmt.Errorf("get token from environment variable failed to ensure token fresh, %v", err)
+			return nil, newTokenInfoError(ErrRefreshFailed, err, "get token from environment variable failed to ensure token fresh")
 		}
 		tokenInfo.Token = *refreshedToken
 	}
@@ -412,6 +1044,14 @@ func (uotm *UserOAuthTokenManager) getTokenInfoFromEnvVar(ctx context.Context) (
 // Note: This should be only used for internal integrations.
 const TokenRefreshSourceTokenStore = "tokenstore"
 
+// TokenRefreshSourceCredential indicates token info wrapping a credential handed to AzCopy in process, see NewOAuthTokenInfoFromCredential.
+// Such token info can't be persisted, as there is no way to serialize the credential.
+const TokenRefreshSourceCredential = "credential"
+
+// TokenRefreshSourceBearer indicates a raw bearer token with an explicit expiry, e.g. a short-lived token
+// injected by automation from an external broker. AzCopy can't refresh it, so it must outlive the job.
+const TokenRefreshSourceBearer = "bearer"
+
 // OAuthTokenInfo contains info necessary for refresh OAuth credentials.
 type OAuthTokenInfo struct {
 	azcore.TokenCredential `json:"-"`
@@ -426,6 +1066,25 @@ type OAuthTokenInfo struct {
 	SPNInfo                 SPNInfo
 	AzCLICred               bool
 	PSCred					bool
+	AzdCred                 bool `json:"_azd_cred"`
+	WorkloadIdentity        bool `json:"_workload_identity"`
+	InteractiveBrowserCred  bool `json:"_interactive_browser"`
+	// BrowserRedirectURL is the localhost URL the interactive browser login redirects to, chosen by the credential when empty.
+	BrowserRedirectURL string `json:"_browser_redirect_url,omitempty"`
+	// Cloud names the Azure cloud to authenticate against, see ResolveAzureCloud. When empty, the cloud is inferred from ActiveDirectoryEndpoint.
+	Cloud string `json:"_cloud,omitempty"`
+	// CustomScopes replace the standard storage audience when requesting tokens, see UserOAuthTokenManager.SetCustomScopes.
+	CustomScopes []string `json:"_custom_scopes,omitempty"`
+	// StorageAudience overrides the scope storage tokens are requested for, e.g. for a gateway fronting storage which presents
+	// its own audience. It must be an absolute URL ending in "/.default"; when empty, the cloud's StorageScope is used.
+	// Custom storage scopes, if any, take precedence.
+	StorageAudience string `json:"_storage_audience,omitempty"`
+	// AdditionalTenants are the tenants besides Tenant which the credential may issue tokens for, see UserOAuthTokenManager.SetAdditionalTenants.
+	AdditionalTenants []string `json:"_additional_tenants,omitempty"`
+	// UseDefaultCredentialChain falls through the Azure SDK's DefaultAzureCredential chain.
+	UseDefaultCredentialChain bool `json:"_use_default_credential_chain"`
+	// CredentialChainLink names the link of the credential chain which logged in, see CredentialChainLogin.
+	CredentialChainLink string `json:"_credential_chain_link,omitempty"`
 	// Note: ClientID should be only used for internal integrations through env var with refresh token.
 	// It indicates the Application ID assigned to your app when you registered it with Azure AD.
 	// In this case AzCopy refresh token on behalf of caller.
@@ -439,6 +1098,10 @@ type IdentityInfo struct {
 	ClientID string `json:"_identity_client_id"`
 	ObjectID string `json:"_identity_object_id"`
 	MSIResID string `json:"_identity_msi_res_id"`
+	// Endpoint optionally overrides the identity endpoint, e.g. with the IDENTITY_ENDPOINT of an Azure Arc machine.
+	Endpoint string `json:"_identity_endpoint,omitempty"`
+	// ProbeTimeout optionally bounds each request to the identity endpoint, including the first one which probes for it.
+	ProbeTimeout time.Duration `json:"_identity_probe_timeout,omitempty"`
 }
 
 // SPNInfo contains info for authenticating with Service Principal Names
@@ -448,6 +1111,91 @@ type SPNInfo struct {
 	// Thus, the original secret is needed to refresh.
 	Secret   string `json:"_spn_secret"`
 	CertPath string `json:"_spn_cert_path"`
+	// SendCertChain sends the certificate chain along with the certificate, which is required for subject name/issuer (SNI) auth.
+	SendCertChain bool `json:"_spn_send_cert_chain,omitempty"`
+	// FederatedTokenFile is the path to the projected service account token used by workload identity.
+	// The file is re-read on every token refresh, as the token is rotated by the cluster.
+	FederatedTokenFile string `json:"_spn_federated_token_file,omitempty"`
+	// Assertion describes where to fetch a federated client assertion from, in place of a secret or certificate.
+	Assertion ClientAssertionConfig
+	// KeyVaultCert locates a certificate in Key Vault, to use in place of CertPath.
+	KeyVaultCert KeyVaultCertificate
+}
+
+// ClientAssertionConfig contains info for fetching a client assertion from an OIDC token endpoint, such as
+// the one GitHub Actions exposes to jobs through ACTIONS_ID_TOKEN_REQUEST_URL and ACTIONS_ID_TOKEN_REQUEST_TOKEN,
+// or else from a file, or as given at login.
+type ClientAssertionConfig struct {
+	RequestURL   string `json:"_assertion_request_url,omitempty"`
+	RequestToken string `json:"_assertion_request_token,omitempty"`
+	// Audience defaults to api://AzureADTokenExchange, the audience expected by Azure AD federated credentials.
+	Audience string `json:"_assertion_audience,omitempty"`
+	// File is re-read on every token refresh, so that whatever writes it can rotate the assertion.
+	File string `json:"_assertion_file,omitempty"`
+	// Value is an assertion given at login. It's short-lived, so it's never persisted.
+	Value string `json:"-"`
+}
+
+func (config ClientAssertionConfig) isSet() bool {
+	return config.File != "" || config.Value != "" || config.RequestURL != ""
+}
+
+const defaultClientAssertionAudience = "api://AzureADTokenExchange"
+
+// newAssertionGetter returns a callback which fetches a fresh assertion on every call.
+func (config ClientAssertionConfig) newAssertionGetter(client *http.Client) func(context.Context) (string, error) {
+	switch {
+	case config.File != "":
+		return func(context.Context) (string, error) {
+			assertion, err := os.ReadFile(config.File)
+			if err != nil {
+				return "", fmt.Errorf("failed to read client assertion file %q, %v", config.File, err)
+			}
+			return strings.TrimSpace(string(assertion)), nil
+		}
+	case config.Value != "":
+		return func(context.Context) (string, error) {
+			return config.Value, nil
+		}
+	}
+
+	return func(ctx context.Context) (string, error) {
+		u, err := url.Parse(config.RequestURL)
+		if err != nil {
+			return "", fmt.Errorf("invalid client assertion request URL, %v", err)
+		}
+		q := u.Query()
+		q.Set("audience", Iff(config.Audience != "", config.Audience, defaultClientAssertionAudience))
+		u.RawQuery = q.Encode()
+
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+		if err != nil {
+			return "", err
+		}
+		if config.RequestToken != "" {
+			req.Header.Set("Authorization", "Bearer "+config.RequestToken)
+		}
+
+		resp, err := client.Do(req)
+		if err != nil {
+			return "", fmt.Errorf("failed to fetch client assertion, %v", err)
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return "", fmt.Errorf("failed to fetch client assertion, unexpected status %s", resp.Status)
+		}
+
+		var body struct {
+			Value string `json:"value"`
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+			return "", fmt.Errorf("failed to parse client assertion response, %v", err)
+		}
+		if body.Value == "" {
+			return "", errors.New("client assertion response did not contain a token")
+		}
+		return body.Value, nil
+	}
 }
 
 // Validate validates identity info, at most only one of clientID, objectID or MSI resource ID could be set.
@@ -465,42 +1213,156 @@ func (identityInfo *IdentityInfo) Validate() error {
 	if len(v) > 1 {
 		return errors.New("client ID, object ID and MSI resource ID are mutually exclusive")
 	}
+	if identityInfo.Endpoint != "" {
+		u, err := url.Parse(identityInfo.Endpoint)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("managed identity endpoint %q is not a valid http(s) URL", identityInfo.Endpoint)
+		}
+	}
+	if identityInfo.ProbeTimeout < 0 {
+		return errors.New("managed identity probe timeout cannot be negative")
+	}
+	// Automation often fills the resource ID with a GUID, which only ever fails later with a confusing service error.
+	if identityInfo.MSIResID != "" {
+		if _, err := ParseUUID(identityInfo.MSIResID); err == nil {
+			return fmt.Errorf("managed identity resource ID %q looks like a client or object ID, resource IDs have the form "+
+				"/subscriptions/<subscription>/resourcegroups/<group>/providers/Microsoft.ManagedIdentity/userAssignedIdentities/<name>. "+
+				"If it is the identity's client ID, pass it as the client ID instead. %s", identityInfo.MSIResID, objectIDMigrationGuidance)
+		}
+	}
+	return nil
+}
+
+const objectIDMigrationGuidance = "Object IDs are no longer supported for managed identity, please use the identity's client ID or resource ID, " +
+	"or opt in to translating the object ID to its client ID with --identity-resolve-object-id or AZCOPY_MSI_RESOLVE_OBJECT_ID."
+
+// ResolveObjectID replaces the object ID of the identity with its client ID, looked up through Microsoft Graph.
+// The client ID is kept in place of the object ID, so that a persisted login doesn't need to look it up again.
+// The lookup is bounded by ProbeTimeout, when set, as well as by ctx.
+func (identityInfo *IdentityInfo) ResolveObjectID(ctx context.Context) error {
+	if identityInfo.ObjectID == "" {
+		return nil
+	}
+	if identityInfo.ProbeTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, identityInfo.ProbeTimeout)
+		defer cancel()
+	}
+	clientID, err := objectIDResolver(ctx, identityInfo.ObjectID)
+	if err != nil {
+		return err
+	}
+	identityInfo.ClientID = clientID
+	identityInfo.ObjectID = ""
 	return nil
 }
 
+// objectIDResolver looks up the client ID of a managed identity by its object ID, authenticating the lookup through
+// the default credential chain, as the identity itself can't be used until its client ID is known.
+var objectIDResolver = func(ctx context.Context, objectID string) (string, error) {
+	bootstrap := &OAuthTokenInfo{UseDefaultCredentialChain: true}
+	cred, err := bootstrap.GetDefaultAzureCredential()
+	if err != nil {
+		return "", err
+	}
+	return ResolveObjectIDToClientID(ctx, objectID, cred)
+}
+
+const graphEndpoint = "https://graph.microsoft.com/v1.0"
+const graphScope = "https://graph.microsoft.com/.default"
+
+// ResolveObjectIDToClientID looks up the client ID of the managed identity with the given object ID through Microsoft Graph,
+// authenticating with cred. It returns an error rather than guessing whenever the lookup cannot be performed.
+func ResolveObjectIDToClientID(ctx context.Context, objectID string, cred azcore.TokenCredential) (string, error) {
+	return resolveObjectIDToClientID(ctx, graphEndpoint, objectID, cred, newAzcopyHTTPClient())
+}
+
+func resolveObjectIDToClientID(ctx context.Context, endpoint, objectID string, cred azcore.TokenCredential, client *http.Client) (string, error) {
+	if _, err := ParseUUID(objectID); err != nil {
+		return "", fmt.Errorf("%q is not a valid object ID", objectID)
+	}
+
+	token, err := cred.GetToken(ctx, policy.TokenRequestOptions{Scopes: []string{graphScope}})
+	if err != nil {
+		return "", fmt.Errorf("failed to authenticate to Microsoft Graph to resolve object ID %q, %v", objectID, err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint+"/servicePrincipals/"+url.PathEscape(objectID)+"?$select=appId", nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Authorization", "Bearer "+token.Token)
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve object ID %q, %v", objectID, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("failed to resolve object ID %q, Microsoft Graph responded %s", objectID, resp.Status)
+	}
+
+	var sp struct {
+		AppID string `json:"appId"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&sp); err != nil {
+		return "", fmt.Errorf("failed to parse Microsoft Graph response for object ID %q, %v", objectID, err)
+	}
+	if sp.AppID == "" {
+		return "", fmt.Errorf("no client ID was returned by Microsoft Graph for object ID %q", objectID)
+	}
+	return sp.AppID, nil
+}
+
 // Refresh gets new token with token info.
 func (credInfo *OAuthTokenInfo) Refresh(ctx context.Context) (*adal.Token, error) {
 	// TODO: I think this method is only necessary until datalake is migrated.
 	// Returns cached TokenCredential or creates a new one if it hasn't been created yet.
-	tc, err := credInfo.GetTokenCredential()
+	tc, err := credInfo.getTokenCredential(ctx)
+	if err != nil {
+		return nil, err
+	}
+	// Device code logins must hand back their refresh token as well, so look past the background refresher.
+	if dcc, ok := unwrapTokenCredential(tc).(*DeviceCodeCredential); ok {
+		var token *adal.Token
+		err = retryTokenRequest(ctx, credInfo.authorityURL(), func(ctx context.Context) error {
+			return serializeTokenRequest(tc, func() (err error) {
+				token, err = dcc.RefreshTokenWithUserCredential(ctx, credInfo.storageResource())
+				return err
+			})
+		})
+		return token, err
+	}
+
+	c, err := credInfo.ResolveCloud()
+	if err != nil {
+		return nil, err
+	}
+	scopes := credInfo.storageScopes(c)
+	t, err := credInfo.requestToken(ctx, tc, policy.TokenRequestOptions{Scopes: scopes})
 	if err != nil {
 		return nil, err
 	}
-	if credInfo.TokenRefreshSource == "tokenstore" || credInfo.Identity || credInfo.ServicePrincipalName {
-		scopes := []string{StorageScope}
-		t, err := tc.GetToken(ctx, policy.TokenRequestOptions{Scopes: scopes})
-		if err != nil {
-			return nil, err
-		}
-		return &adal.Token{
-			AccessToken: t.Token,
-			ExpiresOn:   json.Number(strconv.FormatInt(int64(t.ExpiresOn.Sub(date.UnixEpoch())/time.Second), 10)),
-		}, nil
-	} else {
-		if dcc, ok := tc.(*DeviceCodeCredential); ok {
-			return dcc.RefreshTokenWithUserCredential(ctx, Resource)
-		}
-	}
-	return nil, errors.New("invalid token info")
+	return &adal.Token{
+		AccessToken: t.Token,
+		ExpiresOn:   json.Number(strconv.FormatInt(int64(t.ExpiresOn.Sub(date.UnixEpoch())/time.Second), 10)),
+	}, nil
 }
 
 // Single instance token store credential cache shared by entire azcopy process.
 var tokenStoreCredCache = NewCredCacheInternalIntegration(CredCacheOptions{
-	KeyName:     "azcopy/aadtoken/" + strconv.Itoa(os.Getpid()),
-	ServiceName: "azcopy",
-	AccountName: "aadtoken/" + strconv.Itoa(os.Getpid()),
+	KeyName:     tokenStoreServiceName + "/" + tokenStoreAccountPrefix + strconv.Itoa(os.Getpid()),
+	ServiceName: tokenStoreServiceName,
+	AccountName: tokenStoreAccountPrefix + strconv.Itoa(os.Getpid()),
 })
 
+// The token store keeps a token per AzCopy process, under the account aadtoken/<pid> of the service azcopy.
+// Where the store is keyed by a single name, that's azcopy/aadtoken/<pid>.
+const (
+	tokenStoreServiceName   = "azcopy"
+	tokenStoreAccountPrefix = "aadtoken/"
+)
+
 // IsEmpty returns if current OAuthTokenInfo is empty and doesn't contain any useful info.
 func (credInfo OAuthTokenInfo) IsEmpty() bool {
 	if credInfo.Tenant == "" && credInfo.ActiveDirectoryEndpoint == "" && credInfo.Token.IsZero() && !credInfo.Identity {
@@ -515,78 +1377,280 @@ func (credInfo OAuthTokenInfo) toJSON() ([]byte, error) {
 	return json.Marshal(credInfo)
 }
 
+const redactedSecret = "-REDACTED-"
+
+// Redacted returns a copy of the token info with its tokens, client secret or certificate password, and assertion
+// masked, e.g. to log it. Empty fields are left empty, so that it's still apparent which ones were set.
+func (credInfo OAuthTokenInfo) Redacted() OAuthTokenInfo {
+	redact := func(secret *string) {
+		if *secret != "" {
+			*secret = redactedSecret
+		}
+	}
+
+	redact(&credInfo.AccessToken)
+	redact(&credInfo.RefreshToken)
+	redact(&credInfo.SPNInfo.Secret)
+	redact(&credInfo.SPNInfo.Assertion.RequestToken)
+	redact(&credInfo.SPNInfo.Assertion.Value)
+	if bootstrap := credInfo.SPNInfo.KeyVaultCert.Bootstrap; bootstrap != nil {
+		redacted := bootstrap.Redacted()
+		credInfo.SPNInfo.KeyVaultCert.Bootstrap = &redacted
+	}
+	credInfo.TokenCredential = nil
+	return credInfo
+}
+
+// String formats the token info with its secrets redacted, so that formatting it with %v or %+v can't leak them.
+func (credInfo OAuthTokenInfo) String() string {
+	buf, err := json.Marshal(credInfo.Redacted())
+	if err != nil {
+		return "OAuthTokenInfo{" + redactedSecret + "}"
+	}
+	return string(buf)
+}
+
+// GoString is the %#v counterpart of String.
+func (credInfo OAuthTokenInfo) GoString() string {
+	return "OAuthTokenInfo" + credInfo.String()
+}
+
+// CredentialKind names the kind of credential this token info logs in with, following the same order as GetTokenCredential.
+func (credInfo *OAuthTokenInfo) CredentialKind() string {
+	switch {
+	case credInfo.TokenRefreshSource == TokenRefreshSourceTokenStore:
+		return "TokenStore"
+	case credInfo.TokenRefreshSource == TokenRefreshSourceBearer:
+		return "BearerToken"
+	case credInfo.TokenRefreshSource == TokenRefreshSourceCredential:
+		return "InjectedCredential"
+	case credInfo.Identity:
+		return "ManagedIdentity"
+	case credInfo.ServicePrincipalName:
+		return "ServicePrincipal"
+	case credInfo.WorkloadIdentity:
+		return "WorkloadIdentity"
+	case credInfo.AzCLICred:
+		return "AzureCLI"
+	case credInfo.PSCred:
+		return "AzurePowerShell"
+	case credInfo.AzdCred:
+		return "AzureDeveloperCLI"
+	case credInfo.InteractiveBrowserCred:
+		return "InteractiveBrowser"
+	case credInfo.UseDefaultCredentialChain:
+		return "DefaultCredentialChain"
+	default:
+		return "DeviceCode"
+	}
+}
+
+// CredentialDescription describes a login for status reporting. It holds no secrets, so it's safe to log.
+type CredentialDescription struct {
+	// Kind is the kind of credential, as named by CredentialKind, but telling apart how service principals authenticate.
+	Kind          string
+	TenantID      string
+	ApplicationID string `json:",omitempty"`
+	// ExpiresOn is when the current storage token expires, unset when none has been acquired yet.
+	ExpiresOn *time.Time `json:",omitempty"`
+}
+
+func (d CredentialDescription) String() string {
+	msg := fmt.Sprintf("Authenticated as %s in tenant %s", d.Kind, d.TenantID)
+	if d.ApplicationID != "" {
+		msg += fmt.Sprintf(" with application %s", d.ApplicationID)
+	}
+	if d.ExpiresOn != nil {
+		msg += fmt.Sprintf(", token valid until %s", d.ExpiresOn.UTC().Format(time.RFC3339))
+	}
+	return msg
+}
+
+// Describe reports which identity this token info authenticates as, and when its storage token expires.
+// It never requests a token, the expiry is that of the token the credential currently holds.
+func (credInfo *OAuthTokenInfo) Describe() CredentialDescription {
+	d := CredentialDescription{
+		Kind:          credInfo.CredentialKind(),
+		TenantID:      credInfo.Tenant,
+		ApplicationID: credInfo.ApplicationID,
+	}
+
+	switch {
+	case credInfo.TokenRefreshSource != "":
+	case credInfo.Identity:
+		d.ApplicationID = credInfo.IdentityInfo.ClientID
+	case credInfo.ServicePrincipalName:
+		switch {
+		case credInfo.SPNInfo.Assertion.isSet():
+			d.Kind = "ServicePrincipalAssertion"
+		case credInfo.SPNInfo.KeyVaultCert.VaultURL != "":
+			d.Kind = "ServicePrincipalKeyVaultCertificate"
+		case credInfo.SPNInfo.CertPath != "":
+			d.Kind = "ServicePrincipalCertificate"
+		default:
+			d.Kind = "ServicePrincipalSecret"
+		}
+	}
+
+	if refresher, ok := credInfo.TokenCredential.(*backgroundRefreshCredential); ok {
+		if c, err := credInfo.ResolveCloud(); err == nil {
+			// Logins verify custom scopes directly, while storage clients ask for the standard scope.
+			for _, scopes := range [][]string{{c.StorageScope}, credInfo.storageScopes(c)} {
+				if token, ok := refresher.cachedToken(policy.TokenRequestOptions{Scopes: scopes}); ok {
+					d.ExpiresOn = &token.ExpiresOn
+					return d
+				}
+			}
+		}
+	}
+	if !credInfo.Token.IsZero() {
+		expiresOn := credInfo.Expires()
+		d.ExpiresOn = &expiresOn
+	}
+	return d
+}
+
+// ResolveCloud returns the Azure cloud this token info authenticates against, by name if Cloud is set,
+// or else by matching ActiveDirectoryEndpoint against the known clouds. Its StorageScope is StorageAudience, if that's set.
+func (credInfo *OAuthTokenInfo) ResolveCloud() (AzureCloud, error) {
+	c := azureCloudForAuthority(credInfo.ActiveDirectoryEndpoint)
+	if credInfo.Cloud != "" {
+		var err error
+		if c, err = ResolveAzureCloud(credInfo.Cloud); err != nil {
+			return AzureCloud{}, err
+		}
+	}
+
+	if credInfo.StorageAudience != "" {
+		c.StorageScope = credInfo.StorageAudience
+	}
+	return c, nil
+}
+
+// getAuthorityURL joins tenantID onto activeDirectoryEndpoint. The endpoint is normalized first: any query or fragment is dropped,
+// and a trailing slash is ensured, so that the tenant is appended to (rather than replacing) the endpoint's last path segment.
+// A path on the endpoint is otherwise kept, as AD FS authorities have one.
 func getAuthorityURL(tenantID, activeDirectoryEndpoint string) (*url.URL, error) {
-	u, err := url.Parse(activeDirectoryEndpoint)
+	u, err := url.Parse(strings.TrimSpace(activeDirectoryEndpoint))
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("invalid Active Directory endpoint %q: %w", activeDirectoryEndpoint, err)
+	}
+	if u.Scheme != "https" || u.Host == "" || u.User != nil {
+		return nil, fmt.Errorf("invalid Active Directory endpoint %q: must be an https URL, e.g. %s", activeDirectoryEndpoint, DefaultActiveDirectoryEndpoint)
+	}
+	if strings.ContainsAny(tenantID, "/\\?#") {
+		return nil, fmt.Errorf("invalid tenant ID %q: must be a tenant GUID or domain name", tenantID)
+	}
+
+	u.RawQuery, u.ForceQuery, u.Fragment, u.RawFragment = "", false, "", ""
+	u.RawPath = ""
+	if !strings.HasSuffix(u.Path, "/") {
+		u.Path += "/"
 	}
-	return u.Parse(tenantID)
+	return u.ResolveReference(&url.URL{Path: tenantID}), nil
 }
 
 const minimumTokenValidDuration = time.Minute * 5
 type TokenStoreCredential struct {
 	token *azcore.AccessToken
 	lock  sync.RWMutex
+	// cache is where refreshed tokens are loaded from, tokenStoreCredCache unless overridden in tests.
+	cache tokenStoreCache
+	// metrics counts the reloads from the token store, AuthMetrics unless overridden in tests.
+	metrics *TokenRefreshMetrics
 }
 
-// globalTokenStoreCredential is created to make sure that all
-// service clients share same cred object. This is required so that
-// we do not make repeated GetToken calls.
-// This is a temporary fix for issue where we would request a
-// new token from Stg Exp even while they've not yet populated the
-// tokenstore. 
-//
-// This is okay because we use same credential on both source and
-// destination. If we move to a case where the credentials are
-// different, this should be removed.
-//
-// We should move to a method where the token is always read  from
-// tokenstore, and azcopy is invoked after tokenstore is populated.
-//
-var globalTokenStoreCredential *TokenStoreCredential
-var globalTsc sync.Once
+// tokenStoreCache is the subset of the token store cache which TokenStoreCredential reads from.
+type tokenStoreCache interface {
+	HasCachedToken() (bool, error)
+	LoadToken() (*OAuthTokenInfo, error)
+}
 
 func (tsc *TokenStoreCredential) GetToken(_ context.Context, _ policy.TokenRequestOptions) (azcore.AccessToken, error) {
 	// if the token we've has not expired, return the same.
-	tsc.lock.RLock()
-	if time.Until(tsc.token.ExpiresOn) > minimumTokenValidDuration {
-		return *tsc.token, nil
+	if token, ok := tsc.validToken(); ok {
+		return token, nil
 	}
-	tsc.lock.RUnlock()
 
 	tsc.lock.Lock()
 	defer tsc.lock.Unlock()
-	hasToken, err := tokenStoreCredCache.HasCachedToken()
+	// Another goroutine may have reloaded the token while we were waiting for the write lock.
+	if time.Until(tsc.token.ExpiresOn) > minimumTokenValidDuration {
+		return *tsc.token, nil
+	}
+
+	start := time.Now()
+	token, err := tsc.reload()
+	tsc.metrics.recordRefresh(start, err)
+	return token, err
+}
+
+// reload replaces the token with the one in the token store. It must be called with the write lock held.
+func (tsc *TokenStoreCredential) reload() (azcore.AccessToken, error) {
+	hasToken, err := tsc.cache.HasCachedToken()
 	if err != nil || !hasToken {
 		return azcore.AccessToken{}, fmt.Errorf("no cached token found in Token Store Mode(SE), %v", err)
 	}
 
-	tokenInfo, err := tokenStoreCredCache.LoadToken()
+	tokenInfo, err := tsc.cache.LoadToken()
 	if err != nil {
 		return azcore.AccessToken{}, fmt.Errorf("get cached token failed in Token Store Mode(SE), %v", err)
 	}
 
+	warnOnTokenClockSkew(tokenInfo.AccessToken)
 	tsc.token = &azcore.AccessToken{
 		Token:     tokenInfo.AccessToken,
 		ExpiresOn: tokenInfo.Expires(),
 	}
 
 	return *tsc.token, nil
+}
 
+func (tsc *TokenStoreCredential) validToken() (azcore.AccessToken, bool) {
+	tsc.lock.RLock()
+	defer tsc.lock.RUnlock()
+	return *tsc.token, time.Until(tsc.token.ExpiresOn) > minimumTokenValidDuration
 }
 
+// tokenStoreCredentials holds the one credential reading each token store, so that all service clients (of either
+// role, and of every token info) share it, and do not make repeated reads from the token store.
+// This also keeps AzCopy from asking the store for a new token before the integration has populated it.
+var (
+	tokenStoreCredentialsLock sync.Mutex
+	tokenStoreCredentials     = make(map[tokenStoreCache]*TokenStoreCredential)
+)
+
 // GetNewTokenFromTokenStore gets token from token store. (Credential Manager in Windows, keyring in Linux and keychain in MacOS.)
 // Note: This approach should only be used in internal integrations.
-func GetTokenStoreCredential(accessToken string, expiresOn time.Time) (azcore.TokenCredential) {
-	globalTsc.Do(func() {
-		globalTokenStoreCredential = &TokenStoreCredential{
-			token: &azcore.AccessToken{
-				Token:     accessToken,
-				ExpiresOn: expiresOn,
-			},
+func GetTokenStoreCredential(accessToken string, expiresOn time.Time) azcore.TokenCredential {
+	return sharedTokenStoreCredential(tokenStoreCredCache, accessToken, expiresOn)
+}
+
+// sharedTokenStoreCredential returns the credential reading cache, creating it with the given token if there is none.
+// An existing credential adopts the token if it outlives the one it holds.
+func sharedTokenStoreCredential(cache tokenStoreCache, accessToken string, expiresOn time.Time) *TokenStoreCredential {
+	tokenStoreCredentialsLock.Lock()
+	defer tokenStoreCredentialsLock.Unlock()
+
+	if tsc, ok := tokenStoreCredentials[cache]; ok {
+		tsc.lock.Lock()
+		if expiresOn.After(tsc.token.ExpiresOn) {
+			tsc.token = &azcore.AccessToken{Token: accessToken, ExpiresOn: expiresOn}
 		}
-	})
-	return globalTokenStoreCredential
+		tsc.lock.Unlock()
+		return tsc
+	}
+
+	tsc := &TokenStoreCredential{
+		token: &azcore.AccessToken{
+			Token:     accessToken,
+			ExpiresOn: expiresOn,
+		},
+		cache:   cache,
+		metrics: AuthMetrics,
+	}
+	tokenStoreCredentials[cache] = tsc
+	return tsc
 }
 
 func (credInfo *OAuthTokenInfo) GetTokenStoreCredential() (azcore.TokenCredential, error) {
@@ -594,19 +1658,93 @@ func (credInfo *OAuthTokenInfo) GetTokenStoreCredential() (azcore.TokenCredentia
 	return credInfo.TokenCredential, nil
 }
 
+// NewOAuthTokenInfoFromCredential creates token info which authenticates with cred, for programs embedding AzCopy
+// which construct their own credentials. The credential is asked for storage and managed disk tokens as needed.
+// Hand the token info to AzCopy with UserOAuthTokenManager.SetTokenInfo.
+func NewOAuthTokenInfoFromCredential(cred azcore.TokenCredential, tenant string) *OAuthTokenInfo {
+	if tenant == "" {
+		tenant = DefaultTenantID
+	}
+	endpoint, err := resolveActiveDirectoryEndpoint("")
+	if err != nil {
+		endpoint = DefaultActiveDirectoryEndpoint
+	}
+	return &OAuthTokenInfo{
+		TokenCredential:         cred,
+		Tenant:                  tenant,
+		ActiveDirectoryEndpoint: endpoint,
+		TokenRefreshSource:      TokenRefreshSourceCredential,
+	}
+}
+
+// bearerTokenCredential serves a bearer token handed to AzCopy as is, until it expires.
+type bearerTokenCredential struct {
+	token azcore.AccessToken
+}
+
+func (c *bearerTokenCredential) GetToken(_ context.Context, _ policy.TokenRequestOptions) (azcore.AccessToken, error) {
+	if !time.Now().Before(c.token.ExpiresOn) {
+		return azcore.AccessToken{}, fmt.Errorf("the bearer token passed to AzCopy expired at %s, please provide a new one", c.token.ExpiresOn.UTC().Format(time.RFC3339))
+	}
+	return c.token, nil
+}
+
+func (credInfo *OAuthTokenInfo) GetBearerTokenCredential() (azcore.TokenCredential, error) {
+	credInfo.TokenCredential = &bearerTokenCredential{token: azcore.AccessToken{Token: credInfo.AccessToken, ExpiresOn: credInfo.Expires()}}
+	return credInfo.TokenCredential, nil
+}
+
 func (credInfo *OAuthTokenInfo) GetManagedIdentityCredential() (azcore.TokenCredential, error) {
+	return credInfo.managedIdentityCredential(context.Background())
+}
+
+// managedIdentityCredential is GetManagedIdentityCredential, with ctx bounding the lookup of an identity given by object ID.
+func (credInfo *OAuthTokenInfo) managedIdentityCredential(ctx context.Context) (azcore.TokenCredential, error) {
 	var id azidentity.ManagedIDKind
 	if credInfo.IdentityInfo.ClientID != "" {
 		id = azidentity.ClientID(credInfo.IdentityInfo.ClientID)
 	} else if credInfo.IdentityInfo.MSIResID != "" {
 		id = azidentity.ResourceID(credInfo.IdentityInfo.MSIResID)
 	} else if credInfo.IdentityInfo.ObjectID != "" {
-		return nil, fmt.Errorf("object ID is deprecated and no longer supported for managed identity. Please use client ID or resource ID instead")
+		// Automation which only knows the object ID can opt in to resolving it, e.g. for auto login.
+		if resolve, _ := strconv.ParseBool(lcm.GetEnvironmentVariable(EEnvironmentVariable.ManagedIdentityResolveObjectID())); !resolve {
+			return nil, errors.New(objectIDMigrationGuidance)
+		}
+		if err := credInfo.IdentityInfo.ResolveObjectID(ctx); err != nil {
+			return nil, err
+		}
+		id = azidentity.ClientID(credInfo.IdentityInfo.ClientID)
+	}
+
+	client := newAzcopyHTTPClient()
+	if credInfo.IdentityInfo.ProbeTimeout > 0 {
+		client.Timeout = credInfo.IdentityInfo.ProbeTimeout
+	}
+
+	// On Azure Arc, azidentity discovers the agent's endpoint and handles its challenge by itself, but only
+	// the machine's system-assigned identity is available.
+	arc := lcm.GetEnvironmentVariable(EEnvironmentVariable.IdentityEndpoint()) != "" && lcm.GetEnvironmentVariable(EEnvironmentVariable.ArcIMDSEndpoint()) != ""
+	if arc && (credInfo.IdentityInfo.ClientID != "" || credInfo.IdentityInfo.MSIResID != "") {
+		return nil, errors.New("only the system-assigned managed identity is available on Azure Arc-enabled servers, please remove the identity client/resource ID")
+	}
+
+	if credInfo.IdentityInfo.Endpoint != "" {
+		tc, err := newManagedIdentityEndpointCredential(credInfo.IdentityInfo, lcm.GetEnvironmentVariable(EEnvironmentVariable.IdentityHeader()), client)
+		if err != nil {
+			return nil, err
+		}
+		credInfo.TokenCredential = tc
+		return tc, nil
 	}
 
+	c, err := credInfo.ResolveCloud()
+	if err != nil {
+		return nil, err
+	}
 	tc, err := azidentity.NewManagedIdentityCredential(&azidentity.ManagedIdentityCredentialOptions{
 		ClientOptions: azcore.ClientOptions{
-			Transport: newAzcopyHTTPClient(),
+			Cloud:     c.Configuration,
+			Transport: client,
 		},
 		ID: id,
 	})
@@ -618,23 +1756,34 @@ func (credInfo *OAuthTokenInfo) GetManagedIdentityCredential() (azcore.TokenCred
 }
 
 func (credInfo *OAuthTokenInfo) GetClientCertificateCredential() (azcore.TokenCredential, error) {
-	authorityHost, err := getAuthorityURL(credInfo.Tenant, credInfo.ActiveDirectoryEndpoint)
+	certData, err := os.ReadFile(credInfo.SPNInfo.CertPath)
 	if err != nil {
 		return nil, err
 	}
-	certData, err := os.ReadFile(credInfo.SPNInfo.CertPath)
+	certs, key, err := parseClientCertificate(credInfo.SPNInfo.CertPath, certData, credInfo.SPNInfo.Secret)
+	if err != nil {
+		return nil, err
+	}
+
+	return credInfo.newClientCertificateCredential(certs, key)
+}
+
+func (credInfo *OAuthTokenInfo) newClientCertificateCredential(certs []*x509.Certificate, key crypto.PrivateKey) (azcore.TokenCredential, error) {
+	c, err := credInfo.ResolveCloud()
 	if err != nil {
 		return nil, err
 	}
-	certs, key, err := azidentity.ParseCertificates(certData, []byte(credInfo.SPNInfo.Secret))
+	authorityHost, err := getAuthorityURL(credInfo.Tenant, c.Configuration.ActiveDirectoryAuthorityHost)
 	if err != nil {
 		return nil, err
 	}
 	tc, err := azidentity.NewClientCertificateCredential(credInfo.Tenant, credInfo.ApplicationID, certs, key, &azidentity.ClientCertificateCredentialOptions{
 		ClientOptions: azcore.ClientOptions{
-			Cloud:     cloud.Configuration{ActiveDirectoryAuthorityHost: authorityHost.String()},
+			Cloud:     cloud.Configuration{ActiveDirectoryAuthorityHost: authorityHost.String(), Services: c.Configuration.Services},
 			Transport: newAzcopyHTTPClient(),
 		},
+		SendCertificateChain:       credInfo.SPNInfo.SendCertChain,
+		AdditionallyAllowedTenants: credInfo.AdditionalTenants,
 	})
 	if err != nil {
 		return nil, err
@@ -644,15 +1793,77 @@ func (credInfo *OAuthTokenInfo) GetClientCertificateCredential() (azcore.TokenCr
 }
 
 func (credInfo *OAuthTokenInfo) GetClientSecretCredential() (azcore.TokenCredential, error) {
-	authorityHost, err := getAuthorityURL(credInfo.Tenant, credInfo.ActiveDirectoryEndpoint)
+	c, err := credInfo.ResolveCloud()
+	if err != nil {
+		return nil, err
+	}
+	authorityHost, err := getAuthorityURL(credInfo.Tenant, c.Configuration.ActiveDirectoryAuthorityHost)
 	if err != nil {
 		return nil, err
 	}
 	tc, err := azidentity.NewClientSecretCredential(credInfo.Tenant, credInfo.ApplicationID, credInfo.SPNInfo.Secret, &azidentity.ClientSecretCredentialOptions{
 		ClientOptions: azcore.ClientOptions{
-			Cloud:     cloud.Configuration{ActiveDirectoryAuthorityHost: authorityHost.String()},
+			Cloud:     cloud.Configuration{ActiveDirectoryAuthorityHost: authorityHost.String(), Services: c.Configuration.Services},
+			Transport: newAzcopyHTTPClient(),
+		},
+		AdditionallyAllowedTenants: credInfo.AdditionalTenants,
+	})
+	if err != nil {
+		return nil, err
+	}
+	credInfo.TokenCredential = tc
+	return tc, nil
+}
+
+// GetClientAssertionCredential creates a service principal credential which authenticates with assertions returned by getAssertion.
+// getAssertion is invoked every time a new access token is requested, so it must return a currently valid assertion.
+func (credInfo *OAuthTokenInfo) GetClientAssertionCredential(getAssertion func(context.Context) (string, error)) (azcore.TokenCredential, error) {
+	return credInfo.newClientAssertionCredential(getAssertion, newAzcopyHTTPClient())
+}
+
+func (credInfo *OAuthTokenInfo) newClientAssertionCredential(getAssertion func(context.Context) (string, error), transport policy.Transporter) (azcore.TokenCredential, error) {
+	c, err := credInfo.ResolveCloud()
+	if err != nil {
+		return nil, err
+	}
+	tc, err := azidentity.NewClientAssertionCredential(credInfo.Tenant, credInfo.ApplicationID, getAssertion, &azidentity.ClientAssertionCredentialOptions{
+		ClientOptions: azcore.ClientOptions{
+			Cloud:     c.Configuration,
+			Transport: transport,
+		},
+		AdditionallyAllowedTenants: credInfo.AdditionalTenants,
+	})
+	if err != nil {
+		return nil, err
+	}
+	credInfo.TokenCredential = tc
+	return tc, nil
+}
+
+func (credInfo *OAuthTokenInfo) GetWorkloadIdentityCredential() (azcore.TokenCredential, error) {
+	tokenFile := credInfo.SPNInfo.FederatedTokenFile
+	if tokenFile == "" {
+		return nil, fmt.Errorf("workload identity requires a federated token file, please set %s", EEnvironmentVariable.AzureFederatedTokenFile().Name)
+	}
+	if f, err := os.Open(tokenFile); err != nil {
+		return nil, fmt.Errorf("failed to read workload identity federated token file %q, %v", tokenFile, err)
+	} else {
+		_ = f.Close()
+	}
+
+	c, err := credInfo.ResolveCloud()
+	if err != nil {
+		return nil, err
+	}
+	tc, err := azidentity.NewWorkloadIdentityCredential(&azidentity.WorkloadIdentityCredentialOptions{
+		ClientOptions: azcore.ClientOptions{
+			Cloud:     c.Configuration,
 			Transport: newAzcopyHTTPClient(),
 		},
+		ClientID:                   credInfo.ApplicationID,
+		TenantID:                   Iff(credInfo.Tenant == DefaultTenantID, "", credInfo.Tenant),
+		TokenFilePath:              tokenFile,
+		AdditionallyAllowedTenants: credInfo.AdditionalTenants,
 	})
 	if err != nil {
 		return nil, err
@@ -661,8 +1872,25 @@ func (credInfo *OAuthTokenInfo) GetClientSecretCredential() (azcore.TokenCredent
 	return tc, nil
 }
 
+// GetAzCliCredential authenticates through the Azure CLI, which targets whichever cloud it was configured for with "az cloud set".
 func (credInfo *OAuthTokenInfo) GetAzCliCredential() (azcore.TokenCredential, error) {
-	tc, err := azidentity.NewAzureCLICredential(&azidentity.AzureCLICredentialOptions{TenantID: credInfo.Tenant})
+	tc, err := azidentity.NewAzureCLICredential(&azidentity.AzureCLICredentialOptions{
+		TenantID:                   credInfo.Tenant,
+		AdditionallyAllowedTenants: credInfo.AdditionalTenants,
+	})
+	if err != nil {
+		return nil, err
+	}
+	credInfo.TokenCredential = tc
+	return tc, nil
+}
+
+// GetAzdCredential authenticates through the Azure Developer CLI, using the tenant of its selected subscription unless one was specified.
+func (credInfo *OAuthTokenInfo) GetAzdCredential() (azcore.TokenCredential, error) {
+	tc, err := azidentity.NewAzureDeveloperCLICredential(&azidentity.AzureDeveloperCLICredentialOptions{
+		TenantID:                   Iff(credInfo.Tenant == DefaultTenantID, "", credInfo.Tenant),
+		AdditionallyAllowedTenants: credInfo.AdditionalTenants,
+	})
 	if err != nil {
 		return nil, err
 	}
@@ -670,8 +1898,54 @@ func (credInfo *OAuthTokenInfo) GetAzCliCredential() (azcore.TokenCredential, er
 	return tc, nil
 }
 
+// GetPSContextCredential authenticates through Azure PowerShell, which targets the environment chosen with Connect-AzAccount.
 func (credInfo *OAuthTokenInfo) GetPSContextCredential() (azcore.TokenCredential, error) {
-	tc, err := NewPowershellContextCredential(nil)
+	tc, err := NewPowershellContextCredential(&PowershellContextCredentialOptions{
+		TenantID:                   Iff(credInfo.Tenant == DefaultTenantID, "", credInfo.Tenant),
+		AdditionallyAllowedTenants: credInfo.AdditionalTenants,
+		Transport:                  newAzcopyHTTPClient(),
+	})
+	if err != nil {
+		return nil, err
+	}
+	credInfo.TokenCredential = tc
+	return tc, nil
+}
+
+func (credInfo *OAuthTokenInfo) GetDefaultAzureCredential() (azcore.TokenCredential, error) {
+	c, err := credInfo.ResolveCloud()
+	if err != nil {
+		return nil, err
+	}
+	tc, err := azidentity.NewDefaultAzureCredential(&azidentity.DefaultAzureCredentialOptions{
+		ClientOptions: azcore.ClientOptions{
+			Cloud:     c.Configuration,
+			Transport: newAzcopyHTTPClient(),
+		},
+		TenantID:                   Iff(credInfo.Tenant == DefaultTenantID, "", credInfo.Tenant),
+		AdditionallyAllowedTenants: credInfo.AdditionalTenants,
+	})
+	if err != nil {
+		return nil, err
+	}
+	credInfo.TokenCredential = tc
+	return tc, nil
+}
+
+func (credInfo *OAuthTokenInfo) GetInteractiveBrowserCredential() (azcore.TokenCredential, error) {
+	c, err := credInfo.ResolveCloud()
+	if err != nil {
+		return nil, err
+	}
+	tc, err := azidentity.NewInteractiveBrowserCredential(&azidentity.InteractiveBrowserCredentialOptions{
+		ClientOptions: azcore.ClientOptions{
+			Cloud:     c.Configuration,
+			Transport: newAzcopyHTTPClient(),
+		},
+		ClientID:    Iff(credInfo.ApplicationID != "", credInfo.ApplicationID, ApplicationID),
+		TenantID:    credInfo.Tenant,
+		RedirectURL: credInfo.BrowserRedirectURL,
+	})
 	if err != nil {
 		return nil, err
 	}
@@ -731,33 +2005,106 @@ func (dcc *DeviceCodeCredential) RefreshTokenWithUserCredential(ctx context.Cont
 }
 
 func (credInfo *OAuthTokenInfo) GetDeviceCodeCredential() (azcore.TokenCredential, error) {
-	tc := &DeviceCodeCredential{token: credInfo.Token, aadEndpoint: credInfo.ActiveDirectoryEndpoint, tenantID: credInfo.Tenant, clientID: credInfo.ApplicationID}
+	c, err := credInfo.ResolveCloud()
+	if err != nil {
+		return nil, err
+	}
+	tc := &DeviceCodeCredential{token: credInfo.Token, aadEndpoint: c.Configuration.ActiveDirectoryAuthorityHost, tenantID: credInfo.Tenant, clientID: credInfo.ApplicationID}
 	credInfo.TokenCredential = tc
 	return tc, nil
 }
 
+// GetTokenCredential returns the credential for this login, which caches its tokens (see StartBackgroundRefresh).
 func (credInfo *OAuthTokenInfo) GetTokenCredential() (azcore.TokenCredential, error) {
+	return credInfo.getTokenCredential(context.Background())
+}
+
+// getTokenCredential is GetTokenCredential, with ctx bounding any lookups needed to create the credential.
+func (credInfo *OAuthTokenInfo) getTokenCredential(ctx context.Context) (azcore.TokenCredential, error) {
 	// Token Credential is cached.
-	if credInfo.TokenCredential != nil {
-		return credInfo.TokenCredential, nil
+	tc := credInfo.TokenCredential
+	if tc == nil {
+		var err error
+		if tc, err = credInfo.newTokenCredential(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	if _, ok := tc.(*backgroundRefreshCredential); !ok {
+		if len(credInfo.CustomScopes) != 0 || credInfo.StorageAudience != "" {
+			c, err := credInfo.ResolveCloud()
+			if err != nil {
+				return nil, err
+			}
+			tc = newCustomScopesCredential(tc, c, credInfo.CustomScopes)
+		}
+		refresher := newBackgroundRefreshCredential(tc, tokenRefreshWindow())
+		if _, ok := tc.(*TokenStoreCredential); ok {
+			// Most requests to the token store credential are answered from memory, so it counts its own reloads.
+			refresher.metrics = nil
+		}
+		credInfo.TokenCredential = refresher
+	}
+	return credInfo.TokenCredential, nil
+}
+
+// StartBackgroundRefresh refreshes the tokens of this login in the background, shortly before they expire, until ctx
+// is cancelled or StopBackgroundRefresh is called. Otherwise tokens are only refreshed on demand, once they expire.
+func (credInfo *OAuthTokenInfo) StartBackgroundRefresh(ctx context.Context) error {
+	tc, err := credInfo.getTokenCredential(ctx)
+	if err != nil {
+		return err
+	}
+	tc.(*backgroundRefreshCredential).Start(ctx)
+	return nil
+}
+
+// StopBackgroundRefresh stops refreshing the tokens of this login in the background (see StartBackgroundRefresh), once
+// the token info is discarded. Its credential still fetches tokens on demand.
+func (credInfo *OAuthTokenInfo) StopBackgroundRefresh() {
+	if refresher, ok := credInfo.TokenCredential.(*backgroundRefreshCredential); ok {
+		refresher.Stop()
+	}
+}
+
+func (credInfo *OAuthTokenInfo) newTokenCredential(ctx context.Context) (azcore.TokenCredential, error) {
+	if err := credInfo.validateCredentialKind(); err != nil {
+		return nil, err
 	}
 
 	if credInfo.TokenRefreshSource == TokenRefreshSourceTokenStore {
 		return credInfo.GetTokenStoreCredential()
 	}
 
+	if credInfo.TokenRefreshSource == TokenRefreshSourceBearer {
+		return credInfo.GetBearerTokenCredential()
+	}
+
+	if credInfo.TokenRefreshSource == TokenRefreshSourceCredential {
+		// The credential only lives on the token info it was injected into.
+		return nil, errors.New("the token info has no credential, please create it with NewOAuthTokenInfoFromCredential")
+	}
+
 	if credInfo.Identity {
-		return credInfo.GetManagedIdentityCredential()
+		return credInfo.managedIdentityCredential(ctx)
 	}
 
 	if credInfo.ServicePrincipalName {
-		if credInfo.SPNInfo.CertPath != "" {
+		if credInfo.SPNInfo.Assertion.isSet() {
+			return credInfo.GetClientAssertionCredential(credInfo.SPNInfo.Assertion.newAssertionGetter(newAzcopyHTTPClient()))
+		} else if credInfo.SPNInfo.KeyVaultCert.VaultURL != "" {
+			return credInfo.GetKeyVaultCertificateCredential()
+		} else if credInfo.SPNInfo.CertPath != "" {
 			return credInfo.GetClientCertificateCredential()
 		} else {
 			return credInfo.GetClientSecretCredential()
 		}
 	}
 
+	if credInfo.WorkloadIdentity {
+		return credInfo.GetWorkloadIdentityCredential()
+	}
+
 	if credInfo.AzCLICred {
 		return credInfo.GetAzCliCredential()
 	}
@@ -765,6 +2112,18 @@ func (credInfo *OAuthTokenInfo) GetTokenCredential() (azcore.TokenCredential, er
 	if credInfo.PSCred {
 		return credInfo.GetPSContextCredential()
 	}
+
+	if credInfo.AzdCred {
+		return credInfo.GetAzdCredential()
+	}
+
+	if credInfo.InteractiveBrowserCred {
+		return credInfo.GetInteractiveBrowserCredential()
+	}
+
+	if credInfo.UseDefaultCredentialChain {
+		return credInfo.GetDefaultAzureCredential()
+	}
 	return credInfo.GetDeviceCodeCredential()
 }
 
@@ -774,12 +2133,99 @@ func jsonToTokenInfo(b []byte) (*OAuthTokenInfo, error) {
 	if err := json.Unmarshal(b, &OAuthTokenInfo); err != nil {
 		return nil, err
 	}
-	if OAuthTokenInfo.TokenRefreshSource == TokenRefreshSourceTokenStore {
+	if err := OAuthTokenInfo.validateRefreshSource(); err != nil {
+		return nil, err
+	}
+	if err := OAuthTokenInfo.validateCredentialKind(); err != nil {
+		return nil, err
+	}
+	if err := OAuthTokenInfo.validateRequiredFields(); err != nil {
+		return nil, err
+	}
+	if err := validateStorageAudience(OAuthTokenInfo.StorageAudience); err != nil {
+		return nil, err
+	}
+	switch OAuthTokenInfo.TokenRefreshSource {
+	case TokenRefreshSourceTokenStore:
 		_, _ = OAuthTokenInfo.GetTokenStoreCredential()
+	case TokenRefreshSourceBearer:
+		_, _ = OAuthTokenInfo.GetBearerTokenCredential()
 	}
 	return &OAuthTokenInfo, nil
 }
 
+// validateRefreshSource checks that token info which can't be refreshed carries the access token to use and its expiry.
+func (credInfo *OAuthTokenInfo) validateRefreshSource() error {
+	switch credInfo.TokenRefreshSource {
+	case "":
+		return nil
+	case TokenRefreshSourceTokenStore, TokenRefreshSourceBearer:
+	default:
+		return fmt.Errorf("invalid _token_refresh_source %q, expected %q, %q or none", credInfo.TokenRefreshSource, TokenRefreshSourceTokenStore, TokenRefreshSourceBearer)
+	}
+
+	if credInfo.AccessToken == "" {
+		return fmt.Errorf("access_token is required when _token_refresh_source is %q", credInfo.TokenRefreshSource)
+	}
+	if credInfo.ExpiresOn == "" {
+		return fmt.Errorf("expires_on is required when _token_refresh_source is %q, as seconds since the Unix epoch", credInfo.TokenRefreshSource)
+	}
+	return nil
+}
+
+// validateCredentialKind checks that at most one kind of credential is enabled, rather than letting
+// GetTokenCredential silently pick one of them.
+func (credInfo *OAuthTokenInfo) validateCredentialKind() error {
+	kinds := []struct {
+		field string
+		set   bool
+	}{
+		{"_token_refresh_source", credInfo.TokenRefreshSource != ""},
+		{"_identity", credInfo.Identity},
+		{"_spn", credInfo.ServicePrincipalName},
+		{"_workload_identity", credInfo.WorkloadIdentity},
+		{"AzCLICred", credInfo.AzCLICred},
+		{"PSCred", credInfo.PSCred},
+		{"_azd_cred", credInfo.AzdCred},
+		{"_interactive_browser", credInfo.InteractiveBrowserCred},
+		{"_use_default_credential_chain", credInfo.UseDefaultCredentialChain},
+	}
+
+	var set []string
+	for _, kind := range kinds {
+		if kind.set {
+			set = append(set, kind.field)
+		}
+	}
+	if len(set) > 1 {
+		return fmt.Errorf("token info enables multiple kinds of credential (%s), only one of them may be set", strings.Join(set, ", "))
+	}
+	return nil
+}
+
+// validateRequiredFields checks that token info carries what its kind of credential needs to get tokens.
+func (credInfo *OAuthTokenInfo) validateRequiredFields() error {
+	switch {
+	case credInfo.Identity:
+		return credInfo.IdentityInfo.Validate()
+	case credInfo.ServicePrincipalName:
+		spn := credInfo.SPNInfo
+		if credInfo.ApplicationID == "" {
+			return errors.New("_application_id is required for service principal token info")
+		}
+		if spn.Secret == "" && spn.CertPath == "" && spn.Assertion.RequestURL == "" && spn.Assertion.File == "" && spn.KeyVaultCert.VaultURL == "" {
+			return errors.New("service principal token info requires one of _spn_secret, _spn_cert_path, _assertion_request_url, _assertion_file or _kv_vault_url")
+		}
+	case credInfo.TokenRefreshSource == "" && !credInfo.WorkloadIdentity && !credInfo.AzCLICred && !credInfo.PSCred && !credInfo.AzdCred &&
+		!credInfo.InteractiveBrowserCred && !credInfo.UseDefaultCredentialChain:
+		// Device code logins can only be resumed with their tokens. Empty token info is reported by the caller.
+		if !credInfo.IsEmpty() && credInfo.RefreshToken == "" && credInfo.AccessToken == "" {
+			return errors.New("refresh_token or access_token is required for device code token info")
+		}
+	}
+	return nil
+}
+
 // ====================================================================================
 
 // TestOAuthInjection controls variables for OAuth testing injections
@@ -793,5 +2239,3 @@ var GlobalTestOAuthInjection = TestOAuthInjection{
 	DoTokenRefreshInjection: false,
 	TokenRefreshDuration:    time.Second * 10,
 }
-
-// TODO: Add pipeline policy for token refreshing validating.
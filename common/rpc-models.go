@@ -178,7 +178,12 @@ type CopyJobPartOrderRequest struct {
 	// As a result, CredentialInfo.OAuthTokenInfo may end up being fulfilled even _if_ CredentialInfo.CredentialType is _not_ OAuth.
 	// This may not always be the case (for instance, if we opt to use multiple OAuth tokens). At that point, this will likely be it's own CredentialInfo.
 	S2SSourceCredentialType CredentialType // Only Anonymous and OAuth will really be used in response to this, but S3 and GCP will come along too...
-	FileAttributes          FileTransferAttributes
+	// S2SSourceLoginType is the auto-login type (e.g. "msi") the source logs in with when it doesn't share the
+	// destination's OAuth login, and S2SSourceTenantID the tenant it authenticates against. They're persisted in the
+	// job plan, so that resumed jobs log each side in as before.
+	S2SSourceLoginType string
+	S2SSourceTenantID  string
+	FileAttributes     FileTransferAttributes
 }
 
 // CredentialInfo contains essential credential info which need be transited between modules,
@@ -320,6 +325,14 @@ type ListJobSummaryResponse struct {
 	ServerBusyPercentage   float32 `json:",string"`
 	NetworkErrorPercentage float32 `json:",string"`
 
+	// OAuth token stats, see TokenRefreshMetrics. Like the network stats, they cover the process running the job,
+	// and will be zero if read outside it.
+	TokenRefreshes                  int64 `json:",string"`
+	TokenRefreshFailures            int64 `json:",string"`
+	AverageTokenRefreshMilliseconds int64 `json:",string"`
+	// CredentialFallbacks counts the failed refreshes for which the current token was used until it expired.
+	CredentialFallbacks int64 `json:",string"`
+
 	FailedTransfers  []TransferDetail
 	SkippedTransfers []TransferDetail
 	PerfConstraint   PerfConstraint
@@ -329,6 +342,14 @@ type ListJobSummaryResponse struct {
 	IsCleanupJob      bool
 }
 
+// SetTokenRefreshStats fills in the OAuth token stats of the summary.
+func (js *ListJobSummaryResponse) SetTokenRefreshStats(stats TokenRefreshStats) {
+	js.TokenRefreshes = stats.Refreshes
+	js.TokenRefreshFailures = stats.Failures
+	js.AverageTokenRefreshMilliseconds = stats.AverageLatency.Milliseconds()
+	js.CredentialFallbacks = stats.Fallbacks
+}
+
 // wraps the standard ListJobSummaryResponse with sync-specific stats
 type ListSyncJobSummaryResponse struct {
 	ListJobSummaryResponse
@@ -386,4 +407,7 @@ type GetJobFromToResponse struct {
 	FromTo      FromTo
 	Source      string
 	Destination string
+	// SourceLoginType and SourceTenantID are those of the job's order, see CopyJobPartOrderRequest.S2SSourceLoginType.
+	SourceLoginType string
+	SourceTenantID  string
 }
@@ -0,0 +1,52 @@
+// Copyright © 2017 Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package common
+
+import (
+	"errors"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+)
+
+// Service codes of the 403s returned when a request breaks a restriction the SAS was signed with, rather than because the SAS is wrong.
+const (
+	SAS_IP_MISMATCH_SERVICE_CODE       = "AuthorizationSourceIPMismatch"
+	SAS_PROTOCOL_MISMATCH_SERVICE_CODE = "AuthorizationProtocolMismatch"
+)
+
+// SASRestrictionHint explains a 403 caused by the IP range (sip) or protocol (spr) a SAS is restricted to, which otherwise
+// reads like any other authentication failure. It returns "" for any other error.
+func SASRestrictionHint(err error) string {
+	var respErr *azcore.ResponseError
+	if !errors.As(err, &respErr) {
+		return ""
+	}
+
+	switch respErr.ErrorCode {
+	case SAS_IP_MISMATCH_SERVICE_CODE:
+		return "The SAS only allows requests from the IP range it was signed with (sip), which doesn't include this machine's IP address, as the service sees it. " +
+			"Sign a SAS whose IP range includes it, or none at all."
+	case SAS_PROTOCOL_MISMATCH_SERVICE_CODE:
+		return "The SAS only allows requests over the protocol it was signed with (spr). Use an https:// URL, or sign a SAS that allows http."
+	default:
+		return ""
+	}
+}
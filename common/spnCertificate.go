@@ -0,0 +1,140 @@
+// Copyright © 2017 Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package common
+
+import (
+	"crypto"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"software.sslmate.com/src/go-pkcs12"
+)
+
+// errCertificateNoPrivateKey is returned for certificates which come without their private key,
+// such as Key Vault certificates whose key isn't exportable.
+var errCertificateNoPrivateKey = errors.New("found no private key in certificate")
+
+var errEncryptedPEMKey = errors.New("encrypted PEM private keys are not supported, please convert the certificate to PKCS#12 (.pfx) to protect it with a password")
+
+// parseClientCertificate loads the certificate chain and private key for service principal auth from either a PKCS#12
+// (.pfx/.p12) file, such as those exported from the Windows certificate store, or a PEM bundle.
+// PEM bundles aren't encrypted, so no password is needed for them, and any given is ignored.
+// The certificate matching the private key is returned first, followed by the rest of the chain.
+func parseClientCertificate(certPath string, certData []byte, password string) ([]*x509.Certificate, crypto.PrivateKey, error) {
+	if isPKCS12Certificate(certPath, certData) {
+		key, leaf, chain, err := pkcs12.DecodeChain(certData, password)
+		// go-pkcs12 has no error value for this case, only its message.
+		if err != nil && err.Error() == "pkcs12: private key missing" {
+			return nil, nil, errCertificateNoPrivateKey
+		}
+		if errors.Is(err, pkcs12.ErrIncorrectPassword) || errors.Is(err, pkcs12.ErrDecryption) {
+			return nil, nil, fmt.Errorf("incorrect password for certificate %s, please check the certificate password", certPath)
+		}
+		var notImplemented pkcs12.NotImplementedError
+		if errors.As(err, &notImplemented) {
+			return nil, nil, fmt.Errorf("unsupported PKCS#12 certificate %s, %v", certPath, err)
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("unsupported certificate format in %s, expected a PKCS#12 (.pfx/.p12) file or a PEM bundle, %v", certPath, err)
+		}
+		return append([]*x509.Certificate{leaf}, chain...), key, nil
+	}
+
+	return parsePEMCertificate(certData)
+}
+
+// isPKCS12Certificate reports whether a certificate should be decoded as PKCS#12 rather than PEM,
+// going by the file extension, or by the content when the extension is not a PKCS#12 one.
+func isPKCS12Certificate(certPath string, certData []byte) bool {
+	switch strings.ToLower(filepath.Ext(certPath)) {
+	case ".pfx", ".p12":
+		return true
+	}
+	return !strings.Contains(string(certData), "-----BEGIN")
+}
+
+func parsePEMCertificate(certData []byte) ([]*x509.Certificate, crypto.PrivateKey, error) {
+	var certs []*x509.Certificate
+	var key crypto.PrivateKey
+	for {
+		var block *pem.Block
+		block, certData = pem.Decode(certData)
+		if block == nil {
+			break
+		}
+
+		if strings.Contains(block.Headers["Proc-Type"], "ENCRYPTED") {
+			return nil, nil, errEncryptedPEMKey
+		}
+
+		var err error
+		switch block.Type {
+		case "CERTIFICATE":
+			var cert *x509.Certificate
+			if cert, err = x509.ParseCertificate(block.Bytes); err == nil {
+				certs = append(certs, cert)
+			}
+		case "PRIVATE KEY", "RSA PRIVATE KEY", "EC PRIVATE KEY":
+			if key != nil {
+				return nil, nil, errors.New("certificate file contains multiple private keys")
+			}
+			key, err = parsePEMPrivateKey(block)
+		case "ENCRYPTED PRIVATE KEY":
+			return nil, nil, errEncryptedPEMKey
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to parse %s block, %v", block.Type, err)
+		}
+	}
+
+	if len(certs) == 0 {
+		return nil, nil, errors.New("found no certificate in certificate file")
+	}
+	if key == nil {
+		return nil, nil, errCertificateNoPrivateKey
+	}
+
+	// Bundles don't always list the leaf certificate first, but it's the one identifying the service principal.
+	if signer, ok := key.(crypto.Signer); ok {
+		for i, cert := range certs {
+			if pub, ok := cert.PublicKey.(interface{ Equal(crypto.PublicKey) bool }); ok && pub.Equal(signer.Public()) {
+				certs[0], certs[i] = certs[i], certs[0]
+				break
+			}
+		}
+	}
+	return certs, key, nil
+}
+
+func parsePEMPrivateKey(block *pem.Block) (crypto.PrivateKey, error) {
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		return x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		return x509.ParseECPrivateKey(block.Bytes)
+	default:
+		return x509.ParsePKCS8PrivateKey(block.Bytes)
+	}
+}
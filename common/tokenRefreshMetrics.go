@@ -0,0 +1,82 @@
+// Copyright © 2017 Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package common
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// AuthMetrics counts the OAuth token refreshes made by this process, for the job summary.
+var AuthMetrics = &TokenRefreshMetrics{}
+
+// TokenRefreshMetrics counts token refreshes, their failures and latency, and how often a failed refresh fell back to
+// the current token. It's safe for concurrent use, and a nil *TokenRefreshMetrics records nothing.
+type TokenRefreshMetrics struct {
+	refreshes int64
+	failures  int64
+	fallbacks int64
+	// latency is the total time spent in refreshes, in nanoseconds.
+	latency int64
+}
+
+// TokenRefreshStats is a snapshot of TokenRefreshMetrics.
+type TokenRefreshStats struct {
+	Refreshes      int64
+	Failures       int64
+	Fallbacks      int64
+	AverageLatency time.Duration
+}
+
+// recordRefresh records a refresh which started at start, and failed if err is set.
+func (m *TokenRefreshMetrics) recordRefresh(start time.Time, err error) {
+	if m == nil {
+		return
+	}
+	atomic.AddInt64(&m.latency, int64(time.Since(start)))
+	atomic.AddInt64(&m.refreshes, 1)
+	if err != nil {
+		atomic.AddInt64(&m.failures, 1)
+	}
+}
+
+// recordFallback records a failed refresh which was covered for by the current token.
+func (m *TokenRefreshMetrics) recordFallback() {
+	if m == nil {
+		return
+	}
+	atomic.AddInt64(&m.fallbacks, 1)
+}
+
+func (m *TokenRefreshMetrics) Stats() TokenRefreshStats {
+	if m == nil {
+		return TokenRefreshStats{}
+	}
+	stats := TokenRefreshStats{
+		Refreshes: atomic.LoadInt64(&m.refreshes),
+		Failures:  atomic.LoadInt64(&m.failures),
+		Fallbacks: atomic.LoadInt64(&m.fallbacks),
+	}
+	if stats.Refreshes != 0 {
+		stats.AverageLatency = time.Duration(atomic.LoadInt64(&m.latency) / stats.Refreshes)
+	}
+	return stats
+}
@@ -0,0 +1,119 @@
+// Copyright © 2017 Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package common
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+)
+
+// tokenRefreshPolicy authorizes requests with tokens from cred, logging every acquisition and refresh.
+type tokenRefreshPolicy struct {
+	cred   azcore.TokenCredential
+	scopes []string
+
+	lock       sync.RWMutex
+	token      *azcore.AccessToken
+	acquiredAt time.Time
+}
+
+// NewTokenRefreshPolicy returns a per-retry policy which sets the Authorization header with tokens from cred.
+// Each token acquisition is logged with its scopes, expiry and credential kind, to help diagnose stalled auth.
+// When GlobalTestOAuthInjection.DoTokenRefreshInjection is set, the token is refreshed every TokenRefreshDuration
+// regardless of its expiry, so that tests can exercise refreshing without waiting for a token to expire.
+//
+// Add it to clients created without a credential, otherwise the SDK's bearer token policy acquires tokens as well.
+func NewTokenRefreshPolicy(cred azcore.TokenCredential, scopes []string) policy.Policy {
+	return &tokenRefreshPolicy{cred: cred, scopes: scopes}
+}
+
+func (p *tokenRefreshPolicy) Do(req *policy.Request) (*http.Response, error) {
+	token, err := p.getToken(req.Raw().Context())
+	if err != nil {
+		return nil, err
+	}
+
+	req.Raw().Header.Set("Authorization", "Bearer "+token)
+	return req.Next()
+}
+
+func (p *tokenRefreshPolicy) getToken(ctx context.Context) (string, error) {
+	p.lock.RLock()
+	if !p.needsRefresh() {
+		defer p.lock.RUnlock()
+		return p.token.Token, nil
+	}
+	p.lock.RUnlock()
+
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	// If someone else has updated the token while we waited above, we don't have to refresh again.
+	if !p.needsRefresh() {
+		return p.token.Token, nil
+	}
+
+	action := Iff(p.token == nil, "acquired", "refreshed")
+	start := time.Now()
+	token, err := p.cred.GetToken(ctx, policy.TokenRequestOptions{Scopes: p.scopes})
+	if err != nil {
+		logTokenRefresh(fmt.Sprintf("OAuth token could not be %s from %s for scopes %v after %v: %v",
+			action, credentialKind(p.cred), p.scopes, time.Since(start), err))
+		return "", err
+	}
+
+	p.token = &token
+	p.acquiredAt = time.Now()
+	logTokenRefresh(fmt.Sprintf("OAuth token %s from %s for scopes %v at %s in %v, expires at %s",
+		action, credentialKind(p.cred), p.scopes, p.acquiredAt.UTC().Format(time.RFC3339), p.acquiredAt.Sub(start), token.ExpiresOn.UTC().Format(time.RFC3339)))
+	return token.Token, nil
+}
+
+// needsRefresh must be called with the lock held.
+func (p *tokenRefreshPolicy) needsRefresh() bool {
+	if p.token == nil || time.Until(p.token.ExpiresOn) < minimumTokenValidDuration {
+		return true
+	}
+
+	injection := GlobalTestOAuthInjection
+	return injection.DoTokenRefreshInjection && time.Since(p.acquiredAt) >= injection.TokenRefreshDuration
+}
+
+// logTokenRefresh writes to the job log when there is one, or else reports through the lifecycle manager.
+func logTokenRefresh(msg string) {
+	if AzcopyCurrentJobLogger != nil {
+		AzcopyCurrentJobLogger.Log(LogInfo, msg)
+		return
+	}
+	lcm.Info(msg)
+}
+
+// credentialKind names the credential type, e.g. "ClientSecretCredential".
+func credentialKind(cred azcore.TokenCredential) string {
+	kind := fmt.Sprintf("%T", unwrapTokenCredential(cred))
+	return kind[strings.LastIndex(kind, ".")+1:]
+}
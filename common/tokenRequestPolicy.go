@@ -0,0 +1,140 @@
+// Copyright © 2017 Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package common
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+	"github.com/Azure/azure-sdk-for-go/sdk/azidentity"
+	"github.com/Azure/go-autorest/autorest/adal"
+)
+
+const (
+	defaultTokenRequestTimeout = 30 * time.Second
+	// Throttled and failed token requests are retried this many times before giving up.
+	maxTokenRequestRetries = 3
+)
+
+// tokenRequestRetryDelay is the backoff before the first retry of a token request, doubling for each one after.
+var tokenRequestRetryDelay = time.Second
+
+// tokenRequestTimeout is how long each token request may take, configurable in seconds through AZCOPY_TOKEN_REQUEST_TIMEOUT.
+func tokenRequestTimeout() time.Duration {
+	if seconds, err := strconv.Atoi(lcm.GetEnvironmentVariable(EEnvironmentVariable.TokenRequestTimeout())); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	return defaultTokenRequestTimeout
+}
+
+// requestToken requests a token for this login from tc under the policy of retryTokenRequest. Browser logins are
+// exempt, as they wait for the user to sign in.
+func (credInfo *OAuthTokenInfo) requestToken(ctx context.Context, tc azcore.TokenCredential, options policy.TokenRequestOptions) (azcore.AccessToken, error) {
+	if credInfo.InteractiveBrowserCred {
+		return tc.GetToken(ctx, options)
+	}
+	return getTokenWithRetry(ctx, tc, options, credInfo.authorityURL())
+}
+
+// getTokenWithRetry requests a token from tc under the policy of retryTokenRequest.
+func getTokenWithRetry(ctx context.Context, tc azcore.TokenCredential, options policy.TokenRequestOptions, authority string) (token azcore.AccessToken, err error) {
+	err = retryTokenRequest(ctx, authority, func(ctx context.Context) (err error) {
+		token, err = tc.GetToken(ctx, options)
+		return err
+	})
+	return token, err
+}
+
+// retryTokenRequest gives up on token requests which don't complete within tokenRequestTimeout, e.g. because the
+// authority is unreachable from a locked down network. Throttled requests and server errors are retried with
+// exponential backoff. authority is the endpoint being asked, and is reported if the request fails.
+func retryTokenRequest(ctx context.Context, authority string, request func(ctx context.Context) error) error {
+	timeout := tokenRequestTimeout()
+	for retry := 0; ; retry++ {
+		attemptCtx, cancel := context.WithTimeout(ctx, timeout)
+		err := request(attemptCtx)
+		timedOut := errors.Is(attemptCtx.Err(), context.DeadlineExceeded)
+		cancel()
+		if err == nil {
+			return nil
+		}
+
+		if timedOut && ctx.Err() == nil {
+			return fmt.Errorf("token request to %s did not complete within %v: %w", authority, timeout, err)
+		}
+		if retry >= maxTokenRequestRetries || !isTransientTokenError(err) {
+			if retry == 0 {
+				return fmt.Errorf("token request to %s failed: %w", authority, err)
+			}
+			return fmt.Errorf("token request to %s failed after %d attempts: %w", authority, retry+1, err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("token request to %s was cancelled: %w", authority, err)
+		case <-time.After(tokenRequestRetryDelay << retry):
+		}
+	}
+}
+
+// isTransientTokenError reports whether the authority throttled the token request or failed to serve it.
+func isTransientTokenError(err error) bool {
+	var resp *http.Response
+	var authErr *azidentity.AuthenticationFailedError
+	var respErr *azcore.ResponseError
+	var refreshErr adal.TokenRefreshError
+	switch {
+	case errors.As(err, &authErr):
+		resp = authErr.RawResponse
+	case errors.As(err, &respErr):
+		resp = respErr.RawResponse
+	case errors.As(err, &refreshErr):
+		resp = refreshErr.Response()
+	}
+	return resp != nil && (resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= http.StatusInternalServerError)
+}
+
+// authorityURL names the authority tokens are requested from, for errors.
+func (credInfo *OAuthTokenInfo) authorityURL() string {
+	if credInfo.Identity {
+		// App Service and Azure Arc point managed identities at their own endpoint, others use IMDS.
+		if endpoint := os.Getenv("IDENTITY_ENDPOINT"); endpoint != "" {
+			return endpoint
+		}
+		return "http://" + imdsHost + "/metadata/identity/oauth2/token"
+	}
+	c, err := credInfo.ResolveCloud()
+	if err != nil {
+		return credInfo.ActiveDirectoryEndpoint
+	}
+	u, err := getAuthorityURL(resolveTenantID(credInfo.Tenant), c.Configuration.ActiveDirectoryAuthorityHost)
+	if err != nil {
+		return c.Configuration.ActiveDirectoryAuthorityHost
+	}
+	return u.String()
+}
@@ -116,6 +116,9 @@ func GetServiceClientForLocation(loc Location,
 	locationSpecificOptions any,
 ) (*ServiceClient, error) {
 	ret := &ServiceClient{}
+	if credType.IsAzureOAuth() && cred != nil {
+		policyOptions = withClaimsChallengePolicy(policyOptions, cred, credType)
+	}
 	resourceURL, err := resource.String()
 	if err != nil {
 		return nil, fmt.Errorf("failed to get resource string: %w", err)
@@ -228,6 +231,18 @@ func GetServiceClientForLocation(loc Location,
 	}
 }
 
+// withClaimsChallengePolicy copies the client options, adding a policy which satisfies the claims challenges of
+// continuous access evaluation for clients authorized with cred.
+func withClaimsChallengePolicy(policyOptions *azcore.ClientOptions, cred azcore.TokenCredential, credType CredentialType) *azcore.ClientOptions {
+	var o azcore.ClientOptions
+	if policyOptions != nil {
+		o = *policyOptions
+	}
+	scope := Iff(credType == ECredentialType.MDOAuthToken(), ManagedDiskScope, StorageScope)
+	o.PerRetryPolicies = append(append([]policy.Policy{}, o.PerRetryPolicies...), NewClaimsChallengePolicy(cred, []string{scope}))
+	return &o
+}
+
 // ScopedCredential1 takes in a azcore.TokenCredential object & a list of scopes
 // and returns a function object. This function object on invocation returns
 // a bearer token with specified scope and is of format "Bearer + <Token>".
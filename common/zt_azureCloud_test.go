@@ -0,0 +1,218 @@
+// Copyright © 2017 Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package common
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestResolveAzureCloudAuthorityHosts(t *testing.T) {
+	a := assert.New(t)
+	expectedHosts := map[string]string{
+		AzurePublicCloud:       "https://login.microsoftonline.com/",
+		AzureUSGovernmentCloud: "https://login.microsoftonline.us/",
+		AzureChinaCloud:        "https://login.chinacloudapi.cn/",
+	}
+
+	for name, host := range expectedHosts {
+		c, err := ResolveAzureCloud(name)
+		a.Nil(err)
+		a.Equal(host, c.Configuration.ActiveDirectoryAuthorityHost)
+		a.Equal(StorageScope, c.StorageScope)
+
+		// The credentials built for the cloud must authenticate against its authority.
+		credInfo := &OAuthTokenInfo{Cloud: name, Tenant: DefaultTenantID}
+		tc, err := credInfo.GetDeviceCodeCredential()
+		a.Nil(err)
+		a.Equal(host, tc.(*DeviceCodeCredential).aadEndpoint)
+
+		// Token info persisted without a cloud name falls back to its AD endpoint.
+		inferred, err := (&OAuthTokenInfo{ActiveDirectoryEndpoint: strings.TrimSuffix(host, "/")}).ResolveCloud()
+		a.Nil(err)
+		a.Equal(name, inferred.Name)
+	}
+
+	_, err := ResolveAzureCloud("AzureGermany")
+	a.NotNil(err)
+}
+
+func TestResolveCloudKeepsCustomAuthority(t *testing.T) {
+	a := assert.New(t)
+	c, err := (&OAuthTokenInfo{ActiveDirectoryEndpoint: "https://login.contoso.local"}).ResolveCloud()
+	a.Nil(err)
+	a.Equal("https://login.contoso.local", c.Configuration.ActiveDirectoryAuthorityHost)
+	a.Equal(StorageScope, c.StorageScope)
+}
+
+func TestAzureCloudForAuthority(t *testing.T) {
+	a := assert.New(t)
+	testCases := []struct {
+		endpoint          string
+		expectedCloud     string
+		expectedAuthority string
+	}{
+		{"https://login.microsoftonline.com", AzurePublicCloud, "https://login.microsoftonline.com/"},
+		{"login.chinacloudapi.cn", AzureChinaCloud, "https://login.chinacloudapi.cn/"},
+		{"https://login.chinacloudapi.cn/", AzureChinaCloud, "https://login.chinacloudapi.cn/"},
+		{"https://login.microsoftonline.us", AzureUSGovernmentCloud, "https://login.microsoftonline.us/"},
+		// Azure Stack Hub authorities are not one of the known clouds, and are used as-is.
+		{"https://adfs.local.azurestack.external/adfs", "", "https://adfs.local.azurestack.external/adfs"},
+	}
+
+	for _, tc := range testCases {
+		c := azureCloudForAuthority(tc.endpoint)
+		a.Equal(tc.expectedCloud, c.Name, tc.endpoint)
+		a.Equal(tc.expectedAuthority, c.Configuration.ActiveDirectoryAuthorityHost, tc.endpoint)
+		a.Equal(StorageScope, c.StorageScope, tc.endpoint)
+	}
+}
+
+func TestResolveActiveDirectoryEndpointFromAzureCloud(t *testing.T) {
+	a := assert.New(t)
+
+	t.Setenv(EEnvironmentVariable.AzureCloud().Name, "AzureChinaCloud")
+	endpoint, err := resolveActiveDirectoryEndpoint("")
+	a.Nil(err)
+	a.Equal("https://login.chinacloudapi.cn", endpoint)
+
+	// An explicitly specified endpoint wins over the environment.
+	endpoint, err = resolveActiveDirectoryEndpoint("login.microsoftonline.us")
+	a.Nil(err)
+	a.Equal("https://login.microsoftonline.us", endpoint)
+
+	t.Setenv(EEnvironmentVariable.AzureCloud().Name, "AzureGermanCloud")
+	_, err = resolveActiveDirectoryEndpoint("")
+	a.NotNil(err)
+}
+
+func TestLoginSettingsPrecedence(t *testing.T) {
+	a := assert.New(t)
+	testCases := []struct {
+		explicit, azcopy, azure string
+		expected                string
+	}{
+		{expected: ""},
+		{azure: "azure", expected: "azure"},
+		{azcopy: "azcopy", expected: "azcopy"},
+		{azcopy: "azcopy", azure: "azure", expected: "azcopy"},
+		{explicit: "explicit", expected: "explicit"},
+		{explicit: "explicit", azure: "azure", expected: "explicit"},
+		{explicit: "explicit", azcopy: "azcopy", expected: "explicit"},
+		{explicit: "explicit", azcopy: "azcopy", azure: "azure", expected: "explicit"},
+	}
+
+	for _, tc := range testCases {
+		t.Setenv(EEnvironmentVariable.TenantID().Name, tc.azcopy)
+		t.Setenv(EEnvironmentVariable.AzureTenantID().Name, tc.azure)
+		expected := tc.expected
+		if expected == "" {
+			expected = DefaultTenantID
+		}
+		a.Equal(expected, resolveTenantID(tc.explicit), "tenant %+v", tc)
+
+		t.Setenv(EEnvironmentVariable.ApplicationID().Name, tc.azcopy)
+		t.Setenv(EEnvironmentVariable.AzureClientID().Name, tc.azure)
+		a.Equal(tc.expected, ResolveApplicationID(tc.explicit), "application %+v", tc)
+
+		t.Setenv(EEnvironmentVariable.AADEndpoint().Name, tc.azcopy)
+		t.Setenv(EEnvironmentVariable.AzureAuthorityHost().Name, tc.azure)
+		expected = DefaultActiveDirectoryEndpoint
+		if tc.expected != "" {
+			expected = "https://" + tc.expected
+		}
+		endpoint, err := resolveActiveDirectoryEndpoint(tc.explicit)
+		a.Nil(err)
+		a.Equal(expected, endpoint, "endpoint %+v", tc)
+	}
+}
+
+func TestResolveTenantAndEndpointPrecedence(t *testing.T) {
+	type setting struct {
+		variable EnvironmentVariable // the zero value for the explicit argument
+		value    string
+	}
+	tenants := []setting{
+		{value: "explicit-tenant"},
+		{EEnvironmentVariable.TenantID(), "azcopy-tenant"},
+		{EEnvironmentVariable.AzureTenantID(), "azure-tenant"},
+	}
+	endpoints := []setting{
+		{value: "https://explicit.endpoint"},
+		{EEnvironmentVariable.AADEndpoint(), "https://azcopy.endpoint"},
+		{EEnvironmentVariable.AuthEndpoint(), "https://alias.endpoint"},
+		{EEnvironmentVariable.AzureAuthorityHost(), "https://azure.endpoint"},
+	}
+
+	// apply sets the settings picked out by mask, and returns the argument to pass along with the value expected to win.
+	apply := func(t *testing.T, settings []setting, mask int, fallback string) (arg, expected string) {
+		expected = fallback
+		for i := len(settings) - 1; i >= 0; i-- {
+			value := ""
+			if mask&(1<<i) != 0 {
+				value, expected = settings[i].value, settings[i].value
+			}
+			if settings[i].variable.Name == "" {
+				arg = value
+			} else {
+				t.Setenv(settings[i].variable.Name, value)
+			}
+		}
+		return arg, expected
+	}
+
+	for tenantMask := 0; tenantMask < 1<<len(tenants); tenantMask++ {
+		for endpointMask := 0; endpointMask < 1<<len(endpoints); endpointMask++ {
+			t.Run(fmt.Sprintf("tenants%03b_endpoints%04b", tenantMask, endpointMask), func(t *testing.T) {
+				tenantArg, expectedTenant := apply(t, tenants, tenantMask, DefaultTenantID)
+				endpointArg, expectedEndpoint := apply(t, endpoints, endpointMask, DefaultActiveDirectoryEndpoint)
+
+				a := assert.New(t)
+				tenant, endpoint, err := resolveTenantAndEndpoint(tenantArg, endpointArg)
+				a.NoError(err)
+				a.Equal(expectedTenant, tenant)
+				a.Equal(expectedEndpoint, endpoint)
+			})
+		}
+	}
+}
+
+func TestResolveActiveDirectoryEndpointFromAuthorityHost(t *testing.T) {
+	a := assert.New(t)
+
+	// The authority host of a sovereign cloud selects that cloud, and wins over AZURE_CLOUD.
+	t.Setenv(EEnvironmentVariable.AzureCloud().Name, "AzureUSGovernment")
+	t.Setenv(EEnvironmentVariable.AzureAuthorityHost().Name, "https://login.chinacloudapi.cn/")
+	endpoint, err := resolveActiveDirectoryEndpoint("")
+	a.Nil(err)
+	a.Equal("https://login.chinacloudapi.cn", endpoint)
+	a.Equal(AzureChinaCloud, azureCloudForAuthority(endpoint).Name)
+
+	// AzCopy's own variable wins over the standard one.
+	t.Setenv(EEnvironmentVariable.AADEndpoint().Name, "login.microsoftonline.us")
+	endpoint, err = resolveActiveDirectoryEndpoint("")
+	a.Nil(err)
+	a.Equal("https://login.microsoftonline.us", endpoint)
+	a.Equal(AzureUSGovernmentCloud, azureCloudForAuthority(endpoint).Name)
+}
@@ -0,0 +1,381 @@
+// Copyright © 2017 Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package common
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+	"github.com/stretchr/testify/assert"
+)
+
+// scriptedCredential issues the tokens of its script in order, failing once the script runs out.
+type scriptedCredential struct {
+	lifetimes []time.Duration
+	calls     int
+}
+
+func (c *scriptedCredential) GetToken(_ context.Context, _ policy.TokenRequestOptions) (azcore.AccessToken, error) {
+	c.calls++
+	if c.calls > len(c.lifetimes) {
+		return azcore.AccessToken{}, errors.New("token endpoint unavailable")
+	}
+	return azcore.AccessToken{Token: "token-" + string(rune('0'+c.calls)), ExpiresOn: time.Now().Add(c.lifetimes[c.calls-1])}, nil
+}
+
+func TestBackgroundRefreshSchedule(t *testing.T) {
+	a := assert.New(t)
+	c := newBackgroundRefreshCredential(&scriptedCredential{}, 10*time.Minute)
+	within := func(expected, actual time.Duration) {
+		a.InDelta(float64(expected), float64(actual), float64(time.Second))
+	}
+
+	// Refresh ahead of the window, or halfway through the lifetime of short-lived tokens.
+	delay, ok := c.nextRefresh(&backgroundRefreshEntry{token: azcore.AccessToken{ExpiresOn: time.Now().Add(time.Hour)}})
+	a.True(ok)
+	within(50*time.Minute, delay)
+	delay, ok = c.nextRefresh(&backgroundRefreshEntry{token: azcore.AccessToken{ExpiresOn: time.Now().Add(12 * time.Minute)}})
+	a.True(ok)
+	within(6*time.Minute, delay)
+
+	// Failures back off exponentially up to a cap, for as long as the token is valid.
+	longLived := azcore.AccessToken{ExpiresOn: time.Now().Add(time.Hour)}
+	delay, _ = c.nextRefresh(&backgroundRefreshEntry{token: longLived, failures: 1})
+	a.Equal(minTokenRefreshBackoff, delay)
+	delay, _ = c.nextRefresh(&backgroundRefreshEntry{token: longLived, failures: 3})
+	a.Equal(4*minTokenRefreshBackoff, delay)
+	delay, _ = c.nextRefresh(&backgroundRefreshEntry{token: longLived, failures: 40})
+	a.Equal(maxTokenRefreshBackoff, delay)
+	_, ok = c.nextRefresh(&backgroundRefreshEntry{token: azcore.AccessToken{ExpiresOn: time.Now().Add(time.Second)}, failures: 1})
+	a.False(ok)
+}
+
+func TestBackgroundRefreshServesRefreshedToken(t *testing.T) {
+	a := assert.New(t)
+	cred := &scriptedCredential{lifetimes: []time.Duration{time.Hour, time.Hour}}
+	c := newBackgroundRefreshCredential(cred, 10*time.Minute)
+	c.Start(context.Background())
+	options := policy.TokenRequestOptions{Scopes: []string{StorageScope}}
+
+	token, err := c.GetToken(context.Background(), options)
+	a.Nil(err)
+	a.Equal("token-1", token.Token)
+
+	// Run the scheduled refresh now rather than waiting for it.
+	key := backgroundRefreshKey(options)
+	c.refresh(key, c.tokens[key])
+	token, err = c.GetToken(context.Background(), options)
+	a.Nil(err)
+	a.Equal("token-2", token.Token)
+	a.Equal(2, cred.calls)
+
+	// A failed refresh keeps the current token, and is retried with backoff.
+	c.refresh(key, c.tokens[key])
+	a.Equal(1, c.tokens[key].failures)
+	a.NotNil(c.tokens[key].timer)
+	token, err = c.GetToken(context.Background(), options)
+	a.Nil(err)
+	a.Equal("token-2", token.Token)
+	c.tokens[key].timer.Stop()
+}
+
+func TestBackgroundRefreshFallsBackToValidToken(t *testing.T) {
+	a := assert.New(t)
+	// The first token is already inside minimumTokenValidDuration, so the second GetToken tries to replace it.
+	cred := &scriptedCredential{lifetimes: []time.Duration{minimumTokenValidDuration / 2}}
+	c := newBackgroundRefreshCredential(cred, 10*time.Minute)
+	c.Start(context.Background())
+	options := policy.TokenRequestOptions{Scopes: []string{StorageScope}}
+
+	_, err := c.GetToken(context.Background(), options)
+	a.Nil(err)
+	token, err := c.GetToken(context.Background(), options)
+	a.Nil(err)
+	a.Equal("token-1", token.Token)
+	a.Equal(2, cred.calls)
+	c.tokens[backgroundRefreshKey(options)].timer.Stop()
+}
+
+func TestBackgroundRefreshKeepsClaimsToken(t *testing.T) {
+	a := assert.New(t)
+	c := newBackgroundRefreshCredential(&claimsCredential{}, 10*time.Minute)
+	options := policy.TokenRequestOptions{Scopes: []string{StorageScope}}
+
+	token, err := c.GetToken(context.Background(), options)
+	a.Nil(err)
+	a.Equal("stale", token.Token)
+
+	// The token satisfying a claims challenge replaces the revoked one for later requests.
+	options.Claims = testClaims
+	token, err = c.GetToken(context.Background(), options)
+	a.Nil(err)
+	a.Equal("fresh", token.Token)
+	options.Claims = ""
+	token, err = c.GetToken(context.Background(), options)
+	a.Nil(err)
+	a.Equal("fresh", token.Token)
+}
+
+func TestBackgroundRefreshStop(t *testing.T) {
+	a := assert.New(t)
+	cred := &scriptedCredential{lifetimes: []time.Duration{time.Hour, time.Hour, time.Hour}}
+	c := newBackgroundRefreshCredential(cred, 10*time.Minute)
+	c.Start(context.Background())
+	options := policy.TokenRequestOptions{Scopes: []string{StorageScope}}
+
+	_, err := c.GetToken(context.Background(), options)
+	a.Nil(err)
+	key := backgroundRefreshKey(options)
+	a.NotNil(c.tokens[key].timer)
+
+	c.Stop()
+	a.Nil(c.tokens[key].timer)
+
+	// A refresh whose timer fired as the credential was stopped does nothing.
+	c.refresh(key, c.tokens[key])
+	a.Equal(1, cred.calls)
+	a.Nil(c.tokens[key].timer)
+
+	// Tokens are still requested on demand, but no refresh is scheduled for them.
+	_, err = c.GetToken(context.Background(), policy.TokenRequestOptions{Scopes: []string{ManagedDiskScope}})
+	a.Nil(err)
+	a.Equal(2, cred.calls)
+	a.Nil(c.tokens[backgroundRefreshKey(policy.TokenRequestOptions{Scopes: []string{ManagedDiskScope}})].timer)
+}
+
+func TestBackgroundRefreshIsOptIn(t *testing.T) {
+	a := assert.New(t)
+	cred := &scriptedCredential{lifetimes: []time.Duration{time.Hour}}
+	c := newBackgroundRefreshCredential(cred, minimumTokenValidDuration)
+	options := policy.TokenRequestOptions{Scopes: []string{StorageScope}}
+	key := backgroundRefreshKey(options)
+
+	// Until started, tokens are only requested on demand.
+	_, err := c.GetToken(context.Background(), options)
+	a.Nil(err)
+	a.Nil(c.tokens[key].timer)
+	c.refresh(key, c.tokens[key])
+	a.Equal(1, cred.calls)
+
+	// Starting schedules the refresh of the tokens already held, minimumTokenValidDuration before they expire.
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	c.Start(ctx)
+	a.NotNil(c.tokens[key].timer)
+	delay, ok := c.nextRefresh(c.tokens[key])
+	a.True(ok)
+	a.InDelta(float64(time.Hour-minimumTokenValidDuration), float64(delay), float64(time.Second))
+}
+
+func TestBackgroundRefreshStopsWhenContextIsCancelled(t *testing.T) {
+	a := assert.New(t)
+	cred := &scriptedCredential{lifetimes: []time.Duration{time.Hour, time.Hour}}
+	c := newBackgroundRefreshCredential(cred, minimumTokenValidDuration)
+	options := policy.TokenRequestOptions{Scopes: []string{StorageScope}}
+	key := backgroundRefreshKey(options)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	c.Start(ctx)
+	_, err := c.GetToken(context.Background(), options)
+	a.Nil(err)
+	a.NotNil(c.tokens[key].timer)
+
+	cancel()
+	a.Eventually(func() bool {
+		c.lock.Lock()
+		defer c.lock.Unlock()
+		return c.stopped && c.tokens[key].timer == nil
+	}, time.Second, 10*time.Millisecond)
+
+	// No refresh runs once cancelled, and starting again doesn't revive the credential.
+	c.refresh(key, c.tokens[key])
+	c.Start(context.Background())
+	a.Equal(1, cred.calls)
+	a.Nil(c.tokens[key].timer)
+}
+
+func TestUserOAuthTokenManagerBackgroundRefreshIsOptIn(t *testing.T) {
+	a := assert.New(t)
+	newManager := func() (*UserOAuthTokenManager, *backgroundRefreshCredential) {
+		uotm := &UserOAuthTokenManager{}
+		uotm.SetTokenInfo(NewOAuthTokenInfoFromCredential(&scriptedCredential{lifetimes: []time.Duration{time.Hour}}, ""))
+		tokenInfo, err := uotm.GetTokenInfo(context.Background())
+		a.Nil(err)
+		tc, err := tokenInfo.GetTokenCredential()
+		a.Nil(err)
+		refresher := tc.(*backgroundRefreshCredential)
+		return uotm, refresher
+	}
+
+	_, refresher := newManager()
+	a.Nil(refresher.ctx)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	uotm, refresher := newManager()
+	uotm.EnableBackgroundRefresh(ctx)
+	_, err := uotm.GetTokenInfo(context.Background())
+	a.Nil(err)
+	a.Equal(ctx, refresher.ctx)
+	uotm.StopBackgroundRefresh()
+}
+
+func TestUserOAuthTokenManagerStopsDiscardedRefreshes(t *testing.T) {
+	a := assert.New(t)
+	newInfo := func() (*OAuthTokenInfo, *backgroundRefreshCredential) {
+		info := NewOAuthTokenInfoFromCredential(&scriptedCredential{lifetimes: []time.Duration{time.Hour}}, "")
+		a.Nil(info.StartBackgroundRefresh(context.Background()))
+		refresher := info.TokenCredential.(*backgroundRefreshCredential)
+		_, err := refresher.GetToken(context.Background(), policy.TokenRequestOptions{Scopes: []string{StorageScope}})
+		a.Nil(err)
+		return info, refresher
+	}
+	pending := func(c *backgroundRefreshCredential) bool {
+		c.lock.Lock()
+		defer c.lock.Unlock()
+		return c.tokens[backgroundRefreshKey(policy.TokenRequestOptions{Scopes: []string{StorageScope}})].timer != nil
+	}
+
+	first, firstRefresher := newInfo()
+	uotm := &UserOAuthTokenManager{}
+	uotm.SetTokenInfo(first)
+	uotm.scopeInfos = map[string]*OAuthTokenInfo{ManagedDiskScope: first} // shares the login's credential
+
+	// Setting the same login again keeps it refreshing.
+	uotm.SetTokenInfo(first)
+	a.True(pending(firstRefresher))
+
+	// Replacing the login stops the one discarded.
+	second, secondRefresher := newInfo()
+	uotm.SetTokenInfo(second)
+	a.False(pending(firstRefresher))
+	a.True(pending(secondRefresher))
+
+	// Stopping the manager keeps its login, but stops refreshing it.
+	uotm.StopBackgroundRefresh()
+	a.Equal(second, uotm.stashedInfo)
+	a.False(pending(secondRefresher))
+
+	third, thirdRefresher := newInfo()
+	uotm.stashedInfo = third
+	uotm.credCache = NewCredCache(CredCacheOptions{
+		DPAPIFilePath: ".",
+		KeyName:       "AzCopyOAuthTokenCacheStopRefreshTest",
+		ServiceName:   "AzCopyV10",
+		AccountName:   "AzCopyOAuthTokenCacheStopRefreshTest",
+	})
+	a.ErrorIs(uotm.RemoveCachedToken(), ErrNoCachedToken)
+	a.False(pending(thirdRefresher))
+}
+
+// gatedCredential blocks each request until released, recording how many requests it serves at once.
+type gatedCredential struct {
+	release  chan struct{}
+	calls    int32
+	active   int32
+	overlaps int32
+}
+
+func (c *gatedCredential) GetToken(_ context.Context, options policy.TokenRequestOptions) (azcore.AccessToken, error) {
+	atomic.AddInt32(&c.calls, 1)
+	if atomic.AddInt32(&c.active, 1) > 1 {
+		atomic.AddInt32(&c.overlaps, 1)
+	}
+	defer atomic.AddInt32(&c.active, -1)
+	<-c.release
+	return azcore.AccessToken{Token: options.Scopes[0], ExpiresOn: time.Now().Add(time.Hour)}, nil
+}
+
+func TestBackgroundRefreshCoalescesRequests(t *testing.T) {
+	a := assert.New(t)
+	cred := &gatedCredential{release: make(chan struct{})}
+	c := newBackgroundRefreshCredential(cred, 10*time.Minute)
+	defer c.Stop()
+	storage := policy.TokenRequestOptions{Scopes: []string{StorageScope}}
+
+	// A cached token is served while another request waits on the credential.
+	close(cred.release)
+	_, err := c.GetToken(context.Background(), policy.TokenRequestOptions{Scopes: []string{ManagedDiskScope}})
+	a.Nil(err)
+	cred.release = make(chan struct{})
+
+	wg := &sync.WaitGroup{}
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			token, err := c.GetToken(context.Background(), storage)
+			a.Nil(err)
+			a.Equal(StorageScope, token.Token)
+		}()
+	}
+	a.Eventually(func() bool { return atomic.LoadInt32(&cred.active) == 1 }, 5*time.Second, time.Millisecond)
+
+	token, err := c.GetToken(context.Background(), policy.TokenRequestOptions{Scopes: []string{ManagedDiskScope}})
+	a.Nil(err)
+	a.Equal(ManagedDiskScope, token.Token)
+
+	// Callers done waiting give up, without cancelling the request.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = c.GetToken(ctx, storage)
+	a.ErrorIs(err, context.Canceled)
+
+	close(cred.release)
+	wg.Wait()
+	a.EqualValues(2, atomic.LoadInt32(&cred.calls), "the waiting callers share a single request")
+}
+
+func TestBackgroundRefreshSerializesRequests(t *testing.T) {
+	a := assert.New(t)
+	cred := &gatedCredential{release: make(chan struct{})}
+	c := newBackgroundRefreshCredential(cred, 10*time.Minute)
+	defer c.Stop()
+	close(cred.release)
+
+	// Foreground requests for different scopes, background refreshes, and requests made around the refresher
+	// (as OAuthTokenInfo.Refresh does for device code logins) never reach the credential at once.
+	wg := &sync.WaitGroup{}
+	for i := 0; i < 20; i++ {
+		options := policy.TokenRequestOptions{Scopes: []string{[]string{StorageScope, ManagedDiskScope}[i%2]}, Claims: []string{"", testClaims}[i%3%2]}
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			_, err := c.GetToken(context.Background(), options)
+			a.Nil(err)
+		}()
+		go func() {
+			defer wg.Done()
+			a.Nil(serializeTokenRequest(c, func() error {
+				_, err := cred.GetToken(context.Background(), options)
+				return err
+			}))
+		}()
+	}
+	wg.Wait()
+	a.Zero(atomic.LoadInt32(&cred.overlaps))
+}
@@ -0,0 +1,146 @@
+// Copyright © 2017 Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package common
+
+import (
+	"context"
+	"encoding/base64"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/runtime"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/streaming"
+	"github.com/stretchr/testify/assert"
+)
+
+const testClaims = `{"access_token":{"nbf":{"essential":true,"value":"1700000000"}}}`
+
+// claimsCredential issues a stale token, or a fresh one when asked to satisfy claims.
+type claimsCredential struct {
+	claims []string
+}
+
+func (c *claimsCredential) GetToken(_ context.Context, options policy.TokenRequestOptions) (azcore.AccessToken, error) {
+	c.claims = append(c.claims, options.Claims)
+	token := "stale"
+	if options.Claims != "" {
+		token = "fresh"
+	}
+	return azcore.AccessToken{Token: token, ExpiresOn: time.Now().Add(time.Hour)}, nil
+}
+
+func TestParseClaimsChallenge(t *testing.T) {
+	a := assert.New(t)
+	encoded := base64.StdEncoding.EncodeToString([]byte(testClaims))
+
+	a.Equal(testClaims, parseClaimsChallenge(`Bearer realm="", authorization_uri="https://login.microsoftonline.com/common/oauth2/authorize", error="insufficient_claims", claims="`+encoded+`"`))
+	a.Equal(testClaims, parseClaimsChallenge(`Bearer error="insufficient_claims", claims="`+strings.TrimRight(encoded, "=")+`"`))
+
+	// Challenges which aren't for claims are left to the SDK.
+	a.Empty(parseClaimsChallenge(`Bearer authorization_uri="https://login.microsoftonline.com/tenant/oauth2/authorize", resource_id="https://storage.azure.com"`))
+	a.Empty(parseClaimsChallenge(`Bearer error="invalid_token", claims="` + encoded + `"`))
+	a.Empty(parseClaimsChallenge(`Basic realm="storage"`))
+	a.Empty(parseClaimsChallenge(""))
+}
+
+func TestClaimsChallengePolicyReplaysWithNewToken(t *testing.T) {
+	a := assert.New(t)
+	challenge := `Bearer authorization_uri="https://login.microsoftonline.com/common/oauth2/authorize", error="insufficient_claims", claims="` +
+		base64.StdEncoding.EncodeToString([]byte(testClaims)) + `"`
+
+	var authorizations, bodies []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		authorizations = append(authorizations, r.Header.Get("Authorization"))
+		bodies = append(bodies, string(body))
+		if r.Header.Get("Authorization") != "Bearer fresh" {
+			w.Header().Set("WWW-Authenticate", challenge)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer server.Close()
+
+	cred := &claimsCredential{}
+	pl := runtime.NewPipeline("azcopy", "test", runtime.PipelineOptions{}, &policy.ClientOptions{
+		Retry:            policy.RetryOptions{MaxRetries: -1},
+		PerRetryPolicies: []policy.Policy{staleAuthPolicy{}, NewClaimsChallengePolicy(cred, []string{StorageScope})},
+	})
+
+	send := func() *http.Response {
+		req, err := runtime.NewRequest(context.Background(), http.MethodPut, server.URL)
+		a.Nil(err)
+		a.Nil(req.SetBody(streaming.NopCloser(strings.NewReader("block")), "application/octet-stream"))
+		resp, err := pl.Do(req)
+		a.Nil(err)
+		return resp
+	}
+
+	// The challenge is satisfied with a new token, and the request replayed once with its body.
+	a.Equal(http.StatusCreated, send().StatusCode)
+	a.Equal([]string{"Bearer stale", "Bearer fresh"}, authorizations)
+	a.Equal([]string{"block", "block"}, bodies)
+	a.Equal([]string{testClaims}, cred.claims)
+
+	// Later requests carry the new token right away.
+	a.Equal(http.StatusCreated, send().StatusCode)
+	a.Equal("Bearer fresh", authorizations[2])
+	a.Len(cred.claims, 1)
+}
+
+func TestClaimsChallengePolicyIgnoresOtherFailures(t *testing.T) {
+	a := assert.New(t)
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Header().Set("WWW-Authenticate", `Bearer authorization_uri="https://login.microsoftonline.com/tenant/oauth2/authorize", resource_id="https://storage.azure.com"`)
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer server.Close()
+
+	cred := &claimsCredential{}
+	pl := runtime.NewPipeline("azcopy", "test", runtime.PipelineOptions{}, &policy.ClientOptions{
+		Retry:            policy.RetryOptions{MaxRetries: -1},
+		PerRetryPolicies: []policy.Policy{NewClaimsChallengePolicy(cred, []string{StorageScope})},
+	})
+	req, err := runtime.NewRequest(context.Background(), http.MethodGet, server.URL)
+	a.Nil(err)
+	resp, err := pl.Do(req)
+	a.Nil(err)
+	a.Equal(http.StatusUnauthorized, resp.StatusCode)
+	a.Equal(1, requests)
+	a.Empty(cred.claims)
+}
+
+// staleAuthPolicy stands in for the SDK's bearer token policy, which holds on to a revoked token.
+type staleAuthPolicy struct{}
+
+func (staleAuthPolicy) Do(req *policy.Request) (*http.Response, error) {
+	req.Raw().Header.Set("Authorization", "Bearer stale")
+	return req.Next()
+}
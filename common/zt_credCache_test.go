@@ -21,9 +21,16 @@
 package common
 
 import (
+	"context"
 	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
 	"testing"
+	"time"
 
+	"github.com/Azure/azure-sdk-for-go/sdk/azidentity"
 	"github.com/Azure/go-autorest/autorest/adal"
 )
 
@@ -101,4 +108,179 @@ func TestCredCacheSaveLoadDeleteHas(t *testing.T) {
 	// Test has cached token, and validate remove token.
 	hasCachedToken, err = credCache.HasCachedToken()
 	a.False(hasCachedToken)
-}
\ No newline at end of file
+}
+func TestUserOAuthTokenManagerRemoveWithoutCachedToken(t *testing.T) {
+	a := assert.New(t)
+	uotm := NewUserOAuthTokenManagerInstance(CredCacheOptions{
+		DPAPIFilePath: ".",
+		KeyName:       "AzCopyOAuthTokenCacheRemoveTest",
+		ServiceName:   "AzCopyV10",
+		AccountName:   "AzCopyOAuthTokenCacheRemoveTest",
+	})
+
+	hasCachedToken, _ := uotm.HasCachedToken()
+	a.False(hasCachedToken)
+
+	// Removing a token that was never cached reports there was nothing to remove.
+	err := uotm.RemoveCachedToken()
+	a.ErrorIs(err, ErrNoCachedToken)
+	a.NotErrorIs(err, ErrTokenRemovalFailed)
+
+	a.Nil(uotm.credCache.SaveToken(fakeTokenInfo))
+	a.Nil(uotm.RemoveCachedToken())
+
+	hasCachedToken, _ = uotm.HasCachedToken()
+	a.False(hasCachedToken)
+}
+
+func TestUserOAuthTokenManagerRemoveForgetsStashedToken(t *testing.T) {
+	a := assert.New(t)
+	uotm := NewUserOAuthTokenManagerInstance(CredCacheOptions{
+		DPAPIFilePath: ".",
+		KeyName:       "AzCopyOAuthTokenCacheRemoveStashTest",
+		ServiceName:   "AzCopyV10",
+		AccountName:   "AzCopyOAuthTokenCacheRemoveStashTest",
+	})
+	uotm.SetTokenInfo(&fakeTokenInfo)
+	stashedEnvOAuthTokenExists = true
+	defer func() { stashedEnvOAuthTokenExists = false }()
+
+	a.ErrorIs(uotm.RemoveAllCachedTokens(), ErrNoCachedToken)
+	a.Nil(uotm.stashedInfo)
+	a.False(stashedEnvOAuthTokenExists)
+}
+
+func TestCredCacheRehydratesTokenCredential(t *testing.T) {
+	a := assert.New(t)
+	credCache := NewCredCache(CredCacheOptions{
+		DPAPIFilePath: ".",
+		KeyName:       "AzCopyOAuthTokenCacheRehydrateTest",
+		ServiceName:   "AzCopyV10",
+		AccountName:   "AzCopyOAuthTokenCacheRehydrateTest",
+	})
+	defer func() { _ = credCache.RemoveCachedToken() }()
+
+	deviceCodeInfo := fakeTokenInfo
+	_, err := deviceCodeInfo.GetTokenCredential()
+	a.Nil(err)
+	a.Nil(credCache.SaveToken(deviceCodeInfo))
+
+	// The live credential must never be persisted, but must be rebuilt on load.
+	token, err := credCache.LoadToken()
+	a.Nil(err)
+	a.Nil(token.TokenCredential)
+	tc, err := token.GetTokenCredential()
+	a.Nil(err)
+	dcc, ok := tc.(*backgroundRefreshCredential).cred.(*DeviceCodeCredential)
+	a.True(ok)
+	a.Equal(fakeTokenInfo.RefreshToken, dcc.token.RefreshToken)
+
+	spnInfo := OAuthTokenInfo{
+		ServicePrincipalName:    true,
+		Tenant:                  "00000000-0000-0000-0000-000000000000",
+		ActiveDirectoryEndpoint: DefaultActiveDirectoryEndpoint,
+		ApplicationID:           "00000000-0000-0000-0000-000000000001",
+		SPNInfo:                 SPNInfo{Secret: "secret"},
+	}
+	a.Nil(credCache.SaveToken(spnInfo))
+	token, err = credCache.LoadToken()
+	a.Nil(err)
+	tc, err = token.GetTokenCredential()
+	a.Nil(err)
+	_, ok = tc.(*backgroundRefreshCredential).cred.(*azidentity.ClientSecretCredential)
+	a.True(ok)
+}
+
+func TestGetCachedTokenInfoDoesNotPersistIdentityAccessToken(t *testing.T) {
+	a := assert.New(t)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"access_token":"msi-token","expires_on":"` + strconv.FormatInt(time.Now().Add(time.Hour).Unix(), 10) + `"}`))
+	}))
+	defer srv.Close()
+
+	uotm := NewUserOAuthTokenManagerInstance(CredCacheOptions{
+		DPAPIFilePath: ".",
+		KeyName:       "AzCopyOAuthTokenCacheIdentityTest",
+		ServiceName:   "AzCopyV10",
+		AccountName:   "AzCopyOAuthTokenCacheIdentityTest",
+	})
+	a.Nil(uotm.credCache.SaveToken(OAuthTokenInfo{
+		Identity:                true,
+		IdentityInfo:            IdentityInfo{Endpoint: srv.URL},
+		Tenant:                  DefaultTenantID,
+		ActiveDirectoryEndpoint: DefaultActiveDirectoryEndpoint,
+	}))
+	defer func() { _ = uotm.RemoveCachedToken() }()
+
+	tokenInfo, err := uotm.getCachedTokenInfo(context.Background())
+	a.Nil(err)
+	a.Equal("msi-token", tokenInfo.AccessToken)
+
+	// Only device code logins are saved again once refreshed; the identity's access token stays in memory.
+	cached, err := uotm.credCache.LoadToken()
+	a.Nil(err)
+	a.Empty(cached.AccessToken)
+}
+
+func TestUserOAuthTokenManagerLoginStatus(t *testing.T) {
+	a := assert.New(t)
+	uotm := NewUserOAuthTokenManagerInstance(CredCacheOptions{
+		DPAPIFilePath: ".",
+		KeyName:       "AzCopyOAuthTokenCacheStatusTest",
+		ServiceName:   "AzCopyV10",
+		AccountName:   "AzCopyOAuthTokenCacheStatusTest",
+	})
+
+	status, err := uotm.GetLoginStatus()
+	a.Nil(err)
+	a.False(status.LoggedIn)
+
+	// fakeTokenInfo's access token expired long ago, but is still cached.
+	a.Nil(uotm.credCache.SaveToken(fakeTokenInfo))
+	defer func() { _ = uotm.RemoveCachedToken() }()
+	status, err = uotm.GetLoginStatus()
+	a.Nil(err)
+	a.True(status.LoggedIn)
+	a.True(status.Expired)
+	a.True(status.Refreshable, "the device code login's refresh token renews it silently")
+	a.Equal("DeviceCode", status.CredentialType)
+	a.Equal(fakeTokenInfo.Tenant, status.TenantID)
+	a.Equal(fakeTokenInfo.Expires(), *status.ExpiresOn)
+
+	// SPN logins don't persist an access token, so they have no expiry to report.
+	uotm.stashedInfo = &OAuthTokenInfo{ServicePrincipalName: true, Tenant: "tenant", ApplicationID: "app"}
+	status, err = uotm.GetLoginStatus()
+	a.Nil(err)
+	a.True(status.LoggedIn)
+	a.False(status.Expired)
+	a.Nil(status.ExpiresOn)
+	a.Equal("ServicePrincipal", status.CredentialType)
+	a.Equal("app", status.ApplicationID)
+}
+
+func TestUserOAuthTokenManagerLoginStatusFromEnvVar(t *testing.T) {
+	a := assert.New(t)
+	uotm := NewUserOAuthTokenManagerInstance(CredCacheOptions{
+		DPAPIFilePath: ".",
+		KeyName:       "AzCopyOAuthTokenCacheEnvStatusTest",
+		ServiceName:   "AzCopyV10",
+		AccountName:   "AzCopyOAuthTokenCacheEnvStatusTest",
+	})
+
+	expiresOn := time.Now().Add(-time.Minute).Truncate(time.Second)
+	t.Setenv("AZCOPY_OAUTH_TOKEN_INFO", `{"_token_refresh_source":"tokenstore","_tenant":"tenant","access_token":"stored","refresh_token":"unused","expires_on":"`+strconv.FormatInt(expiresOn.Unix(), 10)+`"}`)
+	status, err := uotm.GetLoginStatus()
+	a.Nil(err)
+	a.True(status.LoggedIn)
+	a.Equal("TokenStore", status.CredentialType)
+	a.Equal("tenant", status.TenantID)
+	if hasToken, _ := tokenStoreCredCache.HasCachedToken(); !hasToken {
+		a.True(status.Expired)
+		a.False(status.Refreshable, "token store tokens are only renewed by the integration")
+	}
+	a.NotEmpty(os.Getenv("AZCOPY_OAUTH_TOKEN_INFO"), "checking the status doesn't consume the token info")
+
+	t.Setenv("AZCOPY_OAUTH_TOKEN_INFO", "{")
+	_, err = uotm.GetLoginStatus()
+	a.ErrorContains(err, "unmarshal")
+}
@@ -0,0 +1,164 @@
+// Copyright © 2017 Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package common
+
+import (
+	"context"
+	"encoding/json"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/go-autorest/autorest/adal"
+	"github.com/stretchr/testify/assert"
+)
+
+// credentialLink returns a chain link logging in with cred, or an unconfigured link when cred is nil.
+func credentialLink(name string, cred azcore.TokenCredential, tried *[]string) credentialChainLink {
+	return credentialChainLink{name: name, tokenInfo: func(tenantID string) *OAuthTokenInfo {
+		*tried = append(*tried, name)
+		if cred == nil {
+			return nil
+		}
+		return NewOAuthTokenInfoFromCredential(cred, tenantID)
+	}}
+}
+
+func TestCredentialChainLoginUsesFirstWorkingLink(t *testing.T) {
+	a := assert.New(t)
+	var tried []string
+	defer func(links []credentialChainLink) { credentialChainLinks = links }(credentialChainLinks)
+	credentialChainLinks = []credentialChainLink{
+		credentialLink("Failing", &scriptedCredential{}, &tried),
+		credentialLink("Unconfigured", nil, &tried),
+		credentialLink("Working", &countingCredential{}, &tried),
+		credentialLink("Unreached", &countingCredential{}, &tried),
+	}
+
+	uotm := &UserOAuthTokenManager{}
+	a.Nil(uotm.CredentialChainLogin("", false))
+	a.Equal([]string{"Failing", "Unconfigured", "Working"}, tried)
+	a.Equal("Working", uotm.stashedInfo.CredentialChainLink)
+
+	// Once a link has logged in, it's reused rather than walking the chain again.
+	a.Nil(uotm.CredentialChainLogin("", false))
+	a.Len(tried, 3)
+}
+
+func TestCredentialChainLoginResumesWithPersistedLink(t *testing.T) {
+	a := assert.New(t)
+	t.Setenv("AZCOPY_DEVICE_CODE_CACHE", "")
+	var tried []string
+	defer func(links []credentialChainLink) { credentialChainLinks = links }(credentialChainLinks)
+	// Unlike credentials handed to AzCopy, bearer tokens can be persisted.
+	expiresOn := json.Number(strconv.FormatInt(time.Now().Add(time.Hour).Unix(), 10))
+	bearerLink := func(name string, configured bool) credentialChainLink {
+		return credentialChainLink{name: name, tokenInfo: func(tenantID string) *OAuthTokenInfo {
+			tried = append(tried, name)
+			if !configured {
+				return nil
+			}
+			return &OAuthTokenInfo{Token: adal.Token{AccessToken: name, ExpiresOn: expiresOn}, Tenant: tenantID, TokenRefreshSource: TokenRefreshSourceBearer}
+		}}
+	}
+	credentialChainLinks = []credentialChainLink{
+		bearerLink("Unconfigured", false),
+		bearerLink("Working", true),
+		bearerLink("Unreached", true),
+	}
+
+	options := CredCacheOptions{
+		DPAPIFilePath: t.TempDir(),
+		KeyName:       "AzCopyCredentialChainTest",
+		ServiceName:   "AzCopyV10Test",
+		AccountName:   "AzCopyCredentialChainTest",
+	}
+	uotm := NewUserOAuthTokenManagerInstance(options)
+	if err := uotm.CredentialChainLogin("", true); err != nil {
+		t.Skipf("secure store unavailable: %v", err)
+	}
+	defer func() { _ = uotm.RemoveCachedToken() }()
+	a.Equal([]string{"Unconfigured", "Working"}, tried)
+
+	// A later process goes straight to the link which logged in, without probing the others.
+	tried = nil
+	tokenInfo, err := NewUserOAuthTokenManagerInstance(options).GetTokenInfo(context.Background())
+	a.NoError(err)
+	a.Equal("Working", tokenInfo.CredentialChainLink)
+	a.Equal("Working", tokenInfo.AccessToken)
+	a.Equal([]string{"Working"}, tried)
+
+	// Once the environment no longer configures that link, the user has to log in again.
+	credentialChainLinks[1] = bearerLink("Working", false)
+	_, err = NewUserOAuthTokenManagerInstance(options).GetTokenInfo(context.Background())
+	a.ErrorIs(err, ErrNoCachedToken)
+}
+
+func TestCredentialChainLoginReportsEveryFailure(t *testing.T) {
+	a := assert.New(t)
+	var tried []string
+	defer func(links []credentialChainLink) { credentialChainLinks = links }(credentialChainLinks)
+	credentialChainLinks = []credentialChainLink{
+		credentialLink("First", &scriptedCredential{}, &tried),
+		credentialLink("Second", &scriptedCredential{}, &tried),
+	}
+
+	err := (&UserOAuthTokenManager{}).CredentialChainLogin("", false)
+	a.ErrorContains(err, "First: token request to https://login.microsoftonline.com/common failed: token endpoint unavailable")
+	a.ErrorContains(err, "Second: token request to https://login.microsoftonline.com/common failed: token endpoint unavailable")
+
+	// Without any credential configured, it's as if nobody logged in.
+	credentialChainLinks = []credentialChainLink{credentialLink("Unconfigured", nil, &tried)}
+	a.ErrorIs((&UserOAuthTokenManager{}).CredentialChainLogin("", false), ErrNoCachedToken)
+}
+
+func TestEnvironmentServicePrincipalLogin(t *testing.T) {
+	a := assert.New(t)
+	for _, name := range []string{"AZCOPY_SPA_APPLICATION_ID", "AZURE_CLIENT_ID", "AZCOPY_SPA_CLIENT_SECRET", "AZURE_CLIENT_SECRET",
+		"AZCOPY_SPA_CERT_PATH", "AZURE_CLIENT_CERTIFICATE_PATH", "AZCOPY_SPA_CERT_PASSWORD", "AZURE_CLIENT_CERTIFICATE_PASSWORD"} {
+		t.Setenv(name, "")
+	}
+	a.Nil(environmentServicePrincipalLogin("tenant"))
+
+	// An application without a secret or certificate isn't a service principal login.
+	t.Setenv("AZURE_CLIENT_ID", "azure-app")
+	a.Nil(environmentServicePrincipalLogin("tenant"))
+
+	t.Setenv("AZURE_CLIENT_CERTIFICATE_PATH", "cert.pfx")
+	t.Setenv("AZURE_CLIENT_CERTIFICATE_PASSWORD", "password")
+	tokenInfo := environmentServicePrincipalLogin("tenant")
+	a.True(tokenInfo.ServicePrincipalName)
+	a.Equal("azure-app", tokenInfo.ApplicationID)
+	a.True(filepath.IsAbs(tokenInfo.SPNInfo.CertPath))
+	a.Equal("password", tokenInfo.SPNInfo.Secret)
+
+	// Secrets win over certificates, and AzCopy's variables over the standard ones.
+	t.Setenv("AZURE_CLIENT_SECRET", "azure-secret")
+	t.Setenv("AZCOPY_SPA_CLIENT_SECRET", "azcopy-secret")
+	t.Setenv("AZCOPY_SPA_APPLICATION_ID", "azcopy-app")
+	tokenInfo = environmentServicePrincipalLogin("tenant")
+	a.Equal("azcopy-app", tokenInfo.ApplicationID)
+	a.Equal("azcopy-secret", tokenInfo.SPNInfo.Secret)
+	a.Empty(tokenInfo.SPNInfo.CertPath)
+	a.Equal("tenant", tokenInfo.Tenant)
+}
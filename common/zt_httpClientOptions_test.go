@@ -0,0 +1,254 @@
+// Copyright © 2017 Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package common
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHTTPClientOptionsWithEnvironment(t *testing.T) {
+	a := assert.New(t)
+	t.Setenv("AZCOPY_DIAL_TIMEOUT", "")
+	t.Setenv("AZCOPY_TLS_HANDSHAKE_TIMEOUT", "")
+	t.Setenv("AZCOPY_MAX_IDLE_CONNS_PER_HOST", "")
+	a.Equal(defaultTokenHTTPClientOptions, defaultTokenHTTPClientOptions.WithEnvironment())
+
+	t.Setenv("AZCOPY_DIAL_TIMEOUT", "45")
+	t.Setenv("AZCOPY_TLS_HANDSHAKE_TIMEOUT", "60")
+	t.Setenv("AZCOPY_MAX_IDLE_CONNS_PER_HOST", "16")
+	options := defaultTokenHTTPClientOptions.WithEnvironment()
+	a.Equal(45*time.Second, options.DialTimeout)
+	a.Equal(60*time.Second, options.TLSHandshakeTimeout)
+	a.Equal(16, options.MaxIdleConnsPerHost)
+	a.Equal(defaultTokenHTTPClientOptions.IdleConnTimeout, options.IdleConnTimeout)
+
+	transport := options.NewTransport(options.Dialer().DialContext)
+	a.Equal(60*time.Second, transport.TLSHandshakeTimeout)
+	a.Equal(16, transport.MaxIdleConnsPerHost)
+
+	// Invalid values keep the defaults.
+	t.Setenv("AZCOPY_DIAL_TIMEOUT", "soon")
+	a.Equal(defaultTokenHTTPClientOptions.DialTimeout, defaultTokenHTTPClientOptions.WithEnvironment().DialTimeout)
+}
+
+func TestHTTPClientOptionsAbortDialOnCancel(t *testing.T) {
+	a := assert.New(t)
+	options := defaultTokenHTTPClientOptions
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	a.Nil(err)
+	defer listener.Close()
+
+	// Hold every connection attempt mid-dial until its context is done, like a network which doesn't answer.
+	dialer := options.Dialer()
+	dialer.ControlContext = func(ctx context.Context, _, _ string, _ syscall.RawConn) error {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(5 * time.Second):
+			return nil
+		}
+	}
+	transport := options.NewTransport(dialer.DialContext)
+	transport.Proxy = nil
+	client := &http.Client{Transport: transport}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(100*time.Millisecond, cancel)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://"+listener.Addr().String()+"/", nil)
+	a.Nil(err)
+
+	start := time.Now()
+	_, err = client.Do(req)
+	a.ErrorIs(err, context.Canceled)
+	a.Less(time.Since(start), 2*time.Second)
+}
+
+func TestAzcopyHTTPClientDialsWithContext(t *testing.T) {
+	a := assert.New(t)
+	transport := newAzcopyHTTPClient().Transport.(*http.Transport)
+	a.NotNil(transport.DialContext)
+	a.Nil(transport.Dial) //nolint:staticcheck
+}
+
+// writePEM writes the PEM blocks of the given type and contents to a file in dir.
+func writePEM(t *testing.T, dir, name, blockType string, der []byte) string {
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// authClientGet requests url with the auth HTTP client, bypassing any proxy configured on the machine.
+func authClientGet(url string) error {
+	client := newAzcopyHTTPClient()
+	if transport, ok := client.Transport.(*http.Transport); ok {
+		transport.Proxy = nil
+	}
+	resp, err := client.Get(url)
+	if err == nil {
+		resp.Body.Close()
+	}
+	return err
+}
+
+func TestAzcopyHTTPClientHonorsCABundle(t *testing.T) {
+	a := assert.New(t)
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+	dir := t.TempDir()
+
+	// The test server's self-signed certificate isn't trusted by default.
+	t.Setenv(EEnvironmentVariable.AuthCABundle().Name, "")
+	var unknownAuthority x509.UnknownAuthorityError
+	a.ErrorAs(authClientGet(srv.URL), &unknownAuthority)
+
+	t.Setenv(EEnvironmentVariable.AuthCABundle().Name, writePEM(t, dir, "ca.pem", "CERTIFICATE", srv.Certificate().Raw))
+	a.Nil(authClientGet(srv.URL))
+
+	// A bundle which can't be loaded fails requests instead of being ignored.
+	t.Setenv(EEnvironmentVariable.AuthCABundle().Name, filepath.Join(dir, "missing.pem"))
+	a.ErrorContains(authClientGet(srv.URL), "failed to read CA bundle")
+	t.Setenv(EEnvironmentVariable.AuthCABundle().Name, writePEM(t, dir, "empty.pem", "NOT A CERTIFICATE", []byte("junk")))
+	a.ErrorContains(authClientGet(srv.URL), "no PEM certificates found")
+}
+
+func TestAzcopyHTTPClientPresentsClientCertificate(t *testing.T) {
+	a := assert.New(t)
+	dir := t.TempDir()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	a.Nil(err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "azcopy-test-client"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	a.Nil(err)
+	clientCert, err := x509.ParseCertificate(der)
+	a.Nil(err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	a.Nil(err)
+
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		a.Equal("azcopy-test-client", r.TLS.PeerCertificates[0].Subject.CommonName)
+	}))
+	clientCAs := x509.NewCertPool()
+	clientCAs.AddCert(clientCert)
+	srv.TLS = &tls.Config{ClientAuth: tls.RequireAndVerifyClientCert, ClientCAs: clientCAs}
+	srv.StartTLS()
+	defer srv.Close()
+
+	t.Setenv(EEnvironmentVariable.AuthCABundle().Name, writePEM(t, dir, "ca.pem", "CERTIFICATE", srv.Certificate().Raw))
+	t.Setenv(EEnvironmentVariable.AuthClientCertificate().Name, "")
+	t.Setenv(EEnvironmentVariable.AuthClientKey().Name, "")
+	a.NotNil(authClientGet(srv.URL))
+
+	t.Setenv(EEnvironmentVariable.AuthClientCertificate().Name, writePEM(t, dir, "client.pem", "CERTIFICATE", der))
+	t.Setenv(EEnvironmentVariable.AuthClientKey().Name, writePEM(t, dir, "client.key", "EC PRIVATE KEY", keyDER))
+	a.Nil(authClientGet(srv.URL))
+
+	// A key without a certificate is a misconfiguration.
+	_, err = LoadTLSConfig("", "", filepath.Join(dir, "client.key"))
+	a.NotNil(err)
+}
+
+func TestAuthProxyLookupBypassesIMDS(t *testing.T) {
+	a := assert.New(t)
+	proxy, _ := url.Parse("http://corporate-proxy:3128")
+	lookup := func(*http.Request) (*url.URL, error) { return proxy, nil }
+	imdsURL := "http://169.254.169.254/metadata/identity/oauth2/token?api-version=2018-02-01"
+
+	for _, noProxy := range []string{"", "login.microsoftonline.com", "*.example.com,10.0.0.0/8", "169.254.0.0/24"} {
+		t.Setenv(EEnvironmentVariable.NoProxy().Name, noProxy)
+		t.Setenv(EEnvironmentVariable.NoProxyLowercase().Name, "")
+		proxyFor := authProxyLookup(lookup)
+
+		req, _ := http.NewRequest(http.MethodGet, imdsURL, nil)
+		u, err := proxyFor(req)
+		a.Nil(err)
+		a.Nil(u, "NO_PROXY=%q", noProxy)
+
+		req, _ = http.NewRequest(http.MethodGet, "https://login.chinacloudapi.cn/tenant/oauth2/v2.0/token", nil)
+		u, err = proxyFor(req)
+		a.Nil(err)
+		a.Equal(proxy, u, "NO_PROXY=%q", noProxy)
+	}
+}
+
+func TestAuthProxyLookupHonorsNoProxy(t *testing.T) {
+	a := assert.New(t)
+	proxy, _ := url.Parse("http://corporate-proxy:3128")
+	lookup := func(*http.Request) (*url.URL, error) { return proxy, nil }
+	proxyURL := func(proxyFor ProxyLookupFunc, target string) *url.URL {
+		req, _ := http.NewRequest(http.MethodGet, target, nil)
+		u, err := proxyFor(req)
+		a.Nil(err)
+		return u
+	}
+
+	t.Setenv(EEnvironmentVariable.NoProxy().Name, "login.microsoftonline.com, .internal.contoso.com,10.0.0.0/8")
+	proxyFor := authProxyLookup(lookup)
+	a.Nil(proxyURL(proxyFor, "https://login.microsoftonline.com/common/oauth2/v2.0/token"))
+	a.Nil(proxyURL(proxyFor, "https://sts.internal.contoso.com/token"))
+	a.Nil(proxyURL(proxyFor, "http://10.1.2.3:40342/metadata/identity/oauth2/token"))
+	a.Equal(proxy, proxyURL(proxyFor, "https://login.microsoftonline.us/common/oauth2/v2.0/token"))
+
+	// The lowercase variable is used when the uppercase one isn't set.
+	t.Setenv(EEnvironmentVariable.NoProxy().Name, "")
+	t.Setenv(EEnvironmentVariable.NoProxyLowercase().Name, "login.microsoftonline.us")
+	proxyFor = authProxyLookup(lookup)
+	a.Nil(proxyURL(proxyFor, "https://login.microsoftonline.us/common/oauth2/v2.0/token"))
+
+	// The auth client uses the wrapped lookup, while other transports keep the global one.
+	defer func(global ProxyLookupFunc) { GlobalProxyLookup = global }(GlobalProxyLookup)
+	GlobalProxyLookup = lookup
+	transport := newAzcopyHTTPClient().Transport.(*http.Transport)
+	req, _ := http.NewRequest(http.MethodGet, "http://169.254.169.254/metadata/identity/oauth2/token", nil)
+	u, err := transport.Proxy(req)
+	a.Nil(err)
+	a.Nil(u)
+	u, err = defaultTokenHTTPClientOptions.NewTransport(nil).Proxy(req)
+	a.Nil(err)
+	a.Equal(proxy, u)
+}
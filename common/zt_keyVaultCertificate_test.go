@@ -0,0 +1,98 @@
+// Copyright © 2017 Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package common
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/security/keyvault/azsecrets"
+	"github.com/stretchr/testify/assert"
+	"software.sslmate.com/src/go-pkcs12"
+)
+
+// newFakeKeyVault serves a single secret, after the bearer challenge Key Vault clients expect.
+func newFakeKeyVault(a *assert.Assertions, name, contentType, value string) (*httptest.Server, *azsecrets.Client) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") == "" {
+			w.Header().Set("WWW-Authenticate", `Bearer authorization="https://login.microsoftonline.com/`+fakeTenantID+`", resource="https://vault.azure.net"`)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		a.Equal("Bearer token-1", r.Header.Get("Authorization"))
+		if r.URL.Path != "/secrets/"+name+"/" && r.URL.Path != "/secrets/"+name {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		body, _ := json.Marshal(map[string]interface{}{
+			"id":          "https://vault.azure.net/secrets/" + name + "/1",
+			"value":       value,
+			"contentType": contentType,
+			"managed":     true,
+		})
+		_, _ = w.Write(body)
+	}))
+
+	client, err := azsecrets.NewClient(srv.URL, &countingCredential{}, &azsecrets.ClientOptions{
+		ClientOptions:                        azcore.ClientOptions{Transport: srv.Client()},
+		DisableChallengeResourceVerification: true,
+	})
+	a.Nil(err)
+	return srv, client
+}
+
+func TestDownloadKeyVaultCertificate(t *testing.T) {
+	a := assert.New(t)
+	key, leaf, ca := newTestCertChain(a)
+	pfx, err := pkcs12.Modern.Encode(key, leaf, []*x509.Certificate{ca}, "")
+	a.Nil(err)
+	srv, client := newFakeKeyVault(a, "spn-cert", "application/x-pkcs12", base64.StdEncoding.EncodeToString(pfx))
+	defer srv.Close()
+
+	certs, parsedKey, err := downloadKeyVaultCertificate(context.Background(), client, "spn-cert")
+	a.Nil(err)
+	a.Len(certs, 2)
+	a.True(certs[0].Equal(leaf))
+	a.True(key.Equal(parsedKey))
+
+	_, _, err = downloadKeyVaultCertificate(context.Background(), client, "missing-cert")
+	a.NotNil(err)
+}
+
+func TestDownloadKeyVaultCertificateWithoutExportableKey(t *testing.T) {
+	a := assert.New(t)
+	_, leaf, _ := newTestCertChain(a)
+	// Key Vault only hands out the public certificate when the key isn't exportable.
+	pfx, err := pkcs12.Modern.EncodeTrustStore([]*x509.Certificate{leaf}, "")
+	a.Nil(err)
+	srv, client := newFakeKeyVault(a, "spn-cert", "application/x-pkcs12", base64.StdEncoding.EncodeToString(pfx))
+	defer srv.Close()
+
+	_, _, err = downloadKeyVaultCertificate(context.Background(), client, "spn-cert")
+	a.NotNil(err)
+	a.Contains(err.Error(), "exportable")
+}
@@ -0,0 +1,195 @@
+// Copyright © 2017 Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package common
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+	"github.com/Azure/go-autorest/autorest/adal"
+	"github.com/stretchr/testify/assert"
+)
+
+// skewedJWT builds an unsigned JWT with the given time claims, each skipped if zero.
+func skewedJWT(issuedAt, notBefore, expiry time.Time) string {
+	claims := map[string]int64{}
+	for name, t := range map[string]time.Time{"iat": issuedAt, "nbf": notBefore, "exp": expiry} {
+		if !t.IsZero() {
+			claims[name] = t.Unix()
+		}
+	}
+	payload, _ := json.Marshal(claims)
+	return "eyJhbGciOiJub25lIn0." + base64.RawURLEncoding.EncodeToString(payload) + "."
+}
+
+// captureTokenTimeWarnings collects the warnings about token times for the rest of the test, which is reset to warn afresh.
+func captureTokenTimeWarnings(t *testing.T) *[]string {
+	var lock sync.Mutex
+	warnings := &[]string{}
+	original := warnTokenTime
+	warnTokenTime = func(msg string) {
+		lock.Lock()
+		defer lock.Unlock()
+		*warnings = append(*warnings, msg)
+	}
+	tokenClockSkewWarned = 0
+	t.Cleanup(func() {
+		warnTokenTime = original
+		tokenClockSkewWarned = 0
+	})
+	return warnings
+}
+
+func TestTokenClockSkew(t *testing.T) {
+	a := assert.New(t)
+	now := time.Now().Truncate(time.Second)
+	testCases := []struct {
+		name                        string
+		issuedAt, notBefore, expiry time.Time
+		skew                        time.Duration
+	}{
+		{name: "in sync", issuedAt: now, notBefore: now, expiry: now.Add(time.Hour)},
+		{name: "slightly behind", issuedAt: now.Add(2 * time.Minute), notBefore: now.Add(2 * time.Minute), expiry: now.Add(time.Hour)},
+		{name: "slightly ahead", issuedAt: now.Add(-time.Hour), notBefore: now.Add(-time.Hour), expiry: now.Add(-2 * time.Minute)},
+		{name: "behind", issuedAt: now.Add(3 * time.Hour), notBefore: now.Add(3 * time.Hour), expiry: now.Add(4 * time.Hour), skew: -3 * time.Hour},
+		{name: "behind, by nbf", notBefore: now.Add(time.Hour), expiry: now.Add(2 * time.Hour), skew: -time.Hour},
+		{name: "ahead", issuedAt: now.Add(-26 * time.Hour), notBefore: now.Add(-26 * time.Hour), expiry: now.Add(-25 * time.Hour), skew: 25 * time.Hour},
+		{name: "no claims"},
+	}
+
+	for _, tc := range testCases {
+		skew, ok := tokenClockSkew(skewedJWT(tc.issuedAt, tc.notBefore, tc.expiry), now)
+		a.Equal(tc.skew != 0, ok, tc.name)
+		a.Equal(tc.skew, skew, tc.name)
+	}
+
+	// Opaque tokens can't tell us anything.
+	_, ok := tokenClockSkew("opaque-token", now)
+	a.False(ok)
+	_, ok = tokenClockSkew("not.a-jwt!.", now)
+	a.False(ok)
+}
+
+func TestWarnOnTokenClockSkewWarnsOnce(t *testing.T) {
+	a := assert.New(t)
+	warnings := captureTokenTimeWarnings(t)
+
+	warnOnTokenClockSkew(skewedJWT(time.Now(), time.Now(), time.Now().Add(time.Hour)))
+	a.Empty(*warnings)
+
+	// The local clock is two hours behind the issuer's.
+	warnOnTokenClockSkew(skewedJWT(time.Now().Add(2*time.Hour), time.Now().Add(2*time.Hour), time.Now().Add(3*time.Hour)))
+	warnOnTokenClockSkew(skewedJWT(time.Now().Add(2*time.Hour), time.Now().Add(2*time.Hour), time.Now().Add(3*time.Hour)))
+	if a.Len(*warnings, 1) {
+		a.Contains((*warnings)[0], "behind the clock of the token issuer")
+		a.Contains((*warnings)[0], "2h0m0s")
+	}
+}
+
+// skewedTokenStoreCache hands out a token issued by a clock which is ahead of ours.
+type skewedTokenStoreCache struct {
+	skew time.Duration
+}
+
+func (c *skewedTokenStoreCache) HasCachedToken() (bool, error) {
+	return true, nil
+}
+
+func (c *skewedTokenStoreCache) LoadToken() (*OAuthTokenInfo, error) {
+	issuerNow := time.Now().Add(c.skew)
+	return &OAuthTokenInfo{Token: adal.Token{
+		AccessToken: skewedJWT(issuerNow, issuerNow, issuerNow.Add(time.Hour)),
+		ExpiresOn:   json.Number(strconv.FormatInt(issuerNow.Add(time.Hour).Unix(), 10)),
+	}}, nil
+}
+
+func TestTokenStoreCredentialWarnsOnClockSkew(t *testing.T) {
+	a := assert.New(t)
+	warnings := captureTokenTimeWarnings(t)
+	tsc := &TokenStoreCredential{
+		token:   &azcore.AccessToken{Token: "expired-token", ExpiresOn: time.Now().Add(-time.Minute)},
+		cache:   &skewedTokenStoreCache{skew: time.Hour},
+		metrics: &TokenRefreshMetrics{},
+	}
+
+	_, err := tsc.GetToken(context.Background(), policy.TokenRequestOptions{})
+	a.Nil(err)
+	if a.Len(*warnings, 1) {
+		a.Contains((*warnings)[0], "1h0m0s behind")
+	}
+}
+
+func TestBackgroundRefreshWarnsOnClockSkew(t *testing.T) {
+	a := assert.New(t)
+	warnings := captureTokenTimeWarnings(t)
+	issuerNow := time.Now().Add(-3 * time.Hour)
+	cred := newBackgroundRefreshCredential(&staticTestCredential{token: azcore.AccessToken{
+		Token:     skewedJWT(issuerNow, issuerNow, issuerNow.Add(time.Hour)),
+		ExpiresOn: time.Now().Add(time.Hour), // as computed locally from expires_in
+	}}, minimumTokenValidDuration)
+	cred.metrics = &TokenRefreshMetrics{}
+
+	_, err := cred.GetToken(context.Background(), policy.TokenRequestOptions{Scopes: []string{StorageScope}})
+	a.Nil(err)
+	if a.Len(*warnings, 1) {
+		a.Contains((*warnings)[0], "2h0m0s ahead of")
+	}
+}
+
+type staticTestCredential struct {
+	token azcore.AccessToken
+}
+
+func (c *staticTestCredential) GetToken(context.Context, policy.TokenRequestOptions) (azcore.AccessToken, error) {
+	return c.token, nil
+}
+
+func TestOAuthTokenInfoExpiresReportsUnparseableExpiry(t *testing.T) {
+	a := assert.New(t)
+	warnings := captureTokenTimeWarnings(t)
+
+	expiresOn := time.Now().Add(time.Hour).Unix()
+	valid := &OAuthTokenInfo{Token: adal.Token{ExpiresOn: json.Number(strconv.FormatInt(expiresOn, 10))}}
+	a.Equal(expiresOn, valid.Expires().Unix())
+	a.False(valid.IsExpired())
+
+	// An unset expiry is common (e.g. before the first token), and isn't worth a warning.
+	unset := &OAuthTokenInfo{}
+	a.True(unset.IsExpired())
+	a.Empty(*warnings)
+
+	bad := &OAuthTokenInfo{Token: adal.Token{ExpiresOn: "2024-01-01T00:00:00Z"}}
+	a.True(bad.IsExpired())
+	a.True(bad.WillExpireIn(minimumTokenValidDuration))
+	a.Equal(int64(-3600), bad.Expires().Unix())
+	if a.Len(*warnings, 1) { // reported once, not on every check
+		a.Equal(fmt.Sprintf("The OAuth token's expiry %q could not be parsed, so the token is treated as expired: "+
+			`strconv.ParseFloat: parsing "2024-01-01T00:00:00Z": invalid syntax`, bad.ExpiresOn), (*warnings)[0])
+	}
+}
@@ -0,0 +1,89 @@
+// Copyright © 2017 Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package common
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTokenInfoErrorMatching(t *testing.T) {
+	a := assert.New(t)
+	cause := errors.New("keyring locked")
+	err := fmt.Errorf("login failed: %w", newTokenInfoError(ErrNoCachedToken, cause, "no cached token found"))
+
+	a.True(errors.Is(err, ErrNoCachedToken))
+	a.False(errors.Is(err, ErrRefreshFailed))
+	a.True(errors.Is(err, cause))
+	var tokenErr *TokenInfoError
+	a.True(errors.As(err, &tokenErr))
+	a.Equal(ErrNoCachedToken, tokenErr.Kind)
+	a.Equal("login failed: no cached token found, keyring locked", err.Error())
+}
+
+func TestGetTokenInfoFromEnvVarErrors(t *testing.T) {
+	a := assert.New(t)
+	uotm := &UserOAuthTokenManager{}
+
+	t.Setenv("AZCOPY_OAUTH_TOKEN_INFO", "")
+	_, err := uotm.getTokenInfoFromEnvVar(context.Background())
+	a.True(errors.Is(err, ErrEnvTokenNotSet))
+	a.True(IsErrorEnvVarOAuthTokenInfoNotSet(err))
+	a.True(IsErrorEnvVarOAuthTokenInfoNotSet(fmt.Errorf("wrapped: %w", err)))
+	a.False(IsErrorEnvVarOAuthTokenInfoNotSet(nil))
+
+	t.Setenv("AZCOPY_OAUTH_TOKEN_INFO", "{")
+	_, err = uotm.getTokenInfoFromEnvVar(context.Background())
+	a.True(errors.Is(err, ErrInvalidTokenInfo))
+	a.False(IsErrorEnvVarOAuthTokenInfoNotSet(err))
+	var syntaxErr *json.SyntaxError
+	a.True(errors.As(err, &syntaxErr))
+
+	// The directory rejects the refresh token.
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = w.Write([]byte(`{"error":"invalid_grant"}`))
+	}))
+	defer server.Close()
+	t.Setenv("AZCOPY_OAUTH_TOKEN_INFO", `{"_tenant":"tenant","_ad_endpoint":"`+server.URL+`","refresh_token":"expired"}`)
+	_, err = uotm.getTokenInfoFromEnvVar(context.Background())
+	a.True(errors.Is(err, ErrRefreshFailed), "%v", err)
+}
+
+func TestGetCachedTokenInfoNoCachedToken(t *testing.T) {
+	a := assert.New(t)
+	uotm := NewUserOAuthTokenManagerInstance(CredCacheOptions{
+		DPAPIFilePath: t.TempDir(),
+		KeyName:       "AzCopyTypedErrorTest",
+		ServiceName:   "AzCopyV10Test",
+		AccountName:   "AzCopyTypedErrorTest",
+	})
+
+	_, err := uotm.getCachedTokenInfo(context.Background())
+	a.True(errors.Is(err, ErrNoCachedToken), "%v", err)
+}
@@ -0,0 +1,209 @@
+// Copyright © 2017 Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package common
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+	"github.com/stretchr/testify/assert"
+)
+
+// scopeRecordingCredential records the scopes of each token request.
+type scopeRecordingCredential struct {
+	scopes [][]string
+}
+
+func (c *scopeRecordingCredential) GetToken(_ context.Context, options policy.TokenRequestOptions) (azcore.AccessToken, error) {
+	c.scopes = append(c.scopes, options.Scopes)
+	return azcore.AccessToken{Token: "token", ExpiresOn: time.Now().Add(time.Hour)}, nil
+}
+
+func TestParseCustomScopes(t *testing.T) {
+	a := assert.New(t)
+	a.Empty(ParseCustomScopes(""))
+	a.Equal([]string{"https://storage.contoso.local/.default"}, ParseCustomScopes("https://storage.contoso.local/.default"))
+	a.Equal([]string{"https://storage.contoso.local/.default", "https://disk.contoso.local//.default"},
+		ParseCustomScopes("https://storage.contoso.local/.default, https://disk.contoso.local//.default"))
+}
+
+func TestResolveCustomScopesFromEnvironment(t *testing.T) {
+	a := assert.New(t)
+	t.Setenv("AZCOPY_OAUTH_SCOPES", "https://storage.contoso.local/.default")
+	a.Equal([]string{"https://storage.contoso.local/.default"}, resolveCustomScopes(nil))
+	a.Equal([]string{"https://flag.contoso.local/.default"}, resolveCustomScopes([]string{"https://flag.contoso.local/.default"}))
+}
+
+func TestValidateCustomScopes(t *testing.T) {
+	a := assert.New(t)
+	a.Nil(validateCustomScopes(nil, true))
+	a.Nil(validateCustomScopes([]string{"https://storage.contoso.local/.default", "https://disk.contoso.local//.default"}, true))
+	a.Nil(validateCustomScopes([]string{"https://storage.contoso.local/user_impersonation"}, false))
+	a.NotNil(validateCustomScopes([]string{"https://storage.contoso.local/user_impersonation"}, true))
+	a.NotNil(validateCustomScopes([]string{"storage"}, false))
+}
+
+func TestCustomScopesCredential(t *testing.T) {
+	a := assert.New(t)
+	inner := &scopeRecordingCredential{}
+	cred := newCustomScopesCredential(inner, AzureCloud{StorageScope: StorageScope},
+		[]string{"https://storage.contoso.local/.default", "https://disk.contoso.local//.default"})
+
+	for _, scope := range []string{StorageScope, ManagedDiskScope, graphScope} {
+		_, err := cred.GetToken(context.Background(), policy.TokenRequestOptions{Scopes: []string{scope}})
+		a.Nil(err)
+	}
+	// The managed disk audience keeps its trailing slash, and other audiences are left alone.
+	a.Equal([][]string{
+		{"https://storage.contoso.local/.default"},
+		{"https://disk.contoso.local//.default"},
+		{graphScope},
+	}, inner.scopes)
+
+	// Without a custom disk scope, disk requests go to the standard audience.
+	inner = &scopeRecordingCredential{}
+	cred = newCustomScopesCredential(inner, AzureCloud{StorageScope: StorageScope}, []string{"https://storage.contoso.local/.default"})
+	_, err := cred.GetToken(context.Background(), policy.TokenRequestOptions{Scopes: []string{ManagedDiskScope}})
+	a.Nil(err)
+	a.Equal([][]string{{ManagedDiskScope}}, inner.scopes)
+}
+
+func TestOAuthTokenInfoStorageScopes(t *testing.T) {
+	a := assert.New(t)
+	c := AzureCloud{StorageScope: StorageScope}
+
+	info := &OAuthTokenInfo{}
+	a.Equal([]string{StorageScope}, info.storageScopes(c))
+	a.Equal(Resource, info.storageResource())
+
+	info.CustomScopes = []string{"https://disk.contoso.local//.default", "https://storage.contoso.local/.default"}
+	a.Equal([]string{"https://storage.contoso.local/.default"}, info.storageScopes(c))
+	a.Equal("https://storage.contoso.local", info.storageResource())
+
+	// Custom scopes are applied underneath the background refresher.
+	info.TokenCredential = &scopeRecordingCredential{}
+	tc, err := info.GetTokenCredential()
+	a.Nil(err)
+	a.IsType(&customScopesCredential{}, tc.(*backgroundRefreshCredential).cred)
+	a.IsType(&scopeRecordingCredential{}, unwrapTokenCredential(tc))
+}
+
+func TestValidateStorageAudience(t *testing.T) {
+	a := assert.New(t)
+	a.Nil(validateStorageAudience(""))
+	a.Nil(validateStorageAudience(StorageScope))
+	a.Nil(validateStorageAudience("https://gateway.contoso.local/.default"))
+	a.Nil(validateStorageAudience("api://3f2a9c4e-storage-gateway/.default"))
+
+	for _, audience := range []string{
+		"https://gateway.contoso.local",
+		"https://gateway.contoso.local/user_impersonation",
+		"gateway.contoso.local/.default",
+		"/.default",
+		"https://gateway.contoso.local/?x=/.default",
+		" https://gateway.contoso.local/.default",
+	} {
+		a.ErrorContains(validateStorageAudience(audience), "must be an absolute URL ending in /.default", audience)
+	}
+}
+
+func TestOAuthTokenInfoStorageAudience(t *testing.T) {
+	a := assert.New(t)
+	const audience = "https://gateway.contoso.local/.default"
+
+	// Without an override, tokens are for the cloud's standard storage audience.
+	info := &OAuthTokenInfo{}
+	c, err := info.ResolveCloud()
+	a.Nil(err)
+	a.Equal(StorageScope, c.StorageScope)
+	a.Equal([]string{StorageScope}, info.storageScopes(c))
+	a.Equal(Resource, info.storageResource())
+
+	inner := &scopeRecordingCredential{}
+	info.TokenCredential = inner
+	tc, err := info.GetTokenCredential()
+	a.Nil(err)
+	a.Same(inner, tc.(*backgroundRefreshCredential).cred)
+
+	// With one, storage clients still ask for StorageScope, but tokens are requested for the override instead.
+	info = &OAuthTokenInfo{Cloud: AzureUSGovernmentCloud, StorageAudience: audience}
+	c, err = info.ResolveCloud()
+	a.Nil(err)
+	a.Equal(AzureUSGovernmentCloud, c.Name)
+	a.Equal(audience, c.StorageScope)
+	a.Equal([]string{audience}, info.storageScopes(c))
+	a.Equal("https://gateway.contoso.local", info.storageResource())
+
+	inner = &scopeRecordingCredential{}
+	info.TokenCredential = inner
+	tc, err = info.GetTokenCredential()
+	a.Nil(err)
+	for _, scope := range []string{StorageScope, ManagedDiskScope} {
+		_, err = tc.GetToken(context.Background(), policy.TokenRequestOptions{Scopes: []string{scope}})
+		a.Nil(err)
+	}
+	a.Equal([][]string{{audience}, {ManagedDiskScope}}, inner.scopes)
+
+	// Custom storage scopes take precedence over the override.
+	info = &OAuthTokenInfo{StorageAudience: audience, CustomScopes: []string{"https://storage.contoso.local/.default"}}
+	c, err = info.ResolveCloud()
+	a.Nil(err)
+	a.Equal([]string{"https://storage.contoso.local/.default"}, info.storageScopes(c))
+}
+
+func TestValidateAndPersistLoginStorageAudience(t *testing.T) {
+	a := assert.New(t)
+	t.Setenv("AZCOPY_OAUTH_SCOPES", "")
+	uotm := &UserOAuthTokenManager{}
+
+	// Logins are verified against the override, and GetTokenInfo hands it on.
+	inner := &scopeRecordingCredential{}
+	info := &OAuthTokenInfo{Tenant: "tenant", StorageAudience: "https://gateway.contoso.local/.default", TokenCredential: inner}
+	a.Nil(uotm.validateAndPersistLogin(info, false))
+	a.Equal([][]string{{"https://gateway.contoso.local/.default"}}, inner.scopes)
+
+	tokenInfo, err := uotm.GetTokenInfoForRole(context.Background(), ECredentialRole.Source())
+	a.Nil(err)
+	a.Equal("https://gateway.contoso.local/.default", tokenInfo.StorageAudience)
+
+	inner = &scopeRecordingCredential{}
+	a.ErrorContains(uotm.validateAndPersistLogin(&OAuthTokenInfo{Tenant: "tenant", StorageAudience: "https://gateway.contoso.local", TokenCredential: inner}, false),
+		"invalid storage audience")
+	a.Empty(inner.scopes)
+
+	// Token info handed over through the environment is held to the same rules.
+	t.Setenv("AZCOPY_OAUTH_TOKEN_INFO", `{"_tenant":"tenant","refresh_token":"refresh","_storage_audience":"gateway.contoso.local/.default"}`)
+	_, err = (&UserOAuthTokenManager{}).GetTokenInfoForRole(context.Background(), ECredentialRole.Source())
+	a.ErrorContains(err, "invalid storage audience")
+
+	expiresOn := strconv.FormatInt(time.Now().Add(time.Hour).Unix(), 10)
+	t.Setenv("AZCOPY_OAUTH_TOKEN_INFO", `{"_token_refresh_source":"bearer","access_token":"brokered","expires_on":"`+expiresOn+`"}`)
+	tokenInfo, err = (&UserOAuthTokenManager{}).GetTokenInfoForRole(context.Background(), ECredentialRole.Source())
+	a.Nil(err)
+	a.Empty(tokenInfo.StorageAudience)
+	c, err := tokenInfo.ResolveCloud()
+	a.Nil(err)
+	a.Equal(StorageScope, c.StorageScope)
+}
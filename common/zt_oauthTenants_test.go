@@ -0,0 +1,70 @@
+// Copyright © 2017 Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package common
+
+import (
+	"context"
+	"testing"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseAdditionalTenants(t *testing.T) {
+	a := assert.New(t)
+	a.Empty(ParseAdditionalTenants(""))
+	a.Equal([]string{"tenant-a", "tenant-b"}, ParseAdditionalTenants("tenant-a, tenant-b"))
+}
+
+func TestResolveAdditionalTenantsFromEnvironment(t *testing.T) {
+	a := assert.New(t)
+	t.Setenv("AZCOPY_ADDITIONALLY_ALLOWED_TENANTS", "tenant-a,*")
+	a.Equal([]string{"tenant-a", "*"}, resolveAdditionalTenants(nil))
+	a.Equal([]string{"tenant-b"}, resolveAdditionalTenants([]string{"tenant-b"}))
+}
+
+func TestValidateAdditionalTenants(t *testing.T) {
+	a := assert.New(t)
+	a.Nil(validateAdditionalTenants(nil))
+	a.Nil(validateAdditionalTenants([]string{"*", "72f988bf-86f1-41af-91ab-2d7cd011db47", "contoso.onmicrosoft.com"}))
+	a.NotNil(validateAdditionalTenants([]string{"tenant/a"}))
+}
+
+func TestAdditionalTenantsReachCredential(t *testing.T) {
+	a := assert.New(t)
+	options := policy.TokenRequestOptions{Scopes: []string{StorageScope}, TenantID: "tenant-b"}
+
+	// azidentity refuses to request tokens for other tenants before doing anything else.
+	info := &OAuthTokenInfo{AzCLICred: true, Tenant: "tenant-a"}
+	tc, err := info.GetAzCliCredential()
+	a.Nil(err)
+	_, err = tc.GetToken(context.Background(), options)
+	a.ErrorContains(err, "AdditionallyAllowedTenants")
+
+	info = &OAuthTokenInfo{AzCLICred: true, Tenant: "tenant-a", AdditionalTenants: []string{"tenant-b"}}
+	tc, err = info.GetAzCliCredential()
+	a.Nil(err)
+	_, err = tc.GetToken(context.Background(), options)
+	if err != nil {
+		// Whether the CLI is installed or not, the tenant was accepted.
+		a.NotContains(err.Error(), "AdditionallyAllowedTenants")
+	}
+}
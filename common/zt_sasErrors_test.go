@@ -0,0 +1,51 @@
+// Copyright © 2017 Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package common
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSASRestrictionHint(t *testing.T) {
+	a := assert.New(t)
+	forbidden := func(code string) error {
+		return fmt.Errorf("GetProperties: %w", &azcore.ResponseError{
+			ErrorCode:   code,
+			StatusCode:  http.StatusForbidden,
+			RawResponse: &http.Response{StatusCode: http.StatusForbidden, Header: http.Header{}},
+		})
+	}
+
+	a.Contains(SASRestrictionHint(forbidden(SAS_IP_MISMATCH_SERVICE_CODE)), "(sip)")
+	a.Contains(SASRestrictionHint(forbidden(SAS_PROTOCOL_MISMATCH_SERVICE_CODE)), "(spr)")
+
+	// Other authentication failures aren't the restrictions' fault.
+	a.Empty(SASRestrictionHint(forbidden("AuthenticationFailed")))
+	a.Empty(SASRestrictionHint(forbidden("AuthorizationPermissionMismatch")))
+	a.Empty(SASRestrictionHint(errors.New(SAS_IP_MISMATCH_SERVICE_CODE)))
+	a.Empty(SASRestrictionHint(nil))
+}
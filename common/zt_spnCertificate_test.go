@@ -0,0 +1,141 @@
+// Copyright © 2017 Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package common
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"software.sslmate.com/src/go-pkcs12"
+)
+
+// newTestCertChain issues a leaf certificate from a self-signed CA, returning the leaf key and both certificates.
+func newTestCertChain(a *assert.Assertions) (*rsa.PrivateKey, *x509.Certificate, *x509.Certificate) {
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	a.Nil(err)
+	caTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "azcopy test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, caTemplate, caTemplate, caKey.Public(), caKey)
+	a.Nil(err)
+	ca, err := x509.ParseCertificate(caDER)
+	a.Nil(err)
+
+	leafKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	a.Nil(err)
+	leafTemplate := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "azcopy test SPN"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+	}
+	leafDER, err := x509.CreateCertificate(rand.Reader, leafTemplate, ca, leafKey.Public(), caKey)
+	a.Nil(err)
+	leaf, err := x509.ParseCertificate(leafDER)
+	a.Nil(err)
+
+	return leafKey, leaf, ca
+}
+
+func TestParseClientCertificatePKCS12(t *testing.T) {
+	a := assert.New(t)
+	key, leaf, ca := newTestCertChain(a)
+
+	// Modern encoding uses AES and SHA-256, as current Windows exports do.
+	pfx, err := pkcs12.Modern.Encode(key, leaf, []*x509.Certificate{ca}, "p@ssword")
+	a.Nil(err)
+	certPath := filepath.Join(t.TempDir(), "spn.pfx")
+	a.Nil(os.WriteFile(certPath, pfx, 0600))
+
+	certs, parsedKey, err := parseClientCertificate(certPath, pfx, "p@ssword")
+	a.Nil(err)
+	a.Len(certs, 2)
+	a.True(certs[0].Equal(leaf))
+	a.True(certs[1].Equal(ca))
+	a.True(key.Equal(parsedKey))
+
+	_, _, err = parseClientCertificate(certPath, pfx, "wrong")
+	a.ErrorContains(err, "incorrect password")
+
+	// A file which is neither PKCS#12 nor PEM, e.g. a DER encoded certificate without its key.
+	_, _, err = parseClientCertificate(filepath.Join(t.TempDir(), "spn.cer"), leaf.Raw, "")
+	a.ErrorContains(err, "unsupported certificate format")
+
+	// Content is sniffed when the extension doesn't tell.
+	certs, _, err = parseClientCertificate(filepath.Join(t.TempDir(), "spn.cert"), pfx, "p@ssword")
+	a.Nil(err)
+	a.Len(certs, 2)
+}
+
+func TestParseClientCertificatePEMBundle(t *testing.T) {
+	a := assert.New(t)
+	key, leaf, ca := newTestCertChain(a)
+
+	// List the CA before the leaf, to check the leaf is still returned first.
+	var bundle []byte
+	bundle = append(bundle, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ca.Raw})...)
+	bundle = append(bundle, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: leaf.Raw})...)
+	bundle = append(bundle, pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})...)
+
+	// A password is ignored for unencrypted PEM, rather than forcing PKCS#12 decoding.
+	certs, parsedKey, err := parseClientCertificate("spn.pem", bundle, "unused")
+	a.Nil(err)
+	a.Len(certs, 2)
+	a.True(certs[0].Equal(leaf))
+	a.True(certs[1].Equal(ca))
+	a.True(key.Equal(parsedKey))
+
+	_, _, err = parseClientCertificate("spn.pem", pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: leaf.Raw}), "")
+	a.NotNil(err)
+
+	// No password is needed for PEM.
+	certs, _, err = parseClientCertificate("spn.pem", bundle, "")
+	a.Nil(err)
+	a.Len(certs, 2)
+
+	// Legacy encrypted keys are rejected rather than misparsed.
+	encrypted := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: leaf.Raw})
+	encrypted = append(encrypted, pem.EncodeToMemory(&pem.Block{
+		Type:    "RSA PRIVATE KEY",
+		Headers: map[string]string{"Proc-Type": "4,ENCRYPTED", "DEK-Info": "AES-256-CBC,00000000000000000000000000000000"},
+		Bytes:   []byte("ciphertext"),
+	})...)
+	_, _, err = parseClientCertificate("spn.pem", encrypted, "p@ssword")
+	a.ErrorIs(err, errEncryptedPEMKey)
+}
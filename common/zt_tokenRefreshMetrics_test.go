@@ -0,0 +1,109 @@
+// Copyright © 2017 Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package common
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBackgroundRefreshRecordsMetrics(t *testing.T) {
+	a := assert.New(t)
+	// The first token is already inside minimumTokenValidDuration, so the second GetToken tries to replace it and fails.
+	cred := &scriptedCredential{lifetimes: []time.Duration{minimumTokenValidDuration / 2}}
+	c := newBackgroundRefreshCredential(cred, 10*time.Minute)
+	c.metrics = &TokenRefreshMetrics{}
+	options := policy.TokenRequestOptions{Scopes: []string{StorageScope}}
+
+	_, err := c.GetToken(context.Background(), options)
+	a.Nil(err)
+	_, err = c.GetToken(context.Background(), options)
+	a.Nil(err)
+
+	stats := c.metrics.Stats()
+	a.Equal(int64(2), stats.Refreshes)
+	a.Equal(int64(1), stats.Failures)
+	a.Equal(int64(1), stats.Fallbacks)
+
+	// Tokens served from the cache aren't refreshes.
+	cred = &scriptedCredential{lifetimes: []time.Duration{time.Hour}}
+	c = newBackgroundRefreshCredential(cred, 10*time.Minute)
+	c.metrics = &TokenRefreshMetrics{}
+	for i := 0; i < 3; i++ {
+		_, err = c.GetToken(context.Background(), options)
+		a.Nil(err)
+	}
+	stats = c.metrics.Stats()
+	a.Equal(int64(1), stats.Refreshes)
+	a.Zero(stats.Failures)
+	a.Zero(stats.Fallbacks)
+}
+
+func TestTokenStoreCredentialRecordsMetrics(t *testing.T) {
+	a := assert.New(t)
+	tsc := &TokenStoreCredential{
+		token:   &azcore.AccessToken{Token: "expiring-token", ExpiresOn: time.Now().Add(time.Minute)},
+		cache:   &countingTokenStoreCache{},
+		metrics: &TokenRefreshMetrics{},
+	}
+
+	for i := 0; i < 3; i++ {
+		_, err := tsc.GetToken(context.Background(), policy.TokenRequestOptions{})
+		a.Nil(err)
+	}
+	a.Equal(int64(1), tsc.metrics.Stats().Refreshes)
+
+	// The refresher leaves counting to the token store credential, which only counts actual reloads.
+	info := &OAuthTokenInfo{TokenCredential: tsc}
+	tc, err := info.GetTokenCredential()
+	a.Nil(err)
+	a.Nil(tc.(*backgroundRefreshCredential).metrics)
+}
+
+func TestJobSummaryIncludesTokenRefreshStats(t *testing.T) {
+	a := assert.New(t)
+	var metrics *TokenRefreshMetrics
+	metrics.recordRefresh(time.Now(), nil) // nil metrics record nothing
+	a.Equal(TokenRefreshStats{}, metrics.Stats())
+
+	metrics = &TokenRefreshMetrics{}
+	metrics.recordRefresh(time.Now().Add(-40*time.Millisecond), nil)
+	metrics.recordRefresh(time.Now().Add(-20*time.Millisecond), context.DeadlineExceeded)
+	metrics.recordFallback()
+
+	js := ListJobSummaryResponse{}
+	js.SetTokenRefreshStats(metrics.Stats())
+	a.Equal(int64(2), js.TokenRefreshes)
+	a.Equal(int64(1), js.TokenRefreshFailures)
+	a.Equal(int64(1), js.CredentialFallbacks)
+	a.GreaterOrEqual(js.AverageTokenRefreshMilliseconds, int64(30))
+
+	buf, err := json.Marshal(js)
+	a.Nil(err)
+	a.Contains(string(buf), `"TokenRefreshes":"2"`)
+	a.Contains(string(buf), `"CredentialFallbacks":"1"`)
+}
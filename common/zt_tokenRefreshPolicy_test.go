@@ -0,0 +1,127 @@
+// Copyright © 2017 Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package common
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/runtime"
+	"github.com/stretchr/testify/assert"
+)
+
+// countingCredential issues a distinct, hour-long token on every call.
+type countingCredential struct {
+	calls int
+}
+
+func (c *countingCredential) GetToken(_ context.Context, _ policy.TokenRequestOptions) (azcore.AccessToken, error) {
+	c.calls++
+	return azcore.AccessToken{Token: "token-" + strconv.Itoa(c.calls), ExpiresOn: time.Now().Add(time.Hour)}, nil
+}
+
+// authRecordingTransport records the Authorization header of each request it receives.
+type authRecordingTransport struct {
+	authHeaders []string
+}
+
+func (t *authRecordingTransport) Do(req *http.Request) (*http.Response, error) {
+	t.authHeaders = append(t.authHeaders, req.Header.Get("Authorization"))
+	return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader("")), Header: http.Header{}, Request: req}, nil
+}
+
+// capturingLogger collects everything logged to it.
+type capturingLogger struct {
+	lock sync.Mutex
+	msgs []string
+}
+
+func (l *capturingLogger) OpenLog()                      {}
+func (l *capturingLogger) CloseLog()                     {}
+func (l *capturingLogger) MinimumLogLevel() LogLevel     { return LogInfo }
+func (l *capturingLogger) ShouldLog(level LogLevel) bool { return true }
+func (l *capturingLogger) Panic(err error)               { panic(err) }
+func (l *capturingLogger) Log(level LogLevel, msg string) {
+	l.lock.Lock()
+	l.msgs = append(l.msgs, msg)
+	l.lock.Unlock()
+}
+
+func sendThroughTokenRefreshPolicy(a *assert.Assertions, p policy.Policy, transport policy.Transporter) {
+	pl := runtime.NewPipeline("azcopy", "test", runtime.PipelineOptions{}, &policy.ClientOptions{
+		Transport:        transport,
+		PerRetryPolicies: []policy.Policy{p},
+		Retry:            policy.RetryOptions{MaxRetries: -1},
+	})
+	req, err := runtime.NewRequest(context.Background(), http.MethodGet, "https://account.blob.core.windows.net/container")
+	a.Nil(err)
+	_, err = pl.Do(req)
+	a.Nil(err)
+}
+
+func TestTokenRefreshPolicyReusesValidToken(t *testing.T) {
+	a := assert.New(t)
+	logger := &capturingLogger{}
+	oldLogger := AzcopyCurrentJobLogger
+	AzcopyCurrentJobLogger = logger
+	defer func() { AzcopyCurrentJobLogger = oldLogger }()
+
+	cred := &countingCredential{}
+	transport := &authRecordingTransport{}
+	p := NewTokenRefreshPolicy(cred, []string{StorageScope})
+	for i := 0; i < 3; i++ {
+		sendThroughTokenRefreshPolicy(a, p, transport)
+	}
+
+	a.Equal(1, cred.calls)
+	a.Equal([]string{"Bearer token-1", "Bearer token-1", "Bearer token-1"}, transport.authHeaders)
+	a.Len(logger.msgs, 1)
+	a.Contains(logger.msgs[0], "countingCredential")
+	a.Contains(logger.msgs[0], StorageScope)
+}
+
+func TestTokenRefreshPolicyHonorsRefreshInjection(t *testing.T) {
+	a := assert.New(t)
+	oldLogger, oldInjection := AzcopyCurrentJobLogger, GlobalTestOAuthInjection
+	AzcopyCurrentJobLogger = &capturingLogger{}
+	GlobalTestOAuthInjection = TestOAuthInjection{DoTokenRefreshInjection: true, TokenRefreshDuration: 10 * time.Millisecond}
+	defer func() { AzcopyCurrentJobLogger, GlobalTestOAuthInjection = oldLogger, oldInjection }()
+
+	cred := &countingCredential{}
+	transport := &authRecordingTransport{}
+	p := NewTokenRefreshPolicy(cred, []string{StorageScope})
+	sendThroughTokenRefreshPolicy(a, p, transport)
+	sendThroughTokenRefreshPolicy(a, p, transport)
+	time.Sleep(20 * time.Millisecond)
+	sendThroughTokenRefreshPolicy(a, p, transport)
+
+	// The hour-long token is refreshed early once the injected refresh duration elapses.
+	a.Equal(2, cred.calls)
+	a.Equal([]string{"Bearer token-1", "Bearer token-1", "Bearer token-2"}, transport.authHeaders)
+}
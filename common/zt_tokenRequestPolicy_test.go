@@ -0,0 +1,110 @@
+// Copyright © 2017 Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package common
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/runtime"
+	"github.com/stretchr/testify/assert"
+)
+
+// authorityCredential requests its tokens from a fake authority.
+type authorityCredential struct {
+	url string
+}
+
+func (c authorityCredential) GetToken(ctx context.Context, _ policy.TokenRequestOptions) (azcore.AccessToken, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.url, nil)
+	if err != nil {
+		return azcore.AccessToken{}, err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return azcore.AccessToken{}, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return azcore.AccessToken{}, runtime.NewResponseError(resp)
+	}
+	return azcore.AccessToken{Token: "token", ExpiresOn: time.Now().Add(time.Hour)}, nil
+}
+
+func TestLoginGivesUpOnStallingAuthority(t *testing.T) {
+	a := assert.New(t)
+	t.Setenv(EEnvironmentVariable.TokenRequestTimeout().Name, "1")
+
+	authority := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Never answer, like an authority behind a firewall which drops packets.
+		<-r.Context().Done()
+	}))
+	defer authority.Close()
+
+	info := NewOAuthTokenInfoFromCredential(authorityCredential{url: authority.URL}, "tenant")
+	start := time.Now()
+	err := (&UserOAuthTokenManager{}).validateAndPersistLogin(info, false)
+	a.ErrorContains(err, "token request to https://login.microsoftonline.com/tenant did not complete within 1s")
+	a.ErrorIs(err, context.DeadlineExceeded)
+	a.Less(time.Since(start), 10*time.Second)
+}
+
+func TestTokenRequestsRetryTransientFailures(t *testing.T) {
+	a := assert.New(t)
+	defer func(delay time.Duration) { tokenRequestRetryDelay = delay }(tokenRequestRetryDelay)
+	tokenRequestRetryDelay = time.Millisecond
+
+	var calls int32
+	statuses := []int{http.StatusTooManyRequests, http.StatusServiceUnavailable, http.StatusOK}
+	authority := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		call := int(atomic.AddInt32(&calls, 1)) - 1
+		if call >= len(statuses) {
+			call = len(statuses) - 1
+		}
+		w.WriteHeader(statuses[call])
+	}))
+	defer authority.Close()
+
+	token, err := getTokenWithRetry(context.Background(), authorityCredential{url: authority.URL}, policy.TokenRequestOptions{}, "https://authority/tenant")
+	a.NoError(err)
+	a.Equal("token", token.Token)
+	a.EqualValues(3, atomic.LoadInt32(&calls))
+
+	// Persistent throttling gives up after the last retry.
+	statuses = []int{http.StatusTooManyRequests}
+	atomic.StoreInt32(&calls, 0)
+	_, err = getTokenWithRetry(context.Background(), authorityCredential{url: authority.URL}, policy.TokenRequestOptions{}, "https://authority/tenant")
+	a.ErrorContains(err, "token request to https://authority/tenant failed after 4 attempts")
+	a.EqualValues(maxTokenRequestRetries+1, atomic.LoadInt32(&calls))
+
+	// Rejected requests aren't retried.
+	statuses = []int{http.StatusBadRequest}
+	atomic.StoreInt32(&calls, 0)
+	_, err = getTokenWithRetry(context.Background(), authorityCredential{url: authority.URL}, policy.TokenRequestOptions{}, "https://authority/tenant")
+	a.ErrorContains(err, "token request to https://authority/tenant failed: ")
+	a.EqualValues(1, atomic.LoadInt32(&calls))
+}
@@ -1,12 +1,13 @@
 package e2etest
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/Azure/azure-storage-azcopy/v10/common"
 	"io"
 	"net/http"
-	"reflect"
 	"strconv"
 	"strings"
 	"time"
@@ -22,6 +23,23 @@ type ARMAsyncResponse[Props any] struct {
 	// Set Properties to a pointer of your target struct, encoding/json will handle the magic.
 	Properties *Props        `json:"properties"`
 	Error      ARMAsyncError `json:"error"`
+
+	// Polling state, set by NewARMAsyncResponse. Unexported, so encoding/json leaves it alone.
+	client      *ARMClient
+	maxAttempts int // per poll, for throttled or failed polls
+	pollURI     string
+	armStatus   bool // the final body was an ARMAsyncResponse, rather than the resource itself
+}
+
+// NewARMAsyncResponse wraps an in-flight operation at uri (an Azure-AsyncOperation or Location header), to be resolved with PollWithCallback.
+// Polls are authorized and retried per client.
+func NewARMAsyncResponse[Props any](client *ARMClient, uri string, properties *Props) *ARMAsyncResponse[Props] {
+	return &ARMAsyncResponse[Props]{
+		Properties:  properties,
+		client:      client,
+		maxAttempts: client.maxAttempts(),
+		pollURI:     uri,
+	}
 }
 
 func (a ARMAsyncResponse[Props]) Validate() bool {
@@ -35,6 +53,10 @@ type ARMAsyncError struct {
 	Message string `json:"message"`
 }
 
+func (e ARMAsyncError) Error() string {
+	return e.Code + ": " + e.Message
+}
+
 const (
 	ARMStatusSucceeded    = "Succeeded"
 	ARMStatusFailed       = "Failed"
@@ -44,42 +66,110 @@ const (
 	ARMStatusResolvingDNS = "ResolvingDNS"
 )
 
+func isTerminalARMStatus(status string) bool {
+	return status == ARMStatusSucceeded || status == ARMStatusFailed || status == ARMStatusCanceled
+}
+
+const maxARMPollInterval = time.Minute
+
+// ARMPollOptions controls how long, and how often, an async operation is polled.
+type ARMPollOptions struct {
+	// Interval between polls, when the service sends no Retry-After. Zero backs off exponentially instead.
+	Interval time.Duration
+	// MaxWait bounds the whole resolution, on top of any deadline on the context. Zero waits for as long as the context allows.
+	MaxWait time.Duration
+}
+
 // ResolveAzureAsyncOperation implements https://learn.microsoft.com/en-us/azure/azure-resource-manager/management/async-operations
-func ResolveAzureAsyncOperation[Props any](OAuth AccessToken, uri string, properties *Props) (armResp *ARMAsyncResponse[Props], err error) {
-	if properties != nil && reflect.TypeOf(properties).Kind() != reflect.Ptr {
-		return nil, fmt.Errorf("properties must be a pointer (or nil)")
+// If the operation reported an ARM status, the final *ARMAsyncResponse is returned (including Failed or Canceled ones, which aren't errors here).
+// Otherwise, armResp is nil, and the resource is written to properties.
+// Polling stops once ctx is done or opts.MaxWait passes, returning the context's error, wrapped with the operation URL.
+func ResolveAzureAsyncOperation[Props any](ctx context.Context, OAuth AccessToken, uri string, properties *Props, opts ARMPollOptions) (armResp *ARMAsyncResponse[Props], err error) {
+	return NewARMAsyncResponse(&ARMClient{OAuth: OAuth}, uri, properties).resolve(ctx, opts)
+}
+
+func (a *ARMAsyncResponse[Props]) resolve(ctx context.Context, opts ARMPollOptions) (*ARMAsyncResponse[Props], error) {
+	if opts.MaxWait > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, opts.MaxWait)
+		defer cancel()
 	}
 
-	req, err := http.NewRequest("GET", uri, nil)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
+	_, err := a.PollWithCallback(ctx, opts.Interval, nil)
+	if errors.As(err, &ARMAsyncError{}) {
+		err = nil // the caller inspects Status
+	}
+	if err != nil || !a.armStatus {
+		return nil, err
 	}
 
-	var resp *http.Response
-	var lastWaitSeconds int64 = 0
+	return a, nil
+}
+
+// PollWithCallback polls the operation until it reaches a terminal state (Succeeded, Failed or Canceled), reporting the state
+// seen on every poll to onState (which may be nil). Between polls, the service's Retry-After is honored; lacking one, it waits
+// interval, or backs off exponentially (capped at a minute) if interval is zero.
+// Transport errors, throttled (429) and failed (5xx) polls are retried as the client would retry any other request.
+// The final payload is deserialized into Properties and returned. A Failed or Canceled operation returns an ARMAsyncError.
+func (a *ARMAsyncResponse[Props]) PollWithCallback(ctx context.Context, interval time.Duration, onState func(state string)) (*Props, error) {
+	if a.pollURI == "" {
+		return nil, errors.New("no operation to poll; use NewARMAsyncResponse")
+	}
+
+	client := a.client.getHTTPClient()
+
+	var wait, backoff time.Duration
+	var failedAttempts int
 	for {
-		if lastWaitSeconds == 0 {
-			lastWaitSeconds = 1 // pretend we waited, proceed to the initial request.
-		} else {
-			fmt.Println("Sleeping", lastWaitSeconds, "seconds")
-			time.Sleep(time.Second * time.Duration(lastWaitSeconds))
+		if wait > 0 {
+			select {
+			case <-ctx.Done():
+				return nil, fmt.Errorf("stopped polling %s: %w", a.pollURI, ctx.Err())
+			case <-time.After(wait):
+			}
+		}
+
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, a.pollURI, nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create request: %w", err)
 		}
 
-		oAuthToken, err := OAuth.FreshToken()
+		oAuthToken, err := a.client.OAuth.FreshToken()
 		if err != nil {
 			return nil, fmt.Errorf("failed to get fresh token: %w", err)
 		}
-		// Update the OAuth token if we have to
 		req.Header["Authorization"] = []string{"Bearer " + oAuthToken}
 
-		armResp = &ARMAsyncResponse[Props]{
-			Properties: properties, // the user may have supplied a ptr to a struct, let encoding/json resolve that
-		}
+		resp, err := client.Do(req)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, fmt.Errorf("stopped polling %s: %w", a.pollURI, ctx.Err())
+			}
 
-		resp, err = http.DefaultClient.Do(req)
+			failedAttempts++
+			if failedAttempts >= a.maxAttempts {
+				return nil, fmt.Errorf("failed to poll %s (%d attempts): %w", a.pollURI, failedAttempts, err)
+			}
+
+			wait = a.client.retryDelay(failedAttempts, nil)
+			continue
+		}
+		buf, err := io.ReadAll(resp.Body)
+		_ = resp.Body.Close()
 		if err != nil {
-			return nil, fmt.Errorf("failed to send request: %w", err)
+			return nil, fmt.Errorf("failed to read response body (resp code %d): %w", resp.StatusCode, err)
+		}
+
+		if isRetryableARMStatus(resp.StatusCode) {
+			failedAttempts++
+			if failedAttempts >= a.maxAttempts {
+				return nil, fmt.Errorf("failed to poll async operation (resp code %d, %d attempts)%s: %s", resp.StatusCode, failedAttempts, armRequestIDs(resp), string(buf))
+			}
+
+			wait = a.client.retryDelay(failedAttempts, resp)
+			continue
 		}
+		failedAttempts = 0
 
 		/*
 			Lessons learned from past attempts:
@@ -98,94 +188,100 @@ func ResolveAzureAsyncOperation[Props any](OAuth AccessToken, uri string, proper
 			followUpLoc = resp.Header.Get("Azure-AsyncOperation")
 		}
 		if followUpLoc != "" {
-			uri = followUpLoc
+			a.pollURI = followUpLoc
 		}
 
 		// Let's see if we can find out how long to wait.
 		// This can appear *sometimes*, but not always.
-		retryAfterRaw := resp.Header.Get("Retry-After")
-		var retryAfter int64
-		if retryAfterRaw != "" {
-			count, err := strconv.ParseInt(retryAfterRaw, 10, 32)
-			if err != nil {
-				retryAfter = count
+		wait = 0
+		if seconds, err := strconv.ParseInt(resp.Header.Get("Retry-After"), 10, 32); err == nil && seconds > 0 {
+			wait = time.Duration(seconds) * time.Second
+		} else if interval > 0 {
+			wait = interval
+		} else { // Fall back to our last wait, exponential, capped.
+			backoff = common.Iff(backoff == 0, 2*time.Second, backoff*2)
+			backoff = common.Iff(backoff > maxARMPollInterval, maxARMPollInterval, backoff)
+			wait = backoff
+		}
+
+		if len(buf) == 0 {
+			if followUpLoc != "" { // Continue if there's a follow-up location
+				continue
 			}
+
+			// Quoth the documentation: If no value is returned for provisioningState, the operation finished and succeeded.
+			a.Status = ARMStatusSucceeded
+			return a.Properties, nil
 		}
-		if retryAfter == 0 { // Fall back to our last wait, exponential, capped to 60s.
-			retryAfter = lastWaitSeconds * 2
-			retryAfter = common.Iff(retryAfter > 60, 60, retryAfter)
-			lastWaitSeconds = retryAfter
+
+		// Sometimes, this body *can* be an ARMAsyncResponse! If it is, this is great and useful information.
+		// Other times, it may include "provisioningState":
+		// Let's check!
+		rawResp := map[string]any{}
+		err = json.Unmarshal(buf, &rawResp)
+		if err != nil {
+			return nil, fmt.Errorf("failed to unmarshal body to raw struct: %w", err)
 		}
 
-		// If the body is nonzero, we should read it.
-		// This might contain status info that is more reliable than the response code (why? good question, that's why.)
-		if resp.ContentLength != 0 {
-			buf, err := io.ReadAll(resp.Body)
-			if err != nil {
-				return nil, fmt.Errorf("failed to read response body (resp code %d): %w", resp.StatusCode, err)
-			}
+		var state string
+		if status, ok := rawResp["status"]; ok {
+			a.armStatus = true
+			state, _ = status.(string)
+		} else if status, ok := searchARMBody(rawResp, "properties/provisioningState"); ok {
+			// workaround for storage accounts.
+			// todo: this will probably burn us eventually, but it's the only exception listed on the docs page, and so far the only one we've encountered.
+			state, _ = status.(string)
+		}
 
-			// Sometimes, this body *can* be an ARMAsyncResponse! If it is, this is great and useful information.
-			// Other times, it may include "provisioningState":
-			// Let's check!
-			rawResp := map[string]any{}
-			err = json.Unmarshal(buf, &rawResp)
-			if err != nil {
-				return nil, fmt.Errorf("failed to unmarshal body to raw struct: %w", err)
-			}
+		if state != "" && onState != nil {
+			onState(state)
+		}
 
-			search := func(data map[string]any, key string) (any, bool) {
-				queue := strings.Split(key, "/")
-				for k, v := range queue {
-					value, ok := data[v]
-					if !ok {
-						return nil, false
-					}
-
-					if k+1 == len(queue) {
-						return value, ok
-					} else {
-						data, ok = value.(map[string]any)
-						if !ok {
-							return nil, false
-						}
-					}
-				}
-
-				// Go can't properly detect that this is unreachable, but we'll always hit
-				panic("unreachable code")
-			}
+		if state != "" && !isTerminalARMStatus(state) {
+			continue
+		}
 
-			usesARMStatus := false
-			if status, ok := rawResp["status"]; ok {
-				usesARMStatus = true
-
-				if status == ARMStatusInProgress || status == ARMStatusRunning || status == ARMStatusResolvingDNS {
-					continue
-				}
-			} else if status, ok = search(rawResp, "properties/provisioningState"); ok {
-				// workaround for storage accounts.
-				// todo: this will probably burn us eventually, but it's the only exception listed on the docs page, and so far the only one we've encountered.
-				strStatus, ok := status.(string)
-				if ok && (strStatus == ARMStatusInProgress || strStatus == ARMStatusRunning || strStatus == ARMStatusResolvingDNS) {
-					continue
-				}
-			}
+		if a.armStatus {
+			err = json.Unmarshal(buf, a)
+		} else {
+			err = json.Unmarshal(buf, &a.Properties)
+			a.Status = common.Iff(state == "", ARMStatusSucceeded, state)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to unmarshal final response: %w", err)
+		}
 
-			if usesARMStatus {
-				err = json.Unmarshal(buf, &armResp)
-				return armResp, err
-			} else {
-				err = json.Unmarshal(buf, &properties)
-				return nil, err
+		if a.Status == ARMStatusFailed || a.Status == ARMStatusCanceled {
+			opErr := a.Error
+			if opErr.Code == "" {
+				opErr.Code = a.Status
 			}
+			return a.Properties, fmt.Errorf("async operation %s: %w", strings.ToLower(a.Status), opErr)
+		}
+
+		return a.Properties, nil
+	}
+}
+
+// searchARMBody walks a slash-separated key (e.g. properties/provisioningState) through an unmarshalled JSON object.
+func searchARMBody(data map[string]any, key string) (any, bool) {
+	queue := strings.Split(key, "/")
+	for k, v := range queue {
+		value, ok := data[v]
+		if !ok {
+			return nil, false
+		}
+
+		if k+1 == len(queue) {
+			return value, ok
 		} else {
-			if followUpLoc != "" { // Continue if there's a follow-up location
-				continue
-			} else {
-				// Quoth the documentation: If no value is returned for provisioningState, the operation finished and succeeded.
-				return nil, nil
+			data, ok = value.(map[string]any)
+			if !ok {
+				return nil, false
 			}
 		}
 	}
+
+	// Go can't properly detect that this is unreachable, but we'll always hit
+	panic("unreachable code")
 }
@@ -111,9 +111,10 @@ type AccountType uint8
 
 func (AccountType) Standard() AccountType                     { return AccountType(0) }
 func (AccountType) PremiumBlockBlobs() AccountType            { return AccountType(1) }
-func (AccountType) PremiumPageBlobs() AccountType             { return AccountType(8) }
-func (AccountType) PremiumFileShares() AccountType            { return AccountType(9) }
-func (AccountType) PremiumHNSEnabled() AccountType            { return AccountType(10) }
+func (AccountType) PremiumPageBlobs() AccountType             { return AccountType(11) }
+func (AccountType) PremiumFileShares() AccountType            { return AccountType(12) }
+func (AccountType) PremiumHNSEnabled() AccountType            { return AccountType(13) }
+func (AccountType) PremiumV2FileShares() AccountType          { return AccountType(14) }
 func (AccountType) HierarchicalNamespaceEnabled() AccountType { return AccountType(2) }
 func (AccountType) Classic() AccountType                      { return AccountType(3) }
 func (AccountType) StdManagedDisk() AccountType               { return AccountType(4) }
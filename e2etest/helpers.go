@@ -87,6 +87,11 @@ func isS3Disabled() bool {
 	return strings.ToLower(os.Getenv("S3_TESTS_OFF")) != ""
 }
 
+// if GCP_TESTS_OFF is set at all, GCP tests are disabled.
+func isGCPDisabled() bool {
+	return strings.ToLower(os.Getenv("GCP_TESTS_OFF")) != ""
+}
+
 func skipIfS3Disabled(c asserter) {
 	if isS3Disabled() {
 		c.Skip("S3 testing is disabled for this unit test suite run.")
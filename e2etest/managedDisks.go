@@ -80,9 +80,9 @@ func (config *ManagedDiskConfig) GetAccess() (*url.URL, error) {
 		if resp.StatusCode == 202 { // async operation
 			// Grab the azure-asyncoperation header
 			newTarget := resp.Header.Get("Azure-Asyncoperation")
-			_, err = ResolveAzureAsyncOperation(config.oauth, newTarget, &struct {
+			_, err = ResolveAzureAsyncOperation(ctx, config.oauth, newTarget, &struct {
 				Output *ManagedDiskGetAccessResponse `json:"output"`
-			}{Output: &getAccessResp}) // no need to get the whole struct, json resolve will place data in our getAccessResp
+			}{Output: &getAccessResp}, ARMPollOptions{}) // no need to get the whole struct, json resolve will place data in our getAccessResp
 			if err != nil {
 				return nil, fmt.Errorf("failed to get access (async op): %w", err)
 			}
@@ -141,7 +141,7 @@ func (config *ManagedDiskConfig) RevokeAccess() error {
 	if resp.StatusCode != 200 {
 		if resp.StatusCode == 202 {
 			newTarget := resp.Header.Get("Azure-Asyncoperation")
-			_, err := ResolveAzureAsyncOperation[any](config.oauth, newTarget, nil)
+			_, err := ResolveAzureAsyncOperation[any](ctx, config.oauth, newTarget, nil, ARMPollOptions{})
 
 			return err
 		}
@@ -3,8 +3,13 @@ package e2etest
 import (
 	"fmt"
 	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/service"
+	"github.com/Azure/azure-storage-azcopy/v10/common"
 	"github.com/google/uuid"
+	"google.golang.org/api/option"
+	"net"
+	"net/url"
 	"strings"
+	"sync"
 )
 
 // AccountRegistry is a set of accounts that are intended to be initialized when the tests start running.
@@ -38,46 +43,104 @@ type CreateAccountOptions struct {
 
 	// ParamMutator is intended for one-off excursions into boilerplate land
 	ParamMutator func(createParams *ARMStorageAccountCreateParams)
+	// ClassicParamMutator is ParamMutator, for classic accounts, which come from Microsoft.ClassicStorage and are shaped differently.
+	ClassicParamMutator func(createParams *ARMClassicStorageAccountCreateParams)
 }
 
-func CreateAccount(a Asserter, accountType AccountType, options *CreateAccountOptions) AccountResourceManager {
-	if d, isDryrunner := a.(DryrunAsserter); isDryrunner && d.Dryrun() {
-		return &MockAccountResourceManager{accountType: accountType}
-	}
-
-	opts := DerefOrZero(options)
-
-	uuidSegments := strings.Split(uuid.NewString(), "-")
-
-	accountARMClient := &ARMStorageAccount{
-		ARMResourceGroup: CommonARMResourceGroup,
-		AccountName:      DerefOrDefault(opts.CustomName, "azcopynewe2e") + uuidSegments[len(uuidSegments)-1],
-	}
+// accountTypeServices lists the services each account type offers, per
+// https://learn.microsoft.com/en-us/azure/storage/common/storage-account-overview#types-of-storage-accounts
+// Premium block blob accounts have no File service, and premium file accounts have nothing else.
+var accountTypeServices = map[AccountType][]common.Location{
+	EAccountType.Standard():                     {common.ELocation.Blob(), common.ELocation.File(), common.ELocation.BlobFS()},
+	EAccountType.PremiumBlockBlobs():            {common.ELocation.Blob(), common.ELocation.BlobFS()},
+	EAccountType.PremiumPageBlobs():             {common.ELocation.Blob()},
+	EAccountType.PremiumFileShares():            {common.ELocation.File()},
+	EAccountType.PremiumV2FileShares():          {common.ELocation.File()},
+	EAccountType.PremiumHNSEnabled():            {common.ELocation.Blob(), common.ELocation.BlobFS()},
+	EAccountType.HierarchicalNamespaceEnabled(): {common.ELocation.Blob(), common.ELocation.File(), common.ELocation.BlobFS()},
+	EAccountType.Classic():                      {common.ELocation.Blob(), common.ELocation.File()},
+	EAccountType.S3():                           {common.ELocation.S3()},
+	EAccountType.GCP():                          {common.ELocation.GCP()},
+}
 
-	accountARMDefinition := ARMStorageAccountCreateParams{
+// accountCreateParams fills in the kind, SKU and HNS setting for accountType; ok is false if it can't be created via ARM.
+func accountCreateParams(accountType AccountType) (params ARMStorageAccountCreateParams, ok bool) {
+	params = ARMStorageAccountCreateParams{
 		Location: "West US 2", // todo configurable
 	}
 
 	switch accountType { // https://learn.microsoft.com/en-us/azure/storage/common/storage-account-create?tabs=azure-portal#storage-account-type-parameters
 	case EAccountType.Standard():
-		accountARMDefinition.Kind = service.AccountKindStorageV2
-		accountARMDefinition.Sku = ARMStorageAccountSKUStandardLRS
+		params.Kind = service.AccountKindStorageV2
+		params.Sku = ARMStorageAccountSKUStandardLRS
 	case EAccountType.HierarchicalNamespaceEnabled():
-		accountARMDefinition.Kind = service.AccountKindStorageV2
-		accountARMDefinition.Sku = ARMStorageAccountSKUStandardLRS
-		accountARMDefinition.Properties = &ARMStorageAccountCreateProperties{
+		params.Kind = service.AccountKindStorageV2
+		params.Sku = ARMStorageAccountSKUStandardLRS
+		params.Properties = &ARMStorageAccountCreateProperties{
 			IsHnsEnabled: pointerTo(true),
 		}
 	case EAccountType.PremiumBlockBlobs():
-		accountARMDefinition.Kind = service.AccountKindBlockBlobStorage
-		accountARMDefinition.Sku = ARMStorageAccountSKUPremiumLRS
+		params.Kind = service.AccountKindBlockBlobStorage
+		params.Sku = ARMStorageAccountSKUPremiumLRS
+	case EAccountType.PremiumHNSEnabled():
+		params.Kind = service.AccountKindBlockBlobStorage
+		params.Sku = ARMStorageAccountSKUPremiumLRS
+		params.Properties = &ARMStorageAccountCreateProperties{
+			IsHnsEnabled: pointerTo(true),
+		}
 	case EAccountType.PremiumFileShares():
-		accountARMDefinition.Kind = service.AccountKindFileStorage
-		accountARMDefinition.Sku = ARMStorageAccountSKUPremiumLRS
+		params.Kind = service.AccountKindFileStorage
+		params.Sku = ARMStorageAccountSKUPremiumLRS
+	case EAccountType.PremiumV2FileShares():
+		params.Kind = service.AccountKindFileStorage
+		params.Sku = ARMStorageAccountSKUPremiumV2LRS
 	case EAccountType.PremiumPageBlobs():
-		accountARMDefinition.Kind = service.AccountKindStorageV2
-		accountARMDefinition.Sku = ARMStorageAccountSKUPremiumLRS
+		params.Kind = service.AccountKindStorageV2
+		params.Sku = ARMStorageAccountSKUPremiumLRS
 	default:
+		return params, false
+	}
+
+	return params, true
+}
+
+func CreateAccount(a Asserter, accountType AccountType, options *CreateAccountOptions) AccountResourceManager {
+	if GlobalConfig.Emulated() {
+		a.Skip(fmt.Sprintf("Creating a %s account requires ARM, which is unavailable against the storage emulator", accountType))
+		return &MockAccountResourceManager{accountType: accountType}
+	}
+
+	if d, isDryrunner := a.(DryrunAsserter); isDryrunner && d.Dryrun() {
+		return &MockAccountResourceManager{accountType: accountType}
+	}
+
+	acct := createAccount(a, accountType, options)
+
+	if rt, ok := a.(ResourceTracker); ok {
+		rt.TrackCreatedAccount(acct)
+	}
+
+	return acct
+}
+
+// createAccount creates the account without handing it to a ResourceTracker, so it outlives the scenario that asked for it.
+func createAccount(a Asserter, accountType AccountType, options *CreateAccountOptions) *AzureAccountResourceManager {
+	opts := DerefOrZero(options)
+
+	uuidSegments := strings.Split(uuid.NewString(), "-")
+	accountName := DerefOrDefault(opts.CustomName, "azcopynewe2e") + uuidSegments[len(uuidSegments)-1]
+
+	if accountType == EAccountType.Classic() {
+		return createClassicAccount(a, accountName, opts)
+	}
+
+	accountARMClient := &ARMStorageAccount{
+		ARMResourceGroup: CommonARMResourceGroup,
+		AccountName:      accountName,
+	}
+
+	accountARMDefinition, ok := accountCreateParams(accountType)
+	if !ok {
 		a.Error(fmt.Sprintf("%s is not currently supported for account creation", accountType))
 	}
 
@@ -91,23 +154,95 @@ func CreateAccount(a Asserter, accountType AccountType, options *CreateAccountOp
 	a.NoError("ARM get keys call", err)
 
 	acct := &AzureAccountResourceManager{
-		accountName: accountARMClient.AccountName,
-		accountKey:  keys.Keys[0].Value, // todo find useful key
-		accountType: accountType,
-		armClient:   accountARMClient,
+		accountName:    accountARMClient.AccountName,
+		accountKey:     keys.Keys[0].Value, // todo find useful key
+		accountKeyName: keys.Keys[0].KeyName,
+		accountType:    accountType,
+		armClient:      accountARMClient,
 	}
 
-	if rt, ok := a.(ResourceTracker); ok {
-		rt.TrackCreatedAccount(acct)
+	if PrimaryOAuthCache != nil {
+		acct.tokenCredential = PrimaryOAuthCache
 	}
 
 	return acct
 }
 
+// createClassicAccount is createAccount, for a classic account.
+func createClassicAccount(a Asserter, accountName string, opts CreateAccountOptions) *AzureAccountResourceManager {
+	accountARMClient := &ARMClassicStorageAccount{
+		ARMResourceGroup: CommonARMResourceGroup,
+		AccountName:      accountName,
+	}
+
+	accountARMDefinition := ARMClassicStorageAccountCreateParams{
+		Location:   "West US 2", // todo configurable
+		Properties: ARMClassicStorageAccountCreateProperties{AccountType: ARMClassicStorageAccountTypeStandardLRS},
+	}
+
+	if opts.ClassicParamMutator != nil {
+		opts.ClassicParamMutator(&accountARMDefinition)
+	}
+
+	_, err := accountARMClient.Create(accountARMDefinition)
+	a.NoError("ARM create classic account call", err)
+	acct, err := accountARMClient.GetResourceManager()
+	a.NoError("get classic account resource manager", err)
+	if acct == nil {
+		return nil
+	}
+
+	if PrimaryOAuthCache != nil {
+		acct.tokenCredential = PrimaryOAuthCache
+	}
+
+	return acct
+}
+
+// accountPool holds the accounts created by GetAccountOfType, at most one per type, until AccountRegistryCleanupHook.
+var accountPool = struct {
+	sync.Mutex
+	accounts map[AccountType]AccountResourceManager
+}{accounts: map[AccountType]AccountResourceManager{}}
+
+// GetAccountOfType returns an account of accountType, for scenarios that need e.g. premium page blobs or file shares.
+// Standard and HNS accounts come from the registry; anything else is created on first request and shared for the rest of the run.
+// Creating accounts requires ARM, so other types are skipped against static resources or the emulator.
+func GetAccountOfType(a Asserter, accountType AccountType) AccountResourceManager {
+	switch accountType {
+	case EAccountType.Standard():
+		return GetAccount(a, PrimaryStandardAcct)
+	case EAccountType.HierarchicalNamespaceEnabled():
+		return GetAccount(a, PrimaryHNSAcct)
+	}
+
+	if d, isDryrunner := a.(DryrunAsserter); isDryrunner && d.Dryrun() {
+		return &MockAccountResourceManager{accountType: accountType}
+	}
+
+	if GlobalConfig.StaticResources() || GlobalConfig.Emulated() {
+		a.Skip(fmt.Sprintf("A %s account must be created via ARM, which needs NEW_E2E_SUBSCRIPTION_ID", accountType))
+		return &MockAccountResourceManager{accountType: accountType}
+	}
+
+	accountPool.Lock()
+	defer accountPool.Unlock()
+
+	if acct, ok := accountPool.accounts[accountType]; ok {
+		return acct
+	}
+
+	acct := createAccount(a, accountType, nil)
+	accountPool.accounts[accountType] = acct
+
+	return acct
+}
+
 func DeleteAccount(a Asserter, arm AccountResourceManager) {
 	switch arm.AccountType() {
-	case EAccountType.Standard(), EAccountType.PremiumPageBlobs(), EAccountType.PremiumFileShares(),
-		EAccountType.PremiumBlockBlobs(), EAccountType.PremiumHNSEnabled(), EAccountType.HierarchicalNamespaceEnabled():
+	case EAccountType.Standard(), EAccountType.PremiumPageBlobs(), EAccountType.PremiumFileShares(), EAccountType.PremiumV2FileShares(),
+		EAccountType.PremiumBlockBlobs(), EAccountType.PremiumHNSEnabled(), EAccountType.HierarchicalNamespaceEnabled(),
+		EAccountType.Classic():
 		azureAcct, ok := arm.(*AzureAccountResourceManager)
 		a.Assert("account manager must be azure account", Equal{}, ok, true)
 
@@ -123,10 +258,27 @@ func DeleteAccount(a Asserter, arm AccountResourceManager) {
 const (
 	PrimaryStandardAcct string = "PrimaryStandard"
 	PrimaryHNSAcct      string = "PrimaryHNS"
+	PrimaryS3Acct       string = "PrimaryS3"
+	PrimaryGCPAcct      string = "PrimaryGCP"
 )
 
 func AccountRegistryInitHook(a Asserter) {
-	if GlobalConfig.StaticResources() {
+	if GlobalConfig.Emulated() {
+		emulatorInfo := GlobalConfig.E2EAuthConfig.EmulatorInfo
+
+		endpoint, err := url.Parse(emulatorInfo.Endpoint)
+		a.NoError("parse E2E_EMULATOR_ENDPOINT", err)
+		a.AssertNow("E2E_EMULATOR_ENDPOINT must address the emulator by IP (e.g. http://127.0.0.1:10000); AzCopy and the SDKs only parse path-style URLs for IP hosts",
+			Equal{}, net.ParseIP(endpoint.Hostname()) != nil, true)
+
+		// There's no HNS account to register; BlobFS isn't emulated.
+		AccountRegistry[PrimaryStandardAcct] = &AzureAccountResourceManager{
+			accountName:      emulatorInfo.AccountName,
+			accountKey:       emulatorInfo.AccountKey,
+			accountType:      EAccountType.Standard(),
+			emulatorEndpoint: endpoint,
+		}
+	} else if GlobalConfig.StaticResources() {
 		acctInfo := GlobalConfig.E2EAuthConfig.StaticStgAcctInfo
 
 		AccountRegistry[PrimaryStandardAcct] = &AzureAccountResourceManager{
@@ -144,6 +296,22 @@ func AccountRegistryInitHook(a Asserter) {
 		AccountRegistry[PrimaryStandardAcct] = CreateAccount(a, EAccountType.Standard(), nil)
 		AccountRegistry[PrimaryHNSAcct] = CreateAccount(a, EAccountType.HierarchicalNamespaceEnabled(), nil)
 	}
+
+	if GlobalConfig.S3Enabled() {
+		s3Info := GlobalConfig.S3AuthConfig
+
+		acct, err := NewS3AccountResourceManager(s3Info.AccessKeyID, s3Info.SecretAccessKey, s3Info.Region)
+		a.NoError("create S3 account", err)
+		AccountRegistry[PrimaryS3Acct] = acct
+	}
+
+	if GlobalConfig.GCPEnabled() {
+		gcpInfo := GlobalConfig.GCPAuthConfig
+
+		acct, err := NewGCPAccountResourceManager(gcpInfo.ProjectID, option.WithCredentialsFile(gcpInfo.CredentialsPath))
+		a.NoError("create GCP account", err)
+		AccountRegistry[PrimaryGCPAcct] = acct
+	}
 }
 
 func AccountRegistryCleanupHook(a Asserter) {
@@ -151,6 +319,21 @@ func AccountRegistryCleanupHook(a Asserter) {
 		return // no need to attempt cleanup
 	}
 
+	if GlobalConfig.DebugSkipCleanup() {
+		a.Log("NEW_E2E_DEBUG_SKIP_CLEANUP is set; leaving the registered and pooled accounts in place")
+		return
+	}
+
+	accountPool.Lock()
+	defer accountPool.Unlock()
+
+	for _, v := range accountPool.accounts {
+		if acct, ok := v.(*AzureAccountResourceManager); ok && acct.ManagementClient() != nil {
+			a.Assert("Delete pooled account", NoError{}, acct.ManagementClient().Delete())
+		}
+	}
+	accountPool.accounts = map[AccountType]AccountResourceManager{}
+
 	for _, v := range AccountRegistry {
 		if acct, ok := v.(*AzureAccountResourceManager); ok && acct.ManagementClient() != nil {
 			managementClient := acct.ManagementClient()
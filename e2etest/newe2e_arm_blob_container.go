@@ -0,0 +1,112 @@
+package e2etest
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+)
+
+// ARMBlobContainer implements the parts of the Storage Resource Provider's container API that the blob service doesn't offer,
+// namely container-level immutability policies and legal holds.
+// https://learn.microsoft.com/en-us/rest/api/storagerp/blob-containers
+type ARMBlobContainer struct {
+	*ARMStorageAccount
+	ContainerName string
+}
+
+func (c *ARMBlobContainer) ManagementURI() url.URL {
+	baseURI := c.ARMStorageAccount.ManagementURI()
+	newURI := baseURI.JoinPath("blobServices/default/containers", c.ContainerName)
+
+	return *newURI
+}
+
+const (
+	ARMImmutabilityPolicyStateLocked   = "Locked"
+	ARMImmutabilityPolicyStateUnlocked = "Unlocked"
+)
+
+// ARMImmutabilityPolicy is https://learn.microsoft.com/en-us/rest/api/storagerp/blob-containers/create-or-update-immutability-policy#immutabilitypolicy
+type ARMImmutabilityPolicy struct {
+	ETag       string                          `json:"etag,omitempty"`
+	Properties ARMImmutabilityPolicyProperties `json:"properties"`
+}
+
+type ARMImmutabilityPolicyProperties struct {
+	ImmutabilityPeriodSinceCreationInDays int   `json:"immutabilityPeriodSinceCreationInDays"`
+	AllowProtectedAppendWrites            *bool `json:"allowProtectedAppendWrites,omitempty"`
+	AllowProtectedAppendWritesAll         *bool `json:"allowProtectedAppendWritesAll,omitempty"`
+	// State is "Locked" or "Unlocked", and is set by the service.
+	State string `json:"state,omitempty"`
+}
+
+// ARMLegalHold is https://learn.microsoft.com/en-us/rest/api/storagerp/blob-containers/set-legal-hold#legalhold
+type ARMLegalHold struct {
+	HasLegalHold bool     `json:"hasLegalHold,omitempty"` // set by the service
+	Tags         []string `json:"tags"`                   // 3 to 23 alphanumeric characters each
+}
+
+// SetImmutabilityPolicy creates or replaces the container's (unlocked) time-based retention policy.
+func (c *ARMBlobContainer) SetImmutabilityPolicy(props ARMImmutabilityPolicyProperties) (*ARMImmutabilityPolicy, error) {
+	var out ARMImmutabilityPolicy
+	_, err := PerformRequest(context.Background(), c, ARMRequestSettings{
+		Method:        http.MethodPut,
+		PathExtension: "immutabilityPolicies/default",
+		Body:          ARMImmutabilityPolicy{Properties: props},
+	}, &out)
+	return &out, err
+}
+
+func (c *ARMBlobContainer) GetImmutabilityPolicy() (*ARMImmutabilityPolicy, error) {
+	var out ARMImmutabilityPolicy
+	_, err := PerformRequest(context.Background(), c, ARMRequestSettings{
+		Method:        http.MethodGet,
+		PathExtension: "immutabilityPolicies/default",
+	}, &out)
+	return &out, err
+}
+
+// LockImmutabilityPolicy locks the policy with the given etag. A locked policy can't be removed, or its period shortened,
+// so the container and its blobs can't be deleted until the period has lapsed.
+func (c *ARMBlobContainer) LockImmutabilityPolicy(etag string) (*ARMImmutabilityPolicy, error) {
+	var out ARMImmutabilityPolicy
+	_, err := PerformRequest(context.Background(), c, ARMRequestSettings{
+		Method:        http.MethodPost,
+		PathExtension: "immutabilityPolicies/default/lock",
+		Headers:       http.Header{"If-Match": []string{etag}},
+	}, &out)
+	return &out, err
+}
+
+// DeleteImmutabilityPolicy removes the unlocked policy with the given etag; a container without a policy is not an error.
+func (c *ARMBlobContainer) DeleteImmutabilityPolicy(etag string) error {
+	_, err := PerformDeleteRequest(context.Background(), c, ARMRequestSettings{
+		PathExtension: "immutabilityPolicies/default",
+		Headers:       http.Header{"If-Match": []string{etag}},
+	})
+	return err
+}
+
+// SetLegalHold adds tags to the container's legal hold; the container is held while it has any tags.
+func (c *ARMBlobContainer) SetLegalHold(tags []string) (*ARMLegalHold, error) {
+	var out ARMLegalHold
+	_, err := PerformRequest(context.Background(), c, ARMRequestSettings{
+		Method:        http.MethodPost,
+		PathExtension: "setLegalHold",
+		Body:          ARMLegalHold{Tags: tags},
+		Retryable:     true, // adding a tag twice is harmless
+	}, &out)
+	return &out, err
+}
+
+// ClearLegalHold removes tags from the container's legal hold.
+func (c *ARMBlobContainer) ClearLegalHold(tags []string) (*ARMLegalHold, error) {
+	var out ARMLegalHold
+	_, err := PerformRequest(context.Background(), c, ARMRequestSettings{
+		Method:        http.MethodPost,
+		PathExtension: "clearLegalHold",
+		Body:          ARMLegalHold{Tags: tags},
+		Retryable:     true,
+	}, &out)
+	return &out, err
+}
@@ -0,0 +1,194 @@
+package e2etest
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+)
+
+// ARMStorageAccountClient is the management surface shared by modern (ARMStorageAccount) and classic (ARMClassicStorageAccount) storage accounts.
+type ARMStorageAccountClient interface {
+	ARMSubject
+	GetKeys() (*ARMStorageAccountListKeysResult, error)
+	Delete() error
+}
+
+// Ensure both account clients match the interface
+func init() {
+	_ = []ARMStorageAccountClient{&ARMStorageAccount{}, &ARMClassicStorageAccount{}}
+}
+
+// ARMClassicStorageAccount implements an API to interface with a singular classic (ASM) Azure Storage account via the Classic Storage Resource Provider.
+// Classic accounts are surfaced through ARM as Microsoft.ClassicStorage/storageAccounts, but shape their requests and responses differently from modern accounts.
+type ARMClassicStorageAccount struct {
+	*ARMResourceGroup
+	AccountName string
+}
+
+func (sa *ARMClassicStorageAccount) ManagementURI() url.URL {
+	baseURI := sa.ARMResourceGroup.ManagementURI()
+	newURI := baseURI.JoinPath("providers/Microsoft.ClassicStorage/storageAccounts", sa.AccountName)
+
+	return *newURI
+}
+
+func (sa *ARMClassicStorageAccount) PrepareRequest(reqSettings *ARMRequestSettings) {
+	if reqSettings.Query == nil {
+		reqSettings.Query = make(url.Values)
+	}
+
+	if !reqSettings.Query.Has("api-version") {
+		reqSettings.Query.Add("api-version", "2016-11-01") // The classic provider doesn't know the modern API versions
+	}
+}
+
+// GetResourceManager should not be called repeatedly; it makes calls to REST APIs and does not cache.
+func (sa *ARMClassicStorageAccount) GetResourceManager() (*AzureAccountResourceManager, error) {
+	keyList, err := sa.GetKeys()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get account keys: %w", err)
+	}
+
+	if len(keyList.Keys) == 0 || keyList.Keys[0].Value == "" {
+		return nil, fmt.Errorf("failed to find suitable account key")
+	}
+
+	return &AzureAccountResourceManager{
+		accountName:      sa.AccountName,
+		accountKey:       keyList.Keys[0].Value,
+		accountKeyName:   keyList.Keys[0].KeyName,
+		accountType:      EAccountType.Classic(),
+		classicARMClient: sa,
+	}, nil
+}
+
+// Create creates the account, waiting out the provisioning if it completes asynchronously, and returns its properties.
+// As with RegenerateKey, the operation's result doesn't carry the account, so it's fetched once the creation is done.
+func (sa *ARMClassicStorageAccount) Create(params ARMClassicStorageAccountCreateParams) (*ARMClassicStorageAccountProperties, error) {
+	armResp, err := PerformRequest[any](context.Background(), sa, ARMRequestSettings{
+		Method: http.MethodPut,
+		Body:   params,
+	}, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create classic account: %w", err)
+	}
+
+	if armResp != nil && armResp.Status != ARMStatusSucceeded {
+		return nil, fmt.Errorf("failed to create classic account (status %s): %s: %s", armResp.Status, armResp.Error.Code, armResp.Error.Message)
+	}
+
+	return sa.GetProperties()
+}
+
+// Delete deletes the account; an account that's already gone is not an error.
+func (sa *ARMClassicStorageAccount) Delete() error {
+	_, err := PerformDeleteRequest(context.Background(), sa, ARMRequestSettings{})
+	return err
+}
+
+func (sa *ARMClassicStorageAccount) GetProperties() (*ARMClassicStorageAccountProperties, error) {
+	var out ARMClassicStorageAccountProperties
+	_, err := PerformRequest(context.Background(), sa, ARMRequestSettings{
+		Method: http.MethodGet,
+	}, &out)
+	return &out, err
+}
+
+// GetKeys lists the primary and secondary keys, in the same shape as ARMStorageAccount.GetKeys, as key1 and key2 respectively.
+func (sa *ARMClassicStorageAccount) GetKeys() (*ARMStorageAccountListKeysResult, error) {
+	var resp ARMClassicStorageAccountKeys
+
+	_, err := PerformRequest(context.Background(), sa, ARMRequestSettings{
+		Method:        http.MethodPost,
+		PathExtension: "listKeys",
+		Retryable:     true, // listing doesn't change anything
+	}, &resp)
+	if err != nil {
+		return nil, err
+	}
+
+	return resp.ListKeysResult(), nil
+}
+
+const (
+	ARMClassicStorageAccountKeyTypePrimary   = "Primary"
+	ARMClassicStorageAccountKeyTypeSecondary = "Secondary"
+)
+
+// RegenerateKey regenerates the primary or secondary key (see the above constants) and returns the new keys.
+// Classic key regeneration may complete asynchronously, and its result doesn't carry the keys, so they're listed once it's done.
+func (sa *ARMClassicStorageAccount) RegenerateKey(keyType string) (*ARMStorageAccountListKeysResult, error) {
+	armResp, err := PerformRequest[any](context.Background(), sa, ARMRequestSettings{
+		Method:        http.MethodPost,
+		PathExtension: "regenerateKey",
+		Body:          ARMClassicStorageAccountRegenerateKeyParams{KeyType: keyType},
+	}, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to regenerate %s key: %w", keyType, err)
+	}
+
+	if armResp != nil && armResp.Status != ARMStatusSucceeded {
+		return nil, fmt.Errorf("failed to regenerate %s key (status %s): %s: %s", keyType, armResp.Status, armResp.Error.Code, armResp.Error.Message)
+	}
+
+	return sa.GetKeys()
+}
+
+// =========== Classic Types ===========
+
+// ARMClassicStorageAccountProperties is the response of a classic storage account GET.
+type ARMClassicStorageAccountProperties struct {
+	ID         string `json:"id"`
+	Location   string `json:"location"`
+	Name       string `json:"name"`
+	Properties struct {
+		AccountType        string   `json:"accountType"` // e.g. "Standard-GRS"
+		CreationTime       string   `json:"creationTime"`
+		Endpoints          []string `json:"endpoints"`
+		GeoPrimaryRegion   string   `json:"geoPrimaryRegion"`
+		GeoSecondaryRegion string   `json:"geoSecondaryRegion"`
+		ProvisioningState  string   `json:"provisioningState"`
+		Status             string   `json:"status"`
+		StatusOfPrimary    string   `json:"statusOfPrimary"`
+		StatusOfSecondary  string   `json:"statusOfSecondary"`
+	} `json:"properties"`
+	Type string `json:"type"`
+}
+
+const (
+	ARMClassicStorageAccountTypeStandardLRS   = "Standard-LRS"
+	ARMClassicStorageAccountTypeStandardGRS   = "Standard-GRS"
+	ARMClassicStorageAccountTypeStandardRAGRS = "Standard-RAGRS"
+	ARMClassicStorageAccountTypeStandardZRS   = "Standard-ZRS"
+	ARMClassicStorageAccountTypePremiumLRS    = "Premium-LRS"
+)
+
+// ARMClassicStorageAccountCreateParams is the body of a classic storage account PUT. Classic accounts have no kind or SKU; the account type covers both.
+type ARMClassicStorageAccountCreateParams struct {
+	Location   string                                   `json:"location"`
+	Properties ARMClassicStorageAccountCreateProperties `json:"properties"`
+}
+
+type ARMClassicStorageAccountCreateProperties struct {
+	AccountType string `json:"accountType"` // See the above constants
+}
+
+type ARMClassicStorageAccountKeys struct {
+	PrimaryKey   string `json:"primaryKey"`
+	SecondaryKey string `json:"secondaryKey"`
+}
+
+// ListKeysResult converts the classic keys to the shape modern accounts list their keys in. Classic keys always have full permissions.
+func (k ARMClassicStorageAccountKeys) ListKeysResult() *ARMStorageAccountListKeysResult {
+	return &ARMStorageAccountListKeysResult{
+		Keys: []ARMStorageAccountKey{
+			{KeyName: "key1", Permissions: ARMStorageAccountKeyPermissionFull, Value: k.PrimaryKey},
+			{KeyName: "key2", Permissions: ARMStorageAccountKeyPermissionFull, Value: k.SecondaryKey},
+		},
+	}
+}
+
+type ARMClassicStorageAccountRegenerateKeyParams struct {
+	KeyType string `json:"keyType"`
+}
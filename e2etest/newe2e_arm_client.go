@@ -2,14 +2,20 @@ package e2etest
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"github.com/Azure/azure-storage-azcopy/v10/common"
 	"io"
+	"math/rand"
 	"net/http"
 	"net/url"
 	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
 )
 
 type ARMSubject interface {
@@ -42,40 +48,175 @@ func init() {
 // It can be explored with "Get", if you know precisely what you want.
 type ARMUnimplementedStruct json.RawMessage
 
+// Get walks Key, one object key or (for arrays) numeric index at a time, and unmarshals the value found into out.
+// e.g. []string{"keys", "0", "value"}
 func (s ARMUnimplementedStruct) Get(Key []string, out interface{}) error {
-	if reflect.TypeOf(out).Kind() != reflect.Pointer {
-		return errors.New("")
+	if out == nil || reflect.TypeOf(out).Kind() != reflect.Pointer {
+		return errors.New("out must be a pointer")
 	}
 
-	object := s
-	for len(Key) > 0 {
-		dict := make(map[string]json.RawMessage)
-		err := json.Unmarshal(object, &dict)
-		if err != nil {
-			return err
+	object := json.RawMessage(s)
+	for k, v := range Key {
+		parent := strings.Join(Key[:k], "/")
+		var ok bool
+
+		switch trimmed := bytes.TrimLeft(object, " \t\r\n"); {
+		case len(trimmed) > 0 && trimmed[0] == '[':
+			var list []json.RawMessage
+			if err := json.Unmarshal(object, &list); err != nil {
+				return fmt.Errorf("failed to unmarshal array at %q: %w", parent, err)
+			}
+
+			idx, err := strconv.Atoi(v)
+			if err != nil {
+				return fmt.Errorf("value at %q is an array, so %q must be an index", parent, v)
+			}
+
+			if ok = idx >= 0 && idx < len(list); ok {
+				object = list[idx]
+			}
+		case len(trimmed) > 0 && trimmed[0] == '{':
+			dict := make(map[string]json.RawMessage)
+			if err := json.Unmarshal(object, &dict); err != nil {
+				return fmt.Errorf("failed to unmarshal object at %q: %w", parent, err)
+			}
+
+			object, ok = dict[v]
+		default:
+			return fmt.Errorf("value at %q is not an object or array", parent)
+		}
+
+		if !ok {
+			return fmt.Errorf("key %q not found", strings.Join(Key[:k+1], "/"))
 		}
+	}
 
-		object = ARMUnimplementedStruct(dict[Key[0]])
+	err := json.Unmarshal(object, out)
+	if err != nil {
+		return fmt.Errorf("failed to unmarshal value at %q: %w", strings.Join(Key, "/"), err)
 	}
 
-	return json.Unmarshal(object, out)
+	return nil
 }
 
+// MarshalJSON and UnmarshalJSON pass the raw JSON through untouched; without them, it'd be treated as a base64 []byte.
+func (s ARMUnimplementedStruct) MarshalJSON() ([]byte, error) {
+	return json.RawMessage(s).MarshalJSON()
+}
+
+func (s *ARMUnimplementedStruct) UnmarshalJSON(data []byte) error {
+	return (*json.RawMessage)(s).UnmarshalJSON(data)
+}
+
+const (
+	defaultARMMaxAttempts = 5
+	defaultARMRetryDelay  = time.Second
+	maxARMRetryDelay      = time.Minute
+	defaultARMMaxPages    = 1000
+)
+
 type ARMClient struct {
 	OAuth      AccessToken
 	HttpClient *http.Client
+	// MaxAttempts caps how many times a retryable request is sent. Defaults to defaultARMMaxAttempts.
+	MaxAttempts int
+	// RetryDelay is the base of the exponential backoff between attempts. Defaults to defaultARMRetryDelay.
+	RetryDelay time.Duration
+	// Cassette is the file that requests are recorded to, or replayed from, when E2E_ARM_RECORD or E2E_ARM_REPLAY is set (see armRecordingTransport).
+	Cassette string
+
+	clientOnce sync.Once
+	client     *http.Client
 }
 
 func (c *ARMClient) Client() *ARMClient {
 	return c
 }
 
+// getHTTPClient returns HttpClient, wrapped by the cassette recorder and the E2E_HTTP_LOG request log as configured.
 func (c *ARMClient) getHTTPClient() *http.Client {
-	if c.HttpClient != nil {
-		return c.HttpClient
+	// The wrapped client must persist across requests, to replay polls of an async operation in order.
+	c.clientOnce.Do(func() {
+		client := http.DefaultClient
+		if c.HttpClient != nil {
+			client = c.HttpClient
+		}
+
+		transport := client.Transport
+		if transport == nil {
+			transport = http.DefaultTransport
+		}
+
+		mode := armRecordModeFromEnv()
+		if c.Cassette != "" && mode != ARMRecordModeOff {
+			transport = newARMRecordingTransport(mode, c.Cassette, transport)
+		}
+
+		// Logged outermost, so replayed requests are logged just like live ones.
+		if log := sharedHTTPLog(); log != nil {
+			transport = newHTTPLogTransport(transport, log, &httpLogMu)
+		} else if c.Cassette == "" || mode == ARMRecordModeOff {
+			c.client = client
+			return
+		}
+
+		wrapped := *client
+		wrapped.Transport = transport
+		c.client = &wrapped
+	})
+
+	return c.client
+}
+
+func (c *ARMClient) maxAttempts() int {
+	if c.MaxAttempts > 0 {
+		return c.MaxAttempts
 	}
 
-	return http.DefaultClient
+	return defaultARMMaxAttempts
+}
+
+// retryDelay picks how long to wait before the next attempt.
+// Retry-After is honored on 429/503; otherwise, the delay backs off exponentially with jitter.
+func (c *ARMClient) retryDelay(attempt int, resp *http.Response) time.Duration {
+	if resp != nil && (resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusServiceUnavailable) {
+		if retryAfter := resp.Header.Get("Retry-After"); retryAfter != "" {
+			if seconds, err := strconv.Atoi(retryAfter); err == nil && seconds >= 0 {
+				return time.Duration(seconds) * time.Second
+			} else if date, err := http.ParseTime(retryAfter); err == nil {
+				if wait := time.Until(date); wait > 0 {
+					return wait
+				}
+				return 0
+			}
+		}
+	}
+
+	base := c.RetryDelay
+	if base <= 0 {
+		base = defaultARMRetryDelay
+	}
+
+	delay := base << (attempt - 1)
+	if delay <= 0 || delay > maxARMRetryDelay {
+		delay = maxARMRetryDelay
+	}
+
+	// Full jitter on the upper half, so concurrent setups don't retry in lockstep.
+	return delay/2 + time.Duration(rand.Int63n(int64(delay/2)+1))
+}
+
+func isRetryableARMStatus(status int) bool {
+	switch status {
+	case http.StatusTooManyRequests,
+		http.StatusInternalServerError,
+		http.StatusBadGateway,
+		http.StatusServiceUnavailable,
+		http.StatusGatewayTimeout:
+		return true
+	default:
+		return false
+	}
 }
 
 func (c *ARMClient) Token() AccessToken {
@@ -95,9 +236,62 @@ type ARMRequestSettings struct { // All values will be added to the request
 	Query         url.Values
 	Headers       http.Header
 	Body          interface{}
+	// Retryable marks a POST as safe to send more than once. GET, HEAD, PUT and DELETE are always retried.
+	Retryable bool
+	// MaxAttempts overrides the client's MaxAttempts for this request, including polls of any resulting async operation.
+	// Requests that aren't retryable (see IsRetryable) are still only sent once.
+	MaxAttempts int
+	// MaxPages caps how many pages PerformPagedRequest will follow. Defaults to defaultARMMaxPages.
+	MaxPages int
+	// DeferAsync hands back a long-running operation unresolved, for the caller to PollWithCallback, rather than blocking on it.
+	DeferAsync bool
+	// Poll controls how a long-running operation is resolved, when it isn't deferred.
+	Poll ARMPollOptions
+}
+
+func (s *ARMRequestSettings) IsRetryable() bool {
+	switch s.Method {
+	case http.MethodGet, http.MethodHead, http.MethodPut, http.MethodDelete:
+		return true
+	case http.MethodPost:
+		return s.Retryable
+	default:
+		return false
+	}
+}
+
+// maxAttempts is how many times this request may be sent.
+func (s *ARMRequestSettings) maxAttempts(c *ARMClient) int {
+	if !s.IsRetryable() {
+		return 1
+	}
+
+	if s.MaxAttempts > 0 {
+		return s.MaxAttempts
+	}
+
+	return c.maxAttempts()
 }
 
-func (s *ARMRequestSettings) CreateRequest(baseURI url.URL) (*http.Request, error) {
+// armRequestIDs formats the IDs ARM tags a response with, to quote in errors when chasing up a failure with the service.
+func armRequestIDs(resp *http.Response) string {
+	var ids []string
+	if id := resp.Header.Get("x-ms-correlation-request-id"); id != "" {
+		ids = append(ids, "correlation ID "+id)
+	}
+	if id := resp.Header.Get("x-ms-request-id"); id != "" {
+		ids = append(ids, "request ID "+id)
+	}
+
+	if len(ids) == 0 {
+		return ""
+	}
+
+	return " (" + strings.Join(ids, ", ") + ")"
+}
+
+// CreateRequest also returns the request body, so that it can be rewound between attempts.
+func (s *ARMRequestSettings) CreateRequest(ctx context.Context, baseURI url.URL) (*http.Request, io.ReadSeeker, error) {
 	query := baseURI.RawQuery
 	if len(query) > 0 {
 		query += "&"
@@ -109,53 +303,164 @@ func (s *ARMRequestSettings) CreateRequest(baseURI url.URL) (*http.Request, erro
 	if s.Body != nil {
 		buf, err := json.Marshal(s.Body)
 		if err != nil {
-			return nil, fmt.Errorf("failed to marshal body: %w", err)
+			return nil, nil, fmt.Errorf("failed to marshal body: %w", err)
 		}
 
 		body = bytes.NewReader(buf)
 	}
 
-	newReq, err := http.NewRequest(s.Method, baseURI.String(), body)
+	newReq, err := http.NewRequestWithContext(ctx, s.Method, baseURI.String(), body)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
+		return nil, nil, fmt.Errorf("failed to create request: %w", err)
 	}
 
-	newReq.Header = s.Headers
+	newReq.Header = s.Headers.Clone()
+	if newReq.Header == nil {
+		newReq.Header = make(http.Header)
+	}
 
 	if s.PathExtension != "" {
 		newReq.URL = newReq.URL.JoinPath(s.PathExtension)
 	}
 
-	return newReq, nil
+	return newReq, body, nil
 }
 
-// PerformRequest will deserialize to target (which assumes the target is a pointer)
-// If an LRO is required, an *ARMAsyncResponse will be returned. Otherwise, both armResp and err will be nil, and target will be written to.
-func PerformRequest[Props any](subject ARMSubject, reqSettings ARMRequestSettings, target *Props) (armResp *ARMAsyncResponse[Props], err error) {
+// sendARMRequest authorizes and sends r, retrying throttled and transient failures up to maxAttempts times.
+// The body, if any, is rewound before every attempt. The number of attempts made is returned alongside the response.
+func sendARMRequest(subject ARMSubject, r *http.Request, body io.ReadSeeker, maxAttempts int) (resp *http.Response, attempt int, err error) {
 	c := subject.Client()
-	baseURI := subject.ManagementURI()
 	client := c.getHTTPClient()
 
+	r.Header["Content-Type"] = []string{"application/json; charset=utf-8"}
+	r.Header["Accept"] = []string{"application/json; charset=utf-8"}
+
+	for attempt = 1; ; attempt++ {
+		if body != nil {
+			if _, err = body.Seek(0, io.SeekStart); err != nil {
+				return nil, attempt, fmt.Errorf("failed to rewind request body: %w", err)
+			}
+			r.Body = io.NopCloser(body)
+		}
+
+		oAuthToken, err := subject.Token().FreshToken()
+		if err != nil {
+			return nil, attempt, fmt.Errorf("failed to get fresh token: %w", err)
+		}
+		r.Header["Authorization"] = []string{"Bearer " + oAuthToken}
+
+		resp, err = client.Do(r)
+		if attempt >= maxAttempts || (err == nil && !isRetryableARMStatus(resp.StatusCode)) {
+			if err != nil {
+				return nil, attempt, fmt.Errorf("failed to send request (%d attempts): %w", attempt, err)
+			}
+			return resp, attempt, nil
+		}
+
+		delay := c.retryDelay(attempt, resp)
+		if resp != nil {
+			_, _ = io.Copy(io.Discard, resp.Body)
+			_ = resp.Body.Close()
+		}
+
+		select {
+		case <-r.Context().Done():
+			return nil, attempt, fmt.Errorf("request cancelled after %d attempts: %w", attempt, r.Context().Err())
+		case <-time.After(delay):
+		}
+	}
+}
+
+// PerformRequest will deserialize to target (which assumes the target is a pointer)
+// If an LRO is required, an *ARMAsyncResponse will be returned; it is already resolved (per reqSettings.Poll), unless reqSettings.DeferAsync is set.
+// Otherwise, both armResp and err will be nil, and target will be written to.
+// ctx bounds the whole request, including retries and resolving the LRO.
+func PerformRequest[Props any](ctx context.Context, subject ARMSubject, reqSettings ARMRequestSettings, target *Props) (armResp *ARMAsyncResponse[Props], err error) {
+	armResp, _, err = PerformRequestWithResponse(ctx, subject, reqSettings, target)
+	return armResp, err
+}
+
+// ErrARMResourceNotFound is wrapped by the error for any request answered with a 404.
+var ErrARMResourceNotFound = errors.New("ARM resource not found")
+
+// PerformDeleteRequest deletes the subject, treating a resource that's already gone as success, so that teardown can safely be repeated.
+// existed reports whether there was anything to delete; ARM answers 404 (or, for some providers, 204) for a resource that isn't there.
+func PerformDeleteRequest(ctx context.Context, subject ARMSubject, reqSettings ARMRequestSettings) (existed bool, err error) {
+	reqSettings.Method = http.MethodDelete
+
+	_, resp, err := PerformRequestWithResponse[any](ctx, subject, reqSettings, nil)
+	if errors.Is(err, ErrARMResourceNotFound) || (err == nil && resp.StatusCode == http.StatusNoContent) {
+		return false, nil
+	}
+
+	return err == nil, err
+}
+
+// PerformHeadRequest checks up on the subject without fetching it, returning just the response headers.
+// A missing resource returns an error wrapping ErrARMResourceNotFound.
+func PerformHeadRequest(ctx context.Context, subject ARMSubject, reqSettings ARMRequestSettings) (http.Header, error) {
+	reqSettings.Method = http.MethodHead
+
 	if prep, ok := subject.(ARMRequestPreparer); ok {
 		prep.PrepareRequest(&reqSettings)
 	}
 
-	r, err := reqSettings.CreateRequest(baseURI)
+	r, body, err := reqSettings.CreateRequest(ctx, subject.ManagementURI())
 	if err != nil {
 		return nil, fmt.Errorf("failed to prepare request: %w", err)
 	}
 
-	oAuthToken, err := subject.Token().FreshToken()
-	r.Header = make(http.Header)
-	r.Header["Authorization"] = []string{"Bearer " + oAuthToken}
-	r.Header["Content-Type"] = []string{"application/json; charset=utf-8"}
-	r.Header["Accept"] = []string{"application/json; charset=utf-8"}
+	resp, attempt, err := sendARMRequest(subject, r, body, reqSettings.maxAttempts(subject.Client()))
+	if err != nil {
+		return nil, err
+	}
+	_ = resp.Body.Close() // HEAD responses have no body
+
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		return resp.Header, nil
+	case resp.StatusCode == http.StatusNotFound:
+		return nil, fmt.Errorf("%w (resp code 404)%s", ErrARMResourceNotFound, armRequestIDs(resp))
+	default:
+		return nil, fmt.Errorf("failed to head resource (resp code %d, %d attempts)%s", resp.StatusCode, attempt, armRequestIDs(resp))
+	}
+}
 
-	resp, err := client.Do(r)
+// ARMResponse is the initial HTTP response to an ARM request, kept for assertions and diagnostics.
+// For an async operation, this is the 202 that started it, not the operation's eventual result.
+type ARMResponse struct {
+	StatusCode int
+	Header     http.Header
+	Body       []byte // empty if the response was an async operation handle
+}
+
+// RequestID is ARM's x-ms-request-id, to correlate a failed test with service-side logs.
+func (r *ARMResponse) RequestID() string {
+	return r.Header.Get("x-ms-request-id")
+}
+
+// PerformRequestWithResponse is PerformRequest, additionally returning the initial HTTP response.
+// out is returned whenever ARM responded, including alongside an error (e.g. a 4xx), so that its request ID can be logged.
+func PerformRequestWithResponse[Props any](ctx context.Context, subject ARMSubject, reqSettings ARMRequestSettings, target *Props) (armResp *ARMAsyncResponse[Props], out *ARMResponse, err error) {
+	c := subject.Client()
+	baseURI := subject.ManagementURI()
+
+	if prep, ok := subject.(ARMRequestPreparer); ok {
+		prep.PrepareRequest(&reqSettings)
+	}
+
+	r, body, err := reqSettings.CreateRequest(ctx, baseURI)
 	if err != nil {
-		return nil, fmt.Errorf("failed to send request: %w", err)
+		return nil, nil, fmt.Errorf("failed to prepare request: %w", err)
 	}
 
+	resp, attempt, err := sendARMRequest(subject, r, body, reqSettings.maxAttempts(c))
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+	out = &ARMResponse{StatusCode: resp.StatusCode, Header: resp.Header}
+
 	switch resp.StatusCode {
 	case 202: // LRO pattern; grab Azure-AsyncOperation and resolve it.
 		newTarget := resp.Header.Get("Azure-Asyncoperation")
@@ -164,34 +469,165 @@ func PerformRequest[Props any](subject ARMSubject, reqSettings ARMRequestSetting
 		}
 
 		if newTarget != "" {
-			return ResolveAzureAsyncOperation(c.OAuth, newTarget, target)
+			armResp = NewARMAsyncResponse(c, newTarget, target)
+			if reqSettings.MaxAttempts > 0 {
+				armResp.maxAttempts = reqSettings.MaxAttempts
+			}
+			if reqSettings.DeferAsync {
+				return armResp, out, nil
+			}
+
+			armResp, err = armResp.resolve(ctx, reqSettings.Poll)
+			return armResp, out, err
 		} else if resp.Header.Get("Content-Length") == "0" {
-			return nil, fmt.Errorf("failed to handle async operation: no response data, Azure-Asyncoperation and Location are not found")
+			return nil, out, fmt.Errorf("failed to handle async operation: no response data, Azure-Asyncoperation and Location are not found")
 		}
 
 		// If we don't have an asyncop to check against, pull the body
 		fallthrough
-	case 200, 201: // immediate response
-		var buf []byte // Read the body
-		buf, err = io.ReadAll(resp.Body)
+	case 200, 201, 204: // immediate response
+		out.Body, err = io.ReadAll(resp.Body)
 		if err != nil {
-			return nil, fmt.Errorf("failed to read response body (resp code 200): %w", err)
+			return nil, out, fmt.Errorf("failed to read response body (resp code %d): %w", resp.StatusCode, err)
 		}
 
-		if len(buf) != 0 && target != nil {
-			err = json.Unmarshal(buf, target)
+		if len(out.Body) != 0 && target != nil {
+			err = json.Unmarshal(out.Body, target)
 			if err != nil {
-				return nil, fmt.Errorf("failed to parse response body: %w", err)
+				return nil, out, fmt.Errorf("failed to parse response body: %w", err)
 			}
 		}
 
-		return nil, nil
+		return nil, out, nil
 	default:
-		rBody, err := io.ReadAll(resp.Body)
+		out.Body, err = io.ReadAll(resp.Body)
+		if err != nil {
+			return nil, out, fmt.Errorf("failed to read response body (resp code %d): %w", resp.StatusCode, err)
+		}
+
+		if resp.StatusCode == http.StatusNotFound {
+			return nil, out, fmt.Errorf("%w (resp code 404)%s: %s", ErrARMResourceNotFound, armRequestIDs(resp), string(out.Body))
+		}
+
+		return nil, out, fmt.Errorf("failed to get access (resp code %d, %d attempts)%s: %s", resp.StatusCode, attempt, armRequestIDs(resp), string(out.Body))
+	}
+}
+
+// ARMPage is a single page of an ARM list operation.
+type ARMPage[T any] struct {
+	Value    []T    `json:"value"`
+	NextLink string `json:"nextLink"`
+}
+
+// PerformPagedRequest performs a list operation, following nextLink until every page has been read, and appends the
+// listed items to target. ctx is checked between pages, so a cancelled listing stops early with the pages read so far.
+func PerformPagedRequest[T any](ctx context.Context, subject ARMSubject, reqSettings ARMRequestSettings, target *[]T) error {
+	if target == nil {
+		return errors.New("target must not be nil")
+	}
+
+	return PerformPagedRequestWithHandler(ctx, subject, reqSettings, func(value json.RawMessage) error {
+		var items []T
+		if len(value) != 0 {
+			if err := json.Unmarshal(value, &items); err != nil {
+				return err
+			}
+		}
+
+		*target = append(*target, items...)
+		return nil
+	})
+}
+
+// PerformPagedRequestWithHandler is PerformPagedRequest for callers that want to handle each page's raw "value" array themselves.
+// An error from pageHandler stops the listing. A nextLink that was already visited, or more than reqSettings.MaxPages pages, fails the listing.
+func PerformPagedRequestWithHandler(ctx context.Context, subject ARMSubject, reqSettings ARMRequestSettings, pageHandler func(value json.RawMessage) error) error {
+	if pageHandler == nil {
+		return errors.New("pageHandler must not be nil")
+	}
+
+	if prep, ok := subject.(ARMRequestPreparer); ok {
+		prep.PrepareRequest(&reqSettings)
+	}
+
+	r, body, err := reqSettings.CreateRequest(ctx, subject.ManagementURI())
+	if err != nil {
+		return fmt.Errorf("failed to prepare request: %w", err)
+	}
+
+	maxAttempts := reqSettings.maxAttempts(subject.Client())
+	maxPages := common.Iff(reqSettings.MaxPages > 0, reqSettings.MaxPages, defaultARMMaxPages)
+	visited := map[string]bool{r.URL.String(): true}
+
+	for pageNum := 1; ; pageNum++ {
+		resp, attempt, err := sendARMRequest(subject, r, body, maxAttempts)
 		if err != nil {
-			return nil, fmt.Errorf("failed to read response body (resp code %d): %w", resp.StatusCode, err)
+			return fmt.Errorf("failed to list page %d: %w", pageNum, err)
+		}
+
+		buf, err := io.ReadAll(resp.Body)
+		_ = resp.Body.Close()
+		if err != nil {
+			return fmt.Errorf("failed to read page %d (resp code %d): %w", pageNum, resp.StatusCode, err)
+		}
+
+		if resp.StatusCode != http.StatusOK {
+			return fmt.Errorf("failed to list page %d (resp code %d, %d attempts)%s: %s", pageNum, resp.StatusCode, attempt, armRequestIDs(resp), string(buf))
 		}
 
-		return nil, fmt.Errorf("failed to get access (resp code %d): %s", resp.StatusCode, string(rBody))
+		var page struct { // ARMPage, with the value left raw for pageHandler
+			Value    json.RawMessage `json:"value"`
+			NextLink string          `json:"nextLink"`
+		}
+		if err = json.Unmarshal(buf, &page); err != nil {
+			return fmt.Errorf("failed to parse page %d: %w", pageNum, err)
+		}
+
+		if err = pageHandler(page.Value); err != nil {
+			return fmt.Errorf("failed to handle page %d: %w", pageNum, err)
+		}
+
+		if page.NextLink == "" {
+			return nil
+		}
+
+		if visited[page.NextLink] {
+			return fmt.Errorf("page %d links back to an already listed page (%s); refusing to loop", pageNum, page.NextLink)
+		}
+		visited[page.NextLink] = true
+
+		if pageNum >= maxPages {
+			return fmt.Errorf("listing exceeded %d pages", maxPages)
+		}
+
+		if err = ctx.Err(); err != nil {
+			return fmt.Errorf("listing cancelled after %d pages: %w", pageNum, err)
+		}
+
+		// nextLink carries the continuation token, and usually api-version. Options it leaves out are carried over from the original request,
+		// as are its headers, so every page is listed the same way.
+		next, err := url.Parse(page.NextLink)
+		if err != nil {
+			return fmt.Errorf("failed to parse nextLink of page %d: %w", pageNum, err)
+		}
+		query := next.Query()
+		for k, v := range reqSettings.Query {
+			if !query.Has(k) {
+				query[k] = v
+			}
+		}
+		next.RawQuery = query.Encode()
+
+		header := r.Header.Clone()
+		header.Del("Content-Type") // pages are fetched without a body
+
+		r, err = http.NewRequestWithContext(ctx, http.MethodGet, next.String(), nil)
+		if err != nil {
+			return fmt.Errorf("failed to create request for page %d: %w", pageNum+1, err)
+		}
+		r.Header = header
+		body = nil
+		reqSettings.Method = http.MethodGet // following pages are always GETs, and so retryable
+		maxAttempts = reqSettings.maxAttempts(subject.Client())
 	}
 }
@@ -1,6 +1,8 @@
 package e2etest
 
 import (
+	"context"
+	"fmt"
 	"net/http"
 	"net/url"
 )
@@ -37,7 +39,7 @@ type ARMManagedDiskCreateOrUpdateParams struct { // https://learn.microsoft.com/
 type ARMManagedDiskPutProperties struct {
 	CreationData                 ARMManagedDiskCreationData `json:"creationData"`
 	BurstingEnabled              *bool                      `json:"burstingEnabled,omitempty"`
-	CompletionPercent            string                     `json:"completionPercent"`
+	CompletionPercent            *float64                   `json:"completionPercent,omitempty"`
 	DataAccessAuthMode           *string                    `json:"dataAccessAuthMode,omitempty"` // AzureActiveDirectory or None
 	DiskAccessId                 *string                    `json:"diskAccessId,omitempty"`
 	DiskIOPSReadWrite            *uint64                    `json:"diskIOPSReadWrite,omitempty"`
@@ -57,6 +59,11 @@ type ARMManagedDiskPutProperties struct {
 	SupportedCapabilities        ARMUnimplementedStruct     `json:"supportedCapabilities,omitempty"`
 	SupportsHibernation          *bool                      `json:"supportsHibernation,omitempty"`
 	Tier                         *string                    `json:"tier,omitempty"` // Perf tier https://azure.microsoft.com/en-us/pricing/details/managed-disks/ does not apply to ultra
+
+	// Read-only
+	DiskSizeBytes     *uint64 `json:"diskSizeBytes,omitempty"`
+	DiskState         string  `json:"diskState,omitempty"` // e.g. ReadyToUpload, ActiveUpload, Unattached
+	ProvisioningState string  `json:"provisioningState,omitempty"`
 }
 
 type ARMManagedDiskCreationData struct {
@@ -86,7 +93,7 @@ const (
 
 func (md *ARMManagedDisk) CreateOrUpdate(params ARMManagedDiskCreateOrUpdateParams) (*ARMManagedDiskInfo, error) {
 	var out ARMManagedDiskInfo
-	_, err := PerformRequest(md, ARMRequestSettings{ // https://learn.microsoft.com/en-us/rest/api/compute/disks/create-or-update?tabs=HTTP
+	_, err := PerformRequest(context.Background(), md, ARMRequestSettings{ // https://learn.microsoft.com/en-us/rest/api/compute/disks/create-or-update?tabs=HTTP
 		Method: http.MethodPut,
 		Body:   params,
 	}, &out)
@@ -94,17 +101,16 @@ func (md *ARMManagedDisk) CreateOrUpdate(params ARMManagedDiskCreateOrUpdatePara
 	return &out, err
 }
 
+// Delete deletes the disk; a disk that's already gone is not an error.
 func (md *ARMManagedDisk) Delete() error {
-	_, err := PerformRequest[any](md, ARMRequestSettings{
-		Method: http.MethodDelete,
-	}, nil)
+	_, err := PerformDeleteRequest(context.Background(), md, ARMRequestSettings{})
 
 	return err
 }
 
 func (md *ARMManagedDisk) Get() (*ARMManagedDiskInfo, error) { // https://learn.microsoft.com/en-us/rest/api/compute/disks/get?tabs=HTTP
 	var out ARMManagedDiskInfo
-	_, err := PerformRequest(md, ARMRequestSettings{
+	_, err := PerformRequest(context.Background(), md, ARMRequestSettings{
 		Method: http.MethodGet,
 	}, &out)
 
@@ -117,24 +123,48 @@ type ARMManagedDiskGrantAccessParams struct {
 	GetSecureVMGuestStateSAS *bool  `json:"getSecureVMGuestStateSAS,omitempty"`
 }
 
+const (
+	ARMManagedDiskAccessLevelRead  = "Read"
+	ARMManagedDiskAccessLevelWrite = "Write" // Only available to disks created with ARMManagedDiskCreateOptionUpload
+)
+
 type ARMManagedDiskAccessURI struct {
 	AccessSAS             string `json:"accessSAS"`
 	SecurityDataAccessSAS string `json:"securityDataAccessSAS"`
 }
 
+// armManagedDiskGrantAccessOutput catches the access URI whether it arrives immediately, or as the output of an async operation.
+type armManagedDiskGrantAccessOutput struct {
+	ARMManagedDiskAccessURI
+	Output *ARMManagedDiskAccessURI `json:"output"`
+}
+
 func (md *ARMManagedDisk) GrantAccess(params ARMManagedDiskGrantAccessParams) (*ARMManagedDiskAccessURI, error) { // https://learn.microsoft.com/en-us/rest/api/compute/disks/grant-access?tabs=HTTP
-	var out ARMManagedDiskAccessURI
-	_, err := PerformRequest(md, ARMRequestSettings{
-		Method:        http.MethodGet,
+	var out armManagedDiskGrantAccessOutput
+	_, err := PerformRequest(context.Background(), md, ARMRequestSettings{
+		Method:        http.MethodPost,
 		Body:          params,
 		PathExtension: "beginGetAccess",
 	}, &out)
-	return &out, err
+	if err != nil {
+		return nil, err
+	}
+
+	if out.Output != nil {
+		return out.Output, nil
+	}
+	if out.AccessSAS == "" {
+		return nil, fmt.Errorf("failed to grant %s access to disk %s: no access SAS was returned", params.AccessLevel, md.DiskName)
+	}
+
+	return &out.ARMManagedDiskAccessURI, nil
 }
 
-func (md *ARMManagedDisk) RevokeAccess() error {
-	_, err := PerformRequest[any](md, ARMRequestSettings{
-		Method: http.MethodPost,
+func (md *ARMManagedDisk) RevokeAccess() error { // https://learn.microsoft.com/en-us/rest/api/compute/disks/revoke-access?tabs=HTTP
+	_, err := PerformRequest[any](context.Background(), md, ARMRequestSettings{
+		Method:        http.MethodPost,
+		PathExtension: "endGetAccess",
+		Retryable:     true, // revoking twice is harmless
 	}, nil)
 	return err
 }
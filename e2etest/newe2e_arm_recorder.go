@@ -0,0 +1,219 @@
+package e2etest
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sync"
+)
+
+/*
+ARM traffic can be recorded to, and replayed from, a "cassette" (a JSON file, conventionally under testdata/arm), so that
+tests of the ARM layer can run without a subscription (e.g. in PR validation for forks).
+
+Set E2E_ARM_RECORD=1 to record every request made through an ARMClient with a Cassette, or E2E_ARM_REPLAY=1 to serve them
+from the cassette instead of the network. Otherwise, cassettes are ignored.
+*/
+
+type ARMRecordMode uint8
+
+const (
+	ARMRecordModeOff ARMRecordMode = iota
+	ARMRecordModeRecord
+	ARMRecordModeReplay
+)
+
+func armRecordModeFromEnv() ARMRecordMode {
+	switch {
+	case os.Getenv("E2E_ARM_RECORD") == "1":
+		return ARMRecordModeRecord
+	case os.Getenv("E2E_ARM_REPLAY") == "1":
+		return ARMRecordModeReplay
+	default:
+		return ARMRecordModeOff
+	}
+}
+
+// armInteraction is a single recorded request and its response.
+// Request headers aren't kept at all (so neither is Authorization), and the body is kept only as a hash to match on.
+type armInteraction struct {
+	Method     string      `json:"method"`
+	URI        string      `json:"uri"` // path and query; the host is irrelevant when replaying
+	BodySHA256 string      `json:"bodySHA256,omitempty"`
+	StatusCode int         `json:"statusCode"`
+	Header     http.Header `json:"header,omitempty"`
+	Body       string      `json:"body,omitempty"`
+}
+
+func (i armInteraction) key() string {
+	return i.Method + " " + i.URI + " " + i.BodySHA256
+}
+
+type armCassette struct {
+	Interactions []armInteraction `json:"interactions"`
+}
+
+var armSecretPatterns = []*regexp.Regexp{
+	// Account keys, as listed by both modern and classic storage accounts
+	regexp.MustCompile(`("(?:value|primaryKey|secondaryKey)"\s*:\s*")[^"]*(")`),
+	// SAS signatures, e.g. in a managed disk's accessSAS
+	regexp.MustCompile(`([?&]sig=)[^&"\\]*()`),
+}
+
+// sanitizeARMBody masks secrets in a response body before it's written to disk.
+func sanitizeARMBody(body string) string {
+	for _, pattern := range armSecretPatterns {
+		body = pattern.ReplaceAllString(body, "${1}REDACTED${2}")
+	}
+
+	return body
+}
+
+// armRecordingTransport records to, or replays from, a cassette. Requests that match the same key (e.g. repeated polls of
+// one async operation) are replayed in the order they were recorded.
+type armRecordingTransport struct {
+	mode         ARMRecordMode
+	cassettePath string
+	inner        http.RoundTripper
+
+	mu       sync.Mutex
+	loaded   bool
+	loadErr  error
+	cassette armCassette
+	replayed map[string]int // key -> how many matching interactions have been served
+}
+
+func newARMRecordingTransport(mode ARMRecordMode, cassettePath string, inner http.RoundTripper) *armRecordingTransport {
+	return &armRecordingTransport{
+		mode:         mode,
+		cassettePath: cassettePath,
+		inner:        inner,
+		replayed:     map[string]int{},
+	}
+}
+
+// load reads the cassette for replay, once. The caller must hold mu.
+func (t *armRecordingTransport) load() error {
+	if t.loaded {
+		return t.loadErr
+	}
+	t.loaded = true
+
+	buf, err := os.ReadFile(t.cassettePath)
+	if err != nil {
+		t.loadErr = fmt.Errorf("failed to read ARM cassette: %w", err)
+	} else if err = json.Unmarshal(buf, &t.cassette); err != nil {
+		t.loadErr = fmt.Errorf("failed to parse ARM cassette %s: %w", t.cassettePath, err)
+	}
+
+	return t.loadErr
+}
+
+func (t *armRecordingTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	interaction := armInteraction{
+		Method: r.Method,
+		URI:    r.URL.RequestURI(),
+	}
+
+	if r.Body != nil {
+		reqBody, err := io.ReadAll(r.Body)
+		_ = r.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read request body: %w", err)
+		}
+
+		if len(reqBody) != 0 {
+			sum := sha256.Sum256(reqBody)
+			interaction.BodySHA256 = hex.EncodeToString(sum[:])
+		}
+		r.Body = io.NopCloser(bytes.NewReader(reqBody))
+	}
+
+	if t.mode == ARMRecordModeReplay {
+		return t.replay(r, interaction)
+	}
+
+	return t.record(r, interaction)
+}
+
+func (t *armRecordingTransport) replay(r *http.Request, interaction armInteraction) (*http.Response, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if err := t.load(); err != nil {
+		return nil, err
+	}
+
+	key := interaction.key()
+	skip := t.replayed[key]
+	for _, v := range t.cassette.Interactions {
+		if v.key() != key {
+			continue
+		}
+
+		if skip > 0 {
+			skip--
+			continue
+		}
+
+		t.replayed[key]++
+		return &http.Response{
+			Status:        fmt.Sprintf("%d %s", v.StatusCode, http.StatusText(v.StatusCode)),
+			StatusCode:    v.StatusCode,
+			Proto:         "HTTP/1.1",
+			ProtoMajor:    1,
+			ProtoMinor:    1,
+			Header:        v.Header.Clone(),
+			Body:          io.NopCloser(bytes.NewBufferString(v.Body)),
+			ContentLength: int64(len(v.Body)),
+			Request:       r,
+		}, nil
+	}
+
+	return nil, fmt.Errorf("no recorded ARM response for %s (request #%d) in %s", key, t.replayed[key]+1, t.cassettePath)
+}
+
+func (t *armRecordingTransport) record(r *http.Request, interaction armInteraction) (*http.Response, error) {
+	resp, err := t.inner.RoundTrip(r)
+	if err != nil {
+		return nil, err // there's nothing to record
+	}
+
+	respBody, err := io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response body: %w", err)
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(respBody))
+
+	interaction.StatusCode = resp.StatusCode
+	interaction.Header = resp.Header.Clone()
+	interaction.Header.Del("Set-Cookie")
+	interaction.Body = sanitizeARMBody(string(respBody))
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.cassette.Interactions = append(t.cassette.Interactions, interaction)
+
+	// The cassette is rewritten after every interaction, so nothing is lost if the test dies partway.
+	buf, err := json.MarshalIndent(t.cassette, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal ARM cassette: %w", err)
+	}
+	if err = os.MkdirAll(filepath.Dir(t.cassettePath), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create ARM cassette directory: %w", err)
+	}
+	if err = os.WriteFile(t.cassettePath, buf, 0644); err != nil {
+		return nil, fmt.Errorf("failed to write ARM cassette: %w", err)
+	}
+
+	return resp, nil
+}
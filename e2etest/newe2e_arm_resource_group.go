@@ -1,6 +1,8 @@
 package e2etest
 
 import (
+	"context"
+	"errors"
 	"net/http"
 	"net/url"
 )
@@ -10,6 +12,8 @@ import (
 type ARMResourceGroup struct {
 	*ARMSubscription
 	ResourceGroupName string
+	// Listed holds the group's properties as of ARMSubscription.ListResourceGroups, for handles that came from it.
+	Listed *ARMResourceGroupInfo
 }
 
 func (rg *ARMResourceGroup) ManagementURI() url.URL {
@@ -23,7 +27,7 @@ type ARMResourceGroupCreateParams struct {
 	Location   string                `json:"location"` // required
 	ManagedBy  *string               `json:"managedBy,omitempty"`
 	Properties *ARMResourceGroupInfo `json:"properties,omitempty"`
-	Tags       []string              `json:"tags,omitempty"`
+	Tags       map[string]string     `json:"tags,omitempty"`
 }
 
 type ARMResourceGroupProvisioningStateOutput struct {
@@ -42,7 +46,7 @@ func (rg *ARMResourceGroup) PrepareRequest(reqSettings *ARMRequestSettings) {
 
 func (rg *ARMResourceGroup) CreateOrUpdate(params ARMResourceGroupCreateParams) (*ARMResourceGroupProvisioningStateOutput, error) {
 	var out ARMResourceGroupProvisioningStateOutput
-	_, err := PerformRequest(rg, ARMRequestSettings{
+	_, err := PerformRequest(context.Background(), rg, ARMRequestSettings{
 		Method: http.MethodPut,
 		Body:   params,
 	}, &out) // Shouldn't "officially" incur an async operation according to docs, and PrepareRequest should catch an error state on that for us.
@@ -53,15 +57,16 @@ func (rg *ARMResourceGroup) CreateOrUpdate(params ARMResourceGroupCreateParams)
 	return &out, nil
 }
 
+// Delete deletes the resource group and everything in it; a group that's already gone is not an error.
 func (rg *ARMResourceGroup) Delete(forceDeletionTypes *string) error {
 	var query = make(url.Values)
 	if forceDeletionTypes != nil {
 		query.Add("forceDeletionTypes", *forceDeletionTypes)
 	}
 
-	_, err := PerformRequest[any](rg, ARMRequestSettings{
-		Method: http.MethodDelete,
-	}, nil) // No need to have a response
+	_, err := PerformDeleteRequest(context.Background(), rg, ARMRequestSettings{
+		Query: query,
+	})
 	if err != nil {
 		return err
 	}
@@ -69,9 +74,19 @@ func (rg *ARMResourceGroup) Delete(forceDeletionTypes *string) error {
 	return nil
 }
 
+// Exists checks for the resource group. https://learn.microsoft.com/en-us/rest/api/resources/resource-groups/check-existence
+func (rg *ARMResourceGroup) Exists() (bool, error) {
+	_, err := PerformHeadRequest(context.Background(), rg, ARMRequestSettings{})
+	if errors.Is(err, ErrARMResourceNotFound) {
+		return false, nil
+	}
+
+	return err == nil, err
+}
+
 func (rg *ARMResourceGroup) GetProperties() (*ARMResourceGroupInfo, error) {
 	var out ARMResourceGroupInfo
-	_, err := PerformRequest(rg, ARMRequestSettings{
+	_, err := PerformRequest(context.Background(), rg, ARMRequestSettings{
 		Method: http.MethodGet,
 	}, &out)
 	if err != nil {
@@ -81,13 +96,52 @@ func (rg *ARMResourceGroup) GetProperties() (*ARMResourceGroupInfo, error) {
 	return &out, nil
 }
 
+// ListStorageAccounts lists every storage account in the resource group, across all pages.
+func (rg *ARMResourceGroup) ListStorageAccounts(ctx context.Context) ([]ARMStorageAccountProperties, error) { // https://learn.microsoft.com/en-us/rest/api/storagerp/storage-accounts/list-by-resource-group
+	var out []ARMStorageAccountProperties
+	err := PerformPagedRequest(ctx, rg, ARMRequestSettings{
+		Method:        http.MethodGet,
+		PathExtension: "providers/Microsoft.Storage/storageAccounts",
+		Query:         url.Values{"api-version": []string{"2023-01-01"}}, // the storage RP's version, not the resource group's
+	}, &out)
+	if err != nil {
+		return nil, err
+	}
+
+	return out, nil
+}
+
+// ListResources lists every resource in the resource group, of any type, across all pages.
+func (rg *ARMResourceGroup) ListResources(ctx context.Context) ([]ARMResourceInfo, error) { // https://learn.microsoft.com/en-us/rest/api/resources/resources/list-by-resource-group
+	var out []ARMResourceInfo
+	err := PerformPagedRequest(ctx, rg, ARMRequestSettings{
+		Method:        http.MethodGet,
+		PathExtension: "resources",
+	}, &out)
+	if err != nil {
+		return nil, err
+	}
+
+	return out, nil
+}
+
 // ========= Shared Structs ==========
 
 type ARMResourceGroupInfo struct {
 	ID                    string                                  `json:"id"`
+	Name                  string                                  `json:"name"`
 	Location              string                                  `json:"location"`
 	ManagedBy             string                                  `json:"managedBy"`
 	ProvisioningStateInfo ARMResourceGroupProvisioningStateOutput `json:"properties"`
-	Tags                  []string                                `json:"tags"`
+	Tags                  map[string]string                       `json:"tags"`
 	Type                  string                                  `json:"type"`
 }
+
+// ARMResourceInfo is the generic view of any resource, as listed by ARMResourceGroup.ListResources.
+type ARMResourceInfo struct {
+	ID       string            `json:"id"`
+	Name     string            `json:"name"`
+	Type     string            `json:"type"`
+	Location string            `json:"location"`
+	Tags     map[string]string `json:"tags"`
+}
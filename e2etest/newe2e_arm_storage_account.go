@@ -1,6 +1,7 @@
 package e2etest
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/blob"
@@ -32,10 +33,10 @@ func (sa *ARMStorageAccount) GetResourceManager() (*AzureAccountResourceManager,
 		return nil, fmt.Errorf("failed to get account keys: %w", err)
 	}
 
-	var acctKey string
+	var acctKey, acctKeyName string
 	for _, v := range keyList.Keys { // todo: fallback to RO key
 		if v.Permissions == ARMStorageAccountKeyPermissionFull || v.Permissions == "" {
-			acctKey = v.Value
+			acctKey, acctKeyName = v.Value, v.KeyName
 			break
 		}
 	}
@@ -56,7 +57,7 @@ func (sa *ARMStorageAccount) GetResourceManager() (*AzureAccountResourceManager,
 		case service.AccountKindBlockBlobStorage: // both use the same kind
 			acctType = common.Iff(props.Properties.IsHNSEnabled, EAccountType.PremiumHNSEnabled(), EAccountType.PremiumBlockBlobs())
 		case service.AccountKindFileStorage:
-			acctType = EAccountType.PremiumFileShares()
+			acctType = common.Iff(strings.HasPrefix(props.Sku.Name, "PremiumV2"), EAccountType.PremiumV2FileShares(), EAccountType.PremiumFileShares())
 		case service.AccountKindStorageV2:
 			acctType = EAccountType.PremiumPageBlobs()
 		}
@@ -64,17 +65,18 @@ func (sa *ARMStorageAccount) GetResourceManager() (*AzureAccountResourceManager,
 		acctType = EAccountType.HierarchicalNamespaceEnabled()
 	case strings.EqualFold(props.Sku.Tier, "Standard"):
 		acctType = EAccountType.Standard()
-	//	// Classic comes from Microsoft.ClassicStorage/storageAccounts, so, not possible here.
+	//	// Classic comes from Microsoft.ClassicStorage/storageAccounts, so, not possible here. See ARMClassicStorageAccount.
 	//	// Managed Disks also won't appear here.
 	default:
 		return nil, fmt.Errorf("failed to assign an appropriate account type")
 	}
 
 	return &AzureAccountResourceManager{
-		accountName: sa.AccountName,
-		accountKey:  acctKey,
-		accountType: acctType,
-		armClient:   sa,
+		accountName:    sa.AccountName,
+		accountKey:     acctKey,
+		accountKeyName: acctKeyName,
+		accountType:    acctType,
+		armClient:      sa,
 	}, nil
 }
 
@@ -129,17 +131,16 @@ type ARMStorageAccountCreateProperties struct { // json.RawMessage(s) are used a
 
 func (sa *ARMStorageAccount) Create(params ARMStorageAccountCreateParams) (*ARMStorageAccountProperties, error) {
 	var out ARMStorageAccountProperties
-	_, err := PerformRequest(sa, ARMRequestSettings{
+	_, err := PerformRequest(context.Background(), sa, ARMRequestSettings{
 		Method: http.MethodPut,
 		Body:   params,
 	}, &out)
 	return &out, err
 }
 
+// Delete deletes the account; an account that's already gone is not an error.
 func (sa *ARMStorageAccount) Delete() error {
-	_, err := PerformRequest[any](sa, ARMRequestSettings{
-		Method: http.MethodDelete,
-	}, nil)
+	_, err := PerformDeleteRequest(context.Background(), sa, ARMRequestSettings{})
 	return err
 }
 
@@ -156,7 +157,7 @@ func (sa *ARMStorageAccount) GetProperties(expand []string) (*ARMStorageAccountP
 	}
 
 	var out ARMStorageAccountProperties
-	_, err := PerformRequest(sa, ARMRequestSettings{
+	_, err := PerformRequest(context.Background(), sa, ARMRequestSettings{
 		Method: http.MethodGet,
 	}, &out)
 	return &out, err
@@ -165,13 +166,26 @@ func (sa *ARMStorageAccount) GetProperties(expand []string) (*ARMStorageAccountP
 func (sa *ARMStorageAccount) GetKeys() (*ARMStorageAccountListKeysResult, error) { // Kerberos keys can be listed, but AzCopy doesn't currently support this.
 	var resp ARMStorageAccountListKeysResult
 
-	_, err := PerformRequest(sa, ARMRequestSettings{
+	_, err := PerformRequest(context.Background(), sa, ARMRequestSettings{
 		Method:        http.MethodPost,
 		PathExtension: "listKeys",
 	}, &resp)
 	return &resp, err
 }
 
+// RegenerateKey replaces the value of the key named keyName ("key1" or "key2"), returning the account's keys afterward.
+// Anything signed with the old value (SAS tokens included) stops working.
+func (sa *ARMStorageAccount) RegenerateKey(keyName string) (*ARMStorageAccountListKeysResult, error) { // https://learn.microsoft.com/en-us/rest/api/storagerp/storage-accounts/regenerate-key
+	var resp ARMStorageAccountListKeysResult
+
+	_, err := PerformRequest(context.Background(), sa, ARMRequestSettings{
+		Method:        http.MethodPost,
+		PathExtension: "regenerateKey",
+		Body:          map[string]string{"keyName": keyName},
+	}, &resp)
+	return &resp, err
+}
+
 // =========== Shared Types ===========
 
 type ARMStorageAccountProperties struct {
@@ -237,6 +251,7 @@ var (
 	// SKU names https://learn.microsoft.com/en-us/rest/api/storagerp/storage-accounts/create?tabs=HTTP#skuname
 	ARMStorageAccountSKUPremiumLRS     = ARMStorageAccountSKU{"Premium_LRS", "Premium"}
 	ARMStorageAccountSKUPremiumZRS     = ARMStorageAccountSKU{"Premium_ZRS", "Premium"}
+	ARMStorageAccountSKUPremiumV2LRS   = ARMStorageAccountSKU{"PremiumV2_LRS", "Premium"} // FileStorage only
 	ARMStorageAccountSKUStandardGRS    = ARMStorageAccountSKU{"Standard_GRS", "Standard"}
 	ARMStorageAccountSKUStandardGZRS   = ARMStorageAccountSKU{"Standard_GZRS", "Standard"}
 	ARMStorageAccountSKUStandardLRS    = ARMStorageAccountSKU{"Standard_LRS", "Standard"}
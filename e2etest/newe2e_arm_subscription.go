@@ -1,6 +1,13 @@
 package e2etest
 
-import "net/url"
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
 
 type ARMSubscription struct {
 	*ARMClient
@@ -13,3 +20,92 @@ func (s *ARMSubscription) ManagementURI() url.URL {
 
 	return *newURI
 }
+
+// ARMResourceGroupTagFilter narrows ListResourceGroups to the groups carrying a tag, optionally with a particular value.
+// ARM applies it server-side.
+type ARMResourceGroupTagFilter struct {
+	Name  string
+	Value string // any value, if empty
+}
+
+func (f ARMResourceGroupTagFilter) odataFilter() string {
+	quote := func(s string) string { return "'" + strings.ReplaceAll(s, "'", "''") + "'" }
+
+	filter := "tagName eq " + quote(f.Name)
+	if f.Value != "" {
+		filter += " and tagValue eq " + quote(f.Value)
+	}
+
+	return filter
+}
+
+// ListResourceGroups lists every resource group in the subscription (or, given tagFilter, just those tagged), across all pages.
+// The handles returned carry the group's properties as listed.
+func (s *ARMSubscription) ListResourceGroups(ctx context.Context, tagFilter *ARMResourceGroupTagFilter) ([]*ARMResourceGroup, error) { // https://learn.microsoft.com/en-us/rest/api/resources/resource-groups/list
+	query := url.Values{"api-version": []string{"2021-04-01"}}
+	if tagFilter != nil {
+		query.Set("$filter", tagFilter.odataFilter())
+	}
+
+	var groups []ARMResourceGroupInfo
+	err := PerformPagedRequest(ctx, s, ARMRequestSettings{
+		Method:        http.MethodGet,
+		PathExtension: "resourcegroups",
+		Query:         query,
+	}, &groups)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]*ARMResourceGroup, len(groups))
+	for i := range groups {
+		out[i] = &ARMResourceGroup{ARMSubscription: s, ResourceGroupName: groups[i].Name, Listed: &groups[i]}
+	}
+
+	return out, nil
+}
+
+// DeleteEmptyResourceGroups deletes the test resource groups in the subscription that hold no resources and were created over olderThan ago,
+// reaping those abandoned by runs that never got to tear down. Groups without the framework's prefix, or without a valid createdAt tag, are never touched.
+// Deletion carries on past failures; the names of the deleted groups are returned, alongside any failures.
+func (s *ARMSubscription) DeleteEmptyResourceGroups(olderThan time.Duration) (deleted []string, err error) {
+	groups, err := s.ListResourceGroups(context.Background(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list resource groups: %w", err)
+	}
+
+	cutoff := time.Now().Add(-olderThan)
+	var failures []string
+	for _, rg := range groups {
+		if !strings.HasPrefix(rg.ResourceGroupName, E2EResourceGroupPrefix) {
+			continue
+		}
+
+		createdAt, err := time.Parse(time.RFC3339, rg.Listed.Tags[ARMCreatedAtTag])
+		if err != nil || createdAt.After(cutoff) {
+			continue // untagged (or mangled), or too new; a run may be about to fill it
+		}
+
+		resources, err := rg.ListResources(context.Background())
+		if err != nil {
+			failures = append(failures, fmt.Sprintf("%s: %s", rg.ResourceGroupName, err))
+			continue
+		}
+		if len(resources) != 0 {
+			continue
+		}
+
+		if err := rg.Delete(nil); err != nil {
+			failures = append(failures, fmt.Sprintf("%s: %s", rg.ResourceGroupName, err))
+			continue
+		}
+
+		deleted = append(deleted, rg.ResourceGroupName)
+	}
+
+	if len(failures) != 0 {
+		return deleted, fmt.Errorf("failed to delete %d empty resource groups:\n%s", len(failures), strings.Join(failures, "\n"))
+	}
+
+	return deleted, nil
+}
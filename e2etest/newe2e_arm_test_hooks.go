@@ -1,14 +1,28 @@
 package e2etest
 
 import (
+	"context"
+	"fmt"
 	"github.com/google/uuid"
 	"net/http"
 	"strings"
+	"time"
 )
 
 var CommonARMClient *ARMClient
 var CommonARMResourceGroup *ARMResourceGroup // separated in case needed.
 
+const (
+	// E2EResourceGroupPrefix names every resource group created by the framework; the cleanup sweep only considers these.
+	E2EResourceGroupPrefix = "azcopy-newe2e-"
+	// ARMDeleteAfterTag holds the RFC 3339 time after which a test resource group may be deleted by CleanupExpiredResources.
+	ARMDeleteAfterTag = "deleteAfter"
+	// ARMCreatedAtTag holds the RFC 3339 time a test resource group was created at; ARM doesn't report it. See ARMSubscription.DeleteEmptyResourceGroups.
+	ARMCreatedAtTag = "createdAt"
+	// E2EResourceGroupTag marks every resource group created by the framework, for CI to find with an ARMResourceGroupTagFilter.
+	E2EResourceGroupTag = "azcopy-e2e"
+)
+
 func SetupArmClient(a Asserter) {
 	if GlobalConfig.StaticResources() {
 		return // no setup
@@ -22,18 +36,35 @@ func SetupArmClient(a Asserter) {
 		HttpClient: http.DefaultClient, // todo if we want something more special
 	}
 
+	subscriptionID := GlobalConfig.E2EAuthConfig.SubscriptionLoginInfo.SubscriptionID
+	if GlobalConfig.ARMCleanupConfig.SweepExpired {
+		deleted, err := CleanupExpiredResources(CommonARMClient, subscriptionID)
+		for _, name := range deleted {
+			a.Log("Deleted expired resource group %s", name)
+		}
+		a.NoError("clean up expired resource groups", err)
+	}
+
+	ttl, err := time.ParseDuration(GlobalConfig.ARMCleanupConfig.ResourceGroupTTL)
+	a.NoError("parse NEW_E2E_RESOURCE_GROUP_TTL", err)
+
 	uuidSegments := strings.Split(uuid.NewString(), "-")
 
 	CommonARMResourceGroup = &ARMResourceGroup{
 		ARMSubscription: &ARMSubscription{
 			ARMClient:      CommonARMClient,
-			SubscriptionID: GlobalConfig.E2EAuthConfig.SubscriptionLoginInfo.SubscriptionID,
+			SubscriptionID: subscriptionID,
 		},
-		ResourceGroupName: "azcopy-newe2e-" + uuidSegments[len(uuidSegments)-1],
+		ResourceGroupName: E2EResourceGroupPrefix + uuidSegments[len(uuidSegments)-1],
 	}
 
 	_, err = CommonARMResourceGroup.CreateOrUpdate(ARMResourceGroupCreateParams{
 		Location: "West US", // todo configurable
+		Tags: map[string]string{
+			E2EResourceGroupTag: "true",
+			ARMCreatedAtTag:     time.Now().UTC().Format(time.RFC3339),
+			ARMDeleteAfterTag:   time.Now().Add(ttl).UTC().Format(time.RFC3339),
+		},
 	})
 	a.NoError("create resource group", err)
 }
@@ -43,5 +74,48 @@ func TeardownArmClient(a Asserter) {
 		return // no need to attempt cleanup
 	}
 
+	if GlobalConfig.DebugSkipCleanup() {
+		a.Log("NEW_E2E_DEBUG_SKIP_CLEANUP is set; leaving resource group %s in place", CommonARMResourceGroup.ResourceGroupName)
+		return
+	}
+
 	a.NoError("delete resource group", CommonARMResourceGroup.Delete(nil))
 }
+
+// CleanupExpiredResources deletes the test resource groups in subscription whose deleteAfter tag has passed, waiting on each deletion.
+// Groups without the framework's prefix, or without a valid deleteAfter tag, are never touched.
+// Deletion carries on past failures; the names of the deleted groups are returned, alongside any failures.
+func CleanupExpiredResources(client *ARMClient, subscription string) (deleted []string, err error) {
+	sub := &ARMSubscription{ARMClient: client, SubscriptionID: subscription}
+
+	groups, err := sub.ListResourceGroups(context.Background(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list resource groups: %w", err)
+	}
+
+	now := time.Now()
+	var failures []string
+	for _, rg := range groups {
+		if !strings.HasPrefix(rg.ResourceGroupName, E2EResourceGroupPrefix) {
+			continue
+		}
+
+		deleteAfter, err := time.Parse(time.RFC3339, rg.Listed.Tags[ARMDeleteAfterTag])
+		if err != nil || now.Before(deleteAfter) {
+			continue // untagged (or mangled), or not expired yet
+		}
+
+		if err := rg.Delete(nil); err != nil {
+			failures = append(failures, fmt.Sprintf("%s: %s", rg.ResourceGroupName, err))
+			continue
+		}
+
+		deleted = append(deleted, rg.ResourceGroupName)
+	}
+
+	if len(failures) != 0 {
+		return deleted, fmt.Errorf("failed to delete %d expired resource groups:\n%s", len(failures), strings.Join(failures, "\n"))
+	}
+
+	return deleted, nil
+}
@@ -107,6 +107,10 @@ func (ta *FrameworkAsserter) GetTestName() string {
 	return out
 }
 
+func (ta *FrameworkAsserter) TestName() string {
+	return ta.t.Name()
+}
+
 func (ta *FrameworkAsserter) PrintFinalizingMessage(reasonFormat string, a ...any) {
 	ta.t.Helper()
 	ta.Log("========== %s ===========", ta.GetTestName())
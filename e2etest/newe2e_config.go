@@ -54,7 +54,38 @@ type NewE2EConfig struct {
 				AccountKey  string `env:"NEW_E2E_HNS_ACCOUNT_KEY,required"`
 			} `env:",required"`
 		} `env:",required,minimum_required=1"`
+
+		// EmulatorInfo targets a local storage emulator such as Azurite. Only Blob is emulated, and there is no ARM.
+		EmulatorInfo struct {
+			// Endpoint is the emulator's Blob endpoint. It must address the emulator by IP (e.g. http://127.0.0.1:10000), so URLs are parsed path-style.
+			Endpoint    string `env:"E2E_EMULATOR_ENDPOINT,required"`
+			AccountName string `env:"E2E_EMULATOR_ACCOUNT_NAME,default=devstoreaccount1"`
+			AccountKey  string `env:"E2E_EMULATOR_ACCOUNT_KEY,default=Eby8vdM02xNOcqFlqUwJPLlmEtlCDXJ1OUzFT50uSRZ6IFsuFq2UVErCz4I6tq/K1SZFPTOtr/KBHBeksoGMGw=="`
+		} `env:",required"`
 	} `env:",required,mutually_exclusive"`
+	S3AuthConfig struct { // optional; S3 scenarios are skipped without it
+		AccessKeyID     string `env:"NEW_E2E_AWS_ACCESS_KEY_ID,required"`
+		SecretAccessKey string `env:"NEW_E2E_AWS_SECRET_ACCESS_KEY,required"`
+		Region          string `env:"NEW_E2E_AWS_REGION"`
+	}
+	GCPAuthConfig struct { // optional; GCP scenarios are skipped without it
+		CredentialsPath string `env:"GOOGLE_APPLICATION_CREDENTIALS,required"`
+		ProjectID       string `env:"GOOGLE_CLOUD_PROJECT,required"`
+	}
+	ARMCleanupConfig struct { // only used against a subscription
+		// ResourceGroupTTL is how long (as a Go duration) a test resource group is kept before SweepExpired may delete it.
+		ResourceGroupTTL string `env:"NEW_E2E_RESOURCE_GROUP_TTL,default=24h"`
+		// SweepExpired deletes expired test resource groups (see CleanupExpiredResources) before the suites run, e.g. in a nightly job.
+		SweepExpired bool `env:"NEW_E2E_SWEEP_EXPIRED_RESOURCE_GROUPS"`
+	}
+	DebugConfig struct { // optional
+		// SkipCleanup leaves everything the run creates in place for post-mortem: each scenario's containers, the accounts, and the resource group.
+		SkipCleanup bool `env:"NEW_E2E_DEBUG_SKIP_CLEANUP"`
+	}
+	ScaleConfig struct { // optional
+		// Enabled runs the scale scenarios (ScaleSuite), which move hundreds of GiB or a million objects; too slow and costly for every run.
+		Enabled bool `env:"E2E_SCALE"`
+	}
 	AzCopyExecutableConfig struct {
 		ExecutablePath      string `env:"NEW_E2E_AZCOPY_PATH,required"`
 		AutobuildExecutable bool   `env:"NEW_E2E_AUTOBUILD_AZCOPY,default=true"` // todo: make this work. It does not as of 11-21-23
@@ -62,9 +93,32 @@ type NewE2EConfig struct {
 }
 
 func (e NewE2EConfig) StaticResources() bool {
+	// The emulator counts as static, too; there's nothing to create accounts with.
 	return e.E2EAuthConfig.SubscriptionLoginInfo.SubscriptionID == "" // all subscriptionlogininfo options would have to be filled due to required
 }
 
+func (e NewE2EConfig) Emulated() bool {
+	return e.E2EAuthConfig.EmulatorInfo.Endpoint != ""
+}
+
+func (e NewE2EConfig) S3Enabled() bool {
+	// S3_TESTS_OFF is honored by the legacy framework too, so one switch turns S3 off across both.
+	return e.S3AuthConfig.AccessKeyID != "" && !isS3Disabled() // the secret would have to be filled due to required
+}
+
+func (e NewE2EConfig) GCPEnabled() bool {
+	// GCP_TESTS_OFF is honored by the cmd and ste tests too, so one switch turns GCP off everywhere.
+	return e.GCPAuthConfig.CredentialsPath != "" && !isGCPDisabled() // the project would have to be filled due to required
+}
+
+func (e NewE2EConfig) DebugSkipCleanup() bool {
+	return e.DebugConfig.SkipCleanup
+}
+
+func (e NewE2EConfig) ScaleEnabled() bool {
+	return e.ScaleConfig.Enabled
+}
+
 // ========= Tag Definition ==========
 
 type EnvTag struct {
@@ -0,0 +1,199 @@
+package e2etest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+/*
+Set E2E_HTTP_LOG=/path/to/file to log every request the framework makes, through an ARMClient or a service obtained via
+GetService, as one JSON object per line. Nothing is logged otherwise.
+
+Secrets are redacted before anything is written: Authorization headers, SAS signatures (in the URL, or in a header
+such as x-ms-copy-source), and account keys in response bodies.
+*/
+
+const (
+	httpLogEnvVar = "E2E_HTTP_LOG"
+	// httpLogMaxBody caps how much of a response body is logged; bodies are only logged for JSON responses and failures.
+	httpLogMaxBody  = 64 * 1024
+	httpLogRedacted = "REDACTED"
+)
+
+// httpLogRedactedHeaders are logged with their value replaced, rather than omitted, so that it's clear they were sent.
+var httpLogRedactedHeaders = []string{
+	"Authorization",
+	"X-Ms-Copy-Source-Authorization",
+}
+
+var httpLogSigPattern = regexp.MustCompile(`(?i)(\bsig=)[^&\s"<]*`)
+
+type httpLogEntry struct {
+	Time       time.Time         `json:"time"`
+	Method     string            `json:"method"`
+	URL        string            `json:"url"`
+	Header     map[string]string `json:"header,omitempty"`
+	StatusCode int               `json:"statusCode,omitempty"`
+	DurationMS int64             `json:"durationMs"`
+	RequestID  string            `json:"requestId,omitempty"`
+	Body       string            `json:"body,omitempty"`
+	Error      string            `json:"error,omitempty"`
+}
+
+var (
+	httpLogOnce   sync.Once
+	httpLogWriter io.Writer
+	httpLogMu     sync.Mutex
+)
+
+// sharedHTTPLog opens the file named by E2E_HTTP_LOG, once per process. It returns nil if logging is disabled, or the
+// file can't be opened (which is reported on stderr, rather than failing every test).
+func sharedHTTPLog() io.Writer {
+	httpLogOnce.Do(func() {
+		path := os.Getenv(httpLogEnvVar)
+		if path == "" {
+			return
+		}
+
+		f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+		if err != nil {
+			_, _ = fmt.Fprintf(os.Stderr, "%s is set, but the log could not be opened: %v\n", httpLogEnvVar, err)
+			return
+		}
+
+		httpLogWriter = f
+	})
+
+	return httpLogWriter
+}
+
+// httpLogTransport logs each request that passes through it to out.
+type httpLogTransport struct {
+	inner http.RoundTripper
+	out   io.Writer
+	// mu serializes writes, so concurrent requests don't interleave lines. Transports logging to the same writer must share it.
+	mu *sync.Mutex
+}
+
+func newHTTPLogTransport(inner http.RoundTripper, out io.Writer, mu *sync.Mutex) *httpLogTransport {
+	if inner == nil {
+		inner = http.DefaultTransport
+	}
+
+	return &httpLogTransport{inner: inner, out: out, mu: mu}
+}
+
+// serviceClientOptions are the azcore options every service client built by the framework uses.
+func serviceClientOptions() azcore.ClientOptions {
+	var opts azcore.ClientOptions
+	if log := sharedHTTPLog(); log != nil {
+		opts.Transport = &http.Client{Transport: newHTTPLogTransport(nil, log, &httpLogMu)}
+	}
+
+	return opts
+}
+
+func (t *httpLogTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	entry := httpLogEntry{
+		Time:   time.Now().UTC(),
+		Method: r.Method,
+		URL:    redactURL(r.URL),
+		Header: redactHeaders(r.Header),
+	}
+
+	resp, err := t.inner.RoundTrip(r)
+	entry.DurationMS = time.Since(entry.Time).Milliseconds()
+
+	if err != nil {
+		entry.Error = redactSAS(err.Error())
+	} else {
+		entry.StatusCode = resp.StatusCode
+		entry.RequestID = resp.Header.Get("x-ms-request-id")
+
+		if resp.StatusCode >= 400 || strings.Contains(resp.Header.Get("Content-Type"), "json") {
+			entry.Body, resp.Body = peekBody(resp.Body)
+		}
+	}
+
+	t.write(entry)
+
+	return resp, err
+}
+
+func (t *httpLogTransport) write(entry httpLogEntry) {
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return // a log line isn't worth failing the request over
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	_, _ = t.out.Write(append(line, '\n'))
+}
+
+// peekBody reads up to httpLogMaxBody of body for logging, and returns a replacement that still yields all of it.
+func peekBody(body io.ReadCloser) (string, io.ReadCloser) {
+	if body == nil || body == http.NoBody {
+		return "", body
+	}
+
+	head, err := io.ReadAll(io.LimitReader(body, httpLogMaxBody))
+	replacement := struct {
+		io.Reader
+		io.Closer
+	}{io.MultiReader(bytes.NewReader(head), body), body}
+	if err != nil {
+		return "", replacement
+	}
+
+	return redactSAS(sanitizeARMBody(string(head))), replacement
+}
+
+func redactURL(u *url.URL) string {
+	if u == nil {
+		return ""
+	}
+
+	redacted := *u
+	if q := redacted.Query(); q.Has("sig") {
+		q.Set("sig", httpLogRedacted)
+		redacted.RawQuery = q.Encode()
+	}
+
+	return redacted.String()
+}
+
+// redactSAS masks SAS signatures anywhere in s, e.g. in a copy source URL.
+func redactSAS(s string) string {
+	return httpLogSigPattern.ReplaceAllString(s, "${1}"+httpLogRedacted)
+}
+
+func redactHeaders(header http.Header) map[string]string {
+	if len(header) == 0 {
+		return nil
+	}
+
+	out := make(map[string]string, len(header))
+	for k, v := range header {
+		out[k] = redactSAS(strings.Join(v, ", "))
+
+		// Not every client canonicalizes header names, so match them case-insensitively.
+		for _, secret := range httpLogRedactedHeaders {
+			if strings.EqualFold(k, secret) {
+				out[k] = httpLogRedacted
+			}
+		}
+	}
+
+	return out
+}
@@ -7,6 +7,7 @@ import (
 	"github.com/Azure/azure-storage-azcopy/v10/common"
 	"io"
 	"runtime"
+	"sort"
 	"sync"
 )
 
@@ -36,7 +37,13 @@ func (m *MultiStepUploader) GetBlockCount(size int64) int64 {
 	return quo
 }
 
+// UploadContents uploads content a block at a time. Blocks of sparse content (see SparseObjectContentContainer) holding only zeroes
+// are skipped, so Init must create an object that reads as zeroes until written, e.g. a page blob.
 func (m *MultiStepUploader) UploadContents(content ObjectContentContainer) error {
+	if content == nil {
+		content = NewZeroObjectContentContainer(0)
+	}
+
 	if m.Init != nil {
 		err := m.Init(content.Size())
 		if err != nil {
@@ -44,61 +51,70 @@ func (m *MultiStepUploader) UploadContents(content ObjectContentContainer) error
 		}
 	}
 
-	if content == nil {
-		content = NewZeroObjectContentContainer(0)
-	}
-
 	size := content.Size()
 	reader := content.Reader()
 	blockCount := m.GetBlockCount(size)
 
-	offset := int64(0)
-	blockIndex := int64(0)
+	var dataRanges []ContentRange
+	if sparse, ok := content.(SparseObjectContentContainer); ok {
+		dataRanges = sparse.DataRanges()
+	}
 
 	wg := &sync.WaitGroup{}
-	pool := &sync.Pool{}
 	threads := common.Iff(m.Parallel, runtime.NumCPU(), 1) // 1 thread if not parallel
+	// Each thread holds a block in memory, so no more are read ahead than can be uploaded at once.
+	sem := make(chan int, threads)
 	for i := 0; i < threads; i++ {
-		pool.Put(&struct{ threadID int }{
-			threadID: i,
-		})
+		sem <- i
 	}
 
 	chunkErrors := make(map[int64]error)
 	errMutex := &sync.Mutex{}
 
-	for {
-		buf := make([]byte, m.BlockSize)
-		n, err := reader.Read(buf)
-		if err != nil && err != io.EOF {
+	for blockIndex := int64(0); blockIndex < blockCount; blockIndex++ {
+		offset := blockIndex * m.BlockSize
+		blockSize := m.BlockSize
+		if offset+blockSize > size {
+			blockSize = size - offset
+		}
+
+		if dataRanges != nil && !overlapsAny(dataRanges, ContentRange{Offset: offset, Count: blockSize}) {
+			continue
+		}
+
+		buf := make([]byte, blockSize)
+		if _, err := reader.Seek(offset, io.SeekStart); err != nil {
+			return fmt.Errorf("failed to seek content (offset %d (block %d/%d), total %d): %w", offset, blockIndex, blockCount, size, err)
+		}
+		if _, err := io.ReadFull(reader, buf); err != nil {
 			return fmt.Errorf("failed to read content (offset %d (block %d/%d), total %d): %w", offset, blockIndex, blockCount, size, err)
-		} else if err == io.EOF {
-			buf = buf[:n] // reduce buffer size for final block
 		}
 
+		threadID := <-sem
 		wg.Add(1)
-		thread := pool.Get().(*struct{ threadID int })
-		go func() {
+		go func(blockIndex, offset int64) {
 			defer wg.Done()
-			defer pool.Put(thread)
-
-			if m.UploadRange != nil {
-				err = m.UploadRange(
-					streaming.NopCloser(bytes.NewReader(buf)),
-					MultiStepUploaderState{BlockSize: int64(n), Offset: offset, BlockIndex: blockIndex, BlockCount: blockCount})
-
-				if err != nil {
-					errMutex.Lock()
-					defer errMutex.Unlock()
-					chunkErrors[blockIndex] = fmt.Errorf("failed to upload content (thread %d, offset %d (block %d/%d), total %d): %w", thread.threadID, offset, blockIndex, blockCount, size, err)
-				}
+			defer func() { sem <- threadID }()
+
+			if m.UploadRange == nil {
+				return
 			}
-		}()
 
-		offset += int64(n)
-		blockIndex++
-		if offset >= size { // Offset will never be above size, but on the off chance it is, may as well handle it properly.
-			break
+			err := m.UploadRange(
+				streaming.NopCloser(bytes.NewReader(buf)),
+				MultiStepUploaderState{BlockSize: int64(len(buf)), Offset: offset, BlockIndex: blockIndex, BlockCount: blockCount})
+			if err != nil {
+				errMutex.Lock()
+				defer errMutex.Unlock()
+				chunkErrors[blockIndex] = fmt.Errorf("failed to upload content (thread %d, offset %d (block %d/%d), total %d): %w", threadID, offset, blockIndex, blockCount, size, err)
+			}
+		}(blockIndex, offset)
+	}
+
+	wg.Wait()
+	for blockIndex := int64(0); blockIndex < blockCount; blockIndex++ {
+		if err, ok := chunkErrors[blockIndex]; ok {
+			return err // the first to fail; the others likely failed the same way
 		}
 	}
 
@@ -111,3 +127,9 @@ func (m *MultiStepUploader) UploadContents(content ObjectContentContainer) error
 
 	return nil
 }
+
+// overlapsAny returns whether r overlaps any of ranges, which are in order.
+func overlapsAny(ranges []ContentRange, r ContentRange) bool {
+	i := sort.Search(len(ranges), func(i int) bool { return ranges[i].End() > r.Offset })
+	return i < len(ranges) && ranges[i].Offset < r.End()
+}
@@ -1,6 +1,7 @@
 package e2etest
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
@@ -62,6 +63,10 @@ func NewOAuthCache(cred azcore.TokenCredential, tenant string) *OAuthCache {
 
 var OAuthCacheDisabledError = errors.New("the OAuth cache is currently disabled")
 
+// tokenRefreshMargin is how long before expiry a cached token is replaced, like common's minimumTokenValidDuration.
+// The cache and AzCoreAccessToken share it, so a token refreshed by one is never immediately stale to the other.
+const tokenRefreshMargin = time.Minute * 5
+
 func (o *OAuthCache) GetAccessToken(scope string) (*AzCoreAccessToken, error) {
 	if o == nil {
 		return nil, OAuthCacheDisabledError
@@ -71,23 +76,40 @@ func (o *OAuthCache) GetAccessToken(scope string) (*AzCoreAccessToken, error) {
 	tok, ok := o.tokens[scope]
 	o.mut.RUnlock()
 
-	if !ok || time.Now().Add(time.Minute*3).After(tok.ExpiresOn) {
+	if !ok || time.Until(tok.ExpiresOn) < tokenRefreshMargin {
 		o.mut.Lock()
-		newTok, err := o.tc.GetToken(ctx, policy.TokenRequestOptions{
-			Scopes:   []string{scope},
-			TenantID: o.tenant,
-		})
-		if err != nil {
-			return nil, fmt.Errorf("failed fetching new AccessToken: %w", err)
+		defer o.mut.Unlock()
+
+		// Another caller may have refreshed the token while we waited for the lock.
+		if tok, ok = o.tokens[scope]; !ok || time.Until(tok.ExpiresOn) < tokenRefreshMargin {
+			newTok, err := o.tc.GetToken(ctx, policy.TokenRequestOptions{
+				Scopes:   []string{scope},
+				TenantID: o.tenant,
+			})
+			if err != nil {
+				return nil, fmt.Errorf("failed fetching new AccessToken: %w", err)
+			}
+
+			o.tokens[scope] = &newTok
+			tok = &newTok
 		}
+	}
 
-		o.tokens[scope] = &newTok
-		o.mut.Unlock()
+	return &AzCoreAccessToken{tok: tok, parent: o, Scope: scope}, nil
+}
 
-		tok = &newTok
+// GetToken lets the cache stand in as an azcore.TokenCredential for SDK clients, so they share its tokens.
+func (o *OAuthCache) GetToken(_ context.Context, options policy.TokenRequestOptions) (azcore.AccessToken, error) {
+	if len(options.Scopes) != 1 {
+		return azcore.AccessToken{}, fmt.Errorf("expected exactly one scope, got %d", len(options.Scopes))
 	}
 
-	return &AzCoreAccessToken{tok, o, scope}, nil
+	tok, err := o.GetAccessToken(options.Scopes[0])
+	if err != nil {
+		return azcore.AccessToken{}, err
+	}
+
+	return *tok.tok, nil
 }
 
 type AccessToken interface {
@@ -99,11 +121,18 @@ type AzCoreAccessToken struct {
 	tok    *azcore.AccessToken
 	parent *OAuthCache
 	Scope  string // this is bad design but maybe it's right
+
+	// mut is held across refreshes, so that concurrent requests (e.g. from ARM clients sharing this token) wait on a single refresh.
+	mut sync.Mutex
 }
 
 // FreshToken attempts to cleanly get a token.
+// The cached token is returned until it nears expiry, and only then is a new one fetched.
 func (a *AzCoreAccessToken) FreshToken() (string, error) {
-	if time.Now().Add(time.Minute).After(a.tok.ExpiresOn) {
+	a.mut.Lock()
+	defer a.mut.Unlock()
+
+	if time.Until(a.tok.ExpiresOn) < tokenRefreshMargin {
 		newTok, err := a.parent.GetAccessToken(a.Scope)
 		if err != nil {
 			return "", fmt.Errorf("failed to refresh token: %w", err)
@@ -116,5 +145,8 @@ func (a *AzCoreAccessToken) FreshToken() (string, error) {
 }
 
 func (a *AzCoreAccessToken) CurrentToken() string {
+	a.mut.Lock()
+	defer a.mut.Unlock()
+
 	return a.tok.Token
 }
@@ -0,0 +1,245 @@
+package e2etest
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+)
+
+/*
+Scale scenarios need objects far too big to hold in memory (or to download again), and far too many to keep each one's data around.
+Seeded content is pseudo-random data which is a pure function of its seed and offset: any range of it can be generated on demand,
+so it's streamed to the service as it's uploaded, and any range of the copy can be checked by generating that range again.
+*/
+
+// ContentRange is a range of an object's content.
+type ContentRange struct {
+	Offset int64
+	Count  int64
+}
+
+func (r ContentRange) End() int64 {
+	return r.Offset + r.Count
+}
+
+// SparseObjectContentContainer is content whose data lies in a few ranges, and is zero elsewhere; e.g. a mostly empty page blob or disk.
+// Uploaders can skip what lies outside DataRanges, where the service already reads as zeroes.
+type SparseObjectContentContainer interface {
+	ObjectContentContainer
+	// DataRanges are the ranges holding data, in order and not overlapping.
+	DataRanges() []ContentRange
+}
+
+// SampledObjectContentContainer is content too big to verify in full. ValidateResource compares the ranges it samples instead,
+// for objects which can be downloaded in part (see ObjectRangeDownloader).
+type SampledObjectContentContainer interface {
+	ObjectContentContainer
+	SampleRanges() []ContentRange
+	// ReadRange returns the expected content of r.
+	ReadRange(r ContentRange) []byte
+}
+
+// ObjectContentContainerSeeded is generated from its seed as it's read; see NewSeededObjectContentContainer.
+type ObjectContentContainerSeeded struct {
+	Seed int64
+
+	size int64
+	// extents are the only ranges holding data when sparse; everything else is zero.
+	extents []ContentRange
+	sparse  bool
+
+	sampleCount int
+	sampleSize  int64
+}
+
+// NewSeededObjectContentContainer generates size bytes of pseudo-random data from seed, without holding any of it in memory.
+func NewSeededObjectContentContainer(seed, size int64) *ObjectContentContainerSeeded {
+	return &ObjectContentContainerSeeded{Seed: seed, size: size}
+}
+
+// NewSparseObjectContentContainer generates size bytes which are zero, but for the data in extents, generated from seed.
+// Extents must be in order, not overlap, and lie within size; for page blobs, they should also be aligned to 512 byte pages.
+func NewSparseObjectContentContainer(a Asserter, seed, size int64, extents []ContentRange) *ObjectContentContainerSeeded {
+	a.NoError("validate extents", validateContentExtents(size, extents))
+	return &ObjectContentContainerSeeded{Seed: seed, size: size, extents: extents, sparse: true}
+}
+
+func validateContentExtents(size int64, extents []ContentRange) error {
+	end := int64(0)
+	for _, e := range extents {
+		if e.Offset < end || e.Count <= 0 || e.End() > size {
+			return fmt.Errorf("extent %d+%d must be non-empty, follow the extent before it (ending at %d), and end within %d bytes", e.Offset, e.Count, end, size)
+		}
+		end = e.End()
+	}
+
+	return nil
+}
+
+// Sampled verifies the content by comparing count ranges of sampleSize bytes (as well as the first, the last, and the edges of
+// any extents), rather than downloading all of it. The ranges sampled are chosen from the seed, so are the same each run.
+func (o *ObjectContentContainerSeeded) Sampled(count int, sampleSize int64) *ObjectContentContainerSeeded {
+	out := *o
+	out.sampleCount, out.sampleSize = count, sampleSize
+	return &out
+}
+
+func (o *ObjectContentContainerSeeded) Size() int64 {
+	return o.size
+}
+
+func (o *ObjectContentContainerSeeded) Reader() io.ReadSeeker {
+	return &seededContentReader{content: o}
+}
+
+func (o *ObjectContentContainerSeeded) DataRanges() []ContentRange {
+	if !o.sparse {
+		return []ContentRange{{Count: o.size}}
+	}
+
+	return o.extents
+}
+
+func (o *ObjectContentContainerSeeded) SampleRanges() []ContentRange {
+	if o.sampleCount == 0 || o.size == 0 {
+		return nil
+	}
+
+	count := o.sampleSize
+	if count > o.size {
+		count = o.size
+	}
+
+	var out []ContentRange
+	add := func(offset int64) { // clamped, so every sample is whole
+		if offset > o.size-count {
+			offset = o.size - count
+		}
+		if offset < 0 {
+			offset = 0
+		}
+		out = append(out, ContentRange{Offset: offset, Count: count})
+	}
+
+	add(0)
+	add(o.size - o.sampleSize)
+	if o.sparse {
+		// Straddle each edge between data and zeroes, where an uploader skipping the zeroes is likeliest to go wrong.
+		for _, e := range o.extents {
+			add(e.Offset - o.sampleSize/2)
+			add(e.End() - o.sampleSize/2)
+		}
+	}
+
+	rng := uint64(o.Seed)
+	for i := 0; i < o.sampleCount; i++ {
+		rng = splitmix64(rng)
+		add(int64(rng % uint64(o.size)))
+	}
+
+	sort.Slice(out, func(i, j int) bool { return out[i].Offset < out[j].Offset })
+	return out
+}
+
+func (o *ObjectContentContainerSeeded) ReadRange(r ContentRange) []byte {
+	buf := make([]byte, r.Count)
+	n, _ := o.readAt(buf, r.Offset)
+	return buf[:n]
+}
+
+// readAt fills p from off, up to the end of the content.
+func (o *ObjectContentContainerSeeded) readAt(p []byte, off int64) (int, error) {
+	if off >= o.size {
+		return 0, io.EOF
+	}
+	if remaining := o.size - off; int64(len(p)) > remaining {
+		p = p[:remaining]
+	}
+
+	if !o.sparse {
+		fillSeeded(p, o.Seed, off)
+		return len(p), nil
+	}
+
+	for i := range p {
+		p[i] = 0
+	}
+	// Extents are in order, so skip to the first one ending after off.
+	first := sort.Search(len(o.extents), func(i int) bool { return o.extents[i].End() > off })
+	for _, e := range o.extents[first:] {
+		if e.Offset >= off+int64(len(p)) {
+			break
+		}
+
+		start, end := e.Offset, e.End()
+		if start < off {
+			start = off
+		}
+		if end > off+int64(len(p)) {
+			end = off + int64(len(p))
+		}
+		fillSeeded(p[start-off:end-off], o.Seed, start)
+	}
+
+	return len(p), nil
+}
+
+// fillSeeded writes the seeded data found at off into p. Each 8 byte word of the data is splitmix64 of the seed and the word's index,
+// so any of it can be generated without generating what comes before.
+func fillSeeded(p []byte, seed, off int64) {
+	var word [8]byte
+	for len(p) > 0 {
+		index, skip := off/8, off%8
+		binary.LittleEndian.PutUint64(word[:], splitmix64(uint64(seed)+uint64(index)*0x9E3779B97F4A7C15))
+
+		n := copy(p, word[skip:])
+		p, off = p[n:], off+int64(n)
+	}
+}
+
+// splitmix64 is the finalizer of the SplitMix64 generator: a cheap, well-mixed hash of x.
+func splitmix64(x uint64) uint64 {
+	x += 0x9E3779B97F4A7C15
+	x = (x ^ (x >> 30)) * 0xBF58476D1CE4E5B9
+	x = (x ^ (x >> 27)) * 0x94D049BB133111EB
+	return x ^ (x >> 31)
+}
+
+type seededContentReader struct {
+	content *ObjectContentContainerSeeded
+	offset  int64
+}
+
+func (r *seededContentReader) Read(p []byte) (int, error) {
+	n, err := r.content.readAt(p, r.offset)
+	r.offset += int64(n)
+	return n, err
+}
+
+func (r *seededContentReader) ReadAt(p []byte, off int64) (int, error) {
+	n, err := r.content.readAt(p, off)
+	if err == nil && n < len(p) {
+		err = io.EOF
+	}
+	return n, err
+}
+
+func (r *seededContentReader) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += r.offset
+	case io.SeekEnd:
+		offset += r.content.size
+	default:
+		return r.offset, errors.New("invalid whence")
+	}
+
+	if offset < 0 {
+		return r.offset, errors.New("negative position")
+	}
+	r.offset = offset
+	return offset, nil
+}
@@ -1,6 +1,11 @@
 package e2etest
 
-import "path"
+import (
+	"fmt"
+	"path"
+
+	"github.com/Azure/azure-storage-azcopy/v10/common"
+)
 
 // this should maybe be in newe2e_resource_definitions but it felt relevant to have on it's own
 
@@ -43,3 +48,87 @@ func (o ObjectResourceMappingObject) Flatten() map[string]ResourceDefinitionObje
 		"": ResourceDefinitionObject(o),
 	}
 }
+
+// DirectoryTreeOptions describes a uniformly shaped tree; Depth 2 with Breadth 3 is 3 folders, each holding 3 more.
+type DirectoryTreeOptions struct {
+	Depth   int
+	Breadth int
+	// FilesPerFolder includes the root of the tree.
+	FilesPerFolder int
+	// FileSize is parsed by SizeFromString, and defaults to 0 bytes.
+	FileSize string
+	// IncludeFolders adds an entry per folder. Leave it off when validating against a service without real folders, such as Blob.
+	IncludeFolders bool
+}
+
+// NewDirectoryTreeMapping generates a nested tree of random files, useful for asserting structure after recursive transfers.
+func NewDirectoryTreeMapping(a Asserter, opts DirectoryTreeOptions) ObjectResourceMappingFlat {
+	out := ObjectResourceMappingFlat{}
+	size := int64(0)
+	if opts.FileSize != "" {
+		size = SizeFromString(opts.FileSize)
+	}
+
+	var generate func(dir string, depth int)
+	generate = func(dir string, depth int) {
+		for i := 0; i < opts.FilesPerFolder; i++ {
+			out[path.Join(dir, fmt.Sprintf("file%d", i))] = ResourceDefinitionObject{
+				ObjectProperties: ObjectProperties{EntityType: common.EEntityType.File()},
+				Body:             NewRandomObjectContentContainer(a, size),
+			}
+		}
+
+		if depth == opts.Depth {
+			return
+		}
+
+		for i := 0; i < opts.Breadth; i++ {
+			child := path.Join(dir, fmt.Sprintf("dir%d", i))
+			if opts.IncludeFolders {
+				out[child] = ResourceDefinitionObject{
+					ObjectProperties: ObjectProperties{EntityType: common.EEntityType.Folder()},
+				}
+			}
+
+			generate(child, depth+1)
+		}
+	}
+	generate("", 0)
+
+	return out
+}
+
+// GeneratedObjectsOptions describes a large, flat set of objects, such as the million tiny files the scale scenarios scan.
+type GeneratedObjectsOptions struct {
+	Count int
+	// PerFolder splits the objects into folders of this many; 0 puts them all at the root.
+	PerFolder int
+	// Size is parsed by SizeFromString, and defaults to 0 bytes.
+	Size string
+	// Seed seeds the first object's content; each object after it uses the next seed.
+	Seed int64
+}
+
+// NewGeneratedObjectMapping generates opts.Count objects of seeded content. Unlike NewDirectoryTreeMapping, none of the content is
+// held in memory until it's read, so the count is bound only by the names.
+func NewGeneratedObjectMapping(opts GeneratedObjectsOptions) ObjectResourceMappingFlat {
+	out := make(ObjectResourceMappingFlat, opts.Count)
+	size := int64(0)
+	if opts.Size != "" {
+		size = SizeFromString(opts.Size)
+	}
+
+	for i := 0; i < opts.Count; i++ {
+		name := fmt.Sprintf("obj%07d", i)
+		if opts.PerFolder > 0 {
+			name = path.Join(fmt.Sprintf("dir%05d", i/opts.PerFolder), name)
+		}
+
+		out[name] = ResourceDefinitionObject{
+			ObjectProperties: ObjectProperties{EntityType: common.EEntityType.File()},
+			Body:             NewSeededObjectContentContainer(opts.Seed+int64(i), size),
+		}
+	}
+
+	return out
+}
@@ -89,7 +89,7 @@ type ResourceDefinitionContainer struct {
 }
 
 func (r ResourceDefinitionContainer) GenerateAdoptiveParent(a Asserter) ResourceDefinition {
-	cName := DerefOrDefault(r.ContainerName, uuid.NewString())
+	cName := DerefOrDefault(r.ContainerName, NewContainerName(a))
 
 	return &ResourceDefinitionService{Containers: map[string]ResourceDefinitionContainer{
 		cName: r,
@@ -2,6 +2,9 @@ package e2etest
 
 import (
 	"fmt"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/blob"
 	blobsas "github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/sas"
 	blobservice "github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/service"
 	blobfscommon "github.com/Azure/azure-sdk-for-go/sdk/storage/azdatalake"
@@ -10,14 +13,36 @@ import (
 	filesas "github.com/Azure/azure-sdk-for-go/sdk/storage/azfile/sas"
 	fileservice "github.com/Azure/azure-sdk-for-go/sdk/storage/azfile/service"
 	"github.com/Azure/azure-storage-azcopy/v10/common"
+	"net/url"
+	"time"
 )
 
 type AzureAccountResourceManager struct {
 	accountName string
 	accountKey  string
-	accountType AccountType
+	// accountKeyName is which of the account's keys accountKey is ("key1" or "key2"), if known. See RotateKey.
+	accountKeyName string
+	accountType    AccountType
+	// tokenCredential, if present, is used to request user delegation keys. See AzureURIOpts.UseUserDelegation.
+	// On keyless accounts (see GetServiceWithCredential), it also authenticates every client.
+	tokenCredential azcore.TokenCredential
 
-	armClient *ARMStorageAccount
+	armClient        *ARMStorageAccount
+	classicARMClient *ARMClassicStorageAccount
+	// emulatorEndpoint, if present, is the Blob endpoint of a storage emulator, which serves the account path-style.
+	emulatorEndpoint *url.URL
+}
+
+// uriScheme returns the scheme requested by opts, falling back on the emulator's own scheme, or https.
+func (acct *AzureAccountResourceManager) uriScheme(opts GetURIOptions) string {
+	switch {
+	case opts.RemoteOpts.Scheme != "":
+		return opts.RemoteOpts.Scheme
+	case acct.emulatorEndpoint != nil:
+		return acct.emulatorEndpoint.Scheme
+	default:
+		return "https"
+	}
 }
 
 func (acct *AzureAccountResourceManager) ApplySAS(URI string, loc common.Location, optList ...GetURIOptions) string {
@@ -30,32 +55,65 @@ func (acct *AzureAccountResourceManager) ApplySAS(URI string, loc common.Locatio
 		return URI
 	}
 
+	if opts.AzureOpts.AccountSAS {
+		if opts.AzureOpts.UseUserDelegation {
+			panic("User delegation can only sign service SAS tokens; account SAS tokens require the account key.")
+		}
+		acct.panicIfKeyless()
+
+		accountOpts := opts.AzureOpts.AccountSASValues.withDefaults(loc)
+		SetIfZero(&accountOpts.Protocol, acct.defaultSASProtocol(opts))
+		common.PanicIfErr(accountOpts.Validate())
+
+		params, err := signAccountSAS(acct.accountName, acct.accountKey, accountOpts.Services, accountOpts.signatureValues())
+		common.PanicIfErr(err)
+
+		return appendSASQuery(URI, params, acct.uriScheme(opts))
+	}
+
+	if opts.AzureOpts.UseUserDelegation {
+		return acct.applyUserDelegationSAS(URI, loc, opts)
+	}
+	acct.panicIfKeyless()
+
 	var sasVals GenericSignatureValues
 	if opts.AzureOpts.SASValues == nil {
 		// Default to account level SAS to cover all our bases
-		sasVals = GenericAccountSignatureValues{}
+		sasVals = GenericAccountSignatureValues{Protocol: acct.defaultSASProtocol(opts)}
 	} else {
 		sasVals = opts.AzureOpts.SASValues
 
 	}
+	common.PanicIfErr(sasVals.Validate())
+
+	if svcVals, ok := sasVals.(GenericServiceSignatureValues); ok && svcVals.Identifier != "" {
+		sasVals = acct.ensureStoredAccessPolicy(URI, loc, svcVals)
+	}
 
 	switch loc {
 	case common.ELocation.Blob():
 		skc, err := blobservice.NewSharedKeyCredential(acct.accountName, acct.accountKey)
 		common.PanicIfErr(err)
 
-		p, err := sasVals.AsBlob().SignWithSharedKey(skc)
+		parts, err := blobsas.ParseURL(URI)
 		common.PanicIfErr(err)
 
-		parts, err := blobsas.ParseURL(URI)
+		if svcVals, ok := sasVals.(GenericServiceSignatureValues); ok {
+			sasVals, err = scopeBlobSASToSnapshot(svcVals, parts)
+			common.PanicIfErr(err)
+		}
+		common.PanicIfErr(validateSASPermissions(sasVals, loc))
+
+		p, err := sasVals.AsBlob().SignWithSharedKey(skc)
 		common.PanicIfErr(err)
 
 		parts.SAS = p
-		parts.Scheme = common.Iff(opts.RemoteOpts.Scheme != "", opts.RemoteOpts.Scheme, "https")
+		parts.Scheme = acct.uriScheme(opts)
 		return parts.String()
 	case common.ELocation.File():
 		skc, err := fileservice.NewSharedKeyCredential(acct.accountName, acct.accountKey)
 		common.PanicIfErr(err)
+		common.PanicIfErr(validateSASPermissions(sasVals, loc))
 
 		p, err := sasVals.AsFile().SignWithSharedKey(skc)
 		common.PanicIfErr(err)
@@ -64,9 +122,26 @@ func (acct *AzureAccountResourceManager) ApplySAS(URI string, loc common.Locatio
 		common.PanicIfErr(err)
 
 		parts.SAS = p
-		parts.Scheme = common.Iff(opts.RemoteOpts.Scheme != "", opts.RemoteOpts.Scheme, "https")
+		parts.Scheme = acct.uriScheme(opts)
 		return parts.String()
 	case common.ELocation.BlobFS():
+		common.PanicIfErr(validateSASPermissions(sasVals, loc))
+		if svcVals, ok := sasVals.(GenericServiceSignatureValues); ok && svcVals.SignedIdentifier != "" {
+			// The datalake SDK demands inline permissions even when a stored access policy supplies them.
+			// Blob SAS tokens are honored by the dfs endpoint too, so sign one of those instead.
+			skc, err := blobservice.NewSharedKeyCredential(acct.accountName, acct.accountKey)
+			common.PanicIfErr(err)
+
+			p, err := svcVals.AsBlob().SignWithSharedKey(skc)
+			common.PanicIfErr(err)
+
+			parts, err := datalakesas.ParseURL(blobStripSAS(URI) + "?" + p.Encode())
+			common.PanicIfErr(err)
+
+			parts.Scheme = acct.uriScheme(opts)
+			return parts.String()
+		}
+
 		skc, err := blobfscommon.NewSharedKeyCredential(acct.accountName, acct.accountKey)
 		common.PanicIfErr(err)
 
@@ -77,7 +152,7 @@ func (acct *AzureAccountResourceManager) ApplySAS(URI string, loc common.Locatio
 		common.PanicIfErr(err)
 
 		parts.SAS = p
-		parts.Scheme = common.Iff(opts.RemoteOpts.Scheme != "", opts.RemoteOpts.Scheme, "https")
+		parts.Scheme = acct.uriScheme(opts)
 		return parts.String()
 	default:
 		panic("Unsupported location " + loc.String())
@@ -85,12 +160,243 @@ func (acct *AzureAccountResourceManager) ApplySAS(URI string, loc common.Locatio
 	}
 }
 
+// keyless indicates the account's clients authenticate with its token credential, because there is no key to use. See GetServiceWithCredential.
+func (acct *AzureAccountResourceManager) keyless() bool {
+	return acct.accountKey == ""
+}
+
+func (acct *AzureAccountResourceManager) panicIfKeyless() {
+	if acct.keyless() {
+		panic(fmt.Sprintf("Account %s has no key to sign a SAS with; use OAuth, or a user delegation SAS.", acct.accountName))
+	}
+}
+
+// defaultSASProtocol permits plain HTTP when the URI will use it, as is usual against an emulator. Otherwise, it leaves the default of HTTPS alone.
+func (acct *AzureAccountResourceManager) defaultSASProtocol(opts GetURIOptions) blobsas.Protocol {
+	return common.Iff(acct.uriScheme(opts) == "http", blobsas.ProtocolHTTPSandHTTP, "")
+}
+
+// scopeBlobSASToSnapshot scopes a service SAS to the snapshot or version the blob URL addresses, if any.
+// Such a SAS must name its blob, so the container and blob names are taken from the URL unless specified.
+// The snapshot or version itself stays in the URL alongside the SAS.
+func scopeBlobSASToSnapshot(vals GenericServiceSignatureValues, parts blobsas.URLParts) (GenericServiceSignatureValues, error) {
+	if parts.Snapshot == "" && parts.VersionID == "" {
+		return vals, nil
+	}
+
+	if parts.Snapshot != "" && vals.SnapshotTime.IsZero() {
+		snapshotTime, err := time.Parse(blob.SnapshotTimeFormat, parts.Snapshot)
+		if err != nil {
+			return vals, fmt.Errorf("failed to parse snapshot %q: %w", parts.Snapshot, err)
+		}
+		vals.SnapshotTime = snapshotTime
+	}
+	SetIfZero(&vals.BlobVersion, parts.VersionID)
+	SetIfZero(&vals.ContainerName, parts.ContainerName)
+	SetIfZero(&vals.ObjectName, parts.BlobName)
+
+	return vals, nil
+}
+
+// maxUserDelegationKeyLifetime is the longest the service will issue a user delegation key for.
+const maxUserDelegationKeyLifetime = time.Hour * 24 * 7
+
+// userDelegationKeyWindow determines the validity window of the user delegation key for a SAS valid from start until expiry.
+// The service rejects keys lasting more than 7 days, and a SAS cannot outlive the key it's signed with,
+// so the returned expiry should be used as the SAS expiry as well.
+func userDelegationKeyWindow(start, expiry time.Time) (keyStart, keyExpiry time.Time) {
+	keyStart, keyExpiry = start.UTC(), expiry.UTC()
+
+	if limit := keyStart.Add(maxUserDelegationKeyLifetime); keyExpiry.After(limit) {
+		keyExpiry = limit
+	}
+
+	return keyStart, keyExpiry
+}
+
+// applyUserDelegationSAS signs a service SAS with a user delegation key, which is requested from the service using the account's token credential.
+func (acct *AzureAccountResourceManager) applyUserDelegationSAS(URI string, loc common.Location, opts GetURIOptions) string {
+	if acct.tokenCredential == nil {
+		panic(fmt.Sprintf("Account %s has no token credential to request a user delegation key with.", acct.accountName))
+	}
+
+	sasVals := GenericServiceSignatureValues{}
+	if opts.AzureOpts.SASValues != nil {
+		var ok bool
+		sasVals, ok = opts.AzureOpts.SASValues.(GenericServiceSignatureValues)
+		if !ok {
+			panic("User delegation can only sign service SAS tokens; account SAS tokens require the account key.")
+		}
+	}
+	if sasVals.SignedIdentifier != "" || sasVals.Identifier != "" {
+		panic("User delegation SAS tokens cannot reference a stored access policy.")
+	}
+	common.PanicIfErr(sasVals.Validate())
+
+	switch loc {
+	case common.ELocation.Blob():
+		parts, err := blobsas.ParseURL(URI)
+		common.PanicIfErr(err)
+
+		SetIfZero(&sasVals.ContainerName, parts.ContainerName)
+		sasVals, err = scopeBlobSASToSnapshot(sasVals, parts)
+		common.PanicIfErr(err)
+		common.PanicIfErr(validateSASPermissions(sasVals, loc))
+		vals := sasVals.AsBlob().(*blobsas.BlobSignatureValues)
+		keyStart, keyExpiry := userDelegationKeyWindow(vals.StartTime, vals.ExpiryTime)
+		vals.StartTime, vals.ExpiryTime = keyStart, keyExpiry
+
+		client, err := blobservice.NewClient(acct.getServiceURL(nil, loc), acct.tokenCredential, nil)
+		common.PanicIfErr(err)
+		udc, err := client.GetUserDelegationCredential(ctx, blobservice.KeyInfo{
+			Start:  to.Ptr(keyStart.Format(blobsas.TimeFormat)),
+			Expiry: to.Ptr(keyExpiry.Format(blobsas.TimeFormat)),
+		}, nil)
+		common.PanicIfErr(err)
+
+		p, err := vals.SignWithUserDelegation(udc)
+		common.PanicIfErr(err)
+
+		parts.SAS = p
+		parts.Scheme = acct.uriScheme(opts)
+		return parts.String()
+	case common.ELocation.BlobFS():
+		parts, err := datalakesas.ParseURL(URI)
+		common.PanicIfErr(err)
+
+		SetIfZero(&sasVals.ContainerName, parts.FileSystemName)
+		common.PanicIfErr(validateSASPermissions(sasVals, loc))
+		vals := sasVals.AsDatalake().(*datalakesas.DatalakeSignatureValues)
+		keyStart, keyExpiry := userDelegationKeyWindow(vals.StartTime, vals.ExpiryTime)
+		vals.StartTime, vals.ExpiryTime = keyStart, keyExpiry
+
+		client, err := blobfsservice.NewClient(acct.getServiceURL(nil, loc), acct.tokenCredential, nil)
+		common.PanicIfErr(err)
+		udc, err := client.GetUserDelegationCredential(ctx, blobfsservice.KeyInfo{
+			Start:  to.Ptr(keyStart.Format(datalakesas.TimeFormat)),
+			Expiry: to.Ptr(keyExpiry.Format(datalakesas.TimeFormat)),
+		}, nil)
+		common.PanicIfErr(err)
+
+		p, err := vals.SignWithUserDelegation(udc)
+		common.PanicIfErr(err)
+
+		parts.SAS = p
+		parts.Scheme = acct.uriScheme(opts)
+		return parts.String()
+	case common.ELocation.File():
+		panic("Azure Files does not support user delegation SAS; sign with the account key instead.")
+	default:
+		panic("Unsupported location " + loc.String())
+	}
+}
+
+// ApplyAccountSAS returns the URL of the account's service at loc with an account SAS appended.
+// Unlike ApplySAS, the SAS may cover several services, so the same token can be used across e.g. Blob and File.
+func (acct *AzureAccountResourceManager) ApplyAccountSAS(a Asserter, loc common.Location, opts AccountSASOptions) string {
+	if acct == nil {
+		panic("Account must not be nil to generate a SAS token.")
+	}
+	acct.panicIfKeyless()
+	SetIfZero(&opts.Protocol, acct.defaultSASProtocol(GetURIOptions{}))
+	common.PanicIfErr(opts.Validate())
+
+	params, err := signAccountSAS(acct.accountName, acct.accountKey, opts.Services, opts.signatureValues())
+	common.PanicIfErr(err)
+
+	return appendSASQuery(acct.getServiceURL(a, loc), params, acct.uriScheme(GetURIOptions{}))
+}
+
+// appendSASQuery adds SAS parameters to URI, keeping any other query parameters (e.g. a snapshot) it already has.
+// SAS parameters already on the URI are replaced.
+func appendSASQuery(URI string, params url.Values, scheme string) string {
+	u, err := url.Parse(URI)
+	common.PanicIfErr(err)
+
+	query := u.Query()
+	for k, v := range params {
+		query[k] = v
+	}
+
+	u.RawQuery = query.Encode()
+	u.Scheme = common.Iff(scheme != "", scheme, "https")
+	return u.String()
+}
+
 // ManagementClient returns the parent management client for this storage account.
 // If this was created raw from key+name, this will return nil.
 // If the account is a "modern" ARM storage account, ARMStorageAccount will be returned.
-// If the account is a "classic" storage account, ARMClassicStorageAccount (not yet implemented) will be returned.
-func (acct *AzureAccountResourceManager) ManagementClient() *ARMStorageAccount {
-	return acct.armClient
+// If the account is a "classic" storage account, ARMClassicStorageAccount will be returned.
+func (acct *AzureAccountResourceManager) ManagementClient() ARMStorageAccountClient {
+	// Return an untyped nil rather than a nil pointer, so that callers can compare against nil.
+	switch {
+	case acct.accountType == EAccountType.Classic() && acct.classicARMClient != nil:
+		return acct.classicARMClient
+	case acct.accountType != EAccountType.Classic() && acct.armClient != nil:
+		return acct.armClient
+	default:
+		return nil
+	}
+}
+
+// EnsureEncryptionScope creates (or re-enables) an encryption scope with Microsoft-managed keys on the account, and returns its name.
+// Scopes can't be deleted, so they're left in place to be reused; accounts that aren't managed through ARM skip.
+func (acct *AzureAccountResourceManager) EnsureEncryptionScope(a Asserter, name string) string {
+	if acct.armClient == nil {
+		a.Skip("encryption scopes require an account managed through ARM")
+	}
+
+	scope := &ARMEncryptionScope{ARMStorageAccount: acct.armClient, ScopeName: name}
+	_, err := scope.CreateOrUpdate(ARMEncryptionScopeProperties{
+		Source: ARMEncryptionScopeSourceStorage,
+		State:  ARMEncryptionScopeStateEnabled,
+	})
+	a.NoError("create encryption scope "+name, err)
+
+	return name
+}
+
+// GetEncryptionScope is AzureAccountResourceManager.EnsureEncryptionScope, for an account that's a mock during dry runs.
+func GetEncryptionScope(a Asserter, acct AccountResourceManager, name string) string {
+	if d, isDryrunner := a.(DryrunAsserter); isDryrunner && d.Dryrun() {
+		return name
+	}
+
+	return GetTypeOrAssert[*AzureAccountResourceManager](a, acct).EnsureEncryptionScope(a, name)
+}
+
+// RotateKey regenerates the account key named keyName ("key1" or "key2") through ARM, invalidating every SAS signed with it.
+// If it's the key in use, the account picks up the new value, so SAS tokens and clients made afterward work; clients made before don't.
+// Rotating a key breaks everything else using the account, so only rotate the keys of accounts a scenario created itself (see CreateAccount).
+func (acct *AzureAccountResourceManager) RotateKey(a Asserter, keyName string) {
+	if acct.armClient == nil {
+		a.Skip("rotating keys requires an account managed through ARM")
+		return
+	}
+
+	keys, err := acct.armClient.RegenerateKey(keyName)
+	a.NoError("regenerate key "+keyName, err)
+	if err != nil || (acct.accountKeyName != "" && acct.accountKeyName != keyName) {
+		return
+	}
+
+	for _, key := range keys.Keys {
+		if key.KeyName == keyName {
+			acct.accountKey, acct.accountKeyName = key.Value, key.KeyName
+			return
+		}
+	}
+
+	a.Error(fmt.Sprintf("regenerated key %s is missing from the account's keys", keyName))
+}
+
+// RotateAccountKey is AzureAccountResourceManager.RotateKey, for an account that's a mock during dry runs.
+func RotateAccountKey(a Asserter, acct AccountResourceManager, keyName string) {
+	if d, isDryrunner := a.(DryrunAsserter); isDryrunner && d.Dryrun() {
+		return
+	}
+
+	GetTypeOrAssert[*AzureAccountResourceManager](a, acct).RotateKey(a, keyName)
 }
 
 func (acct *AzureAccountResourceManager) AccountName() string {
@@ -102,6 +408,14 @@ func (acct *AzureAccountResourceManager) AccountType() AccountType {
 }
 
 func (acct *AzureAccountResourceManager) AvailableServices() []common.Location {
+	if acct.emulatorEndpoint != nil {
+		return []common.Location{common.ELocation.Blob()}
+	}
+
+	if services, ok := accountTypeServices[acct.accountType]; ok {
+		return services
+	}
+
 	return []common.Location{
 		common.ELocation.Blob(),
 		common.ELocation.BlobFS(),
@@ -110,6 +424,21 @@ func (acct *AzureAccountResourceManager) AvailableServices() []common.Location {
 }
 
 func (acct *AzureAccountResourceManager) getServiceURL(a Asserter, service common.Location) string {
+	if acct.emulatorEndpoint != nil {
+		if service != common.ELocation.Blob() {
+			a.Error(fmt.Sprintf("Service %s is not supported by the storage emulator.", service))
+			return ""
+		}
+
+		return acct.emulatorEndpoint.JoinPath(acct.accountName).String() + "/"
+	}
+
+	if !ListContains(service, acct.AvailableServices()) {
+		// e.g. a File variation against a premium block blob account; the combination doesn't exist, so there's nothing to test.
+		a.Skip(fmt.Sprintf("Service %s is not offered by %s accounts", service, acct.accountType))
+		return ""
+	}
+
 	switch service {
 	case common.ELocation.Blob():
 		return fmt.Sprintf("https://%s.blob.core.windows.net/", acct.accountName)
@@ -130,7 +459,7 @@ func (acct *AzureAccountResourceManager) GetService(a Asserter, location common.
 	case common.ELocation.Blob():
 		sharedKey, err := blobservice.NewSharedKeyCredential(acct.accountName, acct.accountKey)
 		a.NoError("Create shared key", err)
-		client, err := blobservice.NewClientWithSharedKeyCredential(uri, sharedKey, nil)
+		client, err := blobservice.NewClientWithSharedKeyCredential(uri, sharedKey, &blobservice.ClientOptions{ClientOptions: serviceClientOptions()})
 		a.NoError("Create Blob client", err)
 
 		return &BlobServiceResourceManager{
@@ -140,7 +469,7 @@ func (acct *AzureAccountResourceManager) GetService(a Asserter, location common.
 	case common.ELocation.File():
 		sharedKey, err := fileservice.NewSharedKeyCredential(acct.accountName, acct.accountKey)
 		a.NoError("Create shared key", err)
-		client, err := fileservice.NewClientWithSharedKeyCredential(uri, sharedKey, nil)
+		client, err := fileservice.NewClientWithSharedKeyCredential(uri, sharedKey, &fileservice.ClientOptions{ClientOptions: serviceClientOptions()})
 		a.NoError("Create File client", err)
 
 		return &FileServiceResourceManager{
@@ -149,7 +478,7 @@ func (acct *AzureAccountResourceManager) GetService(a Asserter, location common.
 		}
 	case common.ELocation.BlobFS():
 		sharedKey, err := blobfscommon.NewSharedKeyCredential(acct.accountName, acct.accountKey)
-		client, err := blobfsservice.NewClientWithSharedKeyCredential(uri, sharedKey, nil)
+		client, err := blobfsservice.NewClientWithSharedKeyCredential(uri, sharedKey, &blobfsservice.ClientOptions{ClientOptions: serviceClientOptions()})
 		a.NoError("Create BlobFS client", err)
 
 		return &BlobFSServiceResourceManager{
@@ -160,3 +489,48 @@ func (acct *AzureAccountResourceManager) GetService(a Asserter, location common.
 		return nil // GetServiceURL already covered the error
 	}
 }
+
+// GetServiceWithCredential is GetService for accounts that disallow shared key access.
+// Clients are built with cred rather than the account key, and so is everything obtained from the returned service;
+// SAS tokens can't be signed for it (except by user delegation), so RunAzCopy defaults to OAuth for its resources.
+func (acct *AzureAccountResourceManager) GetServiceWithCredential(a Asserter, location common.Location, cred azcore.TokenCredential) ServiceResourceManager {
+	a.AssertNow("a token credential is required", Not{IsNil{}}, cred)
+
+	keyless := *acct
+	keyless.accountKey = ""
+	keyless.tokenCredential = cred
+	uri := keyless.getServiceURL(a, location)
+
+	switch location {
+	case common.ELocation.Blob():
+		client, err := blobservice.NewClient(uri, cred, &blobservice.ClientOptions{ClientOptions: serviceClientOptions()})
+		a.NoError("Create Blob client", err)
+
+		return &BlobServiceResourceManager{
+			internalAccount: &keyless,
+			internalClient:  client,
+		}
+	case common.ELocation.File():
+		// Files only accepts OAuth with the backup intent, which bypasses share-level permissions in favor of RBAC.
+		client, err := fileservice.NewClient(uri, cred, &fileservice.ClientOptions{
+			ClientOptions:     serviceClientOptions(),
+			FileRequestIntent: to.Ptr(fileservice.ShareTokenIntentBackup),
+		})
+		a.NoError("Create File client", err)
+
+		return &FileServiceResourceManager{
+			internalAccount: &keyless,
+			internalClient:  client,
+		}
+	case common.ELocation.BlobFS():
+		client, err := blobfsservice.NewClient(uri, cred, &blobfsservice.ClientOptions{ClientOptions: serviceClientOptions()})
+		a.NoError("Create BlobFS client", err)
+
+		return &BlobFSServiceResourceManager{
+			internalAccount: &keyless,
+			internalClient:  client,
+		}
+	default:
+		return nil // GetServiceURL already covered the error
+	}
+}
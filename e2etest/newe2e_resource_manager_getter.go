@@ -8,20 +8,63 @@ import (
 type GetResourceOptions struct {
 	// Key for AccountRegistry when using account-based systems
 	PreferredAccount *string
+	// Keyless builds Azure clients with the framework's OAuth credential instead of the account key, as needed when shared key access is disabled.
+	// See AzureAccountResourceManager.GetServiceWithCredential.
+	Keyless bool
 }
 
 // GetRootResource differs from CreateResource, in that GetRootResource obtains the lowest possible resource for a particular location
 // This eases the act of getting a base resource for tests that might utilize multiple "kinds" of resources (e.g. Local, Azure) interchangeably.
-// on *Local*, this inherently creates a container. But that's fine, because it's likely to be used.
+// on *Local*, this inherently creates a temp directory to act as the service. But that's fine, because it's likely to be used.
 func GetRootResource(a Asserter, location common.Location, varOpts ...GetResourceOptions) ResourceManager {
 	opts := FirstOrZero(varOpts)
 
 	switch location {
 	case common.ELocation.Local():
-		return NewLocalContainer(a)
+		return NewLocalService(a)
 	case common.ELocation.Blob(), common.ELocation.BlobFS(), common.ELocation.File():
+		acctName := DerefOrDefault(opts.PreferredAccount, PrimaryStandardAcct)
+		if GlobalConfig.Emulated() {
+			_, registered := AccountRegistry[acctName]
+
+			if location != common.ELocation.Blob() || !registered {
+				a.Skip(common.Iff(registered,
+					fmt.Sprintf("%s is not supported by the storage emulator", location),
+					fmt.Sprintf("account %s is not available against the storage emulator", acctName)))
+				return (&MockAccountResourceManager{accountType: EAccountType.Standard()}).GetService(a, location)
+			}
+		}
+
+		if opts.Keyless && PrimaryOAuthCache == nil {
+			a.Skip("Keyless testing requires OAuth, which is only configured alongside NEW_E2E_SUBSCRIPTION_ID")
+			return (&MockAccountResourceManager{accountType: EAccountType.Standard()}).GetService(a, location)
+		}
+
 		// acct handles the dryrun case for us
-		acct := GetAccount(a, DerefOrDefault(opts.PreferredAccount, PrimaryStandardAcct))
+		acct := GetAccount(a, acctName)
+		if azAcct, ok := acct.(*AzureAccountResourceManager); ok && opts.Keyless {
+			return azAcct.GetServiceWithCredential(a, location, PrimaryOAuthCache)
+		}
+
+		return acct.GetService(a, location)
+	case common.ELocation.S3():
+		if !GlobalConfig.S3Enabled() {
+			a.Skip("S3 testing is disabled, or S3 credentials are not configured")
+			// Dry runs carry on past Skip, and there's no registered account to mock.
+			return (&MockAccountResourceManager{accountType: EAccountType.S3()}).GetService(a, location)
+		}
+
+		acct := GetAccount(a, DerefOrDefault(opts.PreferredAccount, PrimaryS3Acct))
+		return acct.GetService(a, location)
+	case common.ELocation.GCP():
+		if !GlobalConfig.GCPEnabled() {
+			a.Skip(common.Iff(isGCPDisabled(),
+				"GCP testing is disabled because GCP_TESTS_OFF is set",
+				"GCP testing requires GOOGLE_APPLICATION_CREDENTIALS to point at a service account key, and GOOGLE_CLOUD_PROJECT to name its project"))
+			return (&MockAccountResourceManager{accountType: EAccountType.GCP()}).GetService(a, location)
+		}
+
+		acct := GetAccount(a, DerefOrDefault(opts.PreferredAccount, PrimaryGCPAcct))
 		return acct.GetService(a, location)
 	default:
 		a.Error(fmt.Sprintf("TODO: Location %s is not yet supported", location))
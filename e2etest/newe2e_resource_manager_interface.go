@@ -14,6 +14,8 @@ import (
 type GetURIOptions struct {
 	RemoteOpts RemoteURIOpts
 	AzureOpts  AzureURIOpts
+	S3Opts     S3URIOpts
+	LocalOpts  LocalURIOpts
 }
 
 type RemoteURIOpts struct {
@@ -26,6 +28,25 @@ type AzureURIOpts struct {
 	WithSAS bool
 	// Defaults to a resource-level specific minimally permissioned SAS token.
 	SASValues GenericSignatureValues
+	// UseUserDelegation signs the SAS with a user delegation key obtained via OAuth, rather than the account key.
+	// Only Blob and BlobFS support this, and only for service SAS. If SASValues is unset, the SAS is scoped to the URI's container.
+	UseUserDelegation bool
+	// AccountSAS signs an account SAS from AccountSASValues instead of using SASValues, which the SDKs would scope to the URI's service alone.
+	// Services default to the URI's service, and resource types to service, container and object.
+	AccountSAS       bool
+	AccountSASValues AccountSASOptions
+}
+
+type LocalURIOpts struct {
+	// AsFileURI renders the path as a file:// URI. AzCopy itself expects plain paths, so this must be manually specified.
+	AsFileURI bool
+}
+
+type S3URIOpts struct {
+	// WithPresign presigns a GET against the URI. AzCopy picks up S3 credentials from the environment, so this must be manually specified.
+	WithPresign bool
+	// Defaults to an hour.
+	PresignExpiry time.Duration
 }
 
 type ResourceManager interface {
@@ -224,6 +245,10 @@ type ContainerProperties struct {
 type BlobContainerProperties struct {
 	Access       *container.PublicAccessType
 	CPKScopeInfo *container.CPKScopeInfo
+
+	// HasImmutabilityPolicy and HasLegalHold are read-only; see BlobContainerResourceManager.SetImmutabilityPolicy and SetLegalHold.
+	HasImmutabilityPolicy *bool
+	HasLegalHold          *bool
 }
 
 type FileContainerProperties struct {
@@ -260,6 +285,14 @@ type ObjectResourceManager interface {
 	Exists() bool
 }
 
+// ObjectRangeDownloader is implemented by object managers which can read part of an object, so that content too big to download
+// in full can be verified by sampling it (see SampledObjectContentContainer).
+type ObjectRangeDownloader interface {
+	ContentLength(a Asserter) int64
+	// DownloadRange reads count bytes from offset, or as many as there are.
+	DownloadRange(a Asserter, offset, count int64) []byte
+}
+
 type ObjectProperties struct {
 	EntityType  common.EntityType
 	HTTPHeaders contentHeaders
@@ -275,6 +308,10 @@ type BlobProperties struct {
 	Tags                map[string]string
 	BlockBlobAccessTier *blob.AccessTier
 	PageBlobAccessTier  *pageblob.PremiumPageBlobAccessTier
+	// ArchiveStatus is reported by the service while a blob moves out of the archive tier, e.g. "rehydrate-pending-to-hot".
+	ArchiveStatus *blob.ArchiveStatus
+	// EncryptionScope is the scope the blob is written with, and reported by the service (as x-ms-encryption-scope) when read back.
+	EncryptionScope *string
 }
 
 type BlobFSProperties struct {
@@ -292,6 +329,14 @@ type FileProperties struct {
 	FileCreationTime  *time.Time
 	FileLastWriteTime *time.Time
 	FilePermissions   *string
+	// FilePermissionKey references a permission stored on the share (see FileShareResourceManager.CreatePermission), in place of FilePermissions.
+	// Keys are particular to a share, so they don't survive a copy; compare FilePermissions instead.
+	FilePermissionKey *string
+
+	// NFS shares carry POSIX properties in place of SMB attributes and permissions.
+	FileMode *string // octal, e.g. "0644"
+	Owner    *string // UID
+	Group    *string // GID
 }
 
 func (f FileProperties) hasCustomTimes() bool {
@@ -37,15 +37,6 @@ func init() {
 	)
 }
 
-var mockAccountServices = map[AccountType][]common.Location{
-	EAccountType.Standard():                     {common.ELocation.Blob(), common.ELocation.File(), common.ELocation.BlobFS()},
-	EAccountType.PremiumBlockBlobs():            {common.ELocation.Blob(), common.ELocation.BlobFS()},
-	EAccountType.PremiumPageBlobs():             {common.ELocation.Blob()},
-	EAccountType.PremiumFileShares():            {common.ELocation.File()},
-	EAccountType.HierarchicalNamespaceEnabled(): {common.ELocation.Blob(), common.ELocation.File(), common.ELocation.BlobFS()},
-	EAccountType.Classic():                      {},
-}
-
 type mockResource interface {
 	mockSignature()
 }
@@ -69,11 +60,15 @@ func (m *MockAccountResourceManager) AccountType() AccountType {
 }
 
 func (m *MockAccountResourceManager) AvailableServices() []common.Location {
-	return mockAccountServices[m.accountType]
+	return accountTypeServices[m.accountType]
 }
 
 func (m *MockAccountResourceManager) GetService(a Asserter, location common.Location) ServiceResourceManager {
 	if !ListContains(location, m.AvailableServices()) {
+		// The account type doesn't offer this service (e.g. File on premium block blobs), so the variation is dropped rather than run.
+		if d, isDryrunner := a.(DryrunAsserter); isDryrunner && d.Dryrun() {
+			d.InvalidateScenario()
+		}
 		a.Error(fmt.Sprintf("\"%s\" is not a valid service for account type %s. Valid services are: %v", location, m.accountType, m.AvailableServices()))
 	}
 
@@ -84,14 +79,18 @@ var mockServiceAuthTypes = map[common.Location]ExplicitCredentialTypes{
 	common.ELocation.Blob():   (&BlobServiceResourceManager{}).ValidAuthTypes(),
 	common.ELocation.File():   (&FileServiceResourceManager{}).ValidAuthTypes(),
 	common.ELocation.BlobFS(): (&BlobFSServiceResourceManager{}).ValidAuthTypes(),
-	// todo S3
-	// todo GCP
+	common.ELocation.S3():     (&S3ServiceResourceManager{}).ValidAuthTypes(),
+	common.ELocation.GCP():    (&GCPServiceResourceManager{}).ValidAuthTypes(),
+	common.ELocation.Local():  (&LocalServiceResourceManager{}).ValidAuthTypes(),
 }
 
 var mockServiceDefaultAuthTypes = map[common.Location]ExplicitCredentialTypes{
 	common.ELocation.Blob():   (&BlobServiceResourceManager{}).DefaultAuthType(),
 	common.ELocation.File():   (&FileServiceResourceManager{}).DefaultAuthType(),
 	common.ELocation.BlobFS(): (&BlobFSServiceResourceManager{}).DefaultAuthType(),
+	common.ELocation.S3():     (&S3ServiceResourceManager{}).DefaultAuthType(),
+	common.ELocation.GCP():    (&GCPServiceResourceManager{}).DefaultAuthType(),
+	common.ELocation.Local():  (&LocalServiceResourceManager{}).DefaultAuthType(),
 }
 
 type MockServiceResourceManager struct {
@@ -158,7 +157,7 @@ func (m *MockServiceResourceManager) GetContainer(s string) ContainerResourceMan
 }
 
 func (m *MockServiceResourceManager) IsHierarchical() bool {
-	return m.Location() == common.ELocation.File() || m.Location() == common.ELocation.BlobFS()
+	return m.Location() == common.ELocation.File() || m.Location() == common.ELocation.BlobFS() || m.Location() == common.ELocation.Local()
 }
 
 type MockContainerResourceManager struct {
@@ -2,6 +2,8 @@ package e2etest
 
 import (
 	"bytes"
+	"fmt"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/streaming"
 	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/appendblob"
 	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/blob"
 	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/bloberror"
@@ -15,13 +17,14 @@ import (
 	"io"
 	"regexp"
 	"runtime"
+	"sort"
 	"strings"
+	"time"
 )
 
 /*
 TODOs:
-- Legal Hold
-- Immutability Policy
+- Version-level immutability
 - Leases
 - CPK
 */
@@ -161,10 +164,45 @@ func (b *BlobServiceResourceManager) GetContainer(name string) ContainerResource
 	}
 }
 
+// CreateStoredAccessPolicy adds a stored access policy named id to containerName, alongside any it already has,
+// so that SAS tokens can reference it through GenericServiceSignatureValues.SignedIdentifier.
+// Policies can take up to 30 seconds to take effect.
+func (b *BlobServiceResourceManager) CreateStoredAccessPolicy(a Asserter, containerName, id string, policy container.AccessPolicy) {
+	containerClient := b.internalClient.NewContainerClient(containerName)
+
+	resp, err := containerClient.GetAccessPolicy(ctx, nil)
+	a.NoError("get container access policy", err)
+
+	identifiers := append(resp.SignedIdentifiers, &container.SignedIdentifier{
+		ID:           &id,
+		AccessPolicy: &policy,
+	})
+	_, err = containerClient.SetAccessPolicy(ctx, &container.SetAccessPolicyOptions{
+		Access:       resp.BlobPublicAccess,
+		ContainerACL: identifiers,
+	})
+	a.NoError("set container access policy", err)
+}
+
 func (b *BlobServiceResourceManager) IsHierarchical() bool {
 	return b.internalAccount.AccountType() == EAccountType.HierarchicalNamespaceEnabled()
 }
 
+func (b *BlobServiceResourceManager) GetProperties(a Asserter) ServiceProperties {
+	resp, err := b.internalClient.GetProperties(ctx, nil)
+	a.NoError("get service properties", err)
+
+	return blobServicePropertiesFrom(resp)
+}
+
+// SetProperties sets the non-nil properties, and restores what they replaced as the scenario ends. See ServicePropertiesManager.
+func (b *BlobServiceResourceManager) SetProperties(a Asserter, props ServiceProperties) ServiceProperties {
+	return setServiceProperties(a, props, b.GetProperties, func(a Asserter, props ServiceProperties) {
+		_, err := b.internalClient.SetProperties(ctx, props.blobSetOptions())
+		a.NoError("set service properties", err)
+	})
+}
+
 // ==================== CONTAINER ====================
 
 type BlobContainerResourceManager struct {
@@ -206,6 +244,15 @@ func (b *BlobContainerResourceManager) Parent() ResourceManager {
 
 var premiumRegex = regexp.MustCompile("P\\d{2}")
 
+// premiumPageBlobAccessTier picks out the tiers of premium page blobs, which the service reports alongside the block blob tiers.
+func premiumPageBlobAccessTier(blobType *blob.BlobType, tier *blob.AccessTier) *pageblob.PremiumPageBlobAccessTier {
+	if DerefOrZero(blobType) == blob.BlobTypePageBlob && premiumRegex.MatchString(string(DerefOrZero(tier))) {
+		return pointerTo(pageblob.PremiumPageBlobAccessTier(*tier))
+	}
+
+	return nil
+}
+
 func (b *BlobContainerResourceManager) ListObjects(a Asserter, prefix string, recursive bool) map[string]ObjectProperties {
 	out := make(map[string]ObjectProperties)
 
@@ -236,13 +283,9 @@ func (b *BlobContainerResourceManager) ListObjects(a Asserter, prefix string, re
 					return out
 				}(),
 				BlockBlobAccessTier: v.Properties.AccessTier,
-				PageBlobAccessTier: func() *pageblob.PremiumPageBlobAccessTier {
-					if DerefOrZero(v.Properties.BlobType) == blob.BlobTypePageBlob && premiumRegex.MatchString(string(DerefOrZero(v.Properties.AccessTier))) {
-						return pointerTo(pageblob.PremiumPageBlobAccessTier(*v.Properties.AccessTier))
-					}
-
-					return nil
-				}(),
+				PageBlobAccessTier:  premiumPageBlobAccessTier(v.Properties.BlobType, v.Properties.AccessTier),
+				ArchiveStatus:       v.Properties.ArchiveStatus,
+				EncryptionScope:     v.Properties.EncryptionScope,
 			},
 		}
 	}
@@ -292,10 +335,21 @@ func (b *BlobContainerResourceManager) GetProperties(a Asserter) ContainerProper
 	props, err := b.internalClient.GetProperties(ctx, nil)
 	a.NoError("Get container properties", err)
 
+	var cpkScopeInfo *container.CPKScopeInfo
+	if props.DefaultEncryptionScope != nil {
+		cpkScopeInfo = &container.CPKScopeInfo{
+			DefaultEncryptionScope:         props.DefaultEncryptionScope,
+			PreventEncryptionScopeOverride: props.DenyEncryptionScopeOverride,
+		}
+	}
+
 	return ContainerProperties{
 		Metadata: props.Metadata,
 		BlobContainerProperties: BlobContainerProperties{
-			Access: props.BlobPublicAccess,
+			Access:                props.BlobPublicAccess,
+			CPKScopeInfo:          cpkScopeInfo,
+			HasImmutabilityPolicy: props.HasImmutabilityPolicy,
+			HasLegalHold:          props.HasLegalHold,
 		},
 	}
 }
@@ -372,6 +426,61 @@ func (b *BlobContainerResourceManager) ContainerName() string {
 	return b.containerName
 }
 
+// armContainer addresses the container through the Storage Resource Provider, for the settings the blob service doesn't expose.
+// Accounts that aren't managed through ARM (e.g. static accounts) skip.
+func (b *BlobContainerResourceManager) armContainer(a Asserter) *ARMBlobContainer {
+	if b.internalAccount.armClient == nil {
+		a.Skip("container immutability settings require an account managed through ARM")
+	}
+
+	return &ARMBlobContainer{ARMStorageAccount: b.internalAccount.armClient, ContainerName: b.containerName}
+}
+
+// SetImmutabilityPolicy applies a time-based retention policy of periodInDays to the container, and locks it if asked.
+// Unlocked policies are removed when the scenario ends, so that the container can be deleted. Locked policies can't be;
+// the container is instead left out of the scenario's teardown, to be deleted once the period has lapsed.
+func (b *BlobContainerResourceManager) SetImmutabilityPolicy(a Asserter, periodInDays int, locked bool) {
+	c := b.armContainer(a)
+
+	policy, err := c.SetImmutabilityPolicy(ARMImmutabilityPolicyProperties{ImmutabilityPeriodSinceCreationInDays: periodInDays})
+	a.NoError("set immutability policy", err)
+
+	if locked {
+		_, err = c.LockImmutabilityPolicy(policy.ETag)
+		a.NoError("lock immutability policy", err)
+
+		a.Log("container %s has a locked immutability policy, so is left in place for %d days", b.containerName, periodInDays)
+		if t, ok := a.(resourceUntracker); ok {
+			t.UntrackCreatedResource(b)
+		}
+		return
+	}
+
+	if sa, ok := a.(ScenarioAsserter); ok {
+		sa.Cleanup(func(a ScenarioAsserter) {
+			// Fetch the policy afresh; its etag changes if it's extended.
+			policy, err := c.GetImmutabilityPolicy()
+			a.NoError("get immutability policy", err)
+			a.NoError("remove immutability policy", c.DeleteImmutabilityPolicy(policy.ETag))
+		})
+	}
+}
+
+// SetLegalHold places a legal hold, tagged with tags, on the container. It's cleared when the scenario ends, so that the container can be deleted.
+func (b *BlobContainerResourceManager) SetLegalHold(a Asserter, tags []string) {
+	c := b.armContainer(a)
+
+	_, err := c.SetLegalHold(tags)
+	a.NoError("set legal hold", err)
+
+	if sa, ok := a.(ScenarioAsserter); ok {
+		sa.Cleanup(func(a ScenarioAsserter) {
+			_, err := c.ClearLegalHold(tags)
+			a.NoError("clear legal hold", err)
+		})
+	}
+}
+
 // ==================== OBJECT ====================
 
 type BlobObjectResourceManager struct {
@@ -429,6 +538,8 @@ func (b *BlobObjectResourceManager) Create(a Asserter, body ObjectContentContain
 	b.CreateWithOptions(a, body, properties, nil)
 }
 
+// Delete deletes the blob. With soft delete enabled on the service (see BlobServiceResourceManager.EnableSoftDelete),
+// it lingers as soft-deleted until the retention period runs out: invisible to reads and listings, but recoverable via Undelete.
 func (b *BlobObjectResourceManager) Delete(a Asserter) {
 	_, err := b.internalClient.Delete(ctx, nil)
 
@@ -448,6 +559,11 @@ func (b *BlobObjectResourceManager) CreateWithOptions(a Asserter, body ObjectCon
 	opts := DerefOrZero(options)
 	blobProps := properties.BlobProperties
 
+	cpkScopeInfo := opts.CpkOptions.GetCPKScopeInfo()
+	if blobProps.EncryptionScope != nil {
+		cpkScopeInfo = common.GetCpkScopeInfo(*blobProps.EncryptionScope)
+	}
+
 	copyMeta := func() common.Metadata {
 		out := make(common.Metadata)
 
@@ -501,7 +617,7 @@ func (b *BlobObjectResourceManager) CreateWithOptions(a Asserter, body ObjectCon
 			AccessTier:              blobProps.BlockBlobAccessTier,
 			Tags:                    blobProps.Tags,
 			CPKInfo:                 opts.CpkOptions.GetCPKInfo(),
-			CPKScopeInfo:            opts.CpkOptions.GetCPKScopeInfo(),
+			CPKScopeInfo:            cpkScopeInfo,
 		})
 		a.NoError("Block blob upload", err)
 	case blob.BlobTypePageBlob:
@@ -521,7 +637,7 @@ func (b *BlobObjectResourceManager) CreateWithOptions(a Asserter, body ObjectCon
 						Tier:         blobProps.PageBlobAccessTier,
 						HTTPHeaders:  properties.HTTPHeaders.ToBlob(),
 						CPKInfo:      opts.CpkOptions.GetCPKInfo(),
-						CPKScopeInfo: opts.CpkOptions.GetCPKScopeInfo(),
+						CPKScopeInfo: cpkScopeInfo,
 					})
 
 				return err
@@ -534,7 +650,7 @@ func (b *BlobObjectResourceManager) CreateWithOptions(a Asserter, body ObjectCon
 					&pageblob.UploadPagesOptions{
 						TransactionalValidation: blob.TransferValidationTypeComputeCRC64(),
 						CPKInfo:                 opts.CpkOptions.GetCPKInfo(),
-						CPKScopeInfo:            opts.CpkOptions.GetCPKScopeInfo(),
+						CPKScopeInfo:            cpkScopeInfo,
 					})
 				return err
 			},
@@ -560,7 +676,7 @@ func (b *BlobObjectResourceManager) CreateWithOptions(a Asserter, body ObjectCon
 				_, err := client.Create(ctx, &appendblob.CreateOptions{
 					HTTPHeaders:  properties.HTTPHeaders.ToBlob(),
 					CPKInfo:      opts.CpkOptions.GetCPKInfo(),
-					CPKScopeInfo: opts.CpkOptions.GetCPKScopeInfo(),
+					CPKScopeInfo: cpkScopeInfo,
 					Tags:         blobProps.Tags,
 					Metadata:     properties.Metadata,
 				})
@@ -575,7 +691,7 @@ func (b *BlobObjectResourceManager) CreateWithOptions(a Asserter, body ObjectCon
 						MaxSize:        pointerTo(state.Offset + state.BlockSize - 1),
 					},
 					CPKInfo:      opts.CpkOptions.GetCPKInfo(),
-					CPKScopeInfo: opts.CpkOptions.GetCPKScopeInfo(),
+					CPKScopeInfo: cpkScopeInfo,
 				})
 
 				return err
@@ -606,6 +722,11 @@ func (b *BlobObjectResourceManager) GetPropertiesWithOptions(a Asserter, options
 	})
 	a.NoError("Get properties", err)
 
+	var tier *blob.AccessTier
+	if resp.AccessTier != nil {
+		tier = pointerTo(blob.AccessTier(*resp.AccessTier))
+	}
+
 	eType := common.EEntityType.File()
 	switch {
 	case strings.EqualFold(DerefOrZero(resp.Metadata[common.POSIXFolderMeta]), "true"):
@@ -641,8 +762,16 @@ func (b *BlobObjectResourceManager) GetPropertiesWithOptions(a Asserter, options
 
 				return out
 			}(),
-			BlockBlobAccessTier: nil,
-			PageBlobAccessTier:  nil,
+			BlockBlobAccessTier: tier,
+			PageBlobAccessTier:  premiumPageBlobAccessTier(resp.BlobType, tier),
+			ArchiveStatus: func() *blob.ArchiveStatus {
+				if resp.ArchiveStatus == nil {
+					return nil
+				}
+
+				return pointerTo(blob.ArchiveStatus(*resp.ArchiveStatus))
+			}(),
+			EncryptionScope: resp.EncryptionScope,
 		},
 	}
 }
@@ -688,8 +817,328 @@ func (b *BlobObjectResourceManager) Download(a Asserter) io.ReadSeeker {
 	return bytes.NewReader(buf.Bytes())
 }
 
+func (b *BlobObjectResourceManager) ContentLength(a Asserter) int64 {
+	resp, err := b.internalClient.GetProperties(ctx, nil)
+	a.NoError("Get properties", err)
+	return DerefOrZero(resp.ContentLength)
+}
+
+func (b *BlobObjectResourceManager) DownloadRange(a Asserter, offset, count int64) []byte {
+	resp, err := b.internalClient.DownloadStream(ctx, &blob.DownloadStreamOptions{Range: blob.HTTPRange{Offset: offset, Count: count}})
+	a.NoError("Download range", err)
+	defer resp.Body.Close()
+
+	buf, err := io.ReadAll(resp.Body)
+	a.NoError("Read body", err)
+	return buf
+}
+
 func (b *BlobObjectResourceManager) Exists() bool {
 	_, err := b.internalClient.GetProperties(ctx, nil)
 
 	return err == nil || !bloberror.HasCode(err, bloberror.BlobNotFound, bloberror.ContainerNotFound, bloberror.ContainerBeingDeleted, bloberror.ResourceNotFound)
 }
+
+// ==================== TIERS & TAGS ====================
+
+// blobRehydrationPollInterval is how often WaitForRehydration checks on a blob leaving the archive tier.
+// Rehydration takes anywhere from minutes (at high priority) to hours, so there's no sense in asking more often.
+var blobRehydrationPollInterval = time.Minute
+
+// SetTier moves the blob to tier; priority applies only when moving out of the archive tier, and may be nil.
+// Block blobs take the Hot, Cool, Cold and Archive tiers; page blobs (on premium accounts) take the P* tiers. Append blobs can't be tiered at all.
+func (b *BlobObjectResourceManager) SetTier(a Asserter, tier blob.AccessTier, priority *blob.RehydratePriority) {
+	blobType := DerefOrZero(b.GetProperties(a).BlobProperties.Type)
+	a.AssertNow("append blobs can't be tiered", Not{Equal{}}, blobType, blob.BlobTypeAppendBlob)
+	if blobType == blob.BlobTypePageBlob {
+		a.AssertNow("page blobs only take premium tiers", Equal{}, premiumRegex.MatchString(string(tier)), true)
+	}
+
+	_, err := b.internalClient.SetTier(ctx, tier, &blob.SetTierOptions{RehydratePriority: priority})
+	a.NoError("set tier", err)
+}
+
+// SetTags replaces the blob's index tags.
+func (b *BlobObjectResourceManager) SetTags(a Asserter, tags map[string]string) {
+	_, err := b.internalClient.SetTags(ctx, tags, nil)
+	a.NoError("set tags", err)
+}
+
+// WaitForRehydration waits for the blob to finish moving out of the archive tier (e.g. after SetTier, or a copy with a rehydrate priority),
+// failing the test if it's still rehydrating after timeout. It returns the tier the blob landed in.
+func (b *BlobObjectResourceManager) WaitForRehydration(a Asserter, timeout time.Duration) blob.AccessTier {
+	deadline := time.Now().Add(timeout)
+	for {
+		props := b.GetProperties(a).BlobProperties
+		if props.ArchiveStatus == nil || *props.ArchiveStatus == "" {
+			return DerefOrZero(props.BlockBlobAccessTier)
+		}
+
+		if time.Now().After(deadline) {
+			a.Error(fmt.Sprintf("blob %s still %s after %s", b.Path, *props.ArchiveStatus, timeout))
+			return DerefOrZero(props.BlockBlobAccessTier)
+		}
+		time.Sleep(blobRehydrationPollInterval)
+	}
+}
+
+// ==================== SNAPSHOTS & VERSIONS ====================
+
+// CreateSnapshot snapshots the blob as it currently stands, returning the snapshot's timestamp (see WithSnapshot).
+func (b *BlobObjectResourceManager) CreateSnapshot(a Asserter) string {
+	resp, err := b.internalClient.CreateSnapshot(ctx, nil)
+	a.NoError("create snapshot", err)
+
+	return DerefOrZero(resp.Snapshot)
+}
+
+// ListSnapshots returns the timestamps of the blob's snapshots, oldest first.
+func (b *BlobObjectResourceManager) ListSnapshots(a Asserter) []string {
+	return b.listBlobItems(a, container.ListBlobsInclude{Snapshots: true}, func(item *container.BlobItem) string {
+		return DerefOrZero(item.Snapshot)
+	})
+}
+
+// ListVersions returns the IDs of the blob's versions, oldest first. The current version is the last.
+func (b *BlobObjectResourceManager) ListVersions(a Asserter) []string {
+	return b.listBlobItems(a, container.ListBlobsInclude{Versions: true}, func(item *container.BlobItem) string {
+		return DerefOrZero(item.VersionID)
+	})
+}
+
+// listBlobItems lists this blob's entries (and none sharing its prefix), returning the non-empty keys, sorted.
+// Snapshot timestamps and version IDs are both fixed-width UTC times, so they sort oldest first.
+func (b *BlobObjectResourceManager) listBlobItems(a Asserter, include container.ListBlobsInclude, key func(item *container.BlobItem) string) []string {
+	out := make([]string, 0)
+	pager := b.Container.internalClient.NewListBlobsFlatPager(&container.ListBlobsFlatOptions{
+		Include: include,
+		Prefix:  &b.Path,
+	})
+	for pager.More() {
+		page, err := pager.NextPage(ctx)
+		a.NoError("list blobs", err)
+		if err != nil {
+			return out
+		}
+
+		for _, item := range page.Segment.BlobItems {
+			if DerefOrZero(item.Name) == b.Path && key(item) != "" {
+				out = append(out, key(item))
+			}
+		}
+	}
+	sort.Strings(out)
+
+	return out
+}
+
+// BlobVersion is a version of a blob created by OverwriteToCreateVersion, and the content it was given.
+type BlobVersion struct {
+	VersionID string
+	Body      ObjectContentContainer
+}
+
+// OverwriteToCreateVersion overwrites the blob n times with fresh random content, returning each version it created, in order.
+// The service only keeps versions when blob versioning is enabled on the account, so anywhere else, this fails the test.
+func (b *BlobObjectResourceManager) OverwriteToCreateVersion(a Asserter, n int) []BlobVersion {
+	out := make([]BlobVersion, 0, n)
+	for i := 0; i < n; i++ {
+		body := NewRandomObjectContentContainer(a, SizeFromString("1K"))
+		resp, err := b.Container.internalClient.NewBlockBlobClient(b.Path).Upload(ctx, streaming.NopCloser(body.Reader()), nil)
+		a.NoError("overwrite blob", err)
+		if err != nil {
+			return out
+		}
+		a.AssertNow("overwriting must create a version; is blob versioning enabled on the account?", Not{IsNil{}}, resp.VersionID)
+
+		out = append(out, BlobVersion{VersionID: DerefOrZero(resp.VersionID), Body: body})
+	}
+
+	return out
+}
+
+// WithSnapshot addresses one of the blob's snapshots, e.g. to validate it. Its URI carries the snapshot, through any SAS.
+func (b *BlobObjectResourceManager) WithSnapshot(a Asserter, snapshot string) *BlobObjectResourceManager {
+	client, err := b.internalClient.WithSnapshot(snapshot)
+	a.NoError("get snapshot client", err)
+
+	out := *b
+	out.internalClient = client
+	return &out
+}
+
+// WithVersion addresses one of the blob's versions, e.g. to validate it. Its URI carries the version ID, through any SAS.
+func (b *BlobObjectResourceManager) WithVersion(a Asserter, versionID string) *BlobObjectResourceManager {
+	client, err := b.internalClient.WithVersionID(versionID)
+	a.NoError("get version client", err)
+
+	out := *b
+	out.internalClient = client
+	return &out
+}
+
+// ==================== SOFT DELETE ====================
+
+// EnableSoftDelete has deleted blobs retained as soft-deleted for days (1 to 365), leaving the service's other properties be,
+// until the scenario ends. It's account-wide, so it's best used on an account of the test's own (see CreateAccount). Like other
+// service properties, it can take up to 30 seconds to take effect; blobs deleted sooner may be gone for good.
+func (b *BlobServiceResourceManager) EnableSoftDelete(a Asserter, days int32) {
+	b.SetProperties(a, ServiceProperties{BlobServiceProperties: BlobServiceProperties{DeleteRetentionDays: &days}})
+}
+
+// ListDeleted returns the names of the container's soft-deleted blobs, sorted. Live blobs aren't included.
+func (b *BlobContainerResourceManager) ListDeleted(a Asserter) []string {
+	out := make([]string, 0)
+	pager := b.internalClient.NewListBlobsFlatPager(&container.ListBlobsFlatOptions{
+		Include: container.ListBlobsInclude{Deleted: true},
+	})
+	for pager.More() {
+		page, err := pager.NextPage(ctx)
+		a.NoError("list blobs", err)
+		if err != nil {
+			return out
+		}
+
+		for _, item := range page.Segment.BlobItems {
+			if DerefOrZero(item.Deleted) {
+				out = append(out, DerefOrZero(item.Name))
+			}
+		}
+	}
+	sort.Strings(out)
+
+	return out
+}
+
+// Undelete restores the blob from being soft-deleted. Restoring a live blob is a no-op.
+func (b *BlobObjectResourceManager) Undelete(a Asserter) {
+	_, err := b.internalClient.Undelete(ctx, nil)
+	a.NoError("undelete blob", err)
+}
+
+// IsSoftDeleted reports whether the blob was deleted but is still retained, as opposed to live, or gone for good.
+func (b *BlobObjectResourceManager) IsSoftDeleted(a Asserter) bool {
+	for _, v := range b.Container.ListDeleted(a) {
+		if v == b.Path {
+			return true
+		}
+	}
+
+	return false
+}
+
+// EnableBlobSoftDelete is BlobServiceResourceManager.EnableSoftDelete, for a service that's a mock during dry runs.
+func EnableBlobSoftDelete(a Asserter, s ServiceResourceManager, days int32) {
+	if d, isDryrunner := a.(DryrunAsserter); isDryrunner && d.Dryrun() {
+		return
+	}
+
+	GetTypeOrAssert[*BlobServiceResourceManager](a, s).EnableSoftDelete(a, days)
+}
+
+// ListDeletedBlobs is BlobContainerResourceManager.ListDeleted, for a container that's a mock during dry runs.
+func ListDeletedBlobs(a Asserter, c ContainerResourceManager) []string {
+	if d, isDryrunner := a.(DryrunAsserter); isDryrunner && d.Dryrun() {
+		return nil
+	}
+
+	return GetTypeOrAssert[*BlobContainerResourceManager](a, c).ListDeleted(a)
+}
+
+// UndeleteBlob is BlobObjectResourceManager.Undelete, for an object that's a mock during dry runs.
+func UndeleteBlob(a Asserter, obj ObjectResourceManager) {
+	if d, isDryrunner := a.(DryrunAsserter); isDryrunner && d.Dryrun() {
+		return
+	}
+
+	GetTypeOrAssert[*BlobObjectResourceManager](a, obj).Undelete(a)
+}
+
+// ValidateBlobSoftDeleted asserts obj is soft-deleted (or, if softDeleted is false, live), rather than merely absent.
+func ValidateBlobSoftDeleted(a Asserter, obj ObjectResourceManager, softDeleted bool) {
+	if d, isDryrunner := a.(DryrunAsserter); isDryrunner && d.Dryrun() {
+		return
+	}
+
+	b := GetTypeOrAssert[*BlobObjectResourceManager](a, obj)
+	a.Assert(b.Path+" soft-deleted", Equal{}, b.IsSoftDeleted(a), softDeleted)
+	a.Assert(b.Path+" live", Equal{}, b.Exists(), !softDeleted)
+}
+
+// SetBlobContainerImmutabilityPolicy is BlobContainerResourceManager.SetImmutabilityPolicy, for a container that's a mock during dry runs.
+func SetBlobContainerImmutabilityPolicy(a Asserter, c ContainerResourceManager, periodInDays int, locked bool) {
+	if d, isDryrunner := a.(DryrunAsserter); isDryrunner && d.Dryrun() {
+		return
+	}
+
+	GetTypeOrAssert[*BlobContainerResourceManager](a, c).SetImmutabilityPolicy(a, periodInDays, locked)
+}
+
+// SetBlobContainerLegalHold is BlobContainerResourceManager.SetLegalHold, for a container that's a mock during dry runs.
+func SetBlobContainerLegalHold(a Asserter, c ContainerResourceManager, tags []string) {
+	if d, isDryrunner := a.(DryrunAsserter); isDryrunner && d.Dryrun() {
+		return
+	}
+
+	GetTypeOrAssert[*BlobContainerResourceManager](a, c).SetLegalHold(a, tags)
+}
+
+// BlobImmutableDueToLegalHold is what the service answers writes to a container under legal hold with. bloberror lacks it.
+const BlobImmutableDueToLegalHold bloberror.Code = "BlobImmutableDueToLegalHold"
+
+// ValidateBlobImmutable asserts that overwriting obj directly is refused with code
+// (bloberror.BlobImmutableDueToPolicy or BlobImmutableDueToLegalHold), e.g. after AzCopy has been refused the same.
+func ValidateBlobImmutable(a Asserter, obj ObjectResourceManager, code bloberror.Code) {
+	if d, isDryrunner := a.(DryrunAsserter); isDryrunner && d.Dryrun() {
+		return
+	}
+
+	b := GetTypeOrAssert[*BlobObjectResourceManager](a, obj)
+	_, err := b.Container.internalClient.NewBlockBlobClient(b.Path).Upload(ctx, streaming.NopCloser(bytes.NewReader(nil)), nil)
+	a.Assert("overwrite must be refused with "+string(code), Equal{}, bloberror.HasCode(err, code), true)
+}
+
+// BlobSnapshotOf addresses a snapshot of obj (e.g. a transfer's destination) for validation. Dry runs get obj back as-is.
+func BlobSnapshotOf(a Asserter, obj ObjectResourceManager, snapshot string) ObjectResourceManager {
+	if d, isDryrunner := a.(DryrunAsserter); isDryrunner && d.Dryrun() {
+		return obj
+	}
+
+	return GetTypeOrAssert[*BlobObjectResourceManager](a, obj).WithSnapshot(a, snapshot)
+}
+
+// BlobVersionOf addresses a version of obj (e.g. a transfer's destination) for validation. Dry runs get obj back as-is.
+func BlobVersionOf(a Asserter, obj ObjectResourceManager, versionID string) ObjectResourceManager {
+	if d, isDryrunner := a.(DryrunAsserter); isDryrunner && d.Dryrun() {
+		return obj
+	}
+
+	return GetTypeOrAssert[*BlobObjectResourceManager](a, obj).WithVersion(a, versionID)
+}
+
+// SetBlobTier is BlobObjectResourceManager.SetTier, for an object that's a mock during dry runs.
+func SetBlobTier(a Asserter, obj ObjectResourceManager, tier blob.AccessTier, priority *blob.RehydratePriority) {
+	if d, isDryrunner := a.(DryrunAsserter); isDryrunner && d.Dryrun() {
+		return
+	}
+
+	GetTypeOrAssert[*BlobObjectResourceManager](a, obj).SetTier(a, tier, priority)
+}
+
+// SetBlobTags is BlobObjectResourceManager.SetTags, for an object that's a mock during dry runs.
+func SetBlobTags(a Asserter, obj ObjectResourceManager, tags map[string]string) {
+	if d, isDryrunner := a.(DryrunAsserter); isDryrunner && d.Dryrun() {
+		return
+	}
+
+	GetTypeOrAssert[*BlobObjectResourceManager](a, obj).SetTags(a, tags)
+}
+
+// WaitForBlobRehydration is BlobObjectResourceManager.WaitForRehydration, for an object that's a mock during dry runs; those "land" in the hot tier.
+func WaitForBlobRehydration(a Asserter, obj ObjectResourceManager, timeout time.Duration) blob.AccessTier {
+	if d, isDryrunner := a.(DryrunAsserter); isDryrunner && d.Dryrun() {
+		return blob.AccessTierHot
+	}
+
+	return GetTypeOrAssert[*BlobObjectResourceManager](a, obj).WaitForRehydration(a, timeout)
+}
@@ -2,6 +2,7 @@ package e2etest
 
 import (
 	"bytes"
+	"fmt"
 	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/blob"
 	"github.com/Azure/azure-sdk-for-go/sdk/storage/azdatalake/datalakeerror"
 	"github.com/Azure/azure-sdk-for-go/sdk/storage/azdatalake/directory"
@@ -13,6 +14,8 @@ import (
 	"github.com/Azure/azure-storage-azcopy/v10/common"
 	"io"
 	"runtime"
+	"sort"
+	"strings"
 )
 
 // check that everything aligns with interfaces
@@ -123,6 +126,28 @@ func (b *BlobFSServiceResourceManager) IsHierarchical() bool {
 	return true
 }
 
+// GetProperties returns the properties of the account's blob service, which BlobFS shares.
+func (b *BlobFSServiceResourceManager) GetProperties(a Asserter) ServiceProperties {
+	resp, err := b.internalClient.GetProperties(ctx, nil)
+	a.NoError("get service properties", err)
+
+	return blobServicePropertiesFrom(resp)
+}
+
+// SetProperties sets the non-nil properties, and restores what they replaced as the scenario ends. See ServicePropertiesManager.
+func (b *BlobFSServiceResourceManager) SetProperties(a Asserter, props ServiceProperties) ServiceProperties {
+	return setServiceProperties(a, props, b.GetProperties, func(a Asserter, props ServiceProperties) {
+		opts := props.blobSetOptions()
+		_, err := b.internalClient.SetProperties(ctx, &service.SetPropertiesOptions{
+			CORS:                  opts.CORS,
+			DefaultServiceVersion: opts.DefaultServiceVersion,
+			DeleteRetentionPolicy: opts.DeleteRetentionPolicy,
+			StaticWebsite:         opts.StaticWebsite,
+		})
+		a.NoError("set service properties", err)
+	})
+}
+
 type BlobFSFileSystemResourceManager struct {
 	internalAccount *AzureAccountResourceManager
 	Service         *BlobFSServiceResourceManager
@@ -512,8 +537,225 @@ func (b *BlobFSPathResourceProvider) Download(a Asserter) io.ReadSeeker {
 	return bytes.NewReader(buf.Bytes())
 }
 
+func (b *BlobFSPathResourceProvider) ContentLength(a Asserter) int64 {
+	resp, err := b.getFileClient().GetProperties(ctx, nil)
+	a.NoError("Get properties", err)
+	return DerefOrZero(resp.ContentLength)
+}
+
+func (b *BlobFSPathResourceProvider) DownloadRange(a Asserter, offset, count int64) []byte {
+	resp, err := b.getFileClient().DownloadStream(ctx, &file.DownloadStreamOptions{Range: &file.HTTPRange{Offset: offset, Count: count}})
+	a.NoError("Download range", err)
+	defer resp.Body.Close()
+
+	buf, err := io.ReadAll(resp.Body)
+	a.NoError("Read body", err)
+	return buf
+}
+
 func (b *BlobFSPathResourceProvider) Exists() bool {
 	_, err := b.getFileClient().GetProperties(ctx, nil) // under the hood it's just a path, no special restype flag.
 
 	return err == nil || !datalakeerror.HasCode(err, datalakeerror.PathNotFound, datalakeerror.FileSystemNotFound, datalakeerror.FileSystemBeingDeleted, datalakeerror.ResourceNotFound)
 }
+
+// BlobFSACLEntry is a single entry of a POSIX access control list, as in "default:user:<object ID>:r-x".
+type BlobFSACLEntry struct {
+	// Default entries aren't checked against; they're inherited by paths created beneath a directory.
+	Default bool
+	// Type is one of user, group, mask or other.
+	Type string
+	// Identity is empty for the owning user and group, the mask, and other.
+	Identity    string
+	Permissions string
+}
+
+func (e BlobFSACLEntry) String() string {
+	out := e.Type + ":" + e.Identity + ":" + e.Permissions
+	if e.Default {
+		out = "default:" + out
+	}
+
+	return out
+}
+
+// ParseBlobFSACL splits an ACL string, as the service returns it, into its entries.
+func ParseBlobFSACL(acl string) ([]BlobFSACLEntry, error) {
+	out := make([]BlobFSACLEntry, 0)
+	if acl == "" {
+		return out, nil
+	}
+
+	for _, v := range strings.Split(acl, ",") {
+		parts := strings.Split(v, ":")
+		entry := BlobFSACLEntry{}
+		if len(parts) == 4 && parts[0] == "default" {
+			entry.Default = true
+			parts = parts[1:]
+		}
+
+		if len(parts) != 3 {
+			return nil, fmt.Errorf("invalid ACL entry %q", v)
+		}
+
+		entry.Type, entry.Identity, entry.Permissions = parts[0], parts[1], parts[2]
+		out = append(out, entry)
+	}
+
+	return out, nil
+}
+
+// FormatBlobFSACL joins entries into an ACL string, as the service accepts it.
+func FormatBlobFSACL(entries []BlobFSACLEntry) string {
+	out := make([]string, len(entries))
+	for k, v := range entries {
+		out[k] = v.String()
+	}
+
+	return strings.Join(out, ",")
+}
+
+// BlobFSAccessControl is the access control of a path, with its ACL split into entries.
+type BlobFSAccessControl struct {
+	Owner       string
+	Group       string
+	Permissions string
+	ACL         []BlobFSACLEntry
+}
+
+// Entry finds the ACL entry for the type and identity (empty for the owning user or group, the mask and other).
+func (c BlobFSAccessControl) Entry(isDefault bool, aclType, identity string) (BlobFSACLEntry, bool) {
+	for _, v := range c.ACL {
+		if v.Default == isDefault && v.Type == aclType && v.Identity == identity {
+			return v, true
+		}
+	}
+
+	return BlobFSACLEntry{}, false
+}
+
+// DefaultACL is the default entries of the ACL, which only directories carry.
+func (c BlobFSAccessControl) DefaultACL() []BlobFSACLEntry {
+	out := make([]BlobFSACLEntry, 0)
+	for _, v := range c.ACL {
+		if v.Default {
+			out = append(out, v)
+		}
+	}
+
+	return out
+}
+
+// BlobFSAccessControlRecursiveResult tallies a recursive ACL change across a directory tree.
+type BlobFSAccessControlRecursiveResult struct {
+	DirectoriesSuccessful int32
+	FilesSuccessful       int32
+	FailureCount          int32
+	FailedEntries         []BlobFSACLFailedEntry
+}
+
+type BlobFSACLFailedEntry struct {
+	Name         string
+	Type         string
+	ErrorMessage string
+}
+
+// SetACL replaces the ACL of the path. The owning user, group and other entries must be present.
+func (b *BlobFSPathResourceProvider) SetACL(a Asserter, acl string) {
+	_, err := b.getFileClient().SetAccessControl(ctx, &file.SetAccessControlOptions{ACL: &acl})
+	a.NoError("Set ACL", err)
+}
+
+// GetACL returns the owner, group, permissions and ACL of the path.
+func (b *BlobFSPathResourceProvider) GetACL(a Asserter) BlobFSAccessControl {
+	resp, err := b.getFileClient().GetAccessControl(ctx, nil)
+	a.NoError("Get access control", err)
+
+	entries, err := ParseBlobFSACL(DerefOrZero(resp.ACL))
+	a.NoError("Parse ACL", err)
+
+	return BlobFSAccessControl{
+		Owner:       DerefOrZero(resp.Owner),
+		Group:       DerefOrZero(resp.Group),
+		Permissions: DerefOrZero(resp.Permissions),
+		ACL:         entries,
+	}
+}
+
+// SetAccessControlRecursive replaces the ACL of the directory and everything beneath it, carrying on past failures so they can be asserted against.
+func (b *BlobFSPathResourceProvider) SetAccessControlRecursive(a Asserter, acl string) BlobFSAccessControlRecursiveResult {
+	a.AssertNow("Recursive access control can only be set on directories", Equal{}, b.entityType, common.EEntityType.Folder())
+
+	resp, err := b.getDirClient().SetAccessControlRecursive(ctx, acl, &directory.SetAccessControlRecursiveOptions{
+		ContinueOnFailure: pointerTo(true),
+	})
+	a.NoError("Set access control recursively", err)
+
+	out := BlobFSAccessControlRecursiveResult{
+		DirectoriesSuccessful: DerefOrZero(resp.DirectoriesSuccessful),
+		FilesSuccessful:       DerefOrZero(resp.FilesSuccessful),
+		FailureCount:          DerefOrZero(resp.FailureCount),
+		FailedEntries:         make([]BlobFSACLFailedEntry, 0, len(resp.FailedEntries)),
+	}
+	for _, v := range resp.FailedEntries {
+		if v == nil {
+			continue
+		}
+
+		out.FailedEntries = append(out.FailedEntries, BlobFSACLFailedEntry{
+			Name:         DerefOrZero(v.Name),
+			Type:         DerefOrZero(v.Type),
+			ErrorMessage: DerefOrZero(v.ErrorMessage),
+		})
+	}
+
+	return out
+}
+
+// CreateDirectoriesWithACL creates each directory with its own ACL, parents before their children.
+// Intermediate directories not named are created by the service with the default ACL of their parent.
+func (b *BlobFSFileSystemResourceManager) CreateDirectoriesWithACL(a Asserter, acls map[string]string) map[string]*BlobFSPathResourceProvider {
+	paths := make([]string, 0, len(acls))
+	for k := range acls {
+		paths = append(paths, k)
+	}
+	sort.Slice(paths, func(i, j int) bool { // fewer segments first, so a parent's default ACL is in place before its children are created
+		return strings.Count(paths[i], "/") < strings.Count(paths[j], "/") || (strings.Count(paths[i], "/") == strings.Count(paths[j], "/") && paths[i] < paths[j])
+	})
+
+	out := make(map[string]*BlobFSPathResourceProvider, len(paths))
+	for _, v := range paths {
+		dir := b.GetObject(a, v, common.EEntityType.Folder()).(*BlobFSPathResourceProvider)
+		dir.Create(a, nil, ObjectProperties{BlobFSProperties: BlobFSProperties{ACL: pointerTo(acls[v])}})
+		out[v] = dir
+	}
+
+	return out
+}
+
+// SetBlobFSACL is BlobFSPathResourceProvider.SetACL, for an object that's a mock during dry runs.
+func SetBlobFSACL(a Asserter, obj ObjectResourceManager, acl string) {
+	if d, isDryrunner := a.(DryrunAsserter); isDryrunner && d.Dryrun() {
+		return
+	}
+
+	GetTypeOrAssert[*BlobFSPathResourceProvider](a, obj).SetACL(a, acl)
+}
+
+// GetBlobFSACL is BlobFSPathResourceProvider.GetACL, for an object that's a mock during dry runs.
+func GetBlobFSACL(a Asserter, obj ObjectResourceManager) BlobFSAccessControl {
+	if d, isDryrunner := a.(DryrunAsserter); isDryrunner && d.Dryrun() {
+		return BlobFSAccessControl{}
+	}
+
+	return GetTypeOrAssert[*BlobFSPathResourceProvider](a, obj).GetACL(a)
+}
+
+// SetBlobFSAccessControlRecursive is BlobFSPathResourceProvider.SetAccessControlRecursive, for an object that's a mock during dry runs.
+func SetBlobFSAccessControlRecursive(a Asserter, obj ObjectResourceManager, acl string) BlobFSAccessControlRecursiveResult {
+	if d, isDryrunner := a.(DryrunAsserter); isDryrunner && d.Dryrun() {
+		return BlobFSAccessControlRecursiveResult{}
+	}
+
+	return GetTypeOrAssert[*BlobFSPathResourceProvider](a, obj).SetAccessControlRecursive(a, acl)
+}
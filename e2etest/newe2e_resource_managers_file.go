@@ -2,7 +2,9 @@ package e2etest
 
 import (
 	"bytes"
+	"context"
 	"fmt"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
 	"github.com/Azure/azure-sdk-for-go/sdk/storage/azfile/directory"
 	"github.com/Azure/azure-sdk-for-go/sdk/storage/azfile/file"
 	"github.com/Azure/azure-sdk-for-go/sdk/storage/azfile/fileerror"
@@ -14,6 +16,7 @@ import (
 	"github.com/Azure/azure-storage-azcopy/v10/sddl"
 	"github.com/Azure/azure-storage-azcopy/v10/ste"
 	"io"
+	"net/http"
 	"path"
 	"runtime"
 )
@@ -126,14 +129,41 @@ func (s *FileServiceResourceManager) IsHierarchical() bool {
 	return true
 }
 
+func (s *FileServiceResourceManager) GetProperties(a Asserter) ServiceProperties {
+	resp, err := s.internalClient.GetProperties(ctx, nil)
+	a.NoError("get service properties", err)
+
+	return fileServicePropertiesFrom(resp)
+}
+
+// SetProperties sets the non-nil properties, and restores what they replaced as the scenario ends. See ServicePropertiesManager.
+// Share soft delete isn't among them; Files only exposes it through ARM.
+func (s *FileServiceResourceManager) SetProperties(a Asserter, props ServiceProperties) ServiceProperties {
+	return setServiceProperties(a, props, s.GetProperties, func(a Asserter, props ServiceProperties) {
+		_, err := s.internalClient.SetProperties(ctx, props.fileSetOptions())
+		a.NoError("set service properties", err)
+	})
+}
+
 // ==================== CONTAINER ====================
 
+const (
+	FileShareProtocolSMB = "SMB"
+	FileShareProtocolNFS = "NFS"
+
+	// fileNFSServiceVersion is the first service version to accept and report POSIX properties over REST.
+	// The SDK pins an older one, so NFS requests carry it explicitly.
+	fileNFSServiceVersion = "2025-05-05"
+)
+
 type FileShareResourceManager struct {
 	internalAccount *AzureAccountResourceManager
 	Service         *FileServiceResourceManager
 
 	containerName  string
 	internalClient *share.Client
+
+	enabledProtocols *string // as created or last read; see IsNFS
 }
 
 func (s *FileShareResourceManager) DefaultAuthType() ExplicitCredentialTypes {
@@ -181,6 +211,7 @@ func (s *FileShareResourceManager) Level() cmd.LocationLevel {
 func (s *FileShareResourceManager) URI(opts ...GetURIOptions) string {
 	base := fileStripSAS(s.internalClient.URL())
 	base = s.internalAccount.ApplySAS(base, s.Location(), opts...)
+	s.refuseSAS(opts...)
 
 	return base
 }
@@ -193,6 +224,8 @@ func (s *FileShareResourceManager) GetProperties(a Asserter) ContainerProperties
 	resp, err := s.internalClient.GetProperties(ctx, nil)
 	a.NoError("get share properties", err)
 
+	s.enabledProtocols = resp.EnabledProtocols
+
 	return ContainerProperties{
 		Metadata: resp.Metadata,
 		FileContainerProperties: FileContainerProperties{
@@ -217,6 +250,11 @@ func (s *FileShareResourceManager) Create(a Asserter, props ContainerProperties)
 type FileShareCreateOptions = share.CreateOptions
 
 func (s *FileShareResourceManager) CreateWithOptions(a Asserter, options *FileShareCreateOptions) {
+	if options != nil && DerefOrZero(options.EnabledProtocols) == FileShareProtocolNFS {
+		accountType := s.internalAccount.AccountType()
+		a.AssertNow("NFS shares need a premium FileStorage account; see GetNFSFileService", Equal{}, accountType == EAccountType.PremiumFileShares() || accountType == EAccountType.PremiumV2FileShares(), true)
+	}
+
 	_, err := s.internalClient.Create(ctx, options)
 
 	created := true
@@ -227,10 +265,58 @@ func (s *FileShareResourceManager) CreateWithOptions(a Asserter, options *FileSh
 
 	a.NoError("Create container", err)
 	if created {
+		s.enabledProtocols = common.Iff(options != nil && options.EnabledProtocols != nil, options.EnabledProtocols, pointerTo(FileShareProtocolSMB))
 		TrackResourceCreation(a, s)
 	}
 }
 
+// CreatePermission stores an SDDL permission on the share, returning the key files and directories can reference it by
+// (see FileProperties.FilePermissionKey). Permissions too long to send inline must be stored this way.
+func (s *FileShareResourceManager) CreatePermission(a Asserter, permission string) string {
+	resp, err := s.internalClient.CreatePermission(ctx, portableSDDL(a, permission), nil)
+	a.NoError("Create share permission", err)
+
+	return DerefOrZero(resp.FilePermissionKey)
+}
+
+// GetPermission returns the SDDL permission stored on the share under key.
+func (s *FileShareResourceManager) GetPermission(a Asserter, key string) string {
+	resp, err := s.internalClient.GetPermission(ctx, key, nil)
+	a.NoError("Get share permission", err)
+
+	return DerefOrZero(resp.Permission)
+}
+
+// portableSDDL parses an SDDL permission into the form the service stores, free of machine-specific SIDs.
+func portableSDDL(a Asserter, permission string) string {
+	fSDDL, err := sddl.ParseSDDL(permission)
+	a.NoError("parse input SDDL", err)
+	a.AssertNow("parsed string equivalence sanity check", Equal{}, fSDDL.String(), permission)
+
+	return fSDDL.PortableString()
+}
+
+// IsNFS reports whether the share speaks NFS rather than SMB, asking the service the first time if it wasn't created here.
+// A share that can't be read is assumed to be SMB.
+func (s *FileShareResourceManager) IsNFS() bool {
+	if s.enabledProtocols == nil {
+		resp, err := s.internalClient.GetProperties(ctx, nil)
+		if err != nil {
+			return false
+		}
+		s.enabledProtocols = pointerTo(DerefOrDefault(resp.EnabledProtocols, FileShareProtocolSMB))
+	}
+
+	return *s.enabledProtocols == FileShareProtocolNFS
+}
+
+// refuseSAS panics on asking for a SAS to an NFS share, or anything within one; the service only takes OAuth for those.
+func (s *FileShareResourceManager) refuseSAS(opts ...GetURIOptions) {
+	if FirstOrZero(opts).AzureOpts.WithSAS && s.IsNFS() {
+		panic(fmt.Sprintf("NFS share %s doesn't accept SAS tokens; address it with OAuth instead (GetResourceOptions{Keyless: true}, or EExplicitCredentialType.OAuth())", s.containerName))
+	}
+}
+
 func (s *FileShareResourceManager) Delete(a Asserter) {
 	s.DeleteWithOptions(a, nil)
 }
@@ -396,6 +482,7 @@ func (f *FileObjectResourceManager) Level() cmd.LocationLevel {
 func (f *FileObjectResourceManager) URI(opts ...GetURIOptions) string {
 	base := fileStripSAS(f.getFileClient().URL()) // restype doesn't matter here, same URL under the hood
 	base = f.internalAccount.ApplySAS(base, f.Location(), opts...)
+	f.Share.refuseSAS(opts...)
 
 	return base
 }
@@ -416,23 +503,26 @@ func (f *FileObjectResourceManager) PreparePermissions(a Asserter, p *string) *f
 	if p == nil {
 		return nil
 	}
-	perm := *p
-
-	fSDDL, err := sddl.ParseSDDL(perm)
-	a.NoError("parse input SDDL", err)
-	a.AssertNow("parsed string equivalence sanity check", Equal{}, fSDDL.String(), perm)
-
-	perm = fSDDL.PortableString()
+	perm := portableSDDL(a, *p)
 
 	if len(perm) >= ste.FilesServiceMaxSDDLSize {
-		resp, err := f.Share.internalClient.CreatePermission(ctx, perm, nil)
-		a.NoError("Create share permission", err)
-		return &file.Permissions{PermissionKey: resp.FilePermissionKey}
+		key := f.Share.CreatePermission(a, perm)
+		return &file.Permissions{PermissionKey: &key}
 	}
 
 	return &file.Permissions{Permission: &perm}
 }
 
+// prepareFilePermissions is PreparePermissions, for a permission given either inline or by key (as from FileShareResourceManager.CreatePermission).
+func (f *FileObjectResourceManager) prepareFilePermissions(a Asserter, props FileProperties) *file.Permissions {
+	if props.FilePermissionKey == nil {
+		return f.PreparePermissions(a, props.FilePermissions)
+	}
+
+	a.AssertNow("a permission may be given inline or by key, not both", Equal{}, props.FilePermissions == nil, true)
+	return &file.Permissions{PermissionKey: props.FilePermissionKey}
+}
+
 func (f *FileObjectResourceManager) Create(a Asserter, body ObjectContentContainer, props ObjectProperties) {
 	var attr *file.NTFSFileAttributes
 	if DerefOrZero(props.FileProperties.FileAttributes) != "" {
@@ -441,12 +531,18 @@ func (f *FileObjectResourceManager) Create(a Asserter, body ObjectContentContain
 		a.NoError("Parse attributes", err)
 	}
 
-	perms := f.PreparePermissions(a, props.FileProperties.FilePermissions)
+	perms := f.prepareFilePermissions(a, props.FileProperties)
+
+	reqCtx := ctx
+	if f.Share.IsNFS() {
+		a.AssertNow("NFS shares have no SMB attributes or permissions", Equal{}, attr == nil && perms == nil, true)
+		reqCtx = nfsRequestContext(props.FileProperties)
+	}
 
 	switch f.entityType {
 	case common.EEntityType.File():
 		client := f.getFileClient()
-		_, err := client.Create(ctx, body.Size(), &file.CreateOptions{
+		_, err := client.Create(reqCtx, body.Size(), &file.CreateOptions{
 			SMBProperties: &file.SMBProperties{
 				Attributes:    attr,
 				CreationTime:  props.FileProperties.FileCreationTime,
@@ -463,7 +559,7 @@ func (f *FileObjectResourceManager) Create(a Asserter, body ObjectContentContain
 		a.NoError("Upload Stream", err)
 	case common.EEntityType.Folder():
 		client := f.getDirClient()
-		_, err := client.Create(ctx, &directory.CreateOptions{
+		_, err := client.Create(reqCtx, &directory.CreateOptions{
 			FileSMBProperties: &file.SMBProperties{
 				Attributes:    attr,
 				CreationTime:  props.FileProperties.FileCreationTime,
@@ -505,9 +601,21 @@ func (f *FileObjectResourceManager) ListChildren(a Asserter, recursive bool) map
 }
 
 func (f *FileObjectResourceManager) GetProperties(a Asserter) (out ObjectProperties) {
+	// The SDK doesn't surface POSIX properties, so they're read off the raw response.
+	var raw *http.Response
+	reqCtx := ctx
+	if f.Share.IsNFS() {
+		reqCtx = policy.WithCaptureResponse(nfsRequestContext(FileProperties{}), &raw)
+		defer func() {
+			if raw != nil {
+				out.FileProperties.FileMode, out.FileProperties.Owner, out.FileProperties.Group = headerPtr(raw.Header, "x-ms-mode"), headerPtr(raw.Header, "x-ms-owner"), headerPtr(raw.Header, "x-ms-group")
+			}
+		}()
+	}
+
 	switch f.entityType {
 	case common.EEntityType.Folder():
-		resp, err := f.getDirClient().GetProperties(ctx, &directory.GetPropertiesOptions{})
+		resp, err := f.getDirClient().GetProperties(reqCtx, &directory.GetPropertiesOptions{})
 		a.NoError("Get directory properties", err)
 
 		var permissions *string
@@ -526,10 +634,11 @@ func (f *FileObjectResourceManager) GetProperties(a Asserter) (out ObjectPropert
 				FileCreationTime:  resp.FileCreationTime,
 				FileLastWriteTime: resp.FileLastWriteTime,
 				FilePermissions:   permissions,
+				FilePermissionKey: resp.FilePermissionKey,
 			},
 		}
 	case common.EEntityType.File():
-		resp, err := f.getFileClient().GetProperties(ctx, &file.GetPropertiesOptions{})
+		resp, err := f.getFileClient().GetProperties(reqCtx, &file.GetPropertiesOptions{})
 		a.NoError("Get file properties", err)
 
 		var permissions *string
@@ -556,6 +665,7 @@ func (f *FileObjectResourceManager) GetProperties(a Asserter) (out ObjectPropert
 				FileCreationTime:  resp.FileCreationTime,
 				FileLastWriteTime: resp.FileLastWriteTime,
 				FilePermissions:   permissions,
+				FilePermissionKey: resp.FilePermissionKey,
 			},
 		}
 	default:
@@ -603,7 +713,7 @@ func (f *FileObjectResourceManager) SetObjectProperties(a Asserter, props Object
 		a.NoError("Parse attributes", err)
 	}
 
-	perms := f.PreparePermissions(a, props.FileProperties.FilePermissions)
+	perms := f.prepareFilePermissions(a, props.FileProperties)
 
 	switch f.entityType {
 	case common.EEntityType.File():
@@ -638,6 +748,26 @@ func (f *FileObjectResourceManager) SetObjectProperties(a Asserter, props Object
 	}
 }
 
+// nfsRequestContext carries the service version that understands POSIX properties, and whichever of props' are set, onto a request.
+func nfsRequestContext(props FileProperties) context.Context {
+	h := http.Header{"x-ms-version": []string{fileNFSServiceVersion}}
+	for k, v := range map[string]*string{"x-ms-mode": props.FileMode, "x-ms-owner": props.Owner, "x-ms-group": props.Group} {
+		if v != nil {
+			h.Set(k, *v)
+		}
+	}
+
+	return policy.WithHTTPHeader(ctx, h)
+}
+
+func headerPtr(h http.Header, key string) *string {
+	if v := h.Get(key); v != "" {
+		return &v
+	}
+
+	return nil
+}
+
 func (f *FileObjectResourceManager) getFileClient() *file.Client {
 	return f.Share.internalClient.NewRootDirectoryClient().NewFileClient(f.path)
 }
@@ -659,6 +789,22 @@ func (f *FileObjectResourceManager) Download(a Asserter) io.ReadSeeker {
 	return bytes.NewReader(buf.Bytes())
 }
 
+func (f *FileObjectResourceManager) ContentLength(a Asserter) int64 {
+	resp, err := f.getFileClient().GetProperties(ctx, nil)
+	a.NoError("Get properties", err)
+	return DerefOrZero(resp.ContentLength)
+}
+
+func (f *FileObjectResourceManager) DownloadRange(a Asserter, offset, count int64) []byte {
+	resp, err := f.getFileClient().DownloadStream(ctx, &file.DownloadStreamOptions{Range: file.HTTPRange{Offset: offset, Count: count}})
+	a.NoError("Download range", err)
+	defer resp.Body.Close()
+
+	buf, err := io.ReadAll(resp.Body)
+	a.NoError("Read body", err)
+	return buf
+}
+
 func (f *FileObjectResourceManager) Exists() bool {
 	var err error
 	if f.entityType != common.EEntityType.Folder() {
@@ -669,3 +815,9 @@ func (f *FileObjectResourceManager) Exists() bool {
 
 	return err == nil || !fileerror.HasCode(err, fileerror.ParentNotFound, fileerror.ShareNotFound, fileerror.ShareBeingDeleted, fileerror.ResourceNotFound)
 }
+
+// GetNFSFileService returns the File service of a premium FileStorage account from the account pool, the only kind that can hold NFS shares.
+// Create shares in it with FileContainerProperties.EnabledProtocols set to FileShareProtocolNFS.
+func GetNFSFileService(a Asserter) ServiceResourceManager {
+	return GetAccountOfType(a, EAccountType.PremiumFileShares()).GetService(a, common.ELocation.File())
+}
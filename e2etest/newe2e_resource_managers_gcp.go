@@ -0,0 +1,525 @@
+package e2etest
+
+import (
+	"bytes"
+	"cloud.google.com/go/storage"
+	"errors"
+	"fmt"
+	"github.com/Azure/azure-storage-azcopy/v10/cmd"
+	"github.com/Azure/azure-storage-azcopy/v10/common"
+	"google.golang.org/api/googleapi"
+	"google.golang.org/api/iterator"
+	"google.golang.org/api/option"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+/*
+TODOs:
+- Folders & symlinks (GCS has neither; AzCopy treats them as plain objects)
+- Storage classes
+*/
+
+// enforce interface compliance at compile time
+func init() {
+	void := func(_ ...any) {} // prevent go from erroring from unused vars
+
+	void(
+		AccountResourceManager(&GCPAccountResourceManager{}),
+		ServiceResourceManager(&GCPServiceResourceManager{}),
+		ContainerResourceManager(&GCPBucketResourceManager{}),
+		ObjectResourceManager(&GCPObjectResourceManager{}),
+
+		RemoteResourceManager(&GCPServiceResourceManager{}),
+		RemoteResourceManager(&GCPBucketResourceManager{}),
+		RemoteResourceManager(&GCPObjectResourceManager{}),
+	)
+}
+
+// gcpEndpoint is the host AzCopy recognizes GCS URLs by.
+const gcpEndpoint = "storage.cloud.google.com"
+
+// ==================== ACCOUNT ====================
+
+// GCPAccountResourceManager stands in for an "account" on GCS, which is really a project and the service account key used to reach it.
+type GCPAccountResourceManager struct {
+	projectID string
+
+	internalClient *storage.Client
+}
+
+func NewGCPAccountResourceManager(projectID string, opts ...option.ClientOption) (*GCPAccountResourceManager, error) {
+	client, err := storage.NewClient(ctx, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create GCS client: %w", err)
+	}
+
+	return &GCPAccountResourceManager{
+		projectID:      projectID,
+		internalClient: client,
+	}, nil
+}
+
+func (g *GCPAccountResourceManager) AccountName() string {
+	return "gcp"
+}
+
+func (g *GCPAccountResourceManager) AccountType() AccountType {
+	return EAccountType.GCP()
+}
+
+func (g *GCPAccountResourceManager) AvailableServices() []common.Location {
+	return []common.Location{common.ELocation.GCP()}
+}
+
+func (g *GCPAccountResourceManager) GetService(a Asserter, location common.Location) ServiceResourceManager {
+	a.AssertNow(fmt.Sprintf("\"%s\" is not a valid service for account type %s", location, g.AccountType()), Equal{}, location, common.ELocation.GCP())
+
+	return &GCPServiceResourceManager{internalAccount: g}
+}
+
+// buildURI builds the bucket/object URI AzCopy expects for GCS. AzCopy reads credentials from GOOGLE_APPLICATION_CREDENTIALS, so there's nothing to sign.
+func (g *GCPAccountResourceManager) buildURI(bucket, object string, options ...GetURIOptions) string {
+	opts := FirstOrZero(options)
+
+	out := url.URL{
+		Scheme: common.Iff(opts.RemoteOpts.Scheme != "", opts.RemoteOpts.Scheme, "https"),
+		Host:   gcpEndpoint,
+		Path:   "/" + bucket,
+	}
+	if object != "" {
+		out.Path += "/" + object
+	}
+
+	return out.String()
+}
+
+// ==================== SERVICE ====================
+
+type GCPServiceResourceManager struct {
+	internalAccount *GCPAccountResourceManager
+}
+
+func (g *GCPServiceResourceManager) ValidAuthTypes() ExplicitCredentialTypes {
+	return EExplicitCredentialType.With(EExplicitCredentialType.PublicAuth(), EExplicitCredentialType.GCP())
+}
+
+func (g *GCPServiceResourceManager) DefaultAuthType() ExplicitCredentialTypes {
+	return EExplicitCredentialType.GCP()
+}
+
+func (g *GCPServiceResourceManager) WithSpecificAuthType(cred ExplicitCredentialTypes, a Asserter, opts ...CreateAzCopyTargetOptions) AzCopyTarget {
+	return CreateAzCopyTarget(g, cred, a, opts...)
+}
+
+func (g *GCPServiceResourceManager) ResourceClient() any {
+	return g.internalAccount.internalClient
+}
+
+func (g *GCPServiceResourceManager) Location() common.Location {
+	return common.ELocation.GCP()
+}
+
+func (g *GCPServiceResourceManager) Level() cmd.LocationLevel {
+	return cmd.ELocationLevel.Service()
+}
+
+func (g *GCPServiceResourceManager) URI(opts ...GetURIOptions) string {
+	return g.internalAccount.buildURI("", "", opts...)
+}
+
+func (g *GCPServiceResourceManager) Parent() ResourceManager {
+	return nil
+}
+
+func (g *GCPServiceResourceManager) Account() AccountResourceManager {
+	return g.internalAccount
+}
+
+func (g *GCPServiceResourceManager) Canon() string {
+	return fmt.Sprintf("%s/%s", g.internalAccount.AccountName(), g.Location())
+}
+
+func (g *GCPServiceResourceManager) ListContainers(a Asserter) []string {
+	it := g.internalAccount.internalClient.Buckets(ctx, g.internalAccount.projectID)
+	out := make([]string, 0)
+
+	for {
+		attrs, err := it.Next()
+		if err == iterator.Done {
+			break
+		}
+		a.NoError("list buckets", err)
+
+		out = append(out, attrs.Name)
+	}
+
+	return out
+}
+
+func (g *GCPServiceResourceManager) GetContainer(name string) ContainerResourceManager {
+	return &GCPBucketResourceManager{
+		internalAccount: g.internalAccount,
+		Service:         g,
+		bucketName:      name,
+		internalClient:  g.internalAccount.internalClient.Bucket(name),
+	}
+}
+
+func (g *GCPServiceResourceManager) IsHierarchical() bool {
+	return false
+}
+
+// ==================== BUCKET ====================
+
+type GCPBucketResourceManager struct {
+	internalAccount *GCPAccountResourceManager
+	Service         *GCPServiceResourceManager
+	bucketName      string
+	internalClient  *storage.BucketHandle
+}
+
+func (g *GCPBucketResourceManager) ValidAuthTypes() ExplicitCredentialTypes {
+	return (&GCPServiceResourceManager{}).ValidAuthTypes()
+}
+
+func (g *GCPBucketResourceManager) DefaultAuthType() ExplicitCredentialTypes {
+	return (&GCPServiceResourceManager{}).DefaultAuthType()
+}
+
+func (g *GCPBucketResourceManager) WithSpecificAuthType(cred ExplicitCredentialTypes, a Asserter, opts ...CreateAzCopyTargetOptions) AzCopyTarget {
+	return CreateAzCopyTarget(g, cred, a, opts...)
+}
+
+func (g *GCPBucketResourceManager) ResourceClient() any {
+	return g.internalClient
+}
+
+func (g *GCPBucketResourceManager) Location() common.Location {
+	return g.Service.Location()
+}
+
+func (g *GCPBucketResourceManager) Level() cmd.LocationLevel {
+	return cmd.ELocationLevel.Container()
+}
+
+func (g *GCPBucketResourceManager) URI(opts ...GetURIOptions) string {
+	return g.internalAccount.buildURI(g.bucketName, "", opts...)
+}
+
+func (g *GCPBucketResourceManager) Parent() ResourceManager {
+	return g.Service
+}
+
+func (g *GCPBucketResourceManager) Account() AccountResourceManager {
+	return g.internalAccount
+}
+
+func (g *GCPBucketResourceManager) Canon() string {
+	return g.Service.Canon() + "/" + g.bucketName
+}
+
+func (g *GCPBucketResourceManager) ContainerName() string {
+	return g.bucketName
+}
+
+func (g *GCPBucketResourceManager) Create(a Asserter, props ContainerProperties) {
+	err := g.internalClient.Create(ctx, g.internalAccount.projectID, nil)
+
+	created := true
+	var gErr *googleapi.Error
+	if errors.As(err, &gErr) && gErr.Code == http.StatusConflict {
+		created = false
+		err = nil
+	}
+
+	a.NoError("create bucket", err)
+	if created {
+		TrackResourceCreation(a, g)
+	}
+}
+
+// GetProperties returns empty properties; bucket labels aren't something AzCopy transfers.
+func (g *GCPBucketResourceManager) GetProperties(a Asserter) ContainerProperties {
+	return ContainerProperties{}
+}
+
+func (g *GCPBucketResourceManager) Delete(a Asserter) {
+	// GCS refuses to delete buckets that aren't empty.
+	it := g.internalClient.Objects(ctx, nil)
+	for {
+		attrs, err := it.Next()
+		if err == iterator.Done || errors.Is(err, storage.ErrBucketNotExist) {
+			break
+		}
+		a.NoError("list objects", err)
+
+		err = g.internalClient.Object(attrs.Name).Delete(ctx)
+		if !errors.Is(err, storage.ErrObjectNotExist) {
+			a.NoError("delete object "+attrs.Name, err)
+		}
+	}
+
+	err := g.internalClient.Delete(ctx)
+	if errors.Is(err, storage.ErrBucketNotExist) {
+		err = nil
+	}
+
+	a.NoError("delete bucket", err)
+}
+
+func (g *GCPBucketResourceManager) ListObjects(a Asserter, prefix string, recursive bool) map[string]ObjectProperties {
+	out := make(map[string]ObjectProperties)
+
+	query := &storage.Query{Prefix: prefix}
+	if !recursive {
+		query.Delimiter = "/"
+	}
+
+	it := g.internalClient.Objects(ctx, query)
+	for {
+		attrs, err := it.Next()
+		if err == iterator.Done {
+			break
+		}
+		a.NoError("list objects", err)
+
+		if attrs.Prefix != "" {
+			continue // synthetic directory, not an object
+		}
+
+		out[attrs.Name] = gcpObjectProperties(attrs)
+	}
+
+	return out
+}
+
+func (g *GCPBucketResourceManager) GetObject(a Asserter, path string, eType common.EntityType) ObjectResourceManager {
+	return &GCPObjectResourceManager{
+		internalAccount: g.internalAccount,
+		Service:         g.Service,
+		Bucket:          g,
+		Path:            path,
+		entityType:      eType,
+		internalClient:  g.internalClient.Object(path),
+	}
+}
+
+func (g *GCPBucketResourceManager) Exists() bool {
+	_, err := g.internalClient.Attrs(ctx)
+
+	return err == nil || !errors.Is(err, storage.ErrBucketNotExist)
+}
+
+// ==================== OBJECT ====================
+
+type GCPObjectResourceManager struct {
+	internalAccount *GCPAccountResourceManager
+	Service         *GCPServiceResourceManager
+	Bucket          *GCPBucketResourceManager
+	Path            string
+	entityType      common.EntityType
+
+	internalClient *storage.ObjectHandle
+}
+
+func (g *GCPObjectResourceManager) ValidAuthTypes() ExplicitCredentialTypes {
+	return (&GCPServiceResourceManager{}).ValidAuthTypes()
+}
+
+func (g *GCPObjectResourceManager) DefaultAuthType() ExplicitCredentialTypes {
+	return (&GCPServiceResourceManager{}).DefaultAuthType()
+}
+
+func (g *GCPObjectResourceManager) WithSpecificAuthType(cred ExplicitCredentialTypes, a Asserter, opts ...CreateAzCopyTargetOptions) AzCopyTarget {
+	return CreateAzCopyTarget(g, cred, a, opts...)
+}
+
+func (g *GCPObjectResourceManager) ResourceClient() any {
+	return g.internalClient
+}
+
+func (g *GCPObjectResourceManager) Location() common.Location {
+	return g.Service.Location()
+}
+
+func (g *GCPObjectResourceManager) Level() cmd.LocationLevel {
+	return cmd.ELocationLevel.Object()
+}
+
+func (g *GCPObjectResourceManager) URI(opts ...GetURIOptions) string {
+	return g.internalAccount.buildURI(g.Bucket.bucketName, g.Path, opts...)
+}
+
+func (g *GCPObjectResourceManager) Parent() ResourceManager {
+	return g.Bucket
+}
+
+func (g *GCPObjectResourceManager) Account() AccountResourceManager {
+	return g.internalAccount
+}
+
+func (g *GCPObjectResourceManager) Canon() string {
+	return g.Bucket.Canon() + "/" + g.Path
+}
+
+func (g *GCPObjectResourceManager) EntityType() common.EntityType {
+	return g.entityType
+}
+
+func (g *GCPObjectResourceManager) ContainerName() string {
+	return g.Bucket.ContainerName()
+}
+
+func (g *GCPObjectResourceManager) ObjectName() string {
+	return g.Path
+}
+
+func (g *GCPObjectResourceManager) Create(a Asserter, body ObjectContentContainer, properties ObjectProperties) {
+	a.AssertNow("GCS only supports file objects", Equal{}, g.entityType, common.EEntityType.File())
+
+	w := g.internalClient.NewWriter(ctx)
+	w.ContentType = DerefOrZero(properties.HTTPHeaders.contentType)
+	w.ContentEncoding = DerefOrZero(properties.HTTPHeaders.contentEncoding)
+	w.ContentDisposition = DerefOrZero(properties.HTTPHeaders.contentDisposition)
+	w.ContentLanguage = DerefOrZero(properties.HTTPHeaders.contentLanguage)
+	w.CacheControl = DerefOrZero(properties.HTTPHeaders.cacheControl)
+	w.Metadata = gcpMetadata(properties.Metadata)
+
+	_, err := io.Copy(w, body.Reader())
+	a.NoError("upload object", err)
+	// The upload isn't committed until the writer closes.
+	a.NoError("commit object", w.Close())
+
+	TrackResourceCreation(a, g)
+}
+
+func (g *GCPObjectResourceManager) Delete(a Asserter) {
+	err := g.internalClient.Delete(ctx)
+
+	if errors.Is(err, storage.ErrObjectNotExist) || errors.Is(err, storage.ErrBucketNotExist) {
+		err = nil
+	}
+
+	a.NoError("delete object", err)
+}
+
+func (g *GCPObjectResourceManager) ListChildren(a Asserter, recursive bool) map[string]ObjectProperties {
+	return g.Bucket.ListObjects(a, g.Path, recursive)
+}
+
+func (g *GCPObjectResourceManager) GetProperties(a Asserter) ObjectProperties {
+	attrs, err := g.internalClient.Attrs(ctx)
+	a.NoError("get object attributes", err)
+
+	return gcpObjectProperties(attrs)
+}
+
+func (g *GCPObjectResourceManager) SetHTTPHeaders(a Asserter, h contentHeaders) {
+	_, err := g.internalClient.Update(ctx, storage.ObjectAttrsToUpdate{
+		ContentType:        DerefOrZero(h.contentType),
+		ContentEncoding:    DerefOrZero(h.contentEncoding),
+		ContentDisposition: DerefOrZero(h.contentDisposition),
+		ContentLanguage:    DerefOrZero(h.contentLanguage),
+		CacheControl:       DerefOrZero(h.cacheControl),
+	})
+	a.NoError("set HTTP headers", err)
+}
+
+func (g *GCPObjectResourceManager) SetMetadata(a Asserter, metadata common.Metadata) {
+	// An empty (rather than nil) map is what tells GCS to clear metadata.
+	_, err := g.internalClient.Update(ctx, storage.ObjectAttrsToUpdate{Metadata: gcpMetadata(metadata)})
+	a.NoError("set metadata", err)
+}
+
+func (g *GCPObjectResourceManager) SetObjectProperties(a Asserter, props ObjectProperties) {
+	g.SetHTTPHeaders(a, props.HTTPHeaders)
+	g.SetMetadata(a, props.Metadata)
+}
+
+func (g *GCPObjectResourceManager) Download(a Asserter) io.ReadSeeker {
+	r, err := g.internalClient.NewReader(ctx)
+	a.NoError("open object reader", err)
+	defer r.Close()
+
+	buf := &bytes.Buffer{}
+	_, err = io.Copy(buf, r)
+	a.NoError("read body", err)
+
+	return bytes.NewReader(buf.Bytes())
+}
+
+func (g *GCPObjectResourceManager) Exists() bool {
+	_, err := g.internalClient.Attrs(ctx)
+
+	return err == nil || !(errors.Is(err, storage.ErrObjectNotExist) || errors.Is(err, storage.ErrBucketNotExist))
+}
+
+// ==================== HELPERS ====================
+
+func gcpMetadata(metadata common.Metadata) map[string]string {
+	out := make(map[string]string)
+	for k, v := range metadata {
+		if v != nil {
+			out[k] = *v
+		}
+	}
+
+	return out
+}
+
+func gcpObjectProperties(attrs *storage.ObjectAttrs) ObjectProperties {
+	stringOrNil := func(s string) *string {
+		return common.Iff(s != "", &s, nil)
+	}
+
+	metadata := make(common.Metadata)
+	for k, v := range attrs.Metadata {
+		metadata[k] = pointerTo(v)
+	}
+
+	return ObjectProperties{
+		EntityType: common.EEntityType.File(),
+		HTTPHeaders: contentHeaders{
+			cacheControl:       stringOrNil(attrs.CacheControl),
+			contentDisposition: stringOrNil(attrs.ContentDisposition),
+			contentEncoding:    stringOrNil(attrs.ContentEncoding),
+			contentLanguage:    stringOrNil(attrs.ContentLanguage),
+			contentType:        stringOrNil(attrs.ContentType),
+			contentMD5:         attrs.MD5,
+		},
+		Metadata: metadata,
+	}
+}
+
+// expectedMetadataFromGCP predicts the metadata AzCopy lands on an Azure object copied from GCS.
+// Under the default handling, keys that aren't valid Azure metadata names are dropped.
+// Azure doesn't preserve the case of metadata keys, so keys are lowercased for comparison.
+func expectedMetadataFromGCP(source common.Metadata) common.Metadata {
+	retained, _, _ := source.ExcludeInvalidKey()
+
+	return lowercaseMetadataKeys(retained)
+}
+
+func lowercaseMetadataKeys(metadata common.Metadata) common.Metadata {
+	out := make(common.Metadata)
+	for k, v := range metadata {
+		out[strings.ToLower(k)] = v
+	}
+
+	return out
+}
+
+// ValidateMetadataCopiedFromGCP compares a GCS object's metadata against the Azure object AzCopy copied it to.
+func ValidateMetadataCopiedFromGCP(a Asserter, source *GCPObjectResourceManager, destination ObjectResourceManager) {
+	if dryrunner, ok := a.(DryrunAsserter); ok && dryrunner.Dryrun() {
+		return
+	}
+
+	expected := expectedMetadataFromGCP(source.GetProperties(a).Metadata)
+	real := lowercaseMetadataKeys(destination.GetProperties(a).Metadata)
+
+	ValidateMetadata(a, expected, real)
+}
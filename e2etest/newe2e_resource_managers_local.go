@@ -9,9 +9,11 @@ import (
 	"github.com/google/uuid"
 	"io"
 	"io/fs"
+	"net/url"
 	"os"
 	"path"
 	"path/filepath"
+	"strings"
 	"time"
 )
 
@@ -20,11 +22,37 @@ func init() {
 	void := func(_ ...any) {}
 
 	void(
+		ServiceResourceManager(&LocalServiceResourceManager{}),
 		ContainerResourceManager(&LocalContainerResourceManager{}),
 		ObjectResourceManager(&LocalObjectResourceManager{}),
 	)
 }
 
+// NewLocalService creates a fresh temp directory to act as the root of the local "service".
+// Containers are directories within it, and the whole tree is removed when the scenario ends.
+func NewLocalService(a Asserter) ServiceResourceManager {
+	if d, ok := a.(DryrunAsserter); ok && d.Dryrun() {
+		return &MockServiceResourceManager{
+			parent:      &MockAccountResourceManager{accountName: "accountless"},
+			serviceType: common.ELocation.Local(),
+		}
+	}
+
+	root, err := os.MkdirTemp("", "azcopy-e2e-local-*")
+	a.NoError("Create local service root directory", err)
+
+	if sa, ok := a.(ScenarioAsserter); ok {
+		sa.Cleanup(func(a ScenarioAsserter) {
+			err := os.RemoveAll(root)
+			a.NoError("Delete local service root directory", err)
+		})
+	}
+
+	return &LocalServiceResourceManager{
+		RootPath: root,
+	}
+}
+
 func NewLocalContainer(a Asserter) ContainerResourceManager {
 	if d, ok := a.(DryrunAsserter); ok && d.Dryrun() {
 		return &MockContainerResourceManager{
@@ -41,9 +69,109 @@ func NewLocalContainer(a Asserter) ContainerResourceManager {
 	}
 }
 
+// localURI renders a local path either plainly, as AzCopy expects it, or as a file:// URI.
+func localURI(localPath string, opts ...GetURIOptions) string {
+	if !FirstOrZero(opts).LocalOpts.AsFileURI {
+		return localPath
+	}
+
+	uriPath := filepath.ToSlash(localPath)
+	if !strings.HasPrefix(uriPath, "/") { // e.g. C:/foo on Windows
+		uriPath = "/" + uriPath
+	}
+
+	return (&url.URL{Scheme: "file", Path: uriPath}).String()
+}
+
+// CreateLocalSparseFile creates a sparse file within a local container.
+// Its content validates against NewZeroObjectContentContainer(size).
+func CreateLocalSparseFile(a Asserter, container ContainerResourceManager, path string, size int64, props ...ObjectProperties) ObjectResourceManager {
+	obj := container.GetObject(a, path, common.EEntityType.File())
+	if d, ok := a.(DryrunAsserter); ok && d.Dryrun() {
+		return obj
+	}
+
+	GetTypeOrAssert[*LocalObjectResourceManager](a, obj).CreateSparse(a, size, FirstOrZero(props))
+
+	return obj
+}
+
+// LocalServiceResourceManager is a temp folder whose subdirectories act as containers.
+type LocalServiceResourceManager struct {
+	RootPath string
+}
+
+func (l *LocalServiceResourceManager) ValidAuthTypes() ExplicitCredentialTypes {
+	return EExplicitCredentialType.None()
+}
+
+func (l *LocalServiceResourceManager) DefaultAuthType() ExplicitCredentialTypes {
+	return EExplicitCredentialType.None()
+}
+
+func (l *LocalServiceResourceManager) WithSpecificAuthType(cred ExplicitCredentialTypes, a Asserter, opts ...CreateAzCopyTargetOptions) AzCopyTarget {
+	return CreateAzCopyTarget(l, cred, a, opts...)
+}
+
+func (l *LocalServiceResourceManager) ResourceClient() any {
+	return nil
+}
+
+func (l *LocalServiceResourceManager) Location() common.Location {
+	return common.ELocation.Local()
+}
+
+func (l *LocalServiceResourceManager) Level() cmd.LocationLevel {
+	return cmd.ELocationLevel.Service()
+}
+
+func (l *LocalServiceResourceManager) URI(opts ...GetURIOptions) string {
+	return localURI(l.RootPath, opts...)
+}
+
+func (l *LocalServiceResourceManager) Parent() ResourceManager {
+	return nil
+}
+
+func (l *LocalServiceResourceManager) Account() AccountResourceManager {
+	return nil
+}
+
+func (l *LocalServiceResourceManager) Canon() string {
+	return "accountless/local"
+}
+
+func (l *LocalServiceResourceManager) ListContainers(a Asserter) []string {
+	entries, err := os.ReadDir(l.RootPath)
+	a.NoError("List local service root directory", err)
+
+	out := make([]string, 0, len(entries))
+	for _, v := range entries {
+		if v.IsDir() {
+			out = append(out, v.Name())
+		}
+	}
+
+	return out
+}
+
+func (l *LocalServiceResourceManager) GetContainer(name string) ContainerResourceManager {
+	return &LocalContainerResourceManager{
+		RootPath: filepath.Join(l.RootPath, name),
+		service:  l,
+	}
+}
+
+func (l *LocalServiceResourceManager) IsHierarchical() bool {
+	return true
+}
+
 // LocalContainerResourceManager is effectively just the root temp folder for a transfer.
 type LocalContainerResourceManager struct {
 	RootPath string
+
+	// service is nil when the container was created standalone via NewLocalContainer.
+	service *LocalServiceResourceManager
 }
 
 func (l *LocalContainerResourceManager) Location() common.Location {
@@ -55,11 +183,15 @@ func (l *LocalContainerResourceManager) Level() cmd.LocationLevel {
 }
 
 func (l *LocalContainerResourceManager) URI(opts ...GetURIOptions) string {
-	return l.RootPath
+	return localURI(l.RootPath, opts...)
 }
 
 func (l *LocalContainerResourceManager) Parent() ResourceManager {
-	return nil
+	if l.service == nil {
+		return nil
+	}
+
+	return l.service
 }
 
 func (l *LocalContainerResourceManager) Account() AccountResourceManager {
@@ -217,7 +349,7 @@ func (l *LocalObjectResourceManager) Level() cmd.LocationLevel {
 }
 
 func (l *LocalObjectResourceManager) URI(opts ...GetURIOptions) string {
-	return filepath.Join(l.container.RootPath, l.objectPath)
+	return localURI(filepath.FromSlash(l.getWorkingPath()), opts...)
 }
 
 func (l *LocalObjectResourceManager) Parent() ResourceManager {
@@ -241,7 +373,19 @@ func (l *LocalObjectResourceManager) EntityType() common.EntityType {
 }
 
 func (l *LocalObjectResourceManager) Create(a Asserter, body ObjectContentContainer, properties ObjectProperties) {
-	a.AssertNow("Object must be file to have content", Equal{})
+	if l.entityType == common.EEntityType.Folder() {
+		err := os.MkdirAll(l.getWorkingPath(), 0777)
+		a.NoError("Create folder", err)
+
+		l.SetObjectProperties(a, properties)
+		TrackResourceCreation(a, l)
+		return
+	}
+
+	a.AssertNow("Object must be file to have content", Equal{}, l.entityType, common.EEntityType.File())
+
+	err := os.MkdirAll(filepath.Dir(l.getWorkingPath()), 0777)
+	a.NoError("Create parent folders", err)
 
 	f, err := os.OpenFile(l.getWorkingPath(), os.O_CREATE|os.O_TRUNC|os.O_RDWR, 0774)
 	a.NoError("Open file", err)
@@ -250,8 +394,45 @@ func (l *LocalObjectResourceManager) Create(a Asserter, body ObjectContentContai
 		a.NoError("Close file", err)
 	}(f)
 
-	_, err = io.Copy(f, body.Reader())
-	a.NoError("Write file", err)
+	if sparse, ok := body.(SparseObjectContentContainer); ok {
+		// Only the data is written, so filesystems that support it leave the rest as holes.
+		err = f.Truncate(sparse.Size())
+		a.NoError("Extend file", err)
+
+		reader := sparse.Reader()
+		for _, r := range sparse.DataRanges() {
+			_, err = reader.Seek(r.Offset, io.SeekStart)
+			a.NoError("Seek content", err)
+			_, err = f.Seek(r.Offset, io.SeekStart)
+			a.NoError("Seek file", err)
+			_, err = io.Copy(f, io.LimitReader(reader, r.Count))
+			a.NoError("Write file", err)
+		}
+	} else {
+		_, err = io.Copy(f, body.Reader())
+		a.NoError("Write file", err)
+	}
+
+	l.SetObjectProperties(a, properties)
+
+	TrackResourceCreation(a, l)
+}
+
+// CreateSparse creates a file of the requested size without writing any of its content.
+// Filesystems that support it leave the file as a single hole, which reads back as zeroes.
+func (l *LocalObjectResourceManager) CreateSparse(a Asserter, size int64, properties ObjectProperties) {
+	a.AssertNow("Object must be file to be sparse", Equal{}, l.entityType, common.EEntityType.File())
+
+	err := os.MkdirAll(filepath.Dir(l.getWorkingPath()), 0777)
+	a.NoError("Create parent folders", err)
+
+	f, err := os.OpenFile(l.getWorkingPath(), os.O_CREATE|os.O_TRUNC|os.O_RDWR, 0774)
+	a.NoError("Open file", err)
+
+	err = f.Truncate(size)
+	a.NoError("Extend file", err)
+	err = f.Close() // Close before setting properties, so the write time sticks.
+	a.NoError("Close file", err)
 
 	l.SetObjectProperties(a, properties)
 
@@ -285,7 +466,7 @@ func (l *LocalObjectResourceManager) ListChildren(a Asserter, recursive bool) ma
 }
 
 func (l *LocalObjectResourceManager) GetProperties(a Asserter) ObjectProperties {
-	out := ObjectProperties{}
+	out := ObjectProperties{EntityType: l.entityType}
 
 	// OS-triggered code, implemented in newe2e_resource_managers_local_windows.go
 	if smb, ok := any(l).(localSMBPropertiesManager); ok {
@@ -302,6 +483,8 @@ func (l *LocalObjectResourceManager) GetProperties(a Asserter) ObjectProperties
 			FileLastWriteTime: PtrOf(props.FileLastWriteTime()),
 			FilePermissions:   common.Iff(perms == "", nil, &perms),
 		}
+	} else if fi, err := os.Stat(l.getWorkingPath()); err == nil {
+		out.FileProperties.FileLastWriteTime = PtrOf(fi.ModTime())
 	}
 
 	return out
@@ -323,6 +506,11 @@ func (l *LocalObjectResourceManager) SetObjectProperties(a Asserter, props Objec
 			smb.PutSDDL(*props.FileProperties.FilePermissions, a)
 		}
 	}
+
+	if lwt := props.FileProperties.FileLastWriteTime; lwt != nil {
+		err := os.Chtimes(l.getWorkingPath(), *lwt, *lwt)
+		a.NoError("Set last write time", err)
+	}
 }
 
 func (l *LocalObjectResourceManager) Download(a Asserter) io.ReadSeeker {
@@ -342,6 +530,27 @@ func (l *LocalObjectResourceManager) Download(a Asserter) io.ReadSeeker {
 	return bytes.NewReader(buf.Bytes())
 }
 
+func (l *LocalObjectResourceManager) ContentLength(a Asserter) int64 {
+	fi, err := os.Stat(l.getWorkingPath())
+	a.NoError("stat file", err)
+	return fi.Size()
+}
+
+func (l *LocalObjectResourceManager) DownloadRange(a Asserter, offset, count int64) []byte {
+	f, err := os.Open(l.getWorkingPath())
+	a.NoError("open file", err)
+	defer f.Close()
+
+	buf := make([]byte, count)
+	n, err := f.ReadAt(buf, offset)
+	if err == io.EOF {
+		err = nil
+	}
+	a.NoError("read file", err)
+
+	return buf[:n]
+}
+
 func (l *LocalObjectResourceManager) Exists() bool {
 	_, err := os.Stat(l.getWorkingPath())
 	return err == nil
@@ -0,0 +1,298 @@
+package e2etest
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/blob"
+	"github.com/Azure/azure-storage-azcopy/v10/cmd"
+	"github.com/Azure/azure-storage-azcopy/v10/common"
+	"github.com/google/uuid"
+	"io"
+	"strings"
+	"time"
+)
+
+type CreateManagedDiskOptions struct {
+	// ParentResourceGroup overrides CommonARMResourceGroup as a default.
+	ParentResourceGroup *ARMResourceGroup
+	// CustomName will be suffixed with the last section of a UUID
+	CustomName *string
+	// Location defaults to West US 2.
+	Location *string
+
+	// UploadSizeBytes is the exact size of the VHD to be uploaded, footer included.
+	// Azure requires a multiple of 1MiB plus the 512 byte footer, of at least 20MiB (+512).
+	UploadSizeBytes int64
+}
+
+// CreateManagedDisk creates an empty disk, ready for a VHD to be uploaded to it via GrantAccess(ARMManagedDiskAccessLevelWrite).
+// Disks come from ARM, so they can't be created against static resources or the emulator.
+func CreateManagedDisk(a Asserter, opts CreateManagedDiskOptions) *ManagedDiskResourceManager {
+	if GlobalConfig.StaticResources() {
+		a.Skip("Creating a managed disk requires ARM, which needs NEW_E2E_SUBSCRIPTION_ID")
+	}
+
+	uuidSegments := strings.Split(uuid.NewString(), "-")
+	out := &ManagedDiskResourceManager{
+		armClient: &ARMManagedDisk{
+			ARMResourceGroup: common.Iff(opts.ParentResourceGroup != nil, opts.ParentResourceGroup, CommonARMResourceGroup),
+			DiskName:         DerefOrDefault(opts.CustomName, "azcopy-newe2e-disk-") + uuidSegments[len(uuidSegments)-1],
+		},
+	}
+
+	if d, isDryrunner := a.(DryrunAsserter); (isDryrunner && d.Dryrun()) || GlobalConfig.StaticResources() {
+		return out
+	}
+
+	_, err := out.armClient.CreateOrUpdate(ARMManagedDiskCreateOrUpdateParams{
+		Location: DerefOrDefault(opts.Location, "West US 2"),
+		Sku:      &ARMManagedDiskSkuStandardLrs,
+		Properties: ARMManagedDiskPutProperties{
+			CreationData: ARMManagedDiskCreationData{
+				CreateOption:    pointerTo(ARMManagedDiskCreateOptionUpload),
+				UploadSizeBytes: pointerTo(uint64(opts.UploadSizeBytes)),
+			},
+		},
+	})
+	a.NoError("ARM create managed disk call", err)
+
+	TrackResourceCreation(a, out)
+
+	return out
+}
+
+// ManagedDiskResourceManager is a managed disk, as seen through the page blob SAS that ARM grants access to it with.
+// It has no account or container; URI is only valid between GrantAccess and RevokeAccess.
+type ManagedDiskResourceManager struct {
+	armClient *ARMManagedDisk
+	accessURI string
+}
+
+func (md *ManagedDiskResourceManager) Location() common.Location {
+	return common.ELocation.Blob()
+}
+
+func (md *ManagedDiskResourceManager) Level() cmd.LocationLevel {
+	return cmd.ELocationLevel.Object()
+}
+
+// URI returns the granted access SAS (for both import and export); it already carries its own auth, so opts are ignored.
+func (md *ManagedDiskResourceManager) URI(opts ...GetURIOptions) string {
+	if md.accessURI == "" {
+		panic(fmt.Sprintf("managed disk %s has no access granted; call GrantAccess first", md.armClient.DiskName))
+	}
+
+	return md.accessURI
+}
+
+func (md *ManagedDiskResourceManager) Parent() ResourceManager {
+	return nil
+}
+
+func (md *ManagedDiskResourceManager) Account() AccountResourceManager {
+	return nil
+}
+
+func (md *ManagedDiskResourceManager) Canon() string {
+	return "accountless/ManagedDisk/" + md.armClient.DiskName
+}
+
+func (md *ManagedDiskResourceManager) ManagementClient() *ARMManagedDisk {
+	return md.armClient
+}
+
+// GrantAccess requests a SAS for the disk (Write to import, Read to export), valid for an hour, and exposes it via URI.
+// A disk holds one grant at a time; moving from Write to Read needs a RevokeAccess in between.
+func (md *ManagedDiskResourceManager) GrantAccess(a Asserter, accessLevel string) {
+	if d, isDryrunner := a.(DryrunAsserter); isDryrunner && d.Dryrun() {
+		md.accessURI = "https://md-mock.z0.blob.storage.azure.net/mock/abcd?sv=mock"
+		return
+	}
+
+	access, err := md.armClient.GrantAccess(ARMManagedDiskGrantAccessParams{
+		AccessLevel:       accessLevel,
+		DurationInSeconds: uint64(time.Hour / time.Second),
+	})
+	a.NoError("ARM grant managed disk access call", err)
+	if access != nil {
+		md.accessURI = access.AccessSAS
+	}
+}
+
+func (md *ManagedDiskResourceManager) RevokeAccess(a Asserter) {
+	md.accessURI = ""
+
+	if d, isDryrunner := a.(DryrunAsserter); isDryrunner && d.Dryrun() {
+		return
+	}
+
+	a.NoError("ARM revoke managed disk access call", md.armClient.RevokeAccess())
+}
+
+// Footer downloads the VHD footer (the disk's final 512 bytes) through the granted SAS.
+func (md *ManagedDiskResourceManager) Footer(a Asserter) []byte {
+	if d, isDryrunner := a.(DryrunAsserter); isDryrunner && d.Dryrun() {
+		return nil
+	}
+
+	client, err := blob.NewClientWithNoCredential(md.URI(), nil)
+	a.NoError("create disk blob client", err)
+
+	props, err := client.GetProperties(ctx, nil)
+	a.NoError("get disk properties", err)
+	size := DerefOrZero(props.ContentLength)
+	a.AssertNow("disk must be large enough to hold a VHD footer", Equal{}, size >= vhdFooterSize, true)
+
+	resp, err := client.DownloadStream(ctx, &blob.DownloadStreamOptions{
+		Range: blob.HTTPRange{Offset: size - vhdFooterSize, Count: vhdFooterSize},
+	})
+	a.NoError("download VHD footer", err)
+	defer resp.Body.Close()
+
+	footer, err := io.ReadAll(resp.Body)
+	a.NoError("read VHD footer", err)
+
+	return footer
+}
+
+// Delete revokes any outstanding access (a disk can't be deleted with access granted) and deletes the disk.
+func (md *ManagedDiskResourceManager) Delete(a Asserter) {
+	if d, isDryrunner := a.(DryrunAsserter); isDryrunner && d.Dryrun() {
+		return
+	}
+
+	a.NoError("ARM revoke managed disk access call", md.armClient.RevokeAccess())
+	a.NoError("ARM delete managed disk call", md.armClient.Delete())
+}
+
+// ========== VHDs ==========
+
+const vhdFooterSize = 512
+
+const (
+	vhdDiskTypeFixed    = 2
+	vhdNoDataOffset     = ^uint64(0) // fixed disks have no dynamic header
+	vhdFeaturesReserved = 2
+	vhdFormatVersion    = 0x00010000
+)
+
+var vhdEpoch = time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// vhdFooter is the footer of a VHD, per the Virtual Hard Disk Image Format Specification. All fields are big-endian.
+type vhdFooter struct {
+	Cookie             [8]byte
+	Features           uint32
+	FileFormatVersion  uint32
+	DataOffset         uint64
+	TimeStamp          uint32 // seconds since vhdEpoch
+	CreatorApplication [4]byte
+	CreatorVersion     uint32
+	CreatorHostOS      [4]byte
+	OriginalSize       uint64
+	CurrentSize        uint64
+	Cylinders          uint16
+	Heads              uint8
+	SectorsPerTrack    uint8
+	DiskType           uint32
+	Checksum           uint32
+	UniqueID           [16]byte
+	SavedState         uint8
+	Reserved           [427]byte
+}
+
+func (f vhdFooter) computeChecksum() uint32 {
+	f.Checksum = 0
+	buf := &bytes.Buffer{}
+	_ = binary.Write(buf, binary.BigEndian, f) // writing to a buffer can't fail
+
+	var sum uint32
+	for _, b := range buf.Bytes() {
+		sum += uint32(b)
+	}
+
+	return ^sum
+}
+
+// setGeometry fills in the CHS geometry, per the algorithm in the VHD specification's appendix.
+func (f *vhdFooter) setGeometry(size uint64) {
+	totalSectors := size / 512
+	if totalSectors > 65535*16*255 {
+		totalSectors = 65535 * 16 * 255
+	}
+
+	var sectorsPerTrack, heads, cylinderTimesHeads uint64
+	if totalSectors >= 65535*16*63 {
+		sectorsPerTrack = 255
+		heads = 16
+		cylinderTimesHeads = totalSectors / sectorsPerTrack
+	} else {
+		sectorsPerTrack = 17
+		cylinderTimesHeads = totalSectors / sectorsPerTrack
+		heads = (cylinderTimesHeads + 1023) / 1024
+		if heads < 4 {
+			heads = 4
+		}
+		if cylinderTimesHeads >= heads*1024 || heads > 16 {
+			sectorsPerTrack = 31
+			heads = 16
+			cylinderTimesHeads = totalSectors / sectorsPerTrack
+		}
+		if cylinderTimesHeads >= heads*1024 {
+			sectorsPerTrack = 63
+			heads = 16
+			cylinderTimesHeads = totalSectors / sectorsPerTrack
+		}
+	}
+
+	f.Cylinders = uint16(cylinderTimesHeads / heads)
+	f.Heads = uint8(heads)
+	f.SectorsPerTrack = uint8(sectorsPerTrack)
+}
+
+// NewFixedVHDObjectContentContainer generates a fixed-size VHD of diskSize random bytes, followed by its footer.
+// For managed disk uploads, diskSize must be a multiple of 1MiB.
+func NewFixedVHDObjectContentContainer(a Asserter, diskSize int64) ObjectContentContainer {
+	a.AssertNow("VHD disk size must be a multiple of 512", Equal{}, diskSize%512, int64(0))
+
+	data := GetTypeOrAssert[*ObjectContentContainerBuffer](a, NewRandomObjectContentContainer(a, diskSize))
+
+	footer := vhdFooter{
+		Features:          vhdFeaturesReserved,
+		FileFormatVersion: vhdFormatVersion,
+		DataOffset:        vhdNoDataOffset,
+		TimeStamp:         uint32(time.Since(vhdEpoch) / time.Second),
+		CreatorVersion:    0x000a0000,
+		OriginalSize:      uint64(diskSize),
+		CurrentSize:       uint64(diskSize),
+		DiskType:          vhdDiskTypeFixed,
+		UniqueID:          uuid.New(),
+	}
+	copy(footer.Cookie[:], "conectix")
+	copy(footer.CreatorApplication[:], "azcp")
+	copy(footer.CreatorHostOS[:], "Wi2k")
+	footer.setGeometry(uint64(diskSize))
+	footer.Checksum = footer.computeChecksum()
+
+	buf := bytes.NewBuffer(data.Data)
+	a.NoError("write VHD footer", binary.Write(buf, binary.BigEndian, footer))
+
+	return &ObjectContentContainerBuffer{Data: buf.Bytes()}
+}
+
+// ValidateVHDFooter asserts that footer is an intact footer for a fixed VHD of diskSize bytes (footer excluded).
+func ValidateVHDFooter(a Asserter, footer []byte, diskSize int64) {
+	if d, isDryrunner := a.(DryrunAsserter); isDryrunner && d.Dryrun() {
+		return
+	}
+
+	a.AssertNow("VHD footer must be 512 bytes", Equal{}, len(footer), vhdFooterSize)
+
+	var f vhdFooter
+	a.NoError("parse VHD footer", binary.Read(bytes.NewReader(footer), binary.BigEndian, &f))
+
+	a.Assert("VHD footer cookie must match", Equal{}, string(f.Cookie[:]), "conectix")
+	a.Assert("VHD must be fixed", Equal{}, f.DiskType, uint32(vhdDiskTypeFixed))
+	a.Assert("VHD size must match", Equal{}, f.CurrentSize, uint64(diskSize))
+	a.Assert("VHD footer checksum must match", Equal{}, f.Checksum, f.computeChecksum())
+}
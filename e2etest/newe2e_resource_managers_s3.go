@@ -0,0 +1,505 @@
+package e2etest
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/Azure/azure-storage-azcopy/v10/cmd"
+	"github.com/Azure/azure-storage-azcopy/v10/common"
+	"github.com/minio/minio-go"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+/*
+TODOs:
+- Folders & symlinks (S3 has neither; AzCopy treats them as plain objects)
+- Storage classes
+- Object tagging
+*/
+
+// enforce interface compliance at compile time
+func init() {
+	void := func(_ ...any) {} // prevent go from erroring from unused vars
+
+	void(
+		AccountResourceManager(&S3AccountResourceManager{}),
+		ServiceResourceManager(&S3ServiceResourceManager{}),
+		ContainerResourceManager(&S3BucketResourceManager{}),
+		ObjectResourceManager(&S3ObjectResourceManager{}),
+
+		RemoteResourceManager(&S3ServiceResourceManager{}),
+		RemoteResourceManager(&S3BucketResourceManager{}),
+		RemoteResourceManager(&S3ObjectResourceManager{}),
+	)
+}
+
+const (
+	s3DefaultEndpoint      = "s3.amazonaws.com"
+	s3DefaultRegion        = "us-east-1"
+	s3DefaultPresignExpiry = time.Hour
+	s3UserMetadataPrefix   = "X-Amz-Meta-"
+)
+
+// ==================== ACCOUNT ====================
+
+// S3AccountResourceManager stands in for an "account" on S3, which is really just a set of AWS credentials.
+type S3AccountResourceManager struct {
+	accessKeyID     string
+	secretAccessKey string
+	region          string
+
+	internalClient *minio.Client
+}
+
+func NewS3AccountResourceManager(accessKeyID, secretAccessKey, region string) (*S3AccountResourceManager, error) {
+	// Pinning the region keeps the client from looking up bucket locations, which also lets us presign offline.
+	region = common.Iff(region != "", region, s3DefaultRegion)
+
+	client, err := minio.NewWithRegion(s3DefaultEndpoint, accessKeyID, secretAccessKey, true, region)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create S3 client: %w", err)
+	}
+
+	return &S3AccountResourceManager{
+		accessKeyID:     accessKeyID,
+		secretAccessKey: secretAccessKey,
+		region:          region,
+		internalClient:  client,
+	}, nil
+}
+
+func (s *S3AccountResourceManager) AccountName() string {
+	return "s3"
+}
+
+func (s *S3AccountResourceManager) AccountType() AccountType {
+	return EAccountType.S3()
+}
+
+func (s *S3AccountResourceManager) AvailableServices() []common.Location {
+	return []common.Location{common.ELocation.S3()}
+}
+
+func (s *S3AccountResourceManager) GetService(a Asserter, location common.Location) ServiceResourceManager {
+	a.AssertNow(fmt.Sprintf("\"%s\" is not a valid service for account type %s", location, s.AccountType()), Equal{}, location, common.ELocation.S3())
+
+	return &S3ServiceResourceManager{internalAccount: s}
+}
+
+// ApplyPresign is the S3 analog to AzureAccountResourceManager.ApplySAS.
+// It builds a path-style URI for bucket/key, and presigns a GET against it when requested.
+// AzCopy normally authenticates to S3 through AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY, so presigning is opt-in.
+func (s *S3AccountResourceManager) ApplyPresign(bucket, key string, options ...GetURIOptions) string {
+	opts := FirstOrZero(options)
+
+	if !opts.S3Opts.WithPresign {
+		out := url.URL{
+			Scheme: common.Iff(opts.RemoteOpts.Scheme != "", opts.RemoteOpts.Scheme, "https"),
+			Host:   s3DefaultEndpoint,
+			Path:   "/" + bucket,
+		}
+		if key != "" {
+			out.Path += "/" + key
+		}
+
+		return out.String()
+	}
+
+	if bucket == "" {
+		panic("Only buckets and objects can be presigned.")
+	}
+
+	expiry := common.Iff(opts.S3Opts.PresignExpiry != 0, opts.S3Opts.PresignExpiry, s3DefaultPresignExpiry)
+	presigned, err := s.internalClient.Presign(http.MethodGet, bucket, key, expiry, nil)
+	common.PanicIfErr(err)
+
+	return presigned.String()
+}
+
+// ==================== SERVICE ====================
+
+type S3ServiceResourceManager struct {
+	internalAccount *S3AccountResourceManager
+}
+
+func (s *S3ServiceResourceManager) ValidAuthTypes() ExplicitCredentialTypes {
+	return EExplicitCredentialType.With(EExplicitCredentialType.PublicAuth(), EExplicitCredentialType.S3())
+}
+
+func (s *S3ServiceResourceManager) DefaultAuthType() ExplicitCredentialTypes {
+	return EExplicitCredentialType.S3()
+}
+
+func (s *S3ServiceResourceManager) WithSpecificAuthType(cred ExplicitCredentialTypes, a Asserter, opts ...CreateAzCopyTargetOptions) AzCopyTarget {
+	return CreateAzCopyTarget(s, cred, a, opts...)
+}
+
+func (s *S3ServiceResourceManager) ResourceClient() any {
+	return s.internalAccount.internalClient
+}
+
+func (s *S3ServiceResourceManager) Location() common.Location {
+	return common.ELocation.S3()
+}
+
+func (s *S3ServiceResourceManager) Level() cmd.LocationLevel {
+	return cmd.ELocationLevel.Service()
+}
+
+func (s *S3ServiceResourceManager) URI(opts ...GetURIOptions) string {
+	return s.internalAccount.ApplyPresign("", "", opts...)
+}
+
+func (s *S3ServiceResourceManager) Parent() ResourceManager {
+	return nil
+}
+
+func (s *S3ServiceResourceManager) Account() AccountResourceManager {
+	return s.internalAccount
+}
+
+func (s *S3ServiceResourceManager) Canon() string {
+	return fmt.Sprintf("%s/%s", s.internalAccount.AccountName(), s.Location())
+}
+
+func (s *S3ServiceResourceManager) ListContainers(a Asserter) []string {
+	buckets, err := s.internalAccount.internalClient.ListBuckets()
+	a.NoError("list buckets", err)
+
+	out := make([]string, 0, len(buckets))
+	for _, v := range buckets {
+		out = append(out, v.Name)
+	}
+
+	return out
+}
+
+func (s *S3ServiceResourceManager) GetContainer(name string) ContainerResourceManager {
+	return &S3BucketResourceManager{
+		internalAccount: s.internalAccount,
+		Service:         s,
+		bucketName:      name,
+	}
+}
+
+func (s *S3ServiceResourceManager) IsHierarchical() bool {
+	return false
+}
+
+// ==================== BUCKET ====================
+
+type S3BucketResourceManager struct {
+	internalAccount *S3AccountResourceManager
+	Service         *S3ServiceResourceManager
+	bucketName      string
+}
+
+func (s *S3BucketResourceManager) ValidAuthTypes() ExplicitCredentialTypes {
+	return (&S3ServiceResourceManager{}).ValidAuthTypes()
+}
+
+func (s *S3BucketResourceManager) DefaultAuthType() ExplicitCredentialTypes {
+	return (&S3ServiceResourceManager{}).DefaultAuthType()
+}
+
+func (s *S3BucketResourceManager) WithSpecificAuthType(cred ExplicitCredentialTypes, a Asserter, opts ...CreateAzCopyTargetOptions) AzCopyTarget {
+	return CreateAzCopyTarget(s, cred, a, opts...)
+}
+
+func (s *S3BucketResourceManager) ResourceClient() any {
+	return s.internalAccount.internalClient
+}
+
+func (s *S3BucketResourceManager) Location() common.Location {
+	return s.Service.Location()
+}
+
+func (s *S3BucketResourceManager) Level() cmd.LocationLevel {
+	return cmd.ELocationLevel.Container()
+}
+
+func (s *S3BucketResourceManager) URI(opts ...GetURIOptions) string {
+	return s.internalAccount.ApplyPresign(s.bucketName, "", opts...)
+}
+
+func (s *S3BucketResourceManager) Parent() ResourceManager {
+	return s.Service
+}
+
+func (s *S3BucketResourceManager) Account() AccountResourceManager {
+	return s.internalAccount
+}
+
+func (s *S3BucketResourceManager) Canon() string {
+	return s.Service.Canon() + "/" + s.bucketName
+}
+
+func (s *S3BucketResourceManager) ContainerName() string {
+	return s.bucketName
+}
+
+func (s *S3BucketResourceManager) Create(a Asserter, props ContainerProperties) {
+	err := s.internalAccount.internalClient.MakeBucket(s.bucketName, s.internalAccount.region)
+
+	created := true
+	if code := minio.ToErrorResponse(err).Code; code == "BucketAlreadyOwnedByYou" || code == "BucketAlreadyExists" {
+		created = false
+		err = nil
+	}
+
+	a.NoError("create bucket", err)
+	if created {
+		TrackResourceCreation(a, s)
+	}
+}
+
+// GetProperties returns empty properties, as S3 buckets carry no metadata.
+func (s *S3BucketResourceManager) GetProperties(a Asserter) ContainerProperties {
+	return ContainerProperties{}
+}
+
+func (s *S3BucketResourceManager) Delete(a Asserter) {
+	client := s.internalAccount.internalClient
+
+	// S3 refuses to delete buckets that aren't empty.
+	doneCh := make(chan struct{})
+	defer close(doneCh)
+
+	objectsCh := make(chan string)
+	go func() {
+		defer close(objectsCh)
+		for obj := range client.ListObjectsV2(s.bucketName, "", true, doneCh) {
+			if obj.Err == nil {
+				objectsCh <- obj.Key
+			}
+		}
+	}()
+
+	for rErr := range client.RemoveObjects(s.bucketName, objectsCh) {
+		a.NoError("delete object "+rErr.ObjectName, rErr.Err)
+	}
+
+	err := client.RemoveBucket(s.bucketName)
+	if minio.ToErrorResponse(err).Code == "NoSuchBucket" {
+		err = nil
+	}
+
+	a.NoError("delete bucket", err)
+}
+
+// ListObjects stats each object it lists, since S3 listings don't include headers or metadata.
+func (s *S3BucketResourceManager) ListObjects(a Asserter, prefix string, recursive bool) map[string]ObjectProperties {
+	out := make(map[string]ObjectProperties)
+
+	doneCh := make(chan struct{})
+	defer close(doneCh)
+
+	for obj := range s.internalAccount.internalClient.ListObjectsV2(s.bucketName, prefix, recursive, doneCh) {
+		a.NoError("list objects", obj.Err)
+
+		if !recursive && strings.HasSuffix(obj.Key, "/") && obj.ETag == "" {
+			continue // common prefix, not an object
+		}
+
+		out[obj.Key] = s.GetObject(a, obj.Key, common.EEntityType.File()).GetProperties(a)
+	}
+
+	return out
+}
+
+func (s *S3BucketResourceManager) GetObject(a Asserter, path string, eType common.EntityType) ObjectResourceManager {
+	return &S3ObjectResourceManager{
+		internalAccount: s.internalAccount,
+		Service:         s.Service,
+		Bucket:          s,
+		Path:            path,
+		entityType:      eType,
+	}
+}
+
+func (s *S3BucketResourceManager) Exists() bool {
+	exists, err := s.internalAccount.internalClient.BucketExists(s.bucketName)
+
+	return err == nil && exists
+}
+
+// ==================== OBJECT ====================
+
+type S3ObjectResourceManager struct {
+	internalAccount *S3AccountResourceManager
+	Service         *S3ServiceResourceManager
+	Bucket          *S3BucketResourceManager
+	Path            string
+	entityType      common.EntityType
+}
+
+func (s *S3ObjectResourceManager) ValidAuthTypes() ExplicitCredentialTypes {
+	return (&S3ServiceResourceManager{}).ValidAuthTypes()
+}
+
+func (s *S3ObjectResourceManager) DefaultAuthType() ExplicitCredentialTypes {
+	return (&S3ServiceResourceManager{}).DefaultAuthType()
+}
+
+func (s *S3ObjectResourceManager) WithSpecificAuthType(cred ExplicitCredentialTypes, a Asserter, opts ...CreateAzCopyTargetOptions) AzCopyTarget {
+	return CreateAzCopyTarget(s, cred, a, opts...)
+}
+
+func (s *S3ObjectResourceManager) ResourceClient() any {
+	return s.internalAccount.internalClient
+}
+
+func (s *S3ObjectResourceManager) Location() common.Location {
+	return s.Service.Location()
+}
+
+func (s *S3ObjectResourceManager) Level() cmd.LocationLevel {
+	return cmd.ELocationLevel.Object()
+}
+
+func (s *S3ObjectResourceManager) URI(opts ...GetURIOptions) string {
+	return s.internalAccount.ApplyPresign(s.Bucket.bucketName, s.Path, opts...)
+}
+
+func (s *S3ObjectResourceManager) Parent() ResourceManager {
+	return s.Bucket
+}
+
+func (s *S3ObjectResourceManager) Account() AccountResourceManager {
+	return s.internalAccount
+}
+
+func (s *S3ObjectResourceManager) Canon() string {
+	return s.Bucket.Canon() + "/" + s.Path
+}
+
+func (s *S3ObjectResourceManager) EntityType() common.EntityType {
+	return s.entityType
+}
+
+func (s *S3ObjectResourceManager) ContainerName() string {
+	return s.Bucket.ContainerName()
+}
+
+func (s *S3ObjectResourceManager) ObjectName() string {
+	return s.Path
+}
+
+func (s *S3ObjectResourceManager) Create(a Asserter, body ObjectContentContainer, properties ObjectProperties) {
+	a.AssertNow("S3 only supports file objects", Equal{}, s.entityType, common.EEntityType.File())
+
+	s.put(a, body.Reader(), body.Size(), properties.HTTPHeaders, properties.Metadata)
+
+	TrackResourceCreation(a, s)
+}
+
+// put uploads the object in full. S3 can't change headers or metadata on an existing object, so stamping properties re-uploads it.
+func (s *S3ObjectResourceManager) put(a Asserter, body io.Reader, size int64, headers contentHeaders, metadata common.Metadata) {
+	userMetadata := make(map[string]string)
+	for k, v := range metadata {
+		if v != nil {
+			userMetadata[k] = *v
+		}
+	}
+
+	_, err := s.internalAccount.internalClient.PutObject(s.Bucket.bucketName, s.Path, body, size, minio.PutObjectOptions{
+		UserMetadata:       userMetadata,
+		ContentType:        DerefOrZero(headers.contentType),
+		ContentEncoding:    DerefOrZero(headers.contentEncoding),
+		ContentDisposition: DerefOrZero(headers.contentDisposition),
+		ContentLanguage:    DerefOrZero(headers.contentLanguage),
+		CacheControl:       DerefOrZero(headers.cacheControl),
+	})
+	a.NoError("put object", err)
+}
+
+func (s *S3ObjectResourceManager) Delete(a Asserter) {
+	err := s.internalAccount.internalClient.RemoveObject(s.Bucket.bucketName, s.Path)
+
+	if code := minio.ToErrorResponse(err).Code; code == "NoSuchKey" || code == "NoSuchBucket" {
+		err = nil
+	}
+
+	a.NoError("delete object", err)
+}
+
+func (s *S3ObjectResourceManager) ListChildren(a Asserter, recursive bool) map[string]ObjectProperties {
+	return s.Bucket.ListObjects(a, s.Path, recursive)
+}
+
+func (s *S3ObjectResourceManager) GetProperties(a Asserter) ObjectProperties {
+	info, err := s.internalAccount.internalClient.StatObject(s.Bucket.bucketName, s.Path, minio.StatObjectOptions{})
+	a.NoError("stat object", err)
+
+	metadata := make(common.Metadata)
+	for k, v := range info.Metadata {
+		if strings.HasPrefix(k, s3UserMetadataPrefix) && len(v) > 0 {
+			// S3 stores user metadata keys lowercased
+			metadata[strings.ToLower(strings.TrimPrefix(k, s3UserMetadataPrefix))] = pointerTo(v[0])
+		}
+	}
+
+	headerOrNil := func(key string) *string {
+		if v := info.Metadata.Get(key); v != "" {
+			return &v
+		}
+
+		return nil
+	}
+
+	return ObjectProperties{
+		EntityType: common.EEntityType.File(),
+		HTTPHeaders: contentHeaders{
+			cacheControl:       headerOrNil("Cache-Control"),
+			contentDisposition: headerOrNil("Content-Disposition"),
+			contentEncoding:    headerOrNil("Content-Encoding"),
+			contentLanguage:    headerOrNil("Content-Language"),
+			contentType:        common.Iff(info.ContentType != "", &info.ContentType, nil),
+		},
+		Metadata: metadata,
+	}
+}
+
+func (s *S3ObjectResourceManager) SetHTTPHeaders(a Asserter, h contentHeaders) {
+	props := s.GetProperties(a)
+	props.HTTPHeaders = h
+	s.SetObjectProperties(a, props)
+}
+
+func (s *S3ObjectResourceManager) SetMetadata(a Asserter, metadata common.Metadata) {
+	props := s.GetProperties(a)
+	props.Metadata = metadata
+	s.SetObjectProperties(a, props)
+}
+
+func (s *S3ObjectResourceManager) SetObjectProperties(a Asserter, props ObjectProperties) {
+	body := s.Download(a)
+	size, err := body.Seek(0, io.SeekEnd)
+	a.NoError("measure body", err)
+	_, err = body.Seek(0, io.SeekStart)
+	a.NoError("rewind body", err)
+
+	s.put(a, body, size, props.HTTPHeaders, props.Metadata)
+}
+
+func (s *S3ObjectResourceManager) Download(a Asserter) io.ReadSeeker {
+	obj, err := s.internalAccount.internalClient.GetObject(s.Bucket.bucketName, s.Path, minio.GetObjectOptions{})
+	a.NoError("get object", err)
+	defer obj.Close()
+
+	buf := &bytes.Buffer{}
+	_, err = io.Copy(buf, obj)
+	a.NoError("read body", err)
+
+	return bytes.NewReader(buf.Bytes())
+}
+
+func (s *S3ObjectResourceManager) Exists() bool {
+	_, err := s.internalAccount.internalClient.StatObject(s.Bucket.bucketName, s.Path, minio.StatObjectOptions{})
+
+	code := minio.ToErrorResponse(err).Code
+	return err == nil || (code != "NoSuchKey" && code != "NoSuchBucket")
+}
@@ -0,0 +1,158 @@
+package e2etest
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"github.com/google/uuid"
+	"strings"
+	"testing"
+)
+
+/*
+Scenarios run in parallel against shared accounts, so the containers (and shares, filesystems, buckets) they create are
+namespaced: generated names carry a readable prefix of the test's name, a hash of its full name, a nonce for this run
+of the suite, and a random suffix. Together with per-scenario tracking (see ScenarioVariationManager.TrackCreatedResource),
+this means a scenario's teardown only ever deletes what that scenario created.
+
+e.g. TestNewE2E/BasicFunctionalitySuite/Scenario_SingleFile/Blob creates basicfunctionalitysuite-3f9a1c-8d2e4b7a-5c1f0e92
+*/
+
+const (
+	namespacePrefixLength = 24
+	namespaceFallback     = "e2e"
+)
+
+// runNonce distinguishes this run's resources from those of any other run, e.g. concurrent pipelines sharing an account.
+var runNonce = strings.Split(uuid.NewString(), "-")[0]
+
+// testNamer is implemented by asserters that belong to a single test.
+type testNamer interface {
+	TestName() string
+}
+
+// NewContainerName generates a name for a container, share, filesystem or bucket that's unique to the test a belongs to.
+// The name is valid for every service the framework supports: lowercase alphanumerics and single hyphens, at most 63 characters.
+func NewContainerName(a Asserter) string {
+	testName := namespaceFallback
+	if n, ok := a.(testNamer); ok && n.TestName() != "" {
+		testName = n.TestName()
+	}
+
+	sum := sha256.Sum256([]byte(testName))
+	suffix := strings.Split(uuid.NewString(), "-")
+
+	return fmt.Sprintf("%s-%s-%s-%s",
+		namespacePrefix(testName), hex.EncodeToString(sum[:3]), runNonce, suffix[len(suffix)-1][:8])
+}
+
+// namespacePrefix reduces a test name to something readable that's legal in a container name.
+// The top-level test (e.g. TestNewE2E) is dropped, as it's the same for every scenario.
+func namespacePrefix(testName string) string {
+	if idx := strings.Index(testName, "/"); idx != -1 {
+		testName = testName[idx+1:]
+	}
+
+	var out strings.Builder
+	lastHyphen := true // no leading hyphens
+	for _, r := range strings.ToLower(testName) {
+		if out.Len() == namespacePrefixLength {
+			break
+		}
+
+		switch {
+		case (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9'):
+			out.WriteRune(r)
+			lastHyphen = false
+		case !lastHyphen:
+			out.WriteByte('-')
+			lastHyphen = true
+		}
+	}
+
+	prefix := strings.TrimSuffix(out.String(), "-")
+	if prefix == "" {
+		return namespaceFallback
+	}
+
+	return prefix
+}
+
+// teardownAbandoned is raised by a teardownAsserter to abandon deleting one resource.
+type teardownAbandoned struct{}
+
+// teardownAsserter deletes one tracked resource on behalf of a scenario.
+// Failures are reported against the test, but only abandon that resource, rather than the rest of the scenario's teardown.
+type teardownAsserter struct {
+	t      testing.TB
+	failed bool
+}
+
+// deleteTracked runs deleteFunc with its own teardownAsserter, and reports whether it succeeded.
+// Panics other than teardownAbandoned (e.g. from an SDK) are reported, too; nothing stops the next resource from being deleted.
+func deleteTracked(t testing.TB, canon string, deleteFunc func(a Asserter)) (ok bool) {
+	ta := &teardownAsserter{t: t}
+
+	defer func() {
+		if err := recover(); err != nil {
+			if _, abandoned := err.(teardownAbandoned); !abandoned {
+				ta.t.Errorf("Deleting %s panicked: %v", canon, err)
+			}
+			ok = false
+		}
+	}()
+
+	deleteFunc(ta)
+
+	return !ta.failed
+}
+
+func (ta *teardownAsserter) NoError(comment string, err error) {
+	ta.t.Helper()
+	ta.AssertNow(comment, IsNil{}, err)
+}
+
+func (ta *teardownAsserter) Assert(comment string, assertion Assertion, items ...any) {
+	ta.t.Helper()
+
+	if !assertion.Assert(items...) {
+		if fa, ok := assertion.(FormattedAssertion); ok {
+			ta.t.Errorf("Teardown assertion %s failed: %s (%s)", fa.Name(), fa.Format(items...), comment)
+		} else {
+			ta.t.Errorf("Teardown assertion %s failed with items %v (%s)", assertion.Name(), items, comment)
+		}
+
+		ta.failed = true
+	}
+}
+
+func (ta *teardownAsserter) AssertNow(comment string, assertion Assertion, items ...any) {
+	ta.t.Helper()
+
+	ta.Assert(comment, assertion, items...)
+	if ta.failed {
+		panic(teardownAbandoned{})
+	}
+}
+
+func (ta *teardownAsserter) Error(reason string) {
+	ta.t.Helper()
+	ta.t.Errorf("Teardown error: %s", reason)
+	ta.failed = true
+	panic(teardownAbandoned{})
+}
+
+func (ta *teardownAsserter) Skip(reason string) {
+	ta.t.Helper()
+	ta.t.Logf("Teardown skipped: %s", reason)
+	panic(teardownAbandoned{})
+}
+
+func (ta *teardownAsserter) Log(format string, a ...any) {
+	ta.t.Helper()
+	ta.t.Logf(format, a...)
+}
+
+func (ta *teardownAsserter) Failed() bool {
+	return ta.failed
+}
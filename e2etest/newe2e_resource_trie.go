@@ -67,10 +67,17 @@ func (t *PathTrie[T]) Get(path string) *T {
 	return node.Data
 }
 
+// Remove removes path and all paths beneath it, along with any ancestors left holding nothing.
 func (t *PathTrie[T]) Remove(path string) {
 	node := t.walk(path, false)
+	if node == nil {
+		return
+	}
+
+	node.Data = nil
+	node.Children = make(map[string]*TrieNode[T])
 
-	for node.Parent != nil && node.Data == nil {
+	for node.Parent != nil && node.Data == nil && len(node.Children) == 0 {
 		childSegment := node.Segment
 		node = node.Parent
 
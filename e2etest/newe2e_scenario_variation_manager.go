@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"runtime"
 	"strings"
+	"sync"
 	"testing"
 )
 
@@ -34,7 +35,10 @@ type ScenarioVariationManager struct {
 	VariationData *VariationDataContainer // todo call order, prepared options
 
 	// wetrun data
+	// CreatedResources is everything this variation created, and so everything its teardown deletes. Guarded by resourceMu,
+	// as a scenario may create resources concurrently.
 	CreatedResources *PathTrie[createdResource]
+	resourceMu       sync.Mutex
 }
 
 type createdResource struct {
@@ -42,6 +46,7 @@ type createdResource struct {
 	res  ResourceManager
 }
 
+// initResourceTracker must be called with resourceMu held.
 func (svm *ScenarioVariationManager) initResourceTracker() {
 	if svm.CreatedResources == nil {
 		svm.CreatedResources = NewTrie[createdResource]('/')
@@ -49,28 +54,52 @@ func (svm *ScenarioVariationManager) initResourceTracker() {
 }
 
 func (svm *ScenarioVariationManager) TrackCreatedResource(manager ResourceManager) {
+	svm.resourceMu.Lock()
+	defer svm.resourceMu.Unlock()
 	svm.initResourceTracker()
 
 	canon := manager.Canon()
 	svm.CreatedResources.Insert(canon, &createdResource{res: manager})
 }
 
+func (svm *ScenarioVariationManager) UntrackCreatedResource(manager ResourceManager) {
+	svm.resourceMu.Lock()
+	defer svm.resourceMu.Unlock()
+	svm.initResourceTracker()
+
+	svm.CreatedResources.Remove(manager.Canon())
+}
+
 func (svm *ScenarioVariationManager) TrackCreatedAccount(account AccountResourceManager) {
+	svm.resourceMu.Lock()
+	defer svm.resourceMu.Unlock()
 	svm.initResourceTracker()
 
 	svm.CreatedResources.Insert(account.AccountName(), &createdResource{acct: account})
 }
 
+// DeleteCreatedResources deletes everything the variation created, and nothing else. It's registered as a cleanup, so
+// runs even if the variation failed or panicked. Each resource is deleted independently (see teardownAsserter), so one
+// that can't be deleted doesn't leave the rest behind.
+// With NEW_E2E_DEBUG_SKIP_CLEANUP set, the resources are logged and left in place instead.
 func (svm *ScenarioVariationManager) DeleteCreatedResources() {
+	svm.resourceMu.Lock()
+	defer svm.resourceMu.Unlock()
 	svm.initResourceTracker()
 
 	type deletable interface {
 		Delete(a Asserter)
 	}
 
+	skipCleanup := GlobalConfig.DebugSkipCleanup()
+
 	svm.CreatedResources.Traverse(func(data *createdResource) TraversalOperation {
+		var canon string
+		var deleteFunc func(a Asserter)
+
 		if data.acct != nil {
-			DeleteAccount(svm, data.acct)
+			canon = data.acct.AccountName()
+			deleteFunc = func(a Asserter) { DeleteAccount(a, data.acct) }
 		} else if data.res != nil {
 			del, isDeletable := data.res.(deletable)
 
@@ -78,9 +107,17 @@ func (svm *ScenarioVariationManager) DeleteCreatedResources() {
 				return TraversalOperationContinue
 			}
 
-			del.Delete(svm)
+			canon = data.res.Canon()
+			deleteFunc = del.Delete
 		}
 
+		if skipCleanup {
+			svm.t.Logf("NEW_E2E_DEBUG_SKIP_CLEANUP is set; leaving %s in place", canon)
+		} else if !deleteTracked(svm.t, canon, deleteFunc) {
+			svm.t.Logf("%s could not be deleted, and was left in place", canon)
+		}
+
+		// Children are dropped with their parent; if it couldn't be deleted, they're left in place too.
 		return TraversalOperationRemove
 	})
 
@@ -172,6 +209,15 @@ var variationExcludedCallers = map[string]bool{
 	"GetVariationCallerID": true,
 }
 
+// TestName is the full name of the running test, for namespacing the resources it creates (see NewContainerName).
+func (svm *ScenarioVariationManager) TestName() string {
+	if svm.Dryrun() {
+		return ""
+	}
+
+	return svm.t.Name()
+}
+
 func (svm *ScenarioVariationManager) VariationName() string {
 	return svm.VariationData.GetTestName()
 }
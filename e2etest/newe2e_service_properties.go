@@ -0,0 +1,242 @@
+package e2etest
+
+import (
+	blobservice "github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/service"
+	fileservice "github.com/Azure/azure-sdk-for-go/sdk/storage/azfile/service"
+)
+
+// ServiceProperties are the account-wide settings of a Blob, File or BlobFS service.
+// When specified by user: Nil = left as is
+// When returned by manager: Nil = unsupported
+type ServiceProperties struct {
+	// CORS is supported by every service. An empty, non-nil slice removes all rules.
+	CORS []ServiceCORSRule
+
+	// BlobServiceProperties is shared with BlobFS, whose service properties are the blob service's.
+	BlobServiceProperties BlobServiceProperties
+	FileServiceProperties FileServiceProperties
+}
+
+type ServiceCORSRule struct {
+	// AllowedOrigins, AllowedMethods, AllowedHeaders and ExposedHeaders are comma-separated.
+	AllowedOrigins  string
+	AllowedMethods  string
+	AllowedHeaders  string
+	ExposedHeaders  string
+	MaxAgeInSeconds int32
+}
+
+type BlobServiceProperties struct {
+	// DefaultServiceVersion is the version the service assumes for requests that don't give one, e.g. anonymous reads.
+	// Once set, it can be changed, but not cleared.
+	DefaultServiceVersion *string
+	// DeleteRetentionDays is how long deleted blobs are kept soft-deleted, from 1 to 365; 0 disables soft delete.
+	DeleteRetentionDays *int32
+	StaticWebsite       *BlobStaticWebsite
+}
+
+type BlobStaticWebsite struct {
+	Enabled                  bool
+	IndexDocument            string
+	ErrorDocument404Path     string
+	DefaultIndexDocumentPath string
+}
+
+type FileServiceProperties struct {
+	// SMBMultichannel is only supported by premium file shares.
+	SMBMultichannel *bool
+}
+
+// ServicePropertiesManager is implemented by the Azure service resource managers.
+// Service properties are account-wide, and can take up to 30 seconds to take effect; set them on an account of the test's own (see CreateAccount).
+type ServicePropertiesManager interface {
+	GetProperties(a Asserter) ServiceProperties
+	// SetProperties sets the non-nil properties, leaving the rest as they are. It returns what it replaced; when a is a
+	// ScenarioAsserter, that's restored as the scenario ends.
+	SetProperties(a Asserter, props ServiceProperties) ServiceProperties
+}
+
+// revertOf picks, from current, the properties p sets. Setting them undoes setting p.
+func (p ServiceProperties) revertOf(current ServiceProperties) ServiceProperties {
+	var out ServiceProperties
+
+	if p.CORS != nil {
+		out.CORS = current.CORS
+		if out.CORS == nil {
+			out.CORS = []ServiceCORSRule{}
+		}
+	}
+
+	pb, cb := p.BlobServiceProperties, current.BlobServiceProperties
+	if pb.DefaultServiceVersion != nil {
+		out.BlobServiceProperties.DefaultServiceVersion = cb.DefaultServiceVersion
+	}
+	if pb.DeleteRetentionDays != nil {
+		out.BlobServiceProperties.DeleteRetentionDays = pointerTo(DerefOrZero(cb.DeleteRetentionDays))
+	}
+	if pb.StaticWebsite != nil {
+		out.BlobServiceProperties.StaticWebsite = cb.StaticWebsite
+		if out.BlobServiceProperties.StaticWebsite == nil {
+			out.BlobServiceProperties.StaticWebsite = &BlobStaticWebsite{}
+		}
+	}
+
+	if p.FileServiceProperties.SMBMultichannel != nil {
+		out.FileServiceProperties.SMBMultichannel = pointerTo(DerefOrZero(current.FileServiceProperties.SMBMultichannel))
+	}
+
+	return out
+}
+
+// setServiceProperties sets props with apply, having read what they replace with get, and arranges for that to be restored
+// as the scenario ends. Restores run last-in first-out, so setting properties several times restores the original values.
+func setServiceProperties(a Asserter, props ServiceProperties, get func(a Asserter) ServiceProperties, apply func(a Asserter, props ServiceProperties)) ServiceProperties {
+	revert := props.revertOf(get(a))
+	apply(a, props)
+
+	if sa, ok := a.(ScenarioAsserter); ok {
+		sa.Cleanup(func(a ScenarioAsserter) {
+			apply(a, revert)
+		})
+	}
+
+	return revert
+}
+
+// GetServiceProperties is ServicePropertiesManager.GetProperties, for a service that's a mock during dry runs.
+func GetServiceProperties(a Asserter, s ServiceResourceManager) ServiceProperties {
+	if d, isDryrunner := a.(DryrunAsserter); isDryrunner && d.Dryrun() {
+		return ServiceProperties{}
+	}
+
+	return GetTypeOrAssert[ServicePropertiesManager](a, s).GetProperties(a)
+}
+
+// SetServiceProperties is ServicePropertiesManager.SetProperties, for a service that's a mock during dry runs.
+func SetServiceProperties(a Asserter, s ServiceResourceManager, props ServiceProperties) ServiceProperties {
+	if d, isDryrunner := a.(DryrunAsserter); isDryrunner && d.Dryrun() {
+		return ServiceProperties{}
+	}
+
+	return GetTypeOrAssert[ServicePropertiesManager](a, s).SetProperties(a, props)
+}
+
+// ==================== BLOB (and BlobFS) ====================
+
+func blobServicePropertiesFrom(props blobservice.GetPropertiesResponse) ServiceProperties {
+	out := ServiceProperties{
+		CORS: make([]ServiceCORSRule, 0, len(props.CORS)),
+		BlobServiceProperties: BlobServiceProperties{
+			DefaultServiceVersion: props.DefaultServiceVersion,
+			DeleteRetentionDays:   pointerTo(int32(0)),
+		},
+	}
+
+	for _, rule := range props.CORS {
+		out.CORS = append(out.CORS, ServiceCORSRule{
+			AllowedOrigins:  DerefOrZero(rule.AllowedOrigins),
+			AllowedMethods:  DerefOrZero(rule.AllowedMethods),
+			AllowedHeaders:  DerefOrZero(rule.AllowedHeaders),
+			ExposedHeaders:  DerefOrZero(rule.ExposedHeaders),
+			MaxAgeInSeconds: DerefOrZero(rule.MaxAgeInSeconds),
+		})
+	}
+
+	if policy := props.DeleteRetentionPolicy; policy != nil && DerefOrZero(policy.Enabled) {
+		out.BlobServiceProperties.DeleteRetentionDays = policy.Days
+	}
+
+	if site := props.StaticWebsite; site != nil {
+		out.BlobServiceProperties.StaticWebsite = &BlobStaticWebsite{
+			Enabled:                  DerefOrZero(site.Enabled),
+			IndexDocument:            DerefOrZero(site.IndexDocument),
+			ErrorDocument404Path:     DerefOrZero(site.ErrorDocument404Path),
+			DefaultIndexDocumentPath: DerefOrZero(site.DefaultIndexDocumentPath),
+		}
+	}
+
+	return out
+}
+
+func (p ServiceProperties) blobSetOptions() *blobservice.SetPropertiesOptions {
+	out := &blobservice.SetPropertiesOptions{DefaultServiceVersion: p.BlobServiceProperties.DefaultServiceVersion}
+
+	if p.CORS != nil {
+		out.CORS = make([]*blobservice.CORSRule, 0, len(p.CORS))
+		for _, rule := range p.CORS {
+			out.CORS = append(out.CORS, &blobservice.CORSRule{
+				AllowedOrigins:  pointerTo(rule.AllowedOrigins),
+				AllowedMethods:  pointerTo(rule.AllowedMethods),
+				AllowedHeaders:  pointerTo(rule.AllowedHeaders),
+				ExposedHeaders:  pointerTo(rule.ExposedHeaders),
+				MaxAgeInSeconds: pointerTo(rule.MaxAgeInSeconds),
+			})
+		}
+	}
+
+	if days := p.BlobServiceProperties.DeleteRetentionDays; days != nil {
+		out.DeleteRetentionPolicy = &blobservice.RetentionPolicy{Enabled: pointerTo(*days != 0)}
+		if *days != 0 {
+			out.DeleteRetentionPolicy.Days = days
+		}
+	}
+
+	if site := p.BlobServiceProperties.StaticWebsite; site != nil {
+		out.StaticWebsite = &blobservice.StaticWebsite{Enabled: pointerTo(site.Enabled)}
+		if site.Enabled {
+			out.StaticWebsite.IndexDocument = pointerTo(site.IndexDocument)
+			out.StaticWebsite.ErrorDocument404Path = pointerTo(site.ErrorDocument404Path)
+			out.StaticWebsite.DefaultIndexDocumentPath = pointerTo(site.DefaultIndexDocumentPath)
+		}
+	}
+
+	return out
+}
+
+// ==================== FILE ====================
+
+func fileServicePropertiesFrom(props fileservice.GetPropertiesResponse) ServiceProperties {
+	out := ServiceProperties{CORS: make([]ServiceCORSRule, 0, len(props.CORS))}
+
+	for _, rule := range props.CORS {
+		out.CORS = append(out.CORS, ServiceCORSRule{
+			AllowedOrigins:  DerefOrZero(rule.AllowedOrigins),
+			AllowedMethods:  DerefOrZero(rule.AllowedMethods),
+			AllowedHeaders:  DerefOrZero(rule.AllowedHeaders),
+			ExposedHeaders:  DerefOrZero(rule.ExposedHeaders),
+			MaxAgeInSeconds: DerefOrZero(rule.MaxAgeInSeconds),
+		})
+	}
+
+	// Standard accounts don't report multichannel at all.
+	if protocol := props.Protocol; protocol != nil && protocol.Smb != nil && protocol.Smb.Multichannel != nil {
+		out.FileServiceProperties.SMBMultichannel = protocol.Smb.Multichannel.Enabled
+	}
+
+	return out
+}
+
+func (p ServiceProperties) fileSetOptions() *fileservice.SetPropertiesOptions {
+	out := &fileservice.SetPropertiesOptions{}
+
+	if p.CORS != nil {
+		out.CORS = make([]*fileservice.CORSRule, 0, len(p.CORS))
+		for _, rule := range p.CORS {
+			out.CORS = append(out.CORS, &fileservice.CORSRule{
+				AllowedOrigins:  pointerTo(rule.AllowedOrigins),
+				AllowedMethods:  pointerTo(rule.AllowedMethods),
+				AllowedHeaders:  pointerTo(rule.AllowedHeaders),
+				ExposedHeaders:  pointerTo(rule.ExposedHeaders),
+				MaxAgeInSeconds: pointerTo(rule.MaxAgeInSeconds),
+			})
+		}
+	}
+
+	if enabled := p.FileServiceProperties.SMBMultichannel; enabled != nil {
+		out.Protocol = &fileservice.ProtocolSettings{
+			Smb: &fileservice.SMBSettings{Multichannel: &fileservice.SMBMultichannel{Enabled: enabled}},
+		}
+	}
+
+	return out
+}
@@ -0,0 +1,272 @@
+package e2etest
+
+import (
+	"fmt"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/bloberror"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/container"
+	blobsas "github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/sas"
+	blobservice "github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/service"
+	datalakesas "github.com/Azure/azure-sdk-for-go/sdk/storage/azdatalake/sas"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azfile/fileerror"
+	filesas "github.com/Azure/azure-sdk-for-go/sdk/storage/azfile/sas"
+	fileservice "github.com/Azure/azure-sdk-for-go/sdk/storage/azfile/service"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azfile/share"
+	"github.com/Azure/azure-storage-azcopy/v10/common"
+	"strings"
+	"time"
+)
+
+var (
+	// storedAccessPolicyPropagation is how long the service may take to honor a new or changed stored access policy.
+	storedAccessPolicyPropagation = 30 * time.Second
+	// storedAccessPolicyPollInterval is how often a SAS referencing the policy is retried in the meantime.
+	storedAccessPolicyPollInterval = time.Second
+)
+
+// storedAccessPolicy splits vals into the stored access policy named by Identifier, and the values of a SAS that references it.
+// Start, expiry and permissions move into the policy, defaulted as they would've been inline; the SAS carries none of them.
+func (vals GenericServiceSignatureValues) storedAccessPolicy(loc common.Location) (policy container.AccessPolicy, sasVals GenericServiceSignatureValues) {
+	expiry := vals.ExpiryTime
+	SetIfZero(&expiry, common.Iff(vals.StartTime.IsZero(), time.Now(), vals.StartTime).Add(time.Hour*24))
+	permissions := vals.Permissions
+	if permissions == "" && loc == common.ELocation.File() {
+		permissions = (&filesas.SharePermissions{Read: true, Create: true, Write: true, Delete: true, List: true}).String()
+	}
+	SetIfZero(&permissions, (&blobsas.ContainerPermissions{
+		Read: true, Add: true, Create: true, Write: true, Delete: true, List: true,
+	}).String())
+
+	// The service keeps whole seconds, in UTC.
+	policy = container.AccessPolicy{
+		Expiry:     pointerTo(expiry.UTC().Truncate(time.Second)),
+		Permission: &permissions,
+	}
+	if !vals.StartTime.IsZero() {
+		policy.Start = pointerTo(vals.StartTime.UTC().Truncate(time.Second))
+	}
+
+	sasVals = vals
+	sasVals.SignedIdentifier = vals.Identifier
+	sasVals.Identifier = ""
+	sasVals.StartTime, sasVals.ExpiryTime, sasVals.Permissions = time.Time{}, time.Time{}, ""
+
+	return policy, sasVals
+}
+
+// storedAccessPolicyMatches reports if have already grants want. Times the caller left to default aren't compared,
+// so that signing again doesn't rewrite the policy (and wait out its propagation) for nothing.
+func storedAccessPolicyMatches(have, want container.AccessPolicy, vals GenericServiceSignatureValues) bool {
+	sameTime := func(x, y *time.Time) bool {
+		return (x == nil) == (y == nil) && (x == nil || x.Equal(*y))
+	}
+
+	return DerefOrZero(have.Permission) == DerefOrZero(want.Permission) &&
+		(vals.StartTime.IsZero() || sameTime(have.Start, want.Start)) &&
+		(vals.ExpiryTime.IsZero() || sameTime(have.Expiry, want.Expiry))
+}
+
+// storedAccessPolicyContainer finds the container (or share) URI is within, and its URL on the service that holds its policies.
+// A filesystem's policies are its container's, so BlobFS is handled through the Blob endpoint.
+func storedAccessPolicyContainer(URI string, loc common.Location) (containerURL, containerName string) {
+	switch loc {
+	case common.ELocation.File():
+		parts, err := filesas.ParseURL(URI)
+		common.PanicIfErr(err)
+
+		containerName = parts.ShareName
+		parts.DirectoryOrFilePath, parts.ShareSnapshot, parts.SAS, parts.UnparsedParams = "", "", filesas.QueryParameters{}, ""
+		return parts.String(), containerName
+	case common.ELocation.BlobFS():
+		parts, err := datalakesas.ParseURL(URI)
+		common.PanicIfErr(err)
+
+		containerName = parts.FileSystemName
+		parts.PathName, parts.SAS, parts.UnparsedParams = "", datalakesas.QueryParameters{}, ""
+		parts.Host = strings.Replace(parts.Host, ".dfs.", ".blob.", 1)
+		return parts.String(), containerName
+	default:
+		parts, err := blobsas.ParseURL(URI)
+		common.PanicIfErr(err)
+
+		containerName = parts.ContainerName
+		parts.BlobName, parts.Snapshot, parts.VersionID, parts.SAS, parts.UnparsedParams = "", "", "", blobsas.QueryParameters{}, ""
+		return parts.String(), containerName
+	}
+}
+
+// ensureStoredAccessPolicy creates (or updates) the stored access policy vals.Identifier on the container, share or filesystem URI is within,
+// leaving any others in place, then waits for the service to honor SAS tokens that reference it. It returns the values to sign that SAS with.
+func (acct *AzureAccountResourceManager) ensureStoredAccessPolicy(URI string, loc common.Location, vals GenericServiceSignatureValues) GenericServiceSignatureValues {
+	policy, sasVals := vals.storedAccessPolicy(loc)
+	containerURL, containerName := storedAccessPolicyContainer(URI, loc)
+	SetIfZero(&sasVals.ContainerName, containerName)
+
+	// Probe at the container level, whatever the SAS is scoped to, so that a SAS for a single object can't be mistaken for a bad policy.
+	probeVals := sasVals
+	probeVals.ObjectName, probeVals.DirectoryPath, probeVals.SnapshotTime, probeVals.BlobVersion = "", "", time.Time{}, ""
+
+	switch loc {
+	case common.ELocation.File():
+		skc, err := fileservice.NewSharedKeyCredential(acct.accountName, acct.accountKey)
+		common.PanicIfErr(err)
+		client, err := share.NewClientWithSharedKeyCredential(containerURL, skc, nil)
+		common.PanicIfErr(err)
+
+		resp, err := client.GetAccessPolicy(ctx, nil)
+		common.PanicIfErr(err)
+
+		identifiers := make([]*share.SignedIdentifier, 0, len(resp.SignedIdentifiers)+1)
+		for _, v := range resp.SignedIdentifiers {
+			if DerefOrZero(v.ID) != vals.Identifier {
+				identifiers = append(identifiers, v)
+			} else if v.AccessPolicy != nil && storedAccessPolicyMatches(container.AccessPolicy{Start: v.AccessPolicy.Start, Expiry: v.AccessPolicy.Expiry, Permission: v.AccessPolicy.Permission}, policy, vals) {
+				return sasVals // already in place
+			}
+		}
+		identifiers = append(identifiers, &share.SignedIdentifier{
+			ID:           &vals.Identifier,
+			AccessPolicy: &share.AccessPolicy{Start: policy.Start, Expiry: policy.Expiry, Permission: policy.Permission},
+		})
+		_, err = client.SetAccessPolicy(ctx, &share.SetAccessPolicyOptions{ShareACL: identifiers})
+		common.PanicIfErr(err)
+
+		p, err := probeVals.AsFile().SignWithSharedKey(skc)
+		common.PanicIfErr(err)
+		probe, err := share.NewClientWithNoCredential(containerURL+"?"+p.Encode(), nil)
+		common.PanicIfErr(err)
+
+		waitForStoredAccessPolicy(vals.Identifier, func() error {
+			_, err := probe.NewRootDirectoryClient().NewListFilesAndDirectoriesPager(nil).NextPage(ctx)
+			return err
+		}, func(err error) bool {
+			return fileerror.HasCode(err, fileerror.AuthenticationFailed)
+		})
+	default:
+		skc, err := blobservice.NewSharedKeyCredential(acct.accountName, acct.accountKey)
+		common.PanicIfErr(err)
+		client, err := container.NewClientWithSharedKeyCredential(containerURL, skc, nil)
+		common.PanicIfErr(err)
+
+		resp, err := client.GetAccessPolicy(ctx, nil)
+		common.PanicIfErr(err)
+
+		identifiers := make([]*container.SignedIdentifier, 0, len(resp.SignedIdentifiers)+1)
+		for _, v := range resp.SignedIdentifiers {
+			if DerefOrZero(v.ID) != vals.Identifier {
+				identifiers = append(identifiers, v)
+			} else if v.AccessPolicy != nil && storedAccessPolicyMatches(*v.AccessPolicy, policy, vals) {
+				return sasVals // already in place
+			}
+		}
+		identifiers = append(identifiers, &container.SignedIdentifier{
+			ID:           &vals.Identifier,
+			AccessPolicy: &policy,
+		})
+		_, err = client.SetAccessPolicy(ctx, &container.SetAccessPolicyOptions{
+			Access:       resp.BlobPublicAccess,
+			ContainerACL: identifiers,
+		})
+		common.PanicIfErr(err)
+
+		p, err := probeVals.AsBlob().SignWithSharedKey(skc)
+		common.PanicIfErr(err)
+		probe, err := container.NewClientWithNoCredential(containerURL+"?"+p.Encode(), nil)
+		common.PanicIfErr(err)
+
+		waitForStoredAccessPolicy(vals.Identifier, func() error {
+			_, err := probe.NewListBlobsFlatPager(&container.ListBlobsFlatOptions{MaxResults: to.Ptr(int32(1))}).NextPage(ctx)
+			return err
+		}, func(err error) bool {
+			return bloberror.HasCode(err, bloberror.AuthenticationFailed)
+		})
+	}
+
+	return sasVals
+}
+
+// waitForStoredAccessPolicy retries probe until the service stops rejecting the policy outright.
+// Anything other than an authentication failure (including a permission the policy doesn't grant) means it's in effect.
+func waitForStoredAccessPolicy(id string, probe func() error, unknownPolicy func(error) bool) {
+	deadline := time.Now().Add(storedAccessPolicyPropagation)
+	for {
+		err := probe()
+		if !unknownPolicy(err) {
+			return
+		}
+
+		if time.Now().After(deadline) {
+			panic(fmt.Sprintf("stored access policy %s was not honored within %s: %v", id, storedAccessPolicyPropagation, err))
+		}
+		time.Sleep(storedAccessPolicyPollInterval)
+	}
+}
+
+// removeStoredAccessPolicy deletes the stored access policy id from the container (or share) URI is within, if both still exist.
+func (acct *AzureAccountResourceManager) removeStoredAccessPolicy(URI string, loc common.Location, id string) error {
+	containerURL, _ := storedAccessPolicyContainer(URI, loc)
+
+	switch loc {
+	case common.ELocation.File():
+		skc, err := fileservice.NewSharedKeyCredential(acct.accountName, acct.accountKey)
+		if err != nil {
+			return err
+		}
+		client, err := share.NewClientWithSharedKeyCredential(containerURL, skc, nil)
+		if err != nil {
+			return err
+		}
+
+		resp, err := client.GetAccessPolicy(ctx, nil)
+		if fileerror.HasCode(err, fileerror.ShareNotFound, fileerror.ShareBeingDeleted) {
+			return nil
+		} else if err != nil {
+			return fmt.Errorf("failed to get share access policy: %w", err)
+		}
+
+		identifiers := make([]*share.SignedIdentifier, 0, len(resp.SignedIdentifiers))
+		for _, v := range resp.SignedIdentifiers {
+			if DerefOrZero(v.ID) != id {
+				identifiers = append(identifiers, v)
+			}
+		}
+		if len(identifiers) == len(resp.SignedIdentifiers) {
+			return nil
+		}
+
+		_, err = client.SetAccessPolicy(ctx, &share.SetAccessPolicyOptions{ShareACL: identifiers})
+		return err
+	default:
+		skc, err := blobservice.NewSharedKeyCredential(acct.accountName, acct.accountKey)
+		if err != nil {
+			return err
+		}
+		client, err := container.NewClientWithSharedKeyCredential(containerURL, skc, nil)
+		if err != nil {
+			return err
+		}
+
+		resp, err := client.GetAccessPolicy(ctx, nil)
+		if bloberror.HasCode(err, bloberror.ContainerNotFound, bloberror.ContainerBeingDeleted) {
+			return nil
+		} else if err != nil {
+			return fmt.Errorf("failed to get container access policy: %w", err)
+		}
+
+		identifiers := make([]*container.SignedIdentifier, 0, len(resp.SignedIdentifiers))
+		for _, v := range resp.SignedIdentifiers {
+			if DerefOrZero(v.ID) != id {
+				identifiers = append(identifiers, v)
+			}
+		}
+		if len(identifiers) == len(resp.SignedIdentifiers) {
+			return nil
+		}
+
+		_, err = client.SetAccessPolicy(ctx, &container.SetAccessPolicyOptions{
+			Access:       resp.BlobPublicAccess,
+			ContainerACL: identifiers,
+		})
+		return err
+	}
+}
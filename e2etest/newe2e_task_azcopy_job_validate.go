@@ -1,8 +1,47 @@
 package e2etest
 
+import (
+	"fmt"
+	"github.com/Azure/azure-storage-azcopy/v10/common"
+	"strings"
+)
+
 /*
 ValidatePlanFiles
 
 todo: Determine an interface for this. We could have it act like ValidateResource where it will validate against a resource definition.
 */
 func ValidatePlanFiles(sm *ScenarioVariationManager, plan *AzCopyJobPlan, todo any) {}
+
+// ValidateJobStatus asserts that AzCopy reported the end of the job, with status.
+func ValidateJobStatus(a Asserter, stdout *AzCopyStdout, status common.JobStatus) {
+	if d, isDryrunner := a.(DryrunAsserter); isDryrunner && d.Dryrun() {
+		return
+	}
+
+	a.AssertNow("AzCopy must report the end of the job (is --output-type json?)", Equal{}, stdout.JobEnded, true)
+	a.Assert("job status", Equal{}, stdout.JobSummary.JobStatus, status)
+}
+
+// ValidateFailedTransfers asserts that AzCopy reported failed transfers as the job ended, and that every one failed with statusCode
+// (e.g. http.StatusForbidden, where the service rejected the credential).
+func ValidateFailedTransfers(a Asserter, stdout *AzCopyStdout, statusCode int32) {
+	if d, isDryrunner := a.(DryrunAsserter); isDryrunner && d.Dryrun() {
+		return
+	}
+
+	a.AssertNow("AzCopy must report the end of the job (is --output-type json?)", Equal{}, stdout.JobEnded, true)
+	a.Assert("some transfers must fail", Not{Equal{}}, len(stdout.JobSummary.FailedTransfers), 0)
+	for _, t := range stdout.JobSummary.FailedTransfers {
+		a.Assert(fmt.Sprintf("status of failed transfer %s", t.Src), Equal{}, t.ErrorCode, statusCode)
+	}
+}
+
+// ValidateOutputContains asserts that AzCopy's output includes text, e.g. to check that it explained why it failed.
+func ValidateOutputContains(a Asserter, stdout *AzCopyStdout, text string) {
+	if d, isDryrunner := a.(DryrunAsserter); isDryrunner && d.Dryrun() {
+		return
+	}
+
+	a.Assert(fmt.Sprintf("AzCopy's output must contain %q", text), Equal{}, strings.Contains(stdout.String(), text), true)
+}
@@ -3,6 +3,7 @@ package e2etest
 import (
 	"crypto/md5"
 	"encoding/hex"
+	"fmt"
 	"github.com/Azure/azure-storage-azcopy/v10/cmd"
 	"github.com/Azure/azure-storage-azcopy/v10/common"
 	"io"
@@ -14,6 +15,11 @@ type ResourceTracker interface {
 	TrackCreatedAccount(account AccountResourceManager)
 }
 
+// resourceUntracker lets a resource that can't be deleted at the end of a scenario (e.g. a container with a locked immutability policy) opt out of teardown.
+type resourceUntracker interface {
+	UntrackCreatedResource(manager ResourceManager)
+}
+
 func TrackResourceCreation(a Asserter, rm any) {
 	if t, ok := a.(ResourceTracker); ok {
 		if arm, ok := rm.(AccountResourceManager); ok {
@@ -79,6 +85,19 @@ func ValidatePropertyPtr[T any](a Asserter, name string, expected, real *T) {
 	a.Assert(name+" must match", Equal{Deep: true}, expected, real)
 }
 
+// ValidateSampledContent compares only the ranges expected samples of the object's content, and its length, rather than downloading all of it.
+func ValidateSampledContent(a Asserter, obj ObjectRangeDownloader, expected SampledObjectContentContainer) {
+	if d, isDryrunner := a.(DryrunAsserter); isDryrunner && d.Dryrun() {
+		return
+	}
+
+	a.Assert("content length", Equal{}, obj.ContentLength(a), expected.Size())
+	for _, r := range expected.SampleRanges() {
+		actual := obj.DownloadRange(a, r.Offset, r.Count)
+		a.Assert(fmt.Sprintf("content of range %d+%d", r.Offset, r.Count), Equal{}, md5.Sum(actual), md5.Sum(expected.ReadRange(r)))
+	}
+}
+
 func ValidateMetadata(a Asserter, expected, real common.Metadata) {
 	if expected == nil {
 		return
@@ -119,6 +138,8 @@ func ValidateResource[T ResourceManager](a Asserter, target T, definition Matche
 
 			if manager.Location() == common.ELocation.Blob() || manager.Location() == common.ELocation.BlobFS() {
 				ValidatePropertyPtr(a, "Public access", vProps.BlobContainerProperties.Access, cProps.BlobContainerProperties.Access)
+				ValidatePropertyPtr(a, "Has immutability policy", vProps.BlobContainerProperties.HasImmutabilityPolicy, cProps.BlobContainerProperties.HasImmutabilityPolicy)
+				ValidatePropertyPtr(a, "Has legal hold", vProps.BlobContainerProperties.HasLegalHold, cProps.BlobContainerProperties.HasLegalHold)
 			}
 
 			if manager.Location() == common.ELocation.File() {
@@ -140,7 +161,11 @@ func ValidateResource[T ResourceManager](a Asserter, target T, definition Matche
 			oProps := objMan.GetProperties(a)
 			vProps := objDef.ObjectProperties
 
-			if validateObjectContent && objMan.EntityType() == common.EEntityType.File() && objDef.Body != nil {
+			sampled, isSampled := objDef.Body.(SampledObjectContentContainer)
+			ranged, isRanged := objMan.(ObjectRangeDownloader)
+			if validateObjectContent && objMan.EntityType() == common.EEntityType.File() && isSampled && isRanged && len(sampled.SampleRanges()) != 0 {
+				ValidateSampledContent(a, ranged, sampled)
+			} else if validateObjectContent && objMan.EntityType() == common.EEntityType.File() && objDef.Body != nil {
 				objBody := objMan.Download(a)
 				validationBody := objDef.Body.Reader()
 
@@ -171,11 +196,17 @@ func ValidateResource[T ResourceManager](a Asserter, target T, definition Matche
 				ValidateTags(a, vProps.BlobProperties.Tags, oProps.BlobProperties.Tags)
 				ValidatePropertyPtr(a, "Block blob access tier", vProps.BlobProperties.BlockBlobAccessTier, oProps.BlobProperties.BlockBlobAccessTier)
 				ValidatePropertyPtr(a, "Page blob access tier", vProps.BlobProperties.PageBlobAccessTier, oProps.BlobProperties.PageBlobAccessTier)
+				ValidatePropertyPtr(a, "Archive status", vProps.BlobProperties.ArchiveStatus, oProps.BlobProperties.ArchiveStatus)
+				ValidatePropertyPtr(a, "Encryption scope", vProps.BlobProperties.EncryptionScope, oProps.BlobProperties.EncryptionScope)
 			case common.ELocation.File():
 				ValidatePropertyPtr(a, "Attributes", vProps.FileProperties.FileAttributes, oProps.FileProperties.FileAttributes)
 				ValidatePropertyPtr(a, "Creation time", vProps.FileProperties.FileCreationTime, oProps.FileProperties.FileCreationTime)
 				ValidatePropertyPtr(a, "Last write time", vProps.FileProperties.FileLastWriteTime, oProps.FileProperties.FileLastWriteTime)
 				ValidatePropertyPtr(a, "Permissions", vProps.FileProperties.FilePermissions, oProps.FileProperties.FilePermissions)
+				ValidatePropertyPtr(a, "Permission key", vProps.FileProperties.FilePermissionKey, oProps.FileProperties.FilePermissionKey)
+				ValidatePropertyPtr(a, "Mode", vProps.FileProperties.FileMode, oProps.FileProperties.FileMode)
+				ValidatePropertyPtr(a, "Owner", vProps.FileProperties.Owner, oProps.FileProperties.Owner)
+				ValidatePropertyPtr(a, "Group", vProps.FileProperties.Group, oProps.FileProperties.Group)
 			case common.ELocation.BlobFS():
 				ValidatePropertyPtr(a, "Permissions", vProps.BlobFSProperties.Permissions, oProps.BlobFSProperties.Permissions)
 				ValidatePropertyPtr(a, "Owner", vProps.BlobFSProperties.Owner, oProps.BlobFSProperties.Owner)
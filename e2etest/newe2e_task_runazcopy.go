@@ -1,6 +1,7 @@
 package e2etest
 
 import (
+	"encoding/json"
 	"fmt"
 	"github.com/Azure/azure-storage-azcopy/v10/common"
 	"os"
@@ -15,6 +16,14 @@ type AzCopyJobPlan struct{}
 // AzCopyStdout shouldn't be used or relied upon right now! This will be fleshed out eventually. todo WI#26418258
 type AzCopyStdout struct {
 	RawOutput []string
+
+	// JobSummary is the latest summary of the job AzCopy reported (given --output-type=json, the default), as progress or as the job ended.
+	JobSummary *common.ListJobSummaryResponse
+	// JobEnded is whether JobSummary is the one AzCopy reported as the job ended.
+	JobEnded bool
+
+	onProgress  func(summary common.ListJobSummaryResponse)
+	partialLine string
 }
 
 func (a *AzCopyStdout) Write(p []byte) (n int, err error) {
@@ -23,9 +32,38 @@ func (a *AzCopyStdout) Write(p []byte) (n int, err error) {
 
 	a.RawOutput = append(a.RawOutput, lines...)
 
+	// Writes needn't end on a line break, so JSON output is only parsed a whole line at a time.
+	a.partialLine += str
+	for i := strings.IndexByte(a.partialLine, '\n'); i >= 0; i = strings.IndexByte(a.partialLine, '\n') {
+		a.parseJSONLine(a.partialLine[:i])
+		a.partialLine = a.partialLine[i+1:]
+	}
+
 	return len(p), nil
 }
 
+func (a *AzCopyStdout) parseJSONLine(line string) {
+	var msg common.JsonOutputTemplate
+	if json.Unmarshal([]byte(line), &msg) != nil {
+		return // not JSON output
+	}
+
+	ended := msg.MessageType == "EndOfJob"
+	if msg.MessageType != "Progress" && !ended {
+		return
+	}
+
+	var summary common.ListJobSummaryResponse
+	if json.Unmarshal([]byte(msg.MessageContent), &summary) != nil {
+		return
+	}
+
+	a.JobSummary, a.JobEnded = &summary, ended
+	if !ended && a.onProgress != nil {
+		a.onProgress(summary)
+	}
+}
+
 func (a *AzCopyStdout) String() string {
 	return strings.Join(a.RawOutput, "\n")
 }
@@ -36,6 +74,8 @@ const ( // initially supporting a limited set of verbs
 	AzCopyVerbCopy   AzCopyVerb = "copy"
 	AzCopyVerbSync   AzCopyVerb = "sync"
 	AzCopyVerbRemove AzCopyVerb = "remove"
+	// AzCopyVerbJobsResume takes the job ID as its only argument (see AzCopyCommand.Arguments), and no targets.
+	AzCopyVerbJobsResume AzCopyVerb = "jobs resume"
 )
 
 type AzCopyTarget struct {
@@ -50,7 +90,9 @@ type AzCopyTarget struct {
 type CreateAzCopyTargetOptions struct {
 	// SASTokenOptions expects a GenericSignatureValues, which can contain account signatures, or a service signature.
 	SASTokenOptions GenericSignatureValues
-	Scheme          string
+	// AccountSASOptions, if set, signs an account SAS that may span several services, and SASTokenOptions is ignored.
+	AccountSASOptions *AccountSASOptions
+	Scheme            string
 }
 
 func CreateAzCopyTarget(rm ResourceManager, authType ExplicitCredentialTypes, a Asserter, opts ...CreateAzCopyTargetOptions) AzCopyTarget {
@@ -74,13 +116,26 @@ type AzCopyCommand struct {
 	// Passing a ResourceManager assumes SAS (or GCP/S3) auth is intended.
 	// Passing an AzCopyTarget will allow you to specify an exact credential type.
 	// When OAuth, S3, GCP, AcctKey, etc. the appropriate env flags should auto-populate.
-	Targets     []ResourceManager
+	Targets []ResourceManager
+	// Arguments follow the targets, e.g. the ID of a job to resume.
+	Arguments   []string
 	Flags       any // check SampleFlags
 	Environment *AzCopyEnvironment
+	// ProgressHooks run while the job does, as it reaches each checkpoint. A hook the job finishes without reaching fails the test.
+	ProgressHooks []AzCopyProgressHook
 
 	ShouldFail bool
 }
 
+// AzCopyProgressHook is a checkpoint in a running job, e.g. to rotate an account key partway through.
+type AzCopyProgressHook struct {
+	// PercentComplete is how far along the job must be, as AzCopy reports it, for Hook to run.
+	PercentComplete float32
+	// Hook runs once, while AzCopy carries on transferring (though its output waits on Hook).
+	// It runs off the test's goroutine, so it should Assert rather than AssertNow.
+	Hook func(a ScenarioAsserter)
+}
+
 type AzCopyEnvironment struct {
 	// `env:"XYZ"` is re-used but does not inherit the traits of config's env trait. Merely used for low-code mapping.
 
@@ -90,6 +145,10 @@ type AzCopyEnvironment struct {
 	AutoLoginTenantID            *string `env:"AZCOPY_TENANT_ID"`
 	ServicePrincipalAppID        *string `env:"AZCOPY_SPA_APPLICATION_ID"`
 	ServicePrincipalClientSecret *string `env:"AZCOPY_SPA_CLIENT_SECRET"`
+	AWSAccessKeyID               *string `env:"AWS_ACCESS_KEY_ID"`
+	AWSSecretAccessKey           *string `env:"AWS_SECRET_ACCESS_KEY"`
+	GoogleCredentialsPath        *string `env:"GOOGLE_APPLICATION_CREDENTIALS"`
+	GoogleCloudProject           *string `env:"GOOGLE_CLOUD_PROJECT"`
 
 	InheritEnvironment bool
 }
@@ -125,6 +184,25 @@ func (env *AzCopyEnvironment) DefaultPlanLoc(a ScenarioAsserter) string {
 	return *env.JobPlanLocation
 }
 
+// cleanupStoredAccessPolicy removes, when the scenario ends, the stored access policy that signing target's SAS will create.
+// See GenericServiceSignatureValues.Identifier.
+func (c *AzCopyCommand) cleanupStoredAccessPolicy(a Asserter, target ResourceManager, opts GetURIOptions) {
+	vals, ok := opts.AzureOpts.SASValues.(GenericServiceSignatureValues)
+	if !ok || vals.Identifier == "" || opts.AzureOpts.AccountSAS {
+		return
+	}
+	acct, ok := target.Account().(*AzureAccountResourceManager)
+	sa, isScenario := a.(ScenarioAsserter)
+	if !ok || !isScenario {
+		return
+	}
+
+	uri, loc := target.URI(), target.Location()
+	sa.Cleanup(func(a ScenarioAsserter) {
+		a.NoError("remove stored access policy "+vals.Identifier, acct.removeStoredAccessPolicy(uri, loc, vals.Identifier))
+	})
+}
+
 func (c *AzCopyCommand) applyTargetAuth(a Asserter, target ResourceManager) string {
 	intendedAuthType := EExplicitCredentialType.SASToken()
 	var opts GetURIOptions
@@ -136,11 +214,17 @@ func (c *AzCopyCommand) applyTargetAuth(a Asserter, target ResourceManager) stri
 		}
 
 		opts.AzureOpts.SASValues = tgt.Opts.SASTokenOptions
+		if tgt.Opts.AccountSASOptions != nil {
+			opts.AzureOpts.AccountSAS = true
+			opts.AzureOpts.AccountSASValues = *tgt.Opts.AccountSASOptions
+		}
 		opts.RemoteOpts.Scheme = tgt.Opts.Scheme
 	} else if target.Location() == common.ELocation.S3() {
 		intendedAuthType = EExplicitCredentialType.S3()
 	} else if target.Location() == common.ELocation.GCP() {
 		intendedAuthType = EExplicitCredentialType.GCP()
+	} else if acct, ok := target.Account().(*AzureAccountResourceManager); ok && acct.keyless() {
+		intendedAuthType = EExplicitCredentialType.OAuth() // there's no key to sign a SAS with
 	}
 
 	switch intendedAuthType {
@@ -148,6 +232,7 @@ func (c *AzCopyCommand) applyTargetAuth(a Asserter, target ResourceManager) stri
 		return target.URI() // no SAS, no nothing.
 	case EExplicitCredentialType.SASToken():
 		opts.AzureOpts.WithSAS = true
+		c.cleanupStoredAccessPolicy(a, target, opts)
 		return target.URI(opts)
 	case EExplicitCredentialType.OAuth():
 		// Only set it if it wasn't already configured. If it was manually configured,
@@ -173,6 +258,27 @@ func (c *AzCopyCommand) applyTargetAuth(a Asserter, target ResourceManager) stri
 		}
 
 		return target.URI() // Generate like public
+	case EExplicitCredentialType.S3():
+		// As with OAuth, leave manually configured credentials alone.
+		if c.Environment.AWSAccessKeyID == nil && c.Environment.AWSSecretAccessKey == nil {
+			s3Info := GlobalConfig.S3AuthConfig
+			a.AssertNow("NEW_E2E_AWS_ACCESS_KEY_ID and NEW_E2E_AWS_SECRET_ACCESS_KEY must be specified to use S3.", Empty{true}, s3Info.AccessKeyID, s3Info.SecretAccessKey)
+
+			c.Environment.AWSAccessKeyID = &s3Info.AccessKeyID
+			c.Environment.AWSSecretAccessKey = &s3Info.SecretAccessKey
+		}
+
+		return target.URI(opts)
+	case EExplicitCredentialType.GCP():
+		if c.Environment.GoogleCredentialsPath == nil && c.Environment.GoogleCloudProject == nil {
+			gcpInfo := GlobalConfig.GCPAuthConfig
+			a.AssertNow("GOOGLE_APPLICATION_CREDENTIALS and GOOGLE_CLOUD_PROJECT must be specified to use GCP.", Empty{true}, gcpInfo.CredentialsPath, gcpInfo.ProjectID)
+
+			c.Environment.GoogleCredentialsPath = &gcpInfo.CredentialsPath
+			c.Environment.GoogleCloudProject = &gcpInfo.ProjectID
+		}
+
+		return target.URI(opts)
 	default:
 		a.Error("unsupported credential type")
 		return target.URI()
@@ -191,17 +297,26 @@ func RunAzCopy(a ScenarioAsserter, commandSpec AzCopyCommand) (*AzCopyStdout, *A
 			commandSpec.Environment = &AzCopyEnvironment{}
 		}
 
-		out := []string{GlobalConfig.AzCopyExecutableConfig.ExecutablePath, string(commandSpec.Verb)}
+		out := append([]string{GlobalConfig.AzCopyExecutableConfig.ExecutablePath}, strings.Fields(string(commandSpec.Verb))...)
 
 		for _, v := range commandSpec.Targets {
 			out = append(out, commandSpec.applyTargetAuth(a, v))
 		}
+		out = append(out, commandSpec.Arguments...)
 
+		flags := map[string]string{}
 		if commandSpec.Flags != nil {
-			flags := MapFromTags(reflect.ValueOf(commandSpec.Flags), "flag", a)
-			for k, v := range flags {
-				out = append(out, fmt.Sprintf("--%s=%s", k, v))
-			}
+			flags = MapFromTags(reflect.ValueOf(commandSpec.Flags), "flag", a)
+		}
+
+		// AzCopy can't infer a location from the emulator's IP-style URLs, so nudge it the same way a user would.
+		if _, ok := flags["from-to"]; !ok && GlobalConfig.Emulated() && len(commandSpec.Targets) == 2 {
+			src, dst := commandSpec.Targets[0].Location(), commandSpec.Targets[1].Location()
+			flags["from-to"] = (common.FromTo(src)<<8 | common.FromTo(dst)).String()
+		}
+
+		for k, v := range flags {
+			out = append(out, fmt.Sprintf("--%s=%s", k, v))
 		}
 
 		return out
@@ -222,7 +337,15 @@ func RunAzCopy(a ScenarioAsserter, commandSpec AzCopyCommand) (*AzCopyStdout, *A
 		return out
 	}()
 
-	out := &AzCopyStdout{}
+	hooksRun := make([]bool, len(commandSpec.ProgressHooks))
+	out := &AzCopyStdout{onProgress: func(summary common.ListJobSummaryResponse) {
+		for i, hook := range commandSpec.ProgressHooks {
+			if !hooksRun[i] && summary.PercentComplete >= hook.PercentComplete {
+				hooksRun[i] = true
+				hook.Hook(a)
+			}
+		}
+	}}
 	command := exec.Cmd{
 		Path: GlobalConfig.AzCopyExecutableConfig.ExecutablePath,
 		Args: args,
@@ -246,6 +369,10 @@ func RunAzCopy(a ScenarioAsserter, commandSpec AzCopyCommand) (*AzCopyStdout, *A
 		common.Iff[Assertion](commandSpec.ShouldFail, Not{Equal{}}, Equal{}),
 		0, command.ProcessState.ExitCode())
 
+	for i, hook := range commandSpec.ProgressHooks {
+		a.Assert(fmt.Sprintf("progress hook at %.1f%% must run before the job ends", hook.PercentComplete), Equal{}, hooksRun[i], true)
+	}
+
 	if err != nil {
 		a.Log("AzCopy output:\n%s", out.String())
 	}
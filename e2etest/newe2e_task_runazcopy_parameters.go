@@ -379,6 +379,14 @@ type SyncFlags struct {
 	LocalHashStorageMode *common.HashStorageMode `flag:"local-hash-storage-mode"`
 }
 
+// JobsResumeFlags are for AzCopyVerbJobsResume. SAS tokens aren't persisted with the job, so any it used must be supplied again.
+type JobsResumeFlags struct {
+	GlobalFlags
+
+	SourceSAS      *string `flag:"source-sas"`
+	DestinationSAS *string `flag:"destination-sas"`
+}
+
 // RemoveFlags is not tiered like CopySyncCommonFlags is, because it is dissimilar in functionality, and would be hard to test in the same scenario.
 type RemoveFlags struct {
 	GlobalFlags
@@ -0,0 +1,125 @@
+package e2etest
+
+import (
+	"testing"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/service"
+	"github.com/Azure/azure-storage-azcopy/v10/common"
+	"github.com/stretchr/testify/assert"
+)
+
+func init() {
+	suiteManager.RegisterSuite(&AccountPoolSuite{})
+}
+
+func TestAccountCreateParams(t *testing.T) {
+	a := assert.New(t)
+
+	for accountType, expected := range map[AccountType]struct {
+		kind service.AccountKind
+		sku  ARMStorageAccountSKU
+		hns  bool
+	}{
+		EAccountType.Standard():                     {service.AccountKindStorageV2, ARMStorageAccountSKUStandardLRS, false},
+		EAccountType.HierarchicalNamespaceEnabled(): {service.AccountKindStorageV2, ARMStorageAccountSKUStandardLRS, true},
+		EAccountType.PremiumBlockBlobs():            {service.AccountKindBlockBlobStorage, ARMStorageAccountSKUPremiumLRS, false},
+		EAccountType.PremiumHNSEnabled():            {service.AccountKindBlockBlobStorage, ARMStorageAccountSKUPremiumLRS, true},
+		EAccountType.PremiumPageBlobs():             {service.AccountKindStorageV2, ARMStorageAccountSKUPremiumLRS, false},
+		EAccountType.PremiumFileShares():            {service.AccountKindFileStorage, ARMStorageAccountSKUPremiumLRS, false},
+		EAccountType.PremiumV2FileShares():          {service.AccountKindFileStorage, ARMStorageAccountSKUPremiumV2LRS, false},
+	} {
+		params, ok := accountCreateParams(accountType)
+		a.True(ok, accountType.String())
+		a.Equal(expected.kind, params.Kind, accountType.String())
+		a.Equal(expected.sku, params.Sku, accountType.String())
+		a.Equal(expected.hns, params.Properties != nil && DerefOrZero(params.Properties.IsHnsEnabled), accountType.String())
+	}
+
+	// Neither comes from Microsoft.Storage.
+	for _, accountType := range []AccountType{EAccountType.Classic(), EAccountType.StdManagedDisk()} {
+		_, ok := accountCreateParams(accountType)
+		a.False(ok, accountType.String())
+	}
+}
+
+func TestAccountTypeServices(t *testing.T) {
+	a := assert.New(t)
+	fa := NewFrameworkAsserter(t)
+
+	// Every type has a name of its own; the premium types used to share values with Azurite and managed disk snapshots.
+	names := map[string]AccountType{}
+	for _, accountType := range []AccountType{
+		EAccountType.Standard(), EAccountType.PremiumBlockBlobs(), EAccountType.PremiumPageBlobs(), EAccountType.PremiumFileShares(),
+		EAccountType.PremiumHNSEnabled(), EAccountType.PremiumV2FileShares(), EAccountType.HierarchicalNamespaceEnabled(),
+		EAccountType.Classic(), EAccountType.StdManagedDisk(), EAccountType.OAuthManagedDisk(), EAccountType.S3(), EAccountType.GCP(),
+		EAccountType.Azurite(), EAccountType.ManagedDiskSnapshot(), EAccountType.ManagedDiskSnapshotOAuth(),
+	} {
+		_, dupe := names[accountType.String()]
+		a.False(dupe, accountType.String())
+		names[accountType.String()] = accountType
+	}
+	a.False(EAccountType.PremiumFileShares().IsManagedDisk())
+
+	acct := &AzureAccountResourceManager{accountName: "acct", accountKey: "YWNjb3VudGtleQ==", accountType: EAccountType.PremiumBlockBlobs()}
+	a.Equal([]common.Location{common.ELocation.Blob(), common.ELocation.BlobFS()}, acct.AvailableServices())
+	a.Equal("https://acct.blob.core.windows.net", acct.GetService(fa, common.ELocation.Blob()).URI())
+
+	// Asking for a service the type lacks skips, rather than failing.
+	var skipped bool
+	t.Run("File", func(t *testing.T) {
+		t.Cleanup(func() { skipped = t.Skipped() })
+		acct.GetService(NewFrameworkAsserter(t), common.ELocation.File())
+	})
+	a.True(skipped)
+
+	acct.accountType = EAccountType.PremiumV2FileShares()
+	a.Equal([]common.Location{common.ELocation.File()}, acct.AvailableServices())
+
+	// Mocks agree, and drop the variation rather than attempting it.
+	mock := &MockAccountResourceManager{accountType: EAccountType.PremiumHNSEnabled()}
+	a.Equal([]common.Location{common.ELocation.Blob(), common.ELocation.BlobFS()}, mock.AvailableServices())
+
+	svm := &ScenarioVariationManager{} // no t; a dry run
+	mock.GetService(svm, common.ELocation.File())
+	a.True(svm.Invalid())
+}
+
+type AccountPoolSuite struct{}
+
+// Scenario_PremiumAccounts uploads to each service of each premium account type; combinations the type doesn't offer are dropped.
+func (s *AccountPoolSuite) Scenario_PremiumAccounts(svm *ScenarioVariationManager) {
+	acct := GetAccountOfType(svm, ResolveVariation(svm, []AccountType{
+		EAccountType.PremiumBlockBlobs(),
+		EAccountType.PremiumPageBlobs(),
+		EAccountType.PremiumHNSEnabled(),
+		EAccountType.PremiumFileShares(),
+	}))
+	svc := acct.GetService(svm, ResolveVariation(svm, []common.Location{common.ELocation.Blob(), common.ELocation.File(), common.ELocation.BlobFS()}))
+	if svm.Invalid() {
+		return
+	}
+
+	body := NewRandomObjectContentContainer(svm, SizeFromString("1K"))
+	srcObj := CreateResource[ObjectResourceManager](svm, GetRootResource(svm, common.ELocation.Local()), ResourceDefinitionObject{
+		ObjectName: pointerTo("test"),
+		Body:       body,
+	})
+	dstContainer := CreateResource[ContainerResourceManager](svm, svc, ResourceDefinitionContainer{})
+
+	var flags CopyFlags
+	if acct.AccountType() == EAccountType.PremiumPageBlobs() {
+		flags.BlobType = pointerTo(common.EBlobType.PageBlob()) // premium page blob accounts hold nothing else; 1K is already a multiple of 512
+	}
+
+	RunAzCopy(
+		svm,
+		AzCopyCommand{
+			Verb:    AzCopyVerbCopy,
+			Targets: []ResourceManager{srcObj, dstContainer},
+			Flags:   flags,
+		})
+
+	ValidateResource[ObjectResourceManager](svm, dstContainer.GetObject(svm, "test", common.EEntityType.File()), ResourceDefinitionObject{
+		Body: body,
+	}, true)
+}
@@ -0,0 +1,186 @@
+package e2etest
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/blob"
+	blobsas "github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/sas"
+	blobservice "github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/service"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azfile/file"
+	"github.com/Azure/azure-storage-azcopy/v10/common"
+	"github.com/stretchr/testify/assert"
+)
+
+func init() {
+	suiteManager.RegisterSuite(&AccountSASSuite{})
+}
+
+func TestApplyAccountSAS(t *testing.T) {
+	a := assert.New(t)
+	acct := &AzureAccountResourceManager{accountName: "acct", accountKey: "YWNjb3VudGtleQ=="}
+	start := time.Now().UTC().Truncate(time.Second)
+	opts := AccountSASOptions{
+		Services:      AccountSASServices{Blob: true},
+		ResourceTypes: blobsas.AccountResourceTypes{Container: true, Object: true},
+		StartTime:     start,
+		ExpiryTime:    start.Add(time.Hour),
+	}
+
+	// A blob-only account SAS must match what the SDK signs.
+	skc, err := blobservice.NewSharedKeyCredential(acct.accountName, acct.accountKey)
+	a.NoError(err)
+	expected, err := opts.signatureValues().SignWithSharedKey(skc)
+	a.NoError(err)
+
+	uri := acct.ApplyAccountSAS(nil, common.ELocation.Blob(), opts)
+	a.True(strings.HasPrefix(uri, "https://acct.blob.core.windows.net/?"))
+	parts, err := blobsas.ParseURL(uri)
+	a.NoError(err)
+	a.Equal(expected.Signature(), parts.SAS.Signature())
+	a.Equal("b", parts.SAS.Services())
+	a.Equal("co", parts.SAS.ResourceTypes())
+	a.Equal(blobsas.ProtocolHTTPS, parts.SAS.Protocol())
+
+	// Spanning services only changes ss, and the signature with it.
+	opts.Services.File = true
+	uri = acct.ApplyAccountSAS(nil, common.ELocation.File(), opts)
+	a.True(strings.HasPrefix(uri, "https://acct.file.core.windows.net/?"))
+	_, query, _ := strings.Cut(uri, "?")
+	params, err := url.ParseQuery(query)
+	a.NoError(err)
+	a.Equal("bf", params.Get("ss"))
+	a.NotEqual(expected.Signature(), params.Get("sig"))
+
+	a.Panics(func() {
+		acct.ApplyAccountSAS(nil, common.ELocation.Blob(), AccountSASOptions{ResourceTypes: opts.ResourceTypes})
+	})
+	a.Panics(func() {
+		acct.ApplyAccountSAS(nil, common.ELocation.Blob(), AccountSASOptions{Services: opts.Services})
+	})
+}
+
+func TestApplySASWithAccountSAS(t *testing.T) {
+	a := assert.New(t)
+	acct := &AzureAccountResourceManager{accountName: "acct", accountKey: "YWNjb3VudGtleQ=="}
+	opts := GetURIOptions{AzureOpts: AzureURIOpts{WithSAS: true, AccountSAS: true}}
+
+	for loc, tc := range map[common.Location]struct{ uri, services string }{
+		common.ELocation.Blob():   {"https://acct.blob.core.windows.net/container/blob?snapshot=2024-01-01T00:00:00.0000000Z", "b"},
+		common.ELocation.BlobFS(): {"https://acct.dfs.core.windows.net/filesystem/file?snapshot=2024-01-01T00:00:00.0000000Z", "b"},
+		common.ELocation.File():   {"https://acct.file.core.windows.net/share/file?sharesnapshot=2024-01-01T00:00:00.0000000Z", "f"},
+	} {
+		uri := acct.ApplySAS(tc.uri, loc, opts)
+		base, query, _ := strings.Cut(uri, "?")
+		a.Equal(strings.Split(tc.uri, "?")[0], base, loc.String())
+		params, err := url.ParseQuery(query)
+		a.NoError(err)
+
+		// Existing query parameters survive alongside the SAS.
+		a.Equal("2024-01-01T00:00:00.0000000Z", params.Get(common.Iff(loc == common.ELocation.File(), "sharesnapshot", "snapshot")), loc.String())
+		a.Equal(tc.services, params.Get("ss"), loc.String())
+		a.Equal("sco", params.Get("srt"), loc.String())
+		a.NotEmpty(params.Get("sig"), loc.String())
+
+		start, err := time.Parse(blobsas.TimeFormat, params.Get("st"))
+		a.NoError(err)
+		expiry, err := time.Parse(blobsas.TimeFormat, params.Get("se"))
+		a.NoError(err)
+		a.Equal(sasDefaultLifetime+sasClockSkew, expiry.Sub(start), loc.String())
+	}
+
+	// A SAS already on the URI is replaced rather than duplicated.
+	uri := acct.ApplySAS("https://acct.blob.core.windows.net/container?sig=stale&ss=f", common.ELocation.Blob(), opts)
+	_, query, _ := strings.Cut(uri, "?")
+	params, err := url.ParseQuery(query)
+	a.NoError(err)
+	a.Len(params["sig"], 1)
+	a.NotEqual("stale", params.Get("sig"))
+	a.Equal("b", params.Get("ss"))
+
+	opts.AzureOpts.UseUserDelegation = true
+	a.PanicsWithValue("User delegation can only sign service SAS tokens; account SAS tokens require the account key.", func() {
+		acct.ApplySAS("https://acct.blob.core.windows.net/container", common.ELocation.Blob(), opts)
+	})
+}
+
+type AccountSASSuite struct{}
+
+func (s *AccountSASSuite) Scenario_ContainerCopy(svm *ScenarioVariationManager) {
+	body := NewRandomObjectContentContainer(svm, SizeFromString("1K"))
+	srcObj := CreateResource[ObjectResourceManager](svm, GetRootResource(svm, ResolveVariation(svm, []common.Location{common.ELocation.Blob(), common.ELocation.File()})), ResourceDefinitionObject{
+		ObjectName: pointerTo("test"),
+		Body:       body,
+	})
+	dstContainer := CreateResource[ContainerResourceManager](svm, GetRootResource(svm, common.ELocation.Local()), ResourceDefinitionContainer{})
+
+	// Listing the container needs the container resource type; reading the object needs the object resource type.
+	RunAzCopy(
+		svm,
+		AzCopyCommand{
+			Verb: AzCopyVerbCopy,
+			Targets: []ResourceManager{
+				TryApplySpecificAuthType(srcObj.Parent(), EExplicitCredentialType.SASToken(), svm, CreateAzCopyTargetOptions{
+					AccountSASOptions: &AccountSASOptions{
+						Services:      AccountSASServices{Blob: true, File: true},
+						ResourceTypes: blobsas.AccountResourceTypes{Container: true, Object: true},
+						Permissions:   (&blobsas.AccountPermissions{Read: true, List: true}).String(),
+					},
+				}),
+				dstContainer,
+			},
+			Flags: CopyFlags{
+				CopySyncCommonFlags: CopySyncCommonFlags{
+					Recursive: pointerTo(true),
+				},
+				AsSubdir: pointerTo(false),
+			},
+		})
+
+	ValidateResource[ObjectResourceManager](svm, dstContainer.GetObject(svm, "test", common.EEntityType.File()), ResourceDefinitionObject{
+		Body: body,
+	}, true)
+}
+
+func (s *AccountSASSuite) Scenario_SingleTokenAcrossServices(svm *ScenarioVariationManager) {
+	blobObj := CreateResource[ObjectResourceManager](svm, GetRootResource(svm, common.ELocation.Blob()), ResourceDefinitionObject{
+		Body: NewRandomObjectContentContainer(svm, SizeFromString("1K")),
+	})
+	fileObj := CreateResource[ObjectResourceManager](svm, GetRootResource(svm, common.ELocation.File()), ResourceDefinitionObject{
+		Body: NewRandomObjectContentContainer(svm, SizeFromString("1K")),
+	})
+
+	if svm.Dryrun() {
+		return
+	}
+
+	acct := GetTypeOrAssert[*AzureAccountResourceManager](svm, blobObj.Account())
+	opts := AccountSASOptions{
+		Services:      AccountSASServices{Blob: true, File: true},
+		ResourceTypes: blobsas.AccountResourceTypes{Object: true},
+		Permissions:   (&blobsas.AccountPermissions{Read: true}).String(),
+	}
+	// The token is the same no matter which service URL it's attached to.
+	_, sasToken, _ := strings.Cut(acct.ApplyAccountSAS(svm, common.ELocation.Blob(), opts), "?")
+
+	blobClient, err := blob.NewClientWithNoCredential(blobObj.URI()+"?"+sasToken, nil)
+	svm.NoError("create blob client", err)
+	_, err = blobClient.GetProperties(ctx, nil)
+	svm.NoError("get blob properties with account SAS", err)
+
+	fileClient, err := file.NewClientWithNoCredential(fileObj.URI()+"?"+sasToken, nil)
+	svm.NoError("create file client", err)
+	_, err = fileClient.GetProperties(ctx, nil)
+	svm.NoError("get file properties with account SAS", err)
+
+	// A token scoped to Blob alone must be refused by File.
+	opts.Services = AccountSASServices{Blob: true}
+	_, blobOnlyToken, _ := strings.Cut(acct.ApplyAccountSAS(svm, common.ELocation.Blob(), opts), "?")
+
+	fileClient, err = file.NewClientWithNoCredential(fileObj.URI()+"?"+blobOnlyToken, nil)
+	svm.NoError("create file client", err)
+	_, err = fileClient.GetProperties(ctx, nil)
+	svm.Assert("blob-only account SAS must not grant file access", Not{IsNil{}}, err)
+}
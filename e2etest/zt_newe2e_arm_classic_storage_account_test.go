@@ -0,0 +1,161 @@
+package e2etest
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path"
+	"testing"
+
+	"github.com/Azure/azure-storage-azcopy/v10/common"
+	"github.com/stretchr/testify/assert"
+)
+
+// redirectTransport sends requests meant for management.azure.com to a local server.
+type redirectTransport struct {
+	target *url.URL
+}
+
+func (t redirectTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	r = r.Clone(r.Context())
+	r.URL.Scheme = t.target.Scheme
+	r.URL.Host = t.target.Host
+	return http.DefaultTransport.RoundTrip(r)
+}
+
+func TestARMClassicStorageAccountKeys(t *testing.T) {
+	a := assert.New(t)
+
+	keys := ARMClassicStorageAccountKeys{PrimaryKey: "primary", SecondaryKey: "secondary"}
+	var regenerated []string
+	var srv *httptest.Server
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		a.Equal("2016-11-01", r.URL.Query().Get("api-version"))
+
+		switch r.URL.Path {
+		case "/subscriptions/sub/resourcegroups/rg/providers/Microsoft.ClassicStorage/storageAccounts/classic/listKeys":
+			_ = json.NewEncoder(w).Encode(keys)
+		case "/subscriptions/sub/resourcegroups/rg/providers/Microsoft.ClassicStorage/storageAccounts/classic/regenerateKey":
+			var params ARMClassicStorageAccountRegenerateKeyParams
+			buf, _ := io.ReadAll(r.Body)
+			a.NoError(json.Unmarshal(buf, &params))
+			regenerated = append(regenerated, params.KeyType)
+
+			// Classic key regeneration completes asynchronously.
+			w.Header().Set("Location", srv.URL+"/operations/regenerate?api-version=2016-11-01")
+			w.WriteHeader(http.StatusAccepted)
+		case "/operations/regenerate":
+			keys.PrimaryKey = "regenerated"
+			w.WriteHeader(http.StatusOK)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	target, _ := url.Parse(srv.URL)
+	sa := &ARMClassicStorageAccount{
+		ARMResourceGroup: &ARMResourceGroup{
+			ARMSubscription: &ARMSubscription{
+				ARMClient: &ARMClient{
+					OAuth:      staticAccessToken("token"),
+					HttpClient: &http.Client{Transport: redirectTransport{target: target}},
+				},
+				SubscriptionID: "sub",
+			},
+			ResourceGroupName: "rg",
+		},
+		AccountName: "classic",
+	}
+
+	list, err := sa.GetKeys()
+	a.NoError(err)
+	a.Equal([]ARMStorageAccountKey{
+		{KeyName: "key1", Permissions: ARMStorageAccountKeyPermissionFull, Value: "primary"},
+		{KeyName: "key2", Permissions: ARMStorageAccountKeyPermissionFull, Value: "secondary"},
+	}, list.Keys)
+
+	list, err = sa.RegenerateKey(ARMClassicStorageAccountKeyTypePrimary)
+	a.NoError(err)
+	a.Equal([]string{ARMClassicStorageAccountKeyTypePrimary}, regenerated)
+	a.Equal("regenerated", list.Keys[0].Value)
+
+	// The resource manager hands back the classic client for classic accounts.
+	manager, err := sa.GetResourceManager()
+	a.NoError(err)
+	a.Equal(EAccountType.Classic(), manager.AccountType())
+	a.Same(sa, manager.ManagementClient())
+
+	a.Nil((&AzureAccountResourceManager{accountType: EAccountType.Classic()}).ManagementClient())
+}
+
+func TestARMClassicStorageAccountCreate(t *testing.T) {
+	a := assert.New(t)
+	fa := NewFrameworkAsserter(t)
+
+	var created *ARMClassicStorageAccountCreateParams
+	var accountPath string
+	var srv *httptest.Server
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/operations/create":
+			w.WriteHeader(http.StatusOK)
+		case r.Method == http.MethodPut:
+			a.Equal("2016-11-01", r.URL.Query().Get("api-version"))
+			accountPath = r.URL.Path
+			created = &ARMClassicStorageAccountCreateParams{}
+			buf, _ := io.ReadAll(r.Body)
+			a.NoError(json.Unmarshal(buf, created))
+
+			// Classic accounts provision asynchronously.
+			w.Header().Set("Location", srv.URL+"/operations/create?api-version=2016-11-01")
+			w.WriteHeader(http.StatusAccepted)
+		case r.URL.Path != accountPath+"/listKeys" && r.URL.Path != accountPath:
+			w.WriteHeader(http.StatusNotFound)
+		case r.Method == http.MethodGet:
+			var props ARMClassicStorageAccountProperties
+			props.Name = path.Base(accountPath)
+			props.Location = created.Location
+			props.Properties.AccountType = created.Properties.AccountType
+			props.Properties.ProvisioningState = "Succeeded"
+			_ = json.NewEncoder(w).Encode(props)
+		default:
+			_ = json.NewEncoder(w).Encode(ARMClassicStorageAccountKeys{PrimaryKey: "cHJpbWFyeQ==", SecondaryKey: "c2Vjb25kYXJ5"})
+		}
+	}))
+	defer srv.Close()
+
+	target, _ := url.Parse(srv.URL)
+	defer func(rg *ARMResourceGroup) { CommonARMResourceGroup = rg }(CommonARMResourceGroup)
+	CommonARMResourceGroup = &ARMResourceGroup{
+		ARMSubscription: &ARMSubscription{
+			ARMClient: &ARMClient{
+				OAuth:      staticAccessToken("token"),
+				HttpClient: &http.Client{Transport: redirectTransport{target: target}},
+			},
+			SubscriptionID: "sub",
+		},
+		ResourceGroupName: "rg",
+	}
+
+	acct := createAccount(fa, EAccountType.Classic(), &CreateAccountOptions{
+		ClassicParamMutator: func(params *ARMClassicStorageAccountCreateParams) {
+			params.Properties.AccountType = ARMClassicStorageAccountTypeStandardGRS
+		},
+	})
+	a.Equal("/subscriptions/sub/resourcegroups/rg/providers/Microsoft.ClassicStorage/storageAccounts/"+acct.AccountName(), accountPath)
+	a.Equal(ARMClassicStorageAccountTypeStandardGRS, created.Properties.AccountType)
+
+	// Scenarios can branch on the type; classic accounts have no hierarchical namespace.
+	a.Equal(EAccountType.Classic(), acct.AccountType())
+	a.Equal("cHJpbWFyeQ==", acct.accountKey)
+	a.Equal([]common.Location{common.ELocation.Blob(), common.ELocation.File()}, acct.AvailableServices())
+
+	classic, ok := acct.ManagementClient().(*ARMClassicStorageAccount)
+	a.True(ok)
+	props, err := classic.GetProperties()
+	a.NoError(err)
+	a.Equal(ARMClassicStorageAccountTypeStandardGRS, props.Properties.AccountType)
+}
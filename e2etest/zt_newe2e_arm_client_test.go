@@ -0,0 +1,876 @@
+package e2etest
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-storage-azcopy/v10/common"
+	"github.com/stretchr/testify/assert"
+)
+
+type staticAccessToken string
+
+func (t staticAccessToken) FreshToken() (string, error) { return string(t), nil }
+func (t staticAccessToken) CurrentToken() string        { return string(t) }
+
+// testARMSubject points an ARMClient at a local server instead of management.azure.com.
+type testARMSubject struct {
+	*ARMClient
+	uri url.URL
+}
+
+func (s testARMSubject) ManagementURI() url.URL { return s.uri }
+
+func newTestARMSubject(t *testing.T, handler http.HandlerFunc) testARMSubject {
+	srv := httptest.NewServer(handler)
+	t.Cleanup(srv.Close)
+
+	uri, err := url.Parse(srv.URL + "/")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return testARMSubject{
+		ARMClient: &ARMClient{
+			OAuth:       staticAccessToken("token"),
+			HttpClient:  srv.Client(),
+			MaxAttempts: 4,
+			RetryDelay:  time.Millisecond,
+		},
+		uri: *uri,
+	}
+}
+
+func TestARMPerformRequestRetriesThrottling(t *testing.T) {
+	a := assert.New(t)
+
+	type props struct {
+		Name string `json:"name"`
+	}
+
+	var calls int
+	var bodies []string
+	subject := newTestARMSubject(t, func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		buf, _ := io.ReadAll(r.Body)
+		bodies = append(bodies, string(buf))
+
+		if calls <= 2 {
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+
+		_ = json.NewEncoder(w).Encode(props{Name: "rg"})
+	})
+
+	var out props
+	resp, err := PerformRequest(context.Background(), subject, ARMRequestSettings{
+		Method: http.MethodPut,
+		Body:   props{Name: "rg"},
+	}, &out)
+	a.NoError(err)
+	a.Nil(resp)
+	a.Equal("rg", out.Name)
+	a.Equal(3, calls)
+
+	// The body must be replayed in full on every attempt.
+	a.Len(bodies, 3)
+	for _, b := range bodies {
+		a.JSONEq(`{"name":"rg"}`, b)
+	}
+}
+
+func TestARMPerformRequestRetryLimits(t *testing.T) {
+	a := assert.New(t)
+
+	var calls int
+	subject := newTestARMSubject(t, func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.WriteHeader(http.StatusServiceUnavailable)
+	})
+
+	// Unmarked POSTs are sent once.
+	_, err := PerformRequest[any](context.Background(), subject, ARMRequestSettings{Method: http.MethodPost}, nil)
+	a.ErrorContains(err, "1 attempts")
+	a.Equal(1, calls)
+
+	// Marked POSTs and idempotent methods give up after MaxAttempts.
+	calls = 0
+	_, err = PerformRequest[any](context.Background(), subject, ARMRequestSettings{Method: http.MethodPost, Retryable: true}, nil)
+	a.ErrorContains(err, "4 attempts")
+	a.Equal(4, calls)
+
+	calls = 0
+	_, err = PerformRequest[any](context.Background(), subject, ARMRequestSettings{Method: http.MethodDelete}, nil)
+	a.ErrorContains(err, "resp code 503, 4 attempts")
+	a.Equal(4, calls)
+}
+
+func TestARMRequestMaxAttemptsAndRequestIDs(t *testing.T) {
+	a := assert.New(t)
+
+	var calls int
+	subject := newTestARMSubject(t, func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.Header().Set("x-ms-correlation-request-id", "correlation")
+		w.Header().Set("x-ms-request-id", "request")
+		w.WriteHeader(http.StatusTooManyRequests)
+	})
+
+	// The request's own limit wins over the client's.
+	_, err := PerformRequest[any](context.Background(), subject, ARMRequestSettings{Method: http.MethodPut, MaxAttempts: 2}, nil)
+	a.ErrorContains(err, "resp code 429, 2 attempts) (correlation ID correlation, request ID request)")
+	a.Equal(2, calls)
+
+	// ... but doesn't make an unmarked POST retryable.
+	calls = 0
+	_, err = PerformRequest[any](context.Background(), subject, ARMRequestSettings{Method: http.MethodPost, MaxAttempts: 2}, nil)
+	a.ErrorContains(err, "1 attempts")
+	a.Equal(1, calls)
+}
+
+func TestARMRetryDelayHonorsRetryAfter(t *testing.T) {
+	a := assert.New(t)
+	c := &ARMClient{RetryDelay: time.Millisecond}
+
+	resp := &http.Response{StatusCode: http.StatusTooManyRequests, Header: http.Header{}}
+	resp.Header.Set("Retry-After", "7")
+	a.Equal(7*time.Second, c.retryDelay(1, resp))
+
+	resp.Header.Set("Retry-After", time.Now().Add(time.Hour).UTC().Format(http.TimeFormat))
+	a.InDelta(float64(time.Hour), float64(c.retryDelay(1, resp)), float64(time.Minute))
+
+	resp.Header.Set("Retry-After", time.Now().Add(-time.Hour).UTC().Format(http.TimeFormat))
+	a.Equal(time.Duration(0), c.retryDelay(1, resp))
+
+	// 5xx without Retry-After backs off exponentially, jittered over the upper half.
+	resp = &http.Response{StatusCode: http.StatusInternalServerError, Header: http.Header{}}
+	for attempt, ceiling := range []time.Duration{time.Millisecond, 2 * time.Millisecond, 4 * time.Millisecond} {
+		delay := c.retryDelay(attempt+1, resp)
+		a.GreaterOrEqual(delay, ceiling/2)
+		a.LessOrEqual(delay, ceiling)
+	}
+}
+
+func TestARMPerformPagedRequestFollowsNextLink(t *testing.T) {
+	a := assert.New(t)
+
+	type account struct {
+		Name string `json:"name"`
+	}
+
+	var subject testARMSubject
+	var queries []string
+	subject = newTestARMSubject(t, func(w http.ResponseWriter, r *http.Request) {
+		queries = append(queries, r.URL.RawQuery)
+		a.Equal("Bearer token", r.Header.Get("Authorization"))
+		a.Equal("return=minimal", r.Header.Get("Prefer"))
+
+		page := ARMPage[account]{}
+		if r.URL.Query().Get("skipToken") == "" {
+			page.Value = []account{{Name: "one"}, {Name: "two"}}
+			next := subject.uri
+			next.Path = r.URL.Path
+			next.RawQuery = "api-version=2023-01-01&skipToken=page2"
+			page.NextLink = next.String()
+		} else {
+			page.Value = []account{{Name: "three"}}
+		}
+		_ = json.NewEncoder(w).Encode(page)
+	})
+
+	var accounts []account
+	err := PerformPagedRequest(context.Background(), subject, ARMRequestSettings{
+		Method:        http.MethodGet,
+		PathExtension: "providers/Microsoft.Storage/storageAccounts",
+		Query:         url.Values{"api-version": []string{"2023-01-01"}, "$filter": []string{"kind eq 'StorageV2'"}},
+		Headers:       http.Header{"Prefer": []string{"return=minimal"}},
+	}, &accounts)
+	a.NoError(err)
+	a.Equal([]account{{Name: "one"}, {Name: "two"}, {Name: "three"}}, accounts)
+	// The filter is carried over to the next page, without repeating the api-version nextLink already has.
+	filter := url.QueryEscape("kind eq 'StorageV2'")
+	a.Equal([]string{"%24filter=" + filter + "&api-version=2023-01-01", "%24filter=" + filter + "&api-version=2023-01-01&skipToken=page2"}, queries)
+
+	// Cancelling between pages keeps what was read so far.
+	ctx, cancel := context.WithCancel(context.Background())
+	queries = nil
+	accounts = nil
+	subject.ARMClient.HttpClient.Transport = cancelAfterResponse{RoundTripper: subject.ARMClient.HttpClient.Transport, cancel: cancel}
+	err = PerformPagedRequest(ctx, subject, ARMRequestSettings{Method: http.MethodGet, Headers: http.Header{"Prefer": []string{"return=minimal"}}}, &accounts)
+	a.ErrorIs(err, context.Canceled)
+	a.Len(queries, 1)
+	a.Len(accounts, 2)
+}
+
+// cancelAfterResponse cancels a context once the first response has come back.
+type cancelAfterResponse struct {
+	http.RoundTripper
+	cancel context.CancelFunc
+}
+
+func (c cancelAfterResponse) RoundTrip(r *http.Request) (*http.Response, error) {
+	resp, err := c.RoundTripper.RoundTrip(r)
+	c.cancel()
+	return resp, err
+}
+
+func TestARMAsyncResponsePollWithCallback(t *testing.T) {
+	a := assert.New(t)
+
+	type props struct {
+		ProvisioningState string `json:"provisioningState"`
+		Name              string `json:"name"`
+	}
+
+	var subject testARMSubject
+	var polls int
+	var pollTimes []time.Time
+	subject = newTestARMSubject(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPut {
+			op := subject.uri
+			op.Path = "/operation"
+			w.Header().Set("Azure-AsyncOperation", op.String())
+			w.WriteHeader(http.StatusAccepted)
+			return
+		}
+
+		a.Equal("/operation", r.URL.Path)
+		a.Equal("Bearer token", r.Header.Get("Authorization"))
+		polls++
+		pollTimes = append(pollTimes, time.Now())
+
+		switch polls {
+		case 1:
+			w.Header().Set("Retry-After", "1")
+			_, _ = w.Write([]byte(`{"properties":{"provisioningState":"InProgress"}}`))
+		case 2:
+			_, _ = w.Write([]byte(`{"properties":{"provisioningState":"ResolvingDNS"}}`))
+		default:
+			_, _ = w.Write([]byte(`{"properties":{"provisioningState":"Succeeded","name":"acct"}}`))
+		}
+	})
+
+	var out struct {
+		Properties props `json:"properties"`
+	}
+	armResp, err := PerformRequest(context.Background(), subject, ARMRequestSettings{Method: http.MethodPut, DeferAsync: true}, &out)
+	a.NoError(err)
+	a.NotNil(armResp)
+	a.Equal(0, polls) // nothing happens until we poll
+
+	var states []string
+	final, err := armResp.PollWithCallback(context.Background(), time.Millisecond, func(state string) {
+		states = append(states, state)
+	})
+	a.NoError(err)
+	a.Equal([]string{"InProgress", "ResolvingDNS", "Succeeded"}, states)
+	a.Equal(ARMStatusSucceeded, armResp.Status)
+	a.Same(&out, final)
+	a.Equal("acct", out.Properties.Name)
+
+	// Retry-After wins over the requested interval.
+	a.Len(pollTimes, 3)
+	a.GreaterOrEqual(pollTimes[1].Sub(pollTimes[0]), time.Second)
+	a.Less(pollTimes[2].Sub(pollTimes[1]), time.Second)
+}
+
+func TestARMAsyncResponsePollWithCallbackFailure(t *testing.T) {
+	a := assert.New(t)
+
+	subject := newTestARMSubject(t, func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"name":"op","status":"Failed","error":{"code":"QuotaExceeded","message":"too many accounts"}}`))
+	})
+	op := subject.uri
+	op.Path = "/operation"
+
+	armResp := NewARMAsyncResponse[any](subject.ARMClient, op.String(), nil)
+	_, err := armResp.PollWithCallback(context.Background(), time.Millisecond, nil)
+	a.ErrorContains(err, "QuotaExceeded: too many accounts")
+	a.ErrorAs(err, &ARMAsyncError{})
+	a.Equal(ARMStatusFailed, armResp.Status)
+
+	// Resolving without a callback leaves the failure for the caller to inspect.
+	resolved, err := ResolveAzureAsyncOperation[any](context.Background(), subject.OAuth, op.String(), nil, ARMPollOptions{})
+	a.NoError(err)
+	a.Equal(ARMStatusFailed, resolved.Status)
+
+	// A cancelled context stops polling.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = NewARMAsyncResponse[any](subject.ARMClient, op.String(), nil).PollWithCallback(ctx, time.Hour, nil)
+	a.ErrorIs(err, context.Canceled)
+}
+
+func TestARMAsyncResponseRetriesThrottledPolls(t *testing.T) {
+	a := assert.New(t)
+
+	var polls atomic.Int32
+	subject := newTestARMSubject(t, func(w http.ResponseWriter, r *http.Request) {
+		n := polls.Add(1)
+		w.Header().Set("x-ms-correlation-request-id", "correlation")
+		if n <= 2 {
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		_, _ = w.Write([]byte(`{"name":"op","status":"Succeeded"}`))
+	})
+	op := subject.uri
+	op.Path = "/operation"
+
+	var states []string
+	armResp := NewARMAsyncResponse[any](subject.ARMClient, op.String(), nil)
+	_, err := armResp.PollWithCallback(context.Background(), time.Millisecond, func(state string) {
+		states = append(states, state)
+	})
+	a.NoError(err)
+	a.EqualValues(3, polls.Load())
+	a.Equal([]string{ARMStatusSucceeded}, states) // throttled polls carry no state
+
+	// Persistent throttling gives up after MaxAttempts, naming the request for follow-up.
+	polls.Store(-100)
+	_, err = NewARMAsyncResponse[any](subject.ARMClient, op.String(), nil).PollWithCallback(context.Background(), time.Millisecond, nil)
+	a.ErrorContains(err, "resp code 429, 4 attempts) (correlation ID correlation)")
+	a.EqualValues(-96, polls.Load())
+}
+
+func TestARMAsyncResponseRetriesDroppedPolls(t *testing.T) {
+	a := assert.New(t)
+
+	var polls atomic.Int32
+	subject := newTestARMSubject(t, func(w http.ResponseWriter, r *http.Request) {
+		n := polls.Add(1)
+		if n <= 2 {
+			conn, _, err := w.(http.Hijacker).Hijack()
+			if err == nil {
+				_ = conn.Close() // the client sees a transport error, not a response
+			}
+			return
+		}
+		_, _ = w.Write([]byte(`{"name":"op","status":"Succeeded"}`))
+	})
+	op := subject.uri
+	op.Path = "/operation"
+
+	_, err := NewARMAsyncResponse[any](subject.ARMClient, op.String(), nil).PollWithCallback(context.Background(), time.Millisecond, nil)
+	a.NoError(err)
+	a.EqualValues(3, polls.Load())
+
+	// A connection that keeps dropping gives up after MaxAttempts.
+	polls.Store(-100)
+	_, err = NewARMAsyncResponse[any](subject.ARMClient, op.String(), nil).PollWithCallback(context.Background(), time.Millisecond, nil)
+	a.ErrorContains(err, "(4 attempts)")
+	a.GreaterOrEqual(polls.Load(), int32(-96)) // net/http may transparently retry a dropped GET itself
+}
+
+func TestARMPerformRequestCancelsPolling(t *testing.T) {
+	a := assert.New(t)
+
+	var polls int
+	var subject testARMSubject
+	subject = newTestARMSubject(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/hang":
+			<-r.Context().Done() // never answers; only the client giving up ends this
+		case "/operation":
+			polls++
+			w.Header().Set("Retry-After", "60")
+			_, _ = w.Write([]byte(`{"status":"InProgress"}`))
+		default:
+			op := subject.uri
+			op.Path = "/operation"
+			w.Header().Set("Azure-AsyncOperation", op.String())
+			w.WriteHeader(http.StatusAccepted)
+		}
+	})
+
+	op := subject.uri
+	op.Path = "/operation"
+
+	// Cancelling mid-poll returns right away, rather than sitting out the Retry-After.
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(100*time.Millisecond, cancel)
+	start := time.Now()
+	_, err := PerformRequest[any](ctx, subject, ARMRequestSettings{Method: http.MethodPut}, nil)
+	a.ErrorIs(err, context.Canceled)
+	a.ErrorContains(err, op.String())
+	a.Less(time.Since(start), 5*time.Second)
+	a.Equal(1, polls)
+
+	// MaxWait bounds resolution on its own, while Interval paces the polls.
+	polls = 0
+	start = time.Now()
+	_, err = PerformRequest[any](context.Background(), subject, ARMRequestSettings{
+		Method: http.MethodPut,
+		Poll:   ARMPollOptions{MaxWait: 100 * time.Millisecond},
+	}, nil)
+	a.ErrorIs(err, context.DeadlineExceeded)
+	a.ErrorContains(err, op.String())
+	a.Less(time.Since(start), 5*time.Second)
+
+	// A hung request is bounded too.
+	ctx, cancel = context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start = time.Now()
+	_, err = PerformRequest[any](ctx, subject, ARMRequestSettings{Method: http.MethodGet, PathExtension: "hang"}, nil)
+	a.ErrorIs(err, context.DeadlineExceeded)
+	a.Less(time.Since(start), 5*time.Second)
+}
+
+func TestARMResolveAzureAsyncOperationPollOptions(t *testing.T) {
+	a := assert.New(t)
+
+	var polls int
+	subject := newTestARMSubject(t, func(w http.ResponseWriter, r *http.Request) {
+		polls++
+		if polls < 3 {
+			_, _ = w.Write([]byte(`{"name":"op","status":"InProgress"}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"name":"op","status":"Succeeded"}`))
+	})
+	op := subject.uri
+	op.Path = "/operation"
+
+	// Without an interval, this would back off for 2s, then 4s.
+	start := time.Now()
+	resolved, err := ResolveAzureAsyncOperation[any](context.Background(), subject.OAuth, op.String(), nil, ARMPollOptions{Interval: time.Millisecond})
+	a.NoError(err)
+	a.Equal(ARMStatusSucceeded, resolved.Status)
+	a.Equal(3, polls)
+	a.Less(time.Since(start), 2*time.Second)
+
+	// A cancelled context aborts the poll, naming the operation.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = ResolveAzureAsyncOperation[any](ctx, subject.OAuth, op.String(), nil, ARMPollOptions{Interval: time.Millisecond})
+	a.ErrorIs(err, context.Canceled)
+	a.ErrorContains(err, op.String())
+}
+
+func TestARMUnimplementedStructGet(t *testing.T) {
+	a := assert.New(t)
+
+	var outer struct {
+		Encryption ARMUnimplementedStruct `json:"encryption"`
+	}
+	a.NoError(json.Unmarshal([]byte(`{"encryption":{"keySource":{"vault":{"uri":"https://vault","version":3}},"type":"EncryptionAtRestWithPlatformKey"}}`), &outer))
+
+	var uri string
+	a.NoError(outer.Encryption.Get([]string{"keySource", "vault", "uri"}, &uri))
+	a.Equal("https://vault", uri)
+
+	var encType string
+	a.NoError(outer.Encryption.Get([]string{"type"}, &encType))
+	a.Equal("EncryptionAtRestWithPlatformKey", encType)
+
+	// An empty path fetches the whole thing.
+	var whole map[string]any
+	a.NoError(outer.Encryption.Get(nil, &whole))
+	a.Contains(whole, "keySource")
+
+	// It also round-trips as raw JSON, rather than base64.
+	buf, err := json.Marshal(outer)
+	a.NoError(err)
+	a.JSONEq(`{"encryption":{"keySource":{"vault":{"uri":"https://vault","version":3}},"type":"EncryptionAtRestWithPlatformKey"}}`, string(buf))
+
+	a.EqualError(outer.Encryption.Get([]string{"keySource", "missing", "uri"}, &uri), `key "keySource/missing" not found`)
+	a.ErrorContains(outer.Encryption.Get([]string{"type", "inner"}, &uri), `value at "type" is not an object or array`)
+	a.ErrorContains(outer.Encryption.Get([]string{"keySource", "vault", "version"}, &uri), `failed to unmarshal value at "keySource/vault/version"`)
+	a.EqualError(outer.Encryption.Get([]string{"type"}, encType), "out must be a pointer")
+}
+
+func TestARMListStorageAccountsFollowsThreePages(t *testing.T) {
+	a := assert.New(t)
+
+	var subject testARMSubject
+	var pages []string
+	subject = newTestARMSubject(t, func(w http.ResponseWriter, r *http.Request) {
+		a.Equal("/subscriptions/sub/resourcegroups/rg/providers/Microsoft.Storage/storageAccounts", r.URL.Path)
+		a.Equal("2023-01-01", r.URL.Query().Get("api-version"))
+		token := r.URL.Query().Get("skipToken")
+		pages = append(pages, token)
+
+		page := ARMPage[ARMStorageAccountProperties]{}
+		switch token {
+		case "":
+			page.Value = []ARMStorageAccountProperties{{Name: "one"}}
+			page.NextLink = "page2"
+		case "page2":
+			page.Value = []ARMStorageAccountProperties{{Name: "two"}, {Name: "three"}}
+			page.NextLink = "page3"
+		case "page3":
+			page.Value = []ARMStorageAccountProperties{{Name: "four"}}
+		}
+
+		if page.NextLink != "" {
+			next := subject.uri
+			next.Path = r.URL.Path
+			next.RawQuery = "api-version=2023-01-01&skipToken=" + page.NextLink
+			page.NextLink = next.String()
+		}
+		_ = json.NewEncoder(w).Encode(page)
+	})
+
+	rg := &ARMResourceGroup{
+		ARMSubscription:   &ARMSubscription{ARMClient: subject.ARMClient, SubscriptionID: "sub"},
+		ResourceGroupName: "rg",
+	}
+	rg.ARMClient.HttpClient = &http.Client{Transport: redirectTransport{target: &subject.uri}}
+
+	accounts, err := rg.ListStorageAccounts(context.Background())
+	a.NoError(err)
+	var names []string
+	for _, v := range accounts {
+		names = append(names, v.Name)
+	}
+	a.Equal([]string{"one", "two", "three", "four"}, names)
+	a.Equal([]string{"", "page2", "page3"}, pages)
+}
+
+func TestARMPerformPagedRequestWithHandlerLimits(t *testing.T) {
+	a := assert.New(t)
+
+	var subject testARMSubject
+	var calls int
+	subject = newTestARMSubject(t, func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		next := subject.uri
+		next.Path = r.URL.Path
+		if r.URL.Path == "/loop" {
+			next.RawQuery = "skipToken=" + common.Iff(r.URL.Query().Get("skipToken") == "a", "b", "a")
+		} else {
+			next.RawQuery = "skipToken=" + strconv.Itoa(calls)
+		}
+		_ = json.NewEncoder(w).Encode(ARMPage[string]{Value: []string{r.URL.RawQuery}, NextLink: next.String()})
+	})
+
+	// a -> b -> a would go on forever.
+	var values []json.RawMessage
+	err := PerformPagedRequestWithHandler(context.Background(), subject, ARMRequestSettings{Method: http.MethodGet, PathExtension: "loop"}, func(value json.RawMessage) error {
+		values = append(values, value)
+		return nil
+	})
+	a.ErrorContains(err, "page 3 links back to an already listed page")
+	a.Equal([]json.RawMessage{json.RawMessage(`[""]`), json.RawMessage(`["skipToken=a"]`), json.RawMessage(`["skipToken=b"]`)}, values)
+
+	// So would a service that never stops handing out fresh links.
+	calls = 0
+	var listed []string
+	err = PerformPagedRequest(context.Background(), subject, ARMRequestSettings{Method: http.MethodGet, PathExtension: "endless", MaxPages: 5}, &listed)
+	a.EqualError(err, "listing exceeded 5 pages")
+	a.Len(listed, 5)
+	a.Equal(5, calls)
+
+	// Handler errors stop the listing.
+	calls = 0
+	err = PerformPagedRequestWithHandler(context.Background(), subject, ARMRequestSettings{Method: http.MethodGet}, func(value json.RawMessage) error {
+		return errors.New("boom")
+	})
+	a.EqualError(err, "failed to handle page 1: boom")
+	a.Equal(1, calls)
+}
+
+func TestARMPerformDeleteRequestToleratesMissingResources(t *testing.T) {
+	a := assert.New(t)
+
+	var methods []string
+	subject := newTestARMSubject(t, func(w http.ResponseWriter, r *http.Request) {
+		methods = append(methods, r.Method)
+		switch r.URL.Path {
+		case "/present":
+			w.WriteHeader(http.StatusOK)
+		case "/nocontent":
+			w.WriteHeader(http.StatusNoContent)
+		case "/broken":
+			w.Header().Set("x-ms-request-id", "request")
+			w.WriteHeader(http.StatusConflict)
+		default:
+			w.Header().Set("x-ms-request-id", "request")
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"error":{"code":"ResourceNotFound"}}`))
+		}
+	})
+
+	existed, err := PerformDeleteRequest(context.Background(), subject, ARMRequestSettings{PathExtension: "present"})
+	a.NoError(err)
+	a.True(existed)
+
+	// DELETE -> 404
+	existed, err = PerformDeleteRequest(context.Background(), subject, ARMRequestSettings{PathExtension: "missing"})
+	a.NoError(err)
+	a.False(existed)
+
+	// DELETE -> 204
+	existed, err = PerformDeleteRequest(context.Background(), subject, ARMRequestSettings{PathExtension: "nocontent"})
+	a.NoError(err)
+	a.False(existed)
+
+	// Other failures still fail.
+	existed, err = PerformDeleteRequest(context.Background(), subject, ARMRequestSettings{PathExtension: "broken"})
+	a.ErrorContains(err, "resp code 409")
+	a.False(existed)
+
+	a.Equal([]string{http.MethodDelete, http.MethodDelete, http.MethodDelete, http.MethodDelete}, methods)
+
+	// A plain request still fails on 404, but recognizably so.
+	_, err = PerformRequest[any](context.Background(), subject, ARMRequestSettings{Method: http.MethodGet, PathExtension: "missing"}, nil)
+	a.ErrorIs(err, ErrARMResourceNotFound)
+	a.ErrorContains(err, "(request ID request)")
+}
+
+func TestARMPerformHeadRequest(t *testing.T) {
+	a := assert.New(t)
+
+	subject := newTestARMSubject(t, func(w http.ResponseWriter, r *http.Request) {
+		a.Equal(http.MethodHead, r.Method)
+		if r.URL.Path != "/present" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		w.Header().Set("x-ms-request-id", "request")
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	headers, err := PerformHeadRequest(context.Background(), subject, ARMRequestSettings{PathExtension: "present"})
+	a.NoError(err)
+	a.Equal("request", headers.Get("x-ms-request-id"))
+
+	_, err = PerformHeadRequest(context.Background(), subject, ARMRequestSettings{PathExtension: "missing"})
+	a.ErrorIs(err, ErrARMResourceNotFound)
+}
+
+func TestARMUnimplementedStructGetArrays(t *testing.T) {
+	a := assert.New(t)
+
+	listKeys := ARMUnimplementedStruct(`{"keys": [{"keyName": "key1", "value": "one"}, {"keyName": "key2", "value": "two", "tags": ["a", "b"]}]}`)
+
+	var value string
+	a.NoError(listKeys.Get([]string{"keys", "1", "value"}, &value))
+	a.Equal("two", value)
+
+	var tag string
+	a.NoError(listKeys.Get([]string{"keys", "1", "tags", "0"}, &tag))
+	a.Equal("a", tag)
+
+	var keys []map[string]any
+	a.NoError(listKeys.Get([]string{"keys"}, &keys))
+	a.Len(keys, 2)
+
+	a.EqualError(listKeys.Get([]string{"keys", "2", "value"}, &value), `key "keys/2" not found`)
+	a.EqualError(listKeys.Get([]string{"keys", "-1"}, &value), `key "keys/-1" not found`)
+	a.EqualError(listKeys.Get([]string{"keys", "first"}, &value), `value at "keys" is an array, so "first" must be an index`)
+	a.EqualError(listKeys.Get([]string{"keys", "0", "value", "x"}, &value), `value at "keys/0/value" is not an object or array`)
+	a.ErrorContains(listKeys.Get([]string{"keys", "0"}, &value), `failed to unmarshal value at "keys/0"`)
+}
+
+func TestARMPerformRequestWithResponse(t *testing.T) {
+	a := assert.New(t)
+
+	var subject testARMSubject
+	subject = newTestARMSubject(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("x-ms-request-id", "request-"+r.URL.Path[1:])
+		switch r.URL.Path {
+		case "/resource":
+			w.Header().Set("ETag", `"etag"`)
+			_, _ = w.Write([]byte(`{"name":"resource"}`))
+		case "/async":
+			op := subject.uri
+			op.Path = "/operation"
+			w.Header().Set("Location", op.String())
+			w.WriteHeader(http.StatusAccepted)
+		case "/operation":
+			_, _ = w.Write([]byte(`{"status":"Succeeded"}`))
+		default:
+			w.WriteHeader(http.StatusConflict)
+			_, _ = w.Write([]byte(`{"error":{"code":"Conflict"}}`))
+		}
+	})
+
+	var target struct {
+		Name string `json:"name"`
+	}
+	armResp, resp, err := PerformRequestWithResponse(context.Background(), subject, ARMRequestSettings{Method: http.MethodGet, PathExtension: "resource"}, &target)
+	a.NoError(err)
+	a.Nil(armResp)
+	a.Equal("resource", target.Name)
+	a.Equal(http.StatusOK, resp.StatusCode)
+	a.Equal(`"etag"`, resp.Header.Get("ETag"))
+	a.Equal("request-resource", resp.RequestID())
+	a.JSONEq(`{"name":"resource"}`, string(resp.Body))
+
+	// The initial 202 is what's returned, not the operation.
+	asyncResp, resp, err := PerformRequestWithResponse[any](context.Background(), subject, ARMRequestSettings{Method: http.MethodPut, PathExtension: "async", DeferAsync: true}, nil)
+	a.NoError(err)
+	a.NotNil(asyncResp)
+	a.Equal(http.StatusAccepted, resp.StatusCode)
+	a.Equal("request-async", resp.RequestID())
+	a.Contains(resp.Header.Get("Location"), "/operation")
+	a.Empty(resp.Body)
+
+	// Failures keep the response, so the request ID can be reported.
+	_, resp, err = PerformRequestWithResponse[any](context.Background(), subject, ARMRequestSettings{Method: http.MethodPut, PathExtension: "conflict"}, nil)
+	a.Error(err)
+	a.Equal(http.StatusConflict, resp.StatusCode)
+	a.Equal("request-conflict", resp.RequestID())
+	a.JSONEq(`{"error":{"code":"Conflict"}}`, string(resp.Body))
+}
+
+func TestCleanupExpiredResources(t *testing.T) {
+	a := assert.New(t)
+
+	expired := time.Now().Add(-time.Hour).UTC().Format(time.RFC3339)
+	unexpired := time.Now().Add(time.Hour).UTC().Format(time.RFC3339)
+	groups := []ARMResourceGroupInfo{
+		{Name: "azcopy-newe2e-expired", Tags: map[string]string{ARMDeleteAfterTag: expired}},
+		{Name: "azcopy-newe2e-fresh", Tags: map[string]string{ARMDeleteAfterTag: unexpired}},
+		{Name: "azcopy-newe2e-untagged"},
+		{Name: "azcopy-newe2e-mangled", Tags: map[string]string{ARMDeleteAfterTag: "tomorrow"}},
+		{Name: "someone-elses-group", Tags: map[string]string{ARMDeleteAfterTag: expired}},
+		{Name: "azcopy-newe2e-stuck", Tags: map[string]string{ARMDeleteAfterTag: expired}},
+	}
+
+	var subject testARMSubject
+	var deletes []string
+	var polls int
+	subject = newTestARMSubject(t, func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == "/subscriptions/sub/resourcegroups":
+			a.Equal("2021-04-01", r.URL.Query().Get("api-version"))
+			_ = json.NewEncoder(w).Encode(ARMPage[ARMResourceGroupInfo]{Value: groups})
+		case r.Method == http.MethodDelete:
+			name := strings.TrimPrefix(r.URL.Path, "/subscriptions/sub/resourcegroups/")
+			deletes = append(deletes, name)
+			if name == "azcopy-newe2e-stuck" {
+				w.WriteHeader(http.StatusConflict)
+				return
+			}
+
+			op := subject.uri
+			op.Path = "/operation"
+			w.Header().Set("Location", op.String())
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusAccepted)
+		case r.URL.Path == "/operation":
+			polls++
+			w.WriteHeader(http.StatusOK) // the deletion is done
+		default:
+			w.WriteHeader(http.StatusBadRequest)
+		}
+	})
+	subject.ARMClient.HttpClient = &http.Client{Transport: redirectTransport{target: &subject.uri}}
+
+	deleted, err := CleanupExpiredResources(subject.ARMClient, "sub")
+	a.ErrorContains(err, "failed to delete 1 expired resource groups")
+	a.ErrorContains(err, "azcopy-newe2e-stuck")
+	a.Equal([]string{"azcopy-newe2e-expired"}, deleted)
+	a.Equal([]string{"azcopy-newe2e-expired", "azcopy-newe2e-stuck"}, deletes)
+	a.Equal(1, polls) // the deletion was waited on
+}
+
+func TestListResourceGroupsWithTagFilter(t *testing.T) {
+	a := assert.New(t)
+
+	var filters []string
+	var subject testARMSubject
+	subject = newTestARMSubject(t, func(w http.ResponseWriter, r *http.Request) {
+		a.Equal("/subscriptions/sub/resourcegroups", r.URL.Path)
+		filters = append(filters, r.URL.Query().Get("$filter"))
+
+		if r.URL.Query().Get("page") == "" { // the list is paged
+			next := subject.uri
+			next.Path = r.URL.Path
+			next.RawQuery = r.URL.Query().Encode() + "&page=2"
+			_ = json.NewEncoder(w).Encode(ARMPage[ARMResourceGroupInfo]{Value: []ARMResourceGroupInfo{{Name: "rg1"}}, NextLink: next.String()})
+			return
+		}
+		_ = json.NewEncoder(w).Encode(ARMPage[ARMResourceGroupInfo]{Value: []ARMResourceGroupInfo{{Name: "rg2", Tags: map[string]string{E2EResourceGroupTag: "true"}}}})
+	})
+	subject.ARMClient.HttpClient = &http.Client{Transport: redirectTransport{target: &subject.uri}}
+	sub := &ARMSubscription{ARMClient: subject.ARMClient, SubscriptionID: "sub"}
+
+	groups, err := sub.ListResourceGroups(context.Background(), &ARMResourceGroupTagFilter{Name: E2EResourceGroupTag, Value: "it's"})
+	a.NoError(err)
+	a.Len(groups, 2)
+	for i, name := range []string{"rg1", "rg2"} {
+		a.Equal(name, groups[i].ResourceGroupName)
+		a.Equal(name, groups[i].Listed.Name)
+		a.Same(sub, groups[i].ARMSubscription) // the handles are usable as-is
+	}
+	a.Equal("true", groups[1].Listed.Tags[E2EResourceGroupTag])
+
+	_, err = sub.ListResourceGroups(context.Background(), &ARMResourceGroupTagFilter{Name: E2EResourceGroupTag})
+	a.NoError(err)
+	_, err = sub.ListResourceGroups(context.Background(), nil)
+	a.NoError(err)
+
+	a.Equal([]string{
+		"tagName eq 'azcopy-e2e' and tagValue eq 'it''s'", "tagName eq 'azcopy-e2e' and tagValue eq 'it''s'",
+		"tagName eq 'azcopy-e2e'", "tagName eq 'azcopy-e2e'",
+		"", "",
+	}, filters)
+}
+
+func TestDeleteEmptyResourceGroups(t *testing.T) {
+	a := assert.New(t)
+
+	old := time.Now().Add(-2 * time.Hour).UTC().Format(time.RFC3339)
+	recent := time.Now().UTC().Format(time.RFC3339)
+	groups := []ARMResourceGroupInfo{
+		{Name: "azcopy-newe2e-empty", Tags: map[string]string{ARMCreatedAtTag: old}},
+		{Name: "azcopy-newe2e-full", Tags: map[string]string{ARMCreatedAtTag: old}},
+		{Name: "azcopy-newe2e-recent", Tags: map[string]string{ARMCreatedAtTag: recent}},
+		{Name: "azcopy-newe2e-untagged"},
+		{Name: "someone-elses-group", Tags: map[string]string{ARMCreatedAtTag: old}},
+		{Name: "azcopy-newe2e-stuck", Tags: map[string]string{ARMCreatedAtTag: old}},
+	}
+
+	var listed, deletes []string
+	subject := newTestARMSubject(t, func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == "/subscriptions/sub/resourcegroups":
+			_ = json.NewEncoder(w).Encode(ARMPage[ARMResourceGroupInfo]{Value: groups})
+		case r.Method == http.MethodGet && strings.HasSuffix(r.URL.Path, "/resources"):
+			name := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/subscriptions/sub/resourcegroups/"), "/resources")
+			listed = append(listed, name)
+
+			var resources []ARMResourceInfo
+			if name == "azcopy-newe2e-full" {
+				resources = append(resources, ARMResourceInfo{Name: "acct", Type: "Microsoft.Storage/storageAccounts"})
+			}
+			_ = json.NewEncoder(w).Encode(ARMPage[ARMResourceInfo]{Value: resources})
+		case r.Method == http.MethodDelete:
+			name := strings.TrimPrefix(r.URL.Path, "/subscriptions/sub/resourcegroups/")
+			deletes = append(deletes, name)
+			if name == "azcopy-newe2e-stuck" {
+				w.WriteHeader(http.StatusConflict)
+				return
+			}
+			w.WriteHeader(http.StatusOK)
+		default:
+			w.WriteHeader(http.StatusBadRequest)
+		}
+	})
+	subject.ARMClient.HttpClient = &http.Client{Transport: redirectTransport{target: &subject.uri}}
+	sub := &ARMSubscription{ARMClient: subject.ARMClient, SubscriptionID: "sub"}
+
+	deleted, err := sub.DeleteEmptyResourceGroups(time.Hour)
+	a.ErrorContains(err, "failed to delete 1 empty resource groups")
+	a.ErrorContains(err, "azcopy-newe2e-stuck")
+	a.Equal([]string{"azcopy-newe2e-empty"}, deleted)
+	a.Equal([]string{"azcopy-newe2e-empty", "azcopy-newe2e-full", "azcopy-newe2e-stuck"}, listed)
+	a.Equal([]string{"azcopy-newe2e-empty", "azcopy-newe2e-stuck"}, deletes)
+}
@@ -0,0 +1,147 @@
+package e2etest
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// failingTransport stands in for the network when nothing should reach it.
+type failingTransport struct{}
+
+func (failingTransport) RoundTrip(*http.Request) (*http.Response, error) {
+	return nil, errors.New("the network is off limits during replay")
+}
+
+func TestARMRecordReplay(t *testing.T) {
+	a := assert.New(t)
+
+	type keys struct {
+		Keys []ARMStorageAccountKey `json:"keys"`
+	}
+
+	var subject testARMSubject
+	var polls int
+	subject = newTestARMSubject(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/listKeys":
+			_, _ = w.Write([]byte(`{"keys":[{"keyName":"key1","permissions":"FULL","value":"c2VjcmV0"}]}`))
+		case "/operation":
+			polls++
+			w.Header().Set("Content-Type", "application/json")
+			if polls < 3 {
+				_, _ = w.Write([]byte(`{"status":"InProgress"}`))
+				return
+			}
+			_, _ = w.Write([]byte(`{"status":"Succeeded","properties":{"output":{"accessSAS":"https://md-impexp.z0.blob.storage.azure.net/abcd/abcd?sv=2018-03-28&sig=c2lnbmF0dXJl"}}}`))
+		default:
+			op := subject.uri
+			op.Path = "/operation"
+			w.Header().Set("Azure-AsyncOperation", op.String())
+			w.WriteHeader(http.StatusAccepted)
+		}
+	})
+
+	run := func(subject testARMSubject) (states []string, access *ARMManagedDiskAccessURI, listed keys) {
+		var out armManagedDiskGrantAccessOutput
+		armResp, err := PerformRequest(context.Background(), subject, ARMRequestSettings{
+			Method:        http.MethodPost,
+			PathExtension: "beginGetAccess",
+			Body:          ARMManagedDiskGrantAccessParams{AccessLevel: ARMManagedDiskAccessLevelRead, DurationInSeconds: 3600},
+			DeferAsync:    true,
+		}, &out)
+		a.NoError(err)
+		if armResp != nil {
+			_, err = armResp.PollWithCallback(context.Background(), time.Millisecond, func(state string) {
+				states = append(states, state)
+			})
+			a.NoError(err)
+		}
+
+		_, err = PerformRequest(context.Background(), subject, ARMRequestSettings{Method: http.MethodPost, PathExtension: "listKeys"}, &listed)
+		a.NoError(err)
+
+		return states, out.Output, listed
+	}
+
+	// Record against the (fake) service.
+	cassette := filepath.Join(t.TempDir(), "arm", "cassette.json")
+	t.Setenv("E2E_ARM_REPLAY", "")
+	t.Setenv("E2E_ARM_RECORD", "1")
+	subject.Cassette = cassette
+
+	states, access, listed := run(subject)
+	a.Equal([]string{ARMStatusInProgress, ARMStatusInProgress, ARMStatusSucceeded}, states)
+	a.Equal("c2VjcmV0", listed.Keys[0].Value)
+	a.Contains(access.AccessSAS, "sig=c2lnbmF0dXJl")
+
+	// Nothing secret makes it to disk.
+	buf, err := os.ReadFile(cassette)
+	a.NoError(err)
+	a.NotContains(string(buf), "Bearer")
+	a.NotContains(string(buf), "c2VjcmV0")
+	a.NotContains(string(buf), "c2lnbmF0dXJl")
+
+	// Replay, with no way to reach the service; the polls come back in the order they were recorded.
+	t.Setenv("E2E_ARM_RECORD", "")
+	t.Setenv("E2E_ARM_REPLAY", "1")
+	replaySubject := testARMSubject{
+		ARMClient: &ARMClient{
+			OAuth:      staticAccessToken("token"),
+			HttpClient: &http.Client{Transport: failingTransport{}},
+			RetryDelay: time.Millisecond,
+			Cassette:   cassette,
+		},
+		uri: subject.uri,
+	}
+
+	states, access, listed = run(replaySubject)
+	a.Equal([]string{ARMStatusInProgress, ARMStatusInProgress, ARMStatusSucceeded}, states)
+	a.Equal("REDACTED", listed.Keys[0].Value)
+	a.Equal("https://md-impexp.z0.blob.storage.azure.net/abcd/abcd?sv=2018-03-28&sig=REDACTED", access.AccessSAS)
+	a.Equal(3, polls) // the service wasn't consulted again
+
+	// A request that was never recorded (here, a differing body) fails, rather than going to the network.
+	_, err = PerformRequest[any](context.Background(), replaySubject, ARMRequestSettings{
+		Method:        http.MethodPost,
+		PathExtension: "beginGetAccess",
+		Body:          ARMManagedDiskGrantAccessParams{AccessLevel: ARMManagedDiskAccessLevelWrite, DurationInSeconds: 3600},
+	}, nil)
+	a.ErrorContains(err, "no recorded ARM response for POST /beginGetAccess")
+}
+
+func TestARMReplayManagedDiskAccess(t *testing.T) {
+	a := assert.New(t)
+	fa := NewFrameworkAsserter(t)
+
+	t.Setenv("E2E_ARM_RECORD", "")
+	t.Setenv("E2E_ARM_REPLAY", "1")
+
+	md := &ManagedDiskResourceManager{
+		armClient: &ARMManagedDisk{
+			ARMResourceGroup: &ARMResourceGroup{
+				ARMSubscription: &ARMSubscription{
+					ARMClient: &ARMClient{
+						OAuth:      staticAccessToken("token"),
+						HttpClient: &http.Client{Transport: failingTransport{}},
+						Cassette:   filepath.Join("testdata", "arm", "managed_disk_read_access.json"),
+					},
+					SubscriptionID: "sub",
+				},
+				ResourceGroupName: "rg",
+			},
+			DiskName: "disk",
+		},
+	}
+
+	md.GrantAccess(fa, ARMManagedDiskAccessLevelRead)
+	a.Equal("https://md-impexp-t0abcd.z8.blob.storage.azure.net/kj4lmn/abcd?sv=2018-03-28&sr=b&si=7e2c5a1b&sig=REDACTED", md.URI())
+
+	md.Delete(fa)
+}
@@ -0,0 +1,190 @@
+package e2etest
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/bloberror"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/container"
+	"github.com/Azure/azure-storage-azcopy/v10/common"
+	"github.com/stretchr/testify/assert"
+)
+
+func init() {
+	suiteManager.RegisterSuite(&BlobImmutabilitySuite{})
+}
+
+func TestARMBlobContainerImmutabilitySettings(t *testing.T) {
+	a := assert.New(t)
+
+	type request struct {
+		method, path, ifMatch, body string
+	}
+	var requests []request
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		buf, _ := io.ReadAll(r.Body)
+		requests = append(requests, request{r.Method, r.URL.Path, r.Header.Get("If-Match"), string(buf)})
+		a.Equal("2023-01-01", r.URL.Query().Get("api-version"))
+
+		switch r.Method {
+		case http.MethodDelete:
+			w.WriteHeader(http.StatusOK)
+		case http.MethodPost:
+			if len(buf) != 0 { // legal holds echo the tags
+				_, _ = w.Write(buf)
+				return
+			}
+			_, _ = w.Write([]byte(`{"etag":"\"locked\"","properties":{"immutabilityPeriodSinceCreationInDays":1,"state":"Locked"}}`))
+		default:
+			_, _ = w.Write([]byte(`{"etag":"\"unlocked\"","properties":{"immutabilityPeriodSinceCreationInDays":1,"state":"Unlocked"}}`))
+		}
+	}))
+	defer srv.Close()
+
+	target, _ := url.Parse(srv.URL)
+	c := &ARMBlobContainer{
+		ARMStorageAccount: &ARMStorageAccount{
+			ARMResourceGroup: &ARMResourceGroup{
+				ARMSubscription: &ARMSubscription{
+					ARMClient: &ARMClient{
+						OAuth:      staticAccessToken("token"),
+						HttpClient: &http.Client{Transport: redirectTransport{target: target}},
+					},
+					SubscriptionID: "sub",
+				},
+				ResourceGroupName: "rg",
+			},
+			AccountName: "acct",
+		},
+		ContainerName: "ctr",
+	}
+	base := "/subscriptions/sub/resourcegroups/rg/providers/Microsoft.Storage/storageAccounts/acct/blobServices/default/containers/ctr"
+
+	policy, err := c.SetImmutabilityPolicy(ARMImmutabilityPolicyProperties{ImmutabilityPeriodSinceCreationInDays: 1})
+	a.NoError(err)
+	a.Equal(`"unlocked"`, policy.ETag)
+	a.Equal(ARMImmutabilityPolicyStateUnlocked, policy.Properties.State)
+
+	policy, err = c.LockImmutabilityPolicy(policy.ETag)
+	a.NoError(err)
+	a.Equal(ARMImmutabilityPolicyStateLocked, policy.Properties.State)
+
+	a.NoError(c.DeleteImmutabilityPolicy(`"unlocked"`))
+
+	hold, err := c.SetLegalHold([]string{"e2etest"})
+	a.NoError(err)
+	a.Equal([]string{"e2etest"}, hold.Tags)
+	_, err = c.ClearLegalHold([]string{"e2etest"})
+	a.NoError(err)
+
+	a.Len(requests, 5)
+	suffixes := []string{"/immutabilityPolicies/default", "/immutabilityPolicies/default/lock", "/immutabilityPolicies/default", "/setLegalHold", "/clearLegalHold"}
+	methods := []string{http.MethodPut, http.MethodPost, http.MethodDelete, http.MethodPost, http.MethodPost}
+	ifMatches := []string{"", `"unlocked"`, `"unlocked"`, "", ""}
+	for i, req := range requests {
+		a.Equal(methods[i], req.method)
+		a.Equal(base+suffixes[i], req.path)
+		a.Equal(ifMatches[i], req.ifMatch, req.path) // ARM refuses to lock or delete a policy without its etag
+	}
+
+	var body ARMImmutabilityPolicy
+	a.NoError(json.Unmarshal([]byte(requests[0].body), &body))
+	a.Equal(1, body.Properties.ImmutabilityPeriodSinceCreationInDays)
+	a.JSONEq(`{"tags":["e2etest"]}`, requests[3].body)
+}
+
+func TestBlobContainerImmutabilityRequiresARM(t *testing.T) {
+	a := assert.New(t)
+
+	acct := &AzureAccountResourceManager{accountName: "acct", accountKey: "YWNjb3VudGtleQ=="} // e.g. a static account
+	ctr := &BlobContainerResourceManager{internalAccount: acct, containerName: "ctr"}
+
+	var skipped bool
+	t.Run("LegalHold", func(t *testing.T) {
+		t.Cleanup(func() { skipped = t.Skipped() })
+		ctr.SetLegalHold(NewFrameworkAsserter(t), []string{"e2etest"})
+	})
+	a.True(skipped)
+
+	// Dry runs don't touch the container at all.
+	svm := &ScenarioVariationManager{}
+	SetBlobContainerImmutabilityPolicy(svm, ctr, 1, false)
+	SetBlobContainerLegalHold(svm, ctr, []string{"e2etest"})
+	ValidateBlobImmutable(svm, &BlobObjectResourceManager{}, bloberror.BlobImmutableDueToPolicy)
+}
+
+func TestUntrackCreatedResource(t *testing.T) {
+	a := assert.New(t)
+
+	acct := &AzureAccountResourceManager{accountName: "acct", accountKey: "YWNjb3VudGtleQ=="}
+	client, err := container.NewClientWithNoCredential("https://acct.blob.core.windows.net/ctr", nil)
+	a.NoError(err)
+	ctr := &BlobContainerResourceManager{
+		internalAccount: acct,
+		Service:         &BlobServiceResourceManager{internalAccount: acct},
+		containerName:   "ctr",
+		internalClient:  client,
+	}
+
+	// A container with a locked immutability policy is left out of teardown.
+	svm := &ScenarioVariationManager{}
+	svm.TrackCreatedResource(ctr)
+	a.NotNil(svm.CreatedResources.Get(ctr.Canon()))
+	svm.UntrackCreatedResource(ctr)
+	a.Nil(svm.CreatedResources.Get(ctr.Canon()))
+}
+
+type BlobImmutabilitySuite struct{}
+
+// Scenario_ImmutableDestination copies over a blob in a container under an immutability policy or legal hold.
+// Overwriting it must fail, while skipping it with --overwrite=false succeeds.
+func (s *BlobImmutabilitySuite) Scenario_ImmutableDestination(svm *ScenarioVariationManager) {
+	hold := ResolveVariation(svm, []string{"ImmutabilityPolicy", "LegalHold"})
+
+	originalBody := NewRandomObjectContentContainer(svm, SizeFromString("1K"))
+	dstContainer := CreateResource[ContainerResourceManager](svm, GetRootResource(svm, common.ELocation.Blob()), ResourceDefinitionContainer{})
+	dstObj := CreateResource[ObjectResourceManager](svm, dstContainer, ResourceDefinitionObject{
+		ObjectName: pointerTo("test"),
+		Body:       originalBody,
+	})
+	srcObj := CreateResource[ObjectResourceManager](svm, GetRootResource(svm, common.ELocation.Local()), ResourceDefinitionObject{
+		ObjectName: pointerTo("test"),
+		Body:       NewRandomObjectContentContainer(svm, SizeFromString("1K")),
+	})
+
+	var expectedProps BlobContainerProperties
+	var code bloberror.Code
+	switch hold {
+	case "ImmutabilityPolicy":
+		SetBlobContainerImmutabilityPolicy(svm, dstContainer, 1, false)
+		expectedProps.HasImmutabilityPolicy, code = pointerTo(true), bloberror.BlobImmutableDueToPolicy
+	case "LegalHold":
+		SetBlobContainerLegalHold(svm, dstContainer, []string{"e2etest"})
+		expectedProps.HasLegalHold, code = pointerTo(true), BlobImmutableDueToLegalHold
+	}
+	ValidateResource[ContainerResourceManager](svm, dstContainer, ResourceDefinitionContainer{
+		Properties: ContainerProperties{BlobContainerProperties: expectedProps},
+	}, false)
+
+	for _, overwrite := range []bool{true, false} {
+		RunAzCopy(
+			svm,
+			AzCopyCommand{
+				Verb:    AzCopyVerbCopy,
+				Targets: []ResourceManager{srcObj, dstObj},
+				Flags: CopyFlags{
+					Overwrite: pointerTo(overwrite),
+				},
+				ShouldFail: overwrite, // the service refuses the overwrite; skipping it is fine
+			})
+	}
+
+	ValidateResource[ObjectResourceManager](svm, dstObj, ResourceDefinitionObject{
+		Body: originalBody,
+	}, true)
+	ValidateBlobImmutable(svm, dstObj, code)
+}
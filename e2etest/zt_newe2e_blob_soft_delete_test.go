@@ -0,0 +1,188 @@
+package e2etest
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/service"
+	"github.com/Azure/azure-storage-azcopy/v10/common"
+	"github.com/stretchr/testify/assert"
+)
+
+func init() {
+	suiteManager.RegisterSuite(&BlobSoftDeleteSuite{})
+}
+
+// fakeSoftDeleteContainer serves one container's blobs, retaining deleted ones once soft delete has been enabled on the service.
+type fakeSoftDeleteContainer struct {
+	mut           sync.Mutex
+	retentionDays int
+	blobs         map[string]bool // name to whether it's soft-deleted
+}
+
+func (f *fakeSoftDeleteContainer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mut.Lock()
+	defer f.mut.Unlock()
+
+	q := r.URL.Query()
+	name := strings.TrimPrefix(r.URL.Path, "/acct/container/")
+	deleted, exists := f.blobs[name]
+	switch {
+	case q.Get("comp") == "properties" && r.Method == http.MethodPut:
+		var props struct {
+			DeleteRetentionPolicy struct {
+				Enabled bool
+				Days    int
+			}
+		}
+		buf, _ := io.ReadAll(r.Body)
+		_ = xml.Unmarshal(buf, &props)
+		if props.DeleteRetentionPolicy.Enabled {
+			f.retentionDays = props.DeleteRetentionPolicy.Days
+		}
+		w.WriteHeader(http.StatusAccepted)
+	case q.Get("comp") == "properties" && r.Method == http.MethodGet:
+		_, _ = fmt.Fprintf(w, `<?xml version="1.0" encoding="utf-8"?><StorageServiceProperties><DeleteRetentionPolicy><Enabled>%t</Enabled>%s</DeleteRetentionPolicy></StorageServiceProperties>`,
+			f.retentionDays != 0, common.Iff(f.retentionDays != 0, fmt.Sprintf("<Days>%d</Days>", f.retentionDays), ""))
+	case q.Get("comp") == "list":
+		var names []string
+		for k := range f.blobs {
+			names = append(names, k)
+		}
+		sort.Strings(names)
+
+		out := `<?xml version="1.0" encoding="utf-8"?><EnumerationResults ContainerName="container"><Blobs>`
+		for _, k := range names {
+			if f.blobs[k] && !strings.Contains(q.Get("include"), "deleted") {
+				continue
+			}
+			out += fmt.Sprintf("<Blob><Name>%s</Name><Deleted>%t</Deleted><Properties><BlobType>BlockBlob</BlobType></Properties></Blob>", k, f.blobs[k])
+		}
+		_, _ = w.Write([]byte(out + "</Blobs><NextMarker /></EnumerationResults>"))
+	case !exists || (deleted && r.Method != http.MethodPut):
+		w.Header().Set("x-ms-error-code", "BlobNotFound")
+		w.WriteHeader(http.StatusNotFound)
+	case q.Get("comp") == "undelete":
+		f.blobs[name] = false
+	case r.Method == http.MethodDelete:
+		if f.retentionDays > 0 {
+			f.blobs[name] = true
+		} else {
+			delete(f.blobs, name)
+		}
+		w.WriteHeader(http.StatusAccepted)
+	case r.Method == http.MethodHead:
+		w.Header().Set("x-ms-blob-type", "BlockBlob")
+	default:
+		w.WriteHeader(http.StatusBadRequest)
+	}
+}
+
+func TestBlobSoftDeleteHelpers(t *testing.T) {
+	a := assert.New(t)
+	fa := NewFrameworkAsserter(t)
+
+	fake := &fakeSoftDeleteContainer{blobs: map[string]bool{"a": false, "b": false, "c": false}}
+	srv := httptest.NewServer(fake)
+	defer srv.Close()
+
+	acct := &AzureAccountResourceManager{accountName: "acct", accountKey: "YWNjb3VudGtleQ=="}
+	client, err := service.NewClientWithNoCredential(srv.URL+"/acct/", nil)
+	a.NoError(err)
+	svc := &BlobServiceResourceManager{internalAccount: acct, internalClient: client}
+	ctr := svc.GetContainer("container")
+
+	// Without soft delete, deleted blobs are simply gone.
+	ctr.GetObject(fa, "c", common.EEntityType.File()).Delete(fa)
+	a.Empty(ListDeletedBlobs(fa, ctr))
+
+	EnableBlobSoftDelete(fa, svc, 7)
+	a.Equal(7, fake.retentionDays)
+
+	objA := ctr.GetObject(fa, "a", common.EEntityType.File())
+	objA.Delete(fa)
+	a.Equal([]string{"a"}, ListDeletedBlobs(fa, ctr))
+	a.Len(ctr.ListObjects(fa, "", true), 1) // listings leave soft-deleted blobs out
+	ValidateBlobSoftDeleted(fa, objA, true)
+	ValidateBlobSoftDeleted(fa, ctr.GetObject(fa, "b", common.EEntityType.File()), false)
+
+	UndeleteBlob(fa, objA)
+	a.Empty(ListDeletedBlobs(fa, ctr))
+	ValidateBlobSoftDeleted(fa, objA, false)
+	a.Len(ctr.ListObjects(fa, "", true), 2)
+
+	// Dry runs never reach the service.
+	a.Nil(ListDeletedBlobs(&ScenarioVariationManager{}, nil))
+}
+
+type BlobSoftDeleteSuite struct{}
+
+// Scenario_CopyLiveBlobsOnly soft-deletes half of the source, and checks a copy carries only the live half over,
+// then that undeleting the rest has them copied too.
+// Soft-deleted blobs only ever reach AzCopy's listings via remove's --permanent-delete; copy has no way of including them.
+func (s *BlobSoftDeleteSuite) Scenario_CopyLiveBlobsOnly(svm *ScenarioVariationManager) {
+	acct := CreateAccount(svm, EAccountType.Standard(), nil) // soft delete is account-wide
+	svc := acct.GetService(svm, common.ELocation.Blob())
+	EnableBlobSoftDelete(svm, svc, 1)
+	if !svm.Dryrun() {
+		// Service properties take up to 30 seconds to take effect.
+		time.Sleep(time.Second * 30)
+	}
+
+	objects := ObjectResourceMappingFlat{}
+	for i := 0; i < 10; i++ {
+		objects[fmt.Sprintf("blob%02d", i)] = ResourceDefinitionObject{Body: NewRandomObjectContentContainer(svm, SizeFromString("1K"))}
+	}
+	srcContainer := CreateResource[ContainerResourceManager](svm, svc, ResourceDefinitionContainer{Objects: objects})
+
+	deleted := make([]ObjectResourceManager, 0, 5)
+	for i := 0; i < 10; i += 2 {
+		obj := srcContainer.GetObject(svm, fmt.Sprintf("blob%02d", i), common.EEntityType.File())
+		obj.Delete(svm)
+		deleted = append(deleted, obj)
+	}
+	if !svm.Dryrun() {
+		svm.Assert("soft-deleted source blobs", Equal{}, len(ListDeletedBlobs(svm, srcContainer)), 5)
+	}
+
+	copyAll := func() ContainerResourceManager {
+		dstContainer := CreateResource[ContainerResourceManager](svm, svc, ResourceDefinitionContainer{})
+		RunAzCopy(svm, AzCopyCommand{
+			Verb:    AzCopyVerbCopy,
+			Targets: []ResourceManager{srcContainer, dstContainer},
+			Flags: CopyFlags{
+				CopySyncCommonFlags: CopySyncCommonFlags{Recursive: pointerTo(true)},
+				AsSubdir:            pointerTo(false),
+			},
+		})
+
+		return dstContainer
+	}
+
+	dstContainer := copyAll()
+	for _, obj := range deleted {
+		ValidateBlobSoftDeleted(svm, obj, true)
+		ValidateResource[ObjectResourceManager](svm, dstContainer.GetObject(svm, obj.ObjectName(), common.EEntityType.File()), ResourceDefinitionObject{
+			ObjectShouldExist: pointerTo(false),
+		}, false)
+	}
+	if !svm.Dryrun() {
+		svm.Assert("blobs copied without the soft-deleted half", Equal{}, len(dstContainer.ListObjects(svm, "", true)), 5)
+	}
+
+	for _, obj := range deleted {
+		UndeleteBlob(svm, obj)
+		ValidateBlobSoftDeleted(svm, obj, false)
+	}
+
+	dstContainer = copyAll()
+	ValidateResource[ContainerResourceManager](svm, dstContainer, ResourceDefinitionContainer{Objects: objects}, true)
+}
@@ -0,0 +1,119 @@
+package e2etest
+
+import (
+	"encoding/xml"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/blob"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/container"
+	"github.com/Azure/azure-storage-azcopy/v10/common"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeTieredBlob serves a single block blob's tier, archive status and tags. Rehydrations finish after rehydrationPolls reads.
+type fakeTieredBlob struct {
+	mut              sync.Mutex
+	tier             string
+	archiveStatus    string
+	rehydratingTo    string
+	rehydrationPolls int
+	priority         string
+	tags             string
+}
+
+func (f *fakeTieredBlob) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mut.Lock()
+	defer f.mut.Unlock()
+
+	comp := r.URL.Query().Get("comp")
+	switch {
+	case r.Method == http.MethodPut && comp == "":
+		f.tier = r.Header.Get("x-ms-access-tier")
+		w.WriteHeader(http.StatusCreated)
+	case r.Method == http.MethodPut && comp == "tier":
+		if f.tier == string(blob.AccessTierArchive) && r.Header.Get("x-ms-access-tier") != f.tier {
+			f.rehydratingTo = r.Header.Get("x-ms-access-tier")
+			f.archiveStatus = "rehydrate-pending-to-" + strings.ToLower(f.rehydratingTo)
+			f.priority = r.Header.Get("x-ms-rehydrate-priority")
+			w.WriteHeader(http.StatusAccepted)
+			return
+		}
+		f.tier = r.Header.Get("x-ms-access-tier")
+	case r.Method == http.MethodPut && comp == "tags":
+		buf, _ := io.ReadAll(r.Body)
+		f.tags = string(buf)
+		w.WriteHeader(http.StatusNoContent)
+	case r.Method == http.MethodGet && comp == "tags":
+		_, _ = w.Write([]byte(xml.Header + f.tags))
+	case r.Method == http.MethodHead:
+		w.Header().Set("x-ms-blob-type", "BlockBlob")
+		if f.archiveStatus != "" {
+			if f.rehydrationPolls--; f.rehydrationPolls < 0 {
+				f.tier, f.archiveStatus = f.rehydratingTo, ""
+			}
+		}
+		w.Header().Set("x-ms-access-tier", f.tier)
+		if f.archiveStatus != "" {
+			w.Header().Set("x-ms-archive-status", f.archiveStatus)
+		}
+	default:
+		w.WriteHeader(http.StatusBadRequest)
+	}
+}
+
+func TestBlobTierAndTagsRoundTrip(t *testing.T) {
+	a := assert.New(t)
+	fa := NewFrameworkAsserter(t)
+
+	fake := &fakeTieredBlob{tags: "<Tags><TagSet /></Tags>", rehydrationPolls: 2}
+	srv := httptest.NewServer(fake)
+	defer srv.Close()
+
+	defer func(interval time.Duration) { blobRehydrationPollInterval = interval }(blobRehydrationPollInterval)
+	blobRehydrationPollInterval = time.Millisecond
+
+	acct := &AzureAccountResourceManager{accountName: "acct", accountKey: "YWNjb3VudGtleQ=="}
+	containerClient, err := container.NewClientWithNoCredential(srv.URL+"/acct/container", nil)
+	a.NoError(err)
+	ctr := &BlobContainerResourceManager{internalAccount: acct, containerName: "container", internalClient: containerClient}
+	obj := ctr.GetObject(fa, "blob", common.EEntityType.File()).(*BlobObjectResourceManager)
+
+	// Seed a cool blob, and read the tier back.
+	obj.Create(fa, NewRandomObjectContentContainer(fa, SizeFromString("1K")), ObjectProperties{
+		BlobProperties: BlobProperties{BlockBlobAccessTier: pointerTo(blob.AccessTierCool)},
+	})
+	props := obj.GetProperties(fa).BlobProperties
+	a.Equal(pointerTo(blob.AccessTierCool), props.BlockBlobAccessTier)
+	a.Nil(props.PageBlobAccessTier)
+	a.Nil(props.ArchiveStatus)
+
+	obj.SetTags(fa, map[string]string{"tier": "cool"})
+	a.Equal(map[string]string{"tier": "cool"}, obj.GetProperties(fa).BlobProperties.Tags)
+
+	// Archive it, then rehydrate it to hot.
+	obj.SetTier(fa, blob.AccessTierArchive, nil)
+	a.Equal(pointerTo(blob.AccessTierArchive), obj.GetProperties(fa).BlobProperties.BlockBlobAccessTier)
+
+	SetBlobTier(fa, obj, blob.AccessTierHot, pointerTo(blob.RehydratePriorityHigh))
+	a.Equal(string(blob.RehydratePriorityHigh), fake.priority)
+	a.Equal(pointerTo(blob.ArchiveStatusRehydratePendingToHot), obj.GetProperties(fa).BlobProperties.ArchiveStatus)
+
+	a.Equal(blob.AccessTierHot, WaitForBlobRehydration(fa, obj, time.Minute))
+	a.Nil(obj.GetProperties(fa).BlobProperties.ArchiveStatus)
+}
+
+func TestBlobTierHelpersDryrun(t *testing.T) {
+	a := assert.New(t)
+
+	// Dry runs don't touch the blob at all.
+	svm := &ScenarioVariationManager{}
+	SetBlobTier(svm, &BlobObjectResourceManager{}, blob.AccessTierCool, nil)
+	SetBlobTags(svm, &BlobObjectResourceManager{}, map[string]string{"foo": "bar"})
+	a.Equal(blob.AccessTierHot, WaitForBlobRehydration(svm, &BlobObjectResourceManager{}, time.Hour))
+}
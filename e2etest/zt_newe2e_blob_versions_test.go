@@ -0,0 +1,89 @@
+package e2etest
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"testing"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/container"
+	blobsas "github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/sas"
+	"github.com/Azure/azure-storage-azcopy/v10/common"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBlobSnapshotsAndVersions(t *testing.T) {
+	a := assert.New(t)
+	fa := NewFrameworkAsserter(t)
+
+	var versions int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		switch {
+		case r.Method == http.MethodPut && query.Get("comp") == "snapshot":
+			a.Equal("/acct/container/blob", r.URL.Path)
+			w.Header().Set("x-ms-snapshot", "2024-01-01T00:00:02.0000000Z")
+			w.WriteHeader(http.StatusCreated)
+		case r.Method == http.MethodPut:
+			a.Equal("/acct/container/blob", r.URL.Path)
+			versions++
+			w.Header().Set("x-ms-version-id", "2024-01-01T00:00:0"+strconv.Itoa(versions)+".0000000Z")
+			w.WriteHeader(http.StatusCreated)
+		case query.Get("comp") == "list":
+			a.Equal("blob", query.Get("prefix"))
+			// Listed as the service would: the base blob last, and a blob sharing the prefix that mustn't be confused for this one.
+			_, _ = w.Write([]byte(`<?xml version="1.0" encoding="utf-8"?><EnumerationResults ContainerName="container"><Blobs>` +
+				`<Blob><Name>blob</Name><Snapshot>2024-01-01T00:00:02.0000000Z</Snapshot><VersionId>2024-01-01T00:00:02.0000000Z</VersionId><Properties /></Blob>` +
+				`<Blob><Name>blob</Name><Snapshot>2024-01-01T00:00:01.0000000Z</Snapshot><VersionId>2024-01-01T00:00:01.0000000Z</VersionId><Properties /></Blob>` +
+				`<Blob><Name>blob</Name><VersionId>2024-01-01T00:00:03.0000000Z</VersionId><IsCurrentVersion>true</IsCurrentVersion><Properties /></Blob>` +
+				`<Blob><Name>blob2</Name><Snapshot>2024-01-01T00:00:00.0000000Z</Snapshot><Properties /></Blob>` +
+				`</Blobs><NextMarker /></EnumerationResults>`))
+		default:
+			w.WriteHeader(http.StatusBadRequest)
+		}
+	}))
+	defer srv.Close()
+
+	acct := &AzureAccountResourceManager{accountName: "acct", accountKey: "YWNjb3VudGtleQ=="}
+	containerClient, err := container.NewClientWithNoCredential(srv.URL+"/acct/container", nil)
+	a.NoError(err)
+	obj := &BlobObjectResourceManager{
+		internalAccount: acct,
+		Service:         &BlobServiceResourceManager{internalAccount: acct},
+		Container:       &BlobContainerResourceManager{internalAccount: acct, containerName: "container", internalClient: containerClient},
+		Path:            "blob",
+		entityType:      common.EEntityType.File(),
+		internalClient:  containerClient.NewBlobClient("blob"),
+	}
+
+	a.Equal("2024-01-01T00:00:02.0000000Z", obj.CreateSnapshot(fa))
+	a.Equal([]string{"2024-01-01T00:00:01.0000000Z", "2024-01-01T00:00:02.0000000Z"}, obj.ListSnapshots(fa))
+	a.Equal([]string{"2024-01-01T00:00:01.0000000Z", "2024-01-01T00:00:02.0000000Z", "2024-01-01T00:00:03.0000000Z"}, obj.ListVersions(fa))
+
+	created := obj.OverwriteToCreateVersion(fa, 2)
+	a.Len(created, 2)
+	a.Equal("2024-01-01T00:00:01.0000000Z", created[0].VersionID)
+	a.Equal("2024-01-01T00:00:02.0000000Z", created[1].VersionID)
+	a.NotEqual(created[0].Body, created[1].Body)
+
+	// Snapshots and versions stay on the URI, whichever kind of SAS is added to it.
+	for name, opts := range map[string]GetURIOptions{
+		"service SAS": {AzureOpts: AzureURIOpts{WithSAS: true, SASValues: GenericServiceSignatureValues{Permissions: (&blobsas.BlobPermissions{Read: true}).String()}}},
+		"account SAS": {AzureOpts: AzureURIOpts{WithSAS: true}},
+	} {
+		for param, rm := range map[string]*BlobObjectResourceManager{
+			"snapshot":  obj.WithSnapshot(fa, "2024-01-01T00:00:01.0000000Z"),
+			"versionid": obj.WithVersion(fa, "2024-01-01T00:00:01.0000000Z"),
+		} {
+			u, err := url.Parse(rm.URI(opts))
+			a.NoError(err)
+			a.Equal("2024-01-01T00:00:01.0000000Z", u.Query().Get(param), "%s with %s", param, name)
+			a.NotEmpty(u.Query().Get("sig"), "%s with %s", param, name)
+			a.Equal("/acct/container/blob", u.Path)
+		}
+	}
+
+	// The base blob is left alone.
+	a.NotContains(obj.URI(), "snapshot")
+}
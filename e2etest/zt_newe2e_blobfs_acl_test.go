@@ -0,0 +1,147 @@
+package e2etest
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azdatalake/filesystem"
+	"github.com/Azure/azure-storage-azcopy/v10/common"
+	"github.com/stretchr/testify/assert"
+)
+
+func init() {
+	suiteManager.RegisterSuite(&BlobFSACLSuite{})
+}
+
+// testACLIdentity is an arbitrary object ID; the service doesn't check the identities an ACL names exist.
+const testACLIdentity = "5e1f2c4a-90d3-4b7e-8a61-1c2d3e4f5a6b"
+
+func TestBlobFSACLParsing(t *testing.T) {
+	a := assert.New(t)
+
+	acl := "user::rwx,group::r-x,other::---,default:user:" + testACLIdentity + ":r-x,default:mask::r-x"
+	entries, err := ParseBlobFSACL(acl)
+	a.NoError(err)
+	a.Equal([]BlobFSACLEntry{
+		{Type: "user", Permissions: "rwx"},
+		{Type: "group", Permissions: "r-x"},
+		{Type: "other", Permissions: "---"},
+		{Default: true, Type: "user", Identity: testACLIdentity, Permissions: "r-x"},
+		{Default: true, Type: "mask", Permissions: "r-x"},
+	}, entries)
+	a.Equal(acl, FormatBlobFSACL(entries))
+
+	control := BlobFSAccessControl{ACL: entries}
+	entry, ok := control.Entry(true, "user", testACLIdentity)
+	a.True(ok)
+	a.Equal("r-x", entry.Permissions)
+	_, ok = control.Entry(false, "user", testACLIdentity)
+	a.False(ok)
+	a.Len(control.DefaultACL(), 2)
+
+	_, err = ParseBlobFSACL("user:rwx")
+	a.Error(err)
+
+	entries, err = ParseBlobFSACL("")
+	a.NoError(err)
+	a.Empty(entries)
+}
+
+func TestBlobFSAccessControlHelpers(t *testing.T) {
+	a := assert.New(t)
+	fa := NewFrameworkAsserter(t)
+
+	var setACL string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Query().Get("action") {
+		case "getAccessControl":
+			w.Header().Set("x-ms-owner", "$superuser")
+			w.Header().Set("x-ms-group", "$superuser")
+			w.Header().Set("x-ms-permissions", "rwxr-x---")
+			w.Header().Set("x-ms-acl", "user::rwx,group::r-x,other::---,user:"+testACLIdentity+":r--")
+		case "setAccessControl":
+			setACL = r.Header.Get("x-ms-acl")
+		case "setAccessControlRecursive":
+			a.Equal("set", r.URL.Query().Get("mode"))
+			a.Equal("true", r.URL.Query().Get("forceFlag")) // failures are reported rather than ending the walk
+			setACL = r.Header.Get("x-ms-acl")
+			_ = json.NewEncoder(w).Encode(map[string]any{
+				"directoriesSuccessful": 2,
+				"filesSuccessful":       3,
+				"failureCount":          1,
+				"failedEntries":         []map[string]string{{"name": "dir/locked", "type": "FILE", "errorMessage": "This request is not authorized"}},
+			})
+		default:
+			w.WriteHeader(http.StatusBadRequest)
+		}
+	}))
+	defer srv.Close()
+
+	client, err := filesystem.NewClientWithNoCredential(srv.URL+"/fs", nil)
+	a.NoError(err)
+	fs := &BlobFSFileSystemResourceManager{containerName: "fs", internalClient: client}
+	dir := fs.GetObject(fa, "dir", common.EEntityType.Folder())
+
+	control := GetBlobFSACL(fa, dir)
+	a.Equal("$superuser", control.Owner)
+	a.Equal("rwxr-x---", control.Permissions)
+	entry, ok := control.Entry(false, "user", testACLIdentity)
+	a.True(ok)
+	a.Equal("r--", entry.Permissions)
+
+	SetBlobFSACL(fa, dir, "user::rwx,group::---,other::---")
+	a.Equal("user::rwx,group::---,other::---", setACL)
+
+	result := SetBlobFSAccessControlRecursive(fa, dir, "user::rwx,group::r-x,other::---")
+	a.Equal("user::rwx,group::r-x,other::---", setACL)
+	a.Equal(BlobFSAccessControlRecursiveResult{
+		DirectoriesSuccessful: 2,
+		FilesSuccessful:       3,
+		FailureCount:          1,
+		FailedEntries:         []BlobFSACLFailedEntry{{Name: "dir/locked", Type: "FILE", ErrorMessage: "This request is not authorized"}},
+	}, result)
+
+	// Dry runs never reach the object.
+	a.Equal(BlobFSAccessControl{}, GetBlobFSACL(&ScenarioVariationManager{}, nil))
+}
+
+type BlobFSACLSuite struct{}
+
+// Scenario_DefaultACLInheritance gives a directory a default ACL, and checks a file created beneath it inherits the entries as its own.
+func (s *BlobFSACLSuite) Scenario_DefaultACLInheritance(svm *ScenarioVariationManager) {
+	fs := CreateResource[ContainerResourceManager](svm, GetAccount(svm, PrimaryHNSAcct).GetService(svm, common.ELocation.BlobFS()), ResourceDefinitionContainer{})
+
+	parentACL := FormatBlobFSACL([]BlobFSACLEntry{
+		{Type: "user", Permissions: "rwx"},
+		{Type: "group", Permissions: "r-x"},
+		{Type: "other", Permissions: "---"},
+		{Default: true, Type: "user", Permissions: "rwx"},
+		{Default: true, Type: "group", Permissions: "r-x"},
+		{Default: true, Type: "other", Permissions: "---"},
+		{Default: true, Type: "user", Identity: testACLIdentity, Permissions: "r-x"},
+		{Default: true, Type: "mask", Permissions: "r-x"},
+	})
+	parent := CreateResource[ObjectResourceManager](svm, fs, ResourceDefinitionObject{
+		ObjectName:       pointerTo("parent"),
+		ObjectProperties: ObjectProperties{EntityType: common.EEntityType.Folder(), BlobFSProperties: BlobFSProperties{ACL: &parentACL}},
+	})
+	child := CreateResource[ObjectResourceManager](svm, fs, ResourceDefinitionObject{
+		ObjectName: pointerTo("parent/child"),
+		Body:       NewRandomObjectContentContainer(svm, SizeFromString("1K")),
+	})
+
+	parentControl := GetBlobFSACL(svm, parent)
+	childControl := GetBlobFSACL(svm, child)
+	if svm.Dryrun() {
+		return
+	}
+
+	svm.Assert("parent default ACL", Equal{}, len(parentControl.DefaultACL()), 5)
+
+	inherited, ok := childControl.Entry(false, "user", testACLIdentity)
+	svm.Assert("child inherits the named user entry", Equal{}, ok, true)
+	svm.Assert("inherited permissions", Equal{}, inherited.Permissions, "r-x")
+	svm.Assert("files carry no default ACL", Equal{}, len(childControl.DefaultACL()), 0)
+}
@@ -0,0 +1,52 @@
+package e2etest
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+
+	blobsas "github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/sas"
+	"github.com/Azure/azure-storage-azcopy/v10/common"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEmulatorAccountResourceManager(t *testing.T) {
+	a := assert.New(t)
+	fa := NewFrameworkAsserter(t)
+	endpoint, err := url.Parse("http://127.0.0.1:10000")
+	a.NoError(err)
+	acct := &AzureAccountResourceManager{
+		accountName:      "devstoreaccount1",
+		accountKey:       "YWNjb3VudGtleQ==",
+		accountType:      EAccountType.Standard(),
+		emulatorEndpoint: endpoint,
+	}
+
+	a.Equal([]common.Location{common.ELocation.Blob()}, acct.AvailableServices())
+
+	svc := acct.GetService(fa, common.ELocation.Blob())
+	a.Equal("http://127.0.0.1:10000/devstoreaccount1", svc.URI())
+
+	cont := svc.GetContainer("container")
+	a.Equal("devstoreaccount1/Blob/container", cont.Canon())
+
+	// SAS tokens keep the emulator's scheme and path-style account, and permit the plain HTTP it's served over.
+	for _, uri := range []string{
+		cont.URI(GetURIOptions{AzureOpts: AzureURIOpts{WithSAS: true}}),
+		cont.URI(GetURIOptions{AzureOpts: AzureURIOpts{WithSAS: true, AccountSAS: true}}),
+		acct.ApplyAccountSAS(fa, common.ELocation.Blob(), AccountSASOptions{
+			Services:      AccountSASServices{Blob: true},
+			ResourceTypes: blobsas.AccountResourceTypes{Container: true},
+		}),
+	} {
+		base, query, _ := strings.Cut(uri, "?")
+		a.True(strings.HasPrefix(base, "http://127.0.0.1:10000/devstoreaccount1/"), uri)
+		params, err := url.ParseQuery(query)
+		a.NoError(err)
+		a.Equal(string(blobsas.ProtocolHTTPSandHTTP), params.Get("spr"), uri)
+		a.NotEmpty(params.Get("sig"), uri)
+	}
+
+	// An explicitly requested scheme still wins.
+	a.True(strings.HasPrefix(cont.URI(GetURIOptions{AzureOpts: AzureURIOpts{WithSAS: true}, RemoteOpts: RemoteURIOpts{Scheme: "https"}}), "https://127.0.0.1:10000/devstoreaccount1/container?"))
+}
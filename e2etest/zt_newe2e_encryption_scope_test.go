@@ -0,0 +1,168 @@
+package e2etest
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/container"
+	"github.com/Azure/azure-storage-azcopy/v10/common"
+	"github.com/stretchr/testify/assert"
+)
+
+func init() {
+	suiteManager.RegisterSuite(&EncryptionScopeSuite{})
+}
+
+func TestARMEncryptionScope(t *testing.T) {
+	a := assert.New(t)
+
+	var methods []string
+	var bodies []ARMEncryptionScopeResource
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		a.Equal("/subscriptions/sub/resourcegroups/rg/providers/Microsoft.Storage/storageAccounts/acct/encryptionScopes/scope", r.URL.Path)
+		a.Equal("2023-01-01", r.URL.Query().Get("api-version"))
+
+		var body ARMEncryptionScopeResource
+		if buf, _ := io.ReadAll(r.Body); len(buf) != 0 {
+			a.NoError(json.Unmarshal(buf, &body))
+		}
+		methods = append(methods, r.Method)
+		bodies = append(bodies, body)
+
+		state := common.Iff(body.Properties.State != "", body.Properties.State, ARMEncryptionScopeStateEnabled)
+		_, _ = w.Write([]byte(`{"name":"scope","properties":{"source":"Microsoft.Storage","state":"` + state + `"}}`))
+	}))
+	defer srv.Close()
+
+	target, _ := url.Parse(srv.URL)
+	acct := &ARMStorageAccount{
+		ARMResourceGroup: &ARMResourceGroup{
+			ARMSubscription: &ARMSubscription{
+				ARMClient: &ARMClient{
+					OAuth:      staticAccessToken("token"),
+					HttpClient: &http.Client{Transport: redirectTransport{target: target}},
+				},
+				SubscriptionID: "sub",
+			},
+			ResourceGroupName: "rg",
+		},
+		AccountName: "acct",
+	}
+
+	// The resource manager creates scopes with Microsoft-managed keys.
+	rm := &AzureAccountResourceManager{accountName: "acct", armClient: acct}
+	a.Equal("scope", rm.EnsureEncryptionScope(NewFrameworkAsserter(t), "scope"))
+
+	scope := &ARMEncryptionScope{ARMStorageAccount: acct, ScopeName: "scope"}
+	props, err := scope.GetProperties()
+	a.NoError(err)
+	a.Equal(ARMEncryptionScopeSourceStorage, props.Properties.Source)
+
+	props, err = scope.SetState(ARMEncryptionScopeStateDisabled)
+	a.NoError(err)
+	a.Equal(ARMEncryptionScopeStateDisabled, props.Properties.State)
+
+	a.Equal([]string{http.MethodPut, http.MethodGet, http.MethodPatch}, methods)
+	a.Equal(ARMEncryptionScopeProperties{Source: ARMEncryptionScopeSourceStorage, State: ARMEncryptionScopeStateEnabled}, bodies[0].Properties)
+	a.Equal(ARMEncryptionScopeProperties{State: ARMEncryptionScopeStateDisabled}, bodies[2].Properties)
+
+	// Without ARM, there's no way to make a scope.
+	var skipped bool
+	t.Run("NoARM", func(t *testing.T) {
+		t.Cleanup(func() { skipped = t.Skipped() })
+		(&AzureAccountResourceManager{accountName: "acct"}).EnsureEncryptionScope(NewFrameworkAsserter(t), "scope")
+	})
+	a.True(skipped)
+	a.Equal("scope", GetEncryptionScope(&ScenarioVariationManager{}, &MockAccountResourceManager{}, "scope"))
+}
+
+func TestBlobEncryptionScopeProperties(t *testing.T) {
+	a := assert.New(t)
+	fa := NewFrameworkAsserter(t)
+
+	var uploadedScope string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodPut:
+			uploadedScope = r.Header.Get("x-ms-encryption-scope")
+			w.WriteHeader(http.StatusCreated)
+		case r.URL.Query().Get("comp") == "list":
+			_, _ = w.Write([]byte(`<?xml version="1.0" encoding="utf-8"?><EnumerationResults ContainerName="container"><Blobs>` +
+				`<Blob><Name>blob</Name><Properties><EncryptionScope>scope</EncryptionScope></Properties></Blob>` +
+				`</Blobs><NextMarker /></EnumerationResults>`))
+		case r.URL.Query().Get("comp") == "tags":
+			_, _ = w.Write([]byte(`<?xml version="1.0" encoding="utf-8"?><Tags><TagSet /></Tags>`))
+		case r.Method == http.MethodHead:
+			w.Header().Set("x-ms-encryption-scope", "scope")
+			w.Header().Set("x-ms-blob-type", "BlockBlob")
+		default:
+			w.WriteHeader(http.StatusBadRequest)
+		}
+	}))
+	defer srv.Close()
+
+	acct := &AzureAccountResourceManager{accountName: "acct", accountKey: "YWNjb3VudGtleQ=="}
+	containerClient, err := container.NewClientWithNoCredential(srv.URL+"/acct/container", nil)
+	a.NoError(err)
+	ctr := &BlobContainerResourceManager{internalAccount: acct, containerName: "container", internalClient: containerClient}
+	obj := ctr.GetObject(fa, "blob", common.EEntityType.File())
+
+	obj.Create(fa, NewRandomObjectContentContainer(fa, SizeFromString("1K")), ObjectProperties{
+		BlobProperties: BlobProperties{EncryptionScope: pointerTo("scope")},
+	})
+	a.Equal("scope", uploadedScope)
+
+	a.Equal(pointerTo("scope"), obj.GetProperties(fa).BlobProperties.EncryptionScope)
+	a.Equal(pointerTo("scope"), ctr.ListObjects(fa, "", true)["blob"].BlobProperties.EncryptionScope)
+}
+
+type EncryptionScopeSuite struct{}
+
+// Scenario_CopyWithEncryptionScope uploads with --cpk-by-name, AzCopy's flag for writing blobs with an encryption scope.
+// Every blob written must report the scope, folder stubs included.
+func (s *EncryptionScopeSuite) Scenario_CopyWithEncryptionScope(svm *ScenarioVariationManager) {
+	dstService := GetRootResource(svm, common.ELocation.Blob())
+	scope := GetEncryptionScope(svm, dstService.Account(), "azcopye2escope")
+
+	srcContainer := CreateResource[ContainerResourceManager](svm, GetRootResource(svm, common.ELocation.Local()), ResourceDefinitionContainer{
+		Objects: ObjectResourceMappingFlat{
+			"foo":     ResourceDefinitionObject{Body: NewRandomObjectContentContainer(svm, SizeFromString("1K"))},
+			"bar":     ResourceDefinitionObject{ObjectProperties: ObjectProperties{EntityType: common.EEntityType.Folder()}},
+			"bar/baz": ResourceDefinitionObject{Body: NewRandomObjectContentContainer(svm, SizeFromString("10K"))},
+		},
+	})
+	dstContainer := CreateResource[ContainerResourceManager](svm, dstService, ResourceDefinitionContainer{})
+
+	RunAzCopy(
+		svm,
+		AzCopyCommand{
+			Verb:    AzCopyVerbCopy,
+			Targets: []ResourceManager{srcContainer, dstContainer},
+			Flags: CopyFlags{
+				CopySyncCommonFlags: CopySyncCommonFlags{
+					Recursive: pointerTo(true),
+					CPKByName: pointerTo(scope),
+				},
+				AsSubdir:              pointerTo(false),
+				IncludeDirectoryStubs: pointerTo(true),
+			},
+		})
+
+	for name, eType := range map[string]common.EntityType{"foo": common.EEntityType.File(), "bar": common.EEntityType.Folder(), "bar/baz": common.EEntityType.File()} {
+		ValidateResource[ObjectResourceManager](svm, dstContainer.GetObject(svm, name, eType), ResourceDefinitionObject{
+			ObjectProperties: ObjectProperties{
+				EntityType:     eType,
+				BlobProperties: BlobProperties{EncryptionScope: pointerTo(scope)},
+			},
+		}, false)
+	}
+
+	// Nothing else was written, with or without the scope.
+	if !svm.Dryrun() {
+		svm.Assert("destination blobs", Equal{}, len(dstContainer.ListObjects(svm, "", true)), 3)
+	}
+}
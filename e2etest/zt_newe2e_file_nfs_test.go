@@ -0,0 +1,123 @@
+package e2etest
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azfile/share"
+	"github.com/Azure/azure-storage-azcopy/v10/common"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeFileShare serves one share speaking protocol, keeping the POSIX properties files and directories were created with.
+type fakeFileShare struct {
+	mut      sync.Mutex
+	protocol string
+	versions []string
+	posix    map[string]http.Header
+}
+
+func (f *fakeFileShare) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mut.Lock()
+	defer f.mut.Unlock()
+
+	q := r.URL.Query()
+	switch {
+	case q.Get("restype") == "share" && r.Method == http.MethodPut:
+		f.protocol = r.Header.Get("x-ms-enabled-protocols")
+		w.WriteHeader(http.StatusCreated)
+	case q.Get("restype") == "share":
+		if f.protocol == "" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("x-ms-enabled-protocols", f.protocol)
+	case r.Method == http.MethodPut && q.Get("comp") == "range":
+		w.WriteHeader(http.StatusCreated)
+	case r.Method == http.MethodPut:
+		f.versions = append(f.versions, r.Header.Get("x-ms-version"))
+		f.posix[r.URL.Path] = http.Header{}
+		for _, k := range []string{"x-ms-mode", "x-ms-owner", "x-ms-group"} {
+			if v := r.Header.Get(k); v != "" {
+				f.posix[r.URL.Path].Set(k, v)
+			}
+		}
+		w.WriteHeader(http.StatusCreated)
+	case r.Method == http.MethodHead, r.Method == http.MethodGet: // directories are read with GET
+		f.versions = append(f.versions, r.Header.Get("x-ms-version"))
+		for k, v := range f.posix[r.URL.Path] {
+			w.Header()[k] = v
+		}
+	default:
+		w.WriteHeader(http.StatusBadRequest)
+	}
+}
+
+func newFakeFileShare(t *testing.T, accountType AccountType) (*fakeFileShare, *FileShareResourceManager) {
+	fake := &fakeFileShare{posix: map[string]http.Header{}}
+	srv := httptest.NewServer(fake)
+	t.Cleanup(srv.Close)
+
+	acct := &AzureAccountResourceManager{accountName: "acct", accountKey: "YWNjb3VudGtleQ==", accountType: accountType}
+	client, err := share.NewClientWithNoCredential(srv.URL+"/acct/share", nil)
+	assert.NoError(t, err)
+	svc := &FileServiceResourceManager{internalAccount: acct}
+
+	return fake, &FileShareResourceManager{internalAccount: acct, Service: svc, containerName: "share", internalClient: client}
+}
+
+func TestFileNFSPosixProperties(t *testing.T) {
+	a := assert.New(t)
+	fa := NewFrameworkAsserter(t)
+
+	fake, shr := newFakeFileShare(t, EAccountType.PremiumFileShares())
+	shr.Create(fa, ContainerProperties{FileContainerProperties: FileContainerProperties{
+		EnabledProtocols: pointerTo(FileShareProtocolNFS),
+		RootSquash:       pointerTo(share.RootSquashRootSquash),
+	}})
+	a.Equal(FileShareProtocolNFS, fake.protocol)
+	a.True(shr.IsNFS())
+
+	posix := FileProperties{FileMode: pointerTo("0640"), Owner: pointerTo("1000"), Group: pointerTo("1001")}
+	dir := shr.GetObject(fa, "dir", common.EEntityType.Folder())
+	dir.Create(fa, nil, ObjectProperties{FileProperties: FileProperties{FileMode: pointerTo("0755")}})
+	obj := shr.GetObject(fa, "dir/file", common.EEntityType.File())
+	obj.Create(fa, NewRandomObjectContentContainer(fa, SizeFromString("1K")), ObjectProperties{FileProperties: posix})
+
+	props := obj.GetProperties(fa).FileProperties
+	a.Equal(posix.FileMode, props.FileMode)
+	a.Equal(posix.Owner, props.Owner)
+	a.Equal(posix.Group, props.Group)
+
+	props = dir.GetProperties(fa).FileProperties
+	a.Equal(pointerTo("0755"), props.FileMode)
+	a.Nil(props.Owner)
+
+	// Every request touching POSIX properties asks for a service version that knows them.
+	a.Len(fake.versions, 4)
+	for _, v := range fake.versions {
+		a.Equal(fileNFSServiceVersion, v)
+	}
+
+	// NFS shares don't take SAS.
+	a.NotPanics(func() { obj.URI() })
+	a.PanicsWithValue("NFS share share doesn't accept SAS tokens; address it with OAuth instead (GetResourceOptions{Keyless: true}, or EExplicitCredentialType.OAuth())", func() {
+		obj.URI(GetURIOptions{AzureOpts: AzureURIOpts{WithSAS: true, AccountSAS: true}})
+	})
+	a.Panics(func() { shr.URI(GetURIOptions{AzureOpts: AzureURIOpts{WithSAS: true, AccountSAS: true}}) })
+}
+
+func TestFileSMBShareStillTakesSAS(t *testing.T) {
+	a := assert.New(t)
+
+	// A share that's already there (SMB, by default) is asked about its protocol once.
+	fake, shr := newFakeFileShare(t, EAccountType.Standard())
+	fake.protocol = FileShareProtocolSMB
+	a.False(shr.IsNFS())
+	fake.protocol = FileShareProtocolNFS
+	a.False(shr.IsNFS())
+
+	a.Contains(shr.URI(GetURIOptions{AzureOpts: AzureURIOpts{WithSAS: true, AccountSAS: true}}), "sig=")
+}
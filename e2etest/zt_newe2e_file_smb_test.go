@@ -0,0 +1,115 @@
+package e2etest
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azfile/file"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azfile/share"
+	"github.com/Azure/azure-storage-azcopy/v10/common"
+	"github.com/stretchr/testify/assert"
+)
+
+const testSMBPermission = "O:S-1-5-18G:S-1-5-18D:AI(A;ID;FA;;;S-1-5-18)(A;ID;FA;;;S-1-5-32-544)"
+
+// fakeSMBShare serves one SMB share, storing permissions by key and echoing back the SMB properties files were created with.
+type fakeSMBShare struct {
+	mut         sync.Mutex
+	permissions map[string]string
+	smb         map[string]http.Header
+}
+
+func (f *fakeSMBShare) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mut.Lock()
+	defer f.mut.Unlock()
+
+	q := r.URL.Query()
+	switch {
+	case q.Get("comp") == "filepermission" && r.Method == http.MethodPut:
+		var body struct {
+			Permission string `json:"permission"`
+		}
+		buf, _ := io.ReadAll(r.Body)
+		_ = json.Unmarshal(buf, &body)
+		key := "key" + string(rune('0'+len(f.permissions)))
+		f.permissions[key] = body.Permission
+		w.Header().Set("x-ms-file-permission-key", key)
+		w.WriteHeader(http.StatusCreated)
+	case q.Get("comp") == "filepermission":
+		_ = json.NewEncoder(w).Encode(map[string]string{"permission": f.permissions[r.Header.Get("x-ms-file-permission-key")]})
+	case q.Get("restype") == "share":
+		w.Header().Set("x-ms-enabled-protocols", FileShareProtocolSMB)
+	case r.Method == http.MethodPut && q.Get("comp") == "range":
+		w.WriteHeader(http.StatusCreated)
+	case r.Method == http.MethodPut:
+		h := http.Header{}
+		for _, k := range []string{"x-ms-file-attributes", "x-ms-file-creation-time", "x-ms-file-last-write-time", "x-ms-file-permission-key"} {
+			if v := r.Header.Get(k); v != "" && v != "now" {
+				h.Set(k, v)
+			}
+		}
+		if perm := r.Header.Get("x-ms-file-permission"); perm != "" && perm != "inherit" {
+			key := "key" + string(rune('0'+len(f.permissions)))
+			f.permissions[key] = perm
+			h.Set("x-ms-file-permission-key", key)
+		}
+		f.smb[r.URL.Path] = h
+		w.WriteHeader(http.StatusCreated)
+	case r.Method == http.MethodHead, r.Method == http.MethodGet:
+		for k, v := range f.smb[r.URL.Path] {
+			w.Header()[k] = v
+		}
+	default:
+		w.WriteHeader(http.StatusBadRequest)
+	}
+}
+
+func TestFileSMBPropertiesRoundTrip(t *testing.T) {
+	a := assert.New(t)
+	fa := NewFrameworkAsserter(t)
+
+	fake := &fakeSMBShare{permissions: map[string]string{}, smb: map[string]http.Header{}}
+	srv := httptest.NewServer(fake)
+	defer srv.Close()
+
+	acct := &AzureAccountResourceManager{accountName: "acct", accountKey: "YWNjb3VudGtleQ=="}
+	client, err := share.NewClientWithNoCredential(srv.URL+"/acct/share", nil)
+	a.NoError(err)
+	shr := &FileShareResourceManager{internalAccount: acct, Service: &FileServiceResourceManager{internalAccount: acct}, containerName: "share", internalClient: client}
+
+	// A permission stored on the share is referenced by its key.
+	key := shr.CreatePermission(fa, testSMBPermission)
+	a.NotEmpty(key)
+	a.Equal(testSMBPermission, shr.GetPermission(fa, key))
+
+	created, written := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC), time.Date(2021, 6, 7, 8, 9, 10, 0, time.UTC)
+	requested := FileProperties{
+		FileAttributes:    pointerTo("ReadOnly|Archive"),
+		FileCreationTime:  &created,
+		FileLastWriteTime: &written,
+		FilePermissionKey: &key,
+	}
+	obj := shr.GetObject(fa, "file", common.EEntityType.File())
+	obj.Create(fa, NewRandomObjectContentContainer(fa, SizeFromString("1K")), ObjectProperties{FileProperties: requested})
+
+	props := obj.GetProperties(fa).FileProperties
+	wantAttrs, err := file.ParseNTFSFileAttributes(requested.FileAttributes)
+	a.NoError(err)
+	gotAttrs, err := file.ParseNTFSFileAttributes(props.FileAttributes)
+	a.NoError(err)
+	a.Equal(wantAttrs, gotAttrs)
+	a.True(created.Equal(DerefOrZero(props.FileCreationTime)))
+	a.True(written.Equal(DerefOrZero(props.FileLastWriteTime)))
+	a.Equal(&key, props.FilePermissionKey)
+	a.Equal(pointerTo(testSMBPermission), props.FilePermissions)
+
+	// Inline permissions read back the same.
+	dir := shr.GetObject(fa, "dir", common.EEntityType.Folder())
+	dir.Create(fa, nil, ObjectProperties{FileProperties: FileProperties{FilePermissions: pointerTo(testSMBPermission)}})
+	a.Equal(pointerTo(testSMBPermission), dir.GetProperties(fa).FileProperties.FilePermissions)
+}
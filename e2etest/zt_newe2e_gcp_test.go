@@ -0,0 +1,87 @@
+package e2etest
+
+import (
+	"testing"
+
+	"github.com/Azure/azure-storage-azcopy/v10/common"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/api/option"
+)
+
+func init() {
+	suiteManager.RegisterSuite(&GCPSuite{})
+}
+
+func TestGCPResourceManagerURI(t *testing.T) {
+	a := assert.New(t)
+	acct, err := NewGCPAccountResourceManager("project", option.WithoutAuthentication())
+	a.NoError(err)
+	svc := &GCPServiceResourceManager{internalAccount: acct}
+	obj := svc.GetContainer("bucket").GetObject(nil, "dir/object", common.EEntityType.File())
+
+	a.Equal("https://storage.cloud.google.com/bucket/dir/object", obj.URI())
+	a.Equal("http://storage.cloud.google.com/bucket", obj.Parent().URI(GetURIOptions{RemoteOpts: RemoteURIOpts{Scheme: "http"}}))
+	a.Equal("gcp/GCP/bucket/dir/object", obj.Canon())
+}
+
+func TestExpectedMetadataFromGCP(t *testing.T) {
+	a := assert.New(t)
+
+	expected := expectedMetadataFromGCP(common.Metadata{
+		"Origin":     pointerTo("gcs"),
+		"has-dash":   pointerTo("dropped"),
+		"1stDigit":   pointerTo("dropped"),
+		"_underline": pointerTo("kept"),
+	})
+
+	a.Equal(common.Metadata{
+		"origin":     pointerTo("gcs"),
+		"_underline": pointerTo("kept"),
+	}, expected)
+}
+
+type GCPSuite struct{}
+
+func (s *GCPSuite) Scenario_CopyBucketToContainer(svm *ScenarioVariationManager) {
+	objects := ObjectResourceMappingFlat{
+		"foo": ResourceDefinitionObject{
+			Body: NewRandomObjectContentContainer(svm, SizeFromString("1K")),
+		},
+		"bar/baz": ResourceDefinitionObject{
+			Body: NewRandomObjectContentContainer(svm, SizeFromString("10K")),
+			ObjectProperties: ObjectProperties{
+				Metadata: common.Metadata{
+					"origin":   pointerTo("gcs"),
+					"has-dash": pointerTo("not a valid Azure metadata key"),
+				},
+			},
+		},
+	}
+
+	srcBucket := CreateResource[ContainerResourceManager](svm, GetRootResource(svm, common.ELocation.GCP()), ResourceDefinitionContainer{
+		Objects: objects,
+	})
+	dstContainer := CreateResource[ContainerResourceManager](svm, GetRootResource(svm, common.ELocation.Blob()), ResourceDefinitionContainer{})
+
+	RunAzCopy(
+		svm,
+		AzCopyCommand{
+			Verb:    AzCopyVerbCopy,
+			Targets: []ResourceManager{srcBucket, dstContainer},
+			Flags: CopyFlags{
+				CopySyncCommonFlags: CopySyncCommonFlags{
+					Recursive: pointerTo(true),
+				},
+				AsSubdir: pointerTo(false),
+			},
+		})
+
+	for name, def := range objects {
+		dstObj := dstContainer.GetObject(svm, name, common.EEntityType.File())
+		ValidateResource[ObjectResourceManager](svm, dstObj, ResourceDefinitionObject{Body: def.Body}, true)
+
+		if srcObj, ok := srcBucket.GetObject(svm, name, common.EEntityType.File()).(*GCPObjectResourceManager); ok {
+			ValidateMetadataCopiedFromGCP(svm, srcObj, dstObj)
+		}
+	}
+}
@@ -0,0 +1,88 @@
+package e2etest
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHTTPLogRedaction(t *testing.T) {
+	a := assert.New(t)
+
+	const keysBody = `{"keys":[{"keyName":"key1","permissions":"FULL","value":"c2VjcmV0a2V5"}]}`
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("x-ms-request-id", "req-"+r.Method)
+		switch r.Method {
+		case http.MethodPost:
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(keysBody))
+		default:
+			w.WriteHeader(http.StatusForbidden)
+			_, _ = w.Write([]byte(`<Error><Code>AuthenticationFailed</Code><Message>Signature did not match: sig=c2lnbmF0dXJl</Message></Error>`))
+		}
+	}))
+	defer srv.Close()
+
+	var out bytes.Buffer
+	client := &http.Client{Transport: newHTTPLogTransport(nil, &out, &sync.Mutex{})}
+
+	req, err := http.NewRequest(http.MethodPost, srv.URL+"/listKeys?api-version=2023-01-01", nil)
+	a.NoError(err)
+	req.Header.Set("Authorization", "Bearer c2VjcmV0dG9rZW4=")
+	resp, err := client.Do(req)
+	a.NoError(err)
+	body, err := io.ReadAll(resp.Body)
+	a.NoError(err)
+	_ = resp.Body.Close()
+	a.Equal(keysBody, string(body), "logging must not consume the response body")
+
+	req, err = http.NewRequest(http.MethodPut, srv.URL+"/container/blob?sv=2021-08-06&sp=rw&sig=c2lnbmF0dXJl", nil)
+	a.NoError(err)
+	req.Header["authorization"] = []string{"SharedKey acct:c2VjcmV0a2V5"} // not canonicalized
+	req.Header.Set("x-ms-copy-source", "https://src.blob.core.windows.net/c/b?sv=2021-08-06&sig=c291cmNlc2ln")
+	resp, err = client.Do(req)
+	a.NoError(err)
+	_ = resp.Body.Close()
+
+	logged := out.String()
+	for _, secret := range []string{"c2VjcmV0dG9rZW4", "c2VjcmV0a2V5", "c2lnbmF0dXJl", "c291cmNlc2ln"} {
+		a.NotContains(logged, secret)
+	}
+
+	var entries []httpLogEntry
+	scanner := bufio.NewScanner(strings.NewReader(logged))
+	for scanner.Scan() {
+		var entry httpLogEntry
+		a.NoError(json.Unmarshal(scanner.Bytes(), &entry))
+		entries = append(entries, entry)
+	}
+	if !a.Len(entries, 2) {
+		return
+	}
+
+	listKeys, put := entries[0], entries[1]
+
+	a.Equal(http.MethodPost, listKeys.Method)
+	a.Equal(http.StatusOK, listKeys.StatusCode)
+	a.Equal("req-POST", listKeys.RequestID)
+	a.Equal(httpLogRedacted, listKeys.Header["Authorization"])
+	a.Contains(listKeys.Body, `"value":"REDACTED"`)
+	a.GreaterOrEqual(listKeys.DurationMS, int64(0))
+
+	a.Equal(http.MethodPut, put.Method)
+	a.Equal(http.StatusForbidden, put.StatusCode)
+	a.Equal("req-PUT", put.RequestID)
+	a.Contains(put.URL, "sig=REDACTED")
+	a.Contains(put.URL, "sp=rw")
+	a.Equal(httpLogRedacted, put.Header["authorization"])
+	a.Contains(put.Header["X-Ms-Copy-Source"], "sig=REDACTED")
+	a.Contains(put.Body, "AuthenticationFailed")
+}
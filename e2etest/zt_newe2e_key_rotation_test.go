@@ -0,0 +1,188 @@
+package e2etest
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/Azure/azure-storage-azcopy/v10/common"
+	"github.com/stretchr/testify/assert"
+)
+
+func init() {
+	suiteManager.RegisterSuite(&KeyRotationSuite{})
+}
+
+func TestRotateAccountKey(t *testing.T) {
+	a := assert.New(t)
+
+	var rotated []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		a.Equal(http.MethodPost, r.Method)
+		a.Equal("/subscriptions/sub/resourcegroups/rg/providers/Microsoft.Storage/storageAccounts/acct/regenerateKey", r.URL.Path)
+
+		var body struct{ KeyName string }
+		buf, _ := io.ReadAll(r.Body)
+		a.NoError(json.Unmarshal(buf, &body))
+		rotated = append(rotated, body.KeyName)
+
+		_ = json.NewEncoder(w).Encode(ARMStorageAccountListKeysResult{Keys: []ARMStorageAccountKey{
+			{KeyName: "key1", Value: fmt.Sprintf("key1-v%d", len(rotated)), Permissions: ARMStorageAccountKeyPermissionFull},
+			{KeyName: "key2", Value: fmt.Sprintf("key2-v%d", len(rotated)), Permissions: ARMStorageAccountKeyPermissionFull},
+		}})
+	}))
+	defer srv.Close()
+
+	target, _ := url.Parse(srv.URL)
+	acct := &AzureAccountResourceManager{
+		accountName:    "acct",
+		accountKey:     "key1-v0",
+		accountKeyName: "key1",
+		armClient: &ARMStorageAccount{
+			ARMResourceGroup: &ARMResourceGroup{
+				ARMSubscription: &ARMSubscription{
+					ARMClient: &ARMClient{
+						OAuth:      staticAccessToken("token"),
+						HttpClient: &http.Client{Transport: redirectTransport{target: target}},
+					},
+					SubscriptionID: "sub",
+				},
+				ResourceGroupName: "rg",
+			},
+			AccountName: "acct",
+		},
+	}
+
+	// Rotating the other key leaves the one in use alone.
+	acct.RotateKey(NewFrameworkAsserter(t), "key2")
+	a.Equal("key1-v0", acct.accountKey)
+
+	RotateAccountKey(NewFrameworkAsserter(t), acct, "key1")
+	a.Equal("key1-v2", acct.accountKey)
+	a.Equal([]string{"key2", "key1"}, rotated)
+
+	// Without ARM, there's no rotating keys; dry runs don't try.
+	var skipped bool
+	t.Run("NoARM", func(t *testing.T) {
+		t.Cleanup(func() { skipped = t.Skipped() })
+		(&AzureAccountResourceManager{accountName: "acct", accountKey: "key"}).RotateKey(NewFrameworkAsserter(t), "key1")
+	})
+	a.True(skipped)
+	RotateAccountKey(&ScenarioVariationManager{}, &MockAccountResourceManager{}, "key1")
+}
+
+func TestAzCopyStdoutJobSummaries(t *testing.T) {
+	a := assert.New(t)
+
+	message := func(msgType string, summary common.ListJobSummaryResponse) string {
+		content, err := json.Marshal(summary)
+		a.NoError(err)
+		line, err := json.Marshal(common.JsonOutputTemplate{MessageType: msgType, MessageContent: string(content)})
+		a.NoError(err)
+		return string(line) + "\n"
+	}
+
+	var progress []float32
+	stdout := &AzCopyStdout{onProgress: func(summary common.ListJobSummaryResponse) {
+		progress = append(progress, summary.PercentComplete)
+	}}
+	jobID := common.NewJobID()
+	output := `{"MessageType":"Init","MessageContent":"{}"}` + "\n" +
+		"not json at all\n" +
+		message("Progress", common.ListJobSummaryResponse{JobID: jobID, PercentComplete: 10}) +
+		message("Progress", common.ListJobSummaryResponse{JobID: jobID, PercentComplete: 55.5}) +
+		message("EndOfJob", common.ListJobSummaryResponse{
+			JobID:           jobID,
+			JobStatus:       common.EJobStatus.CompletedWithErrors(),
+			PercentComplete: 100,
+			FailedTransfers: []common.TransferDetail{{Src: "a", ErrorCode: http.StatusForbidden}, {Src: "b", ErrorCode: http.StatusForbidden}},
+		})
+
+	// AzCopy's output arrives in arbitrary pieces.
+	for len(output) > 0 {
+		n := 7
+		if n > len(output) {
+			n = len(output)
+		}
+		_, _ = stdout.Write([]byte(output[:n]))
+		output = output[n:]
+
+		if len(progress) == 1 {
+			a.False(stdout.JobEnded)
+			a.EqualValues(10, stdout.JobSummary.PercentComplete)
+		}
+	}
+
+	a.Equal([]float32{10, 55.5}, progress) // the end of the job isn't progress
+	a.True(stdout.JobEnded)
+	a.Equal(jobID, stdout.JobSummary.JobID)
+
+	fa := NewFrameworkAsserter(t)
+	ValidateJobStatus(fa, stdout, common.EJobStatus.CompletedWithErrors())
+	ValidateFailedTransfers(fa, stdout, http.StatusForbidden)
+
+	// Dry runs have no output to check.
+	ValidateJobStatus(&ScenarioVariationManager{}, nil, common.EJobStatus.Completed())
+	ValidateFailedTransfers(&ScenarioVariationManager{}, nil, http.StatusForbidden)
+}
+
+type KeyRotationSuite struct{}
+
+// Scenario_RotateKeyMidJob rotates the key that signed the destination SAS partway through an upload.
+// The remaining transfers must fail authentication, and resuming the job with a freshly signed SAS must finish it.
+func (s *KeyRotationSuite) Scenario_RotateKeyMidJob(svm *ScenarioVariationManager) {
+	acct := CreateAccount(svm, EAccountType.Standard(), nil) // rotating the key of a shared account would break other scenarios
+	dstContainer := CreateResource[ContainerResourceManager](svm, acct.GetService(svm, common.ELocation.Blob()), ResourceDefinitionContainer{})
+
+	objects := ObjectResourceMappingFlat{}
+	for i := 0; i < 20; i++ {
+		objects[fmt.Sprintf("file%02d", i)] = ResourceDefinitionObject{Body: NewRandomObjectContentContainer(svm, SizeFromString("1M"))}
+	}
+	srcContainer := CreateResource[ContainerResourceManager](svm, GetRootResource(svm, common.ELocation.Local()), ResourceDefinitionContainer{Objects: objects})
+
+	env := &AzCopyEnvironment{} // the resume must find the job's plan files
+	stdout, _ := RunAzCopy(svm, AzCopyCommand{
+		Verb:    AzCopyVerbCopy,
+		Targets: []ResourceManager{srcContainer, dstContainer},
+		Flags: CopyFlags{
+			CopySyncCommonFlags: CopySyncCommonFlags{
+				GlobalFlags: GlobalFlags{CapMbps: pointerTo(4.0)}, // ~40s, for the rotation to land mid-job
+				Recursive:   pointerTo(true),
+			},
+			AsSubdir: pointerTo(false),
+		},
+		Environment: env,
+		ProgressHooks: []AzCopyProgressHook{{
+			PercentComplete: 20,
+			Hook: func(a ScenarioAsserter) {
+				RotateAccountKey(a, acct, "key1") // the key CreateAccount signs with
+			},
+		}},
+		ShouldFail: true,
+	})
+	ValidateJobStatus(svm, stdout, common.EJobStatus.CompletedWithErrors())
+	ValidateFailedTransfers(svm, stdout, http.StatusForbidden)
+
+	// The destination's client predates the rotation, so it's fetched again with the new key.
+	dstContainer = acct.GetService(svm, common.ELocation.Blob()).GetContainer(dstContainer.ContainerName())
+	freshSAS, err := url.Parse(dstContainer.URI(GetURIOptions{AzureOpts: AzureURIOpts{WithSAS: true}}))
+	svm.NoError("parse destination URI", err)
+
+	var jobID string
+	if !svm.Dryrun() {
+		jobID = stdout.JobSummary.JobID.String()
+	}
+	stdout, _ = RunAzCopy(svm, AzCopyCommand{
+		Verb:        AzCopyVerbJobsResume,
+		Arguments:   []string{jobID},
+		Flags:       JobsResumeFlags{DestinationSAS: pointerTo(freshSAS.RawQuery)},
+		Environment: env,
+	})
+	ValidateJobStatus(svm, stdout, common.EJobStatus.Completed())
+
+	ValidateResource[ContainerResourceManager](svm, dstContainer, ResourceDefinitionContainer{Objects: objects}, true)
+}
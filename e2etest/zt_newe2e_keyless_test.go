@@ -0,0 +1,84 @@
+package e2etest
+
+import (
+	"testing"
+
+	"github.com/Azure/azure-storage-azcopy/v10/common"
+	"github.com/stretchr/testify/assert"
+)
+
+func init() {
+	suiteManager.RegisterSuite(&KeylessSuite{})
+}
+
+func TestGetServiceWithCredential(t *testing.T) {
+	a := assert.New(t)
+	fa := NewFrameworkAsserter(t)
+	acct := &AzureAccountResourceManager{accountName: "acct", accountKey: "YWNjb3VudGtleQ==", accountType: EAccountType.Standard()}
+	cred := NewOAuthCache(nil, "") // never asked for a token; nothing is sent
+
+	for _, loc := range []common.Location{common.ELocation.Blob(), common.ELocation.File(), common.ELocation.BlobFS()} {
+		cont := acct.GetServiceWithCredential(fa, loc, cred).GetContainer("container")
+		keylessAcct := GetTypeOrAssert[*AzureAccountResourceManager](fa, cont.Account())
+
+		a.True(keylessAcct.keyless(), loc.String())
+		a.Equal(cred, keylessAcct.tokenCredential, loc.String())
+		a.Equal("acct/"+loc.String()+"/container", cont.Canon(), loc.String())
+		a.PanicsWithValue("Account acct has no key to sign a SAS with; use OAuth, or a user delegation SAS.", func() {
+			cont.URI(GetURIOptions{AzureOpts: AzureURIOpts{WithSAS: true}})
+		}, loc.String())
+
+		// RunAzCopy falls back on OAuth, rather than attempting a SAS.
+		cmd := &AzCopyCommand{Environment: &AzCopyEnvironment{AutoLoginMode: pointerTo("SPN")}}
+		a.Equal(cont.URI(), cmd.applyTargetAuth(fa, cont), loc.String())
+	}
+
+	// The account it came from keeps its key.
+	a.False(acct.keyless())
+	a.Nil(acct.tokenCredential)
+}
+
+type KeylessSuite struct{}
+
+// Scenario_SharedKeyDisabled round-trips data through an account that refuses shared key auth, so every step (setup, transfer and validation) must be keyless.
+// Like user delegation, this relies upon the service principal holding a data-plane role (e.g. Storage Blob Data Owner) over the resource group.
+func (s *KeylessSuite) Scenario_SharedKeyDisabled(svm *ScenarioVariationManager) {
+	loc := ResolveVariation(svm, []common.Location{common.ELocation.Blob(), common.ELocation.BlobFS()})
+
+	if GlobalConfig.StaticResources() || PrimaryOAuthCache == nil {
+		svm.Skip("Disabling shared key access requires creating an account, which needs NEW_E2E_SUBSCRIPTION_ID")
+	}
+
+	acct := CreateAccount(svm, EAccountType.Standard(), &CreateAccountOptions{
+		ParamMutator: func(createParams *ARMStorageAccountCreateParams) {
+			createParams.Properties = &ARMStorageAccountCreateProperties{
+				AllowSharedKeyAccess: pointerTo(false),
+			}
+		},
+	})
+
+	var svc ServiceResourceManager
+	if azAcct, ok := acct.(*AzureAccountResourceManager); ok {
+		svc = azAcct.GetServiceWithCredential(svm, loc, PrimaryOAuthCache)
+	} else {
+		svc = acct.GetService(svm, loc) // dry runs get a mock
+	}
+
+	body := NewRandomObjectContentContainer(svm, SizeFromString("1K"))
+	srcObj := CreateResource[ObjectResourceManager](svm, GetRootResource(svm, common.ELocation.Local()), ResourceDefinitionObject{
+		ObjectName: pointerTo("test"),
+		Body:       body,
+	})
+	dstContainer := CreateResource[ContainerResourceManager](svm, svc, ResourceDefinitionContainer{})
+
+	RunAzCopy(
+		svm,
+		AzCopyCommand{
+			Verb:    AzCopyVerbCopy,
+			Targets: []ResourceManager{srcObj, dstContainer},
+		})
+
+	ValidateResource[ObjectResourceManager](svm, dstContainer.GetObject(svm, "test", common.EEntityType.File()), ResourceDefinitionObject{
+		Body: body,
+	}, true)
+}
@@ -0,0 +1,85 @@
+package e2etest
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-storage-azcopy/v10/common"
+	"github.com/stretchr/testify/assert"
+)
+
+func init() {
+	suiteManager.RegisterSuite(&LocalSuite{})
+}
+
+func TestLocalServiceResourceManager(t *testing.T) {
+	a := assert.New(t)
+	fa := NewFrameworkAsserter(t)
+
+	svc := GetTypeOrAssert[*LocalServiceResourceManager](fa, NewLocalService(fa))
+	defer os.RemoveAll(svc.RootPath)
+
+	mtime := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	tree := NewDirectoryTreeMapping(fa, DirectoryTreeOptions{Depth: 2, Breadth: 2, FilesPerFolder: 1, FileSize: "1K", IncludeFolders: true})
+	a.Len(tree, 13) // 1 + 2 + 4 files, and 2 + 4 folders
+	tree["dir0/dir1/file0"] = ResourceDefinitionObject{
+		ObjectProperties: ObjectProperties{EntityType: common.EEntityType.File(), FileProperties: FileProperties{FileLastWriteTime: &mtime}},
+		Body:             tree["dir0/dir1/file0"].Body,
+	}
+
+	cont := CreateResource[ContainerResourceManager](fa, svc, ResourceDefinitionContainer{
+		ContainerName: pointerTo("cont"),
+		Objects:       tree,
+	})
+	a.Equal(filepath.Join(svc.RootPath, "cont"), cont.URI())
+	a.Equal([]string{"cont"}, svc.ListContainers(fa))
+	a.Equal(svc, cont.Parent())
+
+	ValidateResource[ContainerResourceManager](fa, cont, ResourceDefinitionContainer{Objects: tree}, true)
+	fi, err := os.Stat(filepath.Join(svc.RootPath, "cont", "dir0", "dir1"))
+	a.NoError(err)
+	a.True(fi.IsDir())
+
+	props := cont.GetObject(fa, "dir0/dir1/file0", common.EEntityType.File()).GetProperties(fa)
+	a.True(mtime.Equal(*props.FileProperties.FileLastWriteTime))
+
+	sparse := CreateLocalSparseFile(fa, cont, "sparse/file", SizeFromString("1M"))
+	ValidateResource[ObjectResourceManager](fa, sparse, ResourceDefinitionObject{Body: NewZeroObjectContentContainer(SizeFromString("1M"))}, true)
+
+	obj := cont.GetObject(fa, "dir0/file0", common.EEntityType.File())
+	a.Equal(filepath.Join(svc.RootPath, "cont", "dir0", "file0"), obj.URI())
+	a.Equal("file://"+filepath.ToSlash(filepath.Join(svc.RootPath, "cont", "dir0", "file0")), obj.URI(GetURIOptions{LocalOpts: LocalURIOpts{AsFileURI: true}}))
+}
+
+type LocalSuite struct{}
+
+func (s *LocalSuite) Scenario_UploadDirectoryTree(svm *ScenarioVariationManager) {
+	tree := NewDirectoryTreeMapping(svm, DirectoryTreeOptions{Depth: 2, Breadth: 2, FilesPerFolder: 2, FileSize: "1K"})
+
+	srcContainer := CreateResource[ContainerResourceManager](svm, GetRootResource(svm, common.ELocation.Local()), ResourceDefinitionContainer{
+		Objects: tree,
+	})
+	tree["sparse"] = ResourceDefinitionObject{Body: NewZeroObjectContentContainer(SizeFromString("4M"))}
+	CreateLocalSparseFile(svm, srcContainer, "sparse", SizeFromString("4M"))
+
+	dstContainer := CreateResource[ContainerResourceManager](svm, GetRootResource(svm, ResolveVariation(svm, []common.Location{common.ELocation.Blob(), common.ELocation.File()})), ResourceDefinitionContainer{})
+
+	RunAzCopy(
+		svm,
+		AzCopyCommand{
+			Verb:    AzCopyVerbCopy,
+			Targets: []ResourceManager{srcContainer, dstContainer},
+			Flags: CopyFlags{
+				CopySyncCommonFlags: CopySyncCommonFlags{
+					Recursive: pointerTo(true),
+				},
+				AsSubdir: pointerTo(false),
+			},
+		})
+
+	ValidateResource[ContainerResourceManager](svm, dstContainer, ResourceDefinitionContainer{
+		Objects: tree,
+	}, true)
+}
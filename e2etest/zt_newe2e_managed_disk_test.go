@@ -0,0 +1,152 @@
+package e2etest
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/Azure/azure-storage-azcopy/v10/common"
+	"github.com/stretchr/testify/assert"
+)
+
+func init() {
+	suiteManager.RegisterSuite(&ManagedDiskSuite{})
+}
+
+func TestFixedVHDObjectContentContainer(t *testing.T) {
+	a := assert.New(t)
+	fa := NewFrameworkAsserter(t)
+
+	diskSize := SizeFromString("20M")
+	body := GetTypeOrAssert[*ObjectContentContainerBuffer](fa, NewFixedVHDObjectContentContainer(fa, diskSize))
+	a.Equal(diskSize+vhdFooterSize, body.Size())
+
+	footer := body.Data[diskSize:]
+	ValidateVHDFooter(fa, footer, diskSize)
+	a.Equal("conectix", string(footer[:8]))
+
+	// 20MiB lands in the 17 sectors/track, 4 head range of the spec's geometry table.
+	var f vhdFooter
+	f.setGeometry(uint64(diskSize))
+	a.Equal(uint8(17), f.SectorsPerTrack)
+	a.Equal(uint8(4), f.Heads)
+	a.Equal(uint16(602), f.Cylinders)
+
+	// Any change to the footer shows up in the checksum.
+	footer[vhdFooterSize-1] ^= 0xff
+	a.NoError(binary.Read(bytes.NewReader(footer), binary.BigEndian, &f))
+	a.NotEqual(f.Checksum, f.computeChecksum())
+}
+
+func TestARMManagedDiskAccess(t *testing.T) {
+	a := assert.New(t)
+
+	var srv *httptest.Server
+	var calls []string
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		a.Equal("2021-12-01", r.URL.Query().Get("api-version"))
+		calls = append(calls, r.Method+" "+r.URL.Path)
+
+		switch r.URL.Path {
+		case "/subscriptions/sub/resourcegroups/rg/providers/Microsoft.Compute/disks/disk/beginGetAccess":
+			var params ARMManagedDiskGrantAccessParams
+			buf, _ := io.ReadAll(r.Body)
+			a.NoError(json.Unmarshal(buf, &params))
+			a.Equal(ARMManagedDiskAccessLevelWrite, params.AccessLevel)
+
+			w.Header().Set("Azure-AsyncOperation", srv.URL+"/operations/grant?api-version=2021-12-01")
+			w.WriteHeader(http.StatusAccepted)
+		case "/operations/grant":
+			_, _ = w.Write([]byte(`{"name":"grant","status":"Succeeded","startTime":"now","properties":{"output":{"accessSAS":"https://md-impexp.z0.blob.storage.azure.net/abcd/abcd?sv=2018-03-28&sig=sig"}}}`))
+		case "/subscriptions/sub/resourcegroups/rg/providers/Microsoft.Compute/disks/disk/endGetAccess":
+			w.WriteHeader(http.StatusOK)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	target, _ := url.Parse(srv.URL)
+	md := &ManagedDiskResourceManager{
+		armClient: &ARMManagedDisk{
+			ARMResourceGroup: &ARMResourceGroup{
+				ARMSubscription: &ARMSubscription{
+					ARMClient: &ARMClient{
+						OAuth:      staticAccessToken("token"),
+						HttpClient: &http.Client{Transport: redirectTransport{target: target}},
+					},
+					SubscriptionID: "sub",
+				},
+				ResourceGroupName: "rg",
+			},
+			DiskName: "disk",
+		},
+	}
+	fa := NewFrameworkAsserter(t)
+
+	a.Panics(func() { md.URI() })
+	md.GrantAccess(fa, ARMManagedDiskAccessLevelWrite)
+	a.Equal("https://md-impexp.z0.blob.storage.azure.net/abcd/abcd?sv=2018-03-28&sig=sig", md.URI())
+
+	// The SAS is passed to AzCopy as-is.
+	cmd := &AzCopyCommand{Environment: &AzCopyEnvironment{}}
+	a.Equal(md.URI(), cmd.applyTargetAuth(fa, md))
+	a.Equal(common.ELocation.Blob(), md.Location())
+
+	md.RevokeAccess(fa)
+	a.Panics(func() { md.URI() })
+
+	a.Equal([]string{
+		"POST /subscriptions/sub/resourcegroups/rg/providers/Microsoft.Compute/disks/disk/beginGetAccess",
+		"GET /operations/grant",
+		"POST /subscriptions/sub/resourcegroups/rg/providers/Microsoft.Compute/disks/disk/endGetAccess",
+	}, calls)
+}
+
+type ManagedDiskSuite struct{}
+
+// Scenario_UploadDownloadVHD imports a fixed VHD into a fresh managed disk, then exports it back.
+func (s *ManagedDiskSuite) Scenario_UploadDownloadVHD(svm *ScenarioVariationManager) {
+	diskSize := SizeFromString("20M") // the smallest disk Azure will let us upload
+	vhd := NewFixedVHDObjectContentContainer(svm, diskSize)
+
+	disk := CreateManagedDisk(svm, CreateManagedDiskOptions{UploadSizeBytes: vhd.Size()})
+
+	srcObj := CreateResource[ObjectResourceManager](svm, GetRootResource(svm, common.ELocation.Local()), ResourceDefinitionObject{
+		ObjectName: pointerTo("disk.vhd"),
+		Body:       vhd,
+	})
+
+	disk.GrantAccess(svm, ARMManagedDiskAccessLevelWrite)
+	RunAzCopy(
+		svm,
+		AzCopyCommand{
+			Verb:    AzCopyVerbCopy,
+			Targets: []ResourceManager{srcObj, disk},
+			Flags: CopyFlags{
+				BlobType: pointerTo(common.EBlobType.PageBlob()),
+			},
+		})
+	disk.RevokeAccess(svm) // Import access must end before the disk can be read
+
+	disk.GrantAccess(svm, ARMManagedDiskAccessLevelRead)
+	ValidateVHDFooter(svm, disk.Footer(svm), diskSize)
+
+	dstContainer := CreateResource[ContainerResourceManager](svm, GetRootResource(svm, common.ELocation.Local()), ResourceDefinitionContainer{})
+	dstObj := dstContainer.GetObject(svm, "disk.vhd", common.EEntityType.File())
+	RunAzCopy(
+		svm,
+		AzCopyCommand{
+			Verb:    AzCopyVerbCopy,
+			Targets: []ResourceManager{disk, dstObj},
+		})
+
+	ValidateResource[ObjectResourceManager](svm, dstObj, ResourceDefinitionObject{
+		Body: vhd,
+	}, true)
+}
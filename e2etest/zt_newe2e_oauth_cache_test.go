@@ -0,0 +1,99 @@
+package e2etest
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+	"github.com/stretchr/testify/assert"
+)
+
+// countingCredential hands out a new hour-long token on every call, slowly enough for concurrent callers to pile up.
+type countingCredential struct {
+	calls int32
+}
+
+func (c *countingCredential) GetToken(_ context.Context, _ policy.TokenRequestOptions) (azcore.AccessToken, error) {
+	call := atomic.AddInt32(&c.calls, 1)
+	time.Sleep(10 * time.Millisecond)
+	return azcore.AccessToken{Token: "token" + strconv.Itoa(int(call)), ExpiresOn: time.Now().Add(time.Hour)}, nil
+}
+
+func TestAccessTokenRefreshesOnceForConcurrentRequests(t *testing.T) {
+	a := assert.New(t)
+
+	var authMut sync.Mutex
+	auths := map[string]int{}
+	subject := newTestARMSubject(t, func(w http.ResponseWriter, r *http.Request) {
+		authMut.Lock()
+		auths[r.Header.Get("Authorization")]++
+		authMut.Unlock()
+		_, _ = w.Write([]byte("{}"))
+	})
+
+	cred := &countingCredential{}
+	subject.OAuth = &AzCoreAccessToken{
+		tok:    &azcore.AccessToken{Token: "expiring", ExpiresOn: time.Now().Add(time.Minute)}, // within the refresh margin
+		parent: NewOAuthCache(cred, "tenant"),
+		Scope:  AzureManagementResource,
+	}
+
+	const requests = 50
+	var wg sync.WaitGroup
+	errs := make(chan error, requests)
+	for i := 0; i < requests; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var out struct{}
+			_, err := PerformRequest(context.Background(), subject, ARMRequestSettings{Method: http.MethodGet}, &out)
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		a.NoError(err)
+	}
+	a.EqualValues(1, atomic.LoadInt32(&cred.calls))
+	a.Equal(map[string]int{"Bearer token1": requests}, auths)
+
+	// The refreshed token is cached, rather than fetched again.
+	tok, err := subject.OAuth.FreshToken()
+	a.NoError(err)
+	a.Equal("token1", tok)
+	a.Equal("token1", subject.OAuth.CurrentToken())
+	a.EqualValues(1, atomic.LoadInt32(&cred.calls))
+}
+
+func TestOAuthCacheRefreshesOnceForConcurrentScopes(t *testing.T) {
+	a := assert.New(t)
+
+	cred := &countingCredential{}
+	cache := NewOAuthCache(cred, "tenant")
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := cache.GetAccessToken(AzureStorageResource)
+			a.NoError(err)
+		}()
+	}
+	wg.Wait()
+	a.EqualValues(1, atomic.LoadInt32(&cred.calls))
+
+	// Tokens about to expire are replaced.
+	cache.tokens[AzureStorageResource].ExpiresOn = time.Now().Add(tokenRefreshMargin - time.Second)
+	tok, err := cache.GetAccessToken(AzureStorageResource)
+	a.NoError(err)
+	a.Equal("token2", tok.CurrentToken())
+}
@@ -0,0 +1,153 @@
+package e2etest
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// containerNamePattern is the intersection of the naming rules for blob containers, file shares, filesystems and buckets.
+var containerNamePattern = regexp.MustCompile(`^[a-z0-9](?:[a-z0-9]|-[a-z0-9])*$`)
+
+func TestNewContainerName(t *testing.T) {
+	a := assert.New(t)
+	fa := NewFrameworkAsserter(t)
+
+	first, second := NewContainerName(fa), NewContainerName(fa)
+	a.NotEqual(first, second)
+	a.True(strings.HasPrefix(first, "testnewcontainername-"), first)
+	a.Contains(first, "-"+runNonce+"-")
+
+	for _, name := range []string{first, NewContainerName(nil)} {
+		a.Regexp(containerNamePattern, name)
+		a.LessOrEqual(len(name), 63)
+		a.GreaterOrEqual(len(name), 3)
+	}
+
+	// Scenarios whose names share a prefix still differ, by the hash of their full name.
+	hashOf := func(testName string) string {
+		segments := strings.Split(NewContainerName(namedAsserter{fa, testName}), "-")
+		return segments[len(segments)-3]
+	}
+	a.NotEqual(hashOf("TestNewE2E/VeryLongSuiteNameIndeed/Scenario_A"), hashOf("TestNewE2E/VeryLongSuiteNameIndeed/Scenario_B"))
+
+	for testName, expected := range map[string]string{
+		"TestNewE2E/BasicFunctionalitySuite/Scenario_SingleFile/Blob": "basicfunctionalitysuite",
+		"TestNewE2E/Blob->Blob_Copy":                                  "blob-blob-copy",
+		"TestX/--__--":                                                namespaceFallback,
+		"TestNewE2E/ACLSuite/Scenario_DefaultACLInheritance":          "aclsuite-scenario-defaul",
+	} {
+		a.Equal(expected, namespacePrefix(testName), testName)
+	}
+}
+
+type namedAsserter struct {
+	Asserter
+	name string
+}
+
+func (n namedAsserter) TestName() string { return n.name }
+
+// recordingTB stands in for a test, recording what's reported against it rather than failing.
+type recordingTB struct {
+	testing.TB // unused methods panic
+	errors     *[]string
+}
+
+func (r recordingTB) Helper()             {}
+func (r recordingTB) Logf(string, ...any) {}
+func (r recordingTB) Errorf(format string, args ...any) {
+	*r.errors = append(*r.errors, fmt.Sprintf(format, args...))
+}
+
+func TestDeleteTracked(t *testing.T) {
+	a := assert.New(t)
+
+	var reported []string
+	tb := recordingTB{errors: &reported}
+
+	a.True(deleteTracked(tb, "ok", func(a Asserter) { a.NoError("delete", nil) }))
+	a.Empty(reported)
+
+	a.False(deleteTracked(tb, "fails", func(a Asserter) {
+		a.NoError("delete", errors.New("409 lease present"))
+		panic("unreachable: NoError should have abandoned the deletion")
+	}))
+	a.Len(reported, 1)
+	a.Contains(reported[0], "409 lease present")
+
+	a.False(deleteTracked(tb, "panics", func(a Asserter) {
+		var nilMap map[string]int
+		nilMap["x"] = 1
+	}))
+	a.Len(reported, 2)
+	a.Contains(reported[1], "Deleting panics panicked")
+}
+
+// fakeTrackedResource records its own deletion.
+type fakeTrackedResource struct {
+	ResourceManager // unused methods panic
+	canon           string
+
+	mu      *sync.Mutex
+	deleted *[]string
+}
+
+func (f fakeTrackedResource) Canon() string { return f.canon }
+
+func (f fakeTrackedResource) Delete(a Asserter) {
+	a.NoError("delete "+f.canon, nil)
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	*f.deleted = append(*f.deleted, f.canon)
+}
+
+func TestDeleteCreatedResources(t *testing.T) {
+	a := assert.New(t)
+
+	run := func(t *testing.T, failed bool) []string {
+		var deleted []string
+		var mu sync.Mutex
+
+		svm := &ScenarioVariationManager{t: t}
+		var wg sync.WaitGroup
+		for _, canon := range []string{"acct/blob/c1", "acct/blob/c1/obj", "acct/blob/c2", "acct/file/s1"} {
+			wg.Add(1)
+			go func(canon string) { // scenarios may create resources concurrently
+				defer wg.Done()
+				svm.TrackCreatedResource(fakeTrackedResource{canon: canon, mu: &mu, deleted: &deleted})
+			}(canon)
+		}
+		wg.Wait()
+
+		// A failed variation must still tear everything down, rather than stopping at the first assertion.
+		svm.isInvalid = failed
+		svm.DeleteCreatedResources()
+		a.Nil(svm.CreatedResources)
+
+		sort.Strings(deleted)
+		return deleted
+	}
+
+	t.Run("Passed", func(t *testing.T) {
+		a.Equal([]string{"acct/blob/c1", "acct/blob/c2", "acct/file/s1"}, run(t, false)) // c1/obj went with c1
+	})
+
+	t.Run("Failed", func(t *testing.T) {
+		a.Equal([]string{"acct/blob/c1", "acct/blob/c2", "acct/file/s1"}, run(t, true))
+	})
+
+	t.Run("SkipCleanup", func(t *testing.T) {
+		defer func(skip bool) { GlobalConfig.DebugConfig.SkipCleanup = skip }(GlobalConfig.DebugConfig.SkipCleanup)
+		GlobalConfig.DebugConfig.SkipCleanup = true
+
+		a.Empty(run(t, false))
+	})
+}
@@ -0,0 +1,77 @@
+package e2etest
+
+import (
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-storage-azcopy/v10/common"
+	"github.com/stretchr/testify/assert"
+)
+
+func init() {
+	suiteManager.RegisterSuite(&S3Suite{})
+}
+
+func TestS3ApplyPresign(t *testing.T) {
+	a := assert.New(t)
+	acct, err := NewS3AccountResourceManager("accesskey", "secretkey", "")
+	a.NoError(err)
+	svc := &S3ServiceResourceManager{internalAccount: acct}
+	obj := svc.GetContainer("bucket").GetObject(nil, "dir/object", common.EEntityType.File())
+
+	// AzCopy gets credentials from the environment, so URIs are plain unless presigning is asked for.
+	a.Equal("https://s3.amazonaws.com/bucket/dir/object", obj.URI())
+	a.Equal("http://s3.amazonaws.com/bucket", obj.Parent().URI(GetURIOptions{RemoteOpts: RemoteURIOpts{Scheme: "http"}}))
+	a.Equal("s3/S3/bucket/dir/object", obj.Canon())
+
+	presigned, err := url.Parse(obj.URI(GetURIOptions{S3Opts: S3URIOpts{WithPresign: true, PresignExpiry: time.Minute * 5}}))
+	a.NoError(err)
+	a.Contains(presigned.Path, "dir/object")
+	a.Equal("300", presigned.Query().Get("X-Amz-Expires"))
+	a.Contains(presigned.Query().Get("X-Amz-Credential"), "accesskey/")
+	a.Contains(presigned.Query().Get("X-Amz-Credential"), "/"+s3DefaultRegion+"/")
+	a.NotEmpty(presigned.Query().Get("X-Amz-Signature"))
+
+	a.Panics(func() {
+		svc.URI(GetURIOptions{S3Opts: S3URIOpts{WithPresign: true}})
+	})
+}
+
+type S3Suite struct{}
+
+func (s *S3Suite) Scenario_CopyBucketToContainer(svm *ScenarioVariationManager) {
+	objects := ObjectResourceMappingFlat{
+		"foo": ResourceDefinitionObject{
+			Body: NewRandomObjectContentContainer(svm, SizeFromString("1K")),
+		},
+		"bar/baz": ResourceDefinitionObject{
+			Body: NewRandomObjectContentContainer(svm, SizeFromString("10K")),
+			ObjectProperties: ObjectProperties{
+				Metadata: common.Metadata{"origin": pointerTo("s3")},
+			},
+		},
+	}
+
+	srcBucket := CreateResource[ContainerResourceManager](svm, GetRootResource(svm, common.ELocation.S3()), ResourceDefinitionContainer{
+		Objects: objects,
+	})
+	dstContainer := CreateResource[ContainerResourceManager](svm, GetRootResource(svm, common.ELocation.Blob()), ResourceDefinitionContainer{})
+
+	RunAzCopy(
+		svm,
+		AzCopyCommand{
+			Verb:    AzCopyVerbCopy,
+			Targets: []ResourceManager{srcBucket, dstContainer},
+			Flags: CopyFlags{
+				CopySyncCommonFlags: CopySyncCommonFlags{
+					Recursive: pointerTo(true),
+				},
+				AsSubdir: pointerTo(false),
+			},
+		})
+
+	ValidateResource[ContainerResourceManager](svm, dstContainer, ResourceDefinitionContainer{
+		Objects: objects,
+	}, true)
+}
@@ -0,0 +1,605 @@
+package e2etest
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/blob"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/bloberror"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/container"
+	blobsas "github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/sas"
+	blobservice "github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/service"
+	datalakesas "github.com/Azure/azure-sdk-for-go/sdk/storage/azdatalake/sas"
+	filesas "github.com/Azure/azure-sdk-for-go/sdk/storage/azfile/sas"
+	"github.com/Azure/azure-storage-azcopy/v10/common"
+	"github.com/stretchr/testify/assert"
+)
+
+func init() {
+	suiteManager.RegisterSuite(&SASRestrictionsSuite{})
+}
+
+func TestParseSASIPRange(t *testing.T) {
+	a := assert.New(t)
+
+	r, err := ParseSASIPRange("192.168.0.0-192.168.0.255")
+	a.NoError(err)
+	a.Equal("192.168.0.0-192.168.0.255", r.String())
+
+	r, err = ParseSASIPRange("192.168.0.0/24")
+	a.NoError(err)
+	a.Equal("192.168.0.0-192.168.0.255", r.String())
+
+	r, err = ParseSASIPRange("10.0.0.1")
+	a.NoError(err)
+	a.Equal("10.0.0.1", r.String())
+
+	for _, malformed := range []string{"", "192.168.0", "192.168.0.0-", "192.168.0.0/33", "2001:db8::/32", "192.168.0.255-192.168.0.0", "::1"} {
+		_, err = ParseSASIPRange(malformed)
+		a.Error(err, malformed)
+	}
+
+	a.NoError(ValidateSASIPRange(blobsas.IPRange{}))
+	a.Error(ValidateSASIPRange(blobsas.IPRange{End: net.ParseIP("10.0.0.1")}))
+}
+
+func TestApplySASRestrictions(t *testing.T) {
+	a := assert.New(t)
+	acct := &AzureAccountResourceManager{accountName: "acct", accountKey: "YWNjb3VudGtleQ=="}
+	ipRange, err := ParseSASIPRange("192.168.0.0-192.168.0.255")
+	a.NoError(err)
+
+	for _, vals := range []GenericSignatureValues{
+		GenericServiceSignatureValues{ContainerName: "container", IPRange: ipRange},
+		GenericAccountSignatureValues{IPRange: ipRange},
+	} {
+		uri := acct.ApplySAS("https://acct.blob.core.windows.net/container", common.ELocation.Blob(),
+			GetURIOptions{AzureOpts: AzureURIOpts{WithSAS: true, SASValues: vals}})
+
+		parts, err := blobsas.ParseURL(uri)
+		a.NoError(err)
+		sipr := parts.SAS.IPRange()
+		a.Equal("192.168.0.0-192.168.0.255", sipr.String())
+		// The protocol defaults to HTTPS only, matching the default scheme.
+		a.Equal(blobsas.ProtocolHTTPS, parts.SAS.Protocol())
+		a.Equal("https", parts.Scheme)
+	}
+
+	a.Panics(func() {
+		acct.ApplySAS("https://acct.blob.core.windows.net/container", common.ELocation.Blob(),
+			GetURIOptions{AzureOpts: AzureURIOpts{WithSAS: true, SASValues: GenericAccountSignatureValues{
+				IPRange: blobsas.IPRange{Start: net.ParseIP("192.168.0.255"), End: net.ParseIP("192.168.0.0")},
+			}}})
+	})
+}
+
+func TestApplySASWithSignedIdentifier(t *testing.T) {
+	a := assert.New(t)
+	acct := &AzureAccountResourceManager{accountName: "acct", accountKey: "YWNjb3VudGtleQ=="}
+	opts := GetURIOptions{AzureOpts: AzureURIOpts{WithSAS: true, SASValues: GenericServiceSignatureValues{
+		ContainerName:    "container",
+		SignedIdentifier: "policy",
+	}}}
+
+	for loc, uri := range map[common.Location]string{
+		common.ELocation.Blob():   "https://acct.blob.core.windows.net/container",
+		common.ELocation.File():   "https://acct.file.core.windows.net/container",
+		common.ELocation.BlobFS(): "https://acct.dfs.core.windows.net/container",
+	} {
+		_, query, _ := strings.Cut(acct.ApplySAS(uri, loc, opts), "?")
+		params, err := url.ParseQuery(query)
+		a.NoError(err)
+
+		// Start, expiry and permissions are left to the policy.
+		a.Equal("policy", params.Get("si"), loc.String())
+		a.Equal("https", params.Get("spr"), loc.String())
+		a.NotEmpty(params.Get("sig"), loc.String())
+		for _, inline := range []string{"sp", "st", "se"} {
+			a.False(params.Has(inline), "%s SAS has %s", loc, inline)
+		}
+	}
+}
+
+func TestApplySASEnsuresStoredAccessPolicy(t *testing.T) {
+	a := assert.New(t)
+
+	defer func(interval time.Duration) { storedAccessPolicyPollInterval = interval }(storedAccessPolicyPollInterval)
+	storedAccessPolicyPollInterval = time.Millisecond
+
+	acl := `<?xml version="1.0" encoding="utf-8"?><SignedIdentifiers><SignedIdentifier><Id>other</Id><AccessPolicy><Expiry>2030-01-01T00:00:00.0000000Z</Expiry><Permission>r</Permission></AccessPolicy></SignedIdentifier></SignedIdentifiers>`
+	var sets, probes int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		a.Equal("/acct/container", r.URL.Path)
+		switch {
+		case r.URL.Query().Get("comp") == "acl" && r.Method == http.MethodGet:
+			a.NotEmpty(r.Header.Get("Authorization"))
+			_, _ = w.Write([]byte(acl))
+		case r.URL.Query().Get("comp") == "acl" && r.Method == http.MethodPut:
+			sets++
+			buf, _ := io.ReadAll(r.Body)
+			acl = string(buf)
+		case r.URL.Query().Get("comp") == "list":
+			// The SAS references the policy, and is scoped to the container, whatever it was signed for.
+			probes++
+			a.Equal("policy", r.URL.Query().Get("si"))
+			a.Equal("c", r.URL.Query().Get("sr"))
+			if probes < 3 { // still propagating
+				w.Header().Set("x-ms-error-code", string(bloberror.AuthenticationFailed))
+				w.WriteHeader(http.StatusForbidden)
+				return
+			}
+			_, _ = w.Write([]byte(`<?xml version="1.0" encoding="utf-8"?><EnumerationResults ContainerName="container"><Blobs /><NextMarker /></EnumerationResults>`))
+		default:
+			w.WriteHeader(http.StatusBadRequest)
+		}
+	}))
+	defer srv.Close()
+
+	acct := &AzureAccountResourceManager{accountName: "acct", accountKey: "YWNjb3VudGtleQ=="}
+	opts := GetURIOptions{AzureOpts: AzureURIOpts{WithSAS: true, SASValues: GenericServiceSignatureValues{
+		Identifier:  "policy",
+		Permissions: (&blobsas.ContainerPermissions{Read: true, List: true}).String(),
+		ObjectName:  "dir/blob",
+	}}}
+	blobURI := srv.URL + "/acct/container/dir/blob"
+
+	_, query, _ := strings.Cut(acct.ApplySAS(blobURI, common.ELocation.Blob(), opts), "?")
+	params, err := url.ParseQuery(query)
+	a.NoError(err)
+	a.Equal("policy", params.Get("si"))
+	a.Equal("b", params.Get("sr"))
+	for _, inline := range []string{"sp", "st", "se"} {
+		a.False(params.Has(inline), "SAS has %s", inline)
+	}
+
+	// The policy went in alongside the one already there, and signing waited for it to take effect.
+	a.Equal(1, sets)
+	a.Equal(3, probes)
+	a.Contains(acl, "<Id>other</Id>")
+	a.Contains(acl, "<Id>policy</Id>")
+	a.Contains(acl, "<Permission>rl</Permission>")
+
+	// It's left alone once in place.
+	acct.ApplySAS(blobURI, common.ELocation.Blob(), opts)
+	a.Equal(1, sets)
+
+	a.NoError(acct.removeStoredAccessPolicy(blobURI, common.ELocation.Blob(), "policy"))
+	a.Equal(2, sets)
+	a.Contains(acl, "<Id>other</Id>")
+	a.NotContains(acl, "<Id>policy</Id>")
+
+	a.Error(GenericServiceSignatureValues{Identifier: "policy", SignedIdentifier: "policy"}.Validate())
+}
+
+func TestApplySASScopesToSnapshot(t *testing.T) {
+	a := assert.New(t)
+	acct := &AzureAccountResourceManager{accountName: "acct", accountKey: "YWNjb3VudGtleQ=="}
+	start := time.Now().UTC().Truncate(time.Second)
+	vals := GenericServiceSignatureValues{
+		StartTime:   start,
+		ExpiryTime:  start.Add(time.Hour),
+		Permissions: (&blobsas.BlobPermissions{Read: true}).String(),
+	}
+	opts := GetURIOptions{AzureOpts: AzureURIOpts{WithSAS: true, SASValues: vals}}
+	skc, err := blobservice.NewSharedKeyCredential(acct.accountName, acct.accountKey)
+	a.NoError(err)
+
+	// Snapshots are signed as such, and the snapshot stays on the URL.
+	parts, err := blobsas.ParseURL(acct.ApplySAS("https://acct.blob.core.windows.net/container/dir/blob?snapshot=2024-01-01T00:00:00.1234567Z", common.ELocation.Blob(), opts))
+	a.NoError(err)
+	a.Equal("2024-01-01T00:00:00.1234567Z", parts.Snapshot)
+	a.Equal("bs", parts.SAS.Resource())
+
+	snapshotTime, err := time.Parse(blob.SnapshotTimeFormat, parts.Snapshot)
+	a.NoError(err)
+	expected := vals
+	expected.ContainerName, expected.ObjectName, expected.SnapshotTime = "container", "dir/blob", snapshotTime
+	expectedSAS, err := expected.AsBlob().SignWithSharedKey(skc)
+	a.NoError(err)
+	a.Equal(expectedSAS.Signature(), parts.SAS.Signature())
+
+	// Likewise for versions.
+	parts, err = blobsas.ParseURL(acct.ApplySAS("https://acct.blob.core.windows.net/container/dir/blob?versionid=2024-01-01T00:00:00.1234567Z", common.ELocation.Blob(), opts))
+	a.NoError(err)
+	a.Equal("2024-01-01T00:00:00.1234567Z", parts.VersionID)
+	a.Equal("bv", parts.SAS.Resource())
+
+	// Without either, the SAS is left as specified.
+	parts, err = blobsas.ParseURL(acct.ApplySAS("https://acct.blob.core.windows.net/container/dir/blob", common.ELocation.Blob(), opts))
+	a.NoError(err)
+	a.Equal("c", parts.SAS.Resource())
+
+	a.Panics(func() {
+		acct.ApplySAS("https://acct.blob.core.windows.net/container/blob?snapshot=yesterday", common.ELocation.Blob(), opts)
+	})
+}
+
+func TestApplySASWindow(t *testing.T) {
+	a := assert.New(t)
+	acct := &AzureAccountResourceManager{accountName: "acct", accountKey: "YWNjb3VudGtleQ=="}
+	sasWindow := func(vals GenericSignatureValues) (start, expiry time.Time) {
+		parts, err := blobsas.ParseURL(acct.ApplySAS("https://acct.blob.core.windows.net/container", common.ELocation.Blob(),
+			GetURIOptions{AzureOpts: AzureURIOpts{WithSAS: true, SASValues: vals}}))
+		a.NoError(err)
+		return parts.SAS.StartTime(), parts.SAS.ExpiryTime()
+	}
+
+	// By default, the SAS is already valid despite clock skew, and lasts long enough to outlive slow fixtures.
+	now := time.Now()
+	for _, vals := range []GenericSignatureValues{nil, GenericServiceSignatureValues{ContainerName: "container"}, GenericAccountSignatureValues{}} {
+		start, expiry := sasWindow(vals)
+		a.WithinDuration(now.Add(-sasClockSkew), start, time.Minute)
+		a.WithinDuration(now.Add(sasDefaultLifetime), expiry, time.Minute)
+	}
+
+	// Explicit times are honored; an explicit start later than now pushes the default expiry out with it.
+	start, expiry := now.Add(time.Hour).UTC().Truncate(time.Second), now.Add(time.Hour*2).UTC().Truncate(time.Second)
+	gotStart, gotExpiry := sasWindow(GenericServiceSignatureValues{ContainerName: "container", StartTime: start, ExpiryTime: expiry})
+	a.Equal(start, gotStart)
+	a.Equal(expiry, gotExpiry)
+
+	gotStart, gotExpiry = sasWindow(GenericAccountSignatureValues{StartTime: start})
+	a.Equal(start, gotStart)
+	a.Equal(start.Add(sasDefaultLifetime), gotExpiry)
+
+	_, gotExpiry = sasWindow(GenericAccountSignatureValues{ExpiryTime: expiry})
+	a.Equal(expiry, gotExpiry)
+}
+
+func TestValidateSASWindow(t *testing.T) {
+	a := assert.New(t)
+	now := time.Now()
+
+	for name, tc := range map[string]struct {
+		start, expiry time.Time
+		valid         bool
+	}{
+		"defaulted":             {valid: true},
+		"start only":            {start: now.Add(-time.Hour), valid: true},
+		"expiry only":           {expiry: now.Add(time.Hour), valid: true},
+		"explicit":              {start: now, expiry: now.Add(time.Hour), valid: true},
+		"expired":               {expiry: now.Add(-time.Minute)},
+		"expired after a start": {start: now.Add(-time.Hour), expiry: now.Add(-time.Minute)},
+		"expiry before start":   {start: now.Add(time.Hour * 2), expiry: now.Add(time.Hour)},
+		"expiry at start":       {start: now.Add(time.Hour), expiry: now.Add(time.Hour)},
+	} {
+		a.Equal(tc.valid, ValidateSASWindow(tc.start, tc.expiry) == nil, name)
+		a.Equal(tc.valid, GenericServiceSignatureValues{StartTime: tc.start, ExpiryTime: tc.expiry}.Validate() == nil, name)
+		a.Equal(tc.valid, GenericAccountSignatureValues{StartTime: tc.start, ExpiryTime: tc.expiry}.Validate() == nil, name)
+	}
+
+	// ApplySAS refuses to sign an expired SAS, rather than leave the service to refuse it.
+	acct := &AzureAccountResourceManager{accountName: "acct", accountKey: "YWNjb3VudGtleQ=="}
+	a.Panics(func() {
+		acct.ApplySAS("https://acct.blob.core.windows.net/container", common.ELocation.Blob(),
+			GetURIOptions{AzureOpts: AzureURIOpts{WithSAS: true, SASValues: GenericServiceSignatureValues{ExpiryTime: now.Add(-time.Minute)}}})
+	})
+	a.Panics(func() {
+		acct.ApplyAccountSAS(nil, common.ELocation.Blob(), AccountSASOptions{
+			Services:      AccountSASServices{Blob: true},
+			ResourceTypes: blobsas.AccountResourceTypes{Object: true},
+			ExpiryTime:    now.Add(-time.Minute),
+		})
+	})
+}
+
+func TestValidateSASPermissions(t *testing.T) {
+	a := assert.New(t)
+	file, folder := common.EEntityType.File(), common.EEntityType.Folder()
+
+	for name, tc := range map[string]struct {
+		loc     common.Location
+		et      common.EntityType
+		vals    GenericServiceSignatureValues
+		illegal string // empty if valid
+	}{
+		"blob container":             {loc: common.ELocation.Blob(), et: folder, vals: GenericServiceSignatureValues{Permissions: (&blobsas.ContainerPermissions{Read: true, List: true, FilterByTags: true}).String()}},
+		"blob":                       {loc: common.ELocation.Blob(), et: file, vals: GenericServiceSignatureValues{Permissions: (&blobsas.BlobPermissions{Read: true, PermanentDelete: true}).String()}},
+		"blob permanent delete":      {loc: common.ELocation.Blob(), et: folder, vals: GenericServiceSignatureValues{Permissions: "ry"}, illegal: "y"},
+		"blob filter by tags":        {loc: common.ELocation.Blob(), et: file, vals: GenericServiceSignatureValues{Permissions: "rf"}, illegal: "f"},
+		"blob directory":             {loc: common.ELocation.Blob(), et: folder, vals: GenericServiceSignatureValues{DirectoryPath: "dir", Permissions: "ry"}},
+		"blob directory filter":      {loc: common.ELocation.Blob(), et: folder, vals: GenericServiceSignatureValues{DirectoryPath: "dir", Permissions: "rf"}, illegal: "f"},
+		"share":                      {loc: common.ELocation.File(), et: folder, vals: GenericServiceSignatureValues{Permissions: (&filesas.SharePermissions{Read: true, List: true}).String()}},
+		"file":                       {loc: common.ELocation.File(), et: file, vals: GenericServiceSignatureValues{Permissions: (&filesas.FilePermissions{Read: true, Write: true}).String()}},
+		"file list":                  {loc: common.ELocation.File(), et: file, vals: GenericServiceSignatureValues{Permissions: "rl"}, illegal: "l"},
+		"share with blob-only":       {loc: common.ELocation.File(), et: folder, vals: GenericServiceSignatureValues{Permissions: "racwdl"}, illegal: "a"},
+		"share with several blob":    {loc: common.ELocation.File(), et: folder, vals: GenericServiceSignatureValues{Permissions: "rtxtm"}, illegal: "txm"},
+		"filesystem":                 {loc: common.ELocation.BlobFS(), et: folder, vals: GenericServiceSignatureValues{Permissions: (&datalakesas.FileSystemPermissions{Read: true, List: true, ModifyOwnership: true}).String()}},
+		"datalake file":              {loc: common.ELocation.BlobFS(), et: file, vals: GenericServiceSignatureValues{Permissions: (&datalakesas.FilePermissions{Read: true, Move: true, Execute: true}).String()}},
+		"datalake directory":         {loc: common.ELocation.BlobFS(), et: folder, vals: GenericServiceSignatureValues{DirectoryPath: "dir", Permissions: (&datalakesas.DirectoryPermissions{Read: true, Permissions: true}).String()}},
+		"datalake tags":              {loc: common.ELocation.BlobFS(), et: file, vals: GenericServiceSignatureValues{Permissions: "rt"}, illegal: "t"},
+		"defaulted":                  {loc: common.ELocation.File(), et: file},
+		"unknown permission on blob": {loc: common.ELocation.Blob(), et: folder, vals: GenericServiceSignatureValues{Permissions: "rz"}, illegal: "z"},
+	} {
+		err := tc.vals.ValidatePermissions(tc.loc, tc.et)
+		if tc.illegal == "" {
+			a.NoError(err, name)
+			continue
+		}
+
+		if a.Error(err, name) {
+			a.Contains(err.Error(), fmt.Sprintf("%q", tc.illegal), name)
+		}
+	}
+
+	a.Error(GenericServiceSignatureValues{Permissions: "r"}.ValidatePermissions(common.ELocation.Local(), file))
+
+	// ApplySAS checks permissions against what's being signed for, before signing.
+	acct := &AzureAccountResourceManager{accountName: "acct", accountKey: "YWNjb3VudGtleQ=="}
+	applySAS := func(uri string, loc common.Location, vals GenericServiceSignatureValues) func() {
+		return func() {
+			acct.ApplySAS(uri, loc, GetURIOptions{AzureOpts: AzureURIOpts{WithSAS: true, SASValues: vals}})
+		}
+	}
+	a.NotPanics(applySAS("https://acct.file.core.windows.net/share", common.ELocation.File(), GenericServiceSignatureValues{ContainerName: "share", Permissions: "rl"}))
+	a.PanicsWithError(`permissions "rl" include "l", which a File SAS for a file cannot grant (allowed: "rcwd")`,
+		applySAS("https://acct.file.core.windows.net/share/file", common.ELocation.File(), GenericServiceSignatureValues{ContainerName: "share", ObjectName: "file", Permissions: "rl"}))
+	a.Panics(applySAS("https://acct.blob.core.windows.net/container", common.ELocation.Blob(), GenericServiceSignatureValues{ContainerName: "container", Permissions: "ry"}))
+	// A snapshot URL scopes the SAS to the blob, whose permissions differ from the container's.
+	a.NotPanics(applySAS("https://acct.blob.core.windows.net/container/blob?snapshot=2024-01-01T00:00:00.0000000Z", common.ELocation.Blob(), GenericServiceSignatureValues{Permissions: "ry"}))
+	a.Panics(applySAS("https://acct.dfs.core.windows.net/filesystem/file", common.ELocation.BlobFS(), GenericServiceSignatureValues{ContainerName: "filesystem", ObjectName: "file", Permissions: "rt"}))
+}
+
+type SASRestrictionsSuite struct{}
+
+func (s *SASRestrictionsSuite) Scenario_StoredAccessPolicy(svm *ScenarioVariationManager) {
+	body := NewRandomObjectContentContainer(svm, SizeFromString("1K"))
+	srcObj := CreateResource[ObjectResourceManager](svm, GetRootResource(svm, common.ELocation.Blob()), ResourceDefinitionObject{
+		ObjectName: pointerTo("test"),
+		Body:       body,
+	})
+	dstObj := CreateResource[ContainerResourceManager](svm, GetRootResource(svm, common.ELocation.Local()), ResourceDefinitionContainer{}).GetObject(svm, "test", common.EEntityType.File())
+
+	if blobService, ok := srcObj.Parent().Parent().(*BlobServiceResourceManager); ok {
+		blobService.CreateStoredAccessPolicy(svm, srcObj.ContainerName(), "read-only", container.AccessPolicy{
+			Expiry:     pointerTo(time.Now().Add(time.Hour)),
+			Permission: pointerTo((&container.AccessPolicyPermission{Read: true, List: true}).String()),
+		})
+	}
+	if !svm.Dryrun() {
+		// Stored access policies take up to 30 seconds to take effect.
+		time.Sleep(time.Second * 30)
+	}
+
+	RunAzCopy(
+		svm,
+		AzCopyCommand{
+			Verb: AzCopyVerbCopy,
+			Targets: []ResourceManager{
+				TryApplySpecificAuthType(srcObj, EExplicitCredentialType.SASToken(), svm, CreateAzCopyTargetOptions{
+					SASTokenOptions: GenericServiceSignatureValues{
+						ContainerName:    srcObj.ContainerName(),
+						SignedIdentifier: "read-only",
+					},
+				}),
+				dstObj,
+			},
+		})
+
+	ValidateResource[ObjectResourceManager](svm, dstObj, ResourceDefinitionObject{
+		Body: body,
+	}, true)
+}
+
+// Scenario_EnsuredStoredAccessPolicy has the SAS create its own read-only policy, rather than setting one up beforehand.
+func (s *SASRestrictionsSuite) Scenario_EnsuredStoredAccessPolicy(svm *ScenarioVariationManager) {
+	body := NewRandomObjectContentContainer(svm, SizeFromString("1K"))
+	srcObj := CreateResource[ObjectResourceManager](svm, GetRootResource(svm, ResolveVariation(svm, []common.Location{common.ELocation.Blob(), common.ELocation.File()})), ResourceDefinitionObject{
+		ObjectName: pointerTo("test"),
+		Body:       body,
+	})
+	dstObj := CreateResource[ContainerResourceManager](svm, GetRootResource(svm, common.ELocation.Local()), ResourceDefinitionContainer{}).GetObject(svm, "test", common.EEntityType.File())
+
+	RunAzCopy(
+		svm,
+		AzCopyCommand{
+			Verb: AzCopyVerbCopy,
+			Targets: []ResourceManager{
+				TryApplySpecificAuthType(srcObj, EExplicitCredentialType.SASToken(), svm, CreateAzCopyTargetOptions{
+					SASTokenOptions: GenericServiceSignatureValues{
+						ContainerName: srcObj.ContainerName(),
+						Identifier:    "read-only",
+						Permissions:   (&blobsas.ContainerPermissions{Read: true, List: true}).String(),
+					},
+				}),
+				dstObj,
+			},
+		})
+
+	ValidateResource[ObjectResourceManager](svm, dstObj, ResourceDefinitionObject{
+		Body: body,
+	}, true)
+}
+
+func (s *SASRestrictionsSuite) Scenario_IPRangeRejectsOutOfRangeAccess(svm *ScenarioVariationManager) {
+	srcObj := CreateResource[ObjectResourceManager](svm, GetRootResource(svm, common.ELocation.Blob()), ResourceDefinitionObject{
+		ObjectName: pointerTo("test"),
+		Body:       NewRandomObjectContentContainer(svm, SizeFromString("1K")),
+	})
+	dstObj := CreateResource[ContainerResourceManager](svm, GetRootResource(svm, common.ELocation.Local()), ResourceDefinitionContainer{}).GetObject(svm, "test", common.EEntityType.File())
+
+	// The test runner is never inside this private range, so the service must refuse the SAS.
+	ipRange, err := ParseSASIPRange("192.168.0.0-192.168.0.255")
+	svm.NoError("parse IP range", err)
+
+	RunAzCopy(
+		svm,
+		AzCopyCommand{
+			Verb: AzCopyVerbCopy,
+			Targets: []ResourceManager{
+				TryApplySpecificAuthType(srcObj, EExplicitCredentialType.SASToken(), svm, CreateAzCopyTargetOptions{
+					SASTokenOptions: GenericAccountSignatureValues{IPRange: ipRange},
+				}),
+				dstObj,
+			},
+			ShouldFail: true,
+		})
+}
+
+// Scenario_IPRangeServiceSASReportsSourceIPMismatch checks that AzCopy gives up on a service SAS the service refuses for the caller's IP,
+// explaining why, rather than reporting it like any other authentication failure, or retrying.
+func (s *SASRestrictionsSuite) Scenario_IPRangeServiceSASReportsSourceIPMismatch(svm *ScenarioVariationManager) {
+	srcObj := CreateResource[ObjectResourceManager](svm, GetRootResource(svm, common.ELocation.Blob()), ResourceDefinitionObject{
+		ObjectName: pointerTo("test"),
+		Body:       NewRandomObjectContentContainer(svm, SizeFromString("1K")),
+	})
+	dstObj := CreateResource[ContainerResourceManager](svm, GetRootResource(svm, common.ELocation.Local()), ResourceDefinitionContainer{}).GetObject(svm, "test", common.EEntityType.File())
+
+	// TEST-NET-1 is reserved for documentation, so the test runner is never inside it.
+	ipRange, err := ParseSASIPRange("192.0.2.0/24")
+	svm.NoError("parse IP range", err)
+
+	stdout, _ := RunAzCopy(
+		svm,
+		AzCopyCommand{
+			Verb: AzCopyVerbCopy,
+			Targets: []ResourceManager{
+				TryApplySpecificAuthType(srcObj, EExplicitCredentialType.SASToken(), svm, CreateAzCopyTargetOptions{
+					SASTokenOptions: GenericServiceSignatureValues{
+						ContainerName: srcObj.ContainerName(),
+						ObjectName:    srcObj.ObjectName(),
+						Permissions:   (&blobsas.BlobPermissions{Read: true}).String(),
+						IPRange:       ipRange,
+					},
+				}),
+				dstObj,
+			},
+			ShouldFail: true,
+		})
+
+	ValidateOutputContains(svm, stdout, common.SAS_IP_MISMATCH_SERVICE_CODE)
+	ValidateOutputContains(svm, stdout, "IP range it was signed with (sip)")
+}
+
+// TestApplySASRestrictionsAcrossLocations checks that the IP range and protocol reach the SAS of every Azure location.
+func TestApplySASRestrictionsAcrossLocations(t *testing.T) {
+	a := assert.New(t)
+	acct := &AzureAccountResourceManager{accountName: "acct", accountKey: "YWNjb3VudGtleQ=="}
+
+	ipRange, err := ParseSASIPRange("192.0.2.0/24")
+	a.NoError(err)
+
+	for loc, uri := range map[common.Location]string{
+		common.ELocation.Blob():   "https://acct.blob.core.windows.net/container",
+		common.ELocation.File():   "https://acct.file.core.windows.net/container",
+		common.ELocation.BlobFS(): "https://acct.dfs.core.windows.net/container",
+	} {
+		restrictions := func(vals GenericSignatureValues) (sip, spr string) {
+			_, query, _ := strings.Cut(acct.ApplySAS(uri, loc, GetURIOptions{AzureOpts: AzureURIOpts{WithSAS: true, SASValues: vals}}), "?")
+			params, err := url.ParseQuery(query)
+			a.NoError(err)
+			return params.Get("sip"), params.Get("spr")
+		}
+
+		sip, spr := restrictions(GenericServiceSignatureValues{ContainerName: "container", Permissions: "rl"})
+		a.Empty(sip, loc.String())
+		a.Equal(string(blobsas.ProtocolHTTPS), spr, loc.String())
+
+		sip, spr = restrictions(GenericServiceSignatureValues{ContainerName: "container", Permissions: "rl", IPRange: ipRange, Protocol: blobsas.ProtocolHTTPSandHTTP})
+		a.Equal("192.0.2.0-192.0.2.255", sip, loc.String())
+		a.Equal(string(blobsas.ProtocolHTTPSandHTTP), spr, loc.String())
+
+		sip, spr = restrictions(GenericAccountSignatureValues{Permissions: "rl", IPRange: ipRange, Protocol: blobsas.ProtocolHTTPSandHTTP})
+		a.Equal("192.0.2.0-192.0.2.255", sip, loc.String())
+		a.Equal(string(blobsas.ProtocolHTTPSandHTTP), spr, loc.String())
+
+		a.Panics(func() {
+			restrictions(GenericServiceSignatureValues{ContainerName: "container", Permissions: "rl", Protocol: "http"})
+		}, loc.String())
+	}
+
+	a.Error(ValidateSASProtocol("http,https"))
+	a.Error(AccountSASOptions{Services: AccountSASServices{Blob: true}, ResourceTypes: blobsas.AccountResourceTypes{Object: true}, Protocol: "http"}.Validate())
+}
+
+func (s *SASRestrictionsSuite) Scenario_SnapshotScopedSAS(svm *ScenarioVariationManager) {
+	snapshotBody := NewRandomObjectContentContainer(svm, SizeFromString("1K"))
+	srcObj := CreateResource[ObjectResourceManager](svm, GetRootResource(svm, common.ELocation.Blob()), ResourceDefinitionObject{
+		ObjectName: pointerTo("test"),
+		Body:       snapshotBody,
+	})
+
+	if svm.Dryrun() {
+		return
+	}
+
+	srcBlob := GetTypeOrAssert[*BlobObjectResourceManager](svm, srcObj)
+	snapshot := srcBlob.WithSnapshot(svm, srcBlob.CreateSnapshot(svm))
+
+	// Overwrite the base blob, so that only the snapshot holds the original content.
+	srcObj.Create(svm, NewRandomObjectContentContainer(svm, SizeFromString("1K")), ObjectProperties{})
+
+	snapshotURI := snapshot.URI(GetURIOptions{AzureOpts: AzureURIOpts{
+		WithSAS:   true,
+		SASValues: GenericServiceSignatureValues{Permissions: (&blobsas.BlobPermissions{Read: true}).String()},
+	}})
+
+	// The SAS is scoped to the snapshot, so it mustn't grant access to the base blob.
+	_, query, _ := strings.Cut(snapshotURI, "?")
+	sasParams, err := url.ParseQuery(query)
+	svm.NoError("parse SAS", err)
+	sasParams.Del("snapshot")
+	baseClient, err := blob.NewClientWithNoCredential(blobStripSAS(srcObj.URI())+"?"+sasParams.Encode(), nil)
+	svm.NoError("create base blob client", err)
+	_, err = baseClient.GetProperties(ctx, nil)
+	svm.Assert("snapshot-scoped SAS must not grant access to the base blob", Not{IsNil{}}, err)
+
+	snapshotSASClient, err := blob.NewClientWithNoCredential(snapshotURI, nil)
+	svm.NoError("create snapshot client", err)
+	dlResp, err := snapshotSASClient.DownloadStream(ctx, nil)
+	svm.NoError("download snapshot with snapshot-scoped SAS", err)
+	defer dlResp.Body.Close()
+
+	content, err := io.ReadAll(dlResp.Body)
+	svm.NoError("read snapshot", err)
+	expected, err := io.ReadAll(snapshotBody.Reader())
+	svm.NoError("read expected body", err)
+	svm.Assert("snapshot content must match the original", Equal{Deep: true}, expected, content)
+}
+
+func TestApplySASSignedVersion(t *testing.T) {
+	a := assert.New(t)
+	acct := &AzureAccountResourceManager{accountName: "acct", accountKey: "YWNjb3VudGtleQ=="}
+
+	signedVersion := func(uri string, loc common.Location, vals GenericSignatureValues) string {
+		_, query, _ := strings.Cut(acct.ApplySAS(uri, loc, GetURIOptions{AzureOpts: AzureURIOpts{WithSAS: true, SASValues: vals}}), "?")
+		params, err := url.ParseQuery(query)
+		a.NoError(err)
+		return params.Get("sv")
+	}
+
+	for loc, tc := range map[common.Location]struct {
+		uri, sdkDefault string
+	}{
+		common.ELocation.Blob():   {"https://acct.blob.core.windows.net/container", blobsas.Version},
+		common.ELocation.File():   {"https://acct.file.core.windows.net/container", filesas.Version},
+		common.ELocation.BlobFS(): {"https://acct.dfs.core.windows.net/container", datalakesas.Version},
+	} {
+		// The SDKs' defaults must be signable, so a bump that moves one past sasSignedVersions is caught here.
+		a.NoError(ValidateSASVersion(tc.sdkDefault), loc.String())
+		a.Equal(tc.sdkDefault, signedVersion(tc.uri, loc, GenericServiceSignatureValues{ContainerName: "container", Permissions: "rl"}), loc.String())
+
+		for _, version := range []string{sasSignedVersions[0], "2021-06-08", sasSignedVersions[len(sasSignedVersions)-1]} {
+			a.Equal(version, signedVersion(tc.uri, loc, GenericServiceSignatureValues{ContainerName: "container", Permissions: "rl", SignedVersion: version}), loc.String())
+		}
+
+		a.Panics(func() {
+			signedVersion(tc.uri, loc, GenericServiceSignatureValues{ContainerName: "container", Permissions: "rl", SignedVersion: "2019-12-12"})
+		}, loc.String())
+	}
+
+	a.Equal("2022-11-02", signedVersion("https://acct.blob.core.windows.net/container", common.ELocation.Blob(), GenericAccountSignatureValues{Version: "2022-11-02"}))
+
+	for _, malformed := range []string{"2019-12-12", "2021-06-8", "latest", "2099-01-01"} {
+		a.Error(ValidateSASVersion(malformed), malformed)
+		a.Error(GenericServiceSignatureValues{SignedVersion: malformed}.Validate(), malformed)
+		a.Error(GenericAccountSignatureValues{Version: malformed}.Validate(), malformed)
+	}
+}
@@ -0,0 +1,332 @@
+package e2etest
+
+import (
+	"errors"
+	"io"
+	"sync/atomic"
+	"testing"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/blob"
+	"github.com/Azure/azure-storage-azcopy/v10/common"
+	"github.com/stretchr/testify/assert"
+)
+
+func init() {
+	suiteManager.RegisterSuite(&ScaleSuite{})
+}
+
+func TestSeededObjectContent(t *testing.T) {
+	a := assert.New(t)
+
+	content := NewSeededObjectContentContainer(42, 1000)
+	all, err := io.ReadAll(content.Reader())
+	a.NoError(err)
+	a.Len(all, 1000)
+
+	again, _ := io.ReadAll(NewSeededObjectContentContainer(42, 1000).Reader())
+	a.Equal(all, again)
+	other, _ := io.ReadAll(NewSeededObjectContentContainer(43, 1000).Reader())
+	a.NotEqual(all, other)
+
+	// Any range is the same however it's reached, unaligned to words or not.
+	for _, r := range []ContentRange{{Offset: 0, Count: 8}, {Offset: 3, Count: 13}, {Offset: 500, Count: 1}, {Offset: 990, Count: 10}} {
+		a.Equal(all[r.Offset:r.End()], content.ReadRange(r))
+
+		reader := content.Reader()
+		_, err = reader.Seek(r.Offset, io.SeekStart)
+		a.NoError(err)
+		buf := make([]byte, r.Count)
+		_, err = io.ReadFull(reader, buf)
+		a.NoError(err)
+		a.Equal(all[r.Offset:r.End()], buf)
+
+		n, err := reader.(io.ReaderAt).ReadAt(buf, r.Offset)
+		a.NoError(err)
+		a.EqualValues(r.Count, n)
+		a.Equal(all[r.Offset:r.End()], buf)
+	}
+
+	// Reads past the end are short.
+	a.Len(content.ReadRange(ContentRange{Offset: 995, Count: 10}), 5)
+	n, err := content.Reader().(io.ReaderAt).ReadAt(make([]byte, 10), 995)
+	a.Equal(5, n)
+	a.Equal(io.EOF, err)
+}
+
+func TestSparseObjectContent(t *testing.T) {
+	a := assert.New(t)
+	fa := NewFrameworkAsserter(t)
+
+	extents := []ContentRange{{Offset: 100, Count: 50}, {Offset: 600, Count: 200}}
+	content := NewSparseObjectContentContainer(fa, 7, 1000, extents)
+	a.Equal(extents, content.DataRanges())
+
+	all, _ := io.ReadAll(content.Reader())
+	dense, _ := io.ReadAll(NewSeededObjectContentContainer(7, 1000).Reader())
+	a.Len(all, 1000)
+	for i := range all {
+		inExtent := overlapsAny(extents, ContentRange{Offset: int64(i), Count: 1})
+		if inExtent {
+			a.Equal(dense[i], all[i], "byte %d holds the data of the same seed", i)
+		} else {
+			a.Zero(all[i], "byte %d lies in a hole", i)
+		}
+	}
+
+	// Dense content is all data.
+	a.Equal([]ContentRange{{Count: 1000}}, NewSeededObjectContentContainer(7, 1000).DataRanges())
+
+	for name, bad := range map[string][]ContentRange{
+		"empty":       {{Offset: 10, Count: 0}},
+		"overlapping": {{Offset: 0, Count: 100}, {Offset: 50, Count: 100}},
+		"unordered":   {{Offset: 500, Count: 10}, {Offset: 100, Count: 10}},
+		"past end":    {{Offset: 990, Count: 20}},
+	} {
+		a.Error(validateContentExtents(1000, bad), name)
+	}
+	a.NoError(validateContentExtents(1000, []ContentRange{{Offset: 0, Count: 500}, {Offset: 500, Count: 500}}))
+}
+
+func TestSeededObjectContentSampleRanges(t *testing.T) {
+	a := assert.New(t)
+	fa := NewFrameworkAsserter(t)
+
+	a.Nil(NewSeededObjectContentContainer(1, 1000).SampleRanges(), "unsampled content is verified in full")
+
+	content := NewSparseObjectContentContainer(fa, 1, 10000, []ContentRange{{Offset: 2048, Count: 512}}).Sampled(8, 64)
+	ranges := content.SampleRanges()
+	a.Equal(ranges, content.SampleRanges(), "samples are the same each time")
+	a.Len(ranges, 2+2+8)
+
+	offsets := map[int64]bool{}
+	for i, r := range ranges {
+		a.EqualValues(64, r.Count)
+		a.True(r.Offset >= 0 && r.End() <= 10000, "range %d+%d lies within the content", r.Offset, r.Count)
+		if i > 0 {
+			a.True(ranges[i-1].Offset <= r.Offset, "ranges are in order")
+		}
+		offsets[r.Offset] = true
+	}
+	for _, edge := range []int64{0, 10000 - 64, 2048 - 32, 2560 - 32} {
+		a.True(offsets[edge], "the range at %d is sampled", edge)
+	}
+
+	// Samples larger than the content cover all of it.
+	for _, r := range NewSeededObjectContentContainer(1, 10).Sampled(2, 64).SampleRanges() {
+		a.Equal(ContentRange{Count: 10}, r)
+	}
+}
+
+func TestMultiStepUploaderSparseContent(t *testing.T) {
+	a := assert.New(t)
+	fa := NewFrameworkAsserter(t)
+
+	content := NewSparseObjectContentContainer(fa, 3, 1000, []ContentRange{{Offset: 150, Count: 100}, {Offset: 900, Count: 100}})
+	uploaded := make([]byte, 1000)
+	var calls int32
+	var finalized bool
+	uploader := &MultiStepUploader{
+		BlockSize: 100,
+		Parallel:  true,
+		UploadRange: func(block io.ReadSeekCloser, state MultiStepUploaderState) error {
+			atomic.AddInt32(&calls, 1)
+			_, err := io.ReadFull(block, uploaded[state.Offset:state.Offset+state.BlockSize])
+			return err
+		},
+		Finalize: func() error {
+			a.EqualValues(3, atomic.LoadInt32(&calls), "every block is uploaded before finalizing")
+			finalized = true
+			return nil
+		},
+	}
+
+	a.NoError(uploader.UploadContents(content))
+	a.True(finalized)
+	a.EqualValues(3, calls, "only the blocks holding data are uploaded")
+	expected, _ := io.ReadAll(content.Reader())
+	a.Equal(expected, uploaded)
+
+	// A failing block fails the upload, and stops it being finalized.
+	failure := errors.New("service unavailable")
+	uploader = &MultiStepUploader{
+		BlockSize: 100,
+		Parallel:  true,
+		UploadRange: func(block io.ReadSeekCloser, state MultiStepUploaderState) error {
+			if state.BlockIndex == 4 {
+				return failure
+			}
+			return nil
+		},
+		Finalize: func() error {
+			a.Fail("must not finalize a failed upload")
+			return nil
+		},
+	}
+	a.ErrorIs(uploader.UploadContents(NewSeededObjectContentContainer(3, 1000)), failure)
+}
+
+func TestValidateSampledContent(t *testing.T) {
+	a := assert.New(t)
+	fa := NewFrameworkAsserter(t)
+
+	container := &LocalContainerResourceManager{RootPath: t.TempDir()}
+	content := NewSparseObjectContentContainer(fa, 9, 1<<20, []ContentRange{{Offset: 4096, Count: 8192}, {Offset: 1<<20 - 512, Count: 512}})
+	obj := container.GetObject(fa, "sparse", common.EEntityType.File())
+	obj.Create(fa, content, ObjectProperties{})
+
+	ranged := obj.(ObjectRangeDownloader)
+	a.EqualValues(1<<20, ranged.ContentLength(fa))
+	a.Equal(content.ReadRange(ContentRange{Offset: 4000, Count: 200}), ranged.DownloadRange(fa, 4000, 200))
+	a.Len(ranged.DownloadRange(fa, 1<<20-10, 100), 10, "ranges past the end are short")
+
+	ValidateResource[ObjectResourceManager](fa, obj, ResourceDefinitionObject{Body: content.Sampled(16, 256)}, true)
+
+	// A copy differing only in a byte the samples miss passes; a copy differing at a sampled edge doesn't.
+	full, _ := io.ReadAll(content.Reader())
+	modified := container.GetObject(fa, "modified", common.EEntityType.File())
+	changed := append([]byte{}, full...)
+	changed[4096] ^= 0xff
+	modified.Create(fa, NewStringObjectContentContainer(string(changed)), ObjectProperties{})
+
+	recorder := &failureRecorder{Asserter: fa}
+	ValidateSampledContent(recorder, modified.(ObjectRangeDownloader), content.Sampled(1, 256))
+	a.Len(recorder.failures, 1, "the edge of the first extent is always sampled")
+}
+
+// failureRecorder records the assertions which fail, rather than failing the test.
+type failureRecorder struct {
+	Asserter
+	failures []string
+}
+
+func (f *failureRecorder) Assert(comment string, assertion Assertion, items ...any) {
+	if !assertion.Assert(items...) {
+		f.failures = append(f.failures, comment)
+	}
+}
+
+func TestNewGeneratedObjectMapping(t *testing.T) {
+	a := assert.New(t)
+
+	flat := NewGeneratedObjectMapping(GeneratedObjectsOptions{Count: 25, Size: "1K", Seed: 100})
+	a.Len(flat, 25)
+	a.Contains(flat, "obj0000000")
+	a.Contains(flat, "obj0000024")
+
+	folders := NewGeneratedObjectMapping(GeneratedObjectsOptions{Count: 25, PerFolder: 10, Seed: 100})
+	a.Len(folders, 25)
+	a.Contains(folders, "dir00000/obj0000009")
+	a.Contains(folders, "dir00001/obj0000010")
+	a.Contains(folders, "dir00002/obj0000024")
+	a.EqualValues(0, folders["dir00000/obj0000000"].Body.Size())
+
+	// Each object has its own content, from consecutive seeds.
+	first, _ := io.ReadAll(flat["obj0000000"].Body.Reader())
+	second, _ := io.ReadAll(flat["obj0000001"].Body.Reader())
+	a.Len(first, 1024)
+	a.NotEqual(first, second)
+	expected, _ := io.ReadAll(NewSeededObjectContentContainer(101, 1024).Reader())
+	a.Equal(expected, second)
+	a.Equal(common.EEntityType.File(), flat["obj0000001"].EntityType)
+}
+
+// ScaleSuite covers the limits of a single job: the most blocks a blob can hold, blobs over 100GiB, and a million objects to scan.
+// It's slow and costly, so only runs with E2E_SCALE set. Its content is seeded, so it's never held in memory, and verified by sampling.
+type ScaleSuite struct{}
+
+const scaleSkipReason = "Scale scenarios only run with E2E_SCALE set"
+
+// Scenario_MaxBlockCount uploads a blob of exactly common.MaxNumberOfBlocksPerBlob blocks.
+func (s *ScaleSuite) Scenario_MaxBlockCount(svm *ScenarioVariationManager) {
+	if !GlobalConfig.ScaleEnabled() {
+		svm.Skip(scaleSkipReason)
+		return // dry runs don't skip, but there's no reason to generate the objects either
+	}
+
+	blockSize := int64(common.MegaByte / 4)
+	body := NewSeededObjectContentContainer(1, blockSize*common.MaxNumberOfBlocksPerBlob)
+
+	srcObj := CreateResource[ObjectResourceManager](svm, GetRootResource(svm, common.ELocation.Local()), ResourceDefinitionObject{Body: body})
+	dstObj := CreateResource[ContainerResourceManager](svm, GetRootResource(svm, common.ELocation.Blob()), ResourceDefinitionContainer{}).
+		GetObject(svm, "maxblocks", common.EEntityType.File())
+
+	stdout, _ := RunAzCopy(svm, AzCopyCommand{
+		Verb:    AzCopyVerbCopy,
+		Targets: []ResourceManager{srcObj, dstObj},
+		Flags: CopyFlags{
+			CopySyncCommonFlags: CopySyncCommonFlags{
+				BlockSizeMB: pointerTo(float64(blockSize) / common.MegaByte),
+			},
+		},
+	})
+	ValidateJobStatus(svm, stdout, common.EJobStatus.Completed())
+
+	ValidateResource[ObjectResourceManager](svm, dstObj, ResourceDefinitionObject{Body: body.Sampled(64, common.MegaByte)}, true)
+}
+
+// Scenario_SparsePageBlob copies a page blob of over 100GiB, of which only a few pages hold data; it's created and copied by
+// page range, so only the data is ever uploaded.
+func (s *ScaleSuite) Scenario_SparsePageBlob(svm *ScenarioVariationManager) {
+	if !GlobalConfig.ScaleEnabled() {
+		svm.Skip(scaleSkipReason)
+		return
+	}
+
+	const gibibyte = 1024 * common.MegaByte
+	extent := int64(4 * common.MegaByte)
+	size := int64(101 * gibibyte)
+	body := NewSparseObjectContentContainer(svm, 2, size, []ContentRange{
+		{Offset: 0, Count: extent},
+		{Offset: 37 * gibibyte, Count: extent},
+		{Offset: 100 * gibibyte, Count: extent}, // past 100GiB
+		{Offset: size - extent, Count: extent},
+	})
+	props := ObjectProperties{BlobProperties: BlobProperties{Type: pointerTo(blob.BlobTypePageBlob)}}
+
+	srcObj := CreateResource[ObjectResourceManager](svm, GetRootResource(svm, common.ELocation.Blob()), ResourceDefinitionObject{
+		ObjectProperties: props,
+		Body:             body,
+	})
+	dstObj := CreateResource[ContainerResourceManager](svm, GetRootResource(svm, common.ELocation.Blob()), ResourceDefinitionContainer{}).
+		GetObject(svm, "sparse", common.EEntityType.File())
+
+	stdout, _ := RunAzCopy(svm, AzCopyCommand{
+		Verb:    AzCopyVerbCopy,
+		Targets: []ResourceManager{srcObj, dstObj},
+	})
+	ValidateJobStatus(svm, stdout, common.EJobStatus.Completed())
+
+	ValidateResource[ObjectResourceManager](svm, dstObj, ResourceDefinitionObject{
+		ObjectProperties: props,
+		Body:             body.Sampled(64, common.MegaByte),
+	}, true)
+}
+
+// Scenario_ManyTinyObjects uploads a million tiny objects, to exercise enumeration and scheduling rather than throughput.
+func (s *ScaleSuite) Scenario_ManyTinyObjects(svm *ScenarioVariationManager) {
+	if !GlobalConfig.ScaleEnabled() {
+		svm.Skip(scaleSkipReason)
+		return
+	}
+
+	const count = 1_000_000
+	objects := NewGeneratedObjectMapping(GeneratedObjectsOptions{Count: count, PerFolder: 10_000, Size: "1K", Seed: 3})
+
+	srcContainer := CreateResource[ContainerResourceManager](svm, GetRootResource(svm, common.ELocation.Local()), ResourceDefinitionContainer{Objects: objects})
+	dstContainer := CreateResource[ContainerResourceManager](svm, GetRootResource(svm, common.ELocation.Blob()), ResourceDefinitionContainer{})
+
+	stdout, _ := RunAzCopy(svm, AzCopyCommand{
+		Verb:    AzCopyVerbCopy,
+		Targets: []ResourceManager{srcContainer, dstContainer},
+		Flags: CopyFlags{
+			CopySyncCommonFlags: CopySyncCommonFlags{
+				Recursive: pointerTo(true),
+			},
+			AsSubdir: pointerTo(false),
+		},
+	})
+	ValidateJobStatus(svm, stdout, common.EJobStatus.Completed())
+
+	// Checking each object would take longer than the copy; the job has already accounted for every one of them.
+	svm.Assert("transfers completed", Equal{}, stdout.JobSummary.TransfersCompleted, uint32(count))
+}
@@ -0,0 +1,186 @@
+package e2etest
+
+import (
+	"encoding/xml"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	blobservice "github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/service"
+	blobfsservice "github.com/Azure/azure-sdk-for-go/sdk/storage/azdatalake/service"
+	fileservice "github.com/Azure/azure-sdk-for-go/sdk/storage/azfile/service"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeServiceProperties serves a service's properties like the real thing: each top-level element that's set replaces the
+// element of that name, and those left out are left as they are.
+type fakeServiceProperties struct {
+	mut      sync.Mutex
+	order    []string
+	elements map[string]string // name to inner XML
+	sets     int
+}
+
+func newFakeServiceProperties(initial string) *fakeServiceProperties {
+	f := &fakeServiceProperties{elements: map[string]string{}}
+	f.merge([]byte("<StorageServiceProperties>" + initial + "</StorageServiceProperties>"))
+	f.sets = 0
+	return f
+}
+
+func (f *fakeServiceProperties) merge(body []byte) {
+	var props struct {
+		Elements []struct {
+			XMLName xml.Name
+			Inner   string `xml:",innerxml"`
+		} `xml:",any"`
+	}
+	_ = xml.Unmarshal(body, &props)
+
+	for _, e := range props.Elements {
+		if _, ok := f.elements[e.XMLName.Local]; !ok {
+			f.order = append(f.order, e.XMLName.Local)
+		}
+		f.elements[e.XMLName.Local] = e.Inner
+	}
+	f.sets++
+}
+
+func (f *fakeServiceProperties) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mut.Lock()
+	defer f.mut.Unlock()
+
+	if r.URL.Query().Get("comp") != "properties" {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodPut:
+		body, _ := io.ReadAll(r.Body)
+		f.merge(body)
+		w.WriteHeader(http.StatusAccepted)
+	case http.MethodGet:
+		out := `<?xml version="1.0" encoding="utf-8"?><StorageServiceProperties>`
+		for _, name := range f.order {
+			out += "<" + name + ">" + f.elements[name] + "</" + name + ">"
+		}
+		_, _ = w.Write([]byte(out + "</StorageServiceProperties>"))
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func TestBlobServiceProperties(t *testing.T) {
+	a := assert.New(t)
+	fa := NewFrameworkAsserter(t)
+
+	const initial = `<Logging><Version>1.0</Version><Read>true</Read></Logging>` +
+		`<DeleteRetentionPolicy><Enabled>false</Enabled></DeleteRetentionPolicy>` +
+		`<StaticWebsite><Enabled>false</Enabled></StaticWebsite><Cors></Cors>`
+	acct := &AzureAccountResourceManager{accountName: "acct", accountKey: "YWNjb3VudGtleQ=="}
+
+	for name, newService := range map[string]func(uri string) ServiceResourceManager{
+		"Blob": func(uri string) ServiceResourceManager {
+			client, err := blobservice.NewClientWithNoCredential(uri, nil)
+			a.NoError(err)
+			return &BlobServiceResourceManager{internalAccount: acct, internalClient: client}
+		},
+		"BlobFS": func(uri string) ServiceResourceManager {
+			client, err := blobfsservice.NewClientWithNoCredential(uri, nil)
+			a.NoError(err)
+			return &BlobFSServiceResourceManager{internalAccount: acct, internalClient: client}
+		},
+	} {
+		fake := newFakeServiceProperties(initial)
+		srv := httptest.NewServer(fake)
+		defer srv.Close()
+		svc := newService(srv.URL + "/acct/")
+
+		original := GetServiceProperties(fa, svc)
+		a.Equal(ServiceProperties{
+			CORS: []ServiceCORSRule{},
+			BlobServiceProperties: BlobServiceProperties{
+				DeleteRetentionDays: pointerTo(int32(0)),
+				StaticWebsite:       &BlobStaticWebsite{},
+			},
+		}, original, name)
+
+		want := ServiceProperties{
+			CORS: []ServiceCORSRule{{AllowedOrigins: "https://example.com", AllowedMethods: "GET,HEAD", AllowedHeaders: "*", ExposedHeaders: "x-ms-*", MaxAgeInSeconds: 60}},
+			BlobServiceProperties: BlobServiceProperties{
+				DefaultServiceVersion: pointerTo("2021-08-06"),
+				DeleteRetentionDays:   pointerTo(int32(7)),
+				StaticWebsite:         &BlobStaticWebsite{Enabled: true, IndexDocument: "index.html", ErrorDocument404Path: "404.html"},
+			},
+		}
+		revert := SetServiceProperties(fa, svc, want)
+		a.Equal(want, GetServiceProperties(fa, svc), name)
+		a.Contains(fake.elements["Logging"], "<Read>true</Read>", "%s: properties left unset must be left as is", name)
+
+		// What SetProperties replaced puts everything back, but the default service version, which can't be cleared.
+		a.Equal(ServiceProperties{
+			CORS:                  []ServiceCORSRule{},
+			BlobServiceProperties: BlobServiceProperties{DeleteRetentionDays: pointerTo(int32(0)), StaticWebsite: &BlobStaticWebsite{}},
+		}, revert, name)
+		svc.(ServicePropertiesManager).SetProperties(fa, revert)
+		restored := GetServiceProperties(fa, svc)
+		a.Equal(original.CORS, restored.CORS, name)
+		a.Equal(original.BlobServiceProperties.DeleteRetentionDays, restored.BlobServiceProperties.DeleteRetentionDays, name)
+		a.Equal(original.BlobServiceProperties.StaticWebsite, restored.BlobServiceProperties.StaticWebsite, name)
+	}
+
+	// Scenarios have what they set restored as they end, in reverse, so the original values win.
+	fake := newFakeServiceProperties(initial)
+	srv := httptest.NewServer(fake)
+	defer srv.Close()
+	client, err := blobservice.NewClientWithNoCredential(srv.URL+"/acct/", nil)
+	a.NoError(err)
+	svc := &BlobServiceResourceManager{internalAccount: acct, internalClient: client}
+	t.Run("Scenario", func(t *testing.T) {
+		svm := &ScenarioVariationManager{t: t}
+		svc.EnableSoftDelete(svm, 7)
+		SetServiceProperties(svm, svc, ServiceProperties{BlobServiceProperties: BlobServiceProperties{DeleteRetentionDays: pointerTo(int32(30))}})
+		a.Equal(int32(30), *svc.GetProperties(svm).BlobServiceProperties.DeleteRetentionDays)
+	})
+	a.Equal(int32(0), *svc.GetProperties(fa).BlobServiceProperties.DeleteRetentionDays)
+	a.Contains(fake.elements["DeleteRetentionPolicy"], "<Enabled>false</Enabled>")
+
+	// Dry runs never reach the service.
+	sets := fake.sets
+	a.Equal(ServiceProperties{}, SetServiceProperties(&ScenarioVariationManager{}, nil, ServiceProperties{CORS: []ServiceCORSRule{}}))
+	a.Equal(sets, fake.sets)
+}
+
+func TestFileServiceProperties(t *testing.T) {
+	a := assert.New(t)
+	fa := NewFrameworkAsserter(t)
+
+	fake := newFakeServiceProperties(`<Cors><CorsRule><AllowedOrigins>*</AllowedOrigins><AllowedMethods>GET</AllowedMethods>` +
+		`<AllowedHeaders></AllowedHeaders><ExposedHeaders></ExposedHeaders><MaxAgeInSeconds>5</MaxAgeInSeconds></CorsRule></Cors>` +
+		`<ProtocolSettings><SMB><Multichannel><Enabled>false</Enabled></Multichannel></SMB></ProtocolSettings>`)
+	srv := httptest.NewServer(fake)
+	defer srv.Close()
+
+	client, err := fileservice.NewClientWithNoCredential(srv.URL+"/acct/", nil)
+	a.NoError(err)
+	svc := &FileServiceResourceManager{internalAccount: &AzureAccountResourceManager{accountName: "acct"}, internalClient: client}
+
+	original := GetServiceProperties(fa, svc)
+	a.Equal(ServiceProperties{
+		CORS:                  []ServiceCORSRule{{AllowedOrigins: "*", AllowedMethods: "GET", MaxAgeInSeconds: 5}},
+		FileServiceProperties: FileServiceProperties{SMBMultichannel: pointerTo(false)},
+	}, original)
+
+	// An empty set of rules clears them, rather than leaving them be.
+	revert := SetServiceProperties(fa, svc, ServiceProperties{CORS: []ServiceCORSRule{}, FileServiceProperties: FileServiceProperties{SMBMultichannel: pointerTo(true)}})
+	a.Equal(ServiceProperties{CORS: []ServiceCORSRule{}, FileServiceProperties: FileServiceProperties{SMBMultichannel: pointerTo(true)}}, GetServiceProperties(fa, svc))
+	a.Equal(original, revert)
+
+	svc.SetProperties(fa, revert)
+	a.Equal(original, GetServiceProperties(fa, svc))
+	a.True(strings.Contains(fake.elements["Cors"], "<AllowedOrigins>*</AllowedOrigins>"))
+}
@@ -0,0 +1,44 @@
+package e2etest
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-storage-azcopy/v10/common"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUserDelegationKeyWindow(t *testing.T) {
+	a := assert.New(t)
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	// Short windows are kept as-is.
+	keyStart, keyExpiry := userDelegationKeyWindow(start, start.Add(time.Hour))
+	a.Equal(start, keyStart)
+	a.Equal(start.Add(time.Hour), keyExpiry)
+
+	// The key, and the SAS signed with it, can't outlive 7 days.
+	keyStart, keyExpiry = userDelegationKeyWindow(start, start.Add(time.Hour*24*30))
+	a.Equal(start, keyStart)
+	a.Equal(start.Add(maxUserDelegationKeyLifetime), keyExpiry)
+}
+
+func TestUserDelegationSASRejectsUnsupportedRequests(t *testing.T) {
+	a := assert.New(t)
+	acct := &AzureAccountResourceManager{accountName: "acct", tokenCredential: NewOAuthCache(nil, "")} // never asked for a token; every request is rejected first
+	opts := GetURIOptions{AzureOpts: AzureURIOpts{WithSAS: true, UseUserDelegation: true}}
+
+	a.PanicsWithValue("Azure Files does not support user delegation SAS; sign with the account key instead.", func() {
+		acct.ApplySAS("https://acct.file.core.windows.net/share", common.ELocation.File(), opts)
+	})
+
+	opts.AzureOpts.SASValues = GenericAccountSignatureValues{}
+	a.PanicsWithValue("User delegation can only sign service SAS tokens; account SAS tokens require the account key.", func() {
+		acct.ApplySAS("https://acct.blob.core.windows.net/container", common.ELocation.Blob(), opts)
+	})
+
+	acct.tokenCredential = nil
+	a.PanicsWithValue("Account acct has no token credential to request a user delegation key with.", func() {
+		acct.ApplySAS("https://acct.blob.core.windows.net/container", common.ELocation.Blob(), opts)
+	})
+}
@@ -462,6 +462,7 @@ func GetJobSummary(jobID common.JobID) common.ListJobSummaryResponse {
 		js.NetworkErrorPercentage = pipeStats.NetworkErrorPercentage()
 		js.ServerBusyPercentage = pipeStats.TotalServerBusyPercentage()
 	}
+	js.SetTokenRefreshStats(common.AuthMetrics.Stats())
 
 	// If the status is cancelled, then no need to check for completerJobOrdered
 	// since user must have provided the consent to cancel an incompleteJob if that
@@ -599,6 +600,7 @@ func resurrectJobSummary(jm ste.IJobMgr) common.ListJobSummaryResponse {
 		js.NetworkErrorPercentage = pipeStats.NetworkErrorPercentage()
 		js.ServerBusyPercentage = pipeStats.TotalServerBusyPercentage()
 	}
+	js.SetTokenRefreshStats(common.AuthMetrics.Stats())
 
 	// If the status is cancelled, then no need to check for completerJobOrdered
 	// since user must have provided the consent to cancel an incompleteJob if that
@@ -728,10 +730,13 @@ func GetJobFromTo(r common.GetJobFromToRequest) common.GetJobFromToResponse {
 		}
 	}
 
+	sourceLoginType, sourceTenantID := jp0.Plan().S2SSourceLogin()
 	return common.GetJobFromToResponse{
-		ErrorMsg:    "",
-		FromTo:      jp0.Plan().FromTo,
-		Source:      source,
-		Destination: destination,
+		ErrorMsg:        "",
+		FromTo:          jp0.Plan().FromTo,
+		Source:          source,
+		Destination:     destination,
+		SourceLoginType: sourceLoginType,
+		SourceTenantID:  sourceTenantID,
 	}
 }
@@ -13,7 +13,7 @@ import (
 // dataSchemaVersion defines the data schema version of JobPart order files supported by
 // current version of azcopy
 // To be Incremented every time when we release azcopy with changed dataSchema
-const DataSchemaVersion common.Version = 18
+const DataSchemaVersion common.Version = 19
 
 const (
 	CustomHeaderMaxBytes = 256
@@ -90,6 +90,12 @@ type JobPartPlanHeader struct {
 	S2SInvalidMetadataHandleOption common.InvalidMetadataHandleOption
 	// BlobFSRecursiveDelete represents whether the user wants to make a recursive call to the DFS endpoint or not
 	BlobFSRecursiveDelete bool
+	// S2SSourceLoginType and S2SSourceTenantID record how the source of a service to service transfer authenticates,
+	// when it doesn't use the login of the destination, so that resuming the job authenticates each side as before.
+	S2SSourceLoginTypeLength uint16
+	S2SSourceLoginType       [CustomHeaderMaxBytes]byte
+	S2SSourceTenantIDLength  uint16
+	S2SSourceTenantID        [CustomHeaderMaxBytes]byte
 
 	// Any fields below this comment are NOT constants; they may change over as the job part is processed.
 	// Care must be taken to read/write to these fields in a thread-safe way!
@@ -127,6 +133,11 @@ func (jpph *JobPartPlanHeader) SetJobPartStatus(newJobStatus common.JobStatus) {
 	jpph.atomicPartStatus.AtomicStore(newJobStatus)
 }
 
+// S2SSourceLogin returns how the source of a service to service transfer logs in, see CopyJobPartOrderRequest.S2SSourceLoginType.
+func (jpph *JobPartPlanHeader) S2SSourceLogin() (loginType string, tenantID string) {
+	return string(jpph.S2SSourceLoginType[:jpph.S2SSourceLoginTypeLength]), string(jpph.S2SSourceTenantID[:jpph.S2SSourceTenantIDLength])
+}
+
 // Transfer api gives memory map JobPartPlanTransfer header for given index
 func (jpph *JobPartPlanHeader) Transfer(transferIndex uint32) *JobPartPlanTransfer {
 	// get memory map JobPartPlan Header Pointer
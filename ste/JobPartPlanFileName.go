@@ -93,6 +93,12 @@ func (jpfn JobPartPlanFileName) Create(order common.CopyJobPartOrderRequest) {
 	if len(order.DestinationRoot.ExtraQuery) > len(JobPartPlanHeader{}.DestExtraQuery) {
 		panic(fmt.Errorf("destination extra query strings too large: %q", order.DestinationRoot.ExtraQuery))
 	}
+	if len(order.S2SSourceLoginType) > len(JobPartPlanHeader{}.S2SSourceLoginType) {
+		panic(fmt.Errorf("source login type string is too large: %q", order.S2SSourceLoginType))
+	}
+	if len(order.S2SSourceTenantID) > len(JobPartPlanHeader{}.S2SSourceTenantID) {
+		panic(fmt.Errorf("source tenant ID string is too large: %q", order.S2SSourceTenantID))
+	}
 	if len(order.BlobAttributes.ContentType) > len(JobPartPlanDstBlob{}.ContentType) {
 		panic(fmt.Errorf("content type string is too large: %q", order.BlobAttributes.ContentType))
 	}
@@ -220,6 +226,8 @@ func (jpfn JobPartPlanFileName) Create(order common.CopyJobPartOrderRequest) {
 		S2SInvalidMetadataHandleOption: order.S2SInvalidMetadataHandleOption,
 		DestLengthValidation:           order.DestLengthValidation,
 		BlobFSRecursiveDelete:          order.BlobFSRecursiveDelete,
+		S2SSourceLoginTypeLength:       uint16(len(order.S2SSourceLoginType)),
+		S2SSourceTenantIDLength:        uint16(len(order.S2SSourceTenantID)),
 		atomicJobStatus:                common.EJobStatus.InProgress(), // We default to InProgress
 		DeleteSnapshotsOption:          order.BlobAttributes.DeleteSnapshotsOption,
 		PermanentDeleteOption:          order.BlobAttributes.PermanentDeleteOption,
@@ -235,6 +243,8 @@ func (jpfn JobPartPlanFileName) Create(order common.CopyJobPartOrderRequest) {
 	copy(jpph.SourceExtraQuery[:], order.SourceRoot.ExtraQuery)
 	copy(jpph.DestinationRoot[:], order.DestinationRoot.Value)
 	copy(jpph.DestExtraQuery[:], order.DestinationRoot.ExtraQuery)
+	copy(jpph.S2SSourceLoginType[:], order.S2SSourceLoginType)
+	copy(jpph.S2SSourceTenantID[:], order.S2SSourceTenantID)
 	copy(jpph.DstBlobData.ContentType[:], order.BlobAttributes.ContentType)
 	copy(jpph.DstBlobData.ContentEncoding[:], order.BlobAttributes.ContentEncoding)
 	copy(jpph.DstBlobData.ContentLanguage[:], order.BlobAttributes.ContentLanguage)
@@ -0,0 +1,53 @@
+// Copyright © 2017 Microsoft <wastore@microsoft.com>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package ste
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/Azure/azure-storage-azcopy/v10/common"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestJobPartPlanPersistsSourceLogin(t *testing.T) {
+	a := assert.New(t)
+	planFolder := common.AzcopyJobPlanFolder
+	common.AzcopyJobPlanFolder = t.TempDir()
+	defer func() { common.AzcopyJobPlanFolder = planFolder }()
+
+	order := common.CopyJobPartOrderRequest{
+		JobID:              common.NewJobID(),
+		FromTo:             common.EFromTo.BlobBlob(),
+		SourceRoot:         common.ResourceString{Value: "https://source.blob.core.windows.net/container"},
+		DestinationRoot:    common.ResourceString{Value: "https://destination.blob.core.windows.net/container"},
+		S2SSourceLoginType: common.AutologinTypeMSI,
+		S2SSourceTenantID:  "tenant-a",
+	}
+	jpfn := JobPartPlanFileName(fmt.Sprintf(JobPartPlanFileNameFormat, order.JobID.String(), 0, DataSchemaVersion))
+	jpfn.Create(order)
+
+	mmf := jpfn.Map()
+	defer mmf.Unmap()
+	loginType, tenantID := mmf.Plan().S2SSourceLogin()
+	a.Equal(common.AutologinTypeMSI, loginType)
+	a.Equal("tenant-a", tenantID)
+}
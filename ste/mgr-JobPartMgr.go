@@ -80,25 +80,15 @@ type IJobPartMgr interface {
 // number of available network sockets on resource-constrained Linux systems. (E.g. when
 // 'ulimit -Hn' is low).
 func NewAzcopyHTTPClient(maxIdleConns int) *http.Client {
+	options := common.HTTPClientOptions{
+		DialTimeout:         30 * time.Second,
+		KeepAlive:           30 * time.Second,
+		TLSHandshakeTimeout: 10 * time.Second,
+		IdleConnTimeout:     180 * time.Second,
+		MaxIdleConnsPerHost: maxIdleConns,
+	}.WithEnvironment()
 	return &http.Client{
-		Transport: &http.Transport{
-			Proxy: common.GlobalProxyLookup,
-			DialContext: newDialRateLimiter(&net.Dialer{
-				Timeout:   30 * time.Second,
-				KeepAlive: 30 * time.Second,
-				DualStack: true,
-			}).DialContext,
-			MaxIdleConns:           0, // No limit
-			MaxIdleConnsPerHost:    maxIdleConns,
-			IdleConnTimeout:        180 * time.Second,
-			TLSHandshakeTimeout:    10 * time.Second,
-			ExpectContinueTimeout:  1 * time.Second,
-			DisableKeepAlives:      false,
-			DisableCompression:     true, // must disable the auto-decompression of gzipped files, and just download the gzipped version. See https://github.com/Azure/azure-storage-azcopy/issues/374
-			MaxResponseHeaderBytes: 0,
-			// ResponseHeaderTimeout:  time.Duration{},
-			// ExpectContinueTimeout:  time.Duration{},
-		},
+		Transport: options.NewTransport(newDialRateLimiter(options.Dialer()).DialContext),
 	}
 }
 
@@ -120,7 +110,7 @@ func newDialRateLimiter(dialer *net.Dialer) *dialRateLimiter {
 }
 
 func (d *dialRateLimiter) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
-	err := d.sem.Acquire(context.Background(), 1)
+	err := d.sem.Acquire(ctx, 1)
 	if err != nil {
 		return nil, err
 	}